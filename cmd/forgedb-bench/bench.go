@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"monolithdb/internal/db"
+)
+
+// config 是所有负载共用的参数。
+type config struct {
+	num       int // fill 写入的 key 数，也是其他负载的 key 空间
+	reads     int // read 负载的读取次数
+	keySize   int
+	valueSize int
+	threads   int
+	seed      uint64
+}
+
+// workload 在 d 上执行一种负载，把每次操作的延迟和字节数记录到 r。
+type workload func(d *db.DB, cfg config, r *result) error
+
+var workloads = map[string]workload{
+	"fillseq":          fillSeq,
+	"fillrandom":       fillRandom,
+	"readrandom":       readRandom,
+	"readwhilewriting": readWhileWriting,
+}
+
+func workloadNames() []string {
+	names := make([]string, 0, len(workloads))
+	for name := range workloads {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// result 是一次负载的统计。
+type result struct {
+	name    string
+	elapsed time.Duration
+	bytes   atomic.Int64
+	found   atomic.Int64 // 读负载中命中的次数
+
+	mu  sync.Mutex
+	lat []time.Duration // 每次（被测）操作的延迟
+}
+
+func (r *result) record(lat []time.Duration) {
+	r.mu.Lock()
+	r.lat = append(r.lat, lat...)
+	r.mu.Unlock()
+}
+
+// String 按 db_bench 的风格输出一行：每次操作的平均耗时、吞吐和延迟分位数。
+func (r *result) String() string {
+	ops := len(r.lat)
+	if ops == 0 {
+		return fmt.Sprintf("%-16s : no operations", r.name)
+	}
+	slices.Sort(r.lat)
+	secs := r.elapsed.Seconds()
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-16s : %9.3f micros/op %9.0f ops/sec", r.name, secs*1e6/float64(ops), float64(ops)/secs)
+	if n := r.bytes.Load(); n > 0 {
+		fmt.Fprintf(&b, " %7.1f MB/s", float64(n)/(1<<20)/secs)
+	}
+	fmt.Fprintf(&b, "; p50 %v p95 %v p99 %v max %v", r.percentile(50), r.percentile(95), r.percentile(99), r.lat[ops-1])
+	if strings.HasPrefix(r.name, "read") {
+		fmt.Fprintf(&b, " (%d of %d found)", r.found.Load(), ops)
+	}
+	return b.String()
+}
+
+// percentile 返回已排序延迟的第 p 百分位（nearest-rank）。
+func (r *result) percentile(p float64) time.Duration {
+	i := int(float64(len(r.lat))*p/100+0.5) - 1
+	return r.lat[max(0, min(i, len(r.lat)-1))]
+}
+
+// run 执行名为 name 的负载。
+func run(d *db.DB, name string, cfg config) (*result, error) {
+	r := &result{name: name}
+	start := time.Now()
+	err := workloads[name](d, cfg, r)
+	r.elapsed = time.Since(start)
+	return r, err
+}
+
+// parallel 在 cfg.threads 个 goroutine 里共同执行 n 次 op，i 是全局的操作编号。
+// 每个线程有自己的随机数生成器，同一个 seed 的操作序列可以重现。
+func parallel(cfg config, n int, r *result, op func(i int, rng *rand.Rand) error) error {
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	errs := make([]error, cfg.threads)
+	for t := range cfg.threads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewPCG(cfg.seed, uint64(t)))
+			lat := make([]time.Duration, 0, n/cfg.threads+1)
+			defer func() { r.record(lat) }()
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= n {
+					return
+				}
+				begin := time.Now()
+				if err := op(i, rng); err != nil {
+					errs[t] = err
+					return
+				}
+				lat = append(lat, time.Since(begin))
+			}
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// key 把编号格式化成补零到 keySize 的十进制字符串。
+func key(cfg config, i int) string {
+	s := strconv.Itoa(i)
+	if len(s) >= cfg.keySize {
+		return s
+	}
+	return strings.Repeat("0", cfg.keySize-len(s)) + s
+}
+
+// valueGen 提供随机内容的 value：预先生成一段随机字节，每次从随机位置截取。
+type valueGen struct {
+	data []byte
+	size int
+}
+
+func newValueGen(cfg config) *valueGen {
+	rng := rand.New(rand.NewPCG(cfg.seed, ^uint64(0)))
+	data := make([]byte, max(1<<20, 2*cfg.valueSize))
+	for i := range data {
+		data[i] = byte(rng.Uint32())
+	}
+	return &valueGen{data: data, size: cfg.valueSize}
+}
+
+func (g *valueGen) next(rng *rand.Rand) []byte {
+	off := rng.IntN(len(g.data) - g.size + 1)
+	return g.data[off : off+g.size]
+}
+
+func fill(d *db.DB, cfg config, r *result, keyAt func(i int, rng *rand.Rand) int) error {
+	values := newValueGen(cfg)
+	return parallel(cfg, cfg.num, r, func(i int, rng *rand.Rand) error {
+		k := key(cfg, keyAt(i, rng))
+		r.bytes.Add(int64(len(k) + cfg.valueSize))
+		return d.Put(k, values.next(rng))
+	})
+}
+
+func fillSeq(d *db.DB, cfg config, r *result) error {
+	return fill(d, cfg, r, func(i int, _ *rand.Rand) int { return i })
+}
+
+func fillRandom(d *db.DB, cfg config, r *result) error {
+	return fill(d, cfg, r, func(_ int, rng *rand.Rand) int { return rng.IntN(cfg.num) })
+}
+
+func readRandom(d *db.DB, cfg config, r *result) error {
+	return parallel(cfg, cfg.reads, r, func(_ int, rng *rand.Rand) error {
+		v, ok, err := d.Get(key(cfg, rng.IntN(cfg.num)))
+		if ok {
+			r.found.Add(1)
+			r.bytes.Add(int64(cfg.keySize + len(v)))
+		}
+		return err
+	})
+}
+
+// readWhileWriting 在 cfg.threads 个线程随机读的同时，用一个额外的线程持续随机写，
+// 只统计读的延迟和吞吐。
+func readWhileWriting(d *db.DB, cfg config, r *result) error {
+	stop := make(chan struct{})
+	writeErr := make(chan error, 1)
+	go func() {
+		values := newValueGen(cfg)
+		rng := rand.New(rand.NewPCG(cfg.seed, uint64(cfg.threads)))
+		for {
+			select {
+			case <-stop:
+				writeErr <- nil
+				return
+			default:
+			}
+			if err := d.Put(key(cfg, rng.IntN(cfg.num)), values.next(rng)); err != nil {
+				writeErr <- err
+				return
+			}
+		}
+	}()
+	err := readRandom(d, cfg, r)
+	close(stop)
+	if werr := <-writeErr; err == nil {
+		err = werr
+	}
+	return err
+}
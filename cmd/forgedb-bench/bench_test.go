@@ -0,0 +1,62 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"monolithdb/internal/db"
+)
+
+func TestWorkloads(t *testing.T) {
+	d, err := db.OpenWithOptions(filepath.Join(t.TempDir(), "data"), db.Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	cfg := config{num: 500, reads: 300, keySize: 16, valueSize: 32, threads: 3, seed: 7}
+	for _, name := range workloadNames() {
+		res, err := run(d, name, cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		want := cfg.num
+		if strings.HasPrefix(name, "read") {
+			want = cfg.reads
+		}
+		if len(res.lat) != want {
+			t.Fatalf("%s recorded %d operations, want %d", name, len(res.lat), want)
+		}
+		line := res.String()
+		if !strings.HasPrefix(line, name) || !strings.Contains(line, "ops/sec") || !strings.Contains(line, "p99") {
+			t.Fatalf("%s report = %q", name, line)
+		}
+	}
+
+	// fillseq 写入了所有 key，之后的随机读应当全部命中
+	res, err := run(d, "readrandom", cfg)
+	if err != nil || res.found.Load() != int64(cfg.reads) {
+		t.Fatalf("readrandom found %d of %d, %v", res.found.Load(), cfg.reads, err)
+	}
+	if v, ok, _ := d.Get(key(cfg, 42)); !ok || len(v) != cfg.valueSize {
+		t.Fatalf("Get(%q) = %d bytes, %v", key(cfg, 42), len(v), ok)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	r := &result{name: "x", elapsed: time.Second}
+	for i := 1; i <= 100; i++ {
+		r.lat = append(r.lat, time.Duration(i)*time.Millisecond)
+	}
+	if got := r.percentile(50); got != 50*time.Millisecond {
+		t.Fatalf("p50 = %v", got)
+	}
+	if got := r.percentile(99); got != 99*time.Millisecond {
+		t.Fatalf("p99 = %v", got)
+	}
+	if key(config{keySize: 6}, 42) != "000042" {
+		t.Fatalf("key = %q", key(config{keySize: 6}, 42))
+	}
+}
@@ -0,0 +1,76 @@
+// forgedb-bench 对 ForgeDB 运行标准的读写负载，报告吞吐和延迟分位数，用于比较配置调优和版本之间的回归。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"monolithdb/internal/db"
+)
+
+func main() {
+	dir := flag.String("dir", "", "data directory (empty = a temporary directory removed on exit)")
+	benchmarks := flag.String("benchmarks", "fillseq,fillrandom,readrandom,readwhilewriting", "comma-separated workloads: "+strings.Join(workloadNames(), ", "))
+	num := flag.Int("num", 100000, "number of keys written by fill workloads, and the key space of the others")
+	reads := flag.Int("reads", 0, "number of reads in read workloads (0 = -num)")
+	keySize := flag.Int("key-size", 16, "key size in bytes")
+	valueSize := flag.Int("value-size", 100, "value size in bytes")
+	threads := flag.Int("threads", 1, "number of concurrent goroutines per workload")
+	seed := flag.Uint64("seed", 1, "random seed")
+	fsync := flag.Bool("fsync", false, "fsync the WAL on every write")
+	walCompression := flag.Bool("wal-compression", false, "compress large WAL records")
+	flag.Parse()
+
+	cfg := config{
+		num:       *num,
+		reads:     *reads,
+		keySize:   *keySize,
+		valueSize: *valueSize,
+		threads:   *threads,
+		seed:      *seed,
+	}
+	if cfg.reads == 0 {
+		cfg.reads = cfg.num
+	}
+	if cfg.num <= 0 || cfg.threads <= 0 || cfg.keySize <= 0 || cfg.valueSize < 0 {
+		fmt.Fprintln(os.Stderr, "forgedb-bench: -num, -threads and -key-size must be positive")
+		os.Exit(2)
+	}
+	names := strings.Split(*benchmarks, ",")
+	for _, name := range names {
+		if _, ok := workloads[name]; !ok {
+			fmt.Fprintf(os.Stderr, "forgedb-bench: unknown benchmark %q\n", name)
+			os.Exit(2)
+		}
+	}
+
+	path := *dir
+	if path == "" {
+		tmp, err := os.MkdirTemp("", "forgedb-bench-")
+		if err != nil {
+			log.Fatalf("forgedb-bench: %v", err)
+		}
+		defer os.RemoveAll(tmp)
+		path = tmp
+	}
+	d, err := db.OpenWithOptions(path, db.Options{DisableFsync: !*fsync, WALCompression: *walCompression})
+	if err != nil {
+		log.Fatalf("forgedb-bench: open %s: %v", path, err)
+	}
+
+	fmt.Printf("keys: %d bytes, values: %d bytes, entries: %d, threads: %d\n", cfg.keySize, cfg.valueSize, cfg.num, cfg.threads)
+	for _, name := range names {
+		res, err := run(d, name, cfg)
+		if err != nil {
+			_ = d.Close()
+			log.Fatalf("forgedb-bench: %s: %v", name, err)
+		}
+		fmt.Println(res)
+	}
+	if err := d.Close(); err != nil {
+		log.Fatalf("forgedb-bench: close: %v", err)
+	}
+}
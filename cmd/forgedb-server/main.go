@@ -0,0 +1,158 @@
+// forgedb-server 通过 HTTP/JSON（以及可选的 gRPC、Redis 协议）对外提供 ForgeDB 的读写接口。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"monolithdb/internal/db"
+	"monolithdb/internal/replication"
+	"monolithdb/internal/resp"
+	"monolithdb/internal/rpc"
+)
+
+func main() {
+	dir := flag.String("dir", "", "data directory")
+	addr := flag.String("addr", "127.0.0.1:7070", "listen address")
+	grpcAddr := flag.String("grpc-addr", "", "gRPC listen address (empty = disabled)")
+	respAddr := flag.String("resp-addr", "", "Redis protocol (RESP) listen address (empty = disabled)")
+	leader := flag.Bool("leader", false, "serve the replication stream for followers")
+	backlog := flag.Int("repl-backlog", replication.DefaultBacklog, "number of recent records kept in memory for followers (with -leader)")
+	follow := flag.String("follow", "", "replicate from the leader at this URL (e.g. http://10.0.0.1:7070)")
+	ioRate := flag.Int64("io-rate", 0, "limit flush and compaction writes to this many bytes/sec (0 = unlimited)")
+	walArchive := flag.String("wal-archive-dir", "", "move WAL segments here after flush instead of deleting them (for point-in-time recovery)")
+	walCompression := flag.Bool("wal-compression", false, "compress large WAL records (trades CPU for WAL write bandwidth)")
+	latency := flag.Bool("latency-histograms", false, "record per-operation latency histograms (exported on /metrics)")
+	valueLog := flag.Int("value-log-threshold", 0, "store values of at least this many bytes in a separate value log (0 = disabled)")
+	flushInterval := flag.Duration("flush-interval", 0, "flush the memtable this often to bound WAL replay after a crash (0 disables)")
+	memTableSize := flag.Int64("memtable-size", 0, "flush the memtable once it holds this many bytes (0 = only on explicit or periodic flush)")
+	maxImm := flag.Int("max-immutable-memtables", 0, "with -memtable-size, queue up to this many full memtables for background flushing instead of flushing inline")
+	bloomBits := flag.Int("bloom-bits-per-key", 0, "size new tables' bloom filters at this many bits per key (0 = fixed 1Mbit filter)")
+	subcompactions := flag.Int("max-subcompactions", 0, "split large compactions into up to this many key ranges merged in parallel (0 or 1 = one output table)")
+	scrubInterval := flag.Duration("scrub-interval", 0, "re-verify each table's checksums in the background once it has gone this long unchecked (0 disables)")
+	scrubRate := flag.Int64("scrub-rate", 0, "limit background scrub reads to this many bytes/sec (0 = default rate)")
+	valueLogGC := flag.Duration("value-log-gc-interval", 10*time.Minute, "how often to garbage-collect the value log (with -value-log-threshold)")
+	flag.Parse()
+
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "forgedb-server: -dir is required")
+		os.Exit(2)
+	}
+
+	// 正常退出时把 MemTable 刷成 SST，重启不需要回放 WAL
+	opts := db.Options{FlushOnClose: true, WALCompression: *walCompression, WALArchiveDir: *walArchive, LatencyHistograms: *latency, FlushInterval: *flushInterval,
+		MemTableSize: *memTableSize, MaxImmutableMemtables: *maxImm, BloomBitsPerKey: *bloomBits,
+		MaxSubcompactions: *subcompactions, ScrubInterval: *scrubInterval, ScrubBytesPerSec: *scrubRate}
+	if *ioRate > 0 {
+		opts.RateLimiter = db.NewRateLimiter(*ioRate)
+	}
+	if *valueLog > 0 {
+		opts.ValueLogThreshold, opts.ValueLogGCInterval = *valueLog, *valueLogGC
+	}
+	d, err := db.OpenWithOptions(*dir, opts)
+	if err != nil {
+		log.Fatalf("forgedb-server: open %s: %v", *dir, err)
+	}
+
+	handler := newServer(d)
+	if *leader {
+		mux := http.NewServeMux()
+		mux.Handle(replication.StreamPath, replication.NewLeader(d, *backlog))
+		mux.Handle("/", handler)
+		handler = mux
+	}
+	srv := &http.Server{Addr: *addr, Handler: handler}
+
+	// follower：复制位置保存在数据目录下的 REPLICA 文件里
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	replDone := make(chan struct{})
+	if *follow != "" {
+		f, err := replication.NewFollower(d, *follow, filepath.Join(*dir, "REPLICA"))
+		if err != nil {
+			_ = d.Close()
+			log.Fatalf("forgedb-server: %v", err)
+		}
+		go func() {
+			_ = f.Run(ctx)
+			close(replDone)
+		}()
+		log.Printf("forgedb-server: following %s", *follow)
+	} else {
+		close(replDone)
+	}
+
+	// gRPC 与 HTTP 共用同一个 DB
+	var gs *grpc.Server
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			_ = d.Close()
+			log.Fatalf("forgedb-server: listen %s: %v", *grpcAddr, err)
+		}
+		gs = rpc.NewServer(d)
+		go func() {
+			if err := gs.Serve(lis); err != nil {
+				log.Printf("forgedb-server: grpc: %v", err)
+			}
+		}()
+		log.Printf("forgedb-server: grpc on %s", *grpcAddr)
+	}
+
+	var rs *resp.Server
+	if *respAddr != "" {
+		lis, err := net.Listen("tcp", *respAddr)
+		if err != nil {
+			_ = d.Close()
+			log.Fatalf("forgedb-server: listen %s: %v", *respAddr, err)
+		}
+		rs = resp.NewServer(d)
+		go func() {
+			if err := rs.Serve(lis); err != nil {
+				log.Printf("forgedb-server: resp: %v", err)
+			}
+		}()
+		log.Printf("forgedb-server: resp on %s", *respAddr)
+	}
+
+	// 收到信号后停止接收请求并关闭数据库
+	done := make(chan struct{})
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		_ = srv.Close()
+		if gs != nil {
+			gs.Stop()
+		}
+		if rs != nil {
+			_ = rs.Close()
+		}
+		cancel()
+		<-replDone
+		close(done)
+	}()
+
+	log.Printf("forgedb-server: serving %s on %s", *dir, *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		_ = d.Close()
+		log.Fatalf("forgedb-server: %v", err)
+	}
+	<-done
+
+	if err := d.Close(); err != nil {
+		log.Fatalf("forgedb-server: close: %v", err)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"monolithdb/internal/db"
+	"monolithdb/internal/script"
+	"monolithdb/internal/types"
+)
+
+const (
+	// 单个 value / batch 请求体的上限，防止一个请求吃掉所有内存
+	maxValueBytes = 64 << 20
+	maxBatchBytes = 64 << 20
+
+	defaultScanLimit = 1000
+)
+
+// server 把 DB 暴露为 REST 接口：
+//
+//	GET    /kv/{key}                     读取原始 value
+//	PUT    /kv/{key}[?ttl=30s]           请求体即 value
+//	DELETE /kv/{key}
+//	GET    /kv?start=&end=&limit=        范围扫描，返回 JSON；还有更多结果时带 cursor
+//	GET    /kv?cursor=&limit=            从上一页返回的 cursor 继续扫描
+//	POST   /batch                        批量 get / put / delete，返回 JSON
+//	POST   /script                       在写锁内原子地执行脚本（见 script 包），返回 JSON
+//	GET    /metrics                      Prometheus 文本格式的引擎指标
+//
+// JSON 中的 value 使用 base64 编码（encoding/json 对 []byte 的默认行为）。
+type server struct {
+	d *db.DB
+}
+
+func newServer(d *db.DB) http.Handler {
+	s := &server{d: d}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /kv/{key...}", s.handleGet)
+	mux.HandleFunc("PUT /kv/{key...}", s.handlePut)
+	mux.HandleFunc("DELETE /kv/{key...}", s.handleDelete)
+	mux.HandleFunc("GET /kv", s.handleScan)
+	mux.HandleFunc("POST /batch", s.handleBatch)
+	mux.HandleFunc("POST /script", s.handleScript)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	return mux
+}
+
+type kvJSON struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type scanResponse struct {
+	Entries []kvJSON `json:"entries"`
+	// More 为 true 表示因为 limit 截断，还有更多结果；把 Cursor 作为 cursor 参数传回来读取下一页
+	More   bool   `json:"more"`
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type batchOp struct {
+	Op    string `json:"op"` // get / put / delete
+	Key   string `json:"key"`
+	Value []byte `json:"value,omitempty"`
+	TTL   string `json:"ttl,omitempty"` // put 可选，time.ParseDuration 格式
+}
+
+type batchRequest struct {
+	Ops []batchOp `json:"ops"`
+}
+
+type batchResult struct {
+	Found bool   `json:"found,omitempty"`
+	Value []byte `json:"value,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+type scriptRequest struct {
+	Script string   `json:"script"`
+	Keys   []string `json:"keys,omitempty"`
+	Args   [][]byte `json:"args,omitempty"`
+}
+
+type scriptResponse struct {
+	// Result 是脚本最后一个表达式的值：null、字符串（base64）、整数或布尔
+	Result any `json:"result"`
+}
+
+func (s *server) handleGet(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		http.Error(w, "empty key", http.StatusBadRequest)
+		return
+	}
+
+	v, ok, err := s.d.Get(key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(v)
+}
+
+func (s *server) handlePut(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		http.Error(w, "empty key", http.StatusBadRequest)
+		return
+	}
+
+	ttl, err := parseTTL(r.URL.Query().Get("ttl"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxValueBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err := s.d.PutWithTTL(key, value, ttl); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		http.Error(w, "empty key", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.d.Delete(key); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) handleScan(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	limit := defaultScanLimit
+	if ls := q.Get("limit"); ls != "" {
+		n, err := strconv.Atoi(ls)
+		if err != nil || n <= 0 {
+			http.Error(w, fmt.Sprintf("bad limit %q", ls), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	// 一次只读一页，不会因为范围很大而把整个范围读进内存；cursor 来自上一页的响应
+	var entries []types.Entry
+	var cursor string
+	var err error
+	if c := q.Get("cursor"); c != "" {
+		entries, cursor, err = s.d.ScanFrom(c, limit)
+	} else {
+		entries, cursor, err = s.d.Scan(q.Get("start"), q.Get("end"), limit)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp := scanResponse{Entries: make([]kvJSON, len(entries)), More: cursor != "", Cursor: cursor}
+	for i, e := range entries {
+		resp.Entries[i] = kvJSON{Key: e.Key, Value: e.Value}
+	}
+	writeJSON(w, resp)
+}
+
+// handleBatch 依次执行请求里的每个操作。
+// 注意：批量操作不是原子的，某个操作失败不会回滚之前已经成功的操作，错误按操作逐条返回。
+func (s *server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBytes))
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "bad request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := batchResponse{Results: make([]batchResult, len(req.Ops))}
+	for i, op := range req.Ops {
+		res := &resp.Results[i]
+		if op.Key == "" {
+			res.Error = "empty key"
+			continue
+		}
+
+		switch op.Op {
+		case "get":
+			v, ok, err := s.d.Get(op.Key)
+			if err != nil {
+				res.Error = err.Error()
+				continue
+			}
+			res.Found, res.Value = ok, v
+		case "put":
+			ttl, err := parseTTL(op.TTL)
+			if err != nil {
+				res.Error = err.Error()
+				continue
+			}
+			if err := s.d.PutWithTTL(op.Key, op.Value, ttl); err != nil {
+				res.Error = err.Error()
+			}
+		case "delete":
+			if err := s.d.Delete(op.Key); err != nil {
+				res.Error = err.Error()
+			}
+		default:
+			res.Error = fmt.Sprintf("unknown op %q", op.Op)
+		}
+	}
+	writeJSON(w, resp)
+}
+
+// handleScript 执行请求里的脚本：脚本的读写在写锁内完成，成功时所有写入原子地提交，
+// 出错（包括脚本主动调用 error）时全部丢弃。语法错误返回 400，执行错误返回 422。
+func (s *server) handleScript(w http.ResponseWriter, r *http.Request) {
+	var req scriptRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBytes))
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "bad request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	v, err := script.Eval(s.d, req.Script, req.Keys, req.Args)
+	switch {
+	case errors.Is(err, script.ErrSyntax):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, script.ErrRuntime), errors.Is(err, script.ErrAborted):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case err != nil:
+		writeError(w, err)
+	default:
+		writeJSON(w, scriptResponse{Result: v})
+	}
+}
+
+func parseTTL(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad ttl %q", s)
+	}
+	return ttl, nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, db.ErrReadOnly):
+		status = http.StatusForbidden
+	case errors.Is(err, db.ErrKeyTooLarge), errors.Is(err, db.ErrValueTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, db.ErrInvalidCursor):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// handleMetrics 以 Prometheus 文本格式返回引擎指标。
+func (s *server) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_ = s.d.WritePrometheus(w)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"monolithdb/internal/db"
+)
+
+func TestServerKVAndScan(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+
+	ts := httptest.NewServer(newServer(d))
+	defer ts.Close()
+
+	do := func(method, path, body string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	for _, k := range []string{"a", "b", "c/d"} {
+		resp := do(http.MethodPut, "/kv/"+k, "val-"+k)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("PUT %s: status %d", k, resp.StatusCode)
+		}
+	}
+
+	resp := do(http.MethodGet, "/kv/c/d", "")
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "val-c/d" {
+		t.Fatalf("GET c/d: status %d body %q", resp.StatusCode, body)
+	}
+
+	resp = do(http.MethodDelete, "/kv/a", "")
+	resp.Body.Close()
+	resp = do(http.MethodGet, "/kv/a", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", resp.StatusCode)
+	}
+
+	resp = do(http.MethodGet, "/kv?start=b&limit=1", "")
+	var sr scanResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(sr.Entries) != 1 || sr.Entries[0].Key != "b" || !sr.More {
+		t.Fatalf("unexpected scan response: %+v", sr)
+	}
+	// 用 cursor 翻到下一页
+	resp = do(http.MethodGet, "/kv?limit=1&cursor="+sr.Cursor, "")
+	sr = scanResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(sr.Entries) != 1 || sr.Entries[0].Key != "c/d" || sr.More || sr.Cursor != "" {
+		t.Fatalf("unexpected second page: %+v", sr)
+	}
+	resp = do(http.MethodGet, "/kv?cursor=bogus", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("bad cursor: status %d", resp.StatusCode)
+	}
+
+	resp = do(http.MethodGet, "/metrics", "")
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "forgedb_memtable_entries 3\n") {
+		t.Fatalf("GET /metrics: status %d body %q", resp.StatusCode, body)
+	}
+}
+
+func TestServerBatch(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+
+	ts := httptest.NewServer(newServer(d))
+	defer ts.Close()
+
+	req := batchRequest{Ops: []batchOp{
+		{Op: "put", Key: "x", Value: []byte("1")},
+		{Op: "get", Key: "x"},
+		{Op: "delete", Key: "x"},
+		{Op: "get", Key: "x"},
+		{Op: "bogus", Key: "x"},
+	}}
+	b, _ := json.Marshal(req)
+
+	resp, err := http.Post(ts.URL+"/batch", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var br batchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&br); err != nil {
+		t.Fatal(err)
+	}
+	if len(br.Results) != 5 {
+		t.Fatalf("expected 5 results, got %+v", br)
+	}
+	if !br.Results[1].Found || string(br.Results[1].Value) != "1" {
+		t.Fatalf("expected get x=1, got %+v", br.Results[1])
+	}
+	if br.Results[3].Found {
+		t.Fatalf("expected x to be deleted, got %+v", br.Results[3])
+	}
+	if br.Results[4].Error == "" {
+		t.Fatalf("expected error for unknown op")
+	}
+}
+
+func TestServerScript(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+
+	ts := httptest.NewServer(newServer(d))
+	defer ts.Close()
+
+	post := func(req scriptRequest) (*http.Response, scriptResponse) {
+		t.Helper()
+		b, _ := json.Marshal(req)
+		resp, err := http.Post(ts.URL+"/script", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var sr scriptResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp, sr
+	}
+
+	// compare-and-set：只有当前值等于 arg 0 时才写入 arg 1
+	cas := `(if (= (get (key 0)) (arg 0)) (do (put (key 0) (arg 1)) true) false)`
+	if err := d.Put("k", []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	resp, sr := post(scriptRequest{Script: cas, Keys: []string{"k"}, Args: [][]byte{[]byte("old"), []byte("new")}})
+	if resp.StatusCode != http.StatusOK || sr.Result != true {
+		t.Fatalf("cas: status %d result %v", resp.StatusCode, sr.Result)
+	}
+	resp, sr = post(scriptRequest{Script: cas, Keys: []string{"k"}, Args: [][]byte{[]byte("old"), []byte("newer")}})
+	if resp.StatusCode != http.StatusOK || sr.Result != false {
+		t.Fatalf("stale cas: status %d result %v", resp.StatusCode, sr.Result)
+	}
+	if v, _, _ := d.Get("k"); string(v) != "new" {
+		t.Fatalf("k = %q", v)
+	}
+
+	if resp, _ := post(scriptRequest{Script: `(get`}); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("syntax error: status %d", resp.StatusCode)
+	}
+	if resp, _ := post(scriptRequest{Script: `(error "no")`}); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("aborted script: status %d", resp.StatusCode)
+	}
+}
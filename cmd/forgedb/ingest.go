@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"monolithdb/internal/db"
+	"monolithdb/internal/leveldb"
+	"monolithdb/internal/types"
+)
+
+// ingestChunkBytes 是每张导入的 SST 的目标大小，避免把整个数据集放进内存。
+const ingestChunkBytes = 64 << 20
+
+func runIngest(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("ingest: expected <dir> <table>...")
+	}
+
+	var tables []*leveldb.Table
+	defer func() {
+		for _, t := range tables {
+			_ = t.Close()
+		}
+	}()
+	names := make([]string, len(args)-1)
+	for i, p := range args[1:] {
+		t, err := leveldb.Open(p)
+		if err != nil {
+			return err
+		}
+		tables = append(tables, t)
+		names[i] = filepath.Base(p)
+	}
+	source := "leveldb:" + strings.Join(names, ",")
+
+	return withDB(args[0], false, func(d *db.DB) error {
+		var chunk []types.Entry
+		size, total := 0, 0
+		ingest := func() error {
+			if err := d.Ingest(chunk, source); err != nil {
+				return err
+			}
+			total += len(chunk)
+			chunk, size = chunk[:0], 0
+			return nil
+		}
+		// 合并之后 key 严格升序，切成几段分别导入，每段都是一张互不重叠的表
+		for r, err := range leveldb.Merge(tables...) {
+			if err != nil {
+				return err
+			}
+			chunk = append(chunk, types.Entry{Key: r.Key, Value: r.Value, Tombstone: r.Deleted})
+			if size += len(r.Key) + len(r.Value); size >= ingestChunkBytes {
+				if err := ingest(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := ingest(); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "ingested %d records from %d tables\n", total, len(tables))
+		return nil
+	})
+}
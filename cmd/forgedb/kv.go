@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"monolithdb/internal/db"
+)
+
+var errNotFound = errors.New("not found")
+
+// withDB 打开 dir 下的数据库并执行 fn，结束后关闭。
+func withDB(dir string, readOnly bool, fn func(d *db.DB) error) error {
+	d, err := db.OpenWithOptions(dir, db.Options{ReadOnly: readOnly})
+	if err != nil {
+		return err
+	}
+	err = fn(d)
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func runGet(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("get: expected <dir> <key>")
+	}
+
+	return withDB(args[0], true, func(d *db.DB) error {
+		v, ok, err := d.Get(args[1])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errNotFound
+		}
+		fmt.Printf("%s\n", v)
+		return nil
+	})
+}
+
+func runPut(args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("put: expected <dir> <key> <value>")
+	}
+
+	return withDB(args[0], false, func(d *db.DB) error {
+		return d.Put(args[1], []byte(args[2]))
+	})
+}
+
+func runDelete(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("delete: expected <dir> <key>")
+	}
+
+	return withDB(args[0], false, func(d *db.DB) error {
+		return d.Delete(args[1])
+	})
+}
+
+func runScan(args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	start := fs.String("start", "", "first key (inclusive)")
+	end := fs.String("end", "", "last key (exclusive)")
+	limit := fs.Int("limit", 0, "maximum number of records to print (0 = unlimited)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("scan: expected <dir>")
+	}
+
+	return withDB(fs.Arg(0), true, func(d *db.DB) error {
+		n := 0
+		for chunk, err := range d.RangeChunks(*start, *end, 0) {
+			if err != nil {
+				return err
+			}
+			for _, e := range chunk {
+				if *limit > 0 && n >= *limit {
+					return nil
+				}
+				fmt.Fprintf(os.Stdout, "%s\t%s\n", e.Key, e.Value)
+				n++
+			}
+		}
+		return nil
+	})
+}
+
+func runFlush(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("flush: expected <dir>")
+	}
+
+	return withDB(args[0], false, func(d *db.DB) error {
+		return d.Flush()
+	})
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	start := fs.String("start", "", "first key (inclusive)")
+	end := fs.String("end", "", "last key (exclusive)")
+	format := fs.String("format", "json", "output format: json or csv")
+	valueEnc := fs.String("value-encoding", "text", "csv value column encoding: text, base64 or hex")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("export: expected <dir>")
+	}
+
+	var export func(d *db.DB) error
+	switch *format {
+	case "json":
+		export = func(d *db.DB) error {
+			_, err := d.ExportJSON(os.Stdout, *start, *end)
+			return err
+		}
+	case "csv":
+		enc, err := db.ParseCSVValueEncoding(*valueEnc)
+		if err != nil {
+			return err
+		}
+		export = func(d *db.DB) error {
+			_, err := d.ExportCSV(os.Stdout, *start, *end, db.CSVOptions{ValueEncoding: enc})
+			return err
+		}
+	default:
+		return fmt.Errorf("export: unknown format %q", *format)
+	}
+	return withDB(fs.Arg(0), true, export)
+}
+
+func runImport(args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return fmt.Errorf("import: expected <dir> [file]")
+	}
+
+	in := os.Stdin
+	if len(args) == 2 {
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	return withDB(args[0], false, func(d *db.DB) error {
+		n, err := d.ImportJSON(in)
+		fmt.Fprintf(os.Stderr, "imported %d records\n", n)
+		return err
+	})
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bufio"
+	"io"
+)
+
+// lineReader 读取一行用户输入。
+type lineReader interface {
+	// ReadLine 打印 prompt 并读取一行；history 用于上下方向键翻历史（可能不支持）。
+	ReadLine(prompt string, history []string) (string, error)
+	Close() error
+}
+
+// plainReader 是不支持行编辑的后备实现（非终端输入，或不支持 raw 模式的平台）。
+type plainReader struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+func (p *plainReader) ReadLine(prompt string, _ []string) (string, error) {
+	_, _ = io.WriteString(p.w, prompt)
+	line, err := p.r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return line, nil
+}
+
+func (p *plainReader) Close() error { return nil }
@@ -0,0 +1,157 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// rawReader 是一个极简的行编辑器：支持退格、Tab 补全、上下方向键翻历史、Ctrl-C / Ctrl-D。
+type rawReader struct {
+	in       *os.File
+	r        *bufio.Reader
+	w        io.Writer
+	old      syscall.Termios
+	complete func(line string) []string
+}
+
+// newLineReader 在 in 是终端时返回 rawReader，否则退化为逐行读取。
+func newLineReader(in *os.File, w io.Writer, complete func(string) []string) lineReader {
+	old, err := tcget(in.Fd())
+	if err != nil {
+		return &plainReader{r: bufio.NewReader(in), w: w}
+	}
+	return &rawReader{in: in, r: bufio.NewReader(in), w: w, old: old, complete: complete}
+}
+
+func tcget(fd uintptr) (syscall.Termios, error) {
+	var t syscall.Termios
+	if _, _, e := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCGETS, uintptr(unsafe.Pointer(&t))); e != 0 {
+		return t, e
+	}
+	return t, nil
+}
+
+func tcset(fd uintptr, t *syscall.Termios) error {
+	if _, _, e := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCSETS, uintptr(unsafe.Pointer(t))); e != 0 {
+		return e
+	}
+	return nil
+}
+
+func (rr *rawReader) ReadLine(prompt string, history []string) (string, error) {
+	// 只在读一行期间进入 raw 模式，命令输出仍走正常的终端处理
+	raw := rr.old
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := tcset(rr.in.Fd(), &raw); err != nil {
+		return "", err
+	}
+	defer func() { _ = tcset(rr.in.Fd(), &rr.old) }()
+
+	var buf []byte
+	hist := len(history) // 指向“当前正在编辑的新行”
+
+	redraw := func() {
+		fmt.Fprintf(rr.w, "\r\033[K%s%s", prompt, buf)
+	}
+	redraw()
+
+	for {
+		b, err := rr.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+
+		switch b {
+		case '\r', '\n':
+			fmt.Fprint(rr.w, "\r\n")
+			return string(buf), nil
+
+		case 3: // Ctrl-C：丢弃当前行
+			fmt.Fprint(rr.w, "^C\r\n")
+			buf = buf[:0]
+			hist = len(history)
+			redraw()
+
+		case 4: // Ctrl-D：空行时退出
+			if len(buf) == 0 {
+				return "", io.EOF
+			}
+
+		case 127, 8: // 退格
+			if len(buf) > 0 {
+				buf = buf[:len(buf)-1]
+				redraw()
+			}
+
+		case '\t':
+			cands := rr.complete(string(buf))
+			switch len(cands) {
+			case 0:
+			case 1:
+				buf = []byte(cands[0])
+			default:
+				// 多个候选：补到公共前缀，并列出候选
+				if p := commonPrefix(cands); len(p) > len(buf) {
+					buf = []byte(p)
+				} else {
+					fmt.Fprint(rr.w, "\r\n")
+					for _, c := range cands {
+						fmt.Fprintf(rr.w, "%s\r\n", lastWord(c))
+					}
+				}
+			}
+			redraw()
+
+		case 27: // ESC 序列：只处理上下方向键
+			b1, _ := rr.r.ReadByte()
+			b2, _ := rr.r.ReadByte()
+			if b1 != '[' {
+				continue
+			}
+			switch b2 {
+			case 'A':
+				if hist > 0 {
+					hist--
+					buf = []byte(history[hist])
+				}
+			case 'B':
+				if hist < len(history)-1 {
+					hist++
+					buf = []byte(history[hist])
+				} else {
+					hist = len(history)
+					buf = buf[:0]
+				}
+			}
+			redraw()
+
+		default:
+			if b >= 32 && b < 127 || b >= 128 {
+				buf = append(buf, b)
+				fmt.Fprintf(rr.w, "%c", b)
+			}
+		}
+	}
+}
+
+func (rr *rawReader) Close() error {
+	return tcset(rr.in.Fd(), &rr.old)
+}
+
+func lastWord(s string) string {
+	s = strings.TrimRight(s, " ")
+	if i := strings.LastIndexByte(s, ' '); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+// newLineReader 在非 Linux 平台上不支持行编辑，直接逐行读取。
+func newLineReader(in *os.File, w io.Writer, _ func(string) []string) lineReader {
+	return &plainReader{r: bufio.NewReader(in), w: w}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"monolithdb/internal/db"
+)
+
+const shellHelp = `commands:
+  get <key>                     读取一个 key
+  put <key> <value>             写入一个 key（value 可以包含空格）
+  del <key>                     删除一个 key
+  scan [start] [end] [limit]    按顺序打印 [start, end) 内的记录，默认最多 100 条
+  stats                         打印运行时统计
+  prop [name]                   打印属性（如 forgedb.num-sstables），默认打印 forgedb.stats
+  flush                         把 MemTable 刷成 SST
+  history                       打印本次会话的命令历史
+  help                          打印本帮助
+  exit | quit                   退出
+`
+
+var shellCommands = []string{"del", "exit", "flush", "get", "help", "history", "prop", "put", "quit", "scan", "stats"}
+
+// 补全 key 时最多列出的候选数
+const maxKeyCompletions = 50
+
+// shell 是 `forgedb shell` 的交互式会话。
+type shell struct {
+	d       *db.DB
+	out     io.Writer
+	history []string
+}
+
+func runShell(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("shell: expected <dir>")
+	}
+
+	// 交互式使用，统计延迟的开销可以忽略，stats 命令会显示分位数
+	d, err := db.OpenWithOptions(args[0], db.Options{LatencyHistograms: true})
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	sh := &shell{d: d, out: os.Stdout}
+	lr := newLineReader(os.Stdin, os.Stdout, sh.complete)
+	defer lr.Close()
+
+	fmt.Fprintf(sh.out, "forgedb shell on %s, type \"help\" for commands\n", args[0])
+	for {
+		line, err := lr.ReadLine("forgedb> ", sh.history)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				fmt.Fprintln(sh.out)
+				return nil
+			}
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		sh.history = append(sh.history, line)
+
+		quit, err := sh.exec(line)
+		if err != nil {
+			fmt.Fprintf(sh.out, "error: %v\n", err)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// exec 执行一行命令；返回 quit=true 表示退出会话。
+func (sh *shell) exec(line string) (quit bool, err error) {
+	fields := strings.Fields(line)
+	cmd, args := fields[0], fields[1:]
+
+	switch cmd {
+	case "get":
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: get <key>")
+		}
+		v, ok, err := sh.d.Get(args[0])
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			fmt.Fprintln(sh.out, "(not found)")
+			return false, nil
+		}
+		fmt.Fprintf(sh.out, "%s\n", v)
+
+	case "put":
+		if len(args) < 2 {
+			return false, fmt.Errorf("usage: put <key> <value>")
+		}
+		// value 取 key 之后的原始剩余部分，保留内部空格
+		rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line[len(cmd):]), args[0]))
+		if err := sh.d.Put(args[0], []byte(rest)); err != nil {
+			return false, err
+		}
+		fmt.Fprintln(sh.out, "OK")
+
+	case "del", "delete":
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: del <key>")
+		}
+		if err := sh.d.Delete(args[0]); err != nil {
+			return false, err
+		}
+		fmt.Fprintln(sh.out, "OK")
+
+	case "scan":
+		var start, end string
+		limit := 100
+		if len(args) > 0 {
+			start = args[0]
+		}
+		if len(args) > 1 {
+			end = args[1]
+		}
+		if len(args) > 2 {
+			n, err := strconv.Atoi(args[2])
+			if err != nil || n <= 0 {
+				return false, fmt.Errorf("scan: bad limit %q", args[2])
+			}
+			limit = n
+		}
+		entries, err := sh.d.Range(start, end)
+		if err != nil {
+			return false, err
+		}
+		for i, e := range entries {
+			if i >= limit {
+				fmt.Fprintf(sh.out, "... (%d more)\n", len(entries)-limit)
+				break
+			}
+			fmt.Fprintf(sh.out, "%s\t%s\n", e.Key, e.Value)
+		}
+
+	case "stats":
+		st := sh.d.Stats()
+		fmt.Fprintf(sh.out, "sstables:          %d\n", st.NumSSTables)
+		fmt.Fprintf(sh.out, "memtable entries:  %d\n", st.MemTableEntries)
+		fmt.Fprintf(sh.out, "memtable bytes:    %d\n", st.MemTableBytes)
+		fmt.Fprintf(sh.out, "read cache:        %d entries, %d/%d bytes, %d hits, %d misses\n",
+			st.ReadCache.Entries, st.ReadCache.Bytes, st.ReadCache.Capacity, st.ReadCache.Hits, st.ReadCache.Misses)
+		for _, l := range []struct {
+			name string
+			st   db.LatencyStats
+		}{{"put", st.Latency.Put}, {"get", st.Latency.Get}, {"delete", st.Latency.Delete}, {"flush", st.Latency.Flush}, {"compaction", st.Latency.Compaction}} {
+			if l.st.Count > 0 {
+				fmt.Fprintf(sh.out, "%-18s %d ops, p50 %v, p95 %v, p99 %v, max %v\n",
+					l.name+" latency:", l.st.Count, l.st.P50, l.st.P95, l.st.P99, l.st.Max)
+			}
+		}
+
+	case "prop":
+		name := db.PropStats
+		if len(args) > 0 {
+			name = args[0]
+		}
+		v, ok := sh.d.GetProperty(name)
+		if !ok {
+			return false, fmt.Errorf("unknown property %q", name)
+		}
+		fmt.Fprint(sh.out, v)
+		if !strings.HasSuffix(v, "\n") {
+			fmt.Fprintln(sh.out)
+		}
+
+	case "flush":
+		if err := sh.d.Flush(); err != nil {
+			return false, err
+		}
+		fmt.Fprintln(sh.out, "OK")
+
+	case "history":
+		for i, h := range sh.history {
+			fmt.Fprintf(sh.out, "%4d  %s\n", i+1, h)
+		}
+
+	case "help":
+		fmt.Fprint(sh.out, shellHelp)
+
+	case "exit", "quit":
+		return true, nil
+
+	default:
+		return false, fmt.Errorf("unknown command %q (type \"help\")", cmd)
+	}
+	return false, nil
+}
+
+// complete 返回 line 的补全候选（每个候选都是完整的一行）。
+// 第一个词补全命令名；get / del 的第二个词补全已有的 key。
+func (sh *shell) complete(line string) []string {
+	fields := strings.Fields(line)
+	endsWithSpace := strings.HasSuffix(line, " ")
+
+	// 补全命令
+	if len(fields) == 0 || (len(fields) == 1 && !endsWithSpace) {
+		prefix := ""
+		if len(fields) == 1 {
+			prefix = fields[0]
+		}
+		var out []string
+		for _, c := range shellCommands {
+			if strings.HasPrefix(c, prefix) {
+				out = append(out, c+" ")
+			}
+		}
+		return out
+	}
+
+	// 补全 key
+	cmd := fields[0]
+	if cmd != "get" && cmd != "del" && cmd != "delete" && cmd != "put" {
+		return nil
+	}
+	var prefix string
+	switch {
+	case len(fields) == 1 && endsWithSpace:
+		prefix = ""
+	case len(fields) == 2 && !endsWithSpace:
+		prefix = fields[1]
+	default:
+		return nil
+	}
+
+	var out []string
+scan:
+	for chunk, err := range sh.d.RangeChunks(prefix, "", 0) {
+		if err != nil {
+			return nil
+		}
+		for _, e := range chunk {
+			if !strings.HasPrefix(e.Key, prefix) || len(out) >= maxKeyCompletions {
+				break scan
+			}
+			out = append(out, cmd+" "+e.Key)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// commonPrefix 返回所有字符串的最长公共前缀。
+func commonPrefix(ss []string) string {
+	if len(ss) == 0 {
+		return ""
+	}
+	p := ss[0]
+	for _, s := range ss[1:] {
+		for !strings.HasPrefix(s, p) {
+			p = p[:len(p)-1]
+		}
+	}
+	return p
+}
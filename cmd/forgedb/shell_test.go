@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"monolithdb/internal/db"
+)
+
+func TestShellExecAndComplete(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+
+	var out bytes.Buffer
+	sh := &shell{d: d, out: &out}
+
+	for _, line := range []string{"put user1 hello  world", "put user2 x", "put zed y"} {
+		if _, err := sh.exec(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out.Reset()
+	if _, err := sh.exec("get user1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "hello  world" {
+		t.Fatalf("expected value with inner spaces preserved, got %q", got)
+	}
+
+	if quit, _ := sh.exec("quit"); !quit {
+		t.Fatalf("expected quit to end the session")
+	}
+
+	if got := sh.complete("st"); !reflect.DeepEqual(got, []string{"stats "}) {
+		t.Fatalf("unexpected command completion: %v", got)
+	}
+	if got := sh.complete("get us"); !reflect.DeepEqual(got, []string{"get user1", "get user2"}) {
+		t.Fatalf("unexpected key completion: %v", got)
+	}
+	if got := commonPrefix([]string{"get user1", "get user2"}); got != "get user" {
+		t.Fatalf("unexpected common prefix: %q", got)
+	}
+}
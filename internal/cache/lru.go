@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU 是一个按字节数限制容量的 key -> value 缓存，淘汰最久未访问的项。
+// 并发安全；存入和取出的 value 都会做防御性拷贝。
+type LRU struct {
+	mu       sync.Mutex
+	capacity int64 // 最大字节数（key + value）
+	used     int64
+	ll       *list.List // front = 最近访问
+	items    map[string]*list.Element
+
+	hits   uint64
+	misses uint64
+}
+
+type item struct {
+	key   string
+	value []byte
+}
+
+// Stats 是缓存的运行时统计。
+type Stats struct {
+	Entries  int
+	Bytes    int64
+	Capacity int64
+	Hits     uint64
+	Misses   uint64
+}
+
+// NewLRU 创建容量为 capacity 字节的缓存。
+func NewLRU(capacity int64) *LRU {
+	return &LRU{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func charge(key string, value []byte) int64 {
+	return int64(len(key) + len(value))
+}
+
+// Get 查询缓存，命中时把该项移到最前面。
+func (c *LRU) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.ll.MoveToFront(el)
+	return cloneBytes(el.Value.(*item).value), true
+}
+
+// GetNoCopy 与 Get 相同，但返回的切片直接引用缓存内的数据，不做拷贝。
+// 缓存内的 value 存入后不会再被修改（更新时整体替换），调用方也不能修改它。
+func (c *LRU) GetNoCopy(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.ll.MoveToFront(el)
+	return el.Value.(*item).value, true
+}
+
+// Add 插入或更新一项；单项超过总容量时直接忽略。
+func (c *LRU) Add(key string, value []byte) {
+	sz := charge(key, value)
+	if sz > c.capacity {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		it := el.Value.(*item)
+		c.used += sz - charge(it.key, it.value)
+		it.value = cloneBytes(value)
+		c.ll.MoveToFront(el)
+	} else {
+		el := c.ll.PushFront(&item{key: key, value: cloneBytes(value)})
+		c.items[key] = el
+		c.used += sz
+	}
+
+	// 超出容量：从尾部淘汰
+	for c.used > c.capacity {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// Remove 删除一项（不存在时什么也不做）。
+func (c *LRU) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// Purge 清空缓存。
+func (c *LRU) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	c.used = 0
+}
+
+// Stats 返回当前统计信息。
+func (c *LRU) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Stats{
+		Entries:  len(c.items),
+		Bytes:    c.used,
+		Capacity: c.capacity,
+		Hits:     c.hits,
+		Misses:   c.misses,
+	}
+}
+
+func (c *LRU) removeElement(el *list.Element) {
+	it := el.Value.(*item)
+	c.ll.Remove(el)
+	delete(c.items, it.key)
+	c.used -= charge(it.key, it.value)
+}
+
+// cloneBytes 防御性拷贝，避免外部修改 slice 影响缓存内数据。
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+
+	cp := make([]byte, len(b))
+	copy(cp, b)
+	return cp
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	// 每项 key(1) + value(3) = 4 字节，容量只放得下 2 项
+	c := NewLRU(8)
+
+	c.Add("a", []byte("111"))
+	c.Add("b", []byte("222"))
+
+	// 访问 a，使 b 成为最久未访问
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("expected a to be cached")
+	}
+
+	c.Add("c", []byte("333"))
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("expected b to be evicted")
+	}
+	if v, ok := c.Get("a"); !ok || !bytes.Equal(v, []byte("111")) {
+		t.Fatalf("expected a=111, got %q ok=%v", v, ok)
+	}
+	if v, ok := c.Get("c"); !ok || !bytes.Equal(v, []byte("333")) {
+		t.Fatalf("expected c=333, got %q ok=%v", v, ok)
+	}
+
+	st := c.Stats()
+	if st.Entries != 2 || st.Bytes != 8 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestLRUUpdateAndRemove(t *testing.T) {
+	c := NewLRU(100)
+
+	c.Add("k", []byte("v1"))
+	c.Add("k", []byte("value2"))
+
+	v, ok := c.Get("k")
+	if !ok || !bytes.Equal(v, []byte("value2")) {
+		t.Fatalf("expected k=value2, got %q ok=%v", v, ok)
+	}
+	if st := c.Stats(); st.Bytes != int64(len("k")+len("value2")) {
+		t.Fatalf("expected bytes to follow update, got %+v", st)
+	}
+
+	// 返回值被修改不能影响缓存
+	v[0] = 'X'
+	if v2, _ := c.Get("k"); !bytes.Equal(v2, []byte("value2")) {
+		t.Fatalf("cached value was mutated: %q", v2)
+	}
+
+	c.Remove("k")
+	if _, ok := c.Get("k"); ok {
+		t.Fatalf("expected k to be removed")
+	}
+	if st := c.Stats(); st.Entries != 0 || st.Bytes != 0 {
+		t.Fatalf("expected empty cache, got %+v", st)
+	}
+}
+
+func TestLRUIgnoresOversizedItem(t *testing.T) {
+	c := NewLRU(4)
+
+	c.Add("big", []byte("too large"))
+	if _, ok := c.Get("big"); ok {
+		t.Fatalf("expected oversized item to be ignored")
+	}
+}
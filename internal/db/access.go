@@ -0,0 +1,161 @@
+package db
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"monolithdb/internal/vfs"
+)
+
+// tableStatsFileName 保存每张 SST 的访问统计（见 Options.TableStatsSampleRate）。
+const tableStatsFileName = "TABLESTATS"
+
+// TableAccess 是一张 SST 的点查访问统计，由 TableAccessStats 返回。
+// 开启采样时是按采样率放大后的估算值。
+type TableAccess struct {
+	Table string // 文件名
+	Reads uint64 // 点查查询了这张表的次数（包括被 bloom filter 直接排除的）
+	Hits  uint64 // 其中在这张表里找到了 key（包括 tombstone）的次数
+}
+
+type accessCounter struct {
+	reads, hits atomic.Uint64
+}
+
+// tableAccess 按文件名记录每张表的访问次数。只统计点查（Get / MultiGet / 事务读等），
+// Range 和 compaction 的顺序读不计入。
+type tableAccess struct {
+	rate uint64
+	tick atomic.Uint64
+
+	mu     sync.Mutex
+	tables map[string]*accessCounter
+}
+
+func newTableAccess(rate int) *tableAccess {
+	return &tableAccess{rate: uint64(rate), tables: make(map[string]*accessCounter)}
+}
+
+// sample 报告这次点查是否被采样。
+func (a *tableAccess) sample() bool {
+	return a.tick.Add(1)%a.rate == 0
+}
+
+func (a *tableAccess) counter(path string) *accessCounter {
+	name := filepath.Base(path)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c, ok := a.tables[name]
+	if !ok {
+		c = &accessCounter{}
+		a.tables[name] = c
+	}
+	return c
+}
+
+// record 记录一次被采样的点查查询了 path，found 表示在这张表里找到了 key。
+func (a *tableAccess) record(path string, found bool) {
+	c := a.counter(path)
+	c.reads.Add(a.rate)
+	if found {
+		c.hits.Add(a.rate)
+	}
+}
+
+// replaced 在 compaction 用 added 取代 deleted 之后调用：输出表继承输入表的访问次数，
+// 热度不会因为合并而清零。没有输出（全部记录都被丢弃）时直接丢掉输入的统计。
+func (a *tableAccess) replaced(added, deleted []string) {
+	var reads, hits uint64
+	a.mu.Lock()
+	for _, p := range deleted {
+		if c, ok := a.tables[filepath.Base(p)]; ok {
+			reads += c.reads.Load()
+			hits += c.hits.Load()
+			delete(a.tables, filepath.Base(p))
+		}
+	}
+	a.mu.Unlock()
+	if len(added) > 0 && reads > 0 {
+		c := a.counter(added[0])
+		c.reads.Add(reads)
+		c.hits.Add(hits)
+	}
+}
+
+func (a *tableAccess) get(path string) TableAccess {
+	name := filepath.Base(path)
+	a.mu.Lock()
+	c := a.tables[name]
+	a.mu.Unlock()
+	st := TableAccess{Table: name}
+	if c != nil {
+		st.Reads, st.Hits = c.reads.Load(), c.hits.Load()
+	}
+	return st
+}
+
+// load 读取 dir 下的统计文件，只保留 tables 里还存在的表。
+// 统计只是参考信息：文件不存在或者格式不对时从零开始，不影响打开数据库。
+func (a *tableAccess) load(fs vfs.FS, dir string, tables []string) {
+	f, err := fs.Open(filepath.Join(dir, tableStatsFileName))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	live := make(map[string]bool, len(tables))
+	for _, p := range tables {
+		live[filepath.Base(p)] = true
+	}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var name string
+		var reads, hits uint64
+		if _, err := fmt.Sscanf(sc.Text(), "%s %d %d", &name, &reads, &hits); err != nil || !live[name] {
+			continue
+		}
+		c := a.counter(name)
+		c.reads.Store(reads)
+		c.hits.Store(hits)
+	}
+}
+
+// save 把 tables 的统计写到 dir 下（先写临时文件再 rename）。
+func (a *tableAccess) save(fs vfs.FS, dir string, tables []string) error {
+	var b []byte
+	for _, p := range tables {
+		st := a.get(p)
+		b = fmt.Appendf(b, "%s %d %d\n", st.Table, st.Reads, st.Hits)
+	}
+	tmp := filepath.Join(dir, tableStatsFileName+".tmp")
+	if err := vfs.WriteFile(fs, tmp, b, 0o644); err != nil {
+		return err
+	}
+	return fs.Rename(tmp, filepath.Join(dir, tableStatsFileName))
+}
+
+// TableAccessStats 返回当前每张 SST 的点查访问统计（newest first）。
+// 没有开启 Options.TableStatsSampleRate 时返回 nil。
+func (d *DB) TableAccessStats() []TableAccess {
+	a := d.versions.access
+	if a == nil {
+		return nil
+	}
+	tables := d.versions.current().tables
+	out := make([]TableAccess, len(tables))
+	for i, p := range tables {
+		out[i] = a.get(p)
+	}
+	return out
+}
+
+// saveTableAccess 在 Flush / Compact / Close 时持久化访问统计。调用方持有写锁。
+func (d *DB) saveTableAccess() error {
+	if d.versions.access == nil || d.opts.ReadOnly {
+		return nil
+	}
+	return d.versions.access.save(d.opts.fs(), d.dir, d.versions.current().tables)
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTableAccessStatsPersist(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	opts := Options{DisableFsync: true, TableStatsSampleRate: 1}
+
+	d, err := OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("cold", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("hot", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, _, err := d.Get("hot"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := d.Get("cold"); err != nil {
+		t.Fatal(err)
+	}
+
+	// newest first：hot 所在的表被查了 6 次、命中 5 次；cold 所在的表只被查了 1 次、命中 1 次
+	st := d.TableAccessStats()
+	if len(st) != 2 || st[0].Reads != 6 || st[0].Hits != 5 || st[1].Reads != 1 || st[1].Hits != 1 {
+		t.Fatalf("stats = %+v", st)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := d.TableAccessStats(); len(got) != 2 || got[0] != st[0] || got[1] != st[1] {
+		t.Fatalf("after reopen stats = %+v, want %+v", got, st)
+	}
+
+	// compaction 的输出继承输入的计数
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	got := d.TableAccessStats()
+	if len(got) != 1 || got[0].Reads != 7 || got[0].Hits != 6 {
+		t.Fatalf("after compaction stats = %+v", got)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if got := d.TableAccessStats(); got != nil {
+		t.Fatalf("disabled stats = %+v", got)
+	}
+}
@@ -0,0 +1,241 @@
+package db
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"monolithdb/internal/vfs"
+	"monolithdb/internal/wal"
+)
+
+// ErrWALArchiveGap 表示归档目录里的 WAL 段不连续，无法回放到要求的序号。
+var ErrWALArchiveGap = errors.New("db: gap in WAL archive")
+
+// ArchivedWAL 描述一个已完成的 WAL 段：段内记录的序号是 [FirstSeq, LastSeq]。
+type ArchivedWAL struct {
+	Path     string
+	FirstSeq uint64
+	LastSeq  uint64
+}
+
+// pruneWALSegments 在 Flush 切换 WAL 之后（持有写锁）处理超出 WALRetentionSegments 的旧段：
+// 先交给 WALArchiveHook，再移动到 WALArchiveDir，都没有配置时直接删除。
+// 出错时这个段和之后的段原样保留，下次 Flush 时重试。
+func (d *DB) pruneWALSegments() error {
+	segs, err := listWALSegments(d.opts.fs(), d.dir)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(segs)-d.opts.WALRetentionSegments; i++ {
+		// 段的结束序号是下一段（或者活跃 WAL）的起始序号减一
+		next := d.walFirstSeq
+		if i+1 < len(segs) {
+			next = segs[i+1].first
+		}
+		seg := ArchivedWAL{Path: segs[i].path, FirstSeq: segs[i].first, LastSeq: next - 1}
+
+		if d.opts.WALArchiveHook != nil {
+			if err := d.opts.WALArchiveHook(seg); err != nil {
+				return fmt.Errorf("db: archive WAL segment %d-%d: %w", seg.FirstSeq, seg.LastSeq, err)
+			}
+		}
+		if d.opts.WALArchiveDir != "" {
+			if err := archiveWALSegment(d.opts.fs(), d.opts.WALArchiveDir, seg, !d.opts.DisableFsync); err != nil {
+				return fmt.Errorf("db: archive WAL segment %d-%d: %w", seg.FirstSeq, seg.LastSeq, err)
+			}
+			continue
+		}
+		if err := d.opts.fs().Remove(seg.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveWALSegment 把 seg 移动到 dir 下，文件名是 <FirstSeq>-<LastSeq>.log。
+// 跨文件系统无法 rename 时先复制到临时文件（sync 之后）再 rename，最后删除原文件。
+func archiveWALSegment(fs vfs.FS, dir string, seg ArchivedWAL, sync bool) error {
+	if err := fs.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	dst := filepath.Join(dir, fmt.Sprintf("%020d-%020d.log", seg.FirstSeq, seg.LastSeq))
+	if err := fs.Rename(seg.Path, dst); err == nil {
+		if sync {
+			return syncDir(fs, dir)
+		}
+		return nil
+	} else if _, ok := err.(*os.LinkError); !ok {
+		return err
+	}
+
+	in, err := fs.Open(seg.Path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := fs.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil && sync {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = fs.Rename(tmp, dst)
+	}
+	if err == nil && sync {
+		err = syncDir(fs, dir)
+	}
+	if err != nil {
+		_ = fs.Remove(tmp)
+		return err
+	}
+	return fs.Remove(seg.Path)
+}
+
+// ListWALArchive 返回 dir（Options.WALArchiveDir）下归档的 WAL 段，按序号升序。
+// 不是归档段命名格式的文件会被忽略。
+func ListWALArchive(dir string) ([]ArchivedWAL, error) {
+	return listWALArchive(vfs.Default, dir)
+}
+
+func listWALArchive(fs vfs.FS, dir string) ([]ArchivedWAL, error) {
+	list, err := vfs.Glob(fs, dir, "*.log")
+	if err != nil {
+		return nil, err
+	}
+	var segs []ArchivedWAL
+	for _, p := range list {
+		var seg ArchivedWAL
+		if _, err := fmt.Sscanf(filepath.Base(p), "%d-%d.log", &seg.FirstSeq, &seg.LastSeq); err != nil || seg.FirstSeq > seg.LastSeq {
+			continue
+		}
+		seg.Path = p
+		segs = append(segs, seg)
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i].FirstSeq < segs[j].FirstSeq })
+	return segs, nil
+}
+
+// ReplayWALArchive 按序号顺序对 dir 下归档的、序号在 [from, to] 内的每条记录调用 fn，
+// to 为 0 表示一直回放到归档末尾。fn 返回错误时停止并返回该错误。加密的记录用 opts.Encryption 解密。
+//
+// 配合备份做时间点恢复：恢复一份 LastSequence 为 n 的备份，打开之后
+// 用 ReplayWALArchive(dir, opts, n+1, target, ...) 把记录逐条交给 DB.ApplyRecord，
+// 数据库就回到了提交第 target 条记录之后的状态。段之间有缺口时返回 ErrWALArchiveGap。
+func ReplayWALArchive(dir string, opts Options, from, to uint64, fn func(seq uint64, r wal.Record) error) error {
+	segs, err := listWALArchive(opts.fs(), dir)
+	if err != nil {
+		return err
+	}
+	openEnded := to == 0
+	if openEnded {
+		to = math.MaxUint64
+	}
+	next := max(from, 1) // 序号从 1 开始
+	for _, seg := range segs {
+		if seg.LastSeq < next {
+			continue
+		}
+		if next > to {
+			break
+		}
+		if seg.FirstSeq > next {
+			return fmt.Errorf("%w: missing records %d-%d", ErrWALArchiveGap, next, seg.FirstSeq-1)
+		}
+		records, err := wal.ReplayWithOptions(seg.Path, opts.walOptions())
+		if err != nil {
+			return fmt.Errorf("db: replay %s: %w", seg.Path, err)
+		}
+		for i, r := range records {
+			seq := seg.FirstSeq + uint64(i)
+			if seq < next {
+				continue
+			}
+			if seq > to {
+				return nil
+			}
+			if err := fn(seq, r); err != nil {
+				return err
+			}
+			next = seq + 1
+		}
+	}
+	if !openEnded && next <= to {
+		return fmt.Errorf("%w: archive ends before record %d", ErrWALArchiveGap, next)
+	}
+	return nil
+}
+
+// seqTimeFileName 在 WALArchiveDir 下记录序号与提交时间的对应关系，供 RestoreToTime 使用。
+const seqTimeFileName = "SEQTIME"
+
+// seqTimeLog 在每一秒的第一次提交时向 SEQTIME 追加一行 "<seq> <unix 纳秒>"：
+// 序号小于 seq 的记录都是在这一秒之前提交的，所以按时间恢复的精度是一秒。
+//
+// 文件只追加、不 fsync，丢掉几行只会让 RestoreToTime 的精度变差。崩溃后序号可能被重新分配，
+// 新的一行时间更晚，按文件顺序查找时依然正确。
+type seqTimeLog struct {
+	f       vfs.File
+	lastSec int64
+}
+
+func openSeqTimeLog(fs vfs.FS, dir string) (*seqTimeLog, error) {
+	if err := fs.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := fs.OpenFile(filepath.Join(dir, seqTimeFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &seqTimeLog{f: f, lastSec: math.MinInt64}, nil
+}
+
+// mark 在提交第 seq 条记录时（写锁内）调用，now 是 unix 纳秒。
+func (l *seqTimeLog) mark(seq uint64, now int64) {
+	sec := now / int64(time.Second)
+	if sec == l.lastSec {
+		return
+	}
+	l.lastSec = sec
+	_, _ = fmt.Fprintf(l.f, "%d %d\n", seq, now)
+}
+
+func (l *seqTimeLog) close() error { return l.f.Close() }
+
+// seqAtTime 返回在 t 所在的这一秒结束之前提交的最后一条记录的序号；
+// ok 为 false 表示归档里所有记录都不晚于 t。
+func seqAtTime(fs vfs.FS, dir string, t time.Time) (seq uint64, ok bool, err error) {
+	f, err := fs.Open(filepath.Join(dir, seqTimeFileName))
+	if err != nil {
+		return 0, false, err
+	}
+	defer f.Close()
+
+	limit := t.Unix()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var s uint64
+		var ns int64
+		if _, err := fmt.Sscanf(sc.Text(), "%d %d", &s, &ns); err != nil {
+			continue
+		}
+		if ns/int64(time.Second) > limit {
+			return s - 1, true, nil
+		}
+	}
+	return 0, false, sc.Err()
+}
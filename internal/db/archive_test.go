@@ -0,0 +1,126 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/vfs"
+	"monolithdb/internal/wal"
+)
+
+func TestWALArchivePointInTimeRecovery(t *testing.T) {
+	root := t.TempDir()
+	archive := filepath.Join(root, "archive")
+	var hooked []ArchivedWAL
+	d, err := OpenWithOptions(filepath.Join(root, "data"), Options{
+		DisableFsync:         true,
+		WALRetentionSegments: 1,
+		WALArchiveDir:        archive,
+		WALArchiveHook:       func(seg ArchivedWAL) error { hooked = append(hooked, seg); return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// 三段：seq 1-2、3-5、6；最新的一段保留在数据目录供 Changes 使用
+	for i, n := range []int{2, 3, 1} {
+		for j := 0; j < n; j++ {
+			if err := d.Put(fmt.Sprintf("k%d", j), []byte(fmt.Sprintf("v%d.%d", i, j))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := d.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	segs, err := ListWALArchive(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segs) != 2 || segs[0].FirstSeq != 1 || segs[0].LastSeq != 2 || segs[1].FirstSeq != 3 || segs[1].LastSeq != 5 {
+		t.Fatalf("archive = %+v", segs)
+	}
+	if len(hooked) != 2 || hooked[1].FirstSeq != 3 || hooked[1].LastSeq != 5 {
+		t.Fatalf("hook calls = %+v", hooked)
+	}
+
+	// 从空库开始回放到 seq 4：k0 / k1 是第二段的新值，k2 还是第一段之后的状态（不存在）
+	r, err := OpenWithOptions(filepath.Join(root, "restore"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	err = ReplayWALArchive(archive, Options{}, 1, 4, func(seq uint64, rec wal.Record) error {
+		return r.ApplyRecord(rec)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.LastSequence() != 4 {
+		t.Fatalf("LastSequence = %d", r.LastSequence())
+	}
+	for k, want := range map[string]string{"k0": "v1.0", "k1": "v1.1"} {
+		if v, ok, _ := r.Get(k); !ok || string(v) != want {
+			t.Fatalf("Get(%s) = %q, %v", k, v, ok)
+		}
+	}
+	if _, ok, _ := r.Get("k2"); ok {
+		t.Fatal("k2 was written after the target sequence")
+	}
+
+	// 缺段时报错，而不是悄悄跳过
+	if err := ReplayWALArchive(archive, Options{}, 1, 6, func(uint64, wal.Record) error { return nil }); !errors.Is(err, ErrWALArchiveGap) {
+		t.Fatalf("replay past the archive: err = %v", err)
+	}
+	if err := os.Remove(segs[0].Path); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReplayWALArchive(archive, Options{}, 1, 0, func(uint64, wal.Record) error { return nil }); !errors.Is(err, ErrWALArchiveGap) {
+		t.Fatalf("missing first segment: err = %v", err)
+	}
+}
+
+func TestWALArchiveHookFailureKeepsSegment(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	fail := errors.New("upload failed")
+	var calls int
+	d, err := OpenWithOptions(dir, Options{
+		DisableFsync: true,
+		WALArchiveHook: func(ArchivedWAL) error {
+			calls++
+			if calls == 1 {
+				return fail
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.Put("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); !errors.Is(err, fail) {
+		t.Fatalf("Flush err = %v", err)
+	}
+	// 新 WAL 已经打开，写入不受影响；段留在原处，下次 Flush 时重试
+	if err := d.Put("b", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if segs, _ := listWALSegments(vfs.Default, dir); len(segs) != 1 {
+		t.Fatalf("segments after failed hook = %+v", segs)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if segs, _ := listWALSegments(vfs.Default, dir); len(segs) != 0 || calls != 3 {
+		t.Fatalf("segments = %+v, hook calls = %d", segs, calls)
+	}
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"log"
+	"time"
+)
+
+// startAutoFlush 启动每隔 interval 把 MemTable 刷成 SST 的后台 goroutine（见 Options.FlushInterval），Close 时停止。
+// MemTable 为空时什么也不做，所以写得很少的数据库也不会每个周期产生一张空表。
+// Options.Clock 能安排定时（例如 ManualClock）时按它计时，否则按真实时间；周期从上一次 Flush 结束时算起。
+func (d *DB) startAutoFlush(interval time.Duration) {
+	after := time.After
+	if c, ok := d.opts.clock().(timerClock); ok {
+		after = c.After
+	}
+	d.flushStop = make(chan struct{})
+	d.flushDone = make(chan struct{})
+	go func() {
+		defer close(d.flushDone)
+		for {
+			select {
+			case <-d.flushStop:
+				return
+			case <-after(interval):
+			}
+			if err := d.Flush(); err != nil {
+				// 下一个周期再试，数据还在 WAL 里
+				log.Printf("forgedb: periodic flush: %v", err)
+			}
+		}
+	}()
+}
+
+// stopAutoFlush 停止定时 Flush 并等待正在进行的一次结束。
+func (d *DB) stopAutoFlush() {
+	if d.flushStop == nil {
+		return
+	}
+	close(d.flushStop)
+	<-d.flushDone
+	d.flushStop = nil
+}
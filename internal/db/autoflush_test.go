@@ -0,0 +1,68 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitAfterCalls 等待 clock.After 至少被调用 n 次，也就是后台 goroutine（定时 Flush、巡检）处理完上一步、开始等待时钟。
+func waitAfterCalls(t *testing.T, clock *ManualClock, n int) int {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for clock.afterCalls() < n {
+		if time.Now().After(deadline) {
+			t.Fatal("background goroutine did not wait on the clock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return n
+}
+
+func TestFlushInterval(t *testing.T) {
+	const interval = time.Minute
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, FlushInterval: interval, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := waitAfterCalls(t, clock, 1)
+
+	// 空的 MemTable 不会产生表
+	clock.Advance(interval)
+	calls = waitAfterCalls(t, clock, calls+1)
+	if n := d.Counters().Flushes; n != 0 {
+		t.Fatalf("%d flushes of an empty memtable", n)
+	}
+
+	// 时钟没有推进一个周期时不会 Flush
+	if err := d.Put("k", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(interval / 2)
+	if n := d.Counters().Flushes; n != 0 {
+		t.Fatalf("%d flushes before the interval elapsed", n)
+	}
+	clock.Advance(interval / 2)
+	waitAfterCalls(t, clock, calls+1)
+	if n := d.Counters().Flushes; n != 1 {
+		t.Fatalf("%d flushes after one interval, want 1", n)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新打开时 WAL 已经是空的，数据在 SST 里
+	d, err = OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if rec := d.Recovery(); rec.WALRecords != 0 {
+		t.Fatalf("replayed %d WAL records after periodic flush", rec.WALRecords)
+	}
+	if v, ok, err := d.Get("k"); err != nil || !ok || string(v) != "v" {
+		t.Fatalf("Get = %q, %v, %v", v, ok, err)
+	}
+}
@@ -0,0 +1,369 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"monolithdb/internal/manifest"
+	"monolithdb/internal/vfs"
+)
+
+var (
+	// ErrNotBackup 表示目录里没有 BACKUP 元数据，不是 Backup / IncrementalBackup 创建的备份。
+	ErrNotBackup = errors.New("db: not a backup directory")
+
+	// ErrBackupChain 表示增量备份和它的基准备份链对不上：基准比数据库当前的状态还新，
+	// 或者恢复时基准备份里缺少需要的文件。
+	ErrBackupChain = errors.New("db: broken backup chain")
+)
+
+// backupFileName 是备份目录下元数据文件的名字。
+const backupFileName = "BACKUP"
+
+// backupCRCTable 是备份文件校验和使用的 crc32c 表。
+var backupCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// BackupInfo 是备份目录下 BACKUP 文件的内容。
+type BackupInfo struct {
+	Seq uint64 // 备份包含的最后一条记录的序号
+
+	// Base 是增量备份的基准备份目录（相对这个备份目录的路径），BaseSeq 是基准备份的 Seq。
+	// 全量备份两者都是零值。
+	Base    string
+	BaseSeq uint64
+
+	// Files 是恢复需要的全部 SST 和值日志文件（按 Name 升序），包括保存在基准备份链里的。
+	Files []BackupFile
+}
+
+// BackupFile 是备份里的一个数据文件。
+type BackupFile struct {
+	Name   string // 相对数据目录的路径，例如 "sst/000012.sst"
+	Size   int64
+	CRC32C uint32 // 整个文件的 crc32c
+
+	// Dir 是实际保存这个文件的备份目录（相对这个备份目录的路径），空表示就在这个备份里。
+	// 增量备份没有变化的文件留在基准备份链里，恢复时直接从那里读取。
+	Dir string `json:",omitempty"`
+}
+
+// Backup 在 dir（必须不存在或为空）下创建全量备份：与 Checkpoint 相同的一致副本，另外在 BACKUP 文件里
+// 记录每个 SST 和值日志文件的大小和校验和，可以作为 IncrementalBackup 的基准。全量备份的目录可以直接打开。
+func (d *DB) Backup(dir string) (BackupInfo, error) {
+	return d.backup(dir, "")
+}
+
+// IncrementalBackup 在 dir（必须不存在或为空）下创建相对 base 的增量备份，base 是之前用 Backup 或
+// IncrementalBackup 创建的备份目录，可以一层层链下去。
+//
+// 与 Checkpoint 一样先 Flush，所以 base 之后的写入都在 SST 里，不需要复制 WAL。之后只复制 base 链里
+// 没有的 SST 和值日志文件：base.Seq 之后写入的记录所在的表、compaction 产生的新表等；没有变化的文件
+// 只在 BACKUP 里引用基准备份中的那一份。SST 和值日志文件写完就不再修改，编号也不会重复使用
+// （见 versionSet.persistFileNumber），按文件名、大小和校验和判断是否变化。所有文件会先链接到 dir 下
+// 再计算校验和，不能硬链接的文件系统上相当于先复制一遍。
+//
+// 不能只按序号挑选表：compaction 会把新旧记录合进同一张表，也会丢掉已经没用的 tombstone，
+// 只复制含有新记录的表会让被删除的 key 在恢复时复活，所以恢复使用的总是完整的文件列表。
+//
+// 增量备份的目录不能直接打开，要用 RestoreBackup（或者作为 RestoreOptions.Checkpoint）恢复，
+// 恢复时链上的所有基准备份都必须还在原来的相对位置。
+func (d *DB) IncrementalBackup(dir, base string) (BackupInfo, error) {
+	if base == "" {
+		return BackupInfo{}, fmt.Errorf("%w: no base backup", ErrBackupChain)
+	}
+	return d.backup(dir, base)
+}
+
+func (d *DB) backup(dir, base string) (BackupInfo, error) {
+	if d.opts.ReadOnly {
+		return BackupInfo{}, ErrReadOnly
+	}
+	var inherited map[string]BackupFile
+	var info BackupInfo
+	if base != "" {
+		b, err := readBackupInfo(d.opts.fs(), base)
+		if err != nil {
+			return BackupInfo{}, err
+		}
+		if info.Base, err = relDir(dir, base); err != nil {
+			return BackupInfo{}, err
+		}
+		info.BaseSeq = b.Seq
+		inherited = make(map[string]BackupFile, len(b.Files))
+		for _, f := range b.Files {
+			f.Dir = filepath.Join(info.Base, f.Dir)
+			inherited[f.Name] = f
+		}
+	}
+	if err := prepareEmptyDir(d.opts.fs(), dir); err != nil {
+		return BackupInfo{}, err
+	}
+	if err := d.writeBackup(dir, &info, inherited); err != nil {
+		_ = d.opts.fs().RemoveAll(dir)
+		return BackupInfo{}, err
+	}
+	return info, nil
+}
+
+// writeBackup 复制文件并写出 BACKUP。只有链接文件时持有写锁，计算校验和时读的是备份里的那一份。
+// 名字、大小和校验和都与 inherited 里的一样的文件删掉备份里的这一份，改为引用基准备份链里的。
+func (d *DB) writeBackup(dir string, info *BackupInfo, inherited map[string]BackupFile) error {
+	fs, sync := d.opts.fs(), !d.opts.DisableFsync
+	if err := d.linkBackupFiles(dir, info); err != nil {
+		return err
+	}
+	for i := range info.Files {
+		f := &info.Files[i]
+		path := filepath.Join(dir, f.Name)
+		crc, err := fileCRC(fs, path)
+		if err != nil {
+			return err
+		}
+		if old, ok := inherited[f.Name]; ok && old.Size == f.Size && old.CRC32C == crc {
+			if err := fs.Remove(path); err != nil {
+				return err
+			}
+			*f = old
+			continue
+		}
+		f.CRC32C = crc
+	}
+
+	b, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := vfs.WriteFile(fs, filepath.Join(dir, backupFileName), append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	if sync {
+		for _, sub := range []string{sstDirName, vlogDirName} {
+			if _, err := fs.Stat(filepath.Join(dir, sub)); err == nil {
+				if err := syncDir(fs, filepath.Join(dir, sub)); err != nil {
+					return err
+				}
+			}
+		}
+		return syncDir(fs, dir)
+	}
+	return nil
+}
+
+// linkBackupFiles 持有写锁 Flush，把所有 SST 和值日志文件链接到 dir 下，
+// 写出 MANIFEST 和 WALSEQ，填好 info 的 Seq 和 Files（校验和留给调用方计算）。
+func (d *DB) linkBackupFiles(dir string, info *BackupInfo) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.flushLocked(); err != nil {
+		return err
+	}
+	if d.lastSeq < info.BaseSeq {
+		return fmt.Errorf("%w: base backup is at %d, database is at %d", ErrBackupChain, info.BaseSeq, d.lastSeq)
+	}
+	info.Seq = d.lastSeq
+
+	ids, err := listValueLogs(d.opts.fs(), d.versions.vlog.dir)
+	if err != nil {
+		return err
+	}
+	srcs := append([]string(nil), d.versions.current().tables...)
+	for _, id := range ids {
+		srcs = append(srcs, d.versions.vlog.path(id))
+	}
+
+	fs, sync := d.opts.fs(), !d.opts.DisableFsync
+	for _, src := range srcs {
+		name, err := filepath.Rel(d.dir, src)
+		if err != nil {
+			return err
+		}
+		size, err := fileSize(fs, src)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, name)
+		if err := fs.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		if err := linkOrCopy(fs, src, dst, sync); err != nil {
+			return err
+		}
+		info.Files = append(info.Files, BackupFile{Name: name, Size: size})
+	}
+	sort.Slice(info.Files, func(i, j int) bool { return info.Files[i].Name < info.Files[j].Name })
+
+	if err := linkOrCopy(fs, filepath.Join(d.dir, manifest.FileName), filepath.Join(dir, manifest.FileName), sync); err != nil {
+		return err
+	}
+	// 与 checkpoint 一样 WAL 是空的：恢复出的数据库 LastSequence 就是 WALSEQ - 1
+	seq := []byte(strconv.FormatUint(d.lastSeq+1, 10) + "\n")
+	if err := vfs.WriteFile(fs, filepath.Join(dir, walSeqFileName), seq, 0o644); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReadBackupInfo 读取备份目录 dir 下的 BACKUP 文件。
+func ReadBackupInfo(dir string) (BackupInfo, error) {
+	return readBackupInfo(vfs.Default, dir)
+}
+
+func readBackupInfo(fs vfs.FS, dir string) (BackupInfo, error) {
+	var info BackupInfo
+	b, err := vfs.ReadFile(fs, filepath.Join(dir, backupFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return info, fmt.Errorf("%w: %s", ErrNotBackup, dir)
+	}
+	if err != nil {
+		return info, err
+	}
+	if err := json.Unmarshal(b, &info); err != nil {
+		return info, fmt.Errorf("%w: %s: %v", ErrNotBackup, dir, err)
+	}
+	return info, nil
+}
+
+// RestoreBackup 在 dir（必须不存在或为空）下从备份 backup 恢复出数据目录：全量备份直接复制，
+// 增量备份按 BACKUP 里的文件列表从基准备份链里收集文件（能硬链接时用硬链接）。
+// 基准备份里缺少文件或者文件大小不对时返回 ErrBackupChain；出错时 dir 会被清理。
+// 不检查备份与之后打开它时使用的配置是否兼容，需要时用 RestoreBackupWithOptions。
+func RestoreBackup(dir, backup string) error {
+	return restoreBackup(vfs.Default, dir, backup)
+}
+
+func restoreBackup(fs vfs.FS, dir, backup string) error {
+	if err := prepareEmptyDir(fs, dir); err != nil {
+		return err
+	}
+	if err := restoreBackupFiles(fs, backup, dir); err != nil {
+		_ = fs.RemoveAll(dir)
+		return err
+	}
+	return nil
+}
+
+// RestoreBackupOptions 控制 RestoreBackupWithOptions。
+type RestoreBackupOptions struct {
+	// DryRun 为 true 时只做检查并返回恢复计划，不创建、也不修改 dir。
+	DryRun bool
+
+	// Options 是之后打开恢复出的数据库时要使用的配置，比较器、KeyNormalizer 等必须与备份的 MANIFEST 一致，
+	// 否则返回 ErrIncompatibleOptions。
+	Options Options
+}
+
+// RestorePlan 描述一次从备份恢复会做什么。
+type RestorePlan struct {
+	Seq   uint64       // 恢复出的数据库的 LastSequence
+	Files []BackupFile // 要复制的文件，Dir 是相对备份目录的来源位置
+	Bytes int64        // Files 的总大小
+}
+
+// RestoreBackupWithOptions 与 RestoreBackup 相同，但在动 dir 之前先检查：dir 不存在或为空、
+// 备份的 MANIFEST 与 opts.Options 兼容、基准备份链里的文件都在并且大小正确，然后返回恢复计划。
+// opts.DryRun 为 true 时到此为止，可以在真正恢复之前确认备份可用、目标位置合适。
+// 检查只看文件大小，要连同校验和一起检查用 VerifyBackup。
+func RestoreBackupWithOptions(dir, backup string, opts RestoreBackupOptions) (RestorePlan, error) {
+	fs := opts.Options.fs()
+	if err := checkEmptyDir(fs, dir); err != nil {
+		return RestorePlan{}, err
+	}
+	ro := opts.Options
+	ro.ReadOnly = true
+	if err := checkManifest(backup, ro); err != nil {
+		return RestorePlan{}, err
+	}
+	plan, err := planBackupRestore(fs, backup)
+	if err != nil || opts.DryRun {
+		return plan, err
+	}
+	return plan, restoreBackup(fs, dir, backup)
+}
+
+// planBackupRestore 读取备份 backup 的文件列表，检查每个文件都在并且大小正确。
+func planBackupRestore(fs vfs.FS, backup string) (RestorePlan, error) {
+	info, err := readBackupInfo(fs, backup)
+	if err != nil {
+		return RestorePlan{}, err
+	}
+	plan := RestorePlan{Seq: info.Seq, Files: info.Files}
+	for _, f := range info.Files {
+		src := filepath.Join(backup, f.Dir, f.Name)
+		size, err := fileSize(fs, src)
+		if errors.Is(err, os.ErrNotExist) {
+			return RestorePlan{}, fmt.Errorf("%w: %s is missing", ErrBackupChain, src)
+		}
+		if err != nil {
+			return RestorePlan{}, err
+		}
+		if size != f.Size {
+			return RestorePlan{}, fmt.Errorf("%w: %s has %d bytes, want %d", ErrBackupChain, src, size, f.Size)
+		}
+		plan.Bytes += size
+	}
+	return plan, nil
+}
+
+// restoreBackupFiles 把备份 backup 需要的文件收集到已经存在的目录 dir 下。
+func restoreBackupFiles(fs vfs.FS, backup, dir string) error {
+	plan, err := planBackupRestore(fs, backup)
+	if err != nil {
+		return err
+	}
+	for _, f := range plan.Files {
+		dst := filepath.Join(dir, f.Name)
+		if err := fs.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		if err := linkOrCopy(fs, filepath.Join(backup, f.Dir, f.Name), dst, false); err != nil {
+			return err
+		}
+	}
+	for _, name := range []string{manifest.FileName, walSeqFileName} {
+		if err := copyFile(fs, filepath.Join(backup, name), filepath.Join(dir, name), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isBackup 报告 dir 是否是 Backup / IncrementalBackup 创建的备份。
+func isBackup(fs vfs.FS, dir string) bool {
+	_, err := fs.Stat(filepath.Join(dir, backupFileName))
+	return err == nil
+}
+
+// relDir 返回从 dir 到 target 的相对路径。
+func relDir(dir, target string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(absDir, absTarget)
+}
+
+// fileCRC 计算整个文件的 crc32c。
+func fileCRC(fs vfs.FS, path string) (uint32, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	h := crc32.New(backupCRCTable)
+	if _, err := io.Copy(h, f); err != nil {
+		return 0, err
+	}
+	return h.Sum32(), nil
+}
@@ -0,0 +1,340 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/vfs"
+)
+
+func TestIncrementalBackup(t *testing.T) {
+	root := t.TempDir()
+	d, err := OpenWithOptions(filepath.Join(root, "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	put := func(from, to int, v string) {
+		t.Helper()
+		for i := from; i < to; i++ {
+			if err := d.Put(fmt.Sprintf("k%03d", i), []byte(v)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	check := func(backup string, wantSeq uint64, want map[string]string) {
+		t.Helper()
+		dir := filepath.Join(root, "restore-"+filepath.Base(backup))
+		if err := RestoreBackup(dir, backup); err != nil {
+			t.Fatal(err)
+		}
+		r, err := OpenWithOptions(dir, Options{DisableFsync: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		if r.LastSequence() != wantSeq {
+			t.Fatalf("%s: LastSequence = %d, want %d", backup, r.LastSequence(), wantSeq)
+		}
+		entries, err := r.Range("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(want) {
+			t.Fatalf("%s: restored %d keys, want %d", backup, len(entries), len(want))
+		}
+		for _, e := range entries {
+			if want[e.Key] != string(e.Value) {
+				t.Fatalf("%s: %s = %q, want %q", backup, e.Key, e.Value, want[e.Key])
+			}
+		}
+	}
+
+	put(0, 100, "v1")
+	full := filepath.Join(root, "backups", "full")
+	info, err := d.Backup(full)
+	if err != nil || info.Seq != 100 || info.Base != "" || len(info.Files) != 1 || info.Files[0].CRC32C == 0 {
+		t.Fatalf("Backup = %+v, %v", info, err)
+	}
+
+	// 第一个增量：只有新写入的表需要复制
+	put(100, 120, "v2")
+	inc1 := filepath.Join(root, "backups", "inc1")
+	info, err = d.IncrementalBackup(inc1, full)
+	if err != nil || info.Seq != 120 || info.BaseSeq != 100 || info.Base != "../full" || len(info.Files) != 2 {
+		t.Fatalf("IncrementalBackup = %+v, %v", info, err)
+	}
+	if info.Files[0].Dir != "../full" || info.Files[1].Dir != "" {
+		t.Fatalf("incremental files: %+v", info.Files)
+	}
+
+	// 第二个增量：删除之后 compaction 把所有表合成一张，被删除的 key 不能在恢复时复活
+	for i := 0; i < 50; i++ {
+		if err := d.Delete(fmt.Sprintf("k%03d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	inc2 := filepath.Join(root, "backups", "inc2")
+	if info, err = d.IncrementalBackup(inc2, inc1); err != nil || info.BaseSeq != 120 {
+		t.Fatalf("second IncrementalBackup = %+v, %v", info, err)
+	}
+
+	want := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		want[fmt.Sprintf("k%03d", i)] = "v1"
+	}
+	check(full, 100, want)
+	for i := 100; i < 120; i++ {
+		want[fmt.Sprintf("k%03d", i)] = "v2"
+	}
+	check(inc1, 120, want)
+	for i := 0; i < 50; i++ {
+		delete(want, fmt.Sprintf("k%03d", i))
+	}
+	check(inc2, 170, want)
+
+	// 增量备份也可以作为时间点恢复的基准
+	if err := RestoreToSequence(filepath.Join(root, "pitr"), 120, RestoreOptions{Checkpoint: inc1, Options: Options{DisableFsync: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 基准备份里的文件丢失时恢复失败，并且不留下半成品
+	if err := os.RemoveAll(filepath.Join(full, sstDirName)); err != nil {
+		t.Fatal(err)
+	}
+	broken := filepath.Join(root, "broken")
+	if err := RestoreBackup(broken, inc1); !errors.Is(err, ErrBackupChain) {
+		t.Fatalf("RestoreBackup with a missing base file: %v", err)
+	}
+	if _, err := os.Stat(broken); !os.IsNotExist(err) {
+		t.Fatalf("failed restore left %s behind: %v", broken, err)
+	}
+
+	if _, err := d.IncrementalBackup(filepath.Join(root, "x"), root); !errors.Is(err, ErrNotBackup) {
+		t.Fatalf("IncrementalBackup on a non-backup base: %v", err)
+	}
+}
+
+func TestVerifyBackupAndDryRun(t *testing.T) {
+	root := t.TempDir()
+	d, err := OpenWithOptions(filepath.Join(root, "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for i := 0; i < 100; i++ {
+		if err := d.Put(fmt.Sprintf("k%03d", i), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	full := filepath.Join(root, "full")
+	if _, err := d.Backup(full); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("new", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	inc := filepath.Join(root, "inc")
+	info, err := d.IncrementalBackup(inc, full)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := VerifyBackup(inc)
+	if err != nil || !rep.OK() || rep.Seq != 101 || rep.FilesChecked != 2 {
+		t.Fatalf("VerifyBackup = %+v, %v", rep, err)
+	}
+
+	// 试运行只做检查，不创建目标目录
+	target := filepath.Join(root, "restored")
+	plan, err := RestoreBackupWithOptions(target, inc, RestoreBackupOptions{DryRun: true})
+	if err != nil || plan.Seq != 101 || len(plan.Files) != 2 || plan.Bytes != info.Files[0].Size+info.Files[1].Size {
+		t.Fatalf("dry run = %+v, %v", plan, err)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("dry run created %s: %v", target, err)
+	}
+	if _, err := RestoreBackupWithOptions(target, inc, RestoreBackupOptions{DryRun: true, Options: Options{KeyNormalizer: LowercaseKeys}}); !errors.Is(err, ErrIncompatibleOptions) {
+		t.Fatalf("dry run with incompatible options: %v", err)
+	}
+	if _, err := RestoreBackupWithOptions(root, inc, RestoreBackupOptions{DryRun: true}); err == nil {
+		t.Fatal("dry run into a non-empty directory succeeded")
+	}
+	if _, err := RestoreBackupWithOptions(target, inc, RestoreBackupOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenWithOptions(target, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := r.Get("new"); err != nil || !ok || string(v) != "x" {
+		t.Fatalf("restored new = %q %v %v", v, ok, err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 基准备份里的表被改坏：增量备份的检查也会发现
+	base := filepath.Join(full, info.Files[0].Name)
+	b, err := os.ReadFile(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[len(b)/2] ^= 0xff
+	// 硬链接的文件和数据库共享 inode，先断开再改
+	if err := os.Remove(base); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(base, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inc, sstDirName, "999999.sst"), []byte("stray"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rep, err = VerifyBackup(inc)
+	if err != nil || len(rep.Corrupt) != 2 {
+		t.Fatalf("VerifyBackup after corruption = %+v, %v", rep, err)
+	}
+	for _, cf := range rep.Corrupt {
+		if !errors.Is(cf.Err, ErrCorruptBackup) {
+			t.Fatalf("problem %s: %v", cf.File, cf.Err)
+		}
+	}
+	if rep.Corrupt[0].File != filepath.Join("..", "full", info.Files[0].Name) {
+		t.Fatalf("corrupt file reported as %q", rep.Corrupt[0].File)
+	}
+
+	if _, err := VerifyBackup(root); !errors.Is(err, ErrNotBackup) {
+		t.Fatalf("VerifyBackup on a non-backup: %v", err)
+	}
+}
+
+func TestIncrementalBackupAfterFileNumbersRestart(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("k1", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	full := filepath.Join(root, "full")
+	if _, err := d.Backup(full); err != nil {
+		t.Fatal(err)
+	}
+
+	// compaction 删光 sst/ 之后重启：新文件不能重新用 000001.sst 这个编号
+	if err := d.Delete("k1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d, err = OpenWithOptions(dir, Options{DisableFsync: true}); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Put("k2", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+
+	inc := filepath.Join(root, "inc")
+	info, err := d.IncrementalBackup(inc, full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range info.Files {
+		if f.Name == filepath.Join(sstDirName, "000001.sst") {
+			t.Fatalf("file number 1 was reused: %+v", info.Files)
+		}
+	}
+
+	restored := filepath.Join(root, "restored")
+	if err := RestoreBackup(restored, inc); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Open(restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, ok, err := r.Get("k1"); err != nil || ok {
+		t.Fatalf("deleted k1 came back: ok=%v err=%v", ok, err)
+	}
+	if v, ok, err := r.Get("k2"); err != nil || !ok || string(v) != "v1" {
+		t.Fatalf("Get(k2) = %q, %v, %v", v, ok, err)
+	}
+}
+
+func TestIncrementalBackupComparesChecksums(t *testing.T) {
+	root := t.TempDir()
+	d, err := OpenWithOptions(filepath.Join(root, "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Put("k1", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	full := filepath.Join(root, "full")
+	if _, err := d.Backup(full); err != nil {
+		t.Fatal(err)
+	}
+
+	// 基准备份里同名、同大小但内容不同的文件不能被引用
+	name := filepath.Join(sstDirName, "000001.sst")
+	b, err := os.ReadFile(filepath.Join(full, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(full, name)); err != nil {
+		t.Fatal(err)
+	}
+	b[len(b)/2] ^= 0xff
+	if err := os.WriteFile(filepath.Join(full, name), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := ReadBackupInfo(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crc, err := fileCRC(vfs.Default, filepath.Join(full, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi.Files[0].CRC32C = crc
+	bi, err := json.Marshal(fi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(full, backupFileName), bi, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := d.IncrementalBackup(filepath.Join(root, "inc"), full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Files) != 1 || info.Files[0].Name != name || info.Files[0].Dir != "" {
+		t.Fatalf("expected %s to be copied, got %+v", name, info.Files)
+	}
+}
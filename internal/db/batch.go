@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"monolithdb/internal/wal"
+)
+
+// Batch 收集一组写操作，由 DB.Write 原子地提交：
+// 整批写成一条 WAL 记录，崩溃后要么全部可见，要么全部不可见。
+//
+// Batch 不是并发安全的。
+type Batch struct {
+	recs []wal.Record
+}
+
+// Put 向批次追加一次写入。
+func (b *Batch) Put(key string, value []byte) {
+	b.recs = append(b.recs, wal.Record{Op: wal.OpPut, Key: key, Value: value})
+}
+
+// PutWithTTL 向批次追加一次带过期时间的写入；过期时间从 Write 时（按 Options.Clock）开始计算。
+// ttl <= 0 等同于 Put。
+func (b *Batch) PutWithTTL(key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		b.Put(key, value)
+		return
+	}
+	// 提交前 ExpiresAt 暂存相对的 ttl，Write 时换算成绝对时间
+	b.recs = append(b.recs, wal.Record{Op: wal.OpPutTTL, Key: key, Value: value, ExpiresAt: int64(ttl)})
+}
+
+// Delete 向批次追加一次删除。
+func (b *Batch) Delete(key string) {
+	b.recs = append(b.recs, wal.Record{Op: wal.OpDelete, Key: key})
+}
+
+// Len 返回批次中的操作数。
+func (b *Batch) Len() int { return len(b.recs) }
+
+// Reset 清空批次以便复用。
+func (b *Batch) Reset() { b.recs = b.recs[:0] }
+
+// Write 使用 context.Background() 提交批次，见 WriteContext。
+func (d *DB) Write(b *Batch) error {
+	return d.WriteContext(context.Background(), b)
+}
+
+// WriteContext 原子地提交批次中的所有操作；同一个 key 出现多次时后面的操作生效。
+// 空批次直接返回 nil。与 PutContext 一样，ctx 只约束写 WAL 之前的等待。
+func (d *DB) WriteContext(ctx context.Context, b *Batch) error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.throttleWrite(ctx); err != nil {
+		return err
+	}
+	return d.writeLocked(b)
+}
+
+// writeLocked 是 Write 的实现，调用方持有写锁。
+func (d *DB) writeLocked(b *Batch) error {
+	if b.Len() == 0 {
+		return nil
+	}
+	r := wal.Record{Op: wal.OpBatch, Batch: append([]wal.Record(nil), b.recs...)}
+	now := d.now()
+	for i := range r.Batch {
+		r.Batch[i].Key = d.normKey(r.Batch[i].Key)
+		if err := d.interceptRecord(&r.Batch[i]); err != nil {
+			return err
+		}
+		if err := d.checkSize(r.Batch[i].Key, r.Batch[i].Value); err != nil {
+			return err
+		}
+		if r.Batch[i].Op == wal.OpPutTTL {
+			r.Batch[i].ExpiresAt += now
+		}
+	}
+	if err := d.wal.AppendBatch(r.Batch); err != nil {
+		return err
+	}
+	if err := applyRecord(d.mem, d.imm, d.versions, r, d.lastSeq+1, d.sstReadOptions(ReadOptions{})); err != nil {
+		return err
+	}
+	d.afterApply(r)
+	d.commit(r)
+	return nil
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteBatchAtomicReplay(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Put("old", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	var b Batch
+	b.Put("a", []byte("1"))
+	b.Put("b", []byte("2"))
+	b.Delete("old")
+	b.Put("a", []byte("3")) // 同一批次内后面的操作生效
+	if err := d.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, _ := d.Get("a"); !ok || string(v) != "3" {
+		t.Fatalf("a = %q %v", v, ok)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 截掉 batch 记录的最后一个字节：重启后整批都不可见，之前的 put 仍然可见
+	walPath := filepath.Join(dir, walFileName)
+	st, err := os.Stat(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := os.ReadFile(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(walPath, st.Size()-1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Repair(dir); err != nil {
+		t.Fatal(err)
+	}
+	d, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"a", "b"} {
+		if _, ok, _ := d.Get(k); ok {
+			t.Fatalf("%s visible after torn batch", k)
+		}
+	}
+	if _, ok, _ := d.Get("old"); !ok {
+		t.Fatalf("old should survive a torn batch")
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 完整的 batch 回放后全部可见
+	if err := os.WriteFile(walPath, full, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+	if v, ok, _ := d.Get("b"); !ok || string(v) != "2" {
+		t.Fatalf("b = %q %v after replay", v, ok)
+	}
+	if _, ok, _ := d.Get("old"); ok {
+		t.Fatalf("old should be deleted after replay")
+	}
+}
+
+func TestMultiGet(t *testing.T) {
+	d, err := Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+
+	// flushed 在 SST 里，fresh 在 MemTable 里，gone 被删除
+	if err := d.Put("flushed", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("gone", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("fresh", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete("gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{"fresh", "missing", "flushed", "gone", "fresh"}
+	vals, found, err := d.MultiGet(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2", "", "1", "", "2"}
+	for i := range keys {
+		if found[i] != (want[i] != "") || string(vals[i]) != want[i] {
+			t.Fatalf("%s: got %q %v, want %q", keys[i], vals[i], found[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"monolithdb/internal/vfs"
+	"monolithdb/internal/wal"
+)
+
+const (
+	// walArchiveDirName 下保存 Flush 时归档的 WAL 段，文件名是段内第一条记录的序号
+	walArchiveDirName = "wal"
+	// walSeqFileName 记录当前活跃 WAL 第一条记录的序号
+	walSeqFileName = "WALSEQ"
+)
+
+// ErrChangesUnavailable 表示请求的序号之后的记录已经不在保留的 WAL 段里。
+var ErrChangesUnavailable = errors.New("db: changes no longer retained")
+
+// ChangeOp 是一条变更的类型。
+type ChangeOp byte
+
+const (
+	ChangePut    ChangeOp = iota // 写入（ExpiresAt 非 0 表示带过期时间）
+	ChangeDelete                 // 删除
+	ChangeTouch                  // 只修改过期时间，Value 为空
+)
+
+func (op ChangeOp) String() string {
+	switch op {
+	case ChangePut:
+		return "put"
+	case ChangeDelete:
+		return "delete"
+	case ChangeTouch:
+		return "touch"
+	default:
+		return fmt.Sprintf("ChangeOp(%d)", byte(op))
+	}
+}
+
+// Change 是一条已提交的变更。
+//
+// 每条 WAL 记录占一个序号：同一个 Batch 或同一次 Touch 产生的多条 Change 序号相同。
+type Change struct {
+	Seq       uint64
+	Op        ChangeOp
+	Key       string
+	Value     []byte
+	ExpiresAt int64
+}
+
+// LastSequence 返回最后一条已提交记录的序号，没有任何写入时为 0。
+func (d *DB) LastSequence() uint64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.lastSeq
+}
+
+// Changes 按提交顺序返回序号大于 since 的所有变更，截止到调用时的 LastSequence()。
+// 想持续消费时，记下最后一条的 Seq 再次调用即可。
+//
+// 变更来自活跃 WAL 和 Options.WALRetentionSegments 保留的归档段；
+// since 之后的记录已被清理时，迭代器返回 ErrChangesUnavailable。OpenInMemory 打开的数据库没有 WAL，
+// 有新的记录时总是返回 ErrChangesUnavailable。
+func (d *DB) Changes(since uint64) iter.Seq2[Change, error] {
+	return func(yield func(Change, error) bool) {
+		files, last, err := d.openChangeFiles(since)
+		defer func() {
+			for _, sf := range files {
+				_ = sf.f.Close()
+			}
+		}()
+		if err != nil {
+			yield(Change{}, err)
+			return
+		}
+
+		for _, sf := range files {
+			seq := sf.first - 1
+			stopped := false
+			err := wal.ScanWithOptions(sf.f, d.opts.walOptions(), func(r wal.Record) bool {
+				seq++
+				if seq > last {
+					return false
+				}
+				if seq <= since {
+					return true
+				}
+				for _, c := range expandRecord(seq, r) {
+					if !yield(c, nil) {
+						stopped = true
+						return false
+					}
+				}
+				return true
+			})
+			if stopped {
+				return
+			}
+			// 活跃 WAL 末尾可能有正在写入的半条记录，只要已经读到 last 就不算错误
+			if err != nil && seq < last {
+				yield(Change{}, err)
+				return
+			}
+		}
+	}
+}
+
+type seqFile struct {
+	f     vfs.File
+	first uint64
+}
+
+// openChangeFiles 在读锁内打开所有可能包含 since 之后记录的 WAL 文件。
+// 先打开文件再释放锁，之后 Flush 归档 / 清理这些文件也不影响读取。
+func (d *DB) openChangeFiles(since uint64) (files []seqFile, last uint64, err error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	last = d.lastSeq
+	if since >= last {
+		return nil, last, nil
+	}
+	if d.opts.inMemory {
+		return nil, 0, fmt.Errorf("%w: in-memory database keeps no WAL", ErrChangesUnavailable)
+	}
+
+	segs, err := listWALSegments(d.opts.fs(), d.dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	// 不可变 MemTable 的 WAL 段排在归档段之后、活跃 WAL 之前
+	for _, imm := range d.imm {
+		segs = append(segs, walSegment{first: imm.first, path: imm.walPath})
+	}
+	oldest := d.walFirstSeq
+	if len(segs) > 0 {
+		oldest = segs[0].first
+	}
+	if since+1 < oldest {
+		return nil, 0, fmt.Errorf("%w: oldest retained sequence is %d, requested after %d", ErrChangesUnavailable, oldest, since)
+	}
+
+	// 活跃 WAL 也当作最后一个段
+	segs = append(segs, walSegment{first: d.walFirstSeq, path: d.walPath})
+	for i, s := range segs {
+		if i+1 < len(segs) && segs[i+1].first <= since+1 {
+			continue // 这一段全部 <= since
+		}
+		f, err := d.opts.fs().Open(s.path)
+		if err != nil {
+			return files, 0, err
+		}
+		files = append(files, seqFile{f: f, first: s.first})
+	}
+	return files, last, nil
+}
+
+// expandRecord 把一条 WAL 记录展开成若干 Change。
+func expandRecord(seq uint64, r wal.Record) []Change {
+	switch r.Op {
+	case wal.OpPut, wal.OpPutTTL:
+		return []Change{{Seq: seq, Op: ChangePut, Key: r.Key, Value: r.Value, ExpiresAt: r.ExpiresAt}}
+	case wal.OpDelete:
+		return []Change{{Seq: seq, Op: ChangeDelete, Key: r.Key}}
+	case wal.OpTouch:
+		out := make([]Change, len(r.Keys))
+		for i, k := range r.Keys {
+			out[i] = Change{Seq: seq, Op: ChangeTouch, Key: k, ExpiresAt: r.ExpiresAt}
+		}
+		return out
+	case wal.OpBatch:
+		var out []Change
+		for _, sub := range r.Batch {
+			out = append(out, expandRecord(seq, sub)...)
+		}
+		return out
+	}
+	return nil
+}
+
+type walSegment struct {
+	first uint64
+	path  string
+}
+
+// listWALSegments 返回归档的 WAL 段，按序号升序。
+func listWALSegments(fs vfs.FS, dir string) ([]walSegment, error) {
+	return listSegments(fs, filepath.Join(dir, walArchiveDirName))
+}
+
+// listSegments 返回 segDir 下以第一条记录的序号命名的 WAL 段，按序号升序。
+func listSegments(fs vfs.FS, segDir string) ([]walSegment, error) {
+	list, err := vfs.Glob(fs, segDir, "*.log")
+	if err != nil {
+		return nil, err
+	}
+	var segs []walSegment
+	for _, p := range list {
+		first, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(p), ".log"), 10, 64)
+		if err != nil {
+			continue
+		}
+		segs = append(segs, walSegment{first: first, path: p})
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i].first < segs[j].first })
+	return segs, nil
+}
+
+// loadWALFirstSeq 返回活跃 WAL 第一条记录的序号。
+//
+// 归档（或冻结进不可变队列）时先 rename 再写 WALSEQ，两步之间崩溃会让 WALSEQ 落后，
+// 所以还要用 wal/ 和 imm/ 下最新一段的结束位置校正一次。
+func loadWALFirstSeq(dir string, opts Options) (uint64, error) {
+	first := uint64(1)
+	b, err := vfs.ReadFile(opts.fs(), filepath.Join(dir, walSeqFileName))
+	switch {
+	case err == nil:
+		v, perr := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+		if perr != nil || v == 0 {
+			return 0, fmt.Errorf("db: malformed %s", walSeqFileName)
+		}
+		first = v
+	case !errors.Is(err, os.ErrNotExist):
+		return 0, err
+	}
+
+	segs, err := listWALSegments(opts.fs(), dir)
+	if err != nil {
+		return 0, err
+	}
+	imm, err := listImmWALs(opts.fs(), dir)
+	if err != nil {
+		return 0, err
+	}
+	segs = append(segs, imm...)
+	if len(segs) == 0 {
+		return first, nil
+	}
+	newest := segs[0]
+	for _, s := range segs {
+		if s.first > newest.first {
+			newest = s
+		}
+	}
+	records, _, err := wal.ReplayValidWithOptions(newest.path, opts.walOptions())
+	if err != nil {
+		return 0, err
+	}
+	if end := newest.first + uint64(len(records)); end > first {
+		first = end
+	}
+	return first, nil
+}
+
+// rotateWAL 在 Flush 时（持有写锁、WAL 已关闭）归档活跃 WAL 并开始新的序号段。
+func (d *DB) rotateWAL() error {
+	archiveDir := filepath.Join(d.dir, walArchiveDirName)
+
+	switch {
+	case d.opts.inMemory:
+		// 没有 WAL 文件，只开始新的序号段
+	case d.lastSeq >= d.walFirstSeq:
+		if err := d.opts.fs().MkdirAll(archiveDir, 0o755); err != nil {
+			return err
+		}
+		seg := filepath.Join(archiveDir, fmt.Sprintf("%020d.log", d.walFirstSeq))
+		if err := d.opts.fs().Rename(d.walPath, seg); err != nil {
+			return err
+		}
+	default:
+		if err := vfs.WriteFile(d.opts.fs(), d.walPath, nil, 0o644); err != nil {
+			return err
+		}
+	}
+
+	d.walFirstSeq = d.lastSeq + 1
+	return d.writeWALSeq()
+}
+
+// writeWALSeq 把 walFirstSeq 写入 WALSEQ。
+func (d *DB) writeWALSeq() error {
+	tmp := filepath.Join(d.dir, walSeqFileName+".tmp")
+	if err := vfs.WriteFile(d.opts.fs(), tmp, []byte(strconv.FormatUint(d.walFirstSeq, 10)+"\n"), 0o644); err != nil {
+		return err
+	}
+	return d.opts.fs().Rename(tmp, filepath.Join(d.dir, walSeqFileName))
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func collectChanges(t *testing.T, d *DB, since uint64) []string {
+	t.Helper()
+	var out []string
+	for c, err := range d.Changes(since) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, fmt.Sprintf("%d:%s:%s", c.Seq, c.Op, c.Key))
+	}
+	return out
+}
+
+func TestChangesAcrossFlushAndReopen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	opts := Options{WALRetentionSegments: 2}
+	d, err := OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(d.Put("a", []byte("1")))                   // 1
+	must(d.Delete("a"))                             // 2
+	must(d.Flush())                                 // 归档 [1, 2]
+	must(d.PutWithTTL("b", []byte("2"), time.Hour)) // 3
+	var b Batch
+	b.Put("c", nil)
+	b.Delete("b")
+	must(d.Write(&b))                                            // 4
+	if _, err := d.Touch([]string{"c"}, time.Hour); err != nil { // 5
+		t.Fatal(err)
+	}
+
+	want := "1:put:a 2:delete:a 3:put:b 4:put:c 4:delete:b 5:touch:c"
+	if got := strings.Join(collectChanges(t, d, 0), " "); got != want {
+		t.Fatalf("changes = %s\nwant      %s", got, want)
+	}
+	if got := strings.Join(collectChanges(t, d, 3), " "); got != "4:put:c 4:delete:b 5:touch:c" {
+		t.Fatalf("changes since 3 = %s", got)
+	}
+	must(d.Close())
+
+	// 重启后序号继续递增，历史变更仍然可读
+	d, err = OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+	if d.LastSequence() != 5 {
+		t.Fatalf("LastSequence after reopen = %d", d.LastSequence())
+	}
+	must(d.Put("d", []byte("4"))) // 6
+	if got := collectChanges(t, d, 5); len(got) != 1 || got[0] != "6:put:d" {
+		t.Fatalf("changes since 5 = %v", got)
+	}
+
+	// 提前 break 不会出错
+	for range d.Changes(0) {
+		break
+	}
+
+	// 超出保留的段之后，旧的变更不可用
+	must(d.Flush())
+	must(d.Put("e", nil))
+	must(d.Flush())
+	for _, err := range d.Changes(0) {
+		if !errors.Is(err, ErrChangesUnavailable) {
+			t.Fatalf("expected ErrChangesUnavailable, got %v", err)
+		}
+	}
+	if got := collectChanges(t, d, 2); len(got) != 6 || got[5] != "7:put:e" {
+		t.Fatalf("changes since 2 = %v", got)
+	}
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 是 DB 读取当前时间的来源：TTL 的过期时间、读取时的过期判断、
+// compaction 对过期记录的处理都用它。测试里可以换成 ManualClock，推进时间而不用 sleep。
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// timerClock 是还能按自己的时间安排定时的 Clock。Options.FlushInterval、ScrubInterval 这样的周期任务用它计时，
+// 只实现了 Now 的 Clock 按真实时间计时（time.After）。
+type timerClock interface {
+	Clock
+	After(d time.Duration) <-chan time.Time
+}
+
+// ManualClock 是只在调用 Set / Advance 时才前进的 Clock，并发安全。
+// 它也驱动 FlushInterval 和 ScrubInterval：时钟推进过一个周期时后台 Flush、巡检才会执行。
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []manualTimer
+	afters int // After 被调用的次数，测试用它确认后台任务已经开始等待下一个周期
+}
+
+type manualTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// NewManualClock 返回一个停在 t 的 ManualClock。
+func NewManualClock(t time.Time) *ManualClock {
+	return &ManualClock{now: t}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance 把时钟向前推进 d。
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.fire()
+}
+
+// Set 把时钟设置为 t。
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+	c.fire()
+}
+
+// After 与 time.After 相同，但按这个时钟计时：时钟被推进到 d 之后时，channel 收到当时的时间。
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.afters++
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, manualTimer{at: c.now.Add(d), c: ch})
+	c.fire()
+	return ch
+}
+
+// fire 触发所有已经到期的定时器，调用方持有 mu。
+func (c *ManualClock) fire() {
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+		} else {
+			t.c <- c.now
+		}
+	}
+	c.timers = pending
+}
+
+// afterCalls 返回 After 被调用的次数。
+func (c *ManualClock) afterCalls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.afters
+}
+
+// now 返回当前时间（unix 纳秒）。
+func (d *DB) now() int64 {
+	return d.opts.clock().Now().UnixNano()
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManualClockDrivesTTL(t *testing.T) {
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{Clock: clock, DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.PutWithTTL("a", []byte("1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	var b Batch
+	b.PutWithTTL("b", []byte("2"), 2*time.Minute)
+	if err := d.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(30 * time.Second)
+	if ttl, ok, _ := d.TTL("a"); !ok || ttl != 30*time.Second {
+		t.Fatalf("TTL(a) = %v %v", ttl, ok)
+	}
+	if ttl, ok, _ := d.TTL("b"); !ok || ttl != 90*time.Second {
+		t.Fatalf("TTL(b) = %v %v", ttl, ok)
+	}
+
+	// 时间只在 Advance 时前进：过期时刻之前可见，之后不可见（SST 里同样按注入的时钟判断）
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(30 * time.Second)
+	if _, ok, _ := d.Get("a"); ok {
+		t.Fatal("a should have expired")
+	}
+	if _, ok, _ := d.Get("b"); !ok {
+		t.Fatal("b should still be live")
+	}
+	if entries, _ := d.Range("", ""); len(entries) != 1 || entries[0].Key != "b" {
+		t.Fatalf("Range = %v", entries)
+	}
+
+	clock.Advance(time.Minute)
+	if _, ok, _ := d.Get("b"); ok {
+		t.Fatal("b should have expired")
+	}
+}
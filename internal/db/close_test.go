@@ -0,0 +1,78 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/wal"
+)
+
+func TestFlushOnClose(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{FlushOnClose: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = d.Put("a", []byte("1"))
+	_ = d.Delete("b")
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 干净关闭后 WAL 为空，数据都在 SST 里
+	st, err := os.Stat(filepath.Join(dir, walFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Size() != 0 {
+		t.Fatalf("wal size after close = %d", st.Size())
+	}
+
+	d, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if n := d.Stats().MemTableEntries; n != 0 {
+		t.Fatalf("memtable entries after reopen = %d", n)
+	}
+	if v, ok, _ := d.Get("a"); !ok || string(v) != "1" {
+		t.Fatalf("a = %q %v", v, ok)
+	}
+}
+
+func TestSyncWritesWAL(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.Put("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sync 之后记录已经写进文件，不再停留在 WAL 的缓冲区里
+	records, err := wal.Replay(filepath.Join(dir, walFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Key != "a" {
+		t.Fatalf("wal records after Sync = %+v", records)
+	}
+
+	ro, err := OpenWithOptions(dir, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if err := ro.Sync(); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("read-only Sync: %v", err)
+	}
+}
@@ -0,0 +1,351 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"monolithdb/internal/sstable"
+	"monolithdb/internal/types"
+)
+
+// FilterDecision 是 CompactionFilter 对一条记录的处理结果。
+type FilterDecision int
+
+const (
+	FilterKeep    FilterDecision = iota // 原样保留
+	FilterDrop                          // 丢弃（不写 tombstone，直接从输出里去掉）
+	FilterReplace                       // 用返回的新 value 替换
+)
+
+// CompactionFilter 在 compaction 重写记录时被调用，用于应用层的数据清理（按业务规则过期、降采样等）。
+//
+// 同一次 compaction 内按 key 升序对每条 live 记录调用一次；tombstone 和已过期的记录不会传给它。
+// 实现可以在一次 compaction 内保存状态（例如“同一时间桶只保留第一条”），
+// 所以 Options 里配置的是工厂函数，每次 compaction 新建一个实例。
+type CompactionFilter interface {
+	Filter(key string, value []byte) (decision FilterDecision, newValue []byte)
+}
+
+// CompactionFilterFunc 把普通函数适配成 CompactionFilter，适合不需要状态的 filter，
+// 例如按 value 里的业务时间戳丢弃超过保留期的记录。
+type CompactionFilterFunc func(key string, value []byte) (FilterDecision, []byte)
+
+func (f CompactionFilterFunc) Filter(key string, value []byte) (FilterDecision, []byte) {
+	return f(key, value)
+}
+
+// SetCompactionFilter 注册之后每次 compaction 使用的 filter，优先于 Options.CompactionFilterFactory；
+// nil 表示取消注册，恢复使用 Options 的配置。正在进行的 compaction 不受影响。
+//
+// 同一个 filter 会被之后的每次 compaction 复用，需要在一次 compaction 内保存状态时
+// 使用 Options.CompactionFilterFactory。
+func (d *DB) SetCompactionFilter(f CompactionFilter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.compactionFilter = f
+}
+
+// Compact 把所有 SST 合并成一张表（数据量大时按 Options.MaxSubcompactions 切成几张 key 范围不相交的表），
+// 同一个 key 只保留最新版本。
+// 注册了 filter（SetCompactionFilter / Options.CompactionFilterFactory）时，对每条 live 记录调用 filter。
+//
+// 目前是全量、同步的 compaction：整个过程持有写锁，合并结果全部放在内存里。
+// 合并了所有表，tombstone 不再遮住任何旧版本，会被丢弃；已过期的记录原样保留。
+func (d *DB) Compact() error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.compactTables(d.versions.current().tables)
+}
+
+// compactTables 把 inputs 合并成一张表（开启 sub-compaction 时是几张 key 范围互不重叠的表）。
+// inputs 必须是 SST 列表开头连续的一段（newest first），
+// 输出占用新的、更大的文件编号，所以重启后按编号排序依然在所有未参与合并的表之前。
+// 调用方持有写锁。
+func (d *DB) compactTables(inputs []string) error {
+	if len(inputs) == 0 {
+		return nil
+	}
+	defer d.latency.observe(latCompaction, d.latency.start())
+	// 没有合并到最旧的表时，更旧的表里可能还有同一个 key 的旧版本
+	bottommost := len(inputs) == len(d.versions.current().tables)
+
+	var inputBytes int64
+	for _, p := range inputs {
+		st, err := d.opts.fs().Stat(p)
+		if err != nil {
+			return err
+		}
+		inputBytes += st.Size()
+	}
+
+	out, bounds, err := d.readCompactionInputs(inputs, inputBytes)
+	if err != nil {
+		return err
+	}
+
+	// tombstone 只用来遮住更旧的版本：合并到最旧的表时已经没有更旧的版本，可以直接丢掉。
+	// Range / RangeChunks 每次都在锁内读当前版本，Snapshot 读的是它固定住的输入表，都不受影响
+	var dropped uint64
+	if bottommost {
+		live := out[:0]
+		for _, e := range out {
+			if e.Tombstone {
+				dropped++
+				continue
+			}
+			live = append(live, e)
+		}
+		out = live
+	}
+
+	out, err = d.applyCompactionFilter(out, bottommost)
+	if err != nil {
+		return err
+	}
+	// 值日志：filter 改写出来的大 value 分离出去；合并到最旧一层时才知道哪些引用已经失效，
+	// 失效比例达到 ValueLogGCRatio 的文件把有效的 value 搬到新文件，旧文件在安装新表之后删除
+	var rewrite map[uint64]bool
+	if bottommost {
+		if rewrite, err = d.valueLogGCFiles(out); err != nil {
+			return err
+		}
+	}
+	if err := d.moveValues(out, rewrite); err != nil {
+		return err
+	}
+
+	var outputs []string
+	var outputBytes int64
+	if parts := d.splitEntries(out, bounds); len(parts) > 0 {
+		// 编号大的排在前面，和重启后按编号排序的结果一致；各段的 key 范围互不重叠，顺序不影响读取
+		outputs = make([]string, len(parts))
+		for i := len(parts) - 1; i >= 0; i-- {
+			outputs[i] = filepath.Join(d.sstDir, fmt.Sprintf("%06d.sst", d.versions.newFileNumber()))
+		}
+		names := make([]string, len(inputs))
+		for i, p := range inputs {
+			names[i] = filepath.Base(p)
+		}
+		opts := sstable.WriterOptions{
+			Properties:  sstable.Properties{CreationReason: sstable.ReasonCompaction, InputFiles: names},
+			NoSync:      d.opts.DisableFsync,
+			BlockSize:   d.opts.blockSize(),
+			Comparer:    d.cmp,
+			RateLimiter: d.opts.rateLimiter(),
+			Compression: d.opts.Compression,
+			Encryption:  d.opts.Encryption,
+			FS:          d.opts.FS,
+		}
+		opts = d.opts.withFilter(opts)
+		if bottommost && d.opts.CompressionDictBytes > 0 {
+			opts.CompressionDict = sstable.TrainDictionary(sampleValues(out, dictSampleRatio*d.opts.CompressionDictBytes), d.opts.CompressionDictBytes)
+		}
+		if err := d.writeCompactionOutputs(parts, outputs, opts); err != nil {
+			return err
+		}
+		for _, p := range outputs {
+			st, err := d.opts.fs().Stat(p)
+			if err != nil {
+				return err
+			}
+			outputBytes += st.Size()
+		}
+	}
+
+	// 先切换到新表再删除旧表（没有读取在使用的输入立即删除，其余的等读取结束，见 removeObsolete）：
+	// 删到一半崩溃时，重启会同时看到新旧表。
+	// 新表的 properties 记录了输入文件，Open 时会删掉残留的输入（见 dropCompactedInputs），
+	// 否则被丢弃的 tombstone 遮住的旧版本会重新可见
+	d.versions.apply(versionEdit{added: outputs, deleted: inputs})
+	d.backlogChanged()
+	d.metrics.compactions.Add(1)
+	d.metrics.compactionWritten.Add(uint64(outputBytes))
+	d.metrics.tombstonesDropped.Add(dropped)
+	if inputBytes > outputBytes {
+		d.metrics.compactionReclaimed.Add(uint64(inputBytes - outputBytes))
+	}
+	if d.readCache != nil {
+		d.readCache.Purge()
+	}
+	if err := d.versions.removeObsolete(); err != nil {
+		return err
+	}
+	if err := d.removeObsoleteValueLogs(); err != nil {
+		return err
+	}
+	return d.saveTableAccess()
+}
+
+// readCompactionInputs 归并 inputs，每个 key 取最新的版本，两边都有序号时按序号（见 searchTables）；
+// tombstone 留给调用方处理。输入足够大时按 key 范围切成几段并行归并（见 Options.MaxSubcompactions），
+// bounds 是段之间的分界。
+//
+// 输入表用单独打开的 Reader 读取，不经过 versionSet 的缓存：它们马上就会被删除，
+// 读取的字节数也单独统计（AmplificationStats.CompactionBytesRead），不算作用户读取。
+// 调用方持有写锁，在此期间输入表不会被删除。
+func (d *DB) readCompactionInputs(inputs []string, inputBytes int64) (out []types.Entry, bounds []string, err error) {
+	ro := d.sstReadOptions(ReadOptions{})
+	ro.BytesRead = &d.metrics.compactionRead
+	readers := make([]*sstable.Reader, len(inputs))
+	for i, p := range inputs {
+		r, err := sstable.OpenReader(p, ro)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer r.Close()
+		readers[i] = r
+	}
+	if bounds, err = d.subcompactionBounds(readers, inputBytes); err != nil {
+		return nil, nil, err
+	}
+	if out, err = d.mergeInputs(readers, bounds); err != nil {
+		return nil, nil, err
+	}
+	return out, bounds, nil
+}
+
+// dictSampleRatio 是训练字典时样本总量与字典大小之比。
+const dictSampleRatio = 100
+
+// sampleValues 从 entries 中等间隔抽取 value，总字节数大约不超过 maxBytes。
+func sampleValues(entries []types.Entry, maxBytes int) [][]byte {
+	var total int
+	for _, e := range entries {
+		total += len(e.Value)
+	}
+	stride := 1
+	if total > maxBytes {
+		stride = (total + maxBytes - 1) / maxBytes
+	}
+	var samples [][]byte
+	for i := 0; i < len(entries); i += stride {
+		// 值日志引用不写进 value 区，不参与训练
+		if len(entries[i].Value) > 0 && !entries[i].ValueRef {
+			samples = append(samples, entries[i].Value)
+		}
+	}
+	return samples
+}
+
+// applyCompactionFilter 对合并后的有序记录应用 compaction filter。
+// bottommost 为 false 时，被丢弃的记录改写成 tombstone，否则更旧的表里的版本会重新可见。
+// 在值日志里的 value 先读出来再交给 filter，被改写的 value 不再引用值日志。
+func (d *DB) applyCompactionFilter(entries []types.Entry, bottommost bool) ([]types.Entry, error) {
+	f := d.compactionFilter
+	if f == nil && d.opts.CompactionFilterFactory != nil {
+		f = d.opts.CompactionFilterFactory()
+	}
+	if f == nil {
+		return entries, nil
+	}
+
+	now := d.now()
+	out := entries[:0]
+	for _, e := range entries {
+		if e.Tombstone || expired(e, now) {
+			out = append(out, e)
+			continue
+		}
+		value := e.Value
+		if e.ValueRef {
+			r, err := d.versions.resolve(e)
+			if err != nil {
+				return nil, err
+			}
+			value = r.Value
+		}
+		// memtable 里有更新的版本时，filter 的结果对读取不可见，但依然要按 filter 处理
+		switch decision, v := f.Filter(e.Key, value); decision {
+		case FilterDrop:
+			d.metrics.filterDropped.Add(1)
+			if d.evict != nil && !d.inMemTable(e.Key) {
+				d.evict.removed(e.Key)
+			}
+			if !bottommost {
+				out = append(out, types.Entry{Key: e.Key, Tombstone: true})
+			}
+			continue
+		case FilterReplace:
+			d.metrics.filterReplaced.Add(1)
+			e.Value, e.ValueRef = v, false
+			if d.evict != nil && !d.inMemTable(e.Key) {
+				d.evict.added(e.Key, len(v))
+			}
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+func (d *DB) inMemTable(key string) bool {
+	_, ok := memGet(d.mem, d.imm, key, true)
+	return ok
+}
+
+// dropCompactedInputs 去掉已经被 compaction 输出取代、但在删除前崩溃而残留的输入表。
+// 有多个输出的 compaction（见 Options.MaxSubcompactions）只有全部输出都在时才取代输入；
+// 输出没有全部 rename 完就崩溃时（还有输入在，输出却不全），去掉已经存在的那部分输出，输入依然有效。
+// 输入都已经删除时缺少的输出是被之后的 compaction 合并掉了，不算不完整。
+// 非只读时同时删除这些文件。
+func dropCompactedInputs(tables []string, opts Options) ([]string, error) {
+	present := make(map[string]bool, len(tables))
+	for _, p := range tables {
+		present[p] = true
+	}
+	obsolete := make(map[string]bool)
+	for _, p := range tables {
+		// 读不了 properties 的表留给读取时报错 / Repair 处理，这里不阻止打开
+		props, err := sstable.ReadPropertiesWithOptions(p, sstable.ReadOptions{Keys: opts.Encryption, FS: opts.FS})
+		if err != nil || props.CreationReason != sstable.ReasonCompaction {
+			continue
+		}
+		if len(props.OutputFiles) > 0 && !allPresent(present, p, props.OutputFiles) && anyPresent(present, p, props.InputFiles) {
+			obsolete[p] = true
+			continue
+		}
+		for _, name := range props.InputFiles {
+			obsolete[filepath.Join(filepath.Dir(p), name)] = true
+		}
+	}
+	if len(obsolete) == 0 {
+		return tables, nil
+	}
+
+	live := tables[:0]
+	for _, p := range tables {
+		if !obsolete[p] {
+			live = append(live, p)
+			continue
+		}
+		if !opts.ReadOnly {
+			if err := opts.fs().Remove(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return live, nil
+}
+
+// allPresent / anyPresent 报告和 table 同目录的 names 是否全部 / 至少一个在 present 中。
+func allPresent(present map[string]bool, table string, names []string) bool {
+	for _, name := range names {
+		if !present[filepath.Join(filepath.Dir(table), name)] {
+			return false
+		}
+	}
+	return true
+}
+
+func anyPresent(present map[string]bool, table string, names []string) bool {
+	for _, name := range names {
+		if present[filepath.Join(filepath.Dir(table), name)] {
+			return true
+		}
+	}
+	return false
+}
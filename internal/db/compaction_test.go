@@ -0,0 +1,282 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"monolithdb/internal/sstable"
+)
+
+type prefixFilter struct{ calls []string }
+
+func (f *prefixFilter) Filter(key string, value []byte) (FilterDecision, []byte) {
+	f.calls = append(f.calls, key)
+	switch {
+	case strings.HasPrefix(key, "drop/"):
+		return FilterDrop, nil
+	case strings.HasPrefix(key, "up/"):
+		return FilterReplace, []byte(strings.ToUpper(string(value)))
+	}
+	return FilterKeep, nil
+}
+
+func TestCompactMergesTablesAndAppliesFilter(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	var last *prefixFilter
+	opts := Options{
+		DisableFsync: true,
+		CompactionFilterFactory: func() CompactionFilter {
+			last = &prefixFilter{}
+			return last
+		},
+	}
+	d, err := OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mustPut := func(k, v string) {
+		t.Helper()
+		if err := d.Put(k, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustPut("a", "old")
+	mustPut("gone", "x")
+	mustPut("drop/1", "x")
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	mustPut("a", "new")
+	mustPut("up/1", "abc")
+	if err := d.Delete("gone"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if tables := d.versions.current().tables; len(tables) != 1 {
+		t.Fatalf("sstables = %v", tables)
+	}
+	// tombstone 不传给 filter，调用顺序是 key 升序
+	if got := strings.Join(last.calls, ","); got != "a,drop/1,up/1" {
+		t.Fatalf("filter calls = %s", got)
+	}
+
+	check := func() {
+		t.Helper()
+		want := map[string]string{"a": "new", "up/1": "ABC"}
+		for k, v := range want {
+			if got, ok, err := d.Get(k); err != nil || !ok || string(got) != v {
+				t.Fatalf("Get(%s) = %q %v %v, want %q", k, got, ok, err, v)
+			}
+		}
+		for _, k := range []string{"gone", "drop/1"} {
+			if _, ok, err := d.Get(k); err != nil || ok {
+				t.Fatalf("Get(%s) ok = %v err = %v", k, ok, err)
+			}
+		}
+	}
+	check()
+
+	// 重启后只剩合并后的那张表
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	d, err = OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if tables := d.versions.current().tables; len(tables) != 1 {
+		t.Fatalf("sstables after reopen = %v", tables)
+	}
+	check()
+}
+
+func TestCompactDropsTombstones(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if err := d.Put(fmt.Sprintf("k%03d", i), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 90; i++ {
+		if err := d.Delete(fmt.Sprintf("k%03d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// 最旧的输入表里 k000 还是 live 的
+	oldest := d.versions.current().tables[1]
+	oldestData, err := os.ReadFile(oldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	st := d.Stats().Compaction
+	if st.Compactions != 1 || st.TombstonesDropped != 90 || st.ReclaimedBytes == 0 {
+		t.Fatalf("compaction stats = %+v", st)
+	}
+	out := d.versions.current().tables[0]
+	var n int
+	for e, err := range sstable.RangeIter(out, "", "", sstable.ReadOptions{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Tombstone {
+			t.Fatalf("tombstone %s left in output", e.Key)
+		}
+		n++
+	}
+	if n != 10 {
+		t.Fatalf("output has %d records", n)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟删除输入表之前崩溃：残留的旧表在 Open 时被删除，已删除的 key 不会复活
+	if err := os.WriteFile(oldest, oldestData, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, ok, _ := d.Get("k000"); ok {
+		t.Fatalf("deleted key resurrected from leftover compaction input")
+	}
+	if _, err := os.Stat(oldest); !os.IsNotExist(err) {
+		t.Fatalf("leftover input not removed: %v", err)
+	}
+}
+
+func TestSetCompactionFilterRetention(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// value 是业务写入时间（天）；保留期之前的记录在 compaction 时删除
+	for i, day := range []string{"100", "205", "180", "300"} {
+		if err := d.Put(fmt.Sprintf("event/%d", i), []byte(day)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	d.SetCompactionFilter(CompactionFilterFunc(func(key string, value []byte) (FilterDecision, []byte) {
+		if string(value) < "200" {
+			return FilterDrop, nil
+		}
+		return FilterKeep, nil
+	}))
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := d.Range("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Key != "event/1" || entries[1].Key != "event/3" {
+		t.Fatalf("entries after retention = %v", entries)
+	}
+	if st := d.Stats().Compaction; st.FilterDropped != 2 {
+		t.Fatalf("compaction stats = %+v", st)
+	}
+
+	// 取消注册后不再过滤
+	d.SetCompactionFilter(nil)
+	if err := d.Put("event/4", []byte("001")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := d.Get("event/4"); !ok {
+		t.Fatalf("event/4 dropped without a filter")
+	}
+}
+
+func TestBottommostCompactionTrainsDictionary(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	opts := Options{DisableFsync: true, CompressionDictBytes: 4 << 10}
+	if _, err := OpenWithOptions(filepath.Join(t.TempDir(), "bad"), Options{Compression: "no-such", CompressionDictBytes: 1}); err == nil {
+		t.Fatal("expected dictionary with unknown codec to be rejected")
+	}
+	d, err := OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := func(i int) []byte {
+		return fmt.Appendf(nil, `{"id":%d,"status":"active","region":"eu-west-1","tier":"gold"}`, i)
+	}
+	for round := 0; round < 2; round++ {
+		for i := round; i < 400; i += 2 {
+			if err := d.Put(fmt.Sprintf("k%04d", i), value(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := d.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	tables := d.versions.current().tables
+	if len(tables) != 1 {
+		t.Fatalf("expected 1 table, got %d", len(tables))
+	}
+	p, err := sstable.ReadProperties(tables[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.CompressionDict) == 0 || len(p.CompressionDict) > 4<<10 {
+		t.Fatalf("dictionary size = %d", len(p.CompressionDict))
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if v, ok, err := d.Get("k0123"); err != nil || !ok || string(v) != string(value(123)) {
+		t.Fatalf("Get(k0123) = %q, %v, %v", v, ok, err)
+	}
+	if entries, err := d.Range("", ""); err != nil || len(entries) != 400 {
+		t.Fatalf("Range = %d entries, %v", len(entries), err)
+	}
+}
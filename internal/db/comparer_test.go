@@ -0,0 +1,126 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// reverseComparer 按字节序的逆序排列 key。
+type reverseComparer struct{}
+
+func (reverseComparer) Name() string                 { return "test.ReverseComparator" }
+func (reverseComparer) Compare(a, b string) int      { return strings.Compare(b, a) }
+func (reverseComparer) Separator(a, b string) string { return b }
+func (reverseComparer) Successor(a string) string    { return a }
+
+func TestCustomComparer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	opts := Options{Comparer: reverseComparer{}, DisableFsync: true}
+	d, err := OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 足够多的 key，让 SST 有多个索引块
+	const n = 500
+	for i := 0; i < n; i++ {
+		if err := d.Put(fmt.Sprintf("k%04d", i), []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+		if i == n/2 {
+			if err := d.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	_ = d.Delete("k0100")
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func() {
+		t.Helper()
+		for _, i := range []int{0, 7, 249, 250, 251, 499} {
+			k := fmt.Sprintf("k%04d", i)
+			if v, ok, err := d.Get(k); err != nil || !ok || string(v) != fmt.Sprint(i) {
+				t.Fatalf("Get(%s) = %q %v %v", k, v, ok, err)
+			}
+		}
+		if _, ok, _ := d.Get("k0100"); ok {
+			t.Fatal("deleted key visible")
+		}
+
+		// 逆序下 [k0499, k0489) 是 k0499 ... k0490
+		entries, err := d.Range("k0499", "k0489")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 10 || entries[0].Key != "k0499" || entries[9].Key != "k0490" {
+			t.Fatalf("Range = %v", entries)
+		}
+	}
+	check()
+
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	check()
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 比较器名称记录在 manifest 里，换成默认比较器打开会失败
+	if _, err := OpenWithOptions(dir, Options{}); !errors.Is(err, ErrIncompatibleOptions) {
+		t.Fatalf("err = %v, want ErrIncompatibleOptions", err)
+	}
+	d, err = OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	check()
+}
+
+func TestCustomComparerRangeAllUnflushed(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{Comparer: reverseComparer{}, DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, k := range []string{"a", "c"} {
+		if err := d.Put(k, []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// b、d 只在 MemTable 里。逆序下 "" 排在所有 key 后面，start 为空仍然要从第一条开始
+	for _, k := range []string{"b", "d"} {
+		if err := d.Put(k, []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := d.Range("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Key)
+	}
+	if strings.Join(got, ",") != "d,c,b,a" {
+		t.Fatalf("Range(\"\", \"\") = %v", got)
+	}
+
+	if _, err := d.DeletePrefix(""); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := d.Range("", ""); err != nil || len(entries) != 0 {
+		t.Fatalf("after DeletePrefix(\"\"): %v, %v", entries, err)
+	}
+}
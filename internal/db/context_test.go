@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPutContextDeadlineDuringWriteStop(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, L0StopTables: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, k := range []string{"a", "c"} {
+		if err := d.Put(k, []byte("1")); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := d.PutContext(ctx, "b", []byte("2")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("PutContext = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("PutContext returned after %v", elapsed)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := d.DeleteContext(ctx, "a"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("DeleteContext = %v, want Canceled", err)
+	}
+
+	// 被取消的写入什么也没写
+	if _, ok, err := d.Get("b"); err != nil || ok {
+		t.Fatalf("Get(b) = %v %v", ok, err)
+	}
+	if _, ok, err := d.Get("a"); err != nil || !ok {
+		t.Fatalf("Get(a) = %v %v", ok, err)
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.PutContext(context.Background(), "b", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetContextCanceled(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Put("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if v, ok, err := d.GetContext(ctx, "a"); err != nil || !ok || string(v) != "1" {
+		t.Fatalf("GetContext = %q %v %v", v, ok, err)
+	}
+	cancel()
+	if _, _, err := d.GetContext(ctx, "a"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetContext after cancel = %v", err)
+	}
+}
+
+func TestRangeChunksContextCanceledMidScan(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for i := range 100 {
+		if err := d.Put(fmt.Sprintf("k%03d", i), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	chunks := 0
+	var got error
+	for _, err := range d.RangeChunksContext(ctx, "", "", 40) {
+		if err != nil {
+			got = err
+			break
+		}
+		chunks++
+		cancel()
+	}
+	if chunks != 1 || !errors.Is(got, context.Canceled) {
+		t.Fatalf("chunks = %d, err = %v", chunks, got)
+	}
+
+	if _, err := d.RangeContext(ctx, "", ""); !errors.Is(err, context.Canceled) {
+		t.Fatalf("RangeContext = %v", err)
+	}
+	entries, err := d.RangeContext(context.Background(), "k010", "k020")
+	if err != nil || len(entries) != 10 {
+		t.Fatalf("RangeContext = %d entries, %v", len(entries), err)
+	}
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	// ErrNotCounter 表示 Increment 的 key 已经有一个不是计数器编码（8 字节）的值。
+	ErrNotCounter = errors.New("db: value is not a counter")
+
+	// ErrCounterOverflow 表示 Increment 的结果超出了 int64 的范围，计数器保持原值。
+	ErrCounterOverflow = errors.New("db: counter overflow")
+)
+
+// counterSize 是计数器值的字节数。
+const counterSize = 8
+
+// EncodeCounter 返回计数器 n 的值编码：8 字节大端的 int64（补码）。
+// 可以用它初始化一个计数器，或者在 Batch / 事务里写入计数器。
+func EncodeCounter(n int64) []byte {
+	return binary.BigEndian.AppendUint64(make([]byte, 0, counterSize), uint64(n))
+}
+
+// DecodeCounter 解码 EncodeCounter 编码的值，长度不是 8 字节时返回 ErrNotCounter。
+func DecodeCounter(v []byte) (int64, error) {
+	if len(v) != counterSize {
+		return 0, fmt.Errorf("%w: %d bytes", ErrNotCounter, len(v))
+	}
+	return int64(binary.BigEndian.Uint64(v)), nil
+}
+
+// Increment 原子地把计数器 key 加上 delta（可以为负）并返回新值；key 不存在（包括已删除和已过期）时从 0 开始。
+// 值按 EncodeCounter 编码，读取用 DecodeCounter。与 GetSet 一样，读取旧值和写入新值在同一次写锁内完成，
+// 并发的 Increment 不会丢失更新。结果超出 int64 时返回 ErrCounterOverflow，不写入。
+// 写入的是普通的 Put，会清除 key 原来的过期时间。
+func (d *DB) Increment(key string, delta int64) (int64, error) {
+	var n int64
+	err := d.Update(func(tx *UpdateTx) error {
+		v, ok, err := tx.Get(key)
+		if err != nil {
+			return err
+		}
+		var cur int64
+		if ok {
+			if cur, err = DecodeCounter(v); err != nil {
+				return err
+			}
+		}
+		if (delta > 0 && cur > math.MaxInt64-delta) || (delta < 0 && cur < math.MinInt64-delta) {
+			return fmt.Errorf("%w: %d%+d", ErrCounterOverflow, cur, delta)
+		}
+		n = cur + delta
+		return tx.Put(key, EncodeCounter(n))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestIncrement(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if n, err := d.Increment("c", 5); err != nil || n != 5 {
+		t.Fatalf("first Increment = %d, %v", n, err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := d.Increment("c", -7); err != nil || n != -2 {
+		t.Fatalf("Increment over sst = %d, %v", n, err)
+	}
+	v, _, err := d.Get("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := DecodeCounter(v); err != nil || n != -2 {
+		t.Fatalf("DecodeCounter = %d, %v", n, err)
+	}
+
+	const workers, rounds = 8, 200
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				if _, err := d.Increment("hits", 1); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n, err := d.Increment("hits", 0); err != nil || n != workers*rounds {
+		t.Fatalf("hits = %d, %v", n, err)
+	}
+
+	if err := d.Put("text", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Increment("text", 1); !errors.Is(err, ErrNotCounter) {
+		t.Fatalf("non-counter value: %v", err)
+	}
+	if err := d.Put("max", EncodeCounter(math.MaxInt64)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Increment("max", 1); !errors.Is(err, ErrCounterOverflow) {
+		t.Fatalf("overflow: %v", err)
+	}
+	if n, err := d.Increment("max", -1); err != nil || n != math.MaxInt64-1 {
+		t.Fatalf("after overflow = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExportCSV(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true, Clock: NewManualClock(time.Unix(1000, 0))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for k, v := range map[string]string{"a": `{"n":1}`, "b": "x,y\nz", "z": "out of range"} {
+		if err := d.Put(k, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.PutWithTTL("t", []byte("ttl"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if n, err := d.ExportCSV(&out, "", "u", CSVOptions{}); err != nil || n != 3 {
+		t.Fatalf("ExportCSV = %d, %v", n, err)
+	}
+	want := "key,value,expires_at\na,\"{\"\"n\"\":1}\",\nb,\"x,y\nz\",\nt,ttl,1970-01-01T01:16:40Z\n"
+	if out.String() != want {
+		t.Fatalf("text export:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if n, err := d.ExportCSV(&out, "a", "b", CSVOptions{ValueEncoding: CSVHex, NoHeader: true}); err != nil || n != 1 {
+		t.Fatalf("ExportCSV(hex) = %d, %v", n, err)
+	}
+	if out.String() != "a,7b226e223a317d,\n" {
+		t.Fatalf("hex export = %q", out.String())
+	}
+
+	if err := d.Put("bin", []byte{0xff, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ExportCSV(&bytes.Buffer{}, "bin", "bio", CSVOptions{}); err == nil {
+		t.Fatal("text export of binary value succeeded")
+	}
+	out.Reset()
+	if _, err := d.ExportCSV(&out, "bin", "bio", CSVOptions{ValueEncoding: CSVBase64, NoHeader: true}); err != nil || out.String() != "bin,/wA=,\n" {
+		t.Fatalf("base64 export = %q, %v", out.String(), err)
+	}
+
+	if _, err := ParseCSVValueEncoding("rot13"); err == nil {
+		t.Fatal("ParseCSVValueEncoding accepted an unknown encoding")
+	}
+}
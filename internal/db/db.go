@@ -1,19 +1,37 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"monolithdb/internal/cache"
+	"monolithdb/internal/manifest"
 	"monolithdb/internal/memtable"
 	"monolithdb/internal/sstable"
+	"monolithdb/internal/types"
+	"monolithdb/internal/vfs"
 	"monolithdb/internal/wal"
 )
 
+const (
+	walFileName = "forge.wal"
+	sstDirName  = "sst"
+)
+
+// ErrReadOnly 表示在只读模式下调用了写操作。
+var ErrReadOnly = errors.New("db: read-only")
+
 type DB struct {
+	// mu 保护下面所有可变状态：写操作 / Flush 持写锁，读操作持读锁
+	mu sync.RWMutex
+
 	mem *memtable.MemTable
 	wal *wal.WAL
 
@@ -21,174 +39,763 @@ type DB struct {
 	walPath string
 	sstDir  string
 
-	sstables []string
-	nextID   uint64
+	// versions 管理 SST 列表（newest first）和文件编号
+	versions *versionSet
+
+	// cmp 是 key 的比较器（Options.Comparer）
+	cmp types.Comparer
+
+	opts Options
+
+	// readCache 为 nil 表示未启用读缓存
+	readCache *cache.LRU
+
+	// evict 为 nil 表示未开启有界模式
+	evict *evictor
+
+	// commitHook 见 SetCommitHook
+	commitHook func(wal.Record)
+
+	// watchers 是 Watch 注册的监听者
+	watchers map[*watcher]struct{}
+
+	// compactionFilter 见 SetCompactionFilter
+	compactionFilter CompactionFilter
+
+	// hints 见 SetCompactionHint
+	hints map[string]CompactionHint
+
+	// locks 是悲观事务的 key 锁，有自己的互斥锁
+	locks *lockManager
+
+	// metrics 见 Counters
+	metrics engineMetrics
+
+	// latency 为 nil 表示没有开启延迟统计（见 Options.LatencyHistograms）
+	latency *latencyRecorder
+
+	// ignoreFilters 见 SetIgnoreFilters
+	ignoreFilters atomic.Bool
+
+	// walFirstSeq 是活跃 WAL 第一条记录的序号，lastSeq 是最后一条已提交记录的序号
+	walFirstSeq uint64
+	lastSeq     uint64
+
+	// seqTimes 为 nil 表示没有配置 Options.WALArchiveDir（见 seqTimeLog）
+	seqTimes *seqTimeLog
+
+	// stallCond 绑定 mu，因为写停顿、不可变 MemTable 队列已满而等待的写入，
+	// 以及等待后台写入结束的 Flush 在上面等待（见 throttleWrite、makeRoomForWrite）
+	stallCond *sync.Cond
+	closed    bool
+
+	// recovery 见 Recovery
+	recovery RecoveryReport
+
+	// vlogGCStop / vlogGCDone 控制后台值日志 GC（见 Options.ValueLogGCInterval），未开启时为 nil
+	vlogGCStop chan struct{}
+	vlogGCDone chan struct{}
+
+	// scrub 是后台巡检（见 Options.ScrubInterval），未开启时为 nil
+	scrub *scrubber
+
+	// flushStop / flushDone 控制定时 Flush（见 Options.FlushInterval），未开启时为 nil
+	flushStop chan struct{}
+	flushDone chan struct{}
+
+	// imm 是等待写成 SST 的不可变 MemTable（oldest first，见 Options.MaxImmutableMemtables）。
+	// immFlushing 表示后台正在锁外写 imm[0]，immErr 是后台最近一次写入的错误；都由 mu 保护。
+	// immKick / immStop / immDone 控制后台写入的 goroutine，未开启时为 nil
+	imm         []*immMemTable
+	immFlushing bool
+	immErr      error
+	immKick     chan struct{}
+	immStop     chan struct{}
+	immDone     chan struct{}
 }
 
+// Open 使用默认配置打开（或创建）dir 下的数据库。
 func Open(dir string) (*DB, error) {
-	if err := os.MkdirAll(dir, 0o755); err != nil {
+	return OpenWithOptions(dir, Options{})
+}
+
+// OpenWithOptions 使用指定配置打开（或创建）dir 下的数据库。
+// 若 dir 已存在且配置与 manifest 记录的不兼容，返回 ErrIncompatibleOptions。
+func OpenWithOptions(dir string, opts Options) (*DB, error) {
+	if opts.ReadOnly {
+		// 只读模式不创建任何文件，目录必须已存在
+		if _, err := opts.fs().Stat(dir); err != nil {
+			return nil, err
+		}
+	} else if err := opts.fs().MkdirAll(dir, 0o755); err != nil {
 		return nil, err
 	}
 
-	sstDir := filepath.Join(dir, "sst")
-	if err := os.MkdirAll(sstDir, 0o755); err != nil {
+	if err := checkManifest(dir, opts); err != nil {
 		return nil, err
 	}
+	if opts.Compression != "" {
+		if _, ok := sstable.LookupCodec(opts.Compression); !ok {
+			return nil, fmt.Errorf("%w: unknown compression %q", ErrInvalidOptions, opts.Compression)
+		}
+	}
+	if opts.ParanoidChecks && opts.TolerateCorruptWALTail {
+		return nil, fmt.Errorf("%w: ParanoidChecks and TolerateCorruptWALTail are mutually exclusive", ErrInvalidOptions)
+	}
+	if err := opts.validateSizeLimits(); err != nil {
+		return nil, err
+	}
+	if opts.ValueLogThreshold > 0 && opts.Encryption != nil {
+		return nil, fmt.Errorf("%w: value log does not support encryption", ErrInvalidOptions)
+	}
+	if opts.FilterPolicy != "" {
+		if _, ok := sstable.LookupFilterPolicy(opts.FilterPolicy); !ok {
+			return nil, fmt.Errorf("%w: unknown filter policy %q", ErrInvalidOptions, opts.FilterPolicy)
+		}
+	}
+	if err := sstable.ValidateBloomOptions(opts.withFilter(sstable.WriterOptions{})); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+	if opts.CompressionDictBytes > sstable.MaxDictSize ||
+		(opts.CompressionDictBytes > 0 && opts.Compression != "" && opts.Compression != "flate") {
+		return nil, fmt.Errorf("%w: CompressionDictBytes requires flate and at most %d bytes", ErrInvalidOptions, sstable.MaxDictSize)
+	}
+
+	sstDir := filepath.Join(dir, sstDirName)
+	if !opts.ReadOnly {
+		if err := opts.fs().MkdirAll(sstDir, 0o755); err != nil {
+			return nil, err
+		}
+	}
 
-	walPath := filepath.Join(dir, "forge.wal")
+	walPath := filepath.Join(dir, walFileName)
+
+	if !opts.ReadOnly {
+		if err := removeOrphanFiles(opts.fs(), sstDir); err != nil {
+			return nil, err
+		}
+	}
+	sstables, nextID, err := scanSSTables(opts.fs(), sstDir)
+	if err != nil {
+		return nil, err
+	}
+	// 值日志文件与 SST 共用编号
+	vlogDir := filepath.Join(dir, vlogDirName)
+	vlogs, err := listValueLogs(opts.fs(), vlogDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(vlogs) > 0 {
+		nextID = max(nextID, vlogs[len(vlogs)-1]+1)
+	}
+	// 编号最大的文件可能已经被删除，以 MANIFEST 里记录的为准
+	mf, err := manifest.LoadFS(opts.fs(), filepath.Join(dir, manifest.FileName))
+	if err != nil {
+		return nil, err
+	}
+	if mf != nil {
+		nextID = max(nextID, mf.NextFileNumber)
+	}
+	if sstables, err = dropCompactedInputs(sstables, opts); err != nil {
+		return nil, err
+	}
+	if opts.ParanoidChecks {
+		if err := paranoidCheck(dir, sstables, opts); err != nil {
+			return nil, err
+		}
+	}
 
-	w, err := wal.Open(walPath)
+	walFirstSeq, err := loadWALFirstSeq(dir, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	m := memtable.NewMemTable()
+	// 回放 WAL：把操作重新应用到 MemTable，第 i 条记录的序号是 walFirstSeq+i
+	// （Touch 记录需要读取旧值，所以要先扫描 SST）
+	cmp := opts.comparer()
+	m := memtable.NewMemTableWithComparer(cmp)
+	versions := newVersionSet(sstables, nextID)
+	versions.vlog = newValueLog(opts.fs(), vlogDir)
+	versions.fs = opts.fs()
+	if !opts.ReadOnly {
+		versions.manifest, versions.manifestPath = mf, filepath.Join(dir, manifest.FileName)
+	}
+	if opts.TableStatsSampleRate > 0 {
+		versions.access = newTableAccess(opts.TableStatsSampleRate)
+		versions.access.load(opts.fs(), dir, sstables)
+	}
+	replayStart := time.Now()
+	ro := sstable.ReadOptions{IgnoreBloom: opts.IgnoreFilters, Comparer: cmp, Keys: opts.Encryption, VerifyChecksums: opts.ParanoidChecks, FS: opts.FS}
 
-	// 回放 WAL：把操作重新应用到 MemTable
-	records, err := wal.Replay(walPath)
+	// 先按顺序重建等待写成 SST 的不可变 MemTable（见 Options.MaxImmutableMemtables）
+	immSegs, err := listImmWALs(opts.fs(), dir)
 	if err != nil {
-		_ = w.Close()
 		return nil, err
 	}
-	for _, r := range records {
-		switch r.Op {
-		case 0:
-			m.Put(r.Key, r.Value)
-		case 1:
-			m.Delete(r.Key)
-		default:
-			_ = w.Close()
-			return nil, wal.ErrCorruptWAL
+	var imm []*immMemTable
+	var immRecords int
+	var immChanged bool
+	if len(immSegs) > 0 {
+		var flushedSeq uint64
+		for _, p := range sstables {
+			flushedSeq = max(flushedSeq, versions.maxSeq(p, ro))
+		}
+		imm, immRecords, immChanged, err = replayImmWALs(immSegs, flushedSeq, opts, func(m *memtable.MemTable, prev []*immMemTable, seq uint64, r wal.Record) error {
+			return applyRecord(m, prev, versions, r, seq, ro)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !opts.ReadOnly {
+			if err := archiveFlushedImmWALs(opts.fs(), dir, immSegs, imm); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	sstables, nextID, err := scanSSTables(sstDir)
+	records, recovery, err := readWAL(dir, walPath, opts)
 	if err != nil {
-		_ = w.Close()
 		return nil, err
 	}
+	replayChanged, err := replayWAL(opts, records, func(i int, r wal.Record) error {
+		return applyRecord(m, imm, versions, r, walFirstSeq+uint64(i), ro)
+	})
+	if err != nil {
+		return nil, err
+	}
+	recovery.WALRecords += immRecords
+	recovery.FilterChanged = replayChanged || immChanged
+	recovery.Duration = time.Since(replayStart)
+
+	// 回放完成后再打开 WAL 准备追加写；只读模式不打开
+	var w *wal.WAL
+	if !opts.ReadOnly {
+		w, err = openWAL(walPath, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	return &DB{
+	d := &DB{
 		mem:      m,
+		imm:      imm,
 		wal:      w,
 		dir:      dir,
 		walPath:  walPath,
 		sstDir:   sstDir,
-		sstables: sstables,
-		nextID:   nextID,
-	}, nil
+		versions: versions,
+		cmp:      cmp,
+		opts:     opts,
+		locks:    newLockManager(),
+
+		walFirstSeq: walFirstSeq,
+		lastSeq:     walFirstSeq - 1 + uint64(len(records)),
+		recovery:    recovery,
+	}
+	d.stallCond = sync.NewCond(&d.mu)
+	d.ignoreFilters.Store(opts.IgnoreFilters)
+	if opts.ReadCacheBytes > 0 {
+		d.readCache = cache.NewLRU(opts.ReadCacheBytes)
+	}
+	if opts.LatencyHistograms {
+		d.latency = &latencyRecorder{}
+	}
+	if opts.WALArchiveDir != "" && !opts.ReadOnly {
+		if d.seqTimes, err = openSeqTimeLog(opts.fs(), opts.WALArchiveDir); err != nil {
+			_ = d.wal.Close()
+			return nil, err
+		}
+	}
+	if replayChanged && !opts.ReadOnly {
+		if err := d.persistReplay(); err != nil {
+			_ = d.wal.Close()
+			return nil, err
+		}
+	}
+	if !opts.ReadOnly {
+		// 上次在安装引用它的 SST 之前崩溃留下的值日志文件
+		if err := d.removeObsoleteValueLogs(); err != nil {
+			_ = d.wal.Close()
+			return nil, err
+		}
+	}
+	if opts.bounded() {
+		if err := d.startEviction(); err != nil {
+			_ = d.wal.Close()
+			return nil, err
+		}
+	}
+	if opts.ValueLogGCInterval > 0 && !opts.ReadOnly {
+		d.startValueLogGC(opts.ValueLogGCInterval)
+	}
+	if opts.FlushInterval > 0 && !opts.ReadOnly {
+		d.startAutoFlush(opts.FlushInterval)
+	}
+	if opts.MaxImmutableMemtables > 0 && !opts.ReadOnly {
+		d.startImmFlusher()
+	}
+	if opts.ScrubInterval > 0 {
+		d.startScrubber()
+	}
+	reportRecovery(opts, recovery)
+	return d, nil
 }
 
-func (d *DB) Close() error {
-	if d.wal != nil {
-		return d.wal.Close()
+// startEviction 用当前所有 live key 初始化淘汰状态并启动后台淘汰。
+// 重启后无法恢复之前的访问顺序，初始顺序按 key 排序。
+func (d *DB) startEviction() error {
+	d.evict = newEvictor(d.opts)
+	go d.evict.run(d)
+	for chunk, err := range d.RangeChunks("", "", 0) {
+		if err != nil {
+			return err
+		}
+		for _, e := range chunk {
+			d.evict.added(e.Key, len(e.Value))
+		}
 	}
 	return nil
 }
 
+// Close 关闭数据库。WAL 会先 fsync（Options.DisableFsync 时除外），
+// 开启 Options.FlushOnClose 时还会先把 MemTable 刷成 SST，下次打开不需要回放 WAL。
+func (d *DB) Close() error {
+	// 唤醒因为写停顿而等待的写入，让它们返回 ErrClosed
+	d.mu.Lock()
+	d.closed = true
+	d.stallCond.Broadcast()
+	d.mu.Unlock()
+
+	// 先停掉后台淘汰（它会调用 Delete）、值日志 GC、定时 Flush、不可变 MemTable 的写入和巡检，再关闭 WAL
+	if d.evict != nil {
+		d.evict.close()
+	}
+	d.stopValueLogGC()
+	d.stopScrubber()
+	d.stopAutoFlush()
+	d.stopImmFlusher()
+
+	var err error
+	if d.opts.FlushOnClose && !d.opts.ReadOnly {
+		err = d.Flush()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closeWatchers()
+	if serr := d.saveTableAccess(); err == nil {
+		err = serr
+	}
+	if !d.opts.ReadOnly {
+		if rerr := d.versions.removeObsolete(); err == nil {
+			err = rerr
+		}
+	}
+	d.versions.close()
+	d.versions.vlog.close()
+	if d.seqTimes != nil {
+		_ = d.seqTimes.close()
+	}
+	if d.wal == nil {
+		return err
+	}
+	if !d.opts.DisableFsync {
+		if serr := d.wal.Sync(); err == nil {
+			err = serr
+		}
+	}
+	if cerr := d.wal.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Sync 把已提交的写入（WAL）以及数据目录的元数据 fsync 到磁盘。
+// 写入默认只保证进入 WAL 的缓冲区，不逐条 fsync；应用可以在自己的提交点调用 Sync，
+// Sync 返回后，之前返回成功的写入在掉电后都不会丢。Sync 不受 DisableFsync 影响。
+func (d *DB) Sync() error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	// 读锁足够：写操作持写锁，Sync 期间不会有新的追加，也不会切换 WAL
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if err := d.wal.Sync(); err != nil {
+		return err
+	}
+	// WAL 切换、Flush 会在目录里新建 / rename 文件，目录项也要持久化
+	if err := syncDir(d.opts.fs(), d.dir); err != nil {
+		return err
+	}
+	return syncDir(d.opts.fs(), d.sstDir)
+}
+
+// Put 使用 context.Background() 写入 key，见 PutContext。
 func (d *DB) Put(key string, value []byte) error {
+	return d.PutContext(context.Background(), key, value)
+}
+
+// PutContext 写入 key。ctx 只约束写入之前的等待（写停顿）：ctx 在写 WAL 之前被取消或超时时返回 ctx.Err()，
+// 什么也不写；一旦开始写 WAL 就不再检查 ctx。
+func (d *DB) PutContext(ctx context.Context, key string, value []byte) error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	defer d.latency.observe(latPut, d.latency.start())
+	op := WriteOp{Key: d.normKey(key), Value: value}
+	if err := d.interceptWrite(&op); err != nil {
+		return err
+	}
+	if err := d.checkSize(op.Key, op.Value); err != nil {
+		return err
+	}
+	if op.TTL > 0 {
+		return d.putTTL(ctx, op.Key, op.Value, op.TTL)
+	}
+	return d.put(ctx, op.Key, op.Value)
+}
+
+// put 是 Put 的实现，key 已经规范化并经过了 WriteInterceptors。
+func (d *DB) put(ctx context.Context, key string, value []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.throttleWrite(ctx); err != nil {
+		return err
+	}
+
 	// 先写 WAL（Write-Ahead）
 	if err := d.wal.AppendPut(key, value); err != nil {
 		return err
 	}
 	// 再写 MemTable
-	d.mem.Put(key, value)
+	d.mem.PutEntry(types.Entry{Key: key, Value: value, Seq: d.lastSeq + 1})
+	d.invalidateCache(key)
+	if d.evict != nil {
+		d.evict.added(key, len(value))
+	}
+	d.commit(wal.Record{Op: wal.OpPut, Key: key, Value: value})
 	return nil
 }
 
+// Get 使用默认 ReadOptions 读取 key。
 func (d *DB) Get(key string) ([]byte, bool, error) {
-	// 1) MemTable
-	if e, ok := d.mem.GetAll(key); ok {
-		if e.Tombstone {
+	return d.GetWithOptions(key, ReadOptions{})
+}
+
+// GetContext 使用默认 ReadOptions 读取 key，见 GetWithOptionsContext。
+func (d *DB) GetContext(ctx context.Context, key string) ([]byte, bool, error) {
+	return d.GetWithOptionsContext(ctx, key, ReadOptions{})
+}
+
+// GetWithOptions 按 ro 读取 key。
+func (d *DB) GetWithOptions(key string, ro ReadOptions) ([]byte, bool, error) {
+	return d.GetWithOptionsContext(context.Background(), key, ro)
+}
+
+// GetWithOptionsContext 按 ro 读取 key。ctx 已经取消时直接返回 ctx.Err()；
+// 查找过程中每读一张 SST 之前检查一次 ctx。
+func (d *DB) GetWithOptionsContext(ctx context.Context, key string, ro ReadOptions) ([]byte, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	defer d.latency.observe(latGet, d.latency.start())
+	key = d.normKey(key)
+	sro := d.sstReadOptions(ro)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	v, ok, err := d.getLocked(ctx, key, sro, d.now(), false)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	if v, err = d.interceptRead(key, v); err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+// MultiGet 读取多个 key，values[i] / found[i] 对应 keys[i]。
+// 所有 key 在同一次读锁内读取，结果是同一时刻的一致视图；任一 key 读取出错时返回该错误。
+func (d *DB) MultiGet(keys []string) (values [][]byte, found []bool, err error) {
+	keys = d.normKeys(keys)
+	sro := d.sstReadOptions(ReadOptions{})
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	now := d.now()
+	values = make([][]byte, len(keys))
+	found = make([]bool, len(keys))
+	for i, k := range keys {
+		if values[i], found[i], err = d.getLocked(context.Background(), k, sro, now, false); err != nil {
+			return nil, nil, err
+		}
+		if found[i] {
+			if values[i], err = d.interceptRead(k, values[i]); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return values, found, nil
+}
+
+// getLocked 是 Get 的实现，调用方持有读锁。
+// noCopy 为 true 时 MemTable 和读缓存里的值不做拷贝直接返回（见 GetPinned），调用方不能修改。
+func (d *DB) getLocked(ctx context.Context, key string, sro sstable.ReadOptions, now int64, noCopy bool) ([]byte, bool, error) {
+	d.metrics.logicalReads.Add(1)
+	// 1) MemTable，然后是不可变 MemTable
+	if e, ok := memGet(d.mem, d.imm, key, noCopy); ok {
+		if e.Tombstone || expired(e, now) {
 			return nil, false, nil
 		}
+		d.touchEvict(key)
 		return e.Value, true, nil
 	}
 
-	// 2) SSTables (newest -> oldest)
-	for _, p := range d.sstables {
-		v, res, err := sstable.Get(p, key)
-		if err != nil {
-			return nil, false, err
+	// 2) 读缓存（只保存 SST 里读到的值）
+	if d.readCache != nil {
+		cacheGet := d.readCache.Get
+		if noCopy {
+			cacheGet = d.readCache.GetNoCopy
 		}
-		switch res {
-		case sstable.Found:
+		if v, ok := cacheGet(key); ok {
+			d.touchEvict(key)
 			return v, true, nil
-		case sstable.Deleted:
-			return nil, false, err // 关键：删除短路，阻止旧值“复活”
-		case sstable.NotFound:
-			continue
 		}
 	}
 
-	return nil, false, nil
+	// 3) SSTables：取序号最大的版本（见 searchTables）
+	e, res, err := d.versions.searchTables(ctx, key, sro)
+	if err != nil {
+		return nil, false, err
+	}
+	// 删除、过期都不能继续去更旧的表里找，否则旧值会“复活”
+	if res != sstable.Found || expired(e, now) {
+		return nil, false, nil
+	}
+	// 带过期时间的值不进缓存，避免缓存里的值活得比 TTL 更久
+	if d.readCache != nil && e.ExpiresAt == 0 {
+		d.readCache.Add(key, e.Value)
+	}
+	d.touchEvict(key)
+	return e.Value, true, nil
 }
 
+// Delete 使用 context.Background() 删除 key，见 DeleteContext。
 func (d *DB) Delete(key string) error {
+	return d.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext 删除 key。与 PutContext 一样，ctx 只约束写 WAL 之前的等待。
+func (d *DB) DeleteContext(ctx context.Context, key string) error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	defer d.latency.observe(latDelete, d.latency.start())
+	key = d.normKey(key)
+	if err := d.interceptWrite(&WriteOp{Key: key, Delete: true}); err != nil {
+		return err
+	}
+	if err := d.checkSize(key, nil); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.throttleWrite(ctx); err != nil {
+		return err
+	}
+
 	// 先写 WAL
 	if err := d.wal.AppendDelete(key); err != nil {
 		return err
 	}
 	// 再写 MemTable（tombstone）
-	d.mem.Delete(key)
+	d.mem.PutEntry(types.Entry{Key: key, Tombstone: true, Seq: d.lastSeq + 1})
+	d.invalidateCache(key)
+	if d.evict != nil {
+		d.evict.removed(key)
+	}
+	d.commit(wal.Record{Op: wal.OpDelete, Key: key})
 	return nil
 }
 
 func (d *DB) Flush() error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.flushLocked()
+}
+
+// flushLocked 是 Flush 的实现，调用方持有写锁。
+// 先按从旧到新的顺序写出所有不可变 MemTable（后台正在写的那个要等它写完，等待时会释放锁），再写当前的 MemTable。
+func (d *DB) flushLocked() error {
+	for d.immFlushing {
+		d.stallCond.Wait()
+	}
+	for len(d.imm) > 0 {
+		if err := d.flushImmLocked(); err != nil {
+			return err
+		}
+	}
+
 	entries := d.mem.RangeAll("", "")
 	if len(entries) == 0 {
 		return nil
 	}
+	defer d.latency.observe(latFlush, d.latency.start())
 
 	// 生成新 SSTable 文件名
-	name := fmt.Sprintf("%06d.sst", d.nextID)
+	name := fmt.Sprintf("%06d.sst", d.versions.newFileNumber())
 	path := filepath.Join(d.sstDir, name)
+	if err := d.writeFlushTable(path, entries, d.lastSeq); err != nil {
+		return err
+	}
+	if err := d.installFlushTable(path+".tmp", path); err != nil {
+		return err
+	}
+
+	// 清空 MemTable
+	d.mem = memtable.NewMemTableWithComparer(d.cmp)
+	d.backlogChanged()
+
+	// 换一个新的 WAL：否则重启 Replay 会重复应用旧操作
+	if err := d.switchWAL(); err != nil {
+		return err
+	}
+	return d.saveTableAccess()
+}
+
+// writeFlushTable 把 MemTable 的内容 entries 写到 path + ".tmp"，大 value 先写进值日志。
+// 记录带着各自的提交序号；maxSeq 是这个 MemTable 覆盖到的最后一个序号（当前 MemTable 为 d.lastSeq，
+// 不可变 MemTable 为它的 WAL 段的最后一条），作为表的 MaxSeq 写进 properties（不小于记录里的最大序号）。
+// 只读取 d 的配置、分配文件编号，后台写不可变 MemTable 时不持锁调用。
+func (d *DB) writeFlushTable(path string, entries []types.Entry, maxSeq uint64) error {
+	// 大 value 先写进值日志：值日志文件持久化之后才写引用它的表
+	if err := d.separateValues(entries); err != nil {
+		return err
+	}
 
 	// 先写到临时文件，再 rename，避免写一半崩溃留下半成品
 	tmp := path + ".tmp"
-	if err := sstable.WriteTable(tmp, entries); err != nil {
-		_ = os.Remove(tmp)
+	opts := sstable.WriterOptions{
+		Properties:  sstable.Properties{CreationReason: sstable.ReasonFlush, MaxSeq: maxSeq},
+		NoSync:      d.opts.DisableFsync,
+		BlockSize:   d.opts.blockSize(),
+		Comparer:    d.cmp,
+		RateLimiter: d.opts.rateLimiter(),
+		Compression: d.opts.Compression,
+		Encryption:  d.opts.Encryption,
+		FS:          d.opts.FS,
+	}
+	opts = d.opts.withFilter(opts)
+	if err := sstable.WriteTableWithOptions(tmp, entries, opts); err != nil {
+		_ = d.opts.fs().Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// installFlushTable 把 writeFlushTable 写好的临时文件 rename 成 path，持久化之后放到 SST 列表最前面。
+// 调用方持有写锁。
+func (d *DB) installFlushTable(tmp, path string) error {
+	if err := d.opts.fs().Rename(tmp, path); err != nil {
+		_ = d.opts.fs().Remove(tmp)
 		return err
 	}
-	if err := os.Rename(tmp, path); err != nil {
-		_ = os.Remove(tmp)
+	// rename 只有在目录 fsync 之后才持久化：在这之前截断 WAL，掉电后表和 WAL 可能一起丢失。
+	// 失败时保留 MemTable 和 WAL，删掉新表，下次 Flush 重新写
+	if err := d.syncSSTDir(); err != nil {
+		_ = d.opts.fs().Remove(path)
 		return err
 	}
 
 	// 把新表放到列表最前面
-	d.sstables = append([]string{path}, d.sstables...)
-	d.nextID++
+	d.versions.apply(versionEdit{added: []string{path}})
+	d.metrics.flushes.Add(1)
+	if st, err := d.opts.fs().Stat(path); err == nil {
+		d.metrics.bytesFlushed.Add(uint64(st.Size()))
+	}
+	return nil
+}
 
-	// 清空 MemTable
-	d.mem = memtable.NewMemTable()
+// syncSSTDir fsync sst/ 目录，让刚 rename 进来的表持久化；DisableFsync 时跳过。
+func (d *DB) syncSSTDir() error {
+	if d.opts.DisableFsync {
+		return nil
+	}
+	return syncDir(d.opts.fs(), d.sstDir)
+}
 
-	// 截断 WAL：否则重启 Replay 会重复应用旧操作
+// switchWAL 关闭当前 WAL 并换一个空的。
+// 旧 WAL 归档到 wal/ 下供 Changes 读取（按 WALRetentionSegments 清理）。
+func (d *DB) switchWAL() error {
 	if err := d.wal.Close(); err != nil {
 		return err
 	}
-	// 直接把 wal 文件清空
-	if err := os.WriteFile(d.walPath, nil, 0o644); err != nil {
+	if err := d.rotateWAL(); err != nil {
 		return err
 	}
-	w, err := wal.Open(d.walPath)
+	w, err := openWAL(d.walPath, d.opts)
 	if err != nil {
 		return err
 	}
 	d.wal = w
+	d.metrics.walTruncations.Add(1)
+	// 新 WAL 打开之后再处理旧段：归档失败不会让数据库没有可写的 WAL
+	return d.pruneWALSegments()
+}
 
-	return nil
+// openWAL 打开 path 准备追加写。内存模式（见 OpenInMemory）不写 WAL，返回丢弃所有记录的 wal.Discard。
+func openWAL(path string, opts Options) (*wal.WAL, error) {
+	if opts.inMemory {
+		return wal.Discard(), nil
+	}
+	return wal.OpenWithOptions(path, opts.walOptions())
+}
+
+// invalidateCache 在 key 被修改后让读缓存中的旧值失效。
+// 否则 Flush 清空 MemTable 后，Get 会从缓存读到修改前的值。
+func (d *DB) invalidateCache(key string) {
+	if d.readCache != nil {
+		d.readCache.Remove(key)
+	}
+}
+
+// touchEvict 在读命中后更新淘汰顺序。
+func (d *DB) touchEvict(key string) {
+	if d.evict != nil {
+		d.evict.accessed(key)
+	}
 }
 
-func scanSSTables(sstDir string) (paths []string, nextID uint64, err error) {
-	// 匹配这个目录下所有以 .sst 结尾的文件名
-	glob := filepath.Join(sstDir, "*.sst")
-	list, err := filepath.Glob(glob)
+// ReadCacheStats 返回读缓存的统计信息；未启用时返回零值。
+func (d *DB) ReadCacheStats() cache.Stats {
+	if d.readCache == nil {
+		return cache.Stats{}
+	}
+	return d.readCache.Stats()
+}
+
+// syncDir fsync 目录本身，让其中新建、rename、删除的目录项持久化。
+func syncDir(fs vfs.FS, dir string) error {
+	return fs.SyncDir(dir)
+}
+
+func scanSSTables(fs vfs.FS, sstDir string) (paths []string, nextID uint64, err error) {
+	// 匹配这个目录下所有以 .sst 结尾的文件名（已按名字排序）
+	list, err := vfs.Glob(fs, sstDir, "*.sst")
 	if err != nil {
 		return nil, 1, err
 	}
 
-	sort.Strings(list)
-
 	var maxID uint64 = 0
 	for _, p := range list {
 		id, ok := parseSSTID(p)
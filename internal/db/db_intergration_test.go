@@ -145,3 +145,181 @@ func TestDBDeleteOverridesSST(t *testing.T) {
 		t.Fatalf("expected mem tombstone to override SST value, got ok=%v v=%v", ok, v)
 	}
 }
+
+func TestDBReadCacheInvalidation(t *testing.T) {
+	dir := t.TempDir()
+	dbDir := filepath.Join(dir, "data")
+
+	d, err := OpenWithOptions(dbDir, Options{ReadCacheBytes: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+
+	if err := d.Put("k", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一次从 SST 读，第二次应命中缓存
+	for i := 0; i < 2; i++ {
+		v, ok, err := d.Get("k")
+		if err != nil || !ok || !bytes.Equal(v, []byte("v1")) {
+			t.Fatalf("expected k=v1, got v=%q ok=%v err=%v", v, ok, err)
+		}
+	}
+	if st := d.ReadCacheStats(); st.Hits != 1 || st.Entries != 1 {
+		t.Fatalf("expected one cache hit, got %+v", st)
+	}
+
+	// 覆盖写并 flush：MemTable 被清空后不能读到缓存里的旧值
+	if err := d.Put("k", []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	v, ok, err := d.Get("k")
+	if err != nil || !ok || !bytes.Equal(v, []byte("v2")) {
+		t.Fatalf("expected k=v2 after overwrite, got v=%q ok=%v err=%v", v, ok, err)
+	}
+
+	// 删除同理
+	if err := d.Delete("k"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := d.Get("k"); err != nil || ok {
+		t.Fatalf("expected k deleted, got v=%q ok=%v err=%v", v, ok, err)
+	}
+}
+
+func TestDBRangeMergesMemAndSST(t *testing.T) {
+	dir := t.TempDir()
+	dbDir := filepath.Join(dir, "data")
+
+	d, err := Open(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+
+	for _, k := range []string{"a", "b", "c", "d"} {
+		if err := d.Put(k, []byte("old-"+k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// mem 中覆盖 b、删除 c、新增 bb
+	if err := d.Put("b", []byte("new-b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete("c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("bb", []byte("new-bb")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := d.Range("b", "d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ k, v string }{{"b", "new-b"}, {"bb", "new-bb"}}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Key != w.k || string(got[i].Value) != w.v {
+			t.Fatalf("entry %d: expected %s=%s, got %s=%s", i, w.k, w.v, got[i].Key, got[i].Value)
+		}
+	}
+}
+
+func TestDBReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	dbDir := filepath.Join(dir, "data")
+
+	// 目录不存在时只读打开必须失败，且不能创建目录
+	if _, err := OpenWithOptions(dbDir, Options{ReadOnly: true}); err == nil {
+		t.Fatalf("expected read-only open of missing dir to fail")
+	}
+
+	d, err := Open(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("k", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	_ = d.Close()
+
+	ro, err := OpenWithOptions(dbDir, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ro.Close() }()
+
+	v, ok, err := ro.Get("k")
+	if err != nil || !ok || !bytes.Equal(v, []byte("v")) {
+		t.Fatalf("expected k=v from WAL replay, got v=%q ok=%v err=%v", v, ok, err)
+	}
+	if err := ro.Put("x", []byte("y")); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	if err := ro.Flush(); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly from Flush, got %v", err)
+	}
+}
+
+// 空 value 与 nil value 必须在 MemTable / WAL 回放 / SST 中都保持区分
+func TestDBEmptyValueDistinctFromNil(t *testing.T) {
+	dir := t.TempDir()
+	dbDir := filepath.Join(dir, "data")
+
+	check := func(d *DB, stage string) {
+		t.Helper()
+		v, ok, err := d.Get("empty")
+		if err != nil || !ok || v == nil || len(v) != 0 {
+			t.Fatalf("%s: expected empty non-nil value, got v=%#v ok=%v err=%v", stage, v, ok, err)
+		}
+		v, ok, err = d.Get("nil")
+		if err != nil || !ok || v != nil {
+			t.Fatalf("%s: expected nil value, got v=%#v ok=%v err=%v", stage, v, ok, err)
+		}
+	}
+
+	d, err := Open(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("empty", []byte{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("nil", nil); err != nil {
+		t.Fatal(err)
+	}
+	check(d, "memtable")
+	_ = d.Close()
+
+	// WAL 回放
+	d, err = Open(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(d, "wal replay")
+
+	// SST
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	check(d, "sstable")
+	_ = d.Close()
+}
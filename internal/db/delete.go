@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"strings"
+
+	"monolithdb/internal/types"
+)
+
+// DeleteKeys 删除 keys 中的所有 key：整批写成一条 WAL 记录，原子地提交。
+// 与 Write 一样只写到操作系统缓存，不 fsync，需要持久化时之后调用 Sync。
+// 不存在的 key 也会写一个 tombstone；keys 为空时直接返回 nil。
+func (d *DB) DeleteKeys(keys []string) error {
+	var b Batch
+	for _, k := range keys {
+		b.Delete(k)
+	}
+	return d.Write(&b)
+}
+
+// DeletePrefix 删除所有以 prefix 开头的 key（prefix 为空时删除全部），返回删除的 key 数。
+//
+// 整个删除在一次写锁内完成：先只取 key 扫描出要删除的 key（见 ReadOptions.KeysOnly），
+// 再把它们的 tombstone 写成一个批次（一条 WAL 记录），原子地生效，扫描和写入之间不会插入其他写操作。
+// 与 Write 一样不 fsync，需要持久化时之后调用 Sync。
+//
+// ForgeDB 还没有范围 tombstone，所以每个要删除的 key 仍然各写一个点 tombstone：所有 key 同时放在内存里，
+// 批次的大小随 key 数增长，扫描期间其他写入都被阻塞。删除非常多的 key 时应当按更长的前缀分几次删除。
+// 使用默认的字节序比较器时只扫描这个前缀的范围，否则扫描全部 key。
+func (d *DB) DeletePrefix(prefix string) (int, error) {
+	if d.opts.ReadOnly {
+		return 0, ErrReadOnly
+	}
+	prefix = d.normKey(prefix)
+	start, end := "", ""
+	if d.cmp.Name() == types.BytewiseComparer.Name() {
+		start, end = narrowToPrefix("", "", prefix)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ctx := context.Background()
+	if err := d.throttleWrite(ctx); err != nil {
+		return 0, err
+	}
+
+	var b Batch
+	for e, err := range d.mergeRangeWithOptions(ctx, start, end, d.now(), ReadOptions{KeysOnly: true}) {
+		if err != nil {
+			return 0, err
+		}
+		if strings.HasPrefix(e.Key, prefix) {
+			b.Delete(e.Key)
+		}
+	}
+	if err := d.writeLocked(&b); err != nil {
+		return 0, err
+	}
+	return b.Len(), nil
+}
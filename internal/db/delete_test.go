@@ -0,0 +1,125 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeleteKeys(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		if err := d.Put(k, []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.DeleteKeys([]string{"a", "c", "missing"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DeleteKeys(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重放 WAL 之后删除仍然生效
+	d, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	got, err := d.Range("", "")
+	if err != nil || len(got) != 1 || got[0].Key != "b" {
+		t.Fatalf("after DeleteKeys: %v %v", got, err)
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// user/ 一半在 SST、一半在 MemTable；相邻的前缀不受影响
+	for i := 0; i < 3000; i++ {
+		if i == 1500 {
+			if err := d.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := d.Put(fmt.Sprintf("user/%04d", i), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, k := range []string{"user", "user.", "user0", "usera", "a"} {
+		if err := d.Put(k, []byte("keep")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Delete("user/0007"); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := d.DeletePrefix("user/")
+	if err != nil || n != 2999 {
+		t.Fatalf("DeletePrefix = %d, %v", n, err)
+	}
+	got, err := d.Range("", "")
+	if err != nil || len(got) != 5 {
+		t.Fatalf("after DeletePrefix: %v %v", got, err)
+	}
+	if n, err := d.DeletePrefix("user/"); err != nil || n != 0 {
+		t.Fatalf("second DeletePrefix = %d, %v", n, err)
+	}
+
+	if n, err := d.DeletePrefix(""); err != nil || n != 5 {
+		t.Fatalf("DeletePrefix(\"\") = %d, %v", n, err)
+	}
+	if got, err := d.Range("", ""); err != nil || len(got) != 0 {
+		t.Fatalf("after deleting everything: %v %v", got, err)
+	}
+}
+
+func TestDeletePrefixIsAtomic(t *testing.T) {
+	errReject := errors.New("reject")
+	opts := Options{
+		DisableFsync: true,
+		WriteInterceptors: []WriteInterceptor{WriteInterceptorFunc(func(op *WriteOp) error {
+			if op.Delete && strings.HasPrefix(op.Key, "user/9000/") {
+				return errReject
+			}
+			return nil
+		})},
+	}
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// key 的总字节数足够大，按批次删除的话会分成好几批，被拒绝的 key 在最后一批里
+	const n = 10000
+	pad := strings.Repeat("x", 40)
+	for i := 0; i < n; i++ {
+		if err := d.Put(fmt.Sprintf("user/%04d/%s", i, pad), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 其中一个 tombstone 被拒绝：整个删除都不生效
+	if _, err := d.DeletePrefix("user/"); !errors.Is(err, errReject) {
+		t.Fatalf("DeletePrefix = %v, want errReject", err)
+	}
+	got, err := d.Range("user/", "user0")
+	if err != nil || len(got) != n {
+		t.Fatalf("after failed DeletePrefix: %d keys, %v", len(got), err)
+	}
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffDirectories(t *testing.T) {
+	root := t.TempDir()
+	oldDir, newDir := filepath.Join(root, "old"), filepath.Join(root, "new")
+
+	write := func(dir string, fn func(d *DB)) {
+		t.Helper()
+		d, err := OpenWithOptions(dir, Options{DisableFsync: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn(d)
+		if err := d.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put := func(d *DB, k, v string) {
+		t.Helper()
+		if err := d.Put(k, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(oldDir, func(d *DB) {
+		put(d, "same", "1")
+		put(d, "changed", "a")
+		put(d, "removed", "x")
+		put(d, "deleted-later", "x")
+	})
+	// 新目录里的同一份数据分布在多张 SST 和 WAL 中，比较的是可见结果
+	write(newDir, func(d *DB) {
+		put(d, "same", "0")
+		put(d, "changed", "a")
+		put(d, "deleted-later", "x")
+		if err := d.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		put(d, "same", "1")
+		put(d, "added", "y")
+		if err := d.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		put(d, "changed", "b")
+		if err := d.Delete("deleted-later"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	var got []string
+	for de, err := range Diff(oldDir, newDir) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s:%s:%s>%s", de.Kind, de.Key, de.Old.Value, de.New.Value))
+	}
+	want := "added:added:>y changed:changed:a>b removed:deleted-later:x> removed:removed:x>"
+	if s := strings.Join(got, " "); s != want {
+		t.Fatalf("diff = %s\nwant   %s", s, want)
+	}
+
+	// 同一个目录和自己比较没有差异
+	for de, err := range Diff(newDir, newDir) {
+		t.Fatalf("unexpected diff %v %v", de, err)
+	}
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+
+	"monolithdb/internal/vfs"
+)
+
+// DiskUsage 是数据库目录在磁盘上占用的空间（字节），见 DB.DiskSize。
+type DiskUsage struct {
+	LiveSSTBytes int64 // 当前 version 里的 SST
+
+	// ObsoleteSSTBytes 是已经被 compaction 替换、但还被读取或 Snapshot 使用而没有删除的 SST
+	// （见 PropNumObsoleteSSTables）。关闭这些 Snapshot 之后这部分空间就会释放。
+	ObsoleteSSTBytes int64
+
+	// WALBytes 是 WAL 已经写到文件的部分，包括等待写成 SST 的不可变 MemTable 的 WAL 段，
+	// 以及 Flush 之后保留在 wal/ 下的旧段（见 Options.WALRetentionSegments）。
+	// 移动到 Options.WALArchiveDir 的段不在数据目录里，不计算在内。
+	WALBytes int64
+
+	ValueLogBytes int64 // 值日志文件（见 Options.ValueLogThreshold）
+
+	// LostBytes 是 Repair 和 WAL 恢复隔离到 lost/ 下的文件，确认不需要之后可以手动删除。
+	LostBytes int64
+}
+
+// Total 返回所有部分的总和。
+func (u DiskUsage) Total() int64 {
+	return u.LiveSSTBytes + u.ObsoleteSSTBytes + u.WALBytes + u.ValueLogBytes + u.LostBytes
+}
+
+// DiskSize 返回数据库当前占用的磁盘空间，应用可以据此实施自己的存储配额，或者在磁盘写满之前报警。
+// 只统计文件大小，不读取文件内容。
+func (d *DB) DiskSize() (DiskUsage, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var u DiskUsage
+	live, err := tableBytes(d.opts.fs(), d.versions.current().tables)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+	u.LiveSSTBytes = int64(live)
+	// obsolete 的表随时可能因为最后一个使用者放开而被删除
+	for _, p := range d.versions.obsoleteTables() {
+		n, err := fileSize(d.opts.fs(), p)
+		if err != nil {
+			return DiskUsage{}, err
+		}
+		u.ObsoleteSSTBytes += n
+	}
+
+	if u.WALBytes, err = fileSize(d.opts.fs(), filepath.Join(d.dir, walFileName)); err != nil {
+		return DiskUsage{}, err
+	}
+	segs, err := listImmWALs(d.opts.fs(), d.dir)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+	retained, err := listWALSegments(d.opts.fs(), d.dir)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+	for _, s := range append(segs, retained...) {
+		n, err := fileSize(d.opts.fs(), s.path)
+		if err != nil {
+			return DiskUsage{}, err
+		}
+		u.WALBytes += n
+	}
+
+	ids, err := listValueLogs(d.opts.fs(), d.versions.vlog.dir)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+	for _, id := range ids {
+		n, err := fileSize(d.opts.fs(), d.versions.vlog.path(id))
+		if err != nil {
+			return DiskUsage{}, err
+		}
+		u.ValueLogBytes += n
+	}
+
+	lostDir := filepath.Join(d.dir, lostDirName)
+	names, err := d.opts.fs().List(lostDir)
+	if err != nil && !os.IsNotExist(err) {
+		return DiskUsage{}, err
+	}
+	for _, name := range names {
+		n, err := fileSize(d.opts.fs(), filepath.Join(lostDir, name))
+		if err != nil {
+			return DiskUsage{}, err
+		}
+		u.LostBytes += n
+	}
+	return u, nil
+}
+
+// fileSize 返回 path 的大小，文件不存在时为 0。
+func fileSize(fs vfs.FS, path string) (int64, error) {
+	st, err := fs.Stat(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return st.Size(), nil
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/vfs"
+)
+
+func TestDiskSize(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	u, err := d.DiskSize()
+	if err != nil || u.Total() != 0 {
+		t.Fatalf("empty db: %+v, %v", u, err)
+	}
+
+	value := bytes.Repeat([]byte("v"), 100)
+	put := func() {
+		t.Helper()
+		for i := 0; i < 50; i++ {
+			if err := d.Put(fmt.Sprintf("k%03d", i), value); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := d.wal.Sync(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put()
+	u, err = d.DiskSize()
+	if err != nil || u.WALBytes == 0 || u.LiveSSTBytes != 0 {
+		t.Fatalf("after writes: %+v, %v", u, err)
+	}
+
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	put()
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	live, _ := tableBytes(vfs.Default, d.versions.current().tables)
+	u, err = d.DiskSize()
+	if err != nil || u.LiveSSTBytes != int64(live) || u.ObsoleteSSTBytes != 0 || u.WALBytes != 0 {
+		t.Fatalf("after flush: %+v, %v (live %d)", u, err, live)
+	}
+
+	// Snapshot 固定住的旧表在 compaction 之后计入 ObsoleteSSTBytes，关闭之后释放
+	snap := d.NewSnapshot()
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	u, err = d.DiskSize()
+	if err != nil || u.ObsoleteSSTBytes != int64(live) || u.LiveSSTBytes == 0 {
+		t.Fatalf("with snapshot: %+v, %v", u, err)
+	}
+	if err := snap.Close(); err != nil {
+		t.Fatal(err)
+	}
+	u, err = d.DiskSize()
+	if err != nil || u.ObsoleteSSTBytes != 0 || u.Total() != u.LiveSSTBytes {
+		t.Fatalf("after snapshot close: %+v, %v", u, err)
+	}
+}
+
+func TestDiskSizeValueLog(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true, ValueLogThreshold: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Put("big", bytes.Repeat([]byte("v"), 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	u, err := d.DiskSize()
+	if err != nil || u.ValueLogBytes < 1000 || u.LiveSSTBytes == 0 {
+		t.Fatalf("%+v, %v", u, err)
+	}
+}
+
+func TestDiskSizeRetainedWALAndLost(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, WALRetentionSegments: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	value := bytes.Repeat([]byte("v"), 1000)
+	for i := 0; i < 5; i++ {
+		if err := d.Put(fmt.Sprintf("k%d", i), value); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	segs, err := listWALSegments(vfs.Default, dir)
+	if err != nil || len(segs) != 5 {
+		t.Fatalf("retained segments: %d, %v", len(segs), err)
+	}
+	var retained int64
+	for _, s := range segs {
+		n, err := fileSize(vfs.Default, s.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		retained += n
+	}
+
+	// Repair 隔离的文件计入 LostBytes
+	if err := os.MkdirAll(filepath.Join(dir, lostDirName), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, lostDirName, "000001.sst"), make([]byte, 123), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := d.DiskSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.WALBytes != retained || u.LostBytes != 123 {
+		t.Fatalf("%+v, want WALBytes=%d LostBytes=123", u, retained)
+	}
+	if u.Total() != u.LiveSSTBytes+retained+123 {
+		t.Fatalf("total %d does not include retained WAL and lost files: %+v", u.Total(), u)
+	}
+}
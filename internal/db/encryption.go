@@ -0,0 +1,26 @@
+package db
+
+import (
+	"path/filepath"
+
+	"monolithdb/internal/sstable"
+)
+
+// TableKeyIDs 返回当前每张 SST 使用的加密密钥 ID（文件名 -> 密钥 ID，明文表为空字符串）。
+// 轮换密钥（见 Options.Encryption）并 Compact 之后，用它确认旧密钥已经不再被任何表引用。
+// 活跃 WAL 在每次 Flush 之后重建，不再包含旧密钥的记录；但 Options.WALRetentionSegments
+// 保留的归档段在被清理之前依然需要旧密钥才能读取（Changes）。
+func (d *DB) TableKeyIDs() (map[string]string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	ids := make(map[string]string)
+	for _, p := range d.versions.current().tables {
+		id, err := sstable.TableKeyIDFS(d.opts.fs(), p)
+		if err != nil {
+			return nil, err
+		}
+		ids[filepath.Base(p)] = id
+	}
+	return ids, nil
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/encrypt"
+)
+
+func TestEncryptionAtRest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	keys, err := encrypt.NewStaticKeys("k1", bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{DisableFsync: true, Encryption: keys}
+
+	d, err := OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte("top-secret-value")
+	if err := d.Put("flushed", secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("in-wal", secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// SST 和 WAL 里都不能出现明文
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(b, secret) || bytes.Contains(b, []byte("in-wal")) {
+			t.Errorf("%s contains plaintext", p)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenWithOptions(dir, Options{DisableFsync: true}); !errors.Is(err, encrypt.ErrNoKeyProvider) {
+		t.Fatalf("open without keys: err = %v", err)
+	}
+
+	d, err = OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"flushed", "in-wal"} {
+		if v, ok, err := d.Get(k); err != nil || !ok || !bytes.Equal(v, secret) {
+			t.Fatalf("Get(%q) = %q, %v, %v", k, v, ok, err)
+		}
+	}
+
+	// 轮换密钥：Compact 把表用新密钥重写之后，旧密钥就可以删掉
+	if err := keys.Rotate("k2", bytes.Repeat([]byte{2}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	ids, err := d.TableKeyIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, id := range ids {
+		if id != "k2" {
+			t.Fatalf("table %s still uses key %q after compaction", name, id)
+		}
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keys.Remove("k1"); err != nil {
+		t.Fatal(err)
+	}
+	d, err = OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if v, ok, err := d.Get("in-wal"); err != nil || !ok || !bytes.Equal(v, secret) {
+		t.Fatalf("after rotation Get = %q, %v, %v", v, ok, err)
+	}
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"container/list"
+	"log"
+	"sync"
+)
+
+// EvictionPolicy 决定有界模式（Options.MaxKeys / MaxBytes）下先淘汰哪个 key。
+//
+// 所有方法都由 DB 串行调用，实现不需要自己加锁。
+// 每个 DB 必须使用独立的实例，不能在多个 DB 之间共享。
+type EvictionPolicy interface {
+	// Add 在 key 被写入（新增或覆盖）后调用。
+	Add(key string)
+	// Access 在 Get 命中 key 后调用。
+	Access(key string)
+	// Remove 在 key 被删除（包括被淘汰）后调用。
+	Remove(key string)
+	// Victim 返回下一个应被淘汰的 key，但不把它移除；没有可淘汰的 key 时返回 false。
+	Victim() (string, bool)
+}
+
+// listPolicy 用一个双向链表维护淘汰顺序：表头最新，表尾最先被淘汰。
+type listPolicy struct {
+	ll    *list.List
+	items map[string]*list.Element
+
+	// moveOnAdd / moveOnAccess 决定覆盖写、读命中时是否把 key 挪到表头
+	moveOnAdd    bool
+	moveOnAccess bool
+}
+
+// NewLRUEviction 返回按最近访问时间淘汰的策略：最久没被读写的 key 先被淘汰。
+func NewLRUEviction() EvictionPolicy {
+	return &listPolicy{ll: list.New(), items: make(map[string]*list.Element), moveOnAdd: true, moveOnAccess: true}
+}
+
+// NewFIFOEviction 返回按首次写入顺序淘汰的策略：覆盖写和读取都不会改变顺序。
+func NewFIFOEviction() EvictionPolicy {
+	return &listPolicy{ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (p *listPolicy) Add(key string) {
+	if el, ok := p.items[key]; ok {
+		if p.moveOnAdd {
+			p.ll.MoveToFront(el)
+		}
+		return
+	}
+	p.items[key] = p.ll.PushFront(key)
+}
+
+func (p *listPolicy) Access(key string) {
+	if !p.moveOnAccess {
+		return
+	}
+	if el, ok := p.items[key]; ok {
+		p.ll.MoveToFront(el)
+	}
+}
+
+func (p *listPolicy) Remove(key string) {
+	if el, ok := p.items[key]; ok {
+		p.ll.Remove(el)
+		delete(p.items, key)
+	}
+}
+
+func (p *listPolicy) Victim() (string, bool) {
+	el := p.ll.Back()
+	if el == nil {
+		return "", false
+	}
+	return el.Value.(string), true
+}
+
+// evictor 跟踪所有 live key 的大小，超过上限时由后台 goroutine 调用 Delete 淘汰。
+//
+// 它有自己的锁：Get 只持有 DB 的读锁，但读命中也要更新淘汰顺序。
+type evictor struct {
+	mu       sync.Mutex
+	policy   EvictionPolicy
+	sizes    map[string]int64 // key -> len(key)+len(value)
+	bytes    int64
+	evicted  uint64
+	maxKeys  int
+	maxBytes int64
+
+	kick     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newEvictor(opts Options) *evictor {
+	p := opts.Eviction
+	if p == nil {
+		p = NewLRUEviction()
+	}
+	return &evictor{
+		policy:   p,
+		sizes:    make(map[string]int64),
+		maxKeys:  opts.MaxKeys,
+		maxBytes: opts.MaxBytes,
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (ev *evictor) added(key string, valueLen int) {
+	ev.mu.Lock()
+	size := int64(len(key) + valueLen)
+	ev.bytes += size - ev.sizes[key]
+	ev.sizes[key] = size
+	ev.policy.Add(key)
+	over := ev.overLocked()
+	ev.mu.Unlock()
+
+	if over {
+		// 非阻塞：后台已经有一次待处理的唤醒就够了
+		select {
+		case ev.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (ev *evictor) accessed(key string) {
+	ev.mu.Lock()
+	ev.policy.Access(key)
+	ev.mu.Unlock()
+}
+
+func (ev *evictor) removed(key string) {
+	ev.mu.Lock()
+	if size, ok := ev.sizes[key]; ok {
+		ev.bytes -= size
+		delete(ev.sizes, key)
+	}
+	ev.policy.Remove(key)
+	ev.mu.Unlock()
+}
+
+func (ev *evictor) overLocked() bool {
+	if ev.maxKeys > 0 && len(ev.sizes) > ev.maxKeys {
+		return true
+	}
+	return ev.maxBytes > 0 && ev.bytes > ev.maxBytes
+}
+
+// victim 在超限时返回下一个要淘汰的 key。
+func (ev *evictor) victim() (string, bool) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+
+	if !ev.overLocked() {
+		return "", false
+	}
+	return ev.policy.Victim()
+}
+
+// run 是后台淘汰循环，直到 close 被调用。
+//
+// 淘汰就是普通的 Delete（写 WAL + tombstone），所以重启后被淘汰的 key 依然不可见。
+// 选出 victim 和 Delete 之间不持有锁，并发覆盖写同一个 key 时新值可能被一起删掉——
+// 对缓存场景来说这是可以接受的。
+func (ev *evictor) run(d *DB) {
+	defer close(ev.done)
+	for {
+		select {
+		case <-ev.stop:
+			return
+		case <-ev.kick:
+		}
+
+		for {
+			key, ok := ev.victim()
+			if !ok {
+				break
+			}
+			if err := d.Delete(key); err != nil {
+				// 下次写入超限时会再次唤醒重试
+				log.Printf("forgedb: evict %q: %v", key, err)
+				break
+			}
+			ev.mu.Lock()
+			ev.evicted++
+			ev.mu.Unlock()
+
+			select {
+			case <-ev.stop:
+				return
+			default:
+			}
+		}
+	}
+}
+
+func (ev *evictor) close() {
+	ev.stopOnce.Do(func() { close(ev.stop) })
+	<-ev.done
+}
+
+// EvictionStats 是有界模式的统计信息。
+type EvictionStats struct {
+	Keys    int    // 当前跟踪的 live key 数
+	Bytes   int64  // 当前跟踪的 key + value 字节数
+	Evicted uint64 // 累计淘汰的 key 数
+}
+
+func (ev *evictor) stats() EvictionStats {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	return EvictionStats{Keys: len(ev.sizes), Bytes: ev.bytes, Evicted: ev.evicted}
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitEvicted 等待后台淘汰把 live key 数降到 keys 以内。
+func waitEvicted(t *testing.T, d *DB, keys int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for d.Stats().Eviction.Keys > keys {
+		if time.Now().After(deadline) {
+			t.Fatalf("eviction did not converge: %+v", d.Stats().Eviction)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestEvictionLRU(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "db"), Options{MaxKeys: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+
+	for i := 0; i < 10; i++ {
+		if err := d.Put(fmt.Sprintf("k%02d", i), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 读一次 k00，它变成最近使用，不应被淘汰
+	if _, ok, _ := d.Get("k00"); !ok {
+		t.Fatalf("k00 missing")
+	}
+	for i := 10; i < 13; i++ {
+		if err := d.Put(fmt.Sprintf("k%02d", i), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitEvicted(t, d, 10)
+
+	for _, k := range []string{"k01", "k02", "k03"} {
+		if _, ok, _ := d.Get(k); ok {
+			t.Fatalf("%s should have been evicted", k)
+		}
+	}
+	for _, k := range []string{"k00", "k04", "k12"} {
+		if _, ok, _ := d.Get(k); !ok {
+			t.Fatalf("%s should still exist", k)
+		}
+	}
+	if st := d.Stats().Eviction; st.Evicted != 3 {
+		t.Fatalf("Evicted = %d, want 3", st.Evicted)
+	}
+}
+
+func TestEvictionFIFOAndMaxBytes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	opts := Options{MaxBytes: 100, Eviction: NewFIFOEviction()}
+	d, err := OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 每条 2 + 18 = 20 字节，最多容纳 5 条
+	val := make([]byte, 18)
+	for i := 0; i < 5; i++ {
+		if err := d.Put(fmt.Sprintf("k%d", i), val); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// FIFO：读取不改变顺序
+	_, _, _ = d.Get("k0")
+	if err := d.Put("k5", val); err != nil {
+		t.Fatal(err)
+	}
+	waitEvicted(t, d, 5)
+
+	if _, ok, _ := d.Get("k0"); ok {
+		t.Fatalf("k0 should have been evicted first")
+	}
+	if b := d.Stats().Eviction.Bytes; b > 100 {
+		t.Fatalf("tracked bytes = %d, want <= 100", b)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启后淘汰状态从现有数据重建，被淘汰的 key 依然不可见
+	opts.Eviction = NewFIFOEviction()
+	d, err = OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+
+	if st := d.Stats().Eviction; st.Keys != 5 || st.Bytes != 100 {
+		t.Fatalf("after reopen: %+v", st)
+	}
+	if _, ok, _ := d.Get("k0"); ok {
+		t.Fatalf("k0 resurrected after reopen")
+	}
+}
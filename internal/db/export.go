@@ -0,0 +1,94 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"monolithdb/internal/sstable"
+	"monolithdb/internal/types"
+)
+
+// exportTableBytes 是 Snapshot.Export 写出的每张表大约包含的 key+value 字节数。
+// 一张表的记录在写出之前都在内存里，所以不能太大。
+const exportTableBytes = 32 << 20
+
+// Export 把 Snapshot 中所有可见的记录写成 dir 下的一组 SST（000001.sst、000002.sst……），
+// 返回写出的文件路径。文件按名字的顺序 key 递增、互不重叠；已删除和已过期的记录不会写出，
+// 值日志里的 value 写回表里，TTL 保留。dir 必须不存在或者为空；出错时删除 dir。
+//
+// 写出的是完整的 ForgeDB SST（properties 里的创建原因是 export），使用数据库的比较器、压缩和加密设置，
+// 可以用 sstable 包读取做离线分析，也可以逐张读出之后交给另一个数据库的 Ingest 来初始化副本。
+// value 是保存在数据库里的原始字节，不经过 ReadInterceptors。记录的序号不保留（Seq 为 0）。
+//
+// 与其它读取一样，Export 不持有数据库的锁。
+func (s *Snapshot) Export(dir string) ([]string, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	if err := prepareEmptyDir(s.d.opts.fs(), dir); err != nil {
+		return nil, err
+	}
+	paths, err := s.export(dir)
+	if err != nil {
+		_ = s.d.opts.fs().RemoveAll(dir)
+		return nil, err
+	}
+	return paths, nil
+}
+
+func (s *Snapshot) export(dir string) ([]string, error) {
+	d := s.d
+	opts := sstable.WriterOptions{
+		Properties:  sstable.Properties{CreationReason: sstable.ReasonExport},
+		NoSync:      d.opts.DisableFsync,
+		BlockSize:   d.opts.blockSize(),
+		Comparer:    d.cmp,
+		RateLimiter: d.opts.rateLimiter(),
+		Compression: d.opts.Compression,
+		Encryption:  d.opts.Encryption,
+		FS:          d.opts.FS,
+	}
+	opts = d.opts.withFilter(opts)
+
+	var paths []string
+	var buf []types.Entry
+	var size int64
+	write := func() error {
+		if len(buf) == 0 {
+			return nil
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%06d.sst", len(paths)+1))
+		tmp := path + ".tmp"
+		if err := sstable.WriteTableWithOptions(tmp, buf, opts); err != nil {
+			return err
+		}
+		if err := d.opts.fs().Rename(tmp, path); err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		buf, size = buf[:0], 0
+		return nil
+	}
+
+	for e, err := range s.all("", "", false) {
+		if err != nil {
+			return nil, err
+		}
+		e.Seq = 0
+		buf = append(buf, e)
+		if size += entrySize(e); size >= exportTableBytes {
+			if err := write(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := write(); err != nil {
+		return nil, err
+	}
+	if !d.opts.DisableFsync {
+		if err := syncDir(d.opts.fs(), dir); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
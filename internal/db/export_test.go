@@ -0,0 +1,92 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"monolithdb/internal/sstable"
+)
+
+func TestSnapshotExport(t *testing.T) {
+	tmp := t.TempDir()
+	d, err := OpenWithOptions(filepath.Join(tmp, "data"), Options{DisableFsync: true, ValueLogThreshold: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// 一部分在 SST 里（大 value 在值日志里），一部分在 MemTable 里；k010 被删除，ttl 带过期时间
+	for i := 0; i < 100; i++ {
+		if i == 50 {
+			if err := d.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := d.Put(fmt.Sprintf("k%03d", i), bytes.Repeat([]byte{byte('a' + i%26)}, i+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Delete("k010"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.PutWithTTL("ttl", []byte("x"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	want, err := d.Range("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap := d.NewSnapshot()
+	defer snap.Close()
+	// 创建 Snapshot 之后的写入不会导出
+	if err := d.Put("k000", []byte("changed")); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(tmp, "export")
+	paths, err := snap.Export(out)
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("Export = %v, %v", paths, err)
+	}
+	props, err := sstable.ReadProperties(paths[0])
+	if err != nil || props.CreationReason != sstable.ReasonExport {
+		t.Fatalf("properties: %+v %v", props, err)
+	}
+	got, err := sstable.Range(paths[0], "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("exported %d records, want %d", len(got), len(want))
+	}
+	for i, e := range got {
+		if e.Key != want[i].Key || !bytes.Equal(e.Value, want[i].Value) || e.Tombstone || e.ValueRef || e.ExpiresAt != want[i].ExpiresAt {
+			t.Fatalf("record %d: got %+v, want %+v", i, e, want[i])
+		}
+	}
+	if got[len(got)-1].Key != "ttl" || got[len(got)-1].ExpiresAt == 0 {
+		t.Fatalf("TTL not exported: %+v", got[len(got)-1])
+	}
+
+	// 导出的表可以用来初始化另一个数据库
+	d2, err := OpenWithOptions(filepath.Join(tmp, "replica"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d2.Close()
+	if err := d2.Ingest(got, "export"); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := d2.Get("k000"); err != nil || !ok || string(v) != "a" {
+		t.Fatalf("replica k000 = %q %v %v", v, ok, err)
+	}
+
+	// 目标目录必须为空
+	if _, err := snap.Export(out); err == nil {
+		t.Fatal("Export into a non-empty directory succeeded")
+	}
+}
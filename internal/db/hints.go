@@ -0,0 +1,195 @@
+package db
+
+import (
+	"strings"
+
+	"monolithdb/internal/sstable"
+)
+
+// CompactionHint 是应用对某个 key 前缀写入模式的提示，MaybeCompact 据此选择要合并的表。
+type CompactionHint byte
+
+const (
+	HintNone      CompactionHint = iota // 没有提示
+	HintWriteOnce                       // 写入后不再修改：合并回收不了空间，尽量不重写
+	HintHotUpdate                       // 频繁覆盖 / 删除：旧版本堆积快，优先合并
+)
+
+const (
+	// compactionTrigger 是 MaybeCompact 在没有 hot-update 表时开始合并的表数。
+	compactionTrigger = 4
+
+	// hotCompactionTrigger 是候选表中含有 hot-update key 时开始合并的表数。
+	hotCompactionTrigger = 2
+
+	// tombstoneCompactionMin 是按 tombstone 比例合并一张表所需的最少 tombstone 数，
+	// 避免零星的几次删除就触发合并。
+	tombstoneCompactionMin = 16
+
+	// DefaultTombstoneCompactionRatio 是 Options.TombstoneCompactionRatio 的默认值。
+	DefaultTombstoneCompactionRatio = 0.5
+)
+
+// SetCompactionHint 为 prefix 开头的 key 设置提示，HintNone 表示清除。
+// 前缀互相包含时以最长的为准。提示只保存在内存里，每次 Open 之后需要重新设置。
+func (d *DB) SetCompactionHint(prefix string, hint CompactionHint) {
+	prefix = d.normKey(prefix)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if hint == HintNone {
+		delete(d.hints, prefix)
+		return
+	}
+	if d.hints == nil {
+		d.hints = make(map[string]CompactionHint)
+	}
+	d.hints[prefix] = hint
+}
+
+// MaybeCompact 按提示选择一组表合并，没有值得合并的表时什么都不做。
+// compacted 表示是否执行了合并。
+//
+// 候选表是从最新的表开始的连续一段，遇到只含 write-once key 的表就停下：
+// 它和比它更旧的表都保持不动。候选表达到 compactionTrigger 张（同一次 compaction 切出的几张表算一张）、
+// 或者其中有 hot-update key 且达到 hotCompactionTrigger 张时才合并。
+//
+// 否则如果候选表中有 tombstone 密集的表（见 Options.TombstoneCompactionRatio），也合并全部候选表：
+// tombstone 遮住的旧版本在合并中被回收，候选表包含最旧的表时 tombstone 本身也被丢弃。
+// 空间优先从删除多的地方回收，而不是只看表的个数。
+func (d *DB) MaybeCompact() (compacted bool, err error) {
+	if d.opts.ReadOnly {
+		return false, ErrReadOnly
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	inputs, err := d.pickCompaction()
+	if err != nil || len(inputs) == 0 {
+		return false, err
+	}
+	if err := d.compactTables(inputs); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// pickCompaction 返回 MaybeCompact 要合并的表，nil 表示不需要合并。调用方持有锁（读锁即可）。
+func (d *DB) pickCompaction() ([]string, error) {
+	tables := d.versions.current().tables
+
+	var run []string
+	hot := false
+	for _, p := range tables {
+		writeOnly, hasHot, err := d.classifyTable(p)
+		if err != nil {
+			return nil, err
+		}
+		if writeOnly {
+			break
+		}
+		run = append(run, p)
+		hot = hot || hasHot
+	}
+
+	n, err := d.countSortedRuns(run)
+	if err != nil {
+		return nil, err
+	}
+	if n >= compactionTrigger || (hot && n >= hotCompactionTrigger) {
+		return run, nil
+	}
+	// 只有一张候选表时合并它本身回收不了被遮住的版本，除非它是最旧的表（可以丢弃 tombstone）
+	if len(run) > 1 || (len(run) == 1 && len(tables) == 1) {
+		dense, err := d.tombstoneDense(run)
+		if err != nil || dense {
+			return run, err
+		}
+	}
+	return nil, nil
+}
+
+// countSortedRuns 返回 tables 中 key 范围可能互相重叠的表数：同一次 compaction 切出的几张输出
+// （sstable.Properties.OutputFiles 相同）互不相交，只算一张，否则大的 compaction 一结束就会再次触发。
+func (d *DB) countSortedRuns(tables []string) (int, error) {
+	if len(tables) < hotCompactionTrigger || d.opts.MaxSubcompactions <= 1 {
+		return len(tables), nil
+	}
+	n := 0
+	prev := ""
+	for _, p := range tables {
+		props, err := sstable.ReadPropertiesWithOptions(p, d.sstReadOptions(ReadOptions{}))
+		if err != nil {
+			return 0, err
+		}
+		group := strings.Join(props.OutputFiles, ",")
+		if group == "" || group != prev {
+			n++
+		}
+		prev = group
+	}
+	return n, nil
+}
+
+// tombstoneDense 报告 tables 中是否有 tombstone 比例达到 Options.TombstoneCompactionRatio 的表。
+// 比例来自 properties 里的记录数（sstable.Properties.NumTombstones），没有记录计数的旧表不参与判断。
+func (d *DB) tombstoneDense(tables []string) (bool, error) {
+	ratio := d.opts.TombstoneCompactionRatio
+	if ratio < 0 {
+		return false, nil
+	}
+	if ratio == 0 {
+		ratio = DefaultTombstoneCompactionRatio
+	}
+	for _, p := range tables {
+		props, err := sstable.ReadPropertiesWithOptions(p, d.sstReadOptions(ReadOptions{}))
+		if err != nil {
+			return false, err
+		}
+		if props.NumTombstones >= tombstoneCompactionMin && float64(props.NumTombstones) >= ratio*float64(props.NumEntries) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// classifyTable 扫描表中的 key：writeOnly 表示所有 key 都属于 write-once 前缀，
+// hot 表示至少有一个 key 属于 hot-update 前缀。
+func (d *DB) classifyTable(path string) (writeOnly, hot bool, err error) {
+	if len(d.hints) == 0 {
+		return false, false, nil
+	}
+
+	writeOnly = true
+	n := 0
+	for e, err := range sstable.RangeIter(path, "", "", d.sstReadOptions(ReadOptions{})) {
+		if err != nil {
+			return false, false, err
+		}
+		n++
+		switch d.hintFor(e.Key) {
+		case HintHotUpdate:
+			hot = true
+			writeOnly = false
+		case HintNone:
+			writeOnly = false
+		}
+		if hot && !writeOnly {
+			break
+		}
+	}
+	return writeOnly && n > 0, hot, nil
+}
+
+// hintFor 返回匹配 key 的最长前缀的提示。
+func (d *DB) hintFor(key string) CompactionHint {
+	best, hint := -1, HintNone
+	for prefix, h := range d.hints {
+		if len(prefix) > best && strings.HasPrefix(key, prefix) {
+			best, hint = len(prefix), h
+		}
+	}
+	return hint
+}
@@ -0,0 +1,170 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/sstable"
+)
+
+func TestMaybeCompactUsesHints(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{
+		DisableFsync: true,
+		CompactionFilterFactory: func() CompactionFilter {
+			return &prefixFilter{}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	flush := func(kv ...string) {
+		t.Helper()
+		for i := 0; i < len(kv); i += 2 {
+			if err := d.Put(kv[i], []byte(kv[i+1])); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := d.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	maybe := func(want bool) {
+		t.Helper()
+		got, err := d.MaybeCompact()
+		if err != nil || got != want {
+			t.Fatalf("MaybeCompact = %v %v, want %v", got, err, want)
+		}
+	}
+
+	// 最旧的表只有 write-once 的 key，之后永远不会被选中
+	d.SetCompactionHint("drop/", HintWriteOnce)
+	d.SetCompactionHint("log/", HintWriteOnce)
+	flush("drop/1", "v1", "log/1", "x")
+	cold := d.versions.current().tables[0]
+
+	// 没有 hot-update key 时需要 compactionTrigger 张表
+	flush("a", "1")
+	flush("b", "1")
+	maybe(false)
+
+	// 出现 hot-update key 之后两张表就会合并，但不包括 write-once 的表
+	d.SetCompactionHint("a", HintHotUpdate)
+	flush("drop/1", "v2", "a", "2")
+	maybe(true)
+	tables := d.versions.current().tables
+	if len(tables) != 2 || tables[1] != cold {
+		t.Fatalf("sstables after MaybeCompact = %v", tables)
+	}
+	maybe(false)
+
+	// filter 丢弃了 drop/1 的新版本：没有合并到最旧的表时必须留下 tombstone，
+	// 否则 cold 表里的 v1 会重新可见
+	if _, ok, err := d.Get("drop/1"); err != nil || ok {
+		t.Fatalf("drop/1 visible after partial compaction: %v %v", ok, err)
+	}
+	for k, v := range map[string]string{"a": "2", "b": "1", "log/1": "x"} {
+		if got, ok, err := d.Get(k); err != nil || !ok || string(got) != v {
+			t.Fatalf("Get(%s) = %q %v %v", k, got, ok, err)
+		}
+	}
+
+	// 清除提示后回到按表数触发
+	d.SetCompactionHint("a", HintNone)
+	for i := 0; i < 2; i++ {
+		flush(fmt.Sprintf("c%d", i), "1")
+	}
+	maybe(false)
+	flush("c2", "1")
+	maybe(true)
+	if tables := d.versions.current().tables; len(tables) != 2 || tables[1] != cold {
+		t.Fatalf("sstables = %v", tables)
+	}
+}
+
+func TestMaybeCompactPicksTombstoneDenseTables(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for i := range 100 {
+		if err := d.Put(fmt.Sprintf("k%03d", i), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 只有一张表、没有 tombstone：不合并
+	if compacted, err := d.MaybeCompact(); err != nil || compacted {
+		t.Fatalf("MaybeCompact = %v, %v", compacted, err)
+	}
+
+	// 零星的删除不够 tombstoneCompactionMin，不合并
+	for i := range tombstoneCompactionMin - 1 {
+		if err := d.Delete(fmt.Sprintf("k%03d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if compacted, err := d.MaybeCompact(); err != nil || compacted {
+		t.Fatalf("MaybeCompact = %v, %v with few tombstones", compacted, err)
+	}
+
+	// 一张几乎全是 tombstone 的表：两张表就合并，合并到最旧的表，tombstone 被丢弃
+	for i := range 60 {
+		if err := d.Delete(fmt.Sprintf("k%03d", 40+i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Put("z", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if compacted, err := d.MaybeCompact(); err != nil || !compacted {
+		t.Fatalf("MaybeCompact = %v, %v with a tombstone-dense table", compacted, err)
+	}
+	tables := d.versions.current().tables
+	if len(tables) != 1 {
+		t.Fatalf("sstables = %v", tables)
+	}
+	props, err := sstable.ReadProperties(tables[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := uint64(100 - (tombstoneCompactionMin - 1) - 60 + 1); props.NumEntries != want || props.NumTombstones != 0 {
+		t.Fatalf("output has %d entries, %d tombstones; want %d, 0", props.NumEntries, props.NumTombstones, want)
+	}
+	if compacted, err := d.MaybeCompact(); err != nil || compacted {
+		t.Fatalf("MaybeCompact = %v, %v after reclaiming", compacted, err)
+	}
+}
+
+func TestTombstoneCompactionRatioDisabled(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true, TombstoneCompactionRatio: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for i := range 2 * tombstoneCompactionMin {
+		if err := d.Delete(fmt.Sprintf("k%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if compacted, err := d.MaybeCompact(); err != nil || compacted {
+		t.Fatalf("MaybeCompact = %v, %v with tombstone picking disabled", compacted, err)
+	}
+}
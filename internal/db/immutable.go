@@ -0,0 +1,319 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"monolithdb/internal/memtable"
+	"monolithdb/internal/types"
+	"monolithdb/internal/vfs"
+	"monolithdb/internal/wal"
+)
+
+// 不可变 MemTable 队列（见 Options.MaxImmutableMemtables）：MemTable 达到 Options.MemTableSize 时，
+// 写入不再同步 Flush，而是把它冻结进队列、换一个新的 MemTable 和 WAL，由后台按从旧到新的顺序写成 SST。
+// 只有队列满了写入才等待。
+//
+// 每个冻结的 MemTable 有自己的 WAL 段 imm/<first>.log（first 是段内第一条记录的序号），
+// 写成 SST 之后和 Flush 轮换下来的 WAL 一样归档到 wal/。重启时按序号回放 imm/ 下的段，重建队列。
+
+const (
+	immWALDirName = "imm"
+
+	// immFlushRetry 是后台写 SST 失败之后重试的间隔。
+	immFlushRetry = time.Second
+)
+
+// immMemTable 是一个已冻结、等待写成 SST 的 MemTable。
+type immMemTable struct {
+	mem     *memtable.MemTable
+	walPath string // imm/<first>.log，内存模式（见 OpenInMemory）下为空
+	first   uint64
+	last    uint64 // 段内最后一条记录的序号，写成 SST 时作为表的 MaxSeq（见 replayImmWALs）
+}
+
+// memGet 按 MemTable -> 不可变 MemTable（newest first）的顺序查找 key 的最新版本。
+// imm 是 oldest first 的队列。noCopy 为 true 时返回的 value 不做拷贝（见 GetPinned）。
+func memGet(m *memtable.MemTable, imm []*immMemTable, key string, noCopy bool) (types.Entry, bool) {
+	get := (*memtable.MemTable).GetAll
+	if noCopy {
+		get = (*memtable.MemTable).GetAllNoCopy
+	}
+	if e, ok := get(m, key); ok {
+		return e, true
+	}
+	for i := len(imm) - 1; i >= 0; i-- {
+		if e, ok := get(imm[i].mem, key); ok {
+			return e, true
+		}
+	}
+	return types.Entry{}, false
+}
+
+// makeRoomForWrite 在 MemTable 达到 Options.MemTableSize 时为写入腾出空间：
+// 没有开启不可变队列时直接 Flush；否则冻结当前 MemTable，队列已满时等待后台写出一个。
+// ctx 结束、数据库关闭或者后台写 SST 出错时不再等待，返回对应的错误。调用方持有写锁，等待时会释放。
+func (d *DB) makeRoomForWrite(ctx context.Context) error {
+	size := d.opts.MemTableSize
+	if size <= 0 || d.mem.ApproximateBytes() < size {
+		return nil
+	}
+	if d.opts.MaxImmutableMemtables <= 0 {
+		return d.flushLocked()
+	}
+
+	var stop func() bool
+	defer func() {
+		if stop != nil {
+			stop()
+		}
+	}()
+	for d.mem.ApproximateBytes() >= size {
+		if len(d.imm) < d.opts.MaxImmutableMemtables {
+			return d.freezeMemTable()
+		}
+		switch {
+		case d.closed:
+			return ErrClosed
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case d.immErr != nil:
+			return fmt.Errorf("db: background flush: %w", d.immErr)
+		}
+		if stop == nil {
+			// 计入写停顿的统计（Counters.WriteStops / WriteStallTime）
+			d.metrics.stallStops.Add(1)
+			start := time.Now()
+			defer func() { d.metrics.stallNanos.Add(uint64(time.Since(start))) }()
+			// 和写停顿一样：ctx 结束时广播一次，让等待的写入醒来检查 ctx
+			stop = context.AfterFunc(ctx, func() {
+				d.mu.Lock()
+				d.stallCond.Broadcast()
+				d.mu.Unlock()
+			})
+		}
+		d.stallCond.Wait()
+	}
+	return nil
+}
+
+// freezeMemTable 把当前 MemTable 放进不可变队列，它的 WAL 移到 imm/ 下，再换一个空的 MemTable 和 WAL。
+// 调用方持有写锁。
+func (d *DB) freezeMemTable() error {
+	if d.lastSeq < d.walFirstSeq {
+		return nil
+	}
+	immDir := filepath.Join(d.dir, immWALDirName)
+	if err := d.opts.fs().MkdirAll(immDir, 0o755); err != nil {
+		return err
+	}
+	if err := d.wal.Close(); err != nil {
+		return err
+	}
+	// 内存模式没有 WAL 文件，不可变 MemTable 也就没有对应的 WAL 段
+	var seg string
+	if !d.opts.inMemory {
+		seg = filepath.Join(immDir, fmt.Sprintf("%020d.log", d.walFirstSeq))
+		if err := d.opts.fs().Rename(d.walPath, seg); err != nil {
+			return err
+		}
+	}
+	w, err := openWAL(d.walPath, d.opts)
+	if err != nil {
+		return err
+	}
+	d.wal = w
+	d.metrics.walTruncations.Add(1)
+
+	d.imm = append(d.imm, &immMemTable{mem: d.mem, walPath: seg, first: d.walFirstSeq, last: d.lastSeq})
+	d.mem = memtable.NewMemTableWithComparer(d.cmp)
+	// 先 rename 再写 WALSEQ：两步之间崩溃时由 loadWALFirstSeq 用 imm/ 下最新的段校正
+	d.walFirstSeq = d.lastSeq + 1
+	if err := d.writeWALSeq(); err != nil {
+		return err
+	}
+	if d.immKick != nil {
+		select {
+		case d.immKick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// flushImmLocked 把队列里最旧的不可变 MemTable 写成 SST。调用方持有写锁，并且没有后台写入正在进行。
+func (d *DB) flushImmLocked() error {
+	defer d.latency.observe(latFlush, d.latency.start())
+	imm := d.imm[0]
+	path := filepath.Join(d.sstDir, fmt.Sprintf("%06d.sst", d.versions.newFileNumber()))
+	if err := d.writeFlushTable(path, imm.mem.RangeAll("", ""), imm.last); err != nil {
+		return err
+	}
+	return d.installImm(path, path)
+}
+
+// flushOldestImm 是后台写 SST 的一步：在锁外把最旧的不可变 MemTable 写成临时文件，再持锁安装。
+// 队列为空或者已经有写入在进行时返回 false。
+func (d *DB) flushOldestImm() (bool, error) {
+	d.mu.Lock()
+	if len(d.imm) == 0 || d.immFlushing || d.closed {
+		d.mu.Unlock()
+		return false, nil
+	}
+	imm := d.imm[0]
+	d.immFlushing = true
+	d.mu.Unlock()
+
+	start := d.latency.start()
+	// 临时文件名里的编号只是为了不重名；安装时重新分配编号（见 installImm）
+	tmpPath := filepath.Join(d.sstDir, fmt.Sprintf("%06d.sst", d.versions.newFileNumber()))
+	err := d.writeFlushTable(tmpPath, imm.mem.RangeAll("", ""), imm.last)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.immFlushing = false
+	defer d.backlogChanged()
+	if err == nil {
+		// 写的过程中可能有 compaction 安装了编号更大的输出：重新分配编号，
+		// 保证重启后按编号排序时新写出的表依然排在所有旧数据之前
+		err = d.installImm(tmpPath, filepath.Join(d.sstDir, fmt.Sprintf("%06d.sst", d.versions.newFileNumber())))
+	}
+	d.immErr = err
+	if err != nil {
+		return false, err
+	}
+	d.latency.observe(latFlush, start)
+	return true, nil
+}
+
+// installImm 把写好的 tmpPath + ".tmp" rename 成 path 并安装，从队列中移除最旧的不可变 MemTable，
+// 再把它的 WAL 段归档到 wal/。调用方持有写锁。
+func (d *DB) installImm(tmpPath, path string) error {
+	if err := d.installFlushTable(tmpPath+".tmp", path); err != nil {
+		return err
+	}
+	imm := d.imm[0]
+	d.imm = d.imm[1:]
+	d.backlogChanged()
+
+	if imm.walPath != "" {
+		archiveDir := filepath.Join(d.dir, walArchiveDirName)
+		if err := d.opts.fs().MkdirAll(archiveDir, 0o755); err != nil {
+			return err
+		}
+		if err := d.opts.fs().Rename(imm.walPath, filepath.Join(archiveDir, filepath.Base(imm.walPath))); err != nil {
+			return err
+		}
+		if err := d.pruneWALSegments(); err != nil {
+			return err
+		}
+	}
+	return d.saveTableAccess()
+}
+
+// startImmFlusher 启动按顺序把不可变 MemTable 写成 SST 的后台 goroutine，Close 时停止。
+func (d *DB) startImmFlusher() {
+	d.immKick = make(chan struct{}, 1)
+	d.immStop = make(chan struct{})
+	d.immDone = make(chan struct{})
+	go func() {
+		defer close(d.immDone)
+		retry := time.NewTimer(immFlushRetry)
+		retry.Stop()
+		defer retry.Stop()
+		for {
+			for {
+				ok, err := d.flushOldestImm()
+				if err != nil {
+					log.Printf("forgedb: background flush: %v", err)
+					retry.Reset(immFlushRetry)
+				}
+				if !ok {
+					break
+				}
+			}
+			select {
+			case <-d.immStop:
+				return
+			case <-d.immKick:
+			case <-retry.C:
+			}
+		}
+	}()
+}
+
+// stopImmFlusher 停止后台写入并等待正在进行的一次结束，队列里剩下的 MemTable 留给 Flush 或下次打开。
+func (d *DB) stopImmFlusher() {
+	if d.immStop == nil {
+		return
+	}
+	close(d.immStop)
+	<-d.immDone
+	d.immStop = nil
+}
+
+// archiveFlushedImmWALs 把 imm/ 下已经写成 SST（没有被 replayImmWALs 重建成 MemTable）的段归档到 wal/。
+func archiveFlushedImmWALs(fs vfs.FS, dir string, segs []walSegment, imm []*immMemTable) error {
+	live := make(map[string]bool, len(imm))
+	for _, m := range imm {
+		live[m.walPath] = true
+	}
+	for _, s := range segs {
+		if live[s.path] {
+			continue
+		}
+		archiveDir := filepath.Join(dir, walArchiveDirName)
+		if err := fs.MkdirAll(archiveDir, 0o755); err != nil {
+			return err
+		}
+		if err := fs.Rename(s.path, filepath.Join(archiveDir, filepath.Base(s.path))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listImmWALs 返回 imm/ 下等待写成 SST 的 WAL 段，按序号升序。
+func listImmWALs(fs vfs.FS, dir string) ([]walSegment, error) {
+	return listSegments(fs, filepath.Join(dir, immWALDirName))
+}
+
+// replayImmWALs 回放 imm/ 下的 WAL 段，每段重建成一个不可变 MemTable（oldest first），
+// apply 把序号为 seq 的记录应用到 MemTable（见 Open）。返回回放的记录数，以及 WALReplayFilter 是否改动过记录。
+//
+// 已经写成 SST、但归档之前崩溃而残留的段不回放（否则它会遮住之后写入 SST 的新数据），由 Open 归档：
+// 队列按从旧到新的顺序写出，存在 MaxSeq 不小于段末尾序号的表，说明这一段已经在 SST 里了。
+func replayImmWALs(segs []walSegment, flushedSeq uint64, opts Options, apply func(m *memtable.MemTable, imm []*immMemTable, seq uint64, r wal.Record) error) ([]*immMemTable, int, bool, error) {
+	var imm []*immMemTable
+	n, changed := 0, false
+	for _, s := range segs {
+		var records []wal.Record
+		var err error
+		if opts.TolerateCorruptWALTail {
+			records, _, err = wal.ReplayValidWithOptions(s.path, opts.walOptions())
+		} else {
+			records, err = wal.ReplayWithOptions(s.path, opts.walOptions())
+		}
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("%s: %w", filepath.Join(immWALDirName, filepath.Base(s.path)), err)
+		}
+		last := s.first + uint64(len(records)) - 1
+		if len(records) == 0 || last <= flushedSeq {
+			continue
+		}
+		m := memtable.NewMemTableWithComparer(opts.comparer())
+		c, err := replayWAL(opts, records, func(i int, r wal.Record) error {
+			return apply(m, imm, s.first+uint64(i), r)
+		})
+		if err != nil {
+			return nil, 0, false, err
+		}
+		n += len(records)
+		changed = changed || c
+		imm = append(imm, &immMemTable{mem: m, walPath: s.path, first: s.first, last: last})
+	}
+	return imm, n, changed, nil
+}
@@ -0,0 +1,244 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"monolithdb/internal/vfs"
+)
+
+// immOptions 让 MemTable 在两次 putValue（各 50 字节）之后冻结。
+func immOptions(max int) Options {
+	return Options{DisableFsync: true, MemTableSize: 100, MaxImmutableMemtables: max}
+}
+
+func putValue(t *testing.T, d *DB, k, v string) {
+	t.Helper()
+	if err := d.Put(k, []byte(v+string(bytes.Repeat([]byte{'.'}, 50-len(k)-len(v))))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getValue(t *testing.T, d *DB, k string) (string, bool) {
+	t.Helper()
+	v, ok, err := d.Get(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bytes.TrimRight(v, ".")), ok
+}
+
+func TestImmutableMemTableQueue(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, immOptions(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	// 停掉后台写入，让冻结的 MemTable 留在队列里
+	d.stopImmFlusher()
+
+	putValue(t, d, "a", "1")
+	putValue(t, d, "b", "1")
+	putValue(t, d, "a", "2") // 冻结 {a=1, b=1}
+	putValue(t, d, "c", "2")
+	if err := d.Delete("b"); err != nil { // 冻结 {a=2, c=2}
+		t.Fatal(err)
+	}
+	if st := d.Stats(); st.ImmutableMemTables != 2 || st.NumSSTables != 0 {
+		t.Fatalf("stats = %+v, want 2 immutable memtables and no sstables", st)
+	}
+
+	// 读取按 MemTable -> 不可变 MemTable（newest first）的顺序
+	want := map[string]string{"a": "2", "c": "2"}
+	for k, v := range want {
+		if got, ok := getValue(t, d, k); !ok || got != v {
+			t.Fatalf("Get(%s) = %q, %v; want %q", k, got, ok, v)
+		}
+	}
+	if _, ok := getValue(t, d, "b"); ok {
+		t.Fatal("b deleted in a newer immutable memtable is still visible")
+	}
+	entries, err := d.Range("", "")
+	if err != nil || len(entries) != 2 || entries[0].Key != "a" || entries[1].Key != "c" {
+		t.Fatalf("Range = %v, %v", entries, err)
+	}
+
+	// 队列满了：再冻结需要等后台写出一个，ctx 到期时放弃
+	putValue(t, d, "d", "2")
+	putValue(t, d, "f", "2")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := d.PutContext(ctx, "e", []byte("x")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("PutContext with a full queue = %v, want DeadlineExceeded", err)
+	}
+	if c := d.Counters(); c.WriteStops != 1 {
+		t.Fatalf("WriteStops = %d, want 1", c.WriteStops)
+	}
+
+	// 后台写入恢复之后，等待的写入继续
+	done := make(chan error, 1)
+	go func() { done <- d.Put("e", []byte("x")) }()
+	time.Sleep(10 * time.Millisecond)
+	d.startImmFlusher()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// Flush 写出队列里剩下的和当前的 MemTable
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if st := d.Stats(); st.ImmutableMemTables != 0 || st.MemTableEntries != 0 {
+		t.Fatalf("stats after Flush = %+v", st)
+	}
+	want["d"], want["e"], want["f"] = "2", "x", "2"
+	for k, v := range want {
+		if got, ok := getValue(t, d, k); !ok || got != v {
+			t.Fatalf("Get(%s) = %q, %v after Flush; want %q", k, got, ok, v)
+		}
+	}
+	if segs, err := listImmWALs(vfs.Default, dir); err != nil || len(segs) != 0 {
+		t.Fatalf("imm WAL segments left after Flush: %v, %v", segs, err)
+	}
+}
+
+func TestImmutableMemTableRecovery(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, immOptions(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.stopImmFlusher()
+	for i := range 7 {
+		putValue(t, d, fmt.Sprintf("k%d", i%3), fmt.Sprint(i))
+	}
+	if n := d.Stats().ImmutableMemTables; n != 3 {
+		t.Fatalf("%d immutable memtables, want 3", n)
+	}
+	last := d.LastSequence()
+	// 不 Flush 直接关闭：相当于崩溃，数据只在 imm/ 和活跃 WAL 里
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if st := d.Stats(); st.ImmutableMemTables != 3 || st.NumSSTables != 0 {
+		t.Fatalf("stats after reopen = %+v", st)
+	}
+	if d.LastSequence() != last || d.Recovery().WALRecords != 7 {
+		t.Fatalf("LastSequence = %d, replayed %d; want %d, 7", d.LastSequence(), d.Recovery().WALRecords, last)
+	}
+	for k, v := range map[string]string{"k0": "6", "k1": "4", "k2": "5"} {
+		if got, ok := getValue(t, d, k); !ok || got != v {
+			t.Fatalf("Get(%s) = %q, %v; want %q", k, got, ok, v)
+		}
+	}
+	n := 0
+	for c, err := range d.Changes(0) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n++; c.Seq != uint64(n) {
+			t.Fatalf("change %d has seq %d", n, c.Seq)
+		}
+	}
+	if n != 7 {
+		t.Fatalf("Changes returned %d records, want 7", n)
+	}
+}
+
+func TestImmutableWALAlreadyFlushedIsNotReplayed(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, immOptions(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.stopImmFlusher()
+	putValue(t, d, "k", "old")
+	putValue(t, d, "x", "1")
+	putValue(t, d, "y", "1") // 冻结 {k=old, x=1}
+	segs, err := listImmWALs(vfs.Default, dir)
+	if err != nil || len(segs) != 1 {
+		t.Fatalf("imm segments = %v, %v", segs, err)
+	}
+	stale, err := os.ReadFile(segs[0].path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putValue(t, d, "k", "new")
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟写成 SST 之后、归档 WAL 段之前崩溃
+	if err := os.WriteFile(segs[0].path, stale, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d, err = OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if got, ok := getValue(t, d, "k"); !ok || got != "new" {
+		t.Fatalf("Get(k) = %q, %v; the flushed segment was replayed over newer data", got, ok)
+	}
+	if n := d.Stats().ImmutableMemTables; n != 0 {
+		t.Fatalf("%d immutable memtables rebuilt from a flushed segment", n)
+	}
+	if _, err := os.Stat(segs[0].path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("flushed segment not archived: %v", err)
+	}
+}
+
+func TestImmutableMemTableBackgroundFlush(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, immOptions(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 200 {
+		putValue(t, d, fmt.Sprintf("k%03d", i), fmt.Sprint(i))
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for d.Stats().ImmutableMemTables > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("background flush did not drain the queue")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := d.Stats().NumSSTables; n < 50 {
+		t.Fatalf("%d sstables after 200 writes with a 100 byte memtable", n)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	entries, err := d.Range("", "")
+	if err != nil || len(entries) != 200 {
+		t.Fatalf("Range = %d entries, %v; want 200", len(entries), err)
+	}
+	for i, e := range entries {
+		if got := string(bytes.TrimRight(e.Value, ".")); got != fmt.Sprint(i) {
+			t.Fatalf("%s = %q, want %d", e.Key, got, i)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/sstable"
+	"monolithdb/internal/types"
+)
+
+func TestIngest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		if err := d.Put(k, []byte("old-"+k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := d.LastSequence()
+
+	err = d.Ingest([]types.Entry{{Key: "b"}, {Key: "a"}}, "test")
+	if !errors.Is(err, ErrIngestUnsorted) {
+		t.Fatalf("Ingest(unsorted) = %v, want ErrIngestUnsorted", err)
+	}
+	err = d.Ingest([]types.Entry{
+		{Key: "a", Value: []byte("new-a")},
+		{Key: "b", Tombstone: true},
+		{Key: "d", Value: []byte("new-d")},
+	}, "leveldb:000005.ldb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := d.LastSequence(); got != before+1 {
+		t.Fatalf("LastSequence = %d, want %d", got, before+1)
+	}
+	if err := d.Put("d", []byte("after")); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func() {
+		t.Helper()
+		for k, want := range map[string]string{"a": "new-a", "c": "old-c", "d": "after"} {
+			if v, ok, err := d.Get(k); err != nil || !ok || string(v) != want {
+				t.Fatalf("Get(%q) = %q, %v, %v; want %q", k, v, ok, err, want)
+			}
+		}
+		if _, ok, _ := d.Get("b"); ok {
+			t.Fatal("ingested tombstone did not delete b")
+		}
+	}
+	check()
+
+	var ingested bool
+	for _, p := range d.versions.current().tables {
+		props, err := sstable.ReadProperties(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if props.CreationReason == sstable.ReasonIngest && props.IngestSource == "leveldb:000005.ldb" {
+			ingested = true
+		}
+	}
+	if !ingested {
+		t.Fatal("no table with ingest properties")
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d, err = OpenWithOptions(dir, Options{DisableFsync: true}); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	check()
+	if got := d.LastSequence(); got != before+2 {
+		t.Fatalf("LastSequence after reopen = %d, want %d", got, before+2)
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	check()
+}
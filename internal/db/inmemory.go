@@ -0,0 +1,26 @@
+package db
+
+import (
+	"fmt"
+
+	"monolithdb/internal/vfs"
+)
+
+// inMemoryDir 是 OpenInMemory 打开的数据库在内存文件系统里的目录。
+const inMemoryDir = "/forgedb"
+
+// OpenInMemory 打开一个完全在内存里的空数据库：不写 WAL，Flush / compaction 写出的 SST、值日志等文件
+// 都放在一个新的 vfs.NewMem() 里，不碰磁盘。除了持久化之外，API 和语义与 OpenWithOptions 打开的数据库相同
+// （读写、Snapshot、事务、Flush / compaction、TTL、淘汰等），适合单元测试和临时缓存。
+//
+// Close 之后数据就没有了，每次调用都得到一个新的空数据库。没有 WAL，所以 Changes 不可用（见 DB.Changes）；
+// Checkpoint、Backup、Export 写出的目录也在这个内存文件系统里。
+// opts.FS 被忽略；opts.ReadOnly 为 true 时返回 ErrInvalidOptions。
+func OpenInMemory(opts Options) (*DB, error) {
+	if opts.ReadOnly {
+		return nil, fmt.Errorf("%w: in-memory database cannot be read-only", ErrInvalidOptions)
+	}
+	opts.FS = vfs.NewMem()
+	opts.inMemory = true
+	return OpenWithOptions(inMemoryDir, opts)
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestOpenInMemory(t *testing.T) {
+	d, err := OpenInMemory(Options{MemTableSize: 4 << 10, MaxImmutableMemtables: 2, ValueLogThreshold: 128})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := bytes.Repeat([]byte("v"), 200)
+	for i := 0; i < 200; i++ {
+		if err := d.Put(fmt.Sprintf("k%03d", i), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Delete("k000"); err != nil {
+		t.Fatal(err)
+	}
+	snap := d.NewSnapshot()
+	if err := d.Put("k001", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(d.versions.current().tables); n == 0 {
+		t.Fatal("no tables after flush")
+	}
+
+	if v, ok, err := d.Get("k100"); err != nil || !ok || !bytes.Equal(v, value) {
+		t.Fatalf("k100 = %q, %v, %v", v, ok, err)
+	}
+	if _, ok, err := d.Get("k000"); err != nil || ok {
+		t.Fatalf("deleted key: %v, %v", ok, err)
+	}
+	if v, _, _ := d.Get("k001"); string(v) != "new" {
+		t.Fatalf("k001 = %q", v)
+	}
+	if v, _, _ := snap.Get("k001"); !bytes.Equal(v, value) {
+		t.Fatalf("snapshot k001 = %q", v)
+	}
+	snap.Close()
+
+	for _, err := range d.Changes(0) {
+		if !errors.Is(err, ErrChangesUnavailable) {
+			t.Fatalf("Changes: %v", err)
+		}
+		break
+	}
+	if err := d.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(inMemoryDir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("stat %s on disk: %v", inMemoryDir, err)
+	}
+
+	// 每次都是新的空数据库
+	d, err = OpenInMemory(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, ok, err := d.Get("k100"); err != nil || ok {
+		t.Fatalf("new database has k100: %v, %v", ok, err)
+	}
+
+	if _, err := OpenInMemory(Options{ReadOnly: true}); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("read-only: %v", err)
+	}
+}
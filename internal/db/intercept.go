@@ -0,0 +1,102 @@
+package db
+
+import (
+	"time"
+
+	"monolithdb/internal/wal"
+)
+
+// WriteOp 是经过 WriteInterceptor 的一次写入。Key 已经按 Options.KeyNormalizer 规范化。
+type WriteOp struct {
+	// Key 只读：修改它不会生效（事务的锁和读自己写都已经按原来的 key 处理过）。
+	Key string
+
+	// Value 是要写入的值，interceptor 可以替换它（例如压缩、加密、补充字段）。
+	// Delete 为 true 时没有意义。
+	Value []byte
+
+	// TTL 是写入的存活时间，0 表示永不过期；interceptor 可以修改它（例如按前缀设置默认过期时间）。
+	// Delete 为 true 时没有意义。
+	TTL time.Duration
+
+	// Delete 只读，表示这是一次删除。
+	Delete bool
+}
+
+// WriteInterceptor 在写入进入 WAL 之前被调用，可以校验、改写或者拒绝它：
+// 返回 error 时这次写入（Batch 则是整个批次）不会生效，调用方收到该错误。
+//
+// 所有 Put / PutWithTTL / Delete / Write（包括事务、Update 和 ImportJSON 的提交）都会经过它；
+// Touch 只修改过期时间，ApplyRecord 应用的是已经在主节点经过 interceptor 的记录，都不经过它。
+// 实现不能调用 DB 的方法；批次里的写入是在持有写锁时调用的，应当尽快返回。
+type WriteInterceptor interface {
+	InterceptWrite(op *WriteOp) error
+}
+
+// WriteInterceptorFunc 把普通函数适配成 WriteInterceptor。
+type WriteInterceptorFunc func(op *WriteOp) error
+
+func (f WriteInterceptorFunc) InterceptWrite(op *WriteOp) error { return f(op) }
+
+// ReadInterceptor 在 Get / MultiGet / Range / RangeChunks 等返回值之前被调用，可以改写返回的值
+// （例如解密 WriteInterceptor 加密过的值），返回 error 时读取失败。
+// value 可能指向数据库内部的缓冲区，实现不能原地修改它，需要改写时返回新的切片。
+type ReadInterceptor interface {
+	InterceptRead(key string, value []byte) ([]byte, error)
+}
+
+// ReadInterceptorFunc 把普通函数适配成 ReadInterceptor。
+type ReadInterceptorFunc func(key string, value []byte) ([]byte, error)
+
+func (f ReadInterceptorFunc) InterceptRead(key string, value []byte) ([]byte, error) {
+	return f(key, value)
+}
+
+// interceptWrite 依次把 op 交给 Options.WriteInterceptors。
+func (d *DB) interceptWrite(op *WriteOp) error {
+	key := op.Key
+	for _, ic := range d.opts.WriteInterceptors {
+		if err := ic.InterceptWrite(op); err != nil {
+			return err
+		}
+	}
+	op.Key = key
+	return nil
+}
+
+// interceptRecord 把批次里的一条记录（ExpiresAt 还是相对的 ttl）交给 WriteInterceptors，
+// 并按结果改写记录：ttl 从无到有时 OpPut 变成 OpPutTTL，反之亦然。
+func (d *DB) interceptRecord(r *wal.Record) error {
+	if len(d.opts.WriteInterceptors) == 0 {
+		return nil
+	}
+	op := WriteOp{Key: r.Key, Value: r.Value, Delete: r.Op == wal.OpDelete}
+	if r.Op == wal.OpPutTTL {
+		op.TTL = time.Duration(r.ExpiresAt)
+	}
+	if err := d.interceptWrite(&op); err != nil {
+		return err
+	}
+	if r.Op == wal.OpDelete {
+		return nil
+	}
+	r.Value = op.Value
+	if op.TTL > 0 {
+		r.Op, r.ExpiresAt = wal.OpPutTTL, int64(op.TTL)
+	} else {
+		r.Op, r.ExpiresAt = wal.OpPut, 0
+	}
+	return nil
+}
+
+// interceptRead 依次把读到的值交给 Options.ReadInterceptors。
+func (d *DB) interceptRead(key string, value []byte) ([]byte, error) {
+	for _, ic := range d.opts.ReadInterceptors {
+		v, err := ic.InterceptRead(key, value)
+		if err != nil {
+			return nil, err
+		}
+		value = v
+	}
+	return value, nil
+}
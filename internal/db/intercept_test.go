@@ -0,0 +1,155 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// xorCipher 是测试用的“加密”：对 secret: 前缀的 key 的值逐字节异或。
+func xorCipher(key string, value []byte) []byte {
+	if !strings.HasPrefix(key, "secret:") {
+		return value
+	}
+	out := make([]byte, len(value))
+	for i, c := range value {
+		out[i] = c ^ 0x5a
+	}
+	return out
+}
+
+func TestInterceptors(t *testing.T) {
+	errTooLarge := errors.New("value too large")
+	var raw []byte // 最后一次进入 WAL 的值
+	opts := Options{
+		DisableFsync: true,
+		Clock:        NewManualClock(time.Unix(1000, 0)),
+		WriteInterceptors: []WriteInterceptor{
+			WriteInterceptorFunc(func(op *WriteOp) error {
+				if len(op.Value) > 8 {
+					return errTooLarge
+				}
+				if strings.HasPrefix(op.Key, "session:") && op.TTL == 0 && !op.Delete {
+					op.TTL = time.Minute
+				}
+				return nil
+			}),
+			WriteInterceptorFunc(func(op *WriteOp) error {
+				op.Value = xorCipher(op.Key, op.Value)
+				raw = op.Value
+				return nil
+			}),
+		},
+		ReadInterceptors: []ReadInterceptor{
+			ReadInterceptorFunc(func(key string, value []byte) ([]byte, error) {
+				return xorCipher(key, value), nil
+			}),
+		},
+	}
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.Put("big", []byte("0123456789")); !errors.Is(err, errTooLarge) {
+		t.Fatalf("Put(big) = %v, want errTooLarge", err)
+	}
+	if _, ok, _ := d.Get("big"); ok {
+		t.Fatal("rejected write is visible")
+	}
+
+	if err := d.Put("secret:a", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(raw, []byte("hello")) {
+		t.Fatal("value was not transformed before the WAL")
+	}
+	var b Batch
+	b.Put("secret:b", []byte("world"))
+	b.Put("session:1", []byte("s"))
+	b.Put("plain", []byte("p"))
+	if err := d.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	b.Reset()
+	b.Put("ok", []byte("x"))
+	b.Put("big", []byte("0123456789"))
+	if err := d.Write(&b); !errors.Is(err, errTooLarge) {
+		t.Fatalf("Write = %v, want errTooLarge", err)
+	}
+	if _, ok, _ := d.Get("ok"); ok {
+		t.Fatal("rejected batch is partially visible")
+	}
+
+	if ttl, ok, err := d.TTL("session:1"); err != nil || !ok || ttl != time.Minute {
+		t.Fatalf("TTL(session:1) = %v, %v, %v; want 1m", ttl, ok, err)
+	}
+
+	for _, flush := range []bool{false, true} {
+		if flush {
+			if err := d.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for k, want := range map[string]string{"secret:a": "hello", "secret:b": "world", "plain": "p"} {
+			if got, ok, err := d.Get(k); err != nil || !ok || string(got) != want {
+				t.Fatalf("Get(%q) = %q, %v, %v; want %q", k, got, ok, err, want)
+			}
+		}
+		entries, err := d.Range("secret:", "secret;")
+		if err != nil || len(entries) != 2 || string(entries[0].Value) != "hello" || string(entries[1].Value) != "world" {
+			t.Fatalf("Range = %v, %v", entries, err)
+		}
+		for chunk, err := range d.RangeChunks("secret:", "secret;", 1) {
+			if err != nil || len(chunk) != 1 || (string(chunk[0].Value) != "hello" && string(chunk[0].Value) != "world") {
+				t.Fatalf("RangeChunks = %v, %v", chunk, err)
+			}
+		}
+	}
+
+	if err := d.Delete("secret:a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := d.Get("secret:a"); ok {
+		t.Fatal("Delete did not take effect")
+	}
+}
+
+func TestReadInterceptorError(t *testing.T) {
+	errCorrupt := errors.New("cannot decrypt")
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{
+		DisableFsync: true,
+		ReadInterceptors: []ReadInterceptor{
+			ReadInterceptorFunc(func(key string, value []byte) ([]byte, error) {
+				if key == "bad" {
+					return nil, errCorrupt
+				}
+				return value, nil
+			}),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, k := range []string{"bad", "good"} {
+		if err := d.Put(k, []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := d.Get("bad"); !errors.Is(err, errCorrupt) {
+		t.Fatalf("Get(bad) = %v, want errCorrupt", err)
+	}
+	if _, err := d.Range("", ""); !errors.Is(err, errCorrupt) {
+		t.Fatalf("Range = %v, want errCorrupt", err)
+	}
+	if v, ok, err := d.Get("good"); err != nil || !ok || string(v) != "v" {
+		t.Fatalf("Get(good) = %q, %v, %v", v, ok, err)
+	}
+}
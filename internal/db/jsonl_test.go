@@ -0,0 +1,79 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONExportImport(t *testing.T) {
+	root := t.TempDir()
+	clock := NewManualClock(time.Unix(1000, 0))
+	src, err := OpenWithOptions(filepath.Join(root, "src"), Options{DisableFsync: true, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	for k, v := range map[string]string{"a": `{"n":1}`, "b": "\x00\xff binary", "c": "", "z": "out of range"} {
+		if err := src.Put(k, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := src.PutWithTTL("t", []byte("ttl"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Delete("z"); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	n, err := src.ExportJSON(&out, "", "u")
+	if err != nil || n != 4 {
+		t.Fatalf("ExportJSON = %d, %v", n, err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || lines[0] != `{"key":"a","value":"eyJuIjoxfQ=="}` ||
+		lines[3] != `{"key":"t","value":"dHRs","expires_at":4600000000000}` {
+		t.Fatalf("export:\n%s", out.String())
+	}
+
+	dst, err := OpenWithOptions(filepath.Join(root, "dst"), Options{DisableFsync: true, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if err := dst.Put("gone", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	in := out.String() + "\n" + `{"key":"gone","tombstone":true}` + "\n" + `{"key":"old","value":"eA==","expires_at":1}` + "\n"
+	if n, err := dst.ImportJSON(strings.NewReader(in)); err != nil || n != 6 {
+		t.Fatalf("ImportJSON = %d, %v", n, err)
+	}
+
+	for _, k := range []string{"a", "b", "c", "t"} {
+		want, _, _ := src.Get(k)
+		got, ok, err := dst.Get(k)
+		if err != nil || !ok || !bytes.Equal(got, want) {
+			t.Fatalf("Get(%q) = %q, %v, %v; want %q", k, got, ok, err, want)
+		}
+	}
+	if ttl, ok, _ := dst.TTL("t"); !ok || ttl != time.Hour {
+		t.Fatalf("TTL(t) = %v, %v", ttl, ok)
+	}
+	for _, k := range []string{"gone", "old", "z"} {
+		if _, ok, _ := dst.Get(k); ok {
+			t.Fatalf("%q should not exist", k)
+		}
+	}
+
+	_, err = dst.ImportJSON(strings.NewReader(`{"key":"x","value":"eA=="}` + "\n" + `{"key":` + "\n"))
+	if !errors.Is(err, ErrBadJSONLine) || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("malformed line: err = %v", err)
+	}
+	if _, ok, _ := dst.Get("x"); ok {
+		t.Fatal("lines of a failed batch must not be written")
+	}
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// latencyOp 是记录延迟的操作种类。
+type latencyOp int
+
+const (
+	latPut latencyOp = iota
+	latGet
+	latDelete
+	latFlush
+	latCompaction
+	numLatencyOps
+)
+
+var latencyOpNames = [numLatencyOps]string{"put", "get", "delete", "flush", "compaction"}
+
+// 直方图的桶：小于 histSub 纳秒的值每纳秒一个桶，之后 [2^e, 2^(e+1)) 等分成 histSub 个子桶（HDR 风格的对数-线性分桶），
+// 所以任何值落进的桶宽都不超过它的 1/histSub，分位数的相对误差在 6.25% 以内。
+const (
+	histSubBits = 4
+	histSub     = 1 << histSubBits
+	histBuckets = (64 - histSubBits + 1) * histSub
+)
+
+// latencyHistogram 以纳秒为单位记录延迟。每个桶是一个原子计数器，记录不加锁，
+// 读取时各个计数不是同一时刻的快照，对统计来说足够。
+type latencyHistogram struct {
+	counts [histBuckets]atomic.Uint64
+	count  atomic.Uint64
+	sum    atomic.Uint64
+	max    atomic.Uint64
+}
+
+func histBucket(v uint64) int {
+	if v < histSub {
+		return int(v)
+	}
+	e := bits.Len64(v) - 1 // v 在 [2^e, 2^(e+1)) 中，e >= histSubBits
+	sub := (v >> (e - histSubBits)) & (histSub - 1)
+	return (e-histSubBits+1)*histSub + int(sub)
+}
+
+// histUpper 返回桶 i 里最大的值。
+func histUpper(i int) uint64 {
+	if i < histSub {
+		return uint64(i)
+	}
+	e := i/histSub + histSubBits - 1
+	sub := uint64(i % histSub)
+	return (histSub+sub+1)<<(e-histSubBits) - 1
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	v := uint64(max(d, 0))
+	h.counts[histBucket(v)].Add(1)
+	h.count.Add(1)
+	h.sum.Add(v)
+	for {
+		m := h.max.Load()
+		if v <= m || h.max.CompareAndSwap(m, v) {
+			return
+		}
+	}
+}
+
+// LatencyStats 是一种操作的延迟分布。分位数是所在桶的上界，相对误差不超过 6.25%；Max 是精确值。
+type LatencyStats struct {
+	Count uint64
+	Mean  time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+func (h *latencyHistogram) stats() LatencyStats {
+	var counts [histBuckets]uint64
+	var total uint64
+	for i := range counts {
+		counts[i] = h.counts[i].Load()
+		total += counts[i]
+	}
+	if total == 0 {
+		return LatencyStats{}
+	}
+	st := LatencyStats{
+		Count: total,
+		Mean:  time.Duration(h.sum.Load() / max(h.count.Load(), 1)),
+		Max:   time.Duration(h.max.Load()),
+	}
+	quantile := func(q float64) time.Duration {
+		rank := uint64(q * float64(total))
+		var seen uint64
+		for i, c := range counts {
+			seen += c
+			if seen > rank {
+				return min(time.Duration(histUpper(i)), st.Max)
+			}
+		}
+		return st.Max
+	}
+	st.P50, st.P95, st.P99 = quantile(0.50), quantile(0.95), quantile(0.99)
+	return st
+}
+
+// Latencies 是打开数据库以来各种操作的延迟分布（见 Options.LatencyHistograms），未开启时为零值。
+type Latencies struct {
+	Put        LatencyStats // Put / PutWithTTL
+	Get        LatencyStats // Get / GetWithOptions（包括 Context 版本）
+	Delete     LatencyStats
+	Flush      LatencyStats // 所有把 MemTable 写成 SST 的 Flush，包括 Checkpoint、Ingest 触发的
+	Compaction LatencyStats
+}
+
+// latencyRecorder 为 nil 表示没有开启延迟统计：start / observe 什么也不做，不读取时钟。
+type latencyRecorder struct {
+	hists [numLatencyOps]latencyHistogram
+}
+
+// start 返回计时起点。
+func (l *latencyRecorder) start() time.Time {
+	if l == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// observe 记录从 start 返回的 t 到现在的耗时，通常写成 defer d.latency.observe(op, d.latency.start())。
+func (l *latencyRecorder) observe(op latencyOp, t time.Time) {
+	if l == nil {
+		return
+	}
+	l.hists[op].record(time.Since(t))
+}
+
+func (l *latencyRecorder) snapshot() Latencies {
+	if l == nil {
+		return Latencies{}
+	}
+	return Latencies{
+		Put:        l.hists[latPut].stats(),
+		Get:        l.hists[latGet].stats(),
+		Delete:     l.hists[latDelete].stats(),
+		Flush:      l.hists[latFlush].stats(),
+		Compaction: l.hists[latCompaction].stats(),
+	}
+}
+
+// writePrometheus 以 summary 的形式写出每种操作的延迟分位数。
+func (l *latencyRecorder) writePrometheus(w io.Writer) error {
+	if l == nil {
+		return nil
+	}
+	const name = "forgedb_op_latency_seconds"
+	if _, err := fmt.Fprintf(w, "# HELP %s Latency of database operations.\n# TYPE %s summary\n", name, name); err != nil {
+		return err
+	}
+	for op := range numLatencyOps {
+		h := &l.hists[op]
+		st := h.stats()
+		label := latencyOpNames[op]
+		for _, q := range []struct {
+			q string
+			v time.Duration
+		}{{"0.5", st.P50}, {"0.95", st.P95}, {"0.99", st.P99}} {
+			if _, err := fmt.Fprintf(w, "%s{op=%q,quantile=%q} %g\n", name, label, q.q, q.v.Seconds()); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_sum{op=%q} %g\n%s_count{op=%q} %d\n",
+			name, label, time.Duration(h.sum.Load()).Seconds(), name, label, st.Count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogramQuantiles(t *testing.T) {
+	var h latencyHistogram
+	for i := 1; i <= 10000; i++ {
+		h.record(time.Duration(i) * time.Microsecond)
+	}
+	st := h.stats()
+	if st.Count != 10000 || st.Max != 10*time.Millisecond {
+		t.Fatalf("stats = %+v", st)
+	}
+	for _, c := range []struct {
+		got, want time.Duration
+	}{{st.P50, 5 * time.Millisecond}, {st.P95, 9500 * time.Microsecond}, {st.P99, 9900 * time.Microsecond}, {st.Mean, 5000500 * time.Nanosecond}} {
+		if diff := float64(c.got-c.want) / float64(c.want); diff < -0.01 || diff > 1.0/histSub {
+			t.Fatalf("got %v, want about %v (%+v)", c.got, c.want, st)
+		}
+	}
+
+	for _, v := range []uint64{0, 1, 15, 16, 17, 31, 32, 1000, 1 << 40, 1<<63 + 12345} {
+		i := histBucket(v)
+		if v > histUpper(i) || (i > 0 && v <= histUpper(i-1)) {
+			t.Fatalf("%d is in bucket %d = (%d, %d]", v, i, histUpper(i-1), histUpper(i))
+		}
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, LatencyHistograms: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for range 3 {
+		if err := d.Put("k", []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.PutWithTTL("t", []byte("v"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := d.Get("k"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete("k"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	l := d.Stats().Latency
+	for name, c := range map[string]uint64{"put": l.Put.Count, "get": l.Get.Count, "delete": l.Delete.Count, "flush": l.Flush.Count, "compaction": l.Compaction.Count} {
+		want := uint64(1)
+		if name == "put" {
+			want = 4
+		}
+		if c != want {
+			t.Fatalf("%s count = %d, want %d (%+v)", name, c, want, l)
+		}
+	}
+	if l.Flush.Max <= 0 || l.Flush.P99 > l.Flush.Max {
+		t.Fatalf("flush latency = %+v", l.Flush)
+	}
+
+	var buf bytes.Buffer
+	if err := d.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE forgedb_op_latency_seconds summary\n",
+		`forgedb_op_latency_seconds{op="put",quantile="0.99"} `,
+		`forgedb_op_latency_seconds_count{op="put"} 4`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("prometheus output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestLatencyStatsDisabled(t *testing.T) {
+	d, err := OpenWithOptions(t.TempDir(), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Put("k", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if l := d.Stats().Latency; l != (Latencies{}) {
+		t.Fatalf("latency = %+v with histograms disabled", l)
+	}
+	var buf bytes.Buffer
+	if err := d.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "latency") {
+		t.Fatalf("unexpected latency metrics:\n%s", buf.String())
+	}
+}
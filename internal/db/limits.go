@@ -0,0 +1,62 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"monolithdb/internal/sstable"
+)
+
+// ErrKeyTooLarge 表示写入的 key 超过了 Options.MaxKeySize。
+var ErrKeyTooLarge = errors.New("db: key too large")
+
+// ErrValueTooLarge 表示写入的 value 超过了 Options.MaxValueSize。
+var ErrValueTooLarge = errors.New("db: value too large")
+
+const (
+	// MaxKeySizeLimit 是 Options.MaxKeySize 允许的最大值：更长的 key 写进 SST 之后读不出来（见 sstable.MaxKeySize）。
+	MaxKeySizeLimit = sstable.MaxKeySize
+
+	// MaxValueSizeLimit 是 Options.MaxValueSize 允许的最大值：WAL 里单条记录的 key + value 不能超过 1GB。
+	MaxValueSizeLimit = 1<<30 - MaxKeySizeLimit
+
+	// DefaultMaxKeySize / DefaultMaxValueSize 是 Options.MaxKeySize / MaxValueSize 的默认值。
+	DefaultMaxKeySize   = MaxKeySizeLimit
+	DefaultMaxValueSize = 256 << 20
+)
+
+func (o Options) maxKeySize() int {
+	if o.MaxKeySize <= 0 {
+		return DefaultMaxKeySize
+	}
+	return o.MaxKeySize
+}
+
+func (o Options) maxValueSize() int {
+	if o.MaxValueSize <= 0 {
+		return DefaultMaxValueSize
+	}
+	return o.MaxValueSize
+}
+
+// validateSizeLimits 检查 MaxKeySize / MaxValueSize 没有超过引擎能读回的上限。
+func (o Options) validateSizeLimits() error {
+	if o.MaxKeySize > MaxKeySizeLimit {
+		return fmt.Errorf("%w: MaxKeySize %d exceeds %d", ErrInvalidOptions, o.MaxKeySize, MaxKeySizeLimit)
+	}
+	if o.MaxValueSize > MaxValueSizeLimit {
+		return fmt.Errorf("%w: MaxValueSize %d exceeds %d", ErrInvalidOptions, o.MaxValueSize, MaxValueSizeLimit)
+	}
+	return nil
+}
+
+// checkSize 检查一次写入的 key / value 是否超过上限，删除时 value 为 nil。
+func (d *DB) checkSize(key string, value []byte) error {
+	if n := d.opts.maxKeySize(); len(key) > n {
+		return fmt.Errorf("%w: %d bytes (limit %d)", ErrKeyTooLarge, len(key), n)
+	}
+	if n := d.opts.maxValueSize(); len(value) > n {
+		return fmt.Errorf("%w: key %.32q has a %d byte value (limit %d)", ErrValueTooLarge, key, len(value), n)
+	}
+	return nil
+}
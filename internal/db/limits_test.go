@@ -0,0 +1,84 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"monolithdb/internal/types"
+)
+
+func TestSizeLimits(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, MaxKeySize: 8, MaxValueSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	longKey, bigValue := strings.Repeat("k", 9), make([]byte, 17)
+	okKey, okValue := strings.Repeat("k", 8), make([]byte, 16)
+
+	var b Batch
+	b.Put("a", okValue)
+	b.Put("b", bigValue)
+	for name, err := range map[string]error{
+		"Put key":        d.Put(longKey, okValue),
+		"PutWithTTL key": d.PutWithTTL(longKey, okValue, time.Hour),
+		"Delete key":     d.Delete(longKey),
+		"Ingest key":     d.Ingest([]types.Entry{{Key: longKey, Value: okValue}}, "test"),
+	} {
+		if !errors.Is(err, ErrKeyTooLarge) {
+			t.Errorf("%s: got %v, want ErrKeyTooLarge", name, err)
+		}
+	}
+	for name, err := range map[string]error{
+		"Put value":        d.Put(okKey, bigValue),
+		"PutWithTTL value": d.PutWithTTL(okKey, bigValue, time.Hour),
+		"Write value":      d.Write(&b),
+		"Update value":     d.Update(func(tx *UpdateTx) error { return tx.Put(okKey, bigValue) }),
+	} {
+		if !errors.Is(err, ErrValueTooLarge) {
+			t.Errorf("%s: got %v, want ErrValueTooLarge", name, err)
+		}
+	}
+	// 被拒绝的批次整体不生效
+	if _, ok, err := d.Get("a"); err != nil || ok {
+		t.Fatalf("Get(a) = %v, %v after a rejected batch", ok, err)
+	}
+
+	if err := d.Put(okKey, okValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete(okKey); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSizeLimitsAfterInterceptors(t *testing.T) {
+	pad := WriteInterceptorFunc(func(op *WriteOp) error {
+		op.Value = append(op.Value, make([]byte, 10)...)
+		return nil
+	})
+	d, err := OpenWithOptions(t.TempDir(), Options{DisableFsync: true, MaxValueSize: 16, WriteInterceptors: []WriteInterceptor{pad}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Put("k", make([]byte, 6)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("k", make([]byte, 7)); !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("Put = %v, want ErrValueTooLarge", err)
+	}
+}
+
+func TestSizeLimitsValidated(t *testing.T) {
+	for _, opts := range []Options{{MaxKeySize: MaxKeySizeLimit + 1}, {MaxValueSize: MaxValueSizeLimit + 1}} {
+		if _, err := OpenWithOptions(t.TempDir(), opts); !errors.Is(err, ErrInvalidOptions) {
+			t.Fatalf("Open(%+v) = %v, want ErrInvalidOptions", opts, err)
+		}
+	}
+}
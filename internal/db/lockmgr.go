@@ -0,0 +1,107 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLockTimeout 表示等待 key 锁超过了 TxnOptions.LockTimeout。
+	ErrLockTimeout = errors.New("db: lock wait timeout")
+	// ErrDeadlock 表示等待 key 锁会形成环（死锁）。返回该错误的事务应当回滚后重试。
+	ErrDeadlock = errors.New("db: deadlock detected")
+)
+
+// lockManager 管理事务的 key 级排他锁。
+//
+// 一个事务同一时刻最多在等一把锁，所以 wait-for 图里每个事务最多一条出边，
+// 死锁检测只需要沿着 waitFor 链走一遍。
+type lockManager struct {
+	mu sync.Mutex
+
+	owners map[string]*Txn
+	// waitFor[t] 是 t 正在等待的锁的持有者
+	waitFor map[*Txn]*Txn
+	// released[key] 在 key 被释放时关闭，用来唤醒等待者
+	released map[string]chan struct{}
+}
+
+func newLockManager() *lockManager {
+	return &lockManager{
+		owners:   make(map[string]*Txn),
+		waitFor:  make(map[*Txn]*Txn),
+		released: make(map[string]chan struct{}),
+	}
+}
+
+// lock 为 t 获取 key 的锁，已经持有时直接返回。
+func (lm *lockManager) lock(t *Txn, key string, timeout time.Duration) error {
+	var timer <-chan time.Time
+
+	lm.mu.Lock()
+	for {
+		holder, held := lm.owners[key]
+		if !held || holder == t {
+			if !held {
+				lm.owners[key] = t
+				t.locked = append(t.locked, key)
+			}
+			delete(lm.waitFor, t)
+			lm.mu.Unlock()
+			return nil
+		}
+
+		if lm.reaches(holder, t) {
+			delete(lm.waitFor, t)
+			lm.mu.Unlock()
+			return ErrDeadlock
+		}
+		lm.waitFor[t] = holder
+		ch, ok := lm.released[key]
+		if !ok {
+			ch = make(chan struct{})
+			lm.released[key] = ch
+		}
+		lm.mu.Unlock()
+
+		if timer == nil {
+			timer = time.After(timeout)
+		}
+		select {
+		case <-ch:
+		case <-timer:
+			lm.mu.Lock()
+			delete(lm.waitFor, t)
+			lm.mu.Unlock()
+			return ErrLockTimeout
+		}
+		lm.mu.Lock()
+	}
+}
+
+// reaches 判断沿着 from 的 wait-for 链能否走到 to。
+func (lm *lockManager) reaches(from, to *Txn) bool {
+	for t := from; t != nil; t = lm.waitFor[t] {
+		if t == to {
+			return true
+		}
+	}
+	return false
+}
+
+// unlockAll 释放 t 持有的所有锁并唤醒等待者。
+func (lm *lockManager) unlockAll(t *Txn) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	for _, key := range t.locked {
+		delete(lm.owners, key)
+		if ch, ok := lm.released[key]; ok {
+			close(ch)
+			delete(lm.released, key)
+		}
+	}
+	t.locked = nil
+	delete(lm.waitFor, t)
+}
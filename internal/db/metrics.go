@@ -0,0 +1,165 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"monolithdb/internal/wal"
+)
+
+// engineMetrics 是引擎内部的累计计数器。原子变量，读取时不需要持锁。
+type engineMetrics struct {
+	flushes        atomic.Uint64
+	walTruncations atomic.Uint64
+	bytesFlushed   atomic.Uint64
+	memBytesIn     atomic.Uint64
+
+	compactions         atomic.Uint64
+	tombstonesDropped   atomic.Uint64
+	compactionReclaimed atomic.Uint64
+	filterDropped       atomic.Uint64
+	filterReplaced      atomic.Uint64
+	compactionRead      atomic.Uint64
+	compactionWritten   atomic.Uint64
+
+	logicalReads atomic.Uint64
+
+	scrubTables  atomic.Uint64
+	scrubBytes   atomic.Uint64
+	scrubCorrupt atomic.Uint64
+
+	stallSlowdowns atomic.Uint64
+	stallStops     atomic.Uint64
+	stallNanos     atomic.Uint64
+}
+
+// Counters 是打开数据库以来的累计计数快照，At 是快照时间（按 Options.Clock）。
+// 两次快照之差除以时间间隔就是速率，见 RatesSince。
+type Counters struct {
+	At time.Time
+
+	Flushes        uint64 // 成功的 Flush 次数
+	WALTruncations uint64 // WAL 被换成空文件的次数（Flush、回放过滤之后）
+	BytesFlushed   uint64 // Flush 写出的 SST 字节数
+
+	// MemTableBytesWritten 是写入 MemTable 的 key + value 字节数（含 tombstone 的 key），
+	// 与 Stats.MemTableBytes 一起可以估计 MemTable 多久之后会被写满。
+	MemTableBytesWritten uint64
+
+	// 写停顿（见 Options.L0SlowdownTables 等）：被减速的写入次数、被阻塞的写入次数，
+	// 以及写入因此等待的总时间。
+	WriteSlowdowns uint64
+	WriteStops     uint64
+	WriteStallTime time.Duration
+}
+
+// Rates 是两次 Counters 快照之间的平均速率（每秒）。
+type Rates struct {
+	Interval time.Duration
+
+	Flushes        float64
+	WALTruncations float64
+	BytesFlushed   float64
+	MemTableFill   float64 // MemTable 写入字节数
+}
+
+// RatesSince 返回 prev 到 c 之间的平均速率。间隔不为正时返回只有 Interval 的零值。
+func (c Counters) RatesSince(prev Counters) Rates {
+	r := Rates{Interval: c.At.Sub(prev.At)}
+	secs := r.Interval.Seconds()
+	if secs <= 0 {
+		return r
+	}
+	rate := func(cur, old uint64) float64 { return float64(cur-old) / secs }
+	r.Flushes = rate(c.Flushes, prev.Flushes)
+	r.WALTruncations = rate(c.WALTruncations, prev.WALTruncations)
+	r.BytesFlushed = rate(c.BytesFlushed, prev.BytesFlushed)
+	r.MemTableFill = rate(c.MemTableBytesWritten, prev.MemTableBytesWritten)
+	return r
+}
+
+// Counters 返回当前的累计计数。
+func (d *DB) Counters() Counters {
+	return Counters{
+		At:                   d.opts.clock().Now(),
+		Flushes:              d.metrics.flushes.Load(),
+		WALTruncations:       d.metrics.walTruncations.Load(),
+		BytesFlushed:         d.metrics.bytesFlushed.Load(),
+		MemTableBytesWritten: d.metrics.memBytesIn.Load(),
+		WriteSlowdowns:       d.metrics.stallSlowdowns.Load(),
+		WriteStops:           d.metrics.stallStops.Load(),
+		WriteStallTime:       time.Duration(d.metrics.stallNanos.Load()),
+	}
+}
+
+// WritePrometheus 以 Prometheus 文本格式（0.0.4）写出计数器和当前的 MemTable / SST 状态，
+// 开启 Options.LatencyHistograms 时还有各操作的延迟分位数。速率由 Prometheus 端用 rate() 计算。
+func (d *DB) WritePrometheus(w io.Writer) error {
+	c := d.Counters()
+	st := d.Stats()
+	rec := d.Recovery()
+	disk, err := d.DiskSize()
+	if err != nil {
+		return err
+	}
+
+	metrics := []struct {
+		name, typ, help string
+		value           float64
+	}{
+		{"forgedb_flushes_total", "counter", "Number of completed memtable flushes.", float64(c.Flushes)},
+		{"forgedb_wal_truncations_total", "counter", "Number of times the WAL was replaced by an empty file.", float64(c.WALTruncations)},
+		{"forgedb_flushed_bytes_total", "counter", "Bytes of SST written by flushes.", float64(c.BytesFlushed)},
+		{"forgedb_memtable_written_bytes_total", "counter", "Key and value bytes written into the memtable.", float64(c.MemTableBytesWritten)},
+		{"forgedb_memtable_bytes", "gauge", "Approximate key and value bytes currently in the memtable.", float64(st.MemTableBytes)},
+		{"forgedb_memtable_entries", "gauge", "Entries currently in the memtable, including tombstones.", float64(st.MemTableEntries)},
+		{"forgedb_compactions_total", "counter", "Number of completed compactions.", float64(st.Compaction.Compactions)},
+		{"forgedb_compaction_tombstones_dropped_total", "counter", "Tombstones garbage-collected by compactions.", float64(st.Compaction.TombstonesDropped)},
+		{"forgedb_compaction_reclaimed_bytes_total", "counter", "SST bytes reclaimed by compactions.", float64(st.Compaction.ReclaimedBytes)},
+		{"forgedb_compaction_written_bytes_total", "counter", "Bytes of SST written by compactions.", float64(st.Amplification.CompactionBytesWritten)},
+		{"forgedb_compaction_read_bytes_total", "counter", "Bytes read from SST files by compactions.", float64(st.Amplification.CompactionBytesRead)},
+		{"forgedb_logical_reads_total", "counter", "Point lookups plus entries returned by range reads.", float64(st.Amplification.LogicalReads)},
+		{"forgedb_sst_read_bytes_total", "counter", "Bytes read from SST files to serve reads.", float64(st.Amplification.SSTBytesRead)},
+		{"forgedb_sstables", "gauge", "Number of live SST files.", float64(st.NumSSTables)},
+		{"forgedb_live_sst_bytes", "gauge", "Bytes of live SST files.", float64(disk.LiveSSTBytes)},
+		{"forgedb_obsolete_sst_bytes", "gauge", "Bytes of replaced SST files kept for open readers and snapshots.", float64(disk.ObsoleteSSTBytes)},
+		{"forgedb_wal_bytes", "gauge", "Bytes of WAL files, including immutable memtable and retained segments.", float64(disk.WALBytes)},
+		{"forgedb_value_log_bytes", "gauge", "Bytes of value log files.", float64(disk.ValueLogBytes)},
+		{"forgedb_lost_bytes", "gauge", "Bytes of files quarantined under lost/ by repair and recovery.", float64(disk.LostBytes)},
+		{"forgedb_scrub_checked_tables_total", "counter", "Tables fully re-read by the background scrubber.", float64(st.Scrub.TablesChecked)},
+		{"forgedb_scrub_checked_bytes_total", "counter", "Bytes re-read by the background scrubber.", float64(st.Scrub.BytesChecked)},
+		{"forgedb_scrub_corrupt_tables_total", "counter", "Corrupt tables found by the background scrubber.", float64(st.Scrub.CorruptTables)},
+		{"forgedb_write_slowdowns_total", "counter", "Writes delayed because the backlog exceeded a soft limit.", float64(c.WriteSlowdowns)},
+		{"forgedb_write_stops_total", "counter", "Writes blocked because the backlog exceeded a hard limit.", float64(c.WriteStops)},
+		{"forgedb_recovery_discarded_bytes", "gauge", "Bytes of corrupt WAL tail discarded when the database was opened.", float64(rec.DiscardedBytes)},
+		{"forgedb_write_stall_seconds_total", "counter", "Time writes spent delayed or blocked by write stalls.", c.WriteStallTime.Seconds()},
+	}
+	for _, m := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", m.name, m.help, m.name, m.typ, m.name, m.value); err != nil {
+			return err
+		}
+	}
+	return d.latency.writePrometheus(w)
+}
+
+// recordBytes 返回一条记录写入 MemTable 的 key + value 字节数。
+func recordBytes(r wal.Record) uint64 {
+	switch r.Op {
+	case wal.OpTouch:
+		var n int
+		for _, k := range r.Keys {
+			n += len(k)
+		}
+		return uint64(n)
+	case wal.OpBatch:
+		var n uint64
+		for _, sub := range r.Batch {
+			n += recordBytes(sub)
+		}
+		return n
+	default:
+		return uint64(len(r.Key) + len(r.Value))
+	}
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountersAndRates(t *testing.T) {
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{Clock: clock, DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	start := d.Counters()
+	if err := d.Put("ab", []byte("1234")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete("cd"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil { // MemTable 为空，不计数
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Second)
+
+	c := d.Counters()
+	if c.Flushes != 1 || c.WALTruncations != 1 || c.MemTableBytesWritten != 8 || c.BytesFlushed == 0 {
+		t.Fatalf("counters = %+v", c)
+	}
+	r := c.RatesSince(start)
+	if r.Interval != 2*time.Second || r.Flushes != 0.5 || r.MemTableFill != 4 {
+		t.Fatalf("rates = %+v", r)
+	}
+	if r := c.RatesSince(c); r.Flushes != 0 {
+		t.Fatalf("zero interval rates = %+v", r)
+	}
+
+	var buf bytes.Buffer
+	if err := d.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE forgedb_flushes_total counter\nforgedb_flushes_total 1\n",
+		"forgedb_memtable_written_bytes_total 8\n",
+		"forgedb_sstables 1\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("prometheus output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestAmplificationStats(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	value := bytes.Repeat([]byte("v"), 100)
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 100; i++ {
+			if err := d.Put(fmt.Sprintf("k%03d", i), value); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := d.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	amp := d.Stats().Amplification
+	if amp.UserBytesWritten != 200*104 || amp.FlushBytesWritten == 0 || amp.CompactionBytesWritten != 0 {
+		t.Fatalf("after flushes: %+v", amp)
+	}
+	flushAmp := amp.WriteAmp()
+
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	amp = d.Stats().Amplification
+	if amp.CompactionBytesWritten == 0 || amp.CompactionBytesRead == 0 || amp.WriteAmp() <= flushAmp {
+		t.Fatalf("after compaction: %+v, write amp %.2f (was %.2f)", amp, amp.WriteAmp(), flushAmp)
+	}
+	// compaction 读取的字节不算作用户读取
+	if amp.SSTBytesRead != 0 || amp.LogicalReads != 0 || amp.ReadAmp() != 0 {
+		t.Fatalf("reads before any Get: %+v", amp)
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, ok, err := d.Get(fmt.Sprintf("k%03d", i)); err != nil || !ok {
+			t.Fatal(ok, err)
+		}
+	}
+	entries, err := d.Range("k050", "k060")
+	if err != nil || len(entries) != 10 {
+		t.Fatal(len(entries), err)
+	}
+	amp = d.Stats().Amplification
+	if amp.LogicalReads != 20 || amp.SSTBytesRead == 0 || amp.ReadAmp() != float64(amp.SSTBytesRead)/20 {
+		t.Fatalf("after reads: %+v", amp)
+	}
+}
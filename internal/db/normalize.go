@@ -0,0 +1,76 @@
+package db
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// KeyNormalizer 在 key 进入数据库之前把它规范化（例如统一大小写、去掉首尾空白、Unicode NFC）。
+// 配置在 Options.KeyNormalizer 上之后，所有写入和读取路径都先经过它，调用方不必在每个调用点各自处理。
+//
+// 数据一旦写入，规范化规则就不能再更换：Name 会记录在 manifest 里，打开时校验。
+type KeyNormalizer interface {
+	// Name 是规则的唯一名称。
+	Name() string
+
+	// Normalize 返回 key 的规范形式。必须是幂等的：Normalize(Normalize(k)) == Normalize(k)。
+	Normalize(key string) string
+}
+
+type funcNormalizer struct {
+	name string
+	fn   func(string) string
+}
+
+func (n funcNormalizer) Name() string                { return n.name }
+func (n funcNormalizer) Normalize(key string) string { return n.fn(key) }
+
+// NewKeyNormalizer 用名称和函数构造一个 KeyNormalizer。
+func NewKeyNormalizer(name string, fn func(key string) string) KeyNormalizer {
+	return funcNormalizer{name: name, fn: fn}
+}
+
+// 内置的规范化规则。
+var (
+	// LowercaseKeys 把 key 转成小写（strings.ToLower）。
+	LowercaseKeys = NewKeyNormalizer("forgedb.Lowercase", strings.ToLower)
+	// TrimSpaceKeys 去掉 key 首尾的空白（strings.TrimSpace）。
+	TrimSpaceKeys = NewKeyNormalizer("forgedb.TrimSpace", strings.TrimSpace)
+	// NFCKeys 把 key 转成 Unicode NFC 形式，字形相同但码点序列不同的 key 会被视为同一个。
+	NFCKeys = NewKeyNormalizer("forgedb.NFC", norm.NFC.String)
+)
+
+// ChainKeyNormalizers 按顺序依次应用 ns，名称是各自名称用 "+" 连接。
+func ChainKeyNormalizers(ns ...KeyNormalizer) KeyNormalizer {
+	names := make([]string, len(ns))
+	for i, n := range ns {
+		names[i] = n.Name()
+	}
+	return NewKeyNormalizer(strings.Join(names, "+"), func(key string) string {
+		for _, n := range ns {
+			key = n.Normalize(key)
+		}
+		return key
+	})
+}
+
+// normKey 按 Options.KeyNormalizer 规范化 key；没有配置时原样返回。
+func (d *DB) normKey(key string) string {
+	if d.opts.KeyNormalizer == nil {
+		return key
+	}
+	return d.opts.KeyNormalizer.Normalize(key)
+}
+
+// normKeys 规范化一组 key；没有配置时直接返回 keys，否则返回新的切片，不修改调用方的数据。
+func (d *DB) normKeys(keys []string) []string {
+	if d.opts.KeyNormalizer == nil {
+		return keys
+	}
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		out[i] = d.opts.KeyNormalizer.Normalize(k)
+	}
+	return out
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyNormalizer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	norm := ChainKeyNormalizers(TrimSpaceKeys, LowercaseKeys, NFCKeys)
+	opts := Options{DisableFsync: true, KeyNormalizer: norm}
+
+	d, err := OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("  User:Alice ", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	var b Batch
+	b.Put("USER:BOB", []byte("2"))
+	b.Put("user:cafe\u0301", []byte("3")) // e + 组合重音符，NFC 之后是 é
+	if err := d.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	err = d.Update(func(tx *UpdateTx) error {
+		v, ok, err := tx.Get("user:bob")
+		if err != nil || !ok {
+			return errors.New("Update does not see normalized key")
+		}
+		return tx.Put("User:Carol", v)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, want := range map[string]string{"user:alice": "1", "User:Bob": "2", "user:caf\u00e9": "3", " USER:CAROL": "2"} {
+		if v, ok, err := d.Get(k); err != nil || !ok || string(v) != want {
+			t.Fatalf("Get(%q) = %q, %v, %v", k, v, ok, err)
+		}
+	}
+	entries, err := d.Range("USER:", "USER:C")
+	if err != nil || len(entries) != 2 || entries[0].Key != "user:alice" || entries[1].Key != "user:bob" {
+		t.Fatalf("Range = %+v, %v", entries, err)
+	}
+	if err := d.Delete("USER:ALICE"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := d.Get("user:alice"); ok {
+		t.Fatal("Delete did not normalize its key")
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 规范化规则记录在 manifest 里：换成别的规则或者不设置都不能打开
+	if _, err := OpenWithOptions(dir, Options{DisableFsync: true, KeyNormalizer: LowercaseKeys}); !errors.Is(err, ErrIncompatibleOptions) {
+		t.Fatalf("different normalizer: err = %v", err)
+	}
+	if _, err := OpenWithOptions(dir, Options{DisableFsync: true}); !errors.Is(err, ErrIncompatibleOptions) {
+		t.Fatalf("missing normalizer: err = %v", err)
+	}
+	d, err = OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if v, ok, _ := d.Get("User:Carol"); !ok || string(v) != "2" {
+		t.Fatalf("after reopen Get = %q, %v", v, ok)
+	}
+}
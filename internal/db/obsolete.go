@@ -0,0 +1,109 @@
+package db
+
+import (
+	"log"
+	"os"
+
+	"monolithdb/internal/vfs"
+)
+
+// 文件的生命周期：compaction 把输入表从当前 version 中去掉之后，表记为 obsolete，
+// 但正在使用它的 Reader（见 versionSet.reader）和固定住它的 Snapshot 还可以继续读；
+// 最后一个引用放开之后才关闭缓存的 Reader、删除文件。Open 时删除上次崩溃留下的、不属于任何 version 的文件。
+
+// ref 为 paths 中的每张表增加一个引用。调用方持有 DB 的锁（读锁即可），paths 取自当前 version。
+func (vs *versionSet) ref(paths ...string) {
+	vs.readerMu.Lock()
+	defer vs.readerMu.Unlock()
+	for _, p := range paths {
+		vs.refs[p]++
+	}
+}
+
+// unref 放开 paths 中每张表的一个引用。有表已经 obsolete 并且不再被引用时删除它；
+// 删除失败时留到下一次 removeObsolete（compaction 结束、Close）再试。
+func (vs *versionSet) unref(paths ...string) {
+	idle := false
+	vs.readerMu.Lock()
+	for _, p := range paths {
+		vs.refs[p]--
+		if vs.refs[p] <= 0 {
+			delete(vs.refs, p)
+			idle = idle || vs.obsolete[p]
+		}
+	}
+	vs.readerMu.Unlock()
+
+	if idle {
+		if err := vs.removeObsolete(); err != nil {
+			log.Printf("forgedb: remove obsolete table: %v", err)
+		}
+	}
+}
+
+// removeObsolete 删除所有已经没有读取在使用的 obsolete 表：先关闭缓存的 Reader，再删除文件。
+// 删除失败的表留在 obsolete 里，返回遇到的第一个错误。
+func (vs *versionSet) removeObsolete() error {
+	if vs.numObsolete() > 0 {
+		if err := vs.persistFileNumber(); err != nil {
+			return err
+		}
+	}
+	vs.readerMu.Lock()
+	defer vs.readerMu.Unlock()
+
+	var first error
+	for p := range vs.obsolete {
+		if vs.refs[p] > 0 {
+			continue
+		}
+		if r, ok := vs.readers[p]; ok {
+			_ = r.Close()
+			delete(vs.readers, p)
+		}
+		if err := vs.fs.Remove(p); err != nil && !os.IsNotExist(err) {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		delete(vs.obsolete, p)
+		vs.seqMu.Lock()
+		delete(vs.maxSeqs, p)
+		vs.seqMu.Unlock()
+	}
+	return first
+}
+
+// numObsolete 返回已经被替换、还在等待删除的表数。
+func (vs *versionSet) numObsolete() int {
+	vs.readerMu.Lock()
+	defer vs.readerMu.Unlock()
+	return len(vs.obsolete)
+}
+
+// obsoleteTables 返回还在等待删除的表。
+func (vs *versionSet) obsoleteTables() []string {
+	vs.readerMu.Lock()
+	defer vs.readerMu.Unlock()
+	paths := make([]string, 0, len(vs.obsolete))
+	for p := range vs.obsolete {
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// removeOrphanFiles 删除 sstDir 下写到一半的表（Flush / compaction / ingest 在 rename 之前崩溃留下的 .tmp 文件）。
+// 它们不属于任何 version，也不会再被用到。
+func removeOrphanFiles(fs vfs.FS, sstDir string) error {
+	list, err := vfs.Glob(fs, sstDir, "*.sst.tmp")
+	if err != nil {
+		return err
+	}
+	for _, p := range list {
+		if err := fs.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
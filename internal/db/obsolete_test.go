@@ -0,0 +1,105 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestObsoleteTableKeptWhileInUse(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, k := range []string{"a", "b"} {
+		if err := d.Put(k, []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inputs := d.versions.current().tables
+	busy, idle := inputs[0], inputs[1]
+
+	// 模拟一个还没结束的读取：compaction 之后它依然可以读原来的表
+	r, release, err := d.versions.reader(busy, d.sstReadOptions(ReadOptions{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(idle); !os.IsNotExist(err) {
+		t.Fatalf("unused input %s not removed: %v", idle, err)
+	}
+	if _, err := os.Stat(busy); err != nil {
+		t.Fatalf("input in use removed: %v", err)
+	}
+	if n, _ := d.GetIntProperty(PropNumObsoleteSSTables); n != 1 {
+		t.Fatalf("obsolete sstables = %d, want 1", n)
+	}
+	if v, res, err := r.GetEntryWithOptions("b", d.sstReadOptions(ReadOptions{})); err != nil || string(v.Value) != "b" {
+		t.Fatalf("read from obsolete table = %q %v %v", v.Value, res, err)
+	}
+
+	// 最后一个使用者放开之后删除
+	release()
+	if _, err := os.Stat(busy); !os.IsNotExist(err) {
+		t.Fatalf("obsolete table %s not removed after release: %v", busy, err)
+	}
+	if n, _ := d.GetIntProperty(PropNumObsoleteSSTables); n != 0 {
+		t.Fatalf("obsolete sstables = %d after release", n)
+	}
+	if v, ok, err := d.Get("b"); err != nil || !ok || string(v) != "b" {
+		t.Fatalf("Get(b) = %q %v %v", v, ok, err)
+	}
+}
+
+func TestOpenRemovesOrphanFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("a", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// rename 之前崩溃留下的半张表
+	orphan := filepath.Join(dir, sstDirName, "000099.sst.tmp")
+	if err := os.WriteFile(orphan, []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 只读打开不删除任何文件
+	ro, err := OpenWithOptions(dir, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ro.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(orphan); err != nil {
+		t.Fatalf("read-only Open removed %s: %v", orphan, err)
+	}
+
+	d, err = OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Fatalf("orphan %s not removed: %v", orphan, err)
+	}
+	if v, ok, err := d.Get("a"); err != nil || !ok || string(v) != "a" {
+		t.Fatalf("Get(a) = %q %v %v", v, ok, err)
+	}
+}
@@ -0,0 +1,435 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"monolithdb/internal/encrypt"
+	"monolithdb/internal/manifest"
+	"monolithdb/internal/sstable"
+	"monolithdb/internal/types"
+	"monolithdb/internal/vfs"
+	"monolithdb/internal/wal"
+)
+
+// formatVersion 是当前引擎写出的磁盘格式版本（WAL / SST 编码方式）。
+//
+//	1: 初始格式
+//	2: SST 增加 properties 区，footer 扩展为 24 字节
+//	3: SST record 的 tomb 字节改为 flags，支持过期时间；WAL 增加 PutTTL / Touch 记录
+//	4: SST flags / WAL op 增加“空 value”标记，区分 []byte{} 与 nil
+//	5: WAL 增加 Batch 记录
+//	6: SST record 增加提交序号（flags 标记），properties 增加 max-seq
+//	7: SST record 的 value 可以压缩（flags 标记 + codec id），properties 增加 compression
+//	8: SST footer 增加表格式版本和 footer magic，扩展为 36 字节
+//	9: SST 整个文件可以加密（encrypt 文件格式），WAL 增加 Sealed（加密）记录
+//	10: WAL 增加 Compressed（DEFLATE 压缩）记录
+//	11: SST record 可以是值日志引用（flags 标记），properties 增加值日志引用统计；增加 vlog/ 目录
+//	12: WAL 增加 Snappy 压缩记录，WALCompression 不再写 DEFLATE 记录
+//
+// 每个版本都只是在之前的格式上追加，当前引擎能读取所有更早版本写出的库；manifest 里的版本更新时拒绝打开。
+const formatVersion uint32 = 12
+
+// DefaultComparatorName 是默认按字节序比较 key 的比较器名称。
+const DefaultComparatorName = "forgedb.BytewiseComparator"
+
+// ErrIncompatibleOptions 表示打开已有数据库时传入的配置与 manifest 中记录的不一致。
+var ErrIncompatibleOptions = errors.New("db: incompatible options")
+
+// ErrInvalidOptions 表示配置项本身的组合不合法。
+var ErrInvalidOptions = errors.New("db: invalid options")
+
+// Options 是 Open 时的配置项。零值即默认配置。
+type Options struct {
+	// ComparatorName 是 key 比较器的名称，空表示 DefaultComparatorName。
+	ComparatorName string
+
+	// Comparer 决定 key 的顺序（MemTable、SST 索引、Range、compaction 都按它排序），
+	// nil 表示按字节序。设置后以 Comparer.Name() 作为比较器名称，忽略 ComparatorName。
+	Comparer types.Comparer
+
+	// PrefixExtractorName 是前缀提取器的名称，空表示未配置。
+	PrefixExtractorName string
+
+	// MergeOperatorName 是合并算子的名称，空表示未配置。
+	MergeOperatorName string
+
+	// KeyNormalizer 不为 nil 时，所有读写接口收到的 key 都先经过它规范化再使用（包括 Batch、事务、
+	// Update 里的 key，以及 Range 的边界、Watch 和 SetCompactionHint 的前缀）。
+	// 名称记录在 manifest 里，之后打开时必须使用同名的规则；nil 表示 key 原样使用。
+	KeyNormalizer KeyNormalizer
+
+	// WriteInterceptors 按顺序处理每一次写入（校验、改写、拒绝），见 WriteInterceptor；
+	// ReadInterceptors 按顺序处理读取返回的值，见 ReadInterceptor。
+	// 二者一起可以在不修改 Put / Get 的前提下实现按前缀加密、格式校验之类的横切逻辑。
+	WriteInterceptors []WriteInterceptor
+	ReadInterceptors  []ReadInterceptor
+
+	// ReadOnly 为 true 时以只读方式打开：不创建文件、不写 manifest / WAL，
+	// 所有写操作返回 ErrReadOnly。适合在另一个进程之外查看数据。
+	ReadOnly bool
+
+	// DisableFsync 为 true 时 Flush / Compact 写 SST（表文件和 sst/ 目录）、Close 关闭 WAL 都不做 fsync。
+	// 只应该在测试中使用：崩溃后可能丢失已经 Flush 的数据。
+	DisableFsync bool
+
+	// FlushOnClose 为 true 时 Close 会先把 MemTable 刷成 SST，
+	// 干净关闭之后数据全部在 SST 里，下次打开不需要回放 WAL。
+	FlushOnClose bool
+
+	// ReadCacheBytes 是 key -> value 读缓存的容量（字节），0 表示不启用。
+	// 只缓存从 SST 读到的值；Put / Delete 会让对应 key 失效。
+	ReadCacheBytes int64
+
+	// MaxKeys / MaxBytes 开启有界模式：live key 数或 key+value 总字节数超过上限时，
+	// 后台按 Eviction 策略删除旧 key，直到回到上限以内。0 表示不限制。
+	// 上限是软限制，写入不会因此阻塞；只读模式下忽略。
+	MaxKeys  int
+	MaxBytes int64
+
+	// Eviction 是有界模式的淘汰策略，nil 表示 NewLRUEviction()。
+	Eviction EvictionPolicy
+
+	// WALRetentionSegments 是 Flush 后保留的旧 WAL 段个数，供 Changes 读取历史变更。
+	// 0 表示不保留：Changes 只能读到最近一次 Flush 之后的变更。
+	WALRetentionSegments int
+
+	// WALArchiveDir 不为空时，超出 WALRetentionSegments 的旧 WAL 段不删除，而是移动到这个目录，
+	// 文件名 <first>-<last>.log 标出段内记录的序号范围。配合备份用 ReplayWALArchive 可以恢复到任意序号。
+	WALArchiveDir string
+
+	// WALArchiveHook 不为 nil 时，旧 WAL 段在删除（或移动到 WALArchiveDir）之前交给它，例如上传到对象存储。
+	// 返回错误时这个段保留在原处，Flush 返回该错误，下次 Flush 时重试。
+	WALArchiveHook func(ArchivedWAL) error
+
+	// CompactionFilterFactory 不为 nil 时，每次 Compact 调用它创建一个 CompactionFilter，
+	// 由 filter 决定每条记录是保留、丢弃还是改写。
+	CompactionFilterFactory func() CompactionFilter
+
+	// IgnoreFilters 为 true 时读 SST 不使用 bloom filter（见 DB.SetIgnoreFilters）。
+	IgnoreFilters bool
+
+	// WALReplayFilter 在 Open 回放 WAL 时观察 / 改写记录，只用于紧急恢复，
+	// 必须同时设置 UnsafeRecovery，否则 Open 返回 ErrInvalidOptions。
+	// filter 丢弃或改写过记录时，Open 会立即 Flush 把结果持久化（只读模式下只影响内存）。
+	WALReplayFilter WALReplayFilter
+
+	// UnsafeRecovery 明确允许使用会改变已提交数据的恢复手段（目前只有 WALReplayFilter）。
+	UnsafeRecovery bool
+
+	// TolerateCorruptWALTail 为 true 时，WAL 尾部无法解析（例如写到一半掉电）不会让 Open 失败：
+	// 回放损坏位置之前的记录，原始 WAL 备份到 lost/ 后截断。丢弃的字节数见 DB.Recovery。
+	TolerateCorruptWALTail bool
+
+	// ParanoidChecks 为 true 时用速度换取尽早发现损坏：Open 时校验每个 SST 的 footer / 索引 / bloom
+	// 以及所有数据块的校验和，并完整解析保留的 WAL 段（活跃 WAL 总是完整解析），任何损坏都让 Open 失败；
+	// 之后每次读 SST（包括 compaction）都先校验读到的数据块（见 sstable.ReadOptions.VerifyChecksums）。
+	// 不能与 TolerateCorruptWALTail 同时使用。
+	ParanoidChecks bool
+
+	// RecoveryAlert 在 Open 的恢复丢失或改动了数据时（RecoveryReport.Lossy）被调用一次，
+	// 用于接入告警；nil 表示只写日志。
+	RecoveryAlert func(RecoveryReport)
+
+	// Clock 是时间来源，nil 表示系统时钟。
+	Clock Clock
+
+	// BlockSize 是 Flush / Compact 写出的 SST 中一个块（两个索引项之间）的目标字节数。
+	// 0 表示根据每张表的记录大小分布自动选择（sstable.AdaptiveBlockSize）。
+	BlockSize int
+
+	// MaxRangeBytes 是 Range 一次返回的 key+value 总字节数上限，超过时返回 ErrRangeTooLarge。
+	// 0 表示 DefaultMaxRangeBytes，负数表示不限制。RangeChunks 不受此限制。
+	MaxRangeBytes int64
+
+	// RateLimiter 限制 Flush / Compact 写 SST 的速度（字节/秒），nil 表示不限速。
+	// 可以在多个 DB 之间共享同一个 RateLimiter。
+	RateLimiter *RateLimiter
+
+	// Compression 是 Flush / Compact 写 SST 时压缩 value 的算法名称，空表示不压缩。
+	// 内置 "flate"，其它算法先用 sstable.RegisterCodec 注册；读表时按记录里的 codec id 解压，
+	// 所以之后更换算法不影响已有的表。名称未注册时 Open 返回 ErrInvalidOptions。
+	Compression string
+
+	// CompressionDictBytes 大于 0 时，合并到最旧一层（bottommost）的 Compact 会从输出的 value 中抽样，
+	// 训练一个不超过这么多字节的压缩字典（最大 sstable.MaxDictSize），随表保存并用它压缩 value。
+	// 对大量小而相似的 value 效果明显。此时 Compression 必须为空或 "flate"。
+	CompressionDictBytes int
+
+	// 写停顿：Flush 比 compaction 快时 SST 越积越多，读路径会无限变慢。
+	// SST 个数达到 L0SlowdownTables、或 MemTable 达到 MemTableSlowdownBytes 时，
+	// 每次写入先睡眠 WriteSlowdownDelay（0 表示 DefaultWriteSlowdownDelay）；
+	// 达到 L0StopTables / MemTableStopBytes 时写入阻塞，直到 Flush / Compact 让积压回到硬限制以下。
+	// 0 表示不限制。阻塞的写入只能由其他 goroutine 调用 Flush / Compact（或 MaybeCompact）解除。
+	L0SlowdownTables      int
+	L0StopTables          int
+	MemTableSlowdownBytes int64
+	MemTableStopBytes     int64
+	WriteSlowdownDelay    time.Duration
+
+	// Encryption 不为 nil 时开启静态数据加密：Flush / Compact 写出的 SST 整个文件用活跃密钥加密，
+	// 新追加的 WAL 记录逐条加密（AES-GCM，见 encrypt 包）。读取时按文件 / 记录里的密钥 ID 找密钥，
+	// 所以开启之前写的明文数据和用旧密钥写的数据依然可读。
+	//
+	// 轮换密钥：先让 KeyProvider 返回新的活跃密钥，之后的写入都使用新密钥；再调用 Compact
+	// 把所有表重写一遍，TableKeyIDs 里不再出现旧密钥 ID 之后才能从 KeyProvider 中删掉它。
+	Encryption encrypt.KeyProvider
+
+	// TableStatsSampleRate 大于 0 时统计每张 SST 被点查访问的次数，每 TableStatsSampleRate 次点查采样一次
+	// （计数按采样率放大，1 表示逐次统计）。统计随 Flush / Compact / Close 保存在数据目录的 TABLESTATS 文件里，
+	// 重启后接着累加，compaction 的输出表继承输入表的计数，按冷热做决策时不必从零重新学习。
+	// 通过 TableAccessStats 读取；0 表示不统计。
+	TableStatsSampleRate int
+
+	// WALCompression 为 true 时，较大的 WAL 记录（value 区至少 wal.CompressMinSize 字节）先用 snappy
+	// 压缩再写入，用一点 CPU 换 WAL 写带宽，适合 value 是大块 JSON 之类可压缩数据的场景。
+	// 回放时总是能识别压缩记录，所以可以随时打开或关闭。
+	WALCompression bool
+
+	// ValueLogThreshold 大于 0 时开启值日志（key / value 分离）：Flush / Ingest 写 SST 时，
+	// 不小于这么多字节的 value 写进 vlog/ 下的值日志文件，SST 里只保存 20 字节的引用，
+	// compaction 不再反复重写大 value，代价是读这些 value 多一次随机读。0 表示不分离。
+	// 关闭之后已经分离的 value 依然可读。不能与 Encryption 同时使用（值日志不加密）。
+	ValueLogThreshold int
+
+	// ValueLogGCRatio 是值日志文件中失效数据的比例达到多少时重写它（见 DB.ValueLogGC），
+	// 0 表示 DefaultValueLogGCRatio。
+	ValueLogGCRatio float64
+
+	// TombstoneCompactionRatio 是一张表中 tombstone 占记录数的比例达到多少时，MaybeCompact 即使
+	// 表的个数还不多也合并它（见 MaybeCompact），0 表示 DefaultTombstoneCompactionRatio，
+	// 负数表示不按 tombstone 比例选择。
+	TombstoneCompactionRatio float64
+
+	// LatencyHistograms 为 true 时把 Put / Get / Delete / Flush / compaction 的耗时记录进直方图，
+	// 通过 Stats().Latency 读取分位数（p50 / p95 / p99 / max）。每次操作多读两次时钟、做几次原子加法；
+	// 关闭（默认）时不读时钟。
+	LatencyHistograms bool
+
+	// MaxKeySize / MaxValueSize 是单个 key / value 的最大字节数，0 表示 DefaultMaxKeySize / DefaultMaxValueSize。
+	// Put / PutWithTTL / Delete / Write（包括事务、Update）和 Ingest 超过时返回 ErrKeyTooLarge / ErrValueTooLarge，
+	// 什么也不写；检查的是经过 KeyNormalizer 和 WriteInterceptors 之后真正写入的 key / value。
+	// ApplyRecord 应用的是已经在主节点检查过的记录，不再检查。
+	// 超过 MaxKeySizeLimit / MaxValueSizeLimit 时 Open 返回 ErrInvalidOptions。
+	MaxKeySize   int
+	MaxValueSize int
+
+	// ValueLogGCInterval 大于 0 时在后台每隔这么久调用一次 DB.ValueLogGC；只读模式下忽略。
+	ValueLogGCInterval time.Duration
+
+	// MemTableSize 大于 0 时，MemTable 的 key + value 字节数达到它之后的下一次写入会先为自己腾出空间：
+	// MaxImmutableMemtables 为 0 时同步 Flush；否则把 MemTable 冻结进不可变队列，由后台写成 SST，
+	// 写入不必等待，只有队列里已经有 MaxImmutableMemtables 个 MemTable 时才阻塞，直到后台写出一个。
+	// 读取按 MemTable -> 不可变 MemTable（newest first）-> SST 的顺序查找。
+	// 0 表示不自动 Flush（默认）。
+	MemTableSize          int64
+	MaxImmutableMemtables int
+
+	// FlushInterval 大于 0 时在后台每隔这么久把非空的 MemTable 刷成 SST（同时换成空的 WAL），
+	// 写得很少的数据库崩溃后需要回放的 WAL 也不会超过大约一个周期的写入；只读模式下忽略。
+	FlushInterval time.Duration
+
+	// BloomBitsPerKey / BloomFalsePositiveRate / BloomHashes 是 Flush / Compact / Ingest 写 SST 时
+	// bloom filter 的参数（见 sstable.WriterOptions），全为 0 时每张表固定 1<<20 位、7 次哈希。
+	// 参数随表保存，之后修改不影响已有的表。取值不合法时 Open 返回 ErrInvalidOptions。
+	BloomBitsPerKey        int
+	BloomFalsePositiveRate float64
+	BloomHashes            int
+
+	// FilterPolicy 是写 SST 时使用的过滤器名称（先用 sstable.RegisterFilterPolicy 注册），
+	// 空表示内置的 bloom filter；设置时忽略上面的 Bloom 参数。名称随表保存，读表时按表里的名称选择过滤器，
+	// 所以可以随时更换，用不同过滤器写出的表都能读取。名称未注册时 Open 返回 ErrInvalidOptions。
+	FilterPolicy string
+
+	// MaxSubcompactions 大于 1 时，输入足够大的 compaction 按稀疏索引把 key 范围切成最多这么多段，
+	// 每段至少 SubcompactionMinBytes（0 表示 DefaultSubcompactionMinBytes）的输入，
+	// 并行地归并、写出，每段输出一张表。compaction filter 和值日志依然按 key 顺序串行处理。
+	// 同一次 compaction 的输出 key 范围互不重叠，MaybeCompact 把它们算作一张表；
+	// 写停顿（L0SlowdownTables / L0StopTables）按文件个数计算，开启时应相应调大。
+	// 0 或 1 表示每次 compaction 只输出一张表（默认）。
+	MaxSubcompactions     int
+	SubcompactionMinBytes int64
+
+	// ScrubInterval 大于 0 时开启后台巡检：一个低优先级的 goroutine 不断挑出当前 version 里
+	// 最久没有确认完好的表（上次巡检或写出之后超过 ScrubInterval 的），用 sstable.CheckTable 完整重读一遍，
+	// 在读请求碰到之前发现冷数据里的静默损坏（bit rot）。只读模式下同样生效，适合副本。
+	// ScrubBytesPerSec 限制巡检读盘的平均速度（按表计算），0 表示 DefaultScrubBytesPerSec，负数表示不限速。
+	// 发现的损坏交给 ScrubAlert（nil 表示只写日志），并计入 Stats().Scrub；巡检不修改任何文件。
+	ScrubInterval    time.Duration
+	ScrubBytesPerSec int64
+	ScrubAlert       func(CorruptFile)
+
+	// FS 是数据库读写文件使用的文件系统（WAL、SST、值日志、manifest 等），nil 表示操作系统的文件系统。
+	// 测试可以传入 vfs.NewMem() 让数据库完全不碰磁盘。同一个目录的每次 Open 都要使用同一个 FS。
+	FS vfs.FS
+
+	// inMemory 由 OpenInMemory 设置：不写 WAL（见 openWAL）。
+	inMemory bool
+}
+
+func (o Options) bounded() bool {
+	return !o.ReadOnly && (o.MaxKeys > 0 || o.MaxBytes > 0)
+}
+
+func (o Options) clock() Clock {
+	if o.Clock == nil {
+		return systemClock{}
+	}
+	return o.Clock
+}
+
+func (o Options) blockSize() int {
+	if o.BlockSize <= 0 {
+		return sstable.AdaptiveBlockSize
+	}
+	return o.BlockSize
+}
+
+// withFilter 返回填好过滤器参数的 w。
+func (o Options) withFilter(w sstable.WriterOptions) sstable.WriterOptions {
+	w.BloomBitsPerKey, w.BloomFalsePositiveRate, w.BloomHashes = o.BloomBitsPerKey, o.BloomFalsePositiveRate, o.BloomHashes
+	w.FilterPolicy = o.FilterPolicy
+	return w
+}
+
+func (o Options) walOptions() wal.Options {
+	return wal.Options{Keys: o.Encryption, Compress: o.WALCompression, FS: o.FS}
+}
+
+func (o Options) fs() vfs.FS {
+	return vfs.OrDefault(o.FS)
+}
+
+// rateLimiter 返回传给 sstable 的限速器；不能把 nil 指针直接转成非 nil 的接口。
+func (o Options) rateLimiter() sstable.RateLimiter {
+	if o.RateLimiter == nil {
+		return nil
+	}
+	return o.RateLimiter
+}
+
+func (o Options) subcompactionMinBytes() int64 {
+	if o.SubcompactionMinBytes <= 0 {
+		return DefaultSubcompactionMinBytes
+	}
+	return o.SubcompactionMinBytes
+}
+
+func (o Options) maxRangeBytes() int64 {
+	if o.MaxRangeBytes == 0 {
+		return DefaultMaxRangeBytes
+	}
+	return o.MaxRangeBytes
+}
+
+func (o Options) comparer() types.Comparer {
+	if o.Comparer == nil {
+		return types.BytewiseComparer
+	}
+	return o.Comparer
+}
+
+func (o Options) comparatorName() string {
+	if o.Comparer != nil {
+		return o.Comparer.Name()
+	}
+	if o.ComparatorName == "" {
+		return DefaultComparatorName
+	}
+	return o.ComparatorName
+}
+
+func (o Options) keyNormalizerName() string {
+	if o.KeyNormalizer == nil {
+		return ""
+	}
+	return o.KeyNormalizer.Name()
+}
+
+// fingerprint 把会影响磁盘数据解释方式的配置提取成 manifest。
+func (o Options) fingerprint() *manifest.Manifest {
+	return &manifest.Manifest{
+		FormatVersion:   formatVersion,
+		Comparator:      o.comparatorName(),
+		PrefixExtractor: o.PrefixExtractorName,
+		MergeOperator:   o.MergeOperatorName,
+		KeyNormalizer:   o.keyNormalizerName(),
+	}
+}
+
+// checkManifest 校验 dir 下的 manifest 与 opts 是否兼容。
+// manifest 不存在时：新库（目录里还没有 WAL、SST、值日志）按当前配置写一份；
+// 已经有数据的目录是 manifest 之前的版本创建的库，那时只有格式版本 1 和字节序比较器，按 legacyManifest 校验。
+func checkManifest(dir string, opts Options) error {
+	path := filepath.Join(dir, manifest.FileName)
+	want := opts.fingerprint()
+
+	got, err := manifest.LoadFS(opts.fs(), path)
+	if err != nil {
+		return err
+	}
+	if got == nil {
+		hasData, err := hasLegacyData(dir, opts)
+		if err != nil {
+			return err
+		}
+		if !hasData {
+			if opts.ReadOnly {
+				return nil
+			}
+			return manifest.WriteFS(opts.fs(), path, want)
+		}
+		got = legacyManifest()
+	}
+
+	// 旧版本的格式都还能读（SST / WAL 只追加了新的 flags 和记录类型，footer 按大小区分），只拒绝更新的
+	if got.FormatVersion > want.FormatVersion {
+		return fmt.Errorf("%w: format version is %d on disk, engine supports up to %d",
+			ErrIncompatibleOptions, got.FormatVersion, want.FormatVersion)
+	}
+	if got.Comparator != want.Comparator {
+		return fmt.Errorf("%w: comparator is %q on disk, but %q was requested",
+			ErrIncompatibleOptions, got.Comparator, want.Comparator)
+	}
+	if got.PrefixExtractor != want.PrefixExtractor {
+		return fmt.Errorf("%w: prefix extractor is %q on disk, but %q was requested",
+			ErrIncompatibleOptions, got.PrefixExtractor, want.PrefixExtractor)
+	}
+	if got.MergeOperator != want.MergeOperator {
+		return fmt.Errorf("%w: merge operator is %q on disk, but %q was requested",
+			ErrIncompatibleOptions, got.MergeOperator, want.MergeOperator)
+	}
+	if got.KeyNormalizer != want.KeyNormalizer {
+		return fmt.Errorf("%w: key normalizer is %q on disk, but %q was requested",
+			ErrIncompatibleOptions, got.KeyNormalizer, want.KeyNormalizer)
+	}
+
+	// 之后写出的数据是当前格式，把版本号更新上去，防止更旧的引擎再打开这个库
+	if got.FormatVersion < want.FormatVersion && !opts.ReadOnly {
+		want.NextFileNumber = got.NextFileNumber
+		return manifest.WriteFS(opts.fs(), path, want)
+	}
+	return nil
+}
+
+// legacyManifest 返回 manifest 之前的版本创建的库对应的 manifest：格式版本 1，字节序比较器，没有其它可配置项。
+func legacyManifest() *manifest.Manifest {
+	return &manifest.Manifest{FormatVersion: 1, Comparator: DefaultComparatorName}
+}
+
+// hasLegacyData 判断没有 manifest 的 dir 里是否已经有 WAL、SST 或值日志。
+func hasLegacyData(dir string, opts Options) (bool, error) {
+	for _, name := range []string{walFileName, sstDirName, vlogDirName} {
+		_, err := opts.fs().Stat(filepath.Join(dir, name))
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return false, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,445 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"monolithdb/internal/manifest"
+	"monolithdb/internal/sstable"
+)
+
+func TestOpenRejectsIncompatibleOptions(t *testing.T) {
+	dir := t.TempDir()
+	dbDir := filepath.Join(dir, "data")
+
+	d, err := OpenWithOptions(dbDir, Options{MergeOperatorName: "uint64add"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 相同配置可以正常重新打开
+	d, err = OpenWithOptions(dbDir, Options{MergeOperatorName: "uint64add"})
+	if err != nil {
+		t.Fatalf("expected reopen with same options to succeed, got %v", err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 换 merge operator / comparator 必须失败
+	if _, err := OpenWithOptions(dbDir, Options{MergeOperatorName: "stringappend"}); !errors.Is(err, ErrIncompatibleOptions) {
+		t.Fatalf("expected ErrIncompatibleOptions for merge operator change, got %v", err)
+	}
+	if _, err := OpenWithOptions(dbDir, Options{
+		ComparatorName:    "reverse",
+		MergeOperatorName: "uint64add",
+	}); !errors.Is(err, ErrIncompatibleOptions) {
+		t.Fatalf("expected ErrIncompatibleOptions for comparator change, got %v", err)
+	}
+}
+
+func TestOpenDefaultComparatorMatchesExplicit(t *testing.T) {
+	dir := t.TempDir()
+	dbDir := filepath.Join(dir, "data")
+
+	d, err := Open(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 显式写出默认比较器名称等价于零值
+	d, err = OpenWithOptions(dbDir, Options{ComparatorName: DefaultComparatorName})
+	if err != nil {
+		t.Fatalf("expected explicit default comparator to be compatible, got %v", err)
+	}
+	_ = d.Close()
+}
+
+func TestCompressionOption(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	if _, err := OpenWithOptions(dir, Options{Compression: "no-such-codec"}); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("expected ErrInvalidOptions, got %v", err)
+	}
+
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, Compression: "flate"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := bytes.Repeat([]byte("abcd"), 16<<10)
+	for i := 0; i < 8; i++ {
+		if err := d.Put(fmt.Sprintf("k%d", i), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// 8 个 64KB 的 value 压缩后远小于原始大小（表里还有固定大小的 bloom filter）
+	if n := d.Counters().BytesFlushed; n >= uint64(8*len(value)) {
+		t.Fatalf("table not compressed: %d bytes", n)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 之后不压缩打开也能读到压缩过的表
+	d, err = OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if v, ok, err := d.Get("k3"); err != nil || !ok || !bytes.Equal(v, value) {
+		t.Fatalf("Get(k3) = %d bytes, %v, %v", len(v), ok, err)
+	}
+}
+
+func TestBloomOptions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	if _, err := OpenWithOptions(dir, Options{BloomFalsePositiveRate: 1.5}); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("expected ErrInvalidOptions, got %v", err)
+	}
+
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, BloomBitsPerKey: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := d.Put(fmt.Sprintf("k%03d", i), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	old := d.versions.current().tables[0]
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 换一组参数重新打开：旧表按自己记录的参数读取，新表用新的参数
+	d, err = OpenWithOptions(dir, Options{DisableFsync: true, BloomFalsePositiveRate: 0.001, BloomHashes: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Put("k100", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string][2]uint64{old: {1000, 7}, d.versions.current().tables[0]: {15, 5}} {
+		ti, err := sstable.Describe(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uint64(ti.BloomBits) != want[0] || uint64(ti.BloomHashes) != want[1] {
+			t.Fatalf("%s: bloom bits=%d hashes=%d, want %v", filepath.Base(path), ti.BloomBits, ti.BloomHashes, want)
+		}
+	}
+	for i := 0; i <= 100; i++ {
+		if _, ok, err := d.Get(fmt.Sprintf("k%03d", i)); err != nil || !ok {
+			t.Fatalf("Get(k%03d) = %v, %v", i, ok, err)
+		}
+	}
+}
+
+// exactFilter 原样保存所有 key 的过滤器，没有误判。
+type exactFilter struct{}
+
+func (exactFilter) Name() string                      { return "db-test-exact" }
+func (exactFilter) CreateFilter(keys []string) []byte { return []byte(strings.Join(keys, "\x00")) }
+func (exactFilter) MayContain(filter []byte, key string) bool {
+	return slices.Contains(strings.Split(string(filter), "\x00"), key)
+}
+
+func init() {
+	if err := sstable.RegisterFilterPolicy(exactFilter{}); err != nil {
+		panic(err)
+	}
+}
+
+func TestFilterPolicyOption(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	if _, err := OpenWithOptions(dir, Options{FilterPolicy: "no-such-filter"}); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("expected ErrInvalidOptions, got %v", err)
+	}
+
+	// 先用内置 bloom 写一张表，再换成 FilterPolicy：两种表都能读，compaction 的输出使用新的过滤器
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	d, err = OpenWithOptions(dir, Options{DisableFsync: true, FilterPolicy: "db-test-exact"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Put("b", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	filters := func() []string {
+		var names []string
+		for _, p := range d.versions.current().tables {
+			props, err := sstable.ReadProperties(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, props.FilterPolicy)
+		}
+		return names
+	}
+	if got := filters(); !slices.Equal(got, []string{"db-test-exact", ""}) {
+		t.Fatalf("filter policies = %q", got)
+	}
+	checkGet := func() {
+		t.Helper()
+		for k, want := range map[string]string{"a": "1", "b": "2"} {
+			if v, ok, err := d.Get(k); err != nil || !ok || string(v) != want {
+				t.Fatalf("Get(%q) = %q, %v, %v", k, v, ok, err)
+			}
+		}
+		if _, ok, err := d.Get("c"); err != nil || ok {
+			t.Fatalf("Get(c) = %v, %v", ok, err)
+		}
+	}
+	checkGet()
+
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if got := filters(); !slices.Equal(got, []string{"db-test-exact"}) {
+		t.Fatalf("filter policies after compaction = %q", got)
+	}
+	checkGet()
+}
+
+func TestOpenDataWithoutManifest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("k", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("k2", []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟 manifest 之前的版本创建的库：有 WAL / SST，但没有 manifest
+	path := filepath.Join(dir, manifest.FileName)
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// 那时只有字节序比较器，其它比较器打不开
+	if _, err := OpenWithOptions(dir, Options{Comparer: reverseComparer{}}); !errors.Is(err, ErrIncompatibleOptions) {
+		t.Fatalf("expected ErrIncompatibleOptions, got %v", err)
+	}
+
+	// 只读打开可以读，不写 manifest
+	d, err = OpenWithOptions(dir, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := d.Get("k"); err != nil || !ok || string(v) != "v" {
+		t.Fatalf("Get(k) = %q, %v, %v", v, ok, err)
+	}
+	_ = d.Close()
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("read-only open wrote a manifest: %v", err)
+	}
+
+	// 可写打开按当前配置补上 manifest
+	d, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := d.Get("k2"); err != nil || !ok || string(v) != "v2" {
+		t.Fatalf("Get(k2) = %q, %v, %v", v, ok, err)
+	}
+	_ = d.Close()
+	m, err := manifest.Load(path)
+	if err != nil || m == nil {
+		t.Fatalf("manifest not written: %v", err)
+	}
+	if m.FormatVersion != formatVersion || m.Comparator != DefaultComparatorName {
+		t.Fatalf("unexpected manifest: %+v", m)
+	}
+}
+
+func TestOpenOlderFormatVersion(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("k", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, manifest.FileName)
+	setVersion := func(v uint32) {
+		t.Helper()
+		m, err := manifest.Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.FormatVersion = v
+		if err := manifest.Write(path, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	version := func() uint32 {
+		t.Helper()
+		m, err := manifest.Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.FormatVersion
+	}
+
+	// 更旧的版本可以打开；只读打开不改 manifest，可写打开把版本更新为当前版本
+	setVersion(1)
+	d, err = OpenWithOptions(dir, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = d.Close()
+	if v := version(); v != 1 {
+		t.Fatalf("read-only open changed format version to %d", v)
+	}
+	d, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := d.Get("k"); err != nil || !ok || string(v) != "v" {
+		t.Fatalf("Get = %q, %v, %v", v, ok, err)
+	}
+	_ = d.Close()
+	if v := version(); v != formatVersion {
+		t.Fatalf("format version = %d, want %d", v, formatVersion)
+	}
+
+	// 更新的版本拒绝打开
+	setVersion(formatVersion + 1)
+	if _, err := Open(dir); !errors.Is(err, ErrIncompatibleOptions) {
+		t.Fatalf("expected ErrIncompatibleOptions, got %v", err)
+	}
+}
+
+func TestOpenMixedTableVersions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(k string) {
+		t.Helper()
+		if err := d.Put(k, []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("a")
+	put("b")
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 把第一张表改写成 footer 带版本之前的 24 字节布局（各区位置不变，只换掉 footer）
+	old := d.versions.current().tables[0]
+	ti, err := sstable.Describe(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = data[:len(data)-36]
+	for _, off := range []uint64{ti.IndexStart, ti.BloomStart, ti.PropsStart} {
+		data = binary.LittleEndian.AppendUint64(data, off)
+	}
+	if err := os.WriteFile(old, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	put("b2")
+	put("c")
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	check := func() {
+		t.Helper()
+		for _, k := range []string{"a", "b", "b2", "c"} {
+			if v, ok, err := d.Get(k); err != nil || !ok || string(v) != k {
+				t.Fatalf("Get(%s) = %q, %v, %v", k, v, ok, err)
+			}
+		}
+		if entries, err := d.Range("", ""); err != nil || len(entries) != 4 {
+			t.Fatalf("Range = %v, %v", entries, err)
+		}
+	}
+	check()
+	if ti, err := sstable.Describe(old); err != nil || ti.FormatVersion != 0 {
+		t.Fatalf("old table: %v, %v", ti, err)
+	}
+
+	// compaction 读旧表，写出当前版本的表
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	check()
+	for _, p := range d.versions.current().tables {
+		if ti, err := sstable.Describe(p); err != nil || ti.FormatVersion != sstable.TableFormatVersion {
+			t.Fatalf("%s after compaction: %v, %v", p, ti, err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package db
+
+import "context"
+
+// PinnedValue 是 GetPinned 返回的值。它直接引用数据库内部的数据（MemTable 或读缓存里的值，
+// 或者刚从 SST 解码出来的缓冲区），没有做防御性拷贝，所以调用方不能修改 Value 返回的切片。
+//
+// 用完之后调用 Release 放开引用：在此之前，被引用的数据即使已经随 MemTable 刷盘或者被缓存淘汰，
+// 也不会被回收。Release 之后 Value 返回 nil。Release 可以重复调用，对 nil 调用也是安全的。
+type PinnedValue struct {
+	value []byte
+}
+
+// Value 返回值本身（只读）。
+func (p *PinnedValue) Value() []byte {
+	if p == nil {
+		return nil
+	}
+	return p.value
+}
+
+// Release 放开对值的引用。
+func (p *PinnedValue) Release() {
+	if p != nil {
+		p.value = nil
+	}
+}
+
+// GetPinned 与 Get 相同，但不拷贝 MemTable 和读缓存里的值，适合频繁读取较大的值。
+// key 不存在时返回 nil, false, nil。配置了 ReadInterceptors 时返回的是改写之后的值。
+func (d *DB) GetPinned(key string) (*PinnedValue, bool, error) {
+	key = d.normKey(key)
+	sro := d.sstReadOptions(ReadOptions{})
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	v, ok, err := d.getLocked(context.Background(), key, sro, d.now(), true)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	if v, err = d.interceptRead(key, v); err != nil {
+		return nil, false, err
+	}
+	return &PinnedValue{value: v}, true, nil
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetPinned(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true, ReadCacheBytes: 4 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	big := bytes.Repeat([]byte("x"), 1<<20)
+	if err := d.Put("big", big); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok, err := d.GetPinned("missing"); err != nil || ok || p.Value() != nil {
+		t.Fatalf("GetPinned(missing) = %v %v %v", p, ok, err)
+	}
+
+	// 两次读取引用同一块内存：说明没有拷贝
+	samePinned := func(where string) {
+		t.Helper()
+		p1, ok, err := d.GetPinned("big")
+		if err != nil || !ok || !bytes.Equal(p1.Value(), big) {
+			t.Fatalf("%s: GetPinned = %v %v", where, ok, err)
+		}
+		p2, _, _ := d.GetPinned("big")
+		if &p1.Value()[0] != &p2.Value()[0] {
+			t.Fatalf("%s: pinned values do not share memory", where)
+		}
+		p1.Release()
+		p2.Release()
+		if p1.Value() != nil {
+			t.Fatalf("%s: Value after Release = %d bytes", where, len(p1.Value()))
+		}
+	}
+	samePinned("memtable")
+
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// 第一次从 SST 读取后放进读缓存，之后从读缓存直接返回
+	if v, ok, err := d.Get("big"); err != nil || !ok || !bytes.Equal(v, big) {
+		t.Fatalf("Get = %v %v", ok, err)
+	}
+	samePinned("read cache")
+
+	// Get 依然返回独立的拷贝
+	v, _, _ := d.Get("big")
+	v[0] = 'y'
+	if p, _, _ := d.GetPinned("big"); p.Value()[0] != 'x' {
+		t.Fatal("modifying a Get result changed the cached value")
+	}
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"monolithdb/internal/vfs"
+)
+
+// GetProperty 的属性名，仿照 RocksDB 的 "rocksdb.xxx" 属性：通用的监控、运维工具只需要
+// 按名字轮询字符串，不必依赖 Stats 等结构体。数值属性也可以用 GetIntProperty 读取。
+const (
+	PropNumSSTables       = "forgedb.num-sstables"        // SST 个数
+	PropTotalSSTableBytes = "forgedb.total-sstable-bytes" // 所有 SST 文件的总大小
+	PropMemTableBytes     = "forgedb.memtable-bytes"      // MemTable 中 key + value 的近似字节数
+	PropMemTableEntries   = "forgedb.memtable-entries"    // MemTable 中的记录数，包含 tombstone
+	PropLatestSequence    = "forgedb.latest-sequence-number"
+
+	// PropNumObsoleteSSTables 是已经被 compaction 替换、但还有读取或 Snapshot 在使用所以暂时没有删除的 SST 个数。
+	PropNumObsoleteSSTables = "forgedb.num-obsolete-sstables"
+
+	// PropNumImmutableMemTables 是等待后台写成 SST 的不可变 MemTable 个数（见 Options.MaxImmutableMemtables）。
+	PropNumImmutableMemTables = "forgedb.num-immutable-memtables"
+
+	// PropCompactionPending 为 1 表示 MaybeCompact 现在会执行合并，
+	// PropPendingCompactionBytes 是这次合并要重写的 SST 总大小。
+	// 设置了 CompactionHint 时需要扫描表中的 key 才能判断。
+	PropCompactionPending      = "forgedb.compaction-pending"
+	PropPendingCompactionBytes = "forgedb.estimate-pending-compaction-bytes"
+
+	PropNumCompactions  = "forgedb.num-compactions" // 打开数据库以来完成的 compaction 次数
+	PropNumFlushes      = "forgedb.num-flushes"     // 打开数据库以来完成的 Flush 次数
+	PropIsWriteStopped  = "forgedb.is-write-stopped"
+	PropReadCacheBytes  = "forgedb.read-cache-bytes"
+	PropReadCacheHits   = "forgedb.read-cache-hits"
+	PropReadCacheMisses = "forgedb.read-cache-misses"
+
+	// PropSSTables 是每张 SST 一行 "文件名 字节数"，newest first。
+	PropSSTables = "forgedb.sstables"
+	// PropStats 是上面所有数值属性的多行文本，每行 "属性名: 值"。
+	PropStats = "forgedb.stats"
+)
+
+// intProperties 是所有数值属性。调用方持有读锁。
+var intProperties = map[string]func(d *DB) (uint64, error){
+	PropNumSSTables: func(d *DB) (uint64, error) { return uint64(len(d.versions.current().tables)), nil },
+	PropTotalSSTableBytes: func(d *DB) (uint64, error) {
+		return tableBytes(d.opts.fs(), d.versions.current().tables)
+	},
+	PropMemTableBytes:         func(d *DB) (uint64, error) { return uint64(d.mem.ApproximateBytes()), nil },
+	PropMemTableEntries:       func(d *DB) (uint64, error) { return uint64(d.mem.Len()), nil },
+	PropLatestSequence:        func(d *DB) (uint64, error) { return d.lastSeq, nil },
+	PropNumImmutableMemTables: func(d *DB) (uint64, error) { return uint64(len(d.imm)), nil },
+	PropNumObsoleteSSTables:   func(d *DB) (uint64, error) { return uint64(d.versions.numObsolete()), nil },
+	PropCompactionPending: func(d *DB) (uint64, error) {
+		inputs, err := d.pickCompaction()
+		if err != nil || len(inputs) == 0 {
+			return 0, err
+		}
+		return 1, nil
+	},
+	PropPendingCompactionBytes: func(d *DB) (uint64, error) {
+		inputs, err := d.pickCompaction()
+		if err != nil {
+			return 0, err
+		}
+		return tableBytes(d.opts.fs(), inputs)
+	},
+	PropNumCompactions: func(d *DB) (uint64, error) { return d.metrics.compactions.Load(), nil },
+	PropNumFlushes:     func(d *DB) (uint64, error) { return d.metrics.flushes.Load(), nil },
+	PropIsWriteStopped: func(d *DB) (uint64, error) {
+		if d.opts.stallEnabled() && d.stallLevel() == stallStop {
+			return 1, nil
+		}
+		return 0, nil
+	},
+	PropReadCacheBytes:  func(d *DB) (uint64, error) { return uint64(d.ReadCacheStats().Bytes), nil },
+	PropReadCacheHits:   func(d *DB) (uint64, error) { return d.ReadCacheStats().Hits, nil },
+	PropReadCacheMisses: func(d *DB) (uint64, error) { return d.ReadCacheStats().Misses, nil },
+}
+
+// GetProperty 返回属性 name 的当前值（见 Prop 开头的常量），数值属性是十进制字符串。
+// 属性不存在、或者读取时出错（例如 SST 文件已损坏）时返回 false。
+func (d *DB) GetProperty(name string) (string, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if f, ok := intProperties[name]; ok {
+		v, err := f(d)
+		if err != nil {
+			return "", false
+		}
+		return strconv.FormatUint(v, 10), true
+	}
+
+	var b strings.Builder
+	switch name {
+	case PropSSTables:
+		for _, p := range d.versions.current().tables {
+			st, err := d.opts.fs().Stat(p)
+			if err != nil {
+				return "", false
+			}
+			fmt.Fprintf(&b, "%s %d\n", filepath.Base(p), st.Size())
+		}
+	case PropStats:
+		names := make([]string, 0, len(intProperties))
+		for n := range intProperties {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			v, err := intProperties[n](d)
+			if err != nil {
+				return "", false
+			}
+			fmt.Fprintf(&b, "%s: %d\n", n, v)
+		}
+	default:
+		return "", false
+	}
+	return b.String(), true
+}
+
+// GetIntProperty 返回数值属性 name 的当前值。属性不存在、不是数值属性或者读取出错时返回 false。
+func (d *DB) GetIntProperty(name string) (uint64, bool) {
+	f, ok := intProperties[name]
+	if !ok {
+		return 0, false
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	v, err := f(d)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// tableBytes 返回 paths 中 SST 文件的总大小。
+func tableBytes(fs vfs.FS, paths []string) (uint64, error) {
+	var n uint64
+	for _, p := range paths {
+		st, err := fs.Stat(p)
+		if err != nil {
+			return 0, err
+		}
+		n += uint64(st.Size())
+	}
+	return n, nil
+}
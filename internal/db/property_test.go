@@ -0,0 +1,101 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestGetProperty(t *testing.T) {
+	d, err := OpenWithOptions(t.TempDir(), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	intProp := func(name string) uint64 {
+		t.Helper()
+		v, ok := d.GetIntProperty(name)
+		if !ok {
+			t.Fatalf("GetIntProperty(%q) not ok", name)
+		}
+		if s, ok := d.GetProperty(name); !ok || s != fmt.Sprint(v) {
+			t.Fatalf("GetProperty(%q) = %q, %v; GetIntProperty = %d", name, s, ok, v)
+		}
+		return v
+	}
+
+	if err := d.Put("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if n := intProp(PropMemTableEntries); n != 1 {
+		t.Fatalf("memtable entries = %d", n)
+	}
+	if n := intProp(PropMemTableBytes); n == 0 {
+		t.Fatal("memtable bytes = 0")
+	}
+
+	for i := range compactionTrigger - 1 {
+		if err := d.Put(fmt.Sprintf("k%d", i), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := intProp(PropNumSSTables); n != compactionTrigger-1 {
+		t.Fatalf("num sstables = %d", n)
+	}
+	if n := intProp(PropCompactionPending); n != 0 {
+		t.Fatalf("compaction pending = %d below the trigger", n)
+	}
+	if n := intProp(PropPendingCompactionBytes); n != 0 {
+		t.Fatalf("pending compaction bytes = %d below the trigger", n)
+	}
+
+	if err := d.Put("z", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	total := intProp(PropTotalSSTableBytes)
+	if n := intProp(PropCompactionPending); n != 1 {
+		t.Fatalf("compaction pending = %d at the trigger", n)
+	}
+	if n := intProp(PropPendingCompactionBytes); n != total || n == 0 {
+		t.Fatalf("pending compaction bytes = %d, want all %d bytes", n, total)
+	}
+	if n := intProp(PropNumFlushes); n != compactionTrigger {
+		t.Fatalf("num flushes = %d", n)
+	}
+	if n := intProp(PropLatestSequence); n != d.LastSequence() {
+		t.Fatalf("latest sequence = %d, want %d", n, d.LastSequence())
+	}
+
+	if compacted, err := d.MaybeCompact(); err != nil || !compacted {
+		t.Fatalf("MaybeCompact = %v, %v", compacted, err)
+	}
+	if n := intProp(PropNumCompactions); n != 1 {
+		t.Fatalf("num compactions = %d", n)
+	}
+	if n := intProp(PropPendingCompactionBytes); n != 0 {
+		t.Fatalf("pending compaction bytes = %d after compaction", n)
+	}
+
+	tables, ok := d.GetProperty(PropSSTables)
+	if !ok || strings.Count(tables, "\n") != 1 || !strings.Contains(tables, ".sst ") {
+		t.Fatalf("sstables = %q, %v", tables, ok)
+	}
+	stats, ok := d.GetProperty(PropStats)
+	if !ok || !strings.Contains(stats, PropNumSSTables+": 1\n") {
+		t.Fatalf("stats = %q, %v", stats, ok)
+	}
+
+	if _, ok := d.GetProperty("forgedb.no-such-property"); ok {
+		t.Fatal("unknown property reported ok")
+	}
+	if _, ok := d.GetIntProperty(PropStats); ok {
+		t.Fatal("GetIntProperty accepted a string property")
+	}
+}
@@ -0,0 +1,275 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"reflect"
+	"strings"
+
+	"monolithdb/internal/types"
+)
+
+// ErrInvalidQuery 表示 Query 的字段匹配条件不合法（未知的 FieldOp、不能比较大小的 Value 等）。
+var ErrInvalidQuery = errors.New("db: invalid query")
+
+// Query 描述一次带过滤条件的扫描，见 DB.Query。
+type Query struct {
+	// Start / End 是扫描的范围 [Start, End)，与 Range 相同。
+	Start, End string
+
+	// Prefix 非空时只返回以它开头的 key（与 [Start, End) 取交集）。
+	// 使用默认的字节序比较器时扫描范围直接收窄到这个前缀。
+	Prefix string
+
+	// Fields 把 value 当作 JSON 对象，要求所有条件都满足；value 不是合法的 JSON 时不匹配。
+	Fields []FieldMatch
+
+	// Filter 非 nil 时在 Fields 之后调用，返回 true 的记录才匹配。
+	// 它在持有读锁时被调用：实现不能调用 DB 的方法，也不能在返回之后继续持有 value。
+	Filter func(key string, value []byte) bool
+
+	// Limit 大于 0 时最多返回这么多条匹配的记录，找够之后立即停止扫描。
+	Limit int
+}
+
+// FieldOp 是 FieldMatch 的比较方式。
+type FieldOp int
+
+const (
+	FieldEq     FieldOp = iota // 字段存在且等于 Value
+	FieldNe                    // 字段不存在，或者不等于 Value
+	FieldExists                // 字段存在（包括 JSON null），忽略 Value
+	FieldLt                    // 字段 < Value
+	FieldLe                    // 字段 <= Value
+	FieldGt                    // 字段 > Value
+	FieldGe                    // 字段 >= Value
+)
+
+// FieldMatch 是对 JSON value 中一个字段的条件。
+type FieldMatch struct {
+	// Path 是用 "." 分隔的字段路径，例如 "address.city"；只能穿过 JSON 对象，不能进入数组。
+	Path string
+
+	Op FieldOp
+
+	// Value 是比较的对象：字符串、任意 Go 数字类型、bool、nil，或者 encoding/json 解码出的
+	// []any / map[string]any（只用于 FieldEq / FieldNe）。数字都按 float64 比较；
+	// 大小比较只用于数字和字符串，字段的类型与 Value 不同时不匹配。
+	Value any
+}
+
+// queryScanBytes 是 Query 在一次读锁内最多扫描的 key+value 字节数，扫描大范围而匹配很少时
+// 也会定期释放锁，不会长时间挡住写入。
+const queryScanBytes = DefaultRangeChunkBytes
+
+// Query 使用 context.Background() 执行查询，见 QueryContext。
+func (d *DB) Query(q Query) iter.Seq2[types.Entry, error] {
+	return d.QueryContext(context.Background(), q)
+}
+
+// QueryContext 按 key 升序流式返回 q 匹配的记录。过滤和 Limit 在扫描内部进行：
+// 不匹配的记录不会被缓存或返回，找够 Limit 条、或者调用方停止迭代之后不再继续读取。
+//
+// 与 RangeChunks 一样，扫描分成多段，每段在一次读锁内进行，段与段之间释放锁，
+// 迭代期间（处理产出的记录时）不持有锁，所以结果不是同一时刻的快照。
+// ctx 被取消或超时时产出 ctx.Err() 并结束。
+func (d *DB) QueryContext(ctx context.Context, q Query) iter.Seq2[types.Entry, error] {
+	return func(yield func(types.Entry, error) bool) {
+		if err := checkQuery(q); err != nil {
+			yield(types.Entry{}, err)
+			return
+		}
+		start, end := d.normKey(q.Start), d.normKey(q.End)
+		q.Prefix = d.normKey(q.Prefix)
+		if q.Prefix != "" && d.cmp.Name() == types.BytewiseComparer.Name() {
+			start, end = narrowToPrefix(start, end, q.Prefix)
+			if end != "" && start >= end {
+				return
+			}
+		}
+
+		from, after, n := start, false, 0
+		for {
+			matches, last, more, err := d.queryChunk(ctx, q, from, after, end, q.Limit-n)
+			if err != nil {
+				yield(types.Entry{}, err)
+				return
+			}
+			for _, e := range matches {
+				if !yield(e, nil) {
+					return
+				}
+			}
+			n += len(matches)
+			if !more || (q.Limit > 0 && n >= q.Limit) {
+				return
+			}
+			from, after = last, true
+		}
+	}
+}
+
+// queryChunk 在一次读锁内从 from 开始扫描最多 queryScanBytes 字节（after 为 true 时跳过 from 本身），
+// 返回其中匹配的记录（limit > 0 时最多 limit 条）和最后扫描到的 key。more 表示范围还没有扫描完。
+func (d *DB) queryChunk(ctx context.Context, q Query, from string, after bool, end string, limit int) (matches []types.Entry, last string, more bool, err error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var scanned int64
+	for e, err := range d.mergeRange(ctx, from, end, d.now()) {
+		if err != nil {
+			return nil, "", false, err
+		}
+		if after && d.cmp.Compare(e.Key, from) <= 0 {
+			continue
+		}
+		if scanned >= queryScanBytes {
+			return matches, last, true, nil
+		}
+		scanned += entrySize(e)
+		last = e.Key
+		if q.Prefix != "" && !strings.HasPrefix(e.Key, q.Prefix) {
+			continue
+		}
+		if e.Value, err = d.interceptRead(e.Key, e.Value); err != nil {
+			return nil, "", false, err
+		}
+		if !q.matches(e.Key, e.Value) {
+			continue
+		}
+		matches = append(matches, e)
+		if limit > 0 && len(matches) >= limit {
+			return matches, last, false, nil
+		}
+	}
+	return matches, last, false, nil
+}
+
+// narrowToPrefix 把 [start, end) 收窄到以 prefix 开头的 key（字节序）。
+func narrowToPrefix(start, end, prefix string) (string, string) {
+	if start < prefix {
+		start = prefix
+	}
+	// 去掉末尾的 0xff 之后把最后一个字节加一；prefix 全是 0xff 时没有上界
+	p := strings.TrimRight(prefix, "\xff")
+	if p == "" {
+		return start, end
+	}
+	if pe := p[:len(p)-1] + string([]byte{p[len(p)-1] + 1}); end == "" || pe < end {
+		end = pe
+	}
+	return start, end
+}
+
+// checkQuery 在扫描之前检查字段匹配条件。
+func checkQuery(q Query) error {
+	for _, f := range q.Fields {
+		switch f.Op {
+		case FieldEq, FieldNe, FieldExists:
+		case FieldLt, FieldLe, FieldGt, FieldGe:
+			if _, ok := jsonValue(f.Value).(float64); !ok {
+				if _, ok := f.Value.(string); !ok {
+					return fmt.Errorf("%w: field %q: %T cannot be ordered", ErrInvalidQuery, f.Path, f.Value)
+				}
+			}
+		default:
+			return fmt.Errorf("%w: field %q: unknown op %d", ErrInvalidQuery, f.Path, f.Op)
+		}
+	}
+	return nil
+}
+
+// matches 检查一条记录是否满足 Fields 和 Filter。
+func (q *Query) matches(key string, value []byte) bool {
+	if len(q.Fields) > 0 {
+		var doc any
+		if err := json.Unmarshal(value, &doc); err != nil {
+			return false
+		}
+		for _, f := range q.Fields {
+			if !f.match(doc) {
+				return false
+			}
+		}
+	}
+	return q.Filter == nil || q.Filter(key, value)
+}
+
+func (f FieldMatch) match(doc any) bool {
+	v, ok := lookupField(doc, f.Path)
+	switch f.Op {
+	case FieldExists:
+		return ok
+	case FieldEq:
+		return ok && reflect.DeepEqual(v, jsonValue(f.Value))
+	case FieldNe:
+		return !ok || !reflect.DeepEqual(v, jsonValue(f.Value))
+	}
+	if !ok {
+		return false
+	}
+
+	var c int
+	switch want := jsonValue(f.Value).(type) {
+	case float64:
+		got, ok := v.(float64)
+		if !ok {
+			return false
+		}
+		switch {
+		case got < want:
+			c = -1
+		case got > want:
+			c = 1
+		}
+	case string:
+		got, ok := v.(string)
+		if !ok {
+			return false
+		}
+		c = strings.Compare(got, want)
+	default:
+		return false
+	}
+	switch f.Op {
+	case FieldLt:
+		return c < 0
+	case FieldLe:
+		return c <= 0
+	case FieldGt:
+		return c > 0
+	default: // FieldGe
+		return c >= 0
+	}
+}
+
+// lookupField 沿着 "." 分隔的路径在解码后的 JSON 里取出字段。
+func lookupField(doc any, path string) (any, bool) {
+	for name := range strings.SplitSeq(path, ".") {
+		obj, ok := doc.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if doc, ok = obj[name]; !ok {
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// jsonValue 把 Go 数字统一成 encoding/json 解码出的 float64，其它值原样返回。
+func jsonValue(v any) any {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	}
+	return v
+}
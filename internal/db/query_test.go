@@ -0,0 +1,139 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func collectQuery(t *testing.T, d *DB, q Query) string {
+	t.Helper()
+	var keys []string
+	for e, err := range d.Query(q) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, e.Key)
+	}
+	return fmt.Sprint(keys)
+}
+
+func TestQuery(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	users := map[string]string{
+		"user/1": `{"name":"ann","age":31,"address":{"city":"paris"}}`,
+		"user/2": `{"name":"bob","age":25,"address":{"city":"rome"}}`,
+		"user/3": `{"name":"cid","age":40,"address":{"city":"paris"},"admin":true}`,
+		"user/4": `not json`,
+		"userx":  `{"name":"zed","age":50}`,
+		"item/1": `{"name":"pen","age":1}`,
+	}
+	for k, v := range users {
+		if err := d.Put(k, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete("user/2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("user/2", []byte(`{"name":"bob","age":26,"address":{"city":"rome"}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		q    Query
+		want string
+	}{
+		{Query{Prefix: "user/"}, "[user/1 user/2 user/3 user/4]"},
+		{Query{Prefix: "user/", Fields: []FieldMatch{{Path: "address.city", Value: "paris"}}}, "[user/1 user/3]"},
+		{Query{Fields: []FieldMatch{{Path: "age", Op: FieldGe, Value: 31}}}, "[user/1 user/3 userx]"},
+		{Query{Fields: []FieldMatch{{Path: "age", Op: FieldLt, Value: 26.5}, {Path: "name", Op: FieldNe, Value: "pen"}}}, "[user/2]"},
+		{Query{Prefix: "user/", Fields: []FieldMatch{{Path: "admin", Op: FieldExists}}}, "[user/3]"},
+		{Query{Prefix: "user/", Fields: []FieldMatch{{Path: "admin", Op: FieldNe, Value: true}}}, "[user/1 user/2]"},
+		{Query{Start: "user/2", End: "userz", Prefix: "user"}, "[user/2 user/3 user/4 userx]"},
+		{Query{Prefix: "user/", Limit: 2}, "[user/1 user/2]"},
+		{Query{Filter: func(key string, value []byte) bool { return len(value) < 10 }}, "[user/4]"},
+		{Query{Prefix: "nothing"}, "[]"},
+	} {
+		if got := collectQuery(t, d, tc.q); got != tc.want {
+			t.Errorf("Query(%+v) = %s, want %s", tc.q, got, tc.want)
+		}
+	}
+
+	for e, err := range d.Query(Query{Fields: []FieldMatch{{Path: "age", Op: FieldGt, Value: true}}}) {
+		if !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("invalid query: %+v %v", e, err)
+		}
+	}
+}
+
+func TestQueryStopsEarly(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	value := make([]byte, 1000)
+	for i := 0; i < 5000; i++ {
+		if err := d.Put(fmt.Sprintf("k%05d", i), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Limit 在扫描内部生效：找够之后不再读后面的记录
+	calls := 0
+	q := Query{Limit: 3, Filter: func(key string, _ []byte) bool { calls++; return key >= "k00010" }}
+	if got := collectQuery(t, d, q); got != "[k00010 k00011 k00012]" || calls != 13 {
+		t.Fatalf("limit: %s after %d filter calls", got, calls)
+	}
+
+	// 扫描超过 queryScanBytes 之后分段，段与段之间不持锁：迭代中途可以写入
+	n := 0
+	for e, err := range d.Query(Query{Filter: func(key string, _ []byte) bool { return key >= "k04990" }}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Put("zz", nil); err != nil {
+			t.Fatal(err)
+		}
+		if e.Key < "k04990" {
+			t.Fatalf("unexpected %s", e.Key)
+		}
+		n++
+	}
+	if n != 10 {
+		t.Fatalf("matched %d entries", n)
+	}
+
+	// 调用方提前停止
+	calls = 0
+	for range d.Query(Query{Filter: func(string, []byte) bool { calls++; return true }}) {
+		break
+	}
+	if calls > int(queryScanBytes/1000)+1 {
+		t.Fatalf("filter called %d times after the caller stopped", calls)
+	}
+}
+
+func TestNarrowToPrefix(t *testing.T) {
+	for _, tc := range []struct{ start, end, prefix, wantStart, wantEnd string }{
+		{"", "", "ab", "ab", "ac"},
+		{"abc", "", "ab", "abc", "ac"},
+		{"", "aa", "ab", "ab", "aa"},
+		{"", "", "a\xff", "a\xff", "b"},
+		{"", "", "\xff\xff", "\xff\xff", ""},
+	} {
+		s, e := narrowToPrefix(tc.start, tc.end, tc.prefix)
+		if s != tc.wantStart || e != tc.wantEnd {
+			t.Errorf("narrowToPrefix(%q, %q, %q) = %q, %q", tc.start, tc.end, tc.prefix, s, e)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"iter"
+
+	"monolithdb/internal/mergeiter"
+	"monolithdb/internal/sstable"
+	"monolithdb/internal/types"
+)
+
+// ErrRangeTooLarge 表示 Range 的结果超过了 Options.MaxRangeBytes。
+// 大范围的扫描应该改用 RangeChunks。
+var ErrRangeTooLarge = errors.New("db: range result exceeds MaxRangeBytes")
+
+const (
+	// DefaultMaxRangeBytes 是 Options.MaxRangeBytes 的默认值。
+	DefaultMaxRangeBytes = 256 << 20
+
+	// DefaultRangeChunkBytes 是 RangeChunks 的 maxBytes <= 0 时每块的大小。
+	DefaultRangeChunkBytes = 1 << 20
+)
+
+// Range 返回 [start, end) 内所有可见的 key（按 key 升序），已删除和已过期的会被跳过。
+// start 为空表示从头开始，end 为空表示直到末尾。
+//
+// 结果全部放在内存里：累计的 key+value 字节数超过 Options.MaxRangeBytes 时返回
+// ErrRangeTooLarge，而不是把整张表读进内存。不确定范围大小时使用 RangeChunks。
+func (d *DB) Range(start, end string) ([]types.Entry, error) {
+	return d.RangeContext(context.Background(), start, end)
+}
+
+// RangeContext 与 Range 相同，但扫描过程中 ctx 被取消或超时时停止扫描并返回 ctx.Err()。
+func (d *DB) RangeContext(ctx context.Context, start, end string) ([]types.Entry, error) {
+	start, end = d.normKey(start), d.normKey(end)
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	limit := d.opts.maxRangeBytes()
+	var out []types.Entry
+	var size int64
+	for e, err := range d.mergeRange(ctx, start, end, d.now()) {
+		if err != nil {
+			return nil, err
+		}
+		size += entrySize(e)
+		if limit > 0 && size > limit {
+			return nil, ErrRangeTooLarge
+		}
+		if e.Value, err = d.interceptRead(e.Key, e.Value); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// RangeChunks 按 key 升序分块返回 [start, end) 内所有可见的记录。
+// 每块的 key+value 字节数不超过 maxBytes（单条记录超过 maxBytes 时独占一块），
+// maxBytes <= 0 表示 DefaultRangeChunkBytes。任何时刻内存里最多只有一块结果。
+//
+// 每块在一次读锁内读取，块与块之间会释放锁，所以调用方处理一块时可以继续读写数据库；
+// 代价是整个结果不是同一时刻的快照：已经返回过的 key 之后的修改不会再出现，
+// 尚未返回的 key 反映读取那一块时的状态。
+func (d *DB) RangeChunks(start, end string, maxBytes int) iter.Seq2[[]types.Entry, error] {
+	return d.RangeChunksContext(context.Background(), start, end, maxBytes)
+}
+
+// RangeChunksContext 与 RangeChunks 相同，但 ctx 被取消或超时时（包括读取一块的中途）
+// 迭代器产出 ctx.Err() 并结束。
+func (d *DB) RangeChunksContext(ctx context.Context, start, end string, maxBytes int) iter.Seq2[[]types.Entry, error] {
+	return d.RangeChunksWithOptionsContext(ctx, start, end, maxBytes, ReadOptions{})
+}
+
+// RangeChunksWithOptions 与 RangeChunks 相同，但按 ro 读取，例如 ro.KeysOnly 为 true 时只返回 key。
+func (d *DB) RangeChunksWithOptions(start, end string, maxBytes int, ro ReadOptions) iter.Seq2[[]types.Entry, error] {
+	return d.RangeChunksWithOptionsContext(context.Background(), start, end, maxBytes, ro)
+}
+
+// RangeChunksWithOptionsContext 与 RangeChunksContext 相同，但按 ro 读取。
+// KeysOnly 时每块的大小只按 key 的字节数计算。
+func (d *DB) RangeChunksWithOptionsContext(ctx context.Context, start, end string, maxBytes int, ro ReadOptions) iter.Seq2[[]types.Entry, error] {
+	if maxBytes <= 0 {
+		maxBytes = DefaultRangeChunkBytes
+	}
+	start, end = d.normKey(start), d.normKey(end)
+	return func(yield func([]types.Entry, error) bool) {
+		from, after := start, false
+		for {
+			chunk, more, err := d.rangeChunk(ctx, from, after, end, int64(maxBytes), ro)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if len(chunk) > 0 && !yield(chunk, nil) {
+				return
+			}
+			if !more {
+				return
+			}
+			from, after = chunk[len(chunk)-1].Key, true
+		}
+	}
+}
+
+// rangeChunk 读取 [from, end) 内最多 maxBytes 字节的记录；after 为 true 时跳过 from 本身。
+// more 表示因为达到 maxBytes 而提前结束。
+func (d *DB) rangeChunk(ctx context.Context, from string, after bool, end string, maxBytes int64, ro ReadOptions) (chunk []types.Entry, more bool, err error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var size int64
+	for e, err := range d.mergeRangeWithOptions(ctx, from, end, d.now(), ro) {
+		if err != nil {
+			return nil, false, err
+		}
+		if after && d.cmp.Compare(e.Key, from) <= 0 {
+			continue
+		}
+		if len(chunk) > 0 && size+entrySize(e) > maxBytes {
+			return chunk, true, nil
+		}
+		size += entrySize(e)
+		if !ro.KeysOnly {
+			if e.Value, err = d.interceptRead(e.Key, e.Value); err != nil {
+				return nil, false, err
+			}
+		}
+		chunk = append(chunk, e)
+	}
+	return chunk, false, nil
+}
+
+func entrySize(e types.Entry) int64 { return int64(len(e.Key) + len(e.Value)) }
+
+// mergeRange 按 key 升序流式返回 [start, end) 内所有可见记录：MemTable 与各 SST 逐条归并，
+// 同一个 key 取最新的版本，跳过 tombstone 和在 now 时已过期的记录。
+// 每归并一个 key 检查一次 ctx，取消时产出 ctx.Err() 并结束。
+//
+// 调用方必须在迭代期间持有读锁。
+func (d *DB) mergeRange(ctx context.Context, start, end string, now int64) iter.Seq2[types.Entry, error] {
+	return d.mergeRangeWithOptions(ctx, start, end, now, ReadOptions{})
+}
+
+// mergeRangeWithOptions 与 mergeRange 相同，但按 ro 读取。ro.KeysOnly 时不读取 value，
+// 也不解析值日志引用，产出的 Value 都是 nil。
+func (d *DB) mergeRangeWithOptions(ctx context.Context, start, end string, now int64, ro ReadOptions) iter.Seq2[types.Entry, error] {
+	return func(yield func(types.Entry, error) bool) {
+		// children[0] 是 MemTable，之后是不可变 MemTable 和 SST（都是 newest -> oldest）。
+		// MemTable 也按需逐条读取，而不是先拷贝整个范围：RangeChunks 每块只读到块的末尾为止
+		children := []mergeiter.Iterator{d.mem.NewIterator(!ro.KeysOnly)}
+		for i := len(d.imm) - 1; i >= 0; i-- {
+			children = append(children, d.imm[i].mem.NewIterator(!ro.KeysOnly))
+		}
+		sro := d.sstReadOptions(ro)
+		for _, p := range d.versions.current().tables {
+			r, release, err := d.versions.reader(p, sro)
+			if err != nil {
+				yield(types.Entry{}, err)
+				return
+			}
+			defer release()
+			children = append(children, sstable.NewIteratorWithOptions(r, sro))
+		}
+
+		// 同一个 key 取最新的版本（规则与点查相同，见 mergeiter.Merger），tombstone 由 Merger 跳过
+		m := mergeiter.New(children, mergeiter.Options{Comparer: d.cmp})
+		for ok := m.SeekGE(start); ok; ok = m.Next() {
+			if err := ctx.Err(); err != nil {
+				yield(types.Entry{}, err)
+				return
+			}
+			e := m.Entry()
+			if end != "" && d.cmp.Compare(e.Key, end) >= 0 {
+				return
+			}
+			if expired(e, now) {
+				continue
+			}
+			if ro.KeysOnly {
+				e.ValueRef = false
+			} else {
+				var err error
+				if e, err = d.versions.resolve(e); err != nil {
+					yield(types.Entry{}, err)
+					return
+				}
+			}
+			d.metrics.logicalReads.Add(1)
+			if !yield(e, nil) {
+				return
+			}
+		}
+		if err := m.Err(); err != nil {
+			yield(types.Entry{}, err)
+		}
+	}
+}
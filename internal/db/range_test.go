@@ -0,0 +1,136 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRangeChunks(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{MaxRangeBytes: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// 一半在 SST，一半在 MemTable；k050 被删除
+	for i := 0; i < 100; i++ {
+		if i == 50 {
+			if err := d.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := d.Put(fmt.Sprintf("k%03d", i), []byte("0123456")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Delete("k050"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 每条 11 字节：超过 MaxRangeBytes 的 Range 直接失败
+	if _, err := d.Range("", ""); !errors.Is(err, ErrRangeTooLarge) {
+		t.Fatalf("full Range: %v", err)
+	}
+	if got, err := d.Range("k010", "k020"); err != nil || len(got) != 10 {
+		t.Fatalf("small Range: %d %v", len(got), err)
+	}
+
+	var keys []string
+	chunks := 0
+	for chunk, err := range d.RangeChunks("k005", "", 100) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chunk) > 9 {
+			t.Fatalf("chunk of %d entries exceeds 100 bytes", len(chunk))
+		}
+		chunks++
+		for _, e := range chunk {
+			keys = append(keys, e.Key)
+		}
+		// 块与块之间不持有锁，可以写入
+		if err := d.Put("zzz", []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(keys) != 95 || keys[0] != "k005" || keys[44] != "k049" || keys[45] != "k051" || keys[94] != "zzz" {
+		t.Fatalf("RangeChunks returned %d keys: %v", len(keys), keys)
+	}
+	if chunks != 11 {
+		t.Fatalf("chunks = %d", chunks)
+	}
+
+	// 单条超过 maxBytes 时独占一块
+	n := 0
+	for chunk, err := range d.RangeChunks("k000", "k003", 1) {
+		if err != nil || len(chunk) != 1 {
+			t.Fatalf("chunk %v %v", chunk, err)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Fatalf("got %d chunks, want 3", n)
+	}
+}
+
+func TestRangeChunksKeysOnly(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	clock := NewManualClock(time.Unix(1000, 0))
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, Clock: clock, ValueLogThreshold: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// a* 在 SST 里，value 在值日志里；b* 在 MemTable 里；a3 被删除，b2 已过期
+	for i := 0; i < 10; i++ {
+		if err := d.Put(fmt.Sprintf("a%d", i), bytes.Repeat([]byte("v"), 100)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := d.Put(fmt.Sprintf("b%d", i), []byte("small")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Delete("a3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.PutWithTTL("b2", []byte("x"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Minute)
+
+	// 只取 key 时不读值日志：删掉它之后扫描照样成功
+	if err := os.RemoveAll(filepath.Join(dir, "vlog")); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for chunk, err := range d.RangeChunksWithOptions("", "", 8, ReadOptions{KeysOnly: true}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chunk) > 4 {
+			t.Fatalf("chunk of %d keys exceeds 8 bytes", len(chunk))
+		}
+		for _, e := range chunk {
+			if e.Value != nil || e.ValueRef {
+				t.Fatalf("keys-only entry %+v has a value", e)
+			}
+			keys = append(keys, e.Key)
+		}
+	}
+	want := []string{"a0", "a1", "a2", "a4", "a5", "a6", "a7", "a8", "a9", "b0", "b1", "b3", "b4"}
+	if !slices.Equal(keys, want) {
+		t.Fatalf("keys-only scan returned %v, want %v", keys, want)
+	}
+}
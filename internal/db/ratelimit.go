@@ -0,0 +1,84 @@
+package db
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter 是按字节计的令牌桶，限制 Flush 和 Compact 写 SST 的速度，
+// 避免后台写盘占满磁盘带宽、拖慢同一块盘上的前台读。
+//
+// 同一个 RateLimiter 可以通过 Options.RateLimiter 在多个 DB 之间共享，
+// 总写入速度不超过设置的速率。并发安全。
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒字节数，<= 0 表示不限速
+	tokens float64 // 可以为负：一次申请超过桶容量时，后续申请排在它后面等待
+	last   time.Time
+
+	throttled time.Duration // 累计等待时间
+}
+
+// rateLimiterRefills 是桶容量对应的时间片数：容量是 1/rateLimiterRefills 秒的配额，
+// 空闲之后最多允许这么多字节不等待直接写出。
+const rateLimiterRefills = 10
+
+// NewRateLimiter 返回一个每秒最多放行 bytesPerSec 字节的 RateLimiter，bytesPerSec <= 0 表示不限速。
+func NewRateLimiter(bytesPerSec int64) *RateLimiter {
+	l := &RateLimiter{rate: float64(bytesPerSec), last: time.Now()}
+	l.tokens = l.burst() // 新建时桶是满的
+	return l
+}
+
+// SetBytesPerSecond 修改速率，对之后的申请生效。bytesPerSec <= 0 表示不限速。
+func (l *RateLimiter) SetBytesPerSecond(bytesPerSec int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = float64(bytesPerSec)
+	l.tokens = min(l.tokens, l.burst())
+}
+
+// BytesPerSecond 返回当前速率，0 表示不限速。
+func (l *RateLimiter) BytesPerSecond() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int64(max(l.rate, 0))
+}
+
+// Throttled 返回因为限速累计等待的时间。
+func (l *RateLimiter) Throttled() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.throttled
+}
+
+// WaitN 申请写出 n 字节，配额不够时阻塞到配额补足为止。
+func (l *RateLimiter) WaitN(n int) {
+	if wait := l.reserve(time.Now(), n); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// reserve 在 now 时扣除 n 字节的配额，返回需要等待的时间。
+func (l *RateLimiter) reserve(now time.Time, n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return 0
+	}
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = min(l.burst(), l.tokens+elapsed.Seconds()*l.rate)
+		l.last = now
+	}
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.throttled += wait
+	return wait
+}
+
+func (l *RateLimiter) burst() float64 {
+	return max(l.rate/rateLimiterRefills, 1)
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	l := NewRateLimiter(1000) // 桶容量 100 字节
+	start := l.last
+
+	if wait := l.reserve(start, 100); wait != 0 {
+		t.Fatalf("first burst should not wait, got %v", wait)
+	}
+	// 桶空了：再要 50 字节需要等 50ms
+	if wait := l.reserve(start, 50); wait != 50*time.Millisecond {
+		t.Fatalf("expected 50ms, got %v", wait)
+	}
+	// 超过桶容量的申请也放行，只是后面的申请要排队
+	if wait := l.reserve(start.Add(50*time.Millisecond), 500); wait != 500*time.Millisecond {
+		t.Fatalf("expected 500ms, got %v", wait)
+	}
+	// 空闲很久之后最多攒下一个桶的配额
+	if wait := l.reserve(start.Add(time.Hour), 150); wait != 50*time.Millisecond {
+		t.Fatalf("expected 50ms after idle, got %v", wait)
+	}
+	if got := l.Throttled(); got != 600*time.Millisecond {
+		t.Fatalf("throttled = %v", got)
+	}
+
+	l.SetBytesPerSecond(0)
+	if wait := l.reserve(start.Add(time.Hour), 1<<30); wait != 0 {
+		t.Fatalf("unlimited limiter waited %v", wait)
+	}
+}
+
+func TestFlushHonorsRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(512 << 10)
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, RateLimiter: limiter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	value := bytes.Repeat([]byte("x"), 1024)
+	for i := 0; i < 16; i++ {
+		if err := d.Put(fmt.Sprintf("k%02d", i), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now()
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// 表（含 bloom filter）远超约 51KB 的桶容量，写表必须等待
+	if limiter.Throttled() == 0 {
+		t.Fatal("flush was not throttled")
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("flush finished in %v, faster than the limit allows", elapsed)
+	}
+	if v, ok, err := d.Get("k07"); err != nil || !ok || !bytes.Equal(v, value) {
+		t.Fatalf("Get(k07) = %v %v", ok, err)
+	}
+}
@@ -0,0 +1,32 @@
+package db
+
+import "monolithdb/internal/sstable"
+
+// ReadOptions 控制单次读取的行为。零值即默认配置。
+type ReadOptions struct {
+	// IgnoreFilters 为 true 时这次读取不使用 SST 的 bloom filter，总是查索引和数据区。
+	IgnoreFilters bool
+
+	// KeysOnly 为 true 时范围扫描（RangeChunksWithOptions）只返回 key：SST 里跳过 value 的字节、
+	// 不解压，MemTable 不复制 value，值日志里的大 value 也不会去读。返回的 Value 都是 nil，
+	// ReadInterceptors 不会被调用。适合先枚举 key、再按需点查的场景。点查忽略这个选项。
+	KeysOnly bool
+}
+
+// SetIgnoreFilters 在运行时开关所有读取对 bloom filter 的使用（初始值为 Options.IgnoreFilters）。
+// 怀疑 bloom 损坏造成假阴性，或者 repair 之后 bloom 已知过期时打开；代价是每次点查都要读数据区。
+func (d *DB) SetIgnoreFilters(ignore bool) {
+	d.ignoreFilters.Store(ignore)
+}
+
+// sstReadOptions 合并 DB 级开关与单次读取的选项。
+func (d *DB) sstReadOptions(ro ReadOptions) sstable.ReadOptions {
+	return sstable.ReadOptions{
+		IgnoreBloom:     ro.IgnoreFilters || d.ignoreFilters.Load(),
+		Comparer:        d.cmp,
+		Keys:            d.opts.Encryption,
+		VerifyChecksums: d.opts.ParanoidChecks,
+		KeysOnly:        ro.KeysOnly,
+		FS:              d.opts.FS,
+	}
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/sstable"
+)
+
+func TestIgnoreFilters(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	_ = d.Put("k", []byte("v"))
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 清空 SST 的 bloom 位图，模拟 filter 损坏导致的假阴性
+	path := d.versions.current().tables[0]
+	ti, err := sstable.Describe(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(make([]byte, ti.BloomBytes), int64(ti.BloomStart)+12); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	if _, ok, _ := d.Get("k"); ok {
+		t.Fatal("expected false negative through the zeroed bloom")
+	}
+	if v, ok, err := d.GetWithOptions("k", ReadOptions{IgnoreFilters: true}); err != nil || !ok || string(v) != "v" {
+		t.Fatalf("GetWithOptions = %q %v %v", v, ok, err)
+	}
+
+	d.SetIgnoreFilters(true)
+	if v, ok, _ := d.Get("k"); !ok || string(v) != "v" {
+		t.Fatalf("Get with DB-level toggle = %q %v", v, ok)
+	}
+	if _, ok, _ := d.TTL("k"); !ok {
+		t.Fatal("TTL should honor the DB-level toggle")
+	}
+	d.SetIgnoreFilters(false)
+	if _, ok, _ := d.Get("k"); ok {
+		t.Fatal("toggle did not turn off")
+	}
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"monolithdb/internal/sstable"
+	"monolithdb/internal/wal"
+)
+
+// RecoveryReport 描述 Open 回放 WAL 的结果，由 DB.Recovery 返回。
+type RecoveryReport struct {
+	WALRecords int   // 回放的记录数
+	WALBytes   int64 // 回放的记录占用的字节数
+
+	// Truncated 表示 WAL 尾部有无法解析的内容，已被丢弃（只可能在 Options.TolerateCorruptWALTail 下发生）。
+	// DiscardedBytes 是丢弃的字节数，BackupPath 是截断前原始 WAL 的备份（只读模式下为空，也不会截断文件）。
+	Truncated      bool
+	DiscardedBytes int64
+	BackupPath     string
+
+	// FilterChanged 表示 Options.WALReplayFilter 丢弃或改写过记录。
+	FilterChanged bool
+
+	Duration time.Duration
+}
+
+// Lossy 报告这次恢复是否丢失或改动了已写入 WAL 的数据。
+func (r RecoveryReport) Lossy() bool {
+	return r.Truncated || r.FilterChanged
+}
+
+// Recovery 返回打开数据库时 WAL 回放的结果。
+func (d *DB) Recovery() RecoveryReport {
+	return d.recovery
+}
+
+// paranoidCheck 是 Options.ParanoidChecks 在 Open 时做的完整校验：所有 SST 的元数据和数据块校验和，
+// 以及保留的 WAL 段（活跃 WAL 由 readWAL 严格解析）。
+func paranoidCheck(dir string, tables []string, opts Options) error {
+	ro := sstable.ReadOptions{Comparer: opts.comparer(), Keys: opts.Encryption, VerifyChecksums: true, FS: opts.FS}
+	for _, p := range tables {
+		if err := sstable.VerifyWithOptions(p, ro); err != nil {
+			return fmt.Errorf("paranoid check %s: %w", filepath.Base(p), err)
+		}
+	}
+	segs, err := listWALSegments(opts.fs(), dir)
+	if err != nil {
+		return err
+	}
+	for _, s := range segs {
+		if _, err := wal.ReplayWithOptions(s.path, opts.walOptions()); err != nil {
+			return fmt.Errorf("paranoid check %s: %w", filepath.Join(walArchiveDirName, filepath.Base(s.path)), err)
+		}
+	}
+	return nil
+}
+
+// readWAL 读取要回放的 WAL 记录。默认遇到损坏直接失败；
+// 开启 TolerateCorruptWALTail 时只保留损坏位置之前的记录，并（非只读模式下）先备份原文件到 lost/、
+// 再把 WAL 截断到最后一条完整记录，之后的追加才不会接在坏数据后面。
+func readWAL(dir, walPath string, opts Options) ([]wal.Record, RecoveryReport, error) {
+	var rep RecoveryReport
+	if !opts.TolerateCorruptWALTail {
+		records, err := wal.ReplayWithOptions(walPath, opts.walOptions())
+		if err != nil {
+			return nil, rep, err
+		}
+		rep.WALRecords = len(records)
+		if st, err := opts.fs().Stat(walPath); err == nil {
+			rep.WALBytes = st.Size()
+		}
+		return records, rep, nil
+	}
+
+	records, validSize, err := wal.ReplayValidWithOptions(walPath, opts.walOptions())
+	if err != nil {
+		return nil, rep, err
+	}
+	rep.WALRecords, rep.WALBytes = len(records), validSize
+
+	st, err := opts.fs().Stat(walPath)
+	if err != nil || st.Size() <= validSize {
+		return records, rep, nil
+	}
+	rep.Truncated, rep.DiscardedBytes = true, st.Size()-validSize
+	if opts.ReadOnly {
+		return records, rep, nil
+	}
+	if rep.BackupPath, err = quarantine(opts.fs(), filepath.Join(dir, lostDirName), walPath, true); err != nil {
+		return nil, rep, err
+	}
+	if err := opts.fs().Truncate(walPath, validSize); err != nil {
+		return nil, rep, err
+	}
+	return records, rep, nil
+}
+
+// reportRecovery 在恢复丢失或改动了数据时调用 Options.RecoveryAlert；没有设置时写日志，保证不会悄无声息。
+func reportRecovery(opts Options, rep RecoveryReport) {
+	if !rep.Lossy() {
+		return
+	}
+	if opts.RecoveryAlert != nil {
+		opts.RecoveryAlert(rep)
+		return
+	}
+	if rep.Truncated {
+		log.Printf("forgedb: recovery discarded %d bytes of corrupt WAL tail (backup: %s)", rep.DiscardedBytes, rep.BackupPath)
+	}
+	if rep.FilterChanged {
+		log.Printf("forgedb: recovery: WALReplayFilter skipped or rewrote records")
+	}
+}
@@ -0,0 +1,176 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/sstable"
+	"monolithdb/internal/wal"
+)
+
+func TestTolerantRecoveryReportsTruncation(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("b", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if rep := d.Recovery(); rep.Lossy() || rep.WALRecords != 0 {
+		t.Fatalf("fresh database recovery = %+v", rep)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟写到一半掉电：WAL 末尾多出半条记录
+	walPath := filepath.Join(dir, walFileName)
+	f, err := os.OpenFile(walPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{1, 5, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	if _, err := OpenWithOptions(dir, Options{DisableFsync: true}); err == nil {
+		t.Fatal("strict replay should fail on a corrupt tail")
+	}
+
+	var alerts []RecoveryReport
+	d, err = OpenWithOptions(dir, Options{
+		DisableFsync:           true,
+		TolerateCorruptWALTail: true,
+		RecoveryAlert:          func(r RecoveryReport) { alerts = append(alerts, r) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := d.Recovery()
+	if !rep.Truncated || rep.DiscardedBytes != 4 || rep.WALRecords != 2 || rep.BackupPath == "" {
+		t.Fatalf("recovery = %+v", rep)
+	}
+	if len(alerts) != 1 || alerts[0].DiscardedBytes != 4 {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+	if v, ok, _ := d.Get("b"); !ok || string(v) != "2" {
+		t.Fatalf("Get(b) = %q %v", v, ok)
+	}
+	// 截断之后追加的记录不会接在坏数据后面
+	if err := d.Put("c", []byte("3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := wal.Replay(walPath); err != nil || len(records) != 3 {
+		t.Fatalf("WAL after truncation: %d records, %v", len(records), err)
+	}
+
+	// 原始 WAL 的备份保留了被丢弃的内容
+	if st, err := os.Stat(rep.BackupPath); err != nil || st.Size() != rep.WALBytes+rep.DiscardedBytes {
+		t.Fatalf("backup: %v", err)
+	}
+
+	// 干净的 WAL 不会触发告警
+	alerts = nil
+	d, err = OpenWithOptions(dir, Options{
+		DisableFsync:           true,
+		TolerateCorruptWALTail: true,
+		RecoveryAlert:          func(r RecoveryReport) { alerts = append(alerts, r) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if len(alerts) != 0 || d.Recovery().Truncated {
+		t.Fatalf("unexpected alert on clean recovery: %+v", d.Recovery())
+	}
+}
+
+func TestWALCompressionRecovery(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	doc := bytes.Repeat([]byte(`{"id":1,"status":"active"},`), 200)
+
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, WALCompression: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("doc", doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	st, err := os.Stat(filepath.Join(dir, walFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Size() >= int64(len(doc)) {
+		t.Fatalf("wal size = %d, value size = %d", st.Size(), len(doc))
+	}
+
+	// 关闭压缩后重新打开依然能回放压缩过的记录
+	d, err = OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if v, ok, err := d.Get("doc"); err != nil || !ok || !bytes.Equal(v, doc) {
+		t.Fatalf("Get(doc) = %d bytes, %v, %v", len(v), ok, err)
+	}
+}
+
+func TestParanoidChecks(t *testing.T) {
+	if _, err := OpenWithOptions(t.TempDir(), Options{ParanoidChecks: true, TolerateCorruptWALTail: true}); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("ParanoidChecks + TolerateCorruptWALTail = %v, want ErrInvalidOptions", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, ParanoidChecks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 100 {
+		if err := d.Put(fmt.Sprintf("k%03d", i), []byte(fmt.Sprintf("value-%03d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	_ = d.Close()
+
+	// 只改 value 里的一个字节：元数据都完好，普通的 Open 发现不了
+	path := filepath.Join(dir, sstDirName, "000001.sst")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := bytes.Index(b, []byte("value-050"))
+	b[i+len("value-")] = 'X'
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenWithOptions(dir, Options{DisableFsync: true, ParanoidChecks: true}); !errors.Is(err, sstable.ErrCorruptSST) {
+		t.Fatalf("paranoid Open = %v, want ErrCorruptSST", err)
+	}
+	d, err = OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if v, ok, err := d.Get("k050"); err != nil || !ok || string(v) != "value-X50" {
+		t.Fatalf("Get(k050) = %q, %v", v, err)
+	}
+}
@@ -0,0 +1,211 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"monolithdb/internal/encrypt"
+	"monolithdb/internal/manifest"
+	"monolithdb/internal/sstable"
+	"monolithdb/internal/vfs"
+	"monolithdb/internal/wal"
+)
+
+// lostDirName 是 Repair 隔离无法恢复文件的子目录。
+const lostDirName = "lost"
+
+// RepairReport 描述 Repair 做了哪些修改。
+type RepairReport struct {
+	WALRecords        int      // WAL 中保留下来的记录数
+	WALTruncatedBytes int64    // WAL 尾部被截掉的字节数
+	RebuiltTables     []string // 元数据损坏、已从数据区重建的 SST
+	SalvagedTables    []SalvagedTable
+	QuarantinedFiles  []string // 无法恢复、已移动到 lost/ 的文件
+	RemovedTempFiles  []string // 清理掉的 .tmp 残留
+}
+
+// Repair 使用默认配置修复 dir 下的数据库，见 RepairWithOptions。
+func Repair(dir string) (RepairReport, error) {
+	return RepairWithOptions(dir, Options{})
+}
+
+// SalvagedTable 是数据区部分损坏、只恢复出部分记录的 SST（原文件备份在 lost/ 下）。
+type SalvagedTable struct {
+	Path   string
+	Report sstable.SalvageReport
+}
+
+// RepairWithOptions 尽力把一个损坏的数据目录恢复成可以 Open 的状态：
+//  1. WAL 截断到最后一条完整记录（原文件先备份到 lost/）
+//  2. 每个 SST 先做元数据校验；失败则尝试从数据区重建索引 / bloom；
+//     数据区也坏了就跳过损坏的区域、用剩下的记录重建（见 sstable.Salvage），
+//     一条记录也恢复不出来时整体移动到 lost/
+//  3. 按 opts 重新生成 manifest
+//
+// 调用时数据库不能处于打开状态。
+func RepairWithOptions(dir string, opts Options) (RepairReport, error) {
+	var rep RepairReport
+	fs := opts.fs()
+
+	if _, err := fs.Stat(dir); err != nil {
+		return rep, err
+	}
+	lostDir := filepath.Join(dir, lostDirName)
+
+	// 1) WAL
+	walPath := filepath.Join(dir, walFileName)
+	records, validSize, err := wal.ReplayValidWithOptions(walPath, opts.walOptions())
+	if err != nil {
+		return rep, err
+	}
+	rep.WALRecords = len(records)
+
+	if st, err := fs.Stat(walPath); err == nil && st.Size() > validSize {
+		if _, err := quarantine(fs, lostDir, walPath, true); err != nil {
+			return rep, err
+		}
+		if err := fs.Truncate(walPath, validSize); err != nil {
+			return rep, err
+		}
+		rep.WALTruncatedBytes = st.Size() - validSize
+	}
+
+	// 2) SSTables
+	sstDir := filepath.Join(dir, sstDirName)
+	if err := fs.MkdirAll(sstDir, 0o755); err != nil {
+		return rep, err
+	}
+
+	tmps, err := vfs.Glob(fs, sstDir, "*.tmp")
+	if err != nil {
+		return rep, err
+	}
+	for _, p := range tmps {
+		if err := fs.Remove(p); err != nil {
+			return rep, err
+		}
+		rep.RemovedTempFiles = append(rep.RemovedTempFiles, p)
+	}
+
+	tables, _, err := scanSSTables(fs, sstDir)
+	if err != nil {
+		return rep, err
+	}
+	for _, p := range tables {
+		ropts := sstable.ReadOptions{Comparer: opts.comparer(), Keys: opts.Encryption, FS: opts.FS}
+		verr := sstable.VerifyWithOptions(p, ropts)
+		if verr == nil {
+			continue
+		}
+		// 更新的引擎写出的表不是损坏：按旧格式重建只会把它当成垃圾隔离掉
+		// 缺少密钥也不是损坏：配好 Options.Encryption 之后表依然完好
+		if errors.Is(verr, sstable.ErrUnsupportedVersion) || errors.Is(verr, encrypt.ErrNoKeyProvider) || errors.Is(verr, encrypt.ErrUnknownKey) {
+			return rep, fmt.Errorf("repair %s: %w", filepath.Base(p), verr)
+		}
+
+		tmp := p + ".tmp"
+		wopts := sstable.WriterOptions{
+			Properties: sstable.Properties{
+				CreationReason: sstable.ReasonRepair,
+				InputFiles:     []string{filepath.Base(p)},
+			},
+			Comparer:   opts.comparer(),
+			Encryption: opts.Encryption,
+			FS:         opts.FS,
+		}
+		wopts = opts.withFilter(wopts)
+		entries, err := sstable.ScanDataWithOptions(p, ropts)
+		if err == nil && len(entries) > 0 {
+			if err := sstable.WriteTableWithOptions(tmp, entries, wopts); err != nil {
+				_ = fs.Remove(tmp)
+				return rep, err
+			}
+			if err := replaceTable(fs, lostDir, p, tmp); err != nil {
+				return rep, err
+			}
+			rep.RebuiltTables = append(rep.RebuiltTables, p)
+			continue
+		}
+		if err != nil {
+			srep, serr := sstable.SalvageWithOptions(p, tmp, ropts, wopts)
+			if serr == nil {
+				if err := replaceTable(fs, lostDir, p, tmp); err != nil {
+					return rep, err
+				}
+				rep.SalvagedTables = append(rep.SalvagedTables, SalvagedTable{Path: p, Report: srep})
+				continue
+			}
+			_ = fs.Remove(tmp)
+		}
+
+		dst, err := quarantine(fs, lostDir, p, false)
+		if err != nil {
+			return rep, err
+		}
+		rep.QuarantinedFiles = append(rep.QuarantinedFiles, dst)
+	}
+
+	// 3) manifest：按当前配置重写，还能读出的文件编号保留下来
+	m := opts.fingerprint()
+	if old, err := manifest.LoadFS(fs, filepath.Join(dir, manifest.FileName)); err == nil && old != nil {
+		m.NextFileNumber = old.NextFileNumber
+	}
+	if err := manifest.WriteFS(fs, filepath.Join(dir, manifest.FileName), m); err != nil {
+		return rep, err
+	}
+
+	return rep, nil
+}
+
+// replaceTable 把原表备份到 lostDir，再用重建好的 tmp 替换它。
+func replaceTable(fs vfs.FS, lostDir, path, tmp string) error {
+	if _, err := quarantine(fs, lostDir, path, true); err != nil {
+		_ = fs.Remove(tmp)
+		return err
+	}
+	if err := fs.Rename(tmp, path); err != nil {
+		_ = fs.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// quarantine 把 src 移动（keep=true 时复制、保留原文件）到 lostDir 下，返回目标路径。
+// 目标已存在时追加数字后缀，避免覆盖之前隔离的文件。
+func quarantine(fs vfs.FS, lostDir, src string, keep bool) (string, error) {
+	if err := fs.MkdirAll(lostDir, 0o755); err != nil {
+		return "", err
+	}
+
+	base := filepath.Base(src)
+	dst := filepath.Join(lostDir, base)
+	for i := 1; ; i++ {
+		if _, err := fs.Stat(dst); os.IsNotExist(err) {
+			break
+		}
+		dst = filepath.Join(lostDir, fmt.Sprintf("%s.%d", base, i))
+	}
+
+	if !keep {
+		return dst, fs.Rename(src, dst)
+	}
+
+	in, err := fs.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := fs.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return "", err
+	}
+	return dst, out.Close()
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/manifest"
+)
+
+func TestRepairTruncatesTornWAL(t *testing.T) {
+	dir := t.TempDir()
+	dbDir := filepath.Join(dir, "data")
+
+	d, err := Open(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("b", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	_ = d.Close()
+
+	// 模拟写了一半的记录：追加一个不完整的 header
+	walPath := filepath.Join(dbDir, walFileName)
+	f, err := os.OpenFile(walPath, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{0, 5, 0}); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	if _, err := Open(dbDir); err == nil {
+		t.Fatalf("expected Open to fail on torn WAL")
+	}
+
+	rep, err := Repair(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.WALRecords != 2 || rep.WALTruncatedBytes != 3 {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+
+	d2, err := Open(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d2.Close() }()
+
+	v, ok, err := d2.Get("b")
+	if err != nil || !ok || !bytes.Equal(v, []byte("2")) {
+		t.Fatalf("expected b=2 after repair, got v=%q ok=%v err=%v", v, ok, err)
+	}
+}
+
+func TestRepairRebuildsAndQuarantinesTables(t *testing.T) {
+	dir := t.TempDir()
+	dbDir := filepath.Join(dir, "data")
+
+	d, err := Open(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("b", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	_ = d.Close()
+
+	sstDir := filepath.Join(dbDir, sstDirName)
+	first := filepath.Join(sstDir, "000001.sst")
+	second := filepath.Join(sstDir, "000002.sst")
+
+	// 000001：只破坏 footer，数据区完好 => 应重建
+	st, err := os.Stat(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(first, st.Size()-4); err != nil {
+		t.Fatal(err)
+	}
+
+	// 000002：magic 都坏了 => 只能隔离
+	if err := os.WriteFile(second, []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// manifest 也坏了 => 应重新生成
+	if err := os.WriteFile(filepath.Join(dbDir, manifest.FileName), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := Repair(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.RebuiltTables) != 1 || rep.RebuiltTables[0] != first {
+		t.Fatalf("expected %s to be rebuilt, got %+v", first, rep)
+	}
+	if len(rep.QuarantinedFiles) != 1 {
+		t.Fatalf("expected one quarantined file, got %+v", rep)
+	}
+	if _, err := os.Stat(second); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be moved away, stat err=%v", second, err)
+	}
+
+	d2, err := Open(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d2.Close() }()
+
+	v, ok, err := d2.Get("a")
+	if err != nil || !ok || !bytes.Equal(v, []byte("1")) {
+		t.Fatalf("expected a=1 after rebuild, got v=%q ok=%v err=%v", v, ok, err)
+	}
+}
+
+func TestRepairSalvagesPartiallyCorruptTable(t *testing.T) {
+	dbDir := filepath.Join(t.TempDir(), "data")
+	d, err := Open(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 100 {
+		if err := d.Put(fmt.Sprintf("k%03d", i), []byte(fmt.Sprintf("v%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	_ = d.Close()
+
+	// 数据区中间一条记录的长度字段坏了，footer 也坏了：ScanData 重建不了，只能跳过损坏的区域
+	path := filepath.Join(dbDir, sstDirName, "000001.sst")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// k050 这条记录紧跟在 k049 的 value "v49" 之后，开头是 keyLen、valLen
+	i := bytes.Index(b, []byte("k049v49")) + len("k049v49")
+	copy(b[i:i+8], bytes.Repeat([]byte{0xff}, 8))
+	if err := os.WriteFile(path, b[:len(b)-4], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := Repair(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.SalvagedTables) != 1 || rep.SalvagedTables[0].Path != path || len(rep.SalvagedTables[0].Report.Lost) == 0 {
+		t.Fatalf("expected %s to be salvaged, got %+v", path, rep)
+	}
+	if n := rep.SalvagedTables[0].Report.Recovered; n < 95 || n >= 100 {
+		t.Fatalf("recovered %d records", n)
+	}
+
+	d, err = Open(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, k := range []string{"k000", "k048", "k051", "k099"} {
+		if _, ok, err := d.Get(k); err != nil || !ok {
+			t.Fatalf("Get(%q) after salvage = %v, %v", k, ok, err)
+		}
+	}
+}
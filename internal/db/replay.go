@@ -0,0 +1,59 @@
+package db
+
+import (
+	"fmt"
+
+	"monolithdb/internal/wal"
+)
+
+// ReplayDecision 是 WALReplayFilter 对一条记录的处理结果。
+type ReplayDecision int
+
+const (
+	ReplayKeep    ReplayDecision = iota // 原样回放
+	ReplaySkip                          // 跳过这条记录
+	ReplayReplace                       // 回放 filter 返回的新记录
+)
+
+// WALReplayFilter 在 Open 回放 WAL 时按顺序对每条记录调用，可以只观察（总是返回 ReplayKeep），
+// 也可以丢弃或改写记录：例如跳过一条会导致崩溃的 key，或者把旧编码的 value 迁移成新格式。
+// Batch 记录作为一个整体传入。
+type WALReplayFilter func(r wal.Record) (ReplayDecision, wal.Record)
+
+// replayWAL 把 records 回放到 MemTable，返回 filter 是否丢弃或改写了记录。
+// apply 的 i 是记录在 records 中的下标，被跳过的记录同样占用一个下标（序号）。
+func replayWAL(opts Options, records []wal.Record, apply func(i int, r wal.Record) error) (changed bool, err error) {
+	if opts.WALReplayFilter != nil && !opts.UnsafeRecovery {
+		return false, fmt.Errorf("%w: WALReplayFilter requires UnsafeRecovery", ErrInvalidOptions)
+	}
+	for i, r := range records {
+		if opts.WALReplayFilter != nil {
+			decision, nr := opts.WALReplayFilter(r)
+			switch decision {
+			case ReplaySkip:
+				changed = true
+				continue
+			case ReplayReplace:
+				changed = true
+				r = nr
+			}
+		}
+		if err := apply(i, r); err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// persistReplay 在 filter 改动过回放结果后，把 MemTable 刷成 SST 并轮换 WAL，
+// 否则下次不带 filter 打开时，原始记录又会被回放出来。原始 WAL 归档到 wal/ 下，不会丢失。
+func (d *DB) persistReplay() error {
+	if d.mem.Len() > 0 || len(d.imm) > 0 {
+		return d.Flush()
+	}
+
+	// 所有记录都被丢弃：没有可刷的数据，直接轮换 WAL
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.switchWAL()
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"monolithdb/internal/wal"
+)
+
+func TestWALReplayFilter(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = d.Put("poison", []byte("boom"))
+	_ = d.Put("cfg", []byte("v1:old"))
+	_ = d.Put("keep", []byte("x"))
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []string
+	filter := func(r wal.Record) (ReplayDecision, wal.Record) {
+		seen = append(seen, r.Key)
+		switch {
+		case r.Key == "poison":
+			return ReplaySkip, r
+		case strings.HasPrefix(string(r.Value), "v1:"):
+			r.Value = []byte("v2:" + strings.TrimPrefix(string(r.Value), "v1:"))
+			return ReplayReplace, r
+		}
+		return ReplayKeep, r
+	}
+
+	// 没有明确开启 UnsafeRecovery 时拒绝使用 filter
+	if _, err := OpenWithOptions(dir, Options{WALReplayFilter: filter}); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("err = %v, want ErrInvalidOptions", err)
+	}
+
+	d, err = OpenWithOptions(dir, Options{WALReplayFilter: filter, UnsafeRecovery: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(seen, ","); got != "poison,cfg,keep" {
+		t.Fatalf("filter saw %s", got)
+	}
+	check := func() {
+		t.Helper()
+		if _, ok, _ := d.Get("poison"); ok {
+			t.Fatal("poison should have been skipped")
+		}
+		if v, _, _ := d.Get("cfg"); string(v) != "v2:old" {
+			t.Fatalf("cfg = %q", v)
+		}
+		if v, _, _ := d.Get("keep"); string(v) != "x" {
+			t.Fatalf("keep = %q", v)
+		}
+	}
+	check()
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// filter 的结果已经持久化：不带 filter 重新打开，结果不变
+	d, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	check()
+}
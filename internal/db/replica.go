@@ -0,0 +1,90 @@
+package db
+
+import "monolithdb/internal/wal"
+
+// SetCommitHook 设置写入提交后的回调，fn 为 nil 表示取消。
+//
+// 每次 Put / Delete / PutWithTTL / Touch / ApplyRecord 成功后，fn 会在写锁内、
+// 按提交顺序被调用一次，参数是写入 WAL 的那条记录（过期时间为绝对时间）。
+// fn 不能调用 DB 的任何方法，并且应该尽快返回。复制模块用它来收集要发给 follower 的记录。
+func (d *DB) SetCommitHook(fn func(wal.Record)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.commitHook = fn
+}
+
+// commit 在每条记录写入 WAL 并应用后（写锁内）调用：分配序号、调用提交回调并通知 watcher。
+func (d *DB) commit(r wal.Record) {
+	d.lastSeq++
+	d.metrics.memBytesIn.Add(recordBytes(r))
+	if d.seqTimes != nil {
+		d.seqTimes.mark(d.lastSeq, d.now())
+	}
+	if d.commitHook != nil {
+		d.commitHook(r)
+	}
+	d.notifyWatchers(d.lastSeq, r)
+}
+
+// ApplyRecord 把一条（来自其他节点的）WAL 记录原样写入本库：先写 WAL，再应用到 MemTable。
+// 与 PutWithTTL 不同，记录里的过期时间是绝对时间，不会重新计算。
+//
+// 同一条记录重复应用的结果不变，所以 follower 追赶时重放已经应用过的记录是安全的。
+func (d *DB) ApplyRecord(r wal.Record) error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var err error
+	switch r.Op {
+	case wal.OpPut:
+		err = d.wal.AppendPut(r.Key, r.Value)
+	case wal.OpDelete:
+		err = d.wal.AppendDelete(r.Key)
+	case wal.OpPutTTL:
+		err = d.wal.AppendPutTTL(r.Key, r.Value, r.ExpiresAt)
+	case wal.OpTouch:
+		err = d.wal.AppendTouch(r.Keys, r.ExpiresAt)
+	case wal.OpBatch:
+		err = d.wal.AppendBatch(r.Batch)
+	default:
+		err = wal.ErrCorruptWAL
+	}
+	if err != nil {
+		return err
+	}
+	if err := applyRecord(d.mem, d.imm, d.versions, r, d.lastSeq+1, d.sstReadOptions(ReadOptions{})); err != nil {
+		return err
+	}
+
+	d.afterApply(r)
+	d.commit(r)
+	return nil
+}
+
+// afterApply 在记录应用到 MemTable 之后更新读缓存和淘汰状态。
+func (d *DB) afterApply(r wal.Record) {
+	switch r.Op {
+	case wal.OpPut, wal.OpPutTTL:
+		d.invalidateCache(r.Key)
+		if d.evict != nil {
+			d.evict.added(r.Key, len(r.Value))
+		}
+	case wal.OpDelete:
+		d.invalidateCache(r.Key)
+		if d.evict != nil {
+			d.evict.removed(r.Key)
+		}
+	case wal.OpTouch:
+		for _, k := range r.Keys {
+			d.invalidateCache(k)
+		}
+	case wal.OpBatch:
+		for _, sub := range r.Batch {
+			d.afterApply(sub)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"monolithdb/internal/manifest"
+	"monolithdb/internal/vfs"
+	"monolithdb/internal/wal"
+)
+
+// ErrRestoreTarget 表示要恢复到的位置早于基准 checkpoint，无法从它恢复。
+var ErrRestoreTarget = errors.New("db: restore target is older than the checkpoint")
+
+// Checkpoint 在 dir（必须不存在或为空）下创建数据库当前状态的一致副本，返回它包含的最后一条记录的序号。
+// 先 Flush，再把所有 SST 硬链接过去（跨文件系统时复制），所以很快，也几乎不占额外空间。
+//
+// checkpoint 本身就是一个可以直接打开的数据目录，也是 RestoreToSequence / RestoreToTime 的基准。
+func (d *DB) Checkpoint(dir string) (uint64, error) {
+	if d.opts.ReadOnly {
+		return 0, ErrReadOnly
+	}
+	if err := prepareEmptyDir(d.opts.fs(), dir); err != nil {
+		return 0, err
+	}
+
+	// 整个过程持有写锁：SST 列表不会变化，复制的文件和序号一一对应
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.flushLocked(); err != nil {
+		return 0, err
+	}
+	err := d.writeCheckpoint(dir)
+	if err != nil {
+		_ = d.opts.fs().RemoveAll(dir)
+		return 0, err
+	}
+	return d.lastSeq, nil
+}
+
+func (d *DB) writeCheckpoint(dir string) error {
+	fs, sync := d.opts.fs(), !d.opts.DisableFsync
+	sstDir := filepath.Join(dir, sstDirName)
+	if err := fs.MkdirAll(sstDir, 0o755); err != nil {
+		return err
+	}
+	for _, p := range d.versions.current().tables {
+		if err := linkOrCopy(fs, p, filepath.Join(sstDir, filepath.Base(p)), sync); err != nil {
+			return err
+		}
+	}
+	// 值日志文件写完之后同样不再修改；没有被引用的文件在打开 checkpoint 时删除
+	ids, err := listValueLogs(fs, d.versions.vlog.dir)
+	if err != nil {
+		return err
+	}
+	vlogDir := filepath.Join(dir, vlogDirName)
+	if len(ids) > 0 {
+		if err := fs.MkdirAll(vlogDir, 0o755); err != nil {
+			return err
+		}
+	}
+	for _, id := range ids {
+		if err := linkOrCopy(fs, d.versions.vlog.path(id), filepath.Join(vlogDir, filepath.Base(d.versions.vlog.path(id))), sync); err != nil {
+			return err
+		}
+	}
+	if err := linkOrCopy(fs, filepath.Join(d.dir, manifest.FileName), filepath.Join(dir, manifest.FileName), sync); err != nil {
+		return err
+	}
+	// WAL 是空的：打开 checkpoint 时 LastSequence 就是 WALSEQ - 1
+	seq := []byte(strconv.FormatUint(d.lastSeq+1, 10) + "\n")
+	if err := vfs.WriteFile(fs, filepath.Join(dir, walSeqFileName), seq, 0o644); err != nil {
+		return err
+	}
+	if !sync {
+		return nil
+	}
+	if err := syncDir(fs, sstDir); err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		if err := syncDir(fs, vlogDir); err != nil {
+			return err
+		}
+	}
+	return syncDir(fs, dir)
+}
+
+// RestoreOptions 是 RestoreToSequence / RestoreToTime 的数据来源。
+type RestoreOptions struct {
+	// Checkpoint 是 DB.Checkpoint 创建的基准目录，也可以是 DB.Backup / IncrementalBackup 创建的备份，不会被修改。
+	Checkpoint string
+
+	// WALArchiveDir 是原库的 Options.WALArchiveDir，保存 checkpoint 之后的 WAL 段。
+	WALArchiveDir string
+
+	// Options 是打开恢复出的数据库时使用的配置，比较器、加密密钥等必须与原库一致。
+	// 其中的 WALArchiveDir / WALArchiveHook 会被忽略，恢复过程不会写原库的归档。
+	Options Options
+}
+
+// RestoreToSequence 在 dir（必须不存在或为空）下重建原库提交完第 seq 条记录时的状态：
+// 复制 ro.Checkpoint，再按顺序回放 ro.WALArchiveDir 里序号在 (checkpoint, seq] 内的记录。
+// seq 早于 checkpoint 时返回 ErrRestoreTarget，归档缺段时返回 ErrWALArchiveGap；出错时 dir 会被清理。
+func RestoreToSequence(dir string, seq uint64, ro RestoreOptions) error {
+	fs := ro.Options.fs()
+	if err := prepareEmptyDir(fs, dir); err != nil {
+		return err
+	}
+	if err := restore(dir, seq, ro); err != nil {
+		_ = fs.RemoveAll(dir)
+		return err
+	}
+	return nil
+}
+
+// RestoreToTime 与 RestoreToSequence 相同，但恢复到原库在时刻 t 的状态。
+// 时间与序号的对应关系来自归档目录里的 SEQTIME，精度为一秒：t 所在的这一秒内提交的记录都会包含在内。
+// t 晚于归档里的所有记录时回放整个归档。
+func RestoreToTime(dir string, t time.Time, ro RestoreOptions) error {
+	seq, ok, err := seqAtTime(ro.Options.fs(), ro.WALArchiveDir, t)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		segs, err := listWALArchive(ro.Options.fs(), ro.WALArchiveDir)
+		if err != nil {
+			return err
+		}
+		if len(segs) == 0 {
+			return fmt.Errorf("%w: no archived WAL segments in %s", ErrWALArchiveGap, ro.WALArchiveDir)
+		}
+		seq = segs[len(segs)-1].LastSeq
+	}
+	return RestoreToSequence(dir, seq, ro)
+}
+
+func restore(dir string, seq uint64, ro RestoreOptions) error {
+	fs := ro.Options.fs()
+	copyBase := copyTree
+	if isBackup(fs, ro.Checkpoint) {
+		copyBase = restoreBackupFiles
+	}
+	if err := copyBase(fs, ro.Checkpoint, dir); err != nil {
+		return err
+	}
+
+	opts := ro.Options
+	opts.ReadOnly = false
+	opts.WALArchiveDir, opts.WALArchiveHook = "", nil
+	d, err := OpenWithOptions(dir, opts)
+	if err != nil {
+		return err
+	}
+	base := d.LastSequence()
+	if seq < base {
+		_ = d.Close()
+		return fmt.Errorf("%w: checkpoint is at %d, target is %d", ErrRestoreTarget, base, seq)
+	}
+	if seq > base {
+		err = ReplayWALArchive(ro.WALArchiveDir, opts, base+1, seq, func(_ uint64, r wal.Record) error {
+			return d.ApplyRecord(r)
+		})
+		if err == nil {
+			err = d.Flush()
+		}
+	}
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// prepareEmptyDir 创建 dir；dir 已存在时必须是空目录。
+func prepareEmptyDir(fs vfs.FS, dir string) error {
+	if err := checkEmptyDir(fs, dir); err != nil {
+		return err
+	}
+	return fs.MkdirAll(dir, 0o755)
+}
+
+// checkEmptyDir 检查 dir 不存在或者是空目录，不做任何修改。
+func checkEmptyDir(fs vfs.FS, dir string) error {
+	entries, err := fs.List(dir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	case len(entries) > 0:
+		return fmt.Errorf("db: %s is not empty", dir)
+	}
+	return nil
+}
+
+// copyTree 把 src 下的所有文件（保持目录结构）复制到 dst，不会再修改的 SST 和值日志文件用硬链接。
+func copyTree(fs vfs.FS, src, dst string) error {
+	if err := fs.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	names, err := fs.List(src)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		p, target := filepath.Join(src, name), filepath.Join(dst, name)
+		st, err := fs.Stat(p)
+		if err != nil {
+			return err
+		}
+		switch ext := filepath.Ext(p); {
+		case st.IsDir():
+			err = copyTree(fs, p, target)
+		case ext == ".sst" || ext == ".vlog":
+			err = linkOrCopy(fs, p, target, false)
+		default:
+			err = copyFile(fs, p, target, false)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkOrCopy 把 src 硬链接到 dst，无法硬链接（例如跨文件系统）时复制一份。
+// SST 和 manifest 写完之后都不会再原地修改，所以两边共享同一个 inode 是安全的。
+func linkOrCopy(fs vfs.FS, src, dst string, sync bool) error {
+	if err := fs.Link(src, dst); err == nil {
+		return nil
+	}
+	return copyFile(fs, src, dst, sync)
+}
+
+func copyFile(fs vfs.FS, src, dst string, sync bool) error {
+	in, err := fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := fs.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil && sync {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPointInTimeRestore(t *testing.T) {
+	root := t.TempDir()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	archive := filepath.Join(root, "archive")
+	d, err := OpenWithOptions(filepath.Join(root, "data"), Options{DisableFsync: true, Clock: clock, WALArchiveDir: archive})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.Put("a", []byte("1")); err != nil { // seq 1
+		t.Fatal(err)
+	}
+	cp := filepath.Join(root, "checkpoint")
+	if seq, err := d.Checkpoint(cp); err != nil || seq != 1 {
+		t.Fatalf("Checkpoint = %d, %v", seq, err)
+	}
+
+	clock.Advance(10 * time.Second)
+	if err := d.Put("a", []byte("2")); err != nil { // seq 2
+		t.Fatal(err)
+	}
+	if err := d.Put("b", []byte("1")); err != nil { // seq 3
+		t.Fatal(err)
+	}
+	clock.Advance(10 * time.Second)
+	if err := d.Delete("a"); err != nil { // seq 4
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	ro := RestoreOptions{Checkpoint: cp, WALArchiveDir: archive, Options: Options{DisableFsync: true}}
+	check := func(dir string, wantSeq uint64, want map[string]string) {
+		t.Helper()
+		r, err := OpenWithOptions(dir, Options{DisableFsync: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		if r.LastSequence() != wantSeq {
+			t.Fatalf("%s: LastSequence = %d, want %d", dir, r.LastSequence(), wantSeq)
+		}
+		entries, err := r.Range("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string)
+		for _, e := range entries {
+			got[e.Key] = string(e.Value)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: data = %v, want %v", dir, got, want)
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Fatalf("%s: data = %v, want %v", dir, got, want)
+			}
+		}
+	}
+
+	check(cp, 1, map[string]string{"a": "1"})
+
+	if err := RestoreToSequence(filepath.Join(root, "seq3"), 3, ro); err != nil {
+		t.Fatal(err)
+	}
+	check(filepath.Join(root, "seq3"), 3, map[string]string{"a": "2", "b": "1"})
+
+	if err := RestoreToTime(filepath.Join(root, "t5"), start.Add(5*time.Second), ro); err != nil {
+		t.Fatal(err)
+	}
+	check(filepath.Join(root, "t5"), 1, map[string]string{"a": "1"})
+
+	if err := RestoreToTime(filepath.Join(root, "t15"), start.Add(15*time.Second), ro); err != nil {
+		t.Fatal(err)
+	}
+	check(filepath.Join(root, "t15"), 3, map[string]string{"a": "2", "b": "1"})
+
+	if err := RestoreToTime(filepath.Join(root, "latest"), start.Add(time.Hour), ro); err != nil {
+		t.Fatal(err)
+	}
+	check(filepath.Join(root, "latest"), 4, map[string]string{"b": "1"})
+
+	// 早于 checkpoint 的目标无法恢复，失败时清理目标目录
+	if err := RestoreToSequence(filepath.Join(root, "old"), 0, ro); !errors.Is(err, ErrRestoreTarget) {
+		t.Fatalf("restore before checkpoint: err = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "old")); !os.IsNotExist(err) {
+		t.Fatalf("failed restore left its directory behind: %v", err)
+	}
+	// 非空目录不会被覆盖，也不会被删除
+	if err := RestoreToSequence(filepath.Join(root, "seq3"), 3, ro); err == nil {
+		t.Fatal("restore into a non-empty directory should fail")
+	}
+	check(filepath.Join(root, "seq3"), 3, map[string]string{"a": "2", "b": "1"})
+}
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+
+	"monolithdb/internal/memtable"
+	"monolithdb/internal/sstable"
+)
+
+// sampleRounds 是 SampleKeys 最多抽样几轮：抽到重复、已删除或已过期的 key 时再抽一轮补足。
+const sampleRounds = 4
+
+// sampleSource 是抽样的一个来源：一个 MemTable，或者一张 SST 和它的稀疏索引。
+type sampleSource struct {
+	weight int64 // 记录数（包含 tombstone 和被覆盖的旧版本）
+
+	mem *memtable.MemTable
+
+	r     *sstable.Reader
+	index []string
+}
+
+// SampleKeys 返回最多 n 个随机挑选的可见 key（按 key 升序、不重复），每个 key 被选中的概率大致相同，
+// 用来估计分裂点、抽查数据质量等。数据库里的 key 不足 n 个，或者抽到的大多是已删除的 key 时返回的更少。
+//
+// 不扫描全部数据：每次抽样先按记录数选择 MemTable 或某张 SST；SST 里随机选一个索引项（一个数据块），
+// 只读这个块的 key 并从中均匀地选一个；MemTable 用蓄水池抽样。之后丢掉已经删除、过期或被覆盖的 key。
+// 各个块的记录数不同，所以结果只是近似均匀。整个过程持有读锁。
+func (d *DB) SampleKeys(n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return nil, ErrClosed
+	}
+
+	sro := d.sstReadOptions(ReadOptions{KeysOnly: true})
+	srcs := []sampleSource{{weight: int64(d.mem.Len()), mem: d.mem}}
+	for _, m := range d.imm {
+		srcs = append(srcs, sampleSource{weight: int64(m.mem.Len()), mem: m.mem})
+	}
+	for _, p := range d.versions.current().tables {
+		r, release, err := d.versions.reader(p, sro)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		index, err := r.IndexKeys()
+		if err != nil {
+			return nil, err
+		}
+		props, err := sstable.ReadPropertiesWithOptions(p, sro)
+		if err != nil {
+			return nil, err
+		}
+		// 没有记录计数的旧表按块数估计
+		w := int64(props.NumEntries)
+		if w == 0 {
+			w = int64(len(index))
+		}
+		srcs = append(srcs, sampleSource{weight: w, r: r, index: index})
+	}
+	var total int64
+	for _, s := range srcs {
+		total += s.weight
+	}
+	if total == 0 {
+		return nil, nil
+	}
+
+	now := d.now()
+	seen := make(map[string]bool)
+	var out []string
+	for round := 0; round < sampleRounds && len(out) < n; round++ {
+		// 按记录数把这一轮的 n-len(out) 次抽样分给各个来源
+		draws := make([]int, len(srcs))
+		for range n - len(out) {
+			x := rand.Int64N(total)
+			i := 0
+			for x >= srcs[i].weight {
+				x -= srcs[i].weight
+				i++
+			}
+			draws[i]++
+		}
+		for i, s := range srcs {
+			if draws[i] == 0 {
+				continue
+			}
+			var keys []string
+			if s.mem != nil {
+				keys = sampleMemTable(s.mem, draws[i])
+			} else {
+				for range draws[i] {
+					k, ok, err := d.sampleBlock(s.r, s.index, sro)
+					if err != nil {
+						return nil, err
+					}
+					if ok {
+						keys = append(keys, k)
+					}
+				}
+			}
+			for _, k := range keys {
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+				ok, err := d.visibleLocked(k, sro, now)
+				if err != nil {
+					return nil, err
+				}
+				if ok && len(out) < n {
+					out = append(out, k)
+				}
+			}
+		}
+	}
+	slices.SortFunc(out, d.cmp.Compare)
+	return out, nil
+}
+
+// sampleMemTable 用蓄水池抽样从 m 里均匀地选出最多 k 个不同的 key（包括 tombstone，之后统一过滤）。
+func sampleMemTable(m *memtable.MemTable, k int) []string {
+	var res []string
+	for i, e := range m.RangeKeys("", "") {
+		if i < k {
+			res = append(res, e.Key)
+		} else if j := rand.IntN(i + 1); j < k {
+			res[j] = e.Key
+		}
+	}
+	return res
+}
+
+// sampleBlock 随机选 r 的一个数据块，只读其中的 key，均匀地返回一个。
+func (d *DB) sampleBlock(r *sstable.Reader, index []string, sro sstable.ReadOptions) (string, bool, error) {
+	if len(index) == 0 {
+		return "", false, nil
+	}
+	b := rand.IntN(len(index))
+	it := sstable.NewIteratorWithOptions(r, sro)
+	var key string
+	n := 0
+	// 块 b 的记录是 [index[b], index[b+1]) 内的 key
+	for ok := it.SeekGE(index[b]); ok; ok = it.Next() {
+		if b+1 < len(index) && d.cmp.Compare(it.Key(), index[b+1]) >= 0 {
+			break
+		}
+		n++
+		if rand.IntN(n) == 0 {
+			key = it.Key()
+		}
+	}
+	if err := it.Err(); err != nil {
+		return "", false, err
+	}
+	return key, n > 0, nil
+}
+
+// visibleLocked 报告 key 当前是否可见（没有被删除或过期）。调用方持有读锁。
+func (d *DB) visibleLocked(key string, sro sstable.ReadOptions, now int64) (bool, error) {
+	if e, ok := memGet(d.mem, d.imm, key, true); ok {
+		return !e.Tombstone && !expired(e, now), nil
+	}
+	e, res, err := d.versions.searchTables(context.Background(), key, sro)
+	if err != nil {
+		return false, err
+	}
+	return res == sstable.Found && !expired(e, now), nil
+}
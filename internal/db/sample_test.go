@@ -0,0 +1,70 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSampleKeys(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if keys, err := d.SampleKeys(10); err != nil || len(keys) != 0 {
+		t.Fatalf("empty db: %v %v", keys, err)
+	}
+
+	// a* 在 SST 里（其中 a0xxx 被删除），b* 在 MemTable 里，数量相同
+	for i := 0; i < 2000; i++ {
+		if err := d.Put(fmt.Sprintf("a%04d", i), []byte(strings.Repeat("v", 50))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if err := d.Delete(fmt.Sprintf("a%04d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		if err := d.Put(fmt.Sprintf("b%04d", i), []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := d.SampleKeys(200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) < 150 || len(keys) > 200 || !slices.IsSorted(keys) {
+		t.Fatalf("sampled %d keys (sorted %v)", len(keys), slices.IsSorted(keys))
+	}
+	var fromSST int
+	for i, k := range keys {
+		if i > 0 && keys[i-1] == k {
+			t.Fatalf("duplicate key %q", k)
+		}
+		if _, ok, err := d.Get(k); err != nil || !ok {
+			t.Fatalf("sampled key %q is not visible: %v", k, err)
+		}
+		if k[0] == 'a' {
+			fromSST++
+		}
+	}
+	// 可见的 key 一半在 SST 里、一半在 MemTable 里
+	if fromSST < len(keys)/5 || fromSST > len(keys)*4/5 {
+		t.Fatalf("%d of %d sampled keys come from the SST", fromSST, len(keys))
+	}
+
+	// 可见的 key 不足 n 个时返回的也不会更多
+	if keys, err := d.SampleKeys(5000); err != nil || len(keys) > 2000 {
+		t.Fatalf("oversized sample: %d %v", len(keys), err)
+	}
+}
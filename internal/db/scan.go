@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+
+	"monolithdb/internal/types"
+)
+
+// ErrInvalidCursor 表示传给 ScanFrom 的游标不是 Scan / ScanFrom 返回的。
+var ErrInvalidCursor = errors.New("db: invalid scan cursor")
+
+// DefaultScanLimit 是 Scan / ScanFrom 的 limit <= 0 时一页的记录数。
+const DefaultScanLimit = 1000
+
+// scanCursorVersion 是游标编码的版本，放在第一个字节。
+const scanCursorVersion = 1
+
+// Scan 按 key 升序返回 [start, end) 内最多 limit 条可见的记录（limit <= 0 表示 DefaultScanLimit），
+// 以及读取下一页的游标；范围已经读完时游标为空。一页的 key+value 字节数超过 Options.MaxRangeBytes 时
+// 提前结束这一页，而不是返回 ErrRangeTooLarge。
+//
+// 游标是不透明的 URL 安全字符串，记录了这一页的最后一个 key 和范围的终点，
+// 可以交给 HTTP 客户端，之后再用 ScanFrom 继续，两次请求之间不占用任何资源。
+// 与 RangeChunks 一样，每一页读取时的状态各自独立，整个结果不是同一时刻的快照。
+func (d *DB) Scan(start, end string, limit int) ([]types.Entry, string, error) {
+	return d.scanPage(d.normKey(start), false, d.normKey(end), limit)
+}
+
+// ScanFrom 从 Scan / ScanFrom 返回的游标继续读取下一页，limit 的含义与 Scan 相同（每页可以不同）。
+// 游标无法解析时返回 ErrInvalidCursor。
+func (d *DB) ScanFrom(cursor string, limit int) ([]types.Entry, string, error) {
+	last, end, err := decodeScanCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	return d.scanPage(last, true, end, limit)
+}
+
+// scanPage 读取一页；after 为 true 时跳过 from 本身。
+func (d *DB) scanPage(from string, after bool, end string, limit int) ([]types.Entry, string, error) {
+	if limit <= 0 {
+		limit = DefaultScanLimit
+	}
+	chunk, more, err := d.rangeChunkN(from, after, end, limit, d.opts.maxRangeBytes())
+	if err != nil {
+		return nil, "", err
+	}
+	if !more {
+		return chunk, "", nil
+	}
+	return chunk, encodeScanCursor(chunk[len(chunk)-1].Key, end), nil
+}
+
+// rangeChunkN 在一次读锁内读取 [from, end) 内最多 limit 条、最多 maxBytes 字节（<= 0 表示不限制）的记录，
+// 按字节数截断时也至少包含一条，否则游标无法前进。more 表示后面还有记录。
+func (d *DB) rangeChunkN(from string, after bool, end string, limit int, maxBytes int64) (chunk []types.Entry, more bool, err error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var size int64
+	for e, err := range d.mergeRange(context.Background(), from, end, d.now()) {
+		if err != nil {
+			return nil, false, err
+		}
+		if after && d.cmp.Compare(e.Key, from) <= 0 {
+			continue
+		}
+		if len(chunk) >= limit || (maxBytes > 0 && len(chunk) > 0 && size+entrySize(e) > maxBytes) {
+			return chunk, true, nil
+		}
+		size += entrySize(e)
+		if e.Value, err = d.interceptRead(e.Key, e.Value); err != nil {
+			return nil, false, err
+		}
+		chunk = append(chunk, e)
+	}
+	return chunk, false, nil
+}
+
+// encodeScanCursor 把 (last, end) 编码成游标：版本、uvarint 长度的 last、end。
+func encodeScanCursor(last, end string) string {
+	b := []byte{scanCursorVersion}
+	b = binary.AppendUvarint(b, uint64(len(last)))
+	b = append(append(b, last...), end...)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeScanCursor(cursor string) (last, end string, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(b) == 0 || b[0] != scanCursorVersion {
+		return "", "", ErrInvalidCursor
+	}
+	n, k := binary.Uvarint(b[1:])
+	if k <= 0 || n > uint64(len(b)-1-k) {
+		return "", "", ErrInvalidCursor
+	}
+	rest := b[1+k:]
+	return string(rest[:n]), string(rest[n:]), nil
+}
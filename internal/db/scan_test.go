@@ -0,0 +1,70 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanPages(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true, MaxRangeBytes: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for i := 0; i < 25; i++ {
+		if err := d.Put(fmt.Sprintf("k%02d", i), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete("k05"); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	page, cursor, err := d.Scan("k02", "k20", 4)
+	pages := 0
+	for {
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, e := range page {
+			keys = append(keys, e.Key)
+		}
+		if cursor == "" {
+			break
+		}
+		// 翻页之间的写入：已经返回过的 key 不会再出现，后面的 key 按读取时的状态返回
+		if pages == 1 {
+			if err := d.Put("k00", []byte("new")); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.Delete("k10"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		page, cursor, err = d.ScanFrom(cursor, 4)
+	}
+	want := "[k02 k03 k04 k06 k07 k08 k09 k11 k12 k13 k14 k15 k16 k17 k18 k19]"
+	if got := fmt.Sprint(keys); got != want || pages != 4 {
+		t.Fatalf("scanned %s in %d pages, want %s", got, pages, want)
+	}
+
+	// 一页超过 MaxRangeBytes 时提前结束，而不是报错（k00 的 key+value 是 6 字节，其余每条 4 字节）
+	page, cursor, err = d.Scan("", "", 1000)
+	if err != nil || len(page) != 9 || cursor == "" {
+		t.Fatalf("byte-limited page: %d entries, cursor %q, %v", len(page), cursor, err)
+	}
+
+	if _, _, err := d.ScanFrom("not a cursor", 10); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("bad cursor: %v", err)
+	}
+	if _, _, err := d.ScanFrom(encodeScanCursor("k", "z")[:3], 10); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("truncated cursor: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package db
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"monolithdb/internal/sstable"
+)
+
+// DefaultScrubBytesPerSec 是 Options.ScrubBytesPerSec 为 0 时后台巡检读盘的速度。
+const DefaultScrubBytesPerSec = 4 << 20
+
+// scrubIdleWait 是没有到期的表时巡检最多等待多久再看一次，新写出的表和 compaction 的输出因此也会被纳入。
+const scrubIdleWait = time.Minute
+
+// ScrubStats 是打开数据库以来后台巡检（见 Options.ScrubInterval）的累计统计。
+type ScrubStats struct {
+	TablesChecked uint64
+	BytesChecked  uint64
+	CorruptTables uint64 // 发现损坏的次数（同一张表每次检查都会计入）
+}
+
+// scrubber 是后台巡检的状态，除了 stop / done 只由巡检 goroutine 访问。
+type scrubber struct {
+	interval time.Duration
+	limiter  *RateLimiter
+
+	// verified 是每张表上次确认完好的时间：巡检检查完的时间，没有检查过时是文件的修改时间
+	// （写表时刚算出校验和）。时间最早的表最"冷"，先检查。
+	verified map[string]time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startScrubber 启动后台巡检，Close 时停止。
+func (d *DB) startScrubber() {
+	rate := d.opts.ScrubBytesPerSec
+	if rate == 0 {
+		rate = DefaultScrubBytesPerSec
+	}
+	s := &scrubber{
+		interval: d.opts.ScrubInterval,
+		limiter:  NewRateLimiter(rate),
+		verified: make(map[string]time.Time),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	d.scrub = s
+	go d.runScrubber(s)
+}
+
+// stopScrubber 停止后台巡检并等待正在检查的表结束。
+func (d *DB) stopScrubber() {
+	if d.scrub == nil {
+		return
+	}
+	close(d.scrub.stop)
+	<-d.scrub.done
+	d.scrub = nil
+}
+
+// runScrubber 是巡检 goroutine。Options.Clock 能安排定时（例如 ManualClock）时按它计时，否则按真实时间。
+func (d *DB) runScrubber(s *scrubber) {
+	defer close(s.done)
+	clock := d.opts.clock()
+	after := time.After
+	if c, ok := clock.(timerClock); ok {
+		after = c.After
+	}
+	for {
+		path, wait := d.nextScrubTable(s, clock.Now())
+		if path != "" {
+			n := d.scrubTable(path)
+			s.verified[path] = clock.Now()
+			// 按表计算平均速率：读完一张表之后等到配额补足再读下一张
+			wait = s.limiter.reserve(clock.Now(), int(n))
+		}
+		if wait <= 0 {
+			continue
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-after(wait):
+		}
+	}
+}
+
+// nextScrubTable 返回当前 version 里最久没有确认完好、并且已经超过 ScrubInterval 的表，
+// 返回之前固定住它，scrubTable 检查完之后放开；没有到期的表时返回空路径和需要等待的时间。
+func (d *DB) nextScrubTable(s *scrubber, now time.Time) (string, time.Duration) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return "", scrubIdleWait
+	}
+
+	tables := d.versions.current().tables
+	live := make(map[string]bool, len(tables))
+	var next string
+	var oldest time.Time
+	for _, p := range tables {
+		live[p] = true
+		t, ok := s.verified[p]
+		if !ok {
+			st, err := d.opts.fs().Stat(p)
+			if err != nil {
+				continue
+			}
+			t = st.ModTime()
+			s.verified[p] = t
+		}
+		if next == "" || t.Before(oldest) {
+			next, oldest = p, t
+		}
+	}
+	// 忘掉已经被 compaction 替换的表
+	for p := range s.verified {
+		if !live[p] {
+			delete(s.verified, p)
+		}
+	}
+
+	if next == "" {
+		return "", scrubIdleWait
+	}
+	if due := oldest.Add(s.interval); due.After(now) {
+		return "", min(due.Sub(now), scrubIdleWait)
+	}
+	d.versions.ref(next)
+	return next, 0
+}
+
+// scrubTable 完整检查一张表并上报发现的损坏，返回读取的字节数。
+func (d *DB) scrubTable(path string) int64 {
+	defer d.versions.unref(path)
+
+	size, err := fileSize(d.opts.fs(), path)
+	if err != nil {
+		log.Printf("forgedb: scrub %s: %v", d.relPath(path), err)
+		return 0
+	}
+	blocks, err := sstable.CheckTable(path, d.sstReadOptions(ReadOptions{}))
+	d.metrics.scrubTables.Add(1)
+	d.metrics.scrubBytes.Add(uint64(size))
+	switch {
+	case errors.Is(err, sstable.ErrCorruptSST):
+		d.reportScrub(CorruptFile{File: d.relPath(path), Err: err})
+	case err != nil:
+		// 不是损坏（例如缺少密钥），下一轮再试
+		log.Printf("forgedb: scrub %s: %v", d.relPath(path), err)
+	case blocks != nil:
+		d.reportScrub(CorruptFile{File: d.relPath(path), Err: blocks[0].Err, Blocks: blocks})
+	}
+	return size
+}
+
+// reportScrub 上报巡检发现的一个损坏的文件。
+func (d *DB) reportScrub(cf CorruptFile) {
+	d.metrics.scrubCorrupt.Add(1)
+	if d.opts.ScrubAlert != nil {
+		d.opts.ScrubAlert(cf)
+		return
+	}
+	log.Printf("forgedb: scrub found corruption in %s: %v (%d bad blocks)", cf.File, cf.Err, len(cf.Blocks))
+}
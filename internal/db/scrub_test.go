@@ -0,0 +1,121 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"monolithdb/internal/sstable"
+)
+
+func TestScrubberReportsCorruption(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	// bloom filter 小一些，文件的前三分之一落在数据区
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, BloomBitsPerKey: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 500; i++ {
+		if err := d.Put(fmt.Sprintf("k%04d", i), []byte("some value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	table := d.versions.current().tables[0]
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[len(b)/3] ^= 0xff
+	if err := os.WriteFile(table, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 表的修改时间是真实时间，时钟从现在开始；一小时之后表到期
+	clock := NewManualClock(time.Now())
+	alerts := make(chan CorruptFile, 1)
+	d, err = OpenWithOptions(dir, Options{
+		ReadOnly:         true,
+		Clock:            clock,
+		ScrubInterval:    time.Hour,
+		ScrubBytesPerSec: -1,
+		ScrubAlert: func(cf CorruptFile) {
+			select {
+			case alerts <- cf:
+			default:
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// 巡检在等待时钟：还没有到期，什么也没检查
+	waitAfterCalls(t, clock, 1)
+	if st := d.Stats().Scrub; st.TablesChecked != 0 {
+		t.Fatalf("scrubbed before the table was due: %+v", st)
+	}
+
+	clock.Advance(2 * time.Hour)
+	cf := <-alerts
+	if cf.File != filepath.Join(sstDirName, filepath.Base(table)) || len(cf.Blocks) != 1 ||
+		!errors.Is(cf.Err, sstable.ErrCorruptSST) {
+		t.Fatalf("alert %+v", cf)
+	}
+	if st := d.Stats().Scrub; st.TablesChecked == 0 || st.BytesChecked == 0 || st.CorruptTables == 0 {
+		t.Fatalf("scrub stats %+v", st)
+	}
+}
+
+func TestScrubberPicksColdestTable(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, k := range []string{"a", "b", "c"} {
+		if err := d.Put(k, []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tables := d.versions.current().tables // newest first
+	now := time.Now()
+	for i, p := range tables {
+		// 最新的表最冷
+		mtime := now.Add(-time.Duration(len(tables)-i) * time.Hour)
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &scrubber{interval: 90 * time.Minute, verified: make(map[string]time.Time)}
+	var got []string
+	for {
+		p, wait := d.nextScrubTable(s, now)
+		if p == "" {
+			if wait <= 0 || wait > scrubIdleWait {
+				t.Fatalf("idle wait %v", wait)
+			}
+			break
+		}
+		d.versions.unref(p)
+		s.verified[p] = now
+		got = append(got, p)
+	}
+	// 修改时间在 90 分钟之前的两张表到期，最冷的先检查
+	if len(got) != 2 || got[0] != tables[0] || got[1] != tables[1] {
+		t.Fatalf("scrub order %v, tables %v", got, tables)
+	}
+}
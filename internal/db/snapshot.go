@@ -0,0 +1,196 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sort"
+	"sync"
+
+	"monolithdb/internal/mergeiter"
+	"monolithdb/internal/sstable"
+	"monolithdb/internal/types"
+)
+
+// ErrSnapshotClosed 表示 Snapshot 已经关闭。
+var ErrSnapshotClosed = errors.New("db: snapshot closed")
+
+// Snapshot 是数据库在某一时刻的只读视图：之后的写入、Flush 和 compaction 都不影响它读到的内容。
+//
+// 创建时复制 MemTable（包括不可变 MemTable）的内容，并为当时所有的 SST 增加引用：
+// 这些表即使被 compaction 替换，文件也保留到 Snapshot 关闭为止（见 PropNumObsoleteSSTables）。
+// 所以 Snapshot 用完要尽快 Close，并且必须在 DB.Close 之前关闭；DB 关闭之后的读取返回 ErrClosed。
+// 读取不持有数据库的锁，不会阻塞写入。Snapshot 可以被多个 goroutine 同时使用。
+type Snapshot struct {
+	d      *DB
+	seq    uint64
+	mem    []types.Entry // 按 key 升序，每个 key 只有最新的版本，包含 tombstone
+	tables []string      // newest first
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewSnapshot 返回数据库当前状态的 Snapshot。
+func (d *DB) NewSnapshot() *Snapshot {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	children := []mergeiter.Iterator{mergeiter.NewSliceIterator(d.mem.RangeAll("", ""), d.cmp)}
+	for i := len(d.imm) - 1; i >= 0; i-- {
+		children = append(children, mergeiter.NewSliceIterator(d.imm[i].mem.RangeAll("", ""), d.cmp))
+	}
+	var mem []types.Entry
+	m := mergeiter.New(children, mergeiter.Options{Comparer: d.cmp, KeepTombstones: true})
+	for m.Next() {
+		mem = append(mem, m.Entry())
+	}
+
+	tables := d.versions.current().tables
+	d.versions.ref(tables...)
+	return &Snapshot{d: d, seq: d.lastSeq, mem: mem, tables: tables}
+}
+
+// Sequence 返回 Snapshot 包含的最后一条记录的序号（见 DB.LastSequence）。
+func (s *Snapshot) Sequence() uint64 { return s.seq }
+
+// Close 放开 Snapshot 固定住的表，之后不能再使用它。重复调用是安全的。
+func (s *Snapshot) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.d.versions.unref(s.tables...)
+	return nil
+}
+
+// check 在读取之前确认 Snapshot 和数据库都还没有关闭。
+func (s *Snapshot) check() error {
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return ErrSnapshotClosed
+	}
+	s.d.mu.RLock()
+	defer s.d.mu.RUnlock()
+	if s.d.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// Get 返回创建 Snapshot 时 key 的值，语义与 DB.Get 相同（过期时间按读取时的时钟判断）。
+func (s *Snapshot) Get(key string) ([]byte, bool, error) {
+	if err := s.check(); err != nil {
+		return nil, false, err
+	}
+	d := s.d
+	key = d.normKey(key)
+	now := d.now()
+	d.metrics.logicalReads.Add(1)
+
+	var e types.Entry
+	i := sort.Search(len(s.mem), func(i int) bool { return d.cmp.Compare(s.mem[i].Key, key) >= 0 })
+	if i < len(s.mem) && d.cmp.Compare(s.mem[i].Key, key) == 0 {
+		e = s.mem[i]
+	} else {
+		var res sstable.GetResult
+		var err error
+		e, res, err = d.versions.searchTablesIn(context.Background(), s.tables, key, d.sstReadOptions(ReadOptions{}))
+		if err != nil {
+			return nil, false, err
+		}
+		if res != sstable.Found {
+			return nil, false, nil
+		}
+	}
+	if e.Tombstone || expired(e, now) {
+		return nil, false, nil
+	}
+	v, err := d.interceptRead(key, e.Value)
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+// Range 与 DB.Range 相同，但读取的是 Snapshot 的内容。结果同样受 Options.MaxRangeBytes 限制。
+func (s *Snapshot) Range(start, end string) ([]types.Entry, error) {
+	limit := s.d.opts.maxRangeBytes()
+	var out []types.Entry
+	var size int64
+	for e, err := range s.All(start, end) {
+		if err != nil {
+			return nil, err
+		}
+		size += entrySize(e)
+		if limit > 0 && size > limit {
+			return nil, ErrRangeTooLarge
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// All 按 key 升序流式返回 Snapshot 中 [start, end) 内所有可见的记录。
+// 与 RangeChunks 不同，整个结果都来自同一时刻，迭代期间也不持有数据库的锁。
+func (s *Snapshot) All(start, end string) iter.Seq2[types.Entry, error] {
+	return s.all(start, end, true)
+}
+
+// all 是 All 的实现；intercept 为 false 时返回保存在数据库里的原始 value，不经过 ReadInterceptors。
+func (s *Snapshot) all(start, end string, intercept bool) iter.Seq2[types.Entry, error] {
+	return func(yield func(types.Entry, error) bool) {
+		if err := s.check(); err != nil {
+			yield(types.Entry{}, err)
+			return
+		}
+		d := s.d
+		start, end := d.normKey(start), d.normKey(end)
+		now := d.now()
+
+		children := []mergeiter.Iterator{mergeiter.NewSliceIterator(s.mem, d.cmp)}
+		ro := d.sstReadOptions(ReadOptions{})
+		for _, p := range s.tables {
+			r, release, err := d.versions.reader(p, ro)
+			if err != nil {
+				yield(types.Entry{}, err)
+				return
+			}
+			defer release()
+			children = append(children, sstable.NewIteratorWithOptions(r, ro))
+		}
+
+		m := mergeiter.New(children, mergeiter.Options{Comparer: d.cmp})
+		for ok := m.SeekGE(start); ok; ok = m.Next() {
+			e := m.Entry()
+			if end != "" && d.cmp.Compare(e.Key, end) >= 0 {
+				return
+			}
+			if expired(e, now) {
+				continue
+			}
+			e, err := d.versions.resolve(e)
+			if err != nil {
+				yield(types.Entry{}, err)
+				return
+			}
+			if intercept {
+				if e.Value, err = d.interceptRead(e.Key, e.Value); err != nil {
+					yield(types.Entry{}, err)
+					return
+				}
+			}
+			d.metrics.logicalReads.Add(1)
+			if !yield(e, nil) {
+				return
+			}
+		}
+		if err := m.Err(); err != nil {
+			yield(types.Entry{}, err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/types"
+)
+
+func TestSnapshotIsolation(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	mustPut := func(k, v string) {
+		t.Helper()
+		if err := d.Put(k, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustPut("a", "a1")
+	mustPut("gone", "x")
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	mustPut("b", "b1")
+	if err := d.Delete("gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	snap := d.NewSnapshot()
+	defer snap.Close()
+	if snap.Sequence() != d.LastSequence() {
+		t.Fatalf("snapshot sequence = %d, want %d", snap.Sequence(), d.LastSequence())
+	}
+
+	mustPut("a", "a2")
+	mustPut("c", "c1")
+	if err := d.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(stage string) {
+		t.Helper()
+		for k, want := range map[string]string{"a": "a1", "b": "b1"} {
+			if v, ok, err := snap.Get(k); err != nil || !ok || string(v) != want {
+				t.Fatalf("%s: snapshot Get(%s) = %q %v %v, want %q", stage, k, v, ok, err, want)
+			}
+		}
+		for _, k := range []string{"c", "gone"} {
+			if _, ok, err := snap.Get(k); err != nil || ok {
+				t.Fatalf("%s: snapshot Get(%s) ok = %v err = %v", stage, k, ok, err)
+			}
+		}
+		entries, err := snap.Range("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(keyValues(entries)); got != "[a=a1 b=b1]" {
+			t.Fatalf("%s: snapshot Range = %s", stage, got)
+		}
+	}
+	check("before compaction")
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	check("after compaction")
+
+	entries, err := d.Range("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(keyValues(entries)); got != "[a=a2 c=c1]" {
+		t.Fatalf("db Range = %s", got)
+	}
+}
+
+func keyValues(entries []types.Entry) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = e.Key + "=" + string(e.Value)
+	}
+	return out
+}
+
+func TestSnapshotPinsCompactedTables(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, k := range []string{"a", "b"} {
+		if err := d.Put(k, []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pinned := d.versions.current().tables
+
+	snap := d.NewSnapshot()
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range pinned {
+		if _, err := os.Stat(p); err != nil {
+			t.Fatalf("table pinned by snapshot removed: %v", err)
+		}
+	}
+	if n, _ := d.GetIntProperty(PropNumObsoleteSSTables); n != uint64(len(pinned)) {
+		t.Fatalf("obsolete sstables = %d, want %d", n, len(pinned))
+	}
+	// 迭代中途被 compaction 替换的表依然可读
+	n := 0
+	for e, err := range snap.All("", "") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			if err := d.Put("z", []byte("z")); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.Compact(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if string(e.Value) != e.Key {
+			t.Fatalf("entry %+v", e)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("snapshot iterated %d entries", n)
+	}
+
+	if err := snap.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range pinned {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Fatalf("%s not removed after the snapshot closed: %v", p, err)
+		}
+	}
+	if n, _ := d.GetIntProperty(PropNumObsoleteSSTables); n != 0 {
+		t.Fatalf("obsolete sstables = %d after close", n)
+	}
+	if _, _, err := snap.Get("a"); !errors.Is(err, ErrSnapshotClosed) {
+		t.Fatalf("Get after Close: %v", err)
+	}
+	if err := snap.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}
+
+func TestSnapshotAfterDBClose(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("a", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	snap := d.NewSnapshot()
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := snap.Get("a"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Get after DB.Close: %v", err)
+	}
+	if _, err := snap.Range("", ""); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Range after DB.Close: %v", err)
+	}
+	if err := snap.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrClosed 表示数据库已经关闭。因为写停顿而等待的写入在 Close 时返回它。
+var ErrClosed = errors.New("db: closed")
+
+// DefaultWriteSlowdownDelay 是 Options.WriteSlowdownDelay 的默认值。
+const DefaultWriteSlowdownDelay = time.Millisecond
+
+// stallLevel 是当前积压程度对写入的影响。
+type stallLevel int
+
+const (
+	stallNone     stallLevel = iota
+	stallSlowdown            // 超过软限制：每次写入先睡眠 WriteSlowdownDelay
+	stallStop                // 超过硬限制：写入阻塞到积压回落
+)
+
+// stallEnabled 报告是否配置了任何写停顿限制。
+func (o Options) stallEnabled() bool {
+	return o.L0SlowdownTables > 0 || o.L0StopTables > 0 || o.MemTableSlowdownBytes > 0 || o.MemTableStopBytes > 0
+}
+
+func (o Options) writeSlowdownDelay() time.Duration {
+	if o.WriteSlowdownDelay <= 0 {
+		return DefaultWriteSlowdownDelay
+	}
+	return o.WriteSlowdownDelay
+}
+
+// stallLevel 按当前 SST 个数和 MemTable 大小判断积压程度。调用方持有锁。
+func (d *DB) stallLevel() stallLevel {
+	o := d.opts
+	tables := len(d.versions.current().tables)
+	memBytes := d.mem.ApproximateBytes()
+	switch {
+	case o.L0StopTables > 0 && tables >= o.L0StopTables,
+		o.MemTableStopBytes > 0 && memBytes >= o.MemTableStopBytes:
+		return stallStop
+	case o.L0SlowdownTables > 0 && tables >= o.L0SlowdownTables,
+		o.MemTableSlowdownBytes > 0 && memBytes >= o.MemTableSlowdownBytes:
+		return stallSlowdown
+	}
+	return stallNone
+}
+
+// throttleWrite 在写操作拿到写锁之后、写 WAL 之前调用。
+// MemTable 达到 Options.MemTableSize 时先为写入腾出空间（见 makeRoomForWrite）；
+// 超过软限制时释放锁睡眠一次 WriteSlowdownDelay；超过硬限制时在 stallCond 上等待，
+// 直到 Flush / Compact 让积压回到硬限制以下（或者数据库被关闭，返回 ErrClosed）。
+// ctx 被取消或超时时不再等待，返回 ctx.Err()。返回时依然持有写锁。
+func (d *DB) throttleWrite(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := d.makeRoomForWrite(ctx); err != nil {
+		return err
+	}
+	if !d.opts.stallEnabled() {
+		return nil
+	}
+	level := d.stallLevel()
+	if level == stallNone {
+		return nil
+	}
+
+	start := time.Now()
+	defer func() { d.metrics.stallNanos.Add(uint64(time.Since(start))) }()
+
+	if level == stallSlowdown {
+		d.metrics.stallSlowdowns.Add(1)
+		d.mu.Unlock()
+		t := time.NewTimer(d.opts.writeSlowdownDelay())
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+		}
+		d.mu.Lock()
+	}
+	if ctx.Err() == nil && d.stallLevel() == stallStop {
+		d.metrics.stallStops.Add(1)
+		// Cond 不能和 ctx 一起 select：ctx 结束时广播一次，让等待的写入醒来检查 ctx
+		stop := context.AfterFunc(ctx, func() {
+			d.mu.Lock()
+			d.stallCond.Broadcast()
+			d.mu.Unlock()
+		})
+		defer stop()
+		for !d.closed && ctx.Err() == nil && d.stallLevel() == stallStop {
+			d.stallCond.Wait()
+		}
+	}
+	if d.closed {
+		return ErrClosed
+	}
+	return ctx.Err()
+}
+
+// backlogChanged 在 Flush / Compact 改变了 SST 个数或 MemTable 大小之后调用（持有写锁），
+// 唤醒因为写停顿而等待的写入重新检查。
+func (d *DB) backlogChanged() {
+	d.stallCond.Broadcast()
+}
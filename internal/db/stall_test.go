@@ -0,0 +1,114 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteSlowdown(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, L0SlowdownTables: 1, WriteSlowdownDelay: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.Put("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if c := d.Counters(); c.WriteSlowdowns != 0 {
+		t.Fatalf("slowdowns before any table = %d", c.WriteSlowdowns)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := d.Put("b", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("Put returned after %v, expected a slowdown", elapsed)
+	}
+	c := d.Counters()
+	if c.WriteSlowdowns != 1 || c.WriteStops != 0 || c.WriteStallTime < 20*time.Millisecond {
+		t.Fatalf("counters = %+v", c)
+	}
+}
+
+func TestWriteStopUntilCompaction(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, L0StopTables: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, k := range []string{"a", "b"} {
+		if err := d.Put(k, []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- d.Put("c", []byte("c")) }()
+	select {
+	case err := <-done:
+		t.Fatalf("Put should block at the hard limit, returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 读不受写停顿影响
+	if _, ok, err := d.Get("a"); err != nil || !ok {
+		t.Fatalf("Get(a) = %v %v", ok, err)
+	}
+
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Put still blocked after compaction")
+	}
+	if v, ok, _ := d.Get("c"); !ok || string(v) != "c" {
+		t.Fatalf("Get(c) = %q %v", v, ok)
+	}
+	if c := d.Counters(); c.WriteStops != 1 {
+		t.Fatalf("stops = %d", c.WriteStops)
+	}
+}
+
+func TestCloseReleasesStalledWriters(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, MemTableStopBytes: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- d.Put("b", []byte("2")) }()
+	time.Sleep(20 * time.Millisecond)
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrClosed) {
+			t.Fatalf("expected ErrClosed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled Put not released by Close")
+	}
+}
@@ -0,0 +1,101 @@
+package db
+
+import "monolithdb/internal/cache"
+
+// Stats 是数据库运行时的统计快照。
+type Stats struct {
+	NumSSTables     int
+	MemTableEntries int   // 包含 tombstone
+	MemTableBytes   int64 // key + value 的近似字节数
+
+	// ImmutableMemTables 是等待后台写成 SST 的不可变 MemTable 个数（见 Options.MaxImmutableMemtables），
+	// ImmutableMemTableBytes 是它们的 key + value 近似字节数，不计入 MemTableEntries / MemTableBytes。
+	ImmutableMemTables     int
+	ImmutableMemTableBytes int64
+	ReadCache              cache.Stats
+	Eviction               EvictionStats // 未开启有界模式时为零值
+	Compaction             CompactionStats
+	Amplification          AmplificationStats
+	Scrub                  ScrubStats
+	Latency                Latencies // 未开启 Options.LatencyHistograms 时为零值
+}
+
+// AmplificationStats 是打开数据库以来的读写放大统计，调整 compaction 相关配置时用来衡量效果。
+type AmplificationStats struct {
+	UserBytesWritten       uint64 // 写入 MemTable 的 key + value 字节数（同 Counters.MemTableBytesWritten）
+	FlushBytesWritten      uint64 // Flush 写出的 SST 字节数
+	CompactionBytesWritten uint64 // compaction 写出的 SST 字节数
+	CompactionBytesRead    uint64 // compaction 从输入表读取的字节数
+
+	// LogicalReads 是点查的次数加上范围读取返回的记录数，
+	// SSTBytesRead 是为它们从 SST 文件读取的字节数（包括第一次读取的过滤器和索引）。
+	LogicalReads uint64
+	SSTBytesRead uint64
+}
+
+// WriteAmp 返回写放大：Flush 和 compaction 写出的字节数与用户写入字节数之比。还没有写入时为 0。
+func (a AmplificationStats) WriteAmp() float64 {
+	if a.UserBytesWritten == 0 {
+		return 0
+	}
+	return float64(a.FlushBytesWritten+a.CompactionBytesWritten) / float64(a.UserBytesWritten)
+}
+
+// ReadAmp 返回读放大：平均每次逻辑读取从 SST 文件读取的字节数。还没有读取时为 0。
+func (a AmplificationStats) ReadAmp() float64 {
+	if a.LogicalReads == 0 {
+		return 0
+	}
+	return float64(a.SSTBytesRead) / float64(a.LogicalReads)
+}
+
+// CompactionStats 是打开数据库以来 compaction 的累计统计。
+type CompactionStats struct {
+	Compactions       uint64
+	TombstonesDropped uint64 // 合并到最旧的表时回收的 tombstone 数
+	ReclaimedBytes    uint64 // 输入表总大小减去输出表大小
+	FilterDropped     uint64 // 被 CompactionFilter 丢弃的记录数
+	FilterReplaced    uint64 // 被 CompactionFilter 改写的记录数
+}
+
+// Stats 返回当前统计信息。
+func (d *DB) Stats() Stats {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	st := Stats{
+		NumSSTables:     len(d.versions.current().tables),
+		MemTableEntries: d.mem.Len(),
+		MemTableBytes:   d.mem.ApproximateBytes(),
+		ReadCache:       d.ReadCacheStats(),
+		Compaction: CompactionStats{
+			Compactions:       d.metrics.compactions.Load(),
+			TombstonesDropped: d.metrics.tombstonesDropped.Load(),
+			ReclaimedBytes:    d.metrics.compactionReclaimed.Load(),
+			FilterDropped:     d.metrics.filterDropped.Load(),
+			FilterReplaced:    d.metrics.filterReplaced.Load(),
+		},
+		Amplification: AmplificationStats{
+			UserBytesWritten:       d.metrics.memBytesIn.Load(),
+			FlushBytesWritten:      d.metrics.bytesFlushed.Load(),
+			CompactionBytesWritten: d.metrics.compactionWritten.Load(),
+			CompactionBytesRead:    d.metrics.compactionRead.Load(),
+			LogicalReads:           d.metrics.logicalReads.Load(),
+			SSTBytesRead:           d.versions.bytesRead.Load(),
+		},
+		Scrub: ScrubStats{
+			TablesChecked: d.metrics.scrubTables.Load(),
+			BytesChecked:  d.metrics.scrubBytes.Load(),
+			CorruptTables: d.metrics.scrubCorrupt.Load(),
+		},
+	}
+	for _, imm := range d.imm {
+		st.ImmutableMemTables++
+		st.ImmutableMemTableBytes += imm.mem.ApproximateBytes()
+	}
+	if d.evict != nil {
+		st.Eviction = d.evict.stats()
+	}
+	st.Latency = d.latency.snapshot()
+	return st
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"monolithdb/internal/mergeiter"
+	"monolithdb/internal/sstable"
+	"monolithdb/internal/types"
+)
+
+// DefaultSubcompactionMinBytes 是 Options.SubcompactionMinBytes 的默认值。
+const DefaultSubcompactionMinBytes = 8 << 20
+
+// subcompactionBounds 按输入表的稀疏索引把 compaction 的 key 范围切成若干段，返回段之间的分界 key
+// （升序，第 i 段是 [bounds[i-1], bounds[i])）。不需要切分时返回 nil（见 Options.MaxSubcompactions）。
+func (d *DB) subcompactionBounds(readers []*sstable.Reader, inputBytes int64) ([]string, error) {
+	n := min(int64(d.opts.MaxSubcompactions), inputBytes/d.opts.subcompactionMinBytes())
+	if n <= 1 {
+		return nil, nil
+	}
+	var keys []string
+	for _, r := range readers {
+		ks, err := r.IndexKeys()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, ks...)
+	}
+	sort.Slice(keys, func(i, j int) bool { return d.cmp.Compare(keys[i], keys[j]) < 0 })
+	// 每个索引项大致代表一个块，按块数等分
+	uniq := keys[:0]
+	for _, k := range keys {
+		if len(uniq) == 0 || d.cmp.Compare(uniq[len(uniq)-1], k) != 0 {
+			uniq = append(uniq, k)
+		}
+	}
+	n = min(n, int64(len(uniq)))
+	bounds := make([]string, 0, n-1)
+	for i := int64(1); i < n; i++ {
+		bounds = append(bounds, uniq[i*int64(len(uniq))/n])
+	}
+	return bounds, nil
+}
+
+// mergeInputs 归并 readers（newest first）中的记录，每个 key 只保留最新的版本，保留 tombstone。
+// bounds 非空时按它切成几段，每段在自己的 goroutine 里归并。
+func (d *DB) mergeInputs(readers []*sstable.Reader, bounds []string) ([]types.Entry, error) {
+	ro := d.sstReadOptions(ReadOptions{})
+	parts := make([][]types.Entry, len(bounds)+1)
+	err := runParallel(len(parts), func(i int) error {
+		lo, hi := subcompactionRange(bounds, i)
+		children := make([]mergeiter.Iterator, len(readers))
+		for j, r := range readers {
+			children[j] = sstable.NewIteratorWithOptions(r, ro)
+		}
+		m := mergeiter.New(children, mergeiter.Options{Comparer: d.cmp, KeepTombstones: true})
+		for ok := m.SeekGE(lo); ok; ok = m.Next() {
+			e := m.Entry()
+			if hi != "" && d.cmp.Compare(e.Key, hi) >= 0 {
+				break
+			}
+			parts[i] = append(parts[i], e)
+		}
+		return m.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	var out []types.Entry
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out, nil
+}
+
+// splitEntries 按 bounds 把有序的 entries 切成对应的段，去掉空段。
+func (d *DB) splitEntries(entries []types.Entry, bounds []string) [][]types.Entry {
+	var parts [][]types.Entry
+	for i := 0; i <= len(bounds) && len(entries) > 0; i++ {
+		n := len(entries)
+		if i < len(bounds) {
+			n = sort.Search(len(entries), func(j int) bool { return d.cmp.Compare(entries[j].Key, bounds[i]) >= 0 })
+		}
+		if n > 0 {
+			parts = append(parts, entries[:n])
+		}
+		entries = entries[n:]
+	}
+	return parts
+}
+
+// writeCompactionOutputs 并行地把每一段写成一张表，全部写完之后再 rename 成 paths 并持久化目录。
+// 有多段时每张表的 properties 都记录全部输出（见 dropCompactedInputs）。任何一步失败都删除已写的文件。
+func (d *DB) writeCompactionOutputs(parts [][]types.Entry, paths []string, opts sstable.WriterOptions) error {
+	if len(parts) > 1 {
+		names := make([]string, len(paths))
+		for i, p := range paths {
+			names[i] = filepath.Base(p)
+		}
+		opts.Properties.OutputFiles = names
+	}
+	removeAll := func(suffix string) {
+		for _, p := range paths {
+			_ = d.opts.fs().Remove(p + suffix)
+		}
+	}
+	err := runParallel(len(parts), func(i int) error {
+		return sstable.WriteTableWithOptions(paths[i]+".tmp", parts[i], opts)
+	})
+	if err != nil {
+		removeAll(".tmp")
+		return err
+	}
+	for _, p := range paths {
+		if err := d.opts.fs().Rename(p+".tmp", p); err != nil {
+			removeAll(".tmp")
+			removeAll("")
+			return err
+		}
+	}
+	// 新表持久化之后才能删除旧表
+	if err := d.syncSSTDir(); err != nil {
+		removeAll("")
+		return err
+	}
+	return nil
+}
+
+// subcompactionRange 返回第 i 段的范围 [lo, hi)，空字符串表示不限。
+func subcompactionRange(bounds []string, i int) (lo, hi string) {
+	if i > 0 {
+		lo = bounds[i-1]
+	}
+	if i < len(bounds) {
+		hi = bounds[i]
+	}
+	return lo, hi
+}
+
+// runParallel 为 0..n-1 各启动一个 goroutine 调用 fn，等待全部结束，返回第一个错误。n 为 1 时直接调用。
+func runParallel(n int, fn func(i int) error) error {
+	if n == 1 {
+		return fn(0)
+	}
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(i)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
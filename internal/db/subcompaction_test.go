@@ -0,0 +1,199 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/sstable"
+)
+
+// fillSubcompactionDB 写入两张互相覆盖的表：k000..k399，偶数 key 在较新的表里被覆盖，每 10 个删除一个。
+func fillSubcompactionDB(t *testing.T, d *DB) {
+	t.Helper()
+	for i := 0; i < 400; i++ {
+		if err := d.Put(fmt.Sprintf("k%03d", i), []byte(fmt.Sprintf("old-%03d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 400; i += 2 {
+		var err error
+		if i%10 == 0 {
+			err = d.Delete(fmt.Sprintf("k%03d", i))
+		} else {
+			err = d.Put(fmt.Sprintf("k%03d", i), []byte(fmt.Sprintf("new-%03d", i)))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkSubcompactionDB(t *testing.T, d *DB) {
+	t.Helper()
+	live := 0
+	for i := 0; i < 400; i++ {
+		k := fmt.Sprintf("k%03d", i)
+		want := fmt.Sprintf("old-%03d", i)
+		if i%2 == 0 {
+			want = fmt.Sprintf("new-%03d", i)
+		}
+		got, ok, err := d.Get(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%10 == 0 {
+			if ok {
+				t.Fatalf("deleted key %s = %q", k, got)
+			}
+			continue
+		}
+		live++
+		if !ok || string(got) != want {
+			t.Fatalf("Get(%s) = %q %v, want %q", k, got, ok, want)
+		}
+	}
+	entries, err := d.Range("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != live {
+		t.Fatalf("Range returned %d entries, want %d", len(entries), live)
+	}
+}
+
+func TestSubcompactionSplitsOutput(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	opts := Options{DisableFsync: true, BlockSize: 256, MaxSubcompactions: 4, SubcompactionMinBytes: 1}
+	d, err := OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fillSubcompactionDB(t, d)
+
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	tables := d.versions.current().tables
+	if len(tables) != 4 {
+		t.Fatalf("sstables = %v, want 4 outputs", tables)
+	}
+	// 输出按编号从大到小排列，key 范围依次递增、互不重叠，每张都记录了全部输出
+	var prevLargest string
+	for i, p := range tables {
+		props, err := sstable.ReadProperties(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(props.OutputFiles) != 4 || props.NumTombstones != 0 {
+			t.Fatalf("%s: props = %+v", p, props)
+		}
+		entries, err := sstable.Range(p, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 && entries[0].Key <= prevLargest {
+			t.Fatalf("%s overlaps the previous output: smallest %s, previous largest %s", p, entries[0].Key, prevLargest)
+		}
+		prevLargest = entries[len(entries)-1].Key
+	}
+	checkSubcompactionDB(t, d)
+
+	// 同一次 compaction 的输出算一张表，不会马上再次触发
+	if compacted, err := d.MaybeCompact(); err != nil || compacted {
+		t.Fatalf("MaybeCompact = %v, %v", compacted, err)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	d, err = OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if got := d.versions.current().tables; len(got) != 4 {
+		t.Fatalf("sstables after reopen = %v", got)
+	}
+	checkSubcompactionDB(t, d)
+}
+
+func TestSubcompactionSmallInputNotSplit(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, BlockSize: 256, MaxSubcompactions: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	fillSubcompactionDB(t, d)
+
+	// 输入远小于 DefaultSubcompactionMinBytes，不切分
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if tables := d.versions.current().tables; len(tables) != 1 {
+		t.Fatalf("sstables = %v", tables)
+	}
+	checkSubcompactionDB(t, d)
+}
+
+func TestSubcompactionPartialOutputDropped(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	opts := Options{DisableFsync: true, BlockSize: 256, MaxSubcompactions: 4, SubcompactionMinBytes: 1}
+	d, err := OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fillSubcompactionDB(t, d)
+	inputs := d.versions.current().tables
+	saved := make(map[string][]byte)
+	for _, p := range inputs {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved[p] = b
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	outputs := d.versions.current().tables
+	if len(outputs) < 2 {
+		t.Fatalf("sstables = %v, want several outputs", outputs)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟只 rename 了一部分输出就崩溃：输入都还在，缺一张输出
+	for p, b := range saved {
+		if err := os.WriteFile(p, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Remove(outputs[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if got := d.versions.current().tables; fmt.Sprint(got) != fmt.Sprint(inputs) {
+		t.Fatalf("sstables after reopen = %v, want the inputs %v", got, inputs)
+	}
+	for _, p := range outputs[1:] {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Fatalf("partial output %s not removed: %v", p, err)
+		}
+	}
+	checkSubcompactionDB(t, d)
+}
@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"monolithdb/internal/memtable"
+	"monolithdb/internal/sstable"
+	"monolithdb/internal/types"
+	"monolithdb/internal/wal"
+)
+
+// PutWithTTL 写入一个 ttl 之后过期的值；ttl <= 0 等同于 Put。
+// 过期的 key 对读取来说等同于已删除。
+func (d *DB) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	if ttl <= 0 {
+		return d.Put(key, value)
+	}
+	defer d.latency.observe(latPut, d.latency.start())
+	op := WriteOp{Key: d.normKey(key), Value: value, TTL: ttl}
+	if err := d.interceptWrite(&op); err != nil {
+		return err
+	}
+	if err := d.checkSize(op.Key, op.Value); err != nil {
+		return err
+	}
+	if op.TTL <= 0 {
+		return d.put(context.Background(), op.Key, op.Value)
+	}
+	return d.putTTL(context.Background(), op.Key, op.Value, op.TTL)
+}
+
+// putTTL 是 PutWithTTL 的实现，key 已经规范化并经过了 WriteInterceptors。
+func (d *DB) putTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.throttleWrite(ctx); err != nil {
+		return err
+	}
+
+	expiresAt := d.now() + int64(ttl)
+	if err := d.wal.AppendPutTTL(key, value, expiresAt); err != nil {
+		return err
+	}
+	d.mem.PutEntry(types.Entry{Key: key, Value: value, ExpiresAt: expiresAt, Seq: d.lastSeq + 1})
+	d.invalidateCache(key)
+	if d.evict != nil {
+		d.evict.added(key, len(value))
+	}
+	d.commit(wal.Record{Op: wal.OpPutTTL, Key: key, Value: value, ExpiresAt: expiresAt})
+	return nil
+}
+
+// Touch 把 keys 中当前存在的 key 的过期时间统一改为 now+ttl（ttl <= 0 表示取消过期），
+// 返回实际更新的 key 数。不存在、已删除或已过期的 key 会被跳过。
+//
+// 整批更新只写一条 WAL 记录，且记录里只有 key 和新的过期时间，不会重写 value。
+func (d *DB) Touch(keys []string, ttl time.Duration) (int, error) {
+	if d.opts.ReadOnly {
+		return 0, ErrReadOnly
+	}
+	keys = d.normKeys(keys)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.throttleWrite(context.Background()); err != nil {
+		return 0, err
+	}
+
+	now := d.now()
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = now + int64(ttl)
+	}
+
+	var live []types.Entry
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		e, ok, err := lookup(d.mem, d.imm, d.versions, k, d.sstReadOptions(ReadOptions{}))
+		if err != nil {
+			return 0, err
+		}
+		if !ok || expired(e, now) {
+			continue
+		}
+		live = append(live, e)
+	}
+	if len(live) == 0 {
+		return 0, nil
+	}
+
+	touched := make([]string, len(live))
+	for i, e := range live {
+		touched[i] = e.Key
+	}
+	if err := d.wal.AppendTouch(touched, expiresAt); err != nil {
+		return 0, err
+	}
+
+	for _, e := range live {
+		d.mem.PutEntry(types.Entry{Key: e.Key, Value: e.Value, ExpiresAt: expiresAt, Seq: d.lastSeq + 1})
+		d.invalidateCache(e.Key)
+	}
+	d.commit(wal.Record{Op: wal.OpTouch, Keys: touched, ExpiresAt: expiresAt})
+	return len(live), nil
+}
+
+// TTL 返回 key 的剩余存活时间。key 不存在（含已删除、已过期）时 ok 为 false；
+// 存在但没有过期时间时返回 ttl = 0。
+func (d *DB) TTL(key string) (ttl time.Duration, ok bool, err error) {
+	key = d.normKey(key)
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	e, ok, err := lookup(d.mem, d.imm, d.versions, key, d.sstReadOptions(ReadOptions{}))
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	now := d.now()
+	if expired(e, now) {
+		return 0, false, nil
+	}
+	if e.ExpiresAt == 0 {
+		return 0, true, nil
+	}
+	return time.Duration(e.ExpiresAt - now), true, nil
+}
+
+// expired 判断 e 在 now（unix 纳秒）时是否已过期。
+func expired(e types.Entry, now int64) bool {
+	return e.ExpiresAt != 0 && e.ExpiresAt <= now
+}
+
+// lookup 返回 key 最新的一个未删除版本（不判断是否过期）。
+// 查找顺序与 Get 相同：MemTable -> 不可变 MemTable -> SSTables（见 searchTables）。
+func lookup(m *memtable.MemTable, imm []*immMemTable, vs *versionSet, key string, ro sstable.ReadOptions) (types.Entry, bool, error) {
+	if e, ok := memGet(m, imm, key, false); ok {
+		return e, !e.Tombstone, nil
+	}
+
+	e, res, err := vs.searchTables(context.Background(), key, ro)
+	if err != nil {
+		return types.Entry{}, false, err
+	}
+	return e, res == sstable.Found, nil
+}
+
+// applyRecord 把一条序号为 seq 的 WAL 记录重新应用到 MemTable（Batch 内的子记录共用同一个序号）。
+// imm / vs / ro 用于 Touch 读取旧值。
+func applyRecord(m *memtable.MemTable, imm []*immMemTable, vs *versionSet, r wal.Record, seq uint64, ro sstable.ReadOptions) error {
+	switch r.Op {
+	case wal.OpPut:
+		m.PutEntry(types.Entry{Key: r.Key, Value: r.Value, Seq: seq})
+	case wal.OpDelete:
+		m.PutEntry(types.Entry{Key: r.Key, Tombstone: true, Seq: seq})
+	case wal.OpPutTTL:
+		m.PutEntry(types.Entry{Key: r.Key, Value: r.Value, ExpiresAt: r.ExpiresAt, Seq: seq})
+	case wal.OpTouch:
+		// 写入 Touch 时这些 key 一定存在；回放时不再判断过期，
+		// 否则在旧过期时间之后重启会把本已续期的 key 丢掉
+		for _, k := range r.Keys {
+			e, ok, err := lookup(m, imm, vs, k, ro)
+			if err != nil {
+				return err
+			}
+			if ok {
+				m.PutEntry(types.Entry{Key: k, Value: e.Value, ExpiresAt: r.ExpiresAt, Seq: seq})
+			}
+		}
+	case wal.OpBatch:
+		for _, sub := range r.Batch {
+			if err := applyRecord(m, imm, vs, sub, seq, ro); err != nil {
+				return err
+			}
+		}
+	default:
+		return wal.ErrCorruptWAL
+	}
+	return nil
+}
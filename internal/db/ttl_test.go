@@ -0,0 +1,103 @@
+package db
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPutWithTTLExpires(t *testing.T) {
+	dir := t.TempDir()
+	dbDir := filepath.Join(dir, "data")
+
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	d, err := OpenWithOptions(dbDir, Options{Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+
+	// 旧值在 SST 里，新值带 TTL：过期后不能让旧值“复活”
+	if err := d.Put("k", []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.PutWithTTL("k", []byte("new"), 30*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	v, ok, err := d.Get("k")
+	if err != nil || !ok || !bytes.Equal(v, []byte("new")) {
+		t.Fatalf("expected k=new before expiry, got v=%q ok=%v err=%v", v, ok, err)
+	}
+
+	clock.Advance(30 * time.Millisecond)
+
+	if v, ok, err := d.Get("k"); err != nil || ok {
+		t.Fatalf("expected k to be expired, got v=%q ok=%v err=%v", v, ok, err)
+	}
+}
+
+func TestTouchExtendsExpiryWithoutRewritingValues(t *testing.T) {
+	dir := t.TempDir()
+	dbDir := filepath.Join(dir, "data")
+
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	d, err := OpenWithOptions(dbDir, Options{Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	big := bytes.Repeat([]byte("x"), 64*1024)
+	if err := d.PutWithTTL("a", big, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.PutWithTTL("b", big, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	// 让值落到 SST，WAL 被清空
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := d.Touch([]string{"a", "b", "missing", "a"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 keys touched, got %d", n)
+	}
+
+	// 整批只有一条很小的 WAL 记录
+	st, err := os.Stat(filepath.Join(dbDir, walFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Size() >= int64(len(big)) {
+		t.Fatalf("expected touch to not rewrite values into WAL, wal size=%d", st.Size())
+	}
+
+	clock.Advance(80 * time.Millisecond)
+	_ = d.Close()
+
+	// 重启后通过回放 Touch 记录恢复新的过期时间
+	d2, err := OpenWithOptions(dbDir, Options{Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d2.Close() }()
+
+	for _, k := range []string{"a", "b"} {
+		v, ok, err := d2.Get(k)
+		if err != nil || !ok || !bytes.Equal(v, big) {
+			t.Fatalf("expected %s to survive past original TTL, got len=%d ok=%v err=%v", k, len(v), ok, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultLockTimeout 是 TxnOptions.LockTimeout 为 0 时等待 key 锁的时间。
+const DefaultLockTimeout = time.Second
+
+// ErrTxnDone 表示事务已经提交或回滚。
+var ErrTxnDone = errors.New("db: transaction already committed or rolled back")
+
+// TxnOptions 控制事务的行为。零值即默认配置。
+type TxnOptions struct {
+	// LockTimeout 是等待其他事务释放 key 锁的最长时间，0 表示 DefaultLockTimeout。
+	LockTimeout time.Duration
+}
+
+// Txn 是一个悲观事务：GetForUpdate / Put / Delete 先获取 key 的排他锁，
+// 与之冲突的事务会阻塞等待而不是在提交时失败。锁一直持有到 Commit 或 Rollback。
+//
+// 写操作缓存在事务内，Commit 时作为一个 Batch 原子地写入。
+// 锁只在事务之间生效：不经过事务的 Put / Delete 不会等待，也不会被阻塞。
+//
+// Txn 不是并发安全的。
+type Txn struct {
+	d    *DB
+	opts TxnOptions
+
+	batch  Batch
+	writes map[string]txnWrite
+	locked []string // 由 lockManager 维护
+	done   bool
+}
+
+type txnWrite struct {
+	value   []byte
+	deleted bool
+}
+
+// BeginTxn 开始一个悲观事务。
+func (d *DB) BeginTxn(opts TxnOptions) *Txn {
+	if opts.LockTimeout <= 0 {
+		opts.LockTimeout = DefaultLockTimeout
+	}
+	return &Txn{d: d, opts: opts, writes: make(map[string]txnWrite)}
+}
+
+// Get 读取 key，能读到本事务尚未提交的写入；不加锁。
+func (t *Txn) Get(key string) ([]byte, bool, error) {
+	if t.done {
+		return nil, false, ErrTxnDone
+	}
+	key = t.d.normKey(key)
+	if w, ok := t.writes[key]; ok {
+		return w.value, !w.deleted, nil
+	}
+	return t.d.Get(key)
+}
+
+// GetForUpdate 获取 key 的锁后再读取。之后直到提交，其他事务都不能修改这个 key。
+// 等锁超时返回 ErrLockTimeout，检测到死锁返回 ErrDeadlock。
+func (t *Txn) GetForUpdate(key string) ([]byte, bool, error) {
+	key = t.d.normKey(key)
+	if err := t.lock(key); err != nil {
+		return nil, false, err
+	}
+	return t.Get(key)
+}
+
+// Put 获取 key 的锁并在事务内写入 key。
+func (t *Txn) Put(key string, value []byte) error {
+	key = t.d.normKey(key)
+	if err := t.lock(key); err != nil {
+		return err
+	}
+	t.batch.Put(key, value)
+	t.writes[key] = txnWrite{value: value}
+	return nil
+}
+
+// Delete 获取 key 的锁并在事务内删除 key。
+func (t *Txn) Delete(key string) error {
+	key = t.d.normKey(key)
+	if err := t.lock(key); err != nil {
+		return err
+	}
+	t.batch.Delete(key)
+	t.writes[key] = txnWrite{deleted: true}
+	return nil
+}
+
+func (t *Txn) lock(key string) error {
+	if t.done {
+		return ErrTxnDone
+	}
+	if t.d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	return t.d.locks.lock(t, key, t.opts.LockTimeout)
+}
+
+// Commit 原子地写入事务内的所有修改并释放锁。写入失败时锁同样会被释放。
+func (t *Txn) Commit() error {
+	if t.done {
+		return ErrTxnDone
+	}
+	t.done = true
+	defer t.d.locks.unlockAll(t)
+	return t.d.Write(&t.batch)
+}
+
+// Rollback 丢弃事务内的修改并释放锁。对已结束的事务调用是 no-op。
+func (t *Txn) Rollback() {
+	if t.done {
+		return
+	}
+	t.done = true
+	t.d.locks.unlockAll(t)
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTxnGetForUpdateSerializesWriters(t *testing.T) {
+	d, err := Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// 并发的读-改-写：有锁时不会丢失更新
+	const workers, rounds = 8, 20
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				txn := d.BeginTxn(TxnOptions{LockTimeout: 10 * time.Second})
+				v, _, err := txn.GetForUpdate("counter")
+				if err != nil {
+					t.Error(err)
+					txn.Rollback()
+					return
+				}
+				n, _ := strconv.Atoi(string(v))
+				if err := txn.Put("counter", []byte(strconv.Itoa(n+1))); err != nil {
+					t.Error(err)
+				}
+				if err := txn.Commit(); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	v, _, _ := d.Get("counter")
+	if string(v) != strconv.Itoa(workers*rounds) {
+		t.Fatalf("counter = %s, want %d", v, workers*rounds)
+	}
+}
+
+func TestTxnReadYourWritesAndRollback(t *testing.T) {
+	d, err := Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	_ = d.Put("a", []byte("old"))
+
+	txn := d.BeginTxn(TxnOptions{})
+	_ = txn.Put("a", []byte("new"))
+	_ = txn.Delete("b")
+	if v, ok, _ := txn.Get("a"); !ok || string(v) != "new" {
+		t.Fatalf("txn Get(a) = %q %v", v, ok)
+	}
+	if v, _, _ := d.Get("a"); string(v) != "old" {
+		t.Fatalf("uncommitted write visible: %q", v)
+	}
+	txn.Rollback()
+	if v, _, _ := d.Get("a"); string(v) != "old" {
+		t.Fatalf("rolled back write visible: %q", v)
+	}
+	if err := txn.Put("a", nil); !errors.Is(err, ErrTxnDone) {
+		t.Fatalf("err = %v", err)
+	}
+
+	// 回滚后锁已释放
+	t2 := d.BeginTxn(TxnOptions{LockTimeout: 10 * time.Millisecond})
+	if _, _, err := t2.GetForUpdate("a"); err != nil {
+		t.Fatal(err)
+	}
+	t2.Rollback()
+}
+
+func TestTxnLockTimeoutAndDeadlock(t *testing.T) {
+	d, err := Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	t1 := d.BeginTxn(TxnOptions{LockTimeout: 10 * time.Second})
+	t2 := d.BeginTxn(TxnOptions{LockTimeout: 20 * time.Millisecond})
+	if _, _, err := t1.GetForUpdate("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := t2.GetForUpdate("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := t2.Put("a", nil); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("err = %v, want ErrLockTimeout", err)
+	}
+
+	// t1 等 b（被 t2 持有），t2 再等 a（被 t1 持有）就形成环
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := t1.GetForUpdate("b")
+		done <- err
+	}()
+	for {
+		d.locks.mu.Lock()
+		waiting := d.locks.waitFor[t1] != nil
+		d.locks.mu.Unlock()
+		if waiting {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, _, err := t2.GetForUpdate("a"); !errors.Is(err, ErrDeadlock) {
+		t.Fatalf("err = %v, want ErrDeadlock", err)
+	}
+
+	// t2 回滚后 t1 拿到锁
+	t2.Rollback()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := t1.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"monolithdb/internal/sstable"
+)
+
+// UpdateTx 是 Update 回调里使用的读写句柄。读取能看到本次回调里尚未提交的写入，
+// 写入缓存起来，回调成功返回后作为一个 Batch 原子地提交。只能在回调内部使用。
+type UpdateTx struct {
+	d      *DB
+	sro    sstable.ReadOptions
+	now    int64
+	batch  Batch
+	writes map[string]txnWrite
+	done   bool
+}
+
+// Update 持有写锁执行 fn：fn 里的读取和写入之间不会插入任何其他写操作，
+// 适合“读取 - 修改 - 条件写入”这类需要原子执行的多步操作（例如服务端脚本）。
+// fn 返回 nil 时提交它的所有写入，返回错误时全部丢弃，并把错误原样返回。
+//
+// fn 执行期间整个数据库的写入都被阻塞，应当尽快返回；fn 内不能调用 DB 的其他方法，否则会死锁。
+func (d *DB) Update(fn func(tx *UpdateTx) error) error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.throttleWrite(context.Background()); err != nil {
+		return err
+	}
+
+	tx := &UpdateTx{d: d, sro: d.sstReadOptions(ReadOptions{}), now: d.now(), writes: make(map[string]txnWrite)}
+	err := fn(tx)
+	tx.done = true
+	if err != nil {
+		return err
+	}
+	return d.writeLocked(&tx.batch)
+}
+
+// Get 读取 key，能读到本次回调里之前的写入。
+func (tx *UpdateTx) Get(key string) ([]byte, bool, error) {
+	if tx.done {
+		return nil, false, ErrTxnDone
+	}
+	key = tx.d.normKey(key)
+	if w, ok := tx.writes[key]; ok {
+		return w.value, !w.deleted, nil
+	}
+	v, ok, err := tx.d.getLocked(context.Background(), key, tx.sro, tx.now, false)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	if v, err = tx.d.interceptRead(key, v); err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+// Put 写入 key。
+func (tx *UpdateTx) Put(key string, value []byte) error {
+	return tx.PutWithTTL(key, value, 0)
+}
+
+// PutWithTTL 写入带过期时间的 key，ttl <= 0 等同于 Put。
+func (tx *UpdateTx) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	if tx.done {
+		return ErrTxnDone
+	}
+	key = tx.d.normKey(key)
+	tx.batch.PutWithTTL(key, value, ttl)
+	tx.writes[key] = txnWrite{value: value}
+	return nil
+}
+
+// Delete 删除 key。
+func (tx *UpdateTx) Delete(key string) error {
+	if tx.done {
+		return ErrTxnDone
+	}
+	key = tx.d.normKey(key)
+	tx.batch.Delete(key)
+	tx.writes[key] = txnWrite{deleted: true}
+	return nil
+}
+
+// GetSet 原子地把 key 写成 value 并返回之前的值（按 MemTable -> SST 的顺序读取），
+// existed 为 false 表示 key 之前不存在（包括已删除和已过期）。读取和写入在同一次写锁内完成，
+// 并发的 GetSet 之间每个旧值都恰好被一个调用方拿到，调用方不需要额外加锁。
+func (d *DB) GetSet(key string, value []byte) (old []byte, existed bool, err error) {
+	err = d.Update(func(tx *UpdateTx) error {
+		var err error
+		if old, existed, err = tx.Get(key); err != nil {
+			return err
+		}
+		return tx.Put(key, value)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return old, existed, nil
+}
+
+// PutIfAbsent 只在 key 当前不存在（包括已删除和已过期）时写入 value，返回是否写入了。
+// 检查和写入在同一次写锁内完成，并发调用中只有一个能写入成功。
+func (d *DB) PutIfAbsent(key string, value []byte) (bool, error) {
+	var written bool
+	err := d.Update(func(tx *UpdateTx) error {
+		_, ok, err := tx.Get(key)
+		if err != nil || ok {
+			return err
+		}
+		written = true
+		return tx.Put(key, value)
+	})
+	if err != nil {
+		return false, err
+	}
+	return written, nil
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestUpdateIsAtomic(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	errStop := errors.New("stop")
+	err = d.Update(func(tx *UpdateTx) error {
+		if err := tx.Put("a", []byte("1")); err != nil {
+			return err
+		}
+		if v, ok, err := tx.Get("a"); err != nil || !ok || string(v) != "1" {
+			t.Fatalf("read own write = %q, %v, %v", v, ok, err)
+		}
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("err = %v", err)
+	}
+	if _, ok, _ := d.Get("a"); ok {
+		t.Fatal("failed Update must not write anything")
+	}
+
+	var saved *UpdateTx
+	err = d.Update(func(tx *UpdateTx) error {
+		saved = tx
+		if err := tx.Put("a", []byte("1")); err != nil {
+			return err
+		}
+		return tx.Delete("missing")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, _ := d.Get("a"); !ok || string(v) != "1" {
+		t.Fatalf("a = %q, %v", v, ok)
+	}
+	if err := saved.Put("b", nil); !errors.Is(err, ErrTxnDone) {
+		t.Fatalf("use after Update: err = %v", err)
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if old, ok, err := d.GetSet("k", []byte("v0")); err != nil || ok || old != nil {
+		t.Fatalf("first GetSet = %q, %v, %v", old, ok, err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// 旧值在 SST 里
+	if old, ok, err := d.GetSet("k", []byte("v1")); err != nil || !ok || string(old) != "v0" {
+		t.Fatalf("GetSet over sst = %q, %v, %v", old, ok, err)
+	}
+
+	// 并发的 GetSet 组成一条链：每个写入的值恰好作为旧值被返回一次，最后一个留在数据库里
+	const workers, rounds = 8, 100
+	var mu sync.Mutex
+	seen := map[string]int{}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				old, ok, err := d.GetSet("k", []byte(fmt.Sprintf("w%d-%d", w, i)))
+				if err != nil || !ok {
+					t.Errorf("GetSet: %v, %v", ok, err)
+					return
+				}
+				mu.Lock()
+				seen[string(old)]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	last, _, err := d.Get("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen[string(last)]++
+	if len(seen) != workers*rounds+1 {
+		t.Fatalf("%d distinct values, want %d", len(seen), workers*rounds+1)
+	}
+	for v, n := range seen {
+		if n != 1 {
+			t.Fatalf("value %s returned %d times", v, n)
+		}
+	}
+}
+
+func TestPutIfAbsent(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if ok, err := d.PutIfAbsent("k", []byte("1")); err != nil || !ok {
+		t.Fatalf("absent key: %v, %v", ok, err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := d.PutIfAbsent("k", []byte("2")); err != nil || ok {
+		t.Fatalf("existing key in sst: %v, %v", ok, err)
+	}
+	if v, _, _ := d.Get("k"); string(v) != "1" {
+		t.Fatalf("k = %q", v)
+	}
+	// tombstone 算作不存在
+	if err := d.Delete("k"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := d.PutIfAbsent("k", []byte("3")); err != nil || !ok {
+		t.Fatalf("deleted key: %v, %v", ok, err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	winners := 0
+	for w := 0; w < 16; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := d.PutIfAbsent("lock", []byte(fmt.Sprint(w)))
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				mu.Lock()
+				winners++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if winners != 1 {
+		t.Fatalf("%d writers won", winners)
+	}
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/sstable"
+	"monolithdb/internal/wal"
+)
+
+func TestVerifyChecksums(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	value := bytes.Repeat([]byte("v"), 100)
+	for i := 0; i < 2000; i++ {
+		if err := d.Put(fmt.Sprintf("k%05d", i), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("tail", value); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := d.VerifyChecksums()
+	if err != nil || !rep.OK() || rep.TablesChecked != 1 || rep.WALFilesChecked != 1 {
+		t.Fatalf("healthy db: %+v, %v", rep, err)
+	}
+
+	// 改坏表中间的一个字节
+	table := d.versions.current().tables[0]
+	b, err := os.ReadFile(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[len(b)/3] ^= 0xff
+	if err := os.WriteFile(table, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err = d.VerifyChecksums()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Corrupt) != 1 || rep.Corrupt[0].File != filepath.Join(sstDirName, filepath.Base(table)) ||
+		len(rep.Corrupt[0].Blocks) != 1 || !errors.Is(rep.Corrupt[0].Err, sstable.ErrCorruptSST) {
+		t.Fatalf("corrupt table: %+v", rep)
+	}
+	blk := rep.Corrupt[0].Blocks[0]
+	if blk.Start == "" || blk.End == "" || blk.Start >= blk.End {
+		t.Fatalf("corrupt block key range [%q, %q)", blk.Start, blk.End)
+	}
+}
+
+func TestVerifyChecksumsWAL(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := d.Put(fmt.Sprintf("k%d", i), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.VerifyChecksums(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("after Close: %v", err)
+	}
+
+	// 在 WAL 中间改坏一个字节；只读模式下打开时不会截断损坏的尾部
+	walPath := filepath.Join(dir, walFileName)
+	b, err := os.ReadFile(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[len(b)/2] ^= 0xff
+	if err := os.WriteFile(walPath, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d, err = OpenWithOptions(dir, Options{ReadOnly: true, TolerateCorruptWALTail: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	rep, err := d.VerifyChecksums()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Corrupt) != 1 || rep.Corrupt[0].File != walFileName || !errors.Is(rep.Corrupt[0].Err, wal.ErrCorruptWAL) ||
+		rep.Corrupt[0].Offset <= 0 || rep.Corrupt[0].Offset >= int64(len(b)) {
+		t.Fatalf("corrupt wal: %+v", rep)
+	}
+}
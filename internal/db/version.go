@@ -0,0 +1,257 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"monolithdb/internal/manifest"
+	"monolithdb/internal/sstable"
+	"monolithdb/internal/types"
+	"monolithdb/internal/vfs"
+)
+
+// version 是某一时刻的 SST 列表（newest first）。创建后不再修改，
+// 读者拿到之后即使 Flush / Compact 同时安装了新表，也能继续安全地使用。
+type version struct {
+	tables []string
+}
+
+// versionEdit 描述一次对 SST 列表的修改。
+type versionEdit struct {
+	// added 是新安装的表（newest first）。deleted 非空时放在被删除的第一张表的位置
+	// （compaction 的输出替换它的输入，比它新的表仍然排在前面），否则放在最前面（flush）。
+	added   []string
+	deleted []string
+}
+
+// versionSet 管理当前 version 和文件编号的分配。
+// 所有修改都在 mu 内基于当前 version 复制出新列表，再原子地替换 current。
+type versionSet struct {
+	mu     sync.Mutex
+	cur    atomic.Pointer[version]
+	nextID uint64
+
+	// manifest 是数据目录里 MANIFEST 的内容，nil 表示只读打开。删除文件之前由 persistFileNumber
+	// 把 nextID 记进去，编号最大的文件被删除之后重启也不会重复使用它的编号
+	// （增量备份按文件名识别没有变化的文件，见 IncrementalBackup）
+	manifest     *manifest.Manifest
+	manifestPath string
+
+	// maxSeqs 缓存每张表 properties 里的 MaxSeq（见 maxSeq），表被删除时一并移除
+	seqMu   sync.Mutex
+	maxSeqs map[string]uint64
+
+	// readers 缓存每张表打开的 sstable.Reader（见 getEntry），表的文件删除时关闭；
+	// close 之后为 nil，不再缓存。refs 是每张表的引用数（正在使用的 Reader 和固定住它的 Snapshot），
+	// obsolete 是已经不在当前 version 里、等待删除的表（见 removeObsolete）
+	readerMu sync.Mutex
+	readers  map[string]*sstable.Reader
+	refs     map[string]int
+	obsolete map[string]bool
+
+	// bytesRead 是这些 Reader 从表文件读取的字节数（见 AmplificationStats.SSTBytesRead）
+	bytesRead atomic.Uint64
+
+	// access 是每张表的点查访问统计，nil 表示未开启（见 Options.TableStatsSampleRate）
+	access *tableAccess
+
+	// vlog 是表里的值日志引用指向的值日志（见 resolve），nil 表示没有值日志
+	vlog *valueLog
+
+	// fs 是表文件所在的文件系统（见 Options.FS），删除 obsolete 的表时使用
+	fs vfs.FS
+}
+
+func newVersionSet(tables []string, nextID uint64) *versionSet {
+	vs := &versionSet{nextID: nextID, maxSeqs: make(map[string]uint64), readers: make(map[string]*sstable.Reader),
+		refs: make(map[string]int), obsolete: make(map[string]bool), fs: vfs.Default}
+	vs.cur.Store(&version{tables: tables})
+	return vs
+}
+
+// current 返回当前 version，不需要持有任何锁。
+func (vs *versionSet) current() *version {
+	return vs.cur.Load()
+}
+
+// newFileNumber 分配一个新的文件编号（SST 和值日志共用）。编号用过即作废，写表失败也不会复用。
+func (vs *versionSet) newFileNumber() uint64 {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	id := vs.nextID
+	vs.nextID++
+	return id
+}
+
+// persistFileNumber 在删除 SST 或值日志文件之前调用：把 nextID 写进 MANIFEST（已经写过时什么也不做）。
+func (vs *versionSet) persistFileNumber() error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	if vs.manifest == nil || vs.manifest.NextFileNumber >= vs.nextID {
+		return nil
+	}
+	m := *vs.manifest
+	m.NextFileNumber = vs.nextID
+	if err := manifest.WriteFS(vs.fs, vs.manifestPath, &m); err != nil {
+		return err
+	}
+	vs.manifest = &m
+	return nil
+}
+
+// apply 基于当前 version 应用 e，安装并返回新的 version。
+// 被删除的表只记为 obsolete，文件由 removeObsolete 在没有读取使用它之后删除。
+func (vs *versionSet) apply(e versionEdit) *version {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	deleted := make(map[string]bool, len(e.deleted))
+	for _, p := range e.deleted {
+		deleted[p] = true
+	}
+
+	old := vs.cur.Load().tables
+	tables := make([]string, 0, len(old)+len(e.added))
+	inserted := len(e.deleted) == 0
+	if inserted {
+		tables = append(tables, e.added...)
+	}
+	for _, p := range old {
+		if deleted[p] {
+			if !inserted {
+				tables = append(tables, e.added...)
+				inserted = true
+			}
+			continue
+		}
+		tables = append(tables, p)
+	}
+	if !inserted {
+		tables = append(tables, e.added...)
+	}
+
+	v := &version{tables: tables}
+	vs.cur.Store(v)
+
+	vs.seqMu.Lock()
+	for _, p := range e.deleted {
+		delete(vs.maxSeqs, p)
+	}
+	vs.seqMu.Unlock()
+	vs.readerMu.Lock()
+	for _, p := range e.deleted {
+		vs.obsolete[p] = true
+	}
+	vs.readerMu.Unlock()
+	if vs.access != nil && len(e.deleted) > 0 {
+		vs.access.replaced(e.added, e.deleted)
+	}
+	return v
+}
+
+// maxSeq 返回表中记录的最大序号，0 表示表里的记录没有序号（或 properties 读取失败）。
+// 第一次访问时读取 properties，之后使用缓存。
+func (vs *versionSet) maxSeq(path string, ro sstable.ReadOptions) uint64 {
+	vs.seqMu.Lock()
+	defer vs.seqMu.Unlock()
+
+	if n, ok := vs.maxSeqs[path]; ok {
+		return n
+	}
+	props, err := sstable.ReadPropertiesWithOptions(path, ro)
+	if err != nil {
+		return 0
+	}
+	vs.maxSeqs[path] = props.MaxSeq
+	return props.MaxSeq
+}
+
+// searchTables 在当前 version 的 SST 中查找 key 的最新版本，结果为 Found / Deleted / NotFound。
+//
+// 正常情况下按文件编号从新到旧第一个命中的就是最新版本；但 ingest / repair / 复制
+// 产生的表的编号不一定反映写入顺序，所以命中之后还会检查更旧的表中 MaxSeq 大于
+// 命中记录序号的表，取序号最大的版本。没有序号的记录（旧表）只按文件顺序。
+// 每读一张表之前检查一次 ctx，取消时返回 ctx.Err()。找到的 value 在值日志里时读出 value 本身。
+func (vs *versionSet) searchTables(ctx context.Context, key string, ro sstable.ReadOptions) (types.Entry, sstable.GetResult, error) {
+	return vs.searchTablesIn(ctx, vs.current().tables, key, ro)
+}
+
+// searchTablesIn 与 searchTables 相同，但在 tables（newest first）中查找，例如 Snapshot 固定住的表。
+func (vs *versionSet) searchTablesIn(ctx context.Context, tables []string, key string, ro sstable.ReadOptions) (types.Entry, sstable.GetResult, error) {
+	var best types.Entry
+	res := sstable.NotFound
+	sampled := vs.access != nil && vs.access.sample()
+	for _, p := range tables {
+		if res != sstable.NotFound && (best.Seq == 0 || vs.maxSeq(p, ro) <= best.Seq) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return types.Entry{}, sstable.NotFound, err
+		}
+		e, r, err := vs.getEntry(p, key, ro)
+		if err != nil {
+			return types.Entry{}, sstable.NotFound, err
+		}
+		if sampled {
+			vs.access.record(p, r != sstable.NotFound)
+		}
+		if r == sstable.NotFound {
+			continue
+		}
+		if res == sstable.NotFound || e.Seq > best.Seq {
+			best, res = e, r
+		}
+	}
+	if res == sstable.Found {
+		var err error
+		if best, err = vs.resolve(best); err != nil {
+			return types.Entry{}, sstable.NotFound, err
+		}
+	}
+	return best, res, nil
+}
+
+// getEntry 在 path 中查找 key，使用缓存的 Reader：表的 bloom 和索引只在第一次查找时读取。
+// 调用方持有 DB 的锁（读锁即可）。
+func (vs *versionSet) getEntry(path, key string, ro sstable.ReadOptions) (types.Entry, sstable.GetResult, error) {
+	r, release, err := vs.reader(path, ro)
+	if err != nil {
+		return types.Entry{}, sstable.NotFound, err
+	}
+	defer release()
+	return r.GetEntryWithOptions(key, ro)
+}
+
+// reader 返回 path 缓存的 Reader，用完之后调用 release：在此之前表即使被 compaction 替换，
+// 文件也不会被删除（见 unref）。已经 close 时不再缓存，每次重新打开，release 关闭它。
+// path 必须取自调用方持有 DB 的锁（读锁即可）时的当前 version。
+func (vs *versionSet) reader(path string, ro sstable.ReadOptions) (*sstable.Reader, func(), error) {
+	vs.readerMu.Lock()
+	defer vs.readerMu.Unlock()
+	if r, ok := vs.readers[path]; ok {
+		vs.refs[path]++
+		return r, func() { vs.unref(path) }, nil
+	}
+	ro.BytesRead = &vs.bytesRead
+	r, err := sstable.OpenReader(path, ro)
+	if err != nil {
+		return nil, nil, err
+	}
+	if vs.readers == nil {
+		return r, func() { _ = r.Close() }, nil
+	}
+	vs.readers[path] = r
+	vs.refs[path]++
+	return r, func() { vs.unref(path) }, nil
+}
+
+// close 关闭所有缓存的 Reader。调用方持有 DB 的写锁，并且已经调用过 removeObsolete。
+func (vs *versionSet) close() {
+	vs.readerMu.Lock()
+	defer vs.readerMu.Unlock()
+	for _, r := range vs.readers {
+		_ = r.Close()
+	}
+	vs.readers = nil
+}
@@ -0,0 +1,226 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"monolithdb/internal/sstable"
+	"monolithdb/internal/types"
+)
+
+func TestVersionSetApply(t *testing.T) {
+	vs := newVersionSet([]string{"3", "2", "1"}, 4)
+	if id := vs.newFileNumber(); id != 4 {
+		t.Fatalf("file number = %d", id)
+	}
+	if id := vs.newFileNumber(); id != 5 {
+		t.Fatalf("file number = %d", id)
+	}
+
+	// flush：新表放在最前面，之前拿到的 version 不受影响
+	before := vs.current()
+	vs.apply(versionEdit{added: []string{"4"}})
+	if want := []string{"4", "3", "2", "1"}; !reflect.DeepEqual(vs.current().tables, want) {
+		t.Fatalf("after flush = %v", vs.current().tables)
+	}
+	if want := []string{"3", "2", "1"}; !reflect.DeepEqual(before.tables, want) {
+		t.Fatalf("old version modified: %v", before.tables)
+	}
+
+	// compaction：输出替换输入所在的位置，比输入新的表仍在前面
+	vs.apply(versionEdit{added: []string{"5"}, deleted: []string{"2", "1"}})
+	if want := []string{"4", "3", "5"}; !reflect.DeepEqual(vs.current().tables, want) {
+		t.Fatalf("after compaction = %v", vs.current().tables)
+	}
+
+	// 输出为空的 compaction 只删除输入
+	vs.apply(versionEdit{deleted: []string{"3", "5"}})
+	if want := []string{"4"}; !reflect.DeepEqual(vs.current().tables, want) {
+		t.Fatalf("after empty compaction = %v", vs.current().tables)
+	}
+}
+
+// 编号较小（按文件顺序更旧）的表里有序号更大的记录时，读路径和 compaction 都应以序号为准
+func TestReadResolvesOverlapBySeq(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sstDir := filepath.Join(dir, sstDirName)
+	write := func(name string, entries []types.Entry) {
+		t.Helper()
+		if err := sstable.WriteTableWithOptions(filepath.Join(sstDir, name), entries, sstable.WriterOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("000001.sst", []types.Entry{
+		{Key: "gone", Tombstone: true, Seq: 6},
+		{Key: "k", Value: []byte("new"), Seq: 5},
+	})
+	write("000002.sst", []types.Entry{
+		{Key: "gone", Value: []byte("stale"), Seq: 3},
+		{Key: "k", Value: []byte("old"), Seq: 2},
+	})
+
+	d, err = OpenWithOptions(dir, Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	check := func(stage string) {
+		t.Helper()
+		if v, ok, err := d.Get("k"); err != nil || !ok || string(v) != "new" {
+			t.Fatalf("%s: Get(k) = %q, %v, %v", stage, v, ok, err)
+		}
+		if _, ok, err := d.Get("gone"); err != nil || ok {
+			t.Fatalf("%s: Get(gone) = %v, %v", stage, ok, err)
+		}
+		entries, err := d.Range("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].Key != "k" || string(entries[0].Value) != "new" {
+			t.Fatalf("%s: Range = %+v", stage, entries)
+		}
+	}
+	check("before compaction")
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	check("after compaction")
+}
+
+func TestTableReadersClosedWithTables(t *testing.T) {
+	d, err := OpenWithOptions(filepath.Join(t.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"a", "b"} {
+		if err := d.Put(k, []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		if _, _, err := d.Get(k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(d.versions.readers); n != 2 {
+		t.Fatalf("cached readers = %d, want 2", n)
+	}
+
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(d.versions.readers); n != 0 {
+		t.Fatalf("readers of compacted tables still cached: %d", n)
+	}
+	if v, ok, err := d.Get("a"); err != nil || !ok || string(v) != "a" {
+		t.Fatalf("Get(a) after compaction = %q %v %v", v, ok, err)
+	}
+	if n := len(d.versions.readers); n != 1 {
+		t.Fatalf("cached readers = %d, want 1", n)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d.versions.readers != nil {
+		t.Fatal("readers still cached after Close")
+	}
+}
+
+func BenchmarkGetFromTables(b *testing.B) {
+	d, err := OpenWithOptions(filepath.Join(b.TempDir(), "data"), Options{DisableFsync: true})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer d.Close()
+	for t := range 4 {
+		for i := range 10000 {
+			if err := d.Put(fmt.Sprintf("key%06d", i*4+t), make([]byte, 100)); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := d.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%06d", i*7919%40000)
+	}
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		if _, ok, err := d.Get(keys[i%len(keys)]); err != nil || !ok {
+			b.Fatal(ok, err)
+		}
+	}
+}
+
+func TestFlushedTablesCarrySeq(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	opts := Options{DisableFsync: true}
+	d, err := OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("k", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Ingest([]types.Entry{{Key: "k", Value: []byte("ingested")}}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	ingested := d.versions.current().tables[0]
+	if err := d.Put("k", []byte("v3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Put + Flush 写出的表：记录和 properties 都带序号
+	flushed := d.versions.current().tables[0]
+	e, res, err := sstable.GetEntry(flushed, "k")
+	if err != nil || res != sstable.Found || e.Seq != d.LastSequence() {
+		t.Fatalf("flushed record = %+v, %v, %v; want seq %d", e, res, err, d.LastSequence())
+	}
+	props, err := sstable.ReadProperties(flushed)
+	if err != nil || props.MaxSeq != d.LastSequence() {
+		t.Fatalf("flushed MaxSeq = %d, %v; want %d", props.MaxSeq, err, d.LastSequence())
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 把导入的表改成最大的文件编号：文件顺序不再反映写入顺序，只能靠序号判断 v3 更新
+	if err := os.Rename(ingested, filepath.Join(filepath.Dir(ingested), "000999.sst")); err != nil {
+		t.Fatal(err)
+	}
+	d, err = OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if v, ok, err := d.Get("k"); err != nil || !ok || string(v) != "v3" {
+		t.Fatalf("Get = %q, %v, %v; want v3", v, ok, err)
+	}
+	if entries, err := d.Range("", ""); err != nil || len(entries) != 1 || string(entries[0].Value) != "v3" {
+		t.Fatalf("Range = %v, %v", entries, err)
+	}
+}
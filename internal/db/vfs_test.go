@@ -0,0 +1,83 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"monolithdb/internal/vfs"
+)
+
+func TestOpenOnMemFS(t *testing.T) {
+	const dir = "/forgedb-memfs-test"
+	fs := vfs.NewMem()
+	opts := Options{FS: fs, ValueLogThreshold: 64}
+	d, err := OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	big := bytes.Repeat([]byte("v"), 100)
+	for i := 0; i < 100; i++ {
+		if err := d.Put(fmt.Sprintf("k%03d", i), big); err != nil {
+			t.Fatal(err)
+		}
+		if i%30 == 29 {
+			if err := d.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := d.Delete("k000"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("small", []byte("s")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Checkpoint("/ckpt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("after", []byte("wal only")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 什么也没有写到磁盘上
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("stat %s on disk: %v", dir, err)
+	}
+
+	check := func(dir string, wantAfter bool) {
+		t.Helper()
+		d, err := OpenWithOptions(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		if v, ok, err := d.Get("k050"); err != nil || !ok || !bytes.Equal(v, big) {
+			t.Fatalf("%s: k050 = %q, %v, %v", dir, v, ok, err)
+		}
+		if _, ok, err := d.Get("k000"); err != nil || ok {
+			t.Fatalf("%s: deleted key: %v, %v", dir, ok, err)
+		}
+		if v, ok, _ := d.Get("small"); !ok || string(v) != "s" {
+			t.Fatalf("%s: small = %q, %v", dir, v, ok)
+		}
+		if _, ok, _ := d.Get("after"); ok != wantAfter {
+			t.Fatalf("%s: after present = %v, want %v", dir, ok, wantAfter)
+		}
+		rep, err := d.VerifyChecksums()
+		if err != nil || !rep.OK() {
+			t.Fatalf("%s: verify: %+v, %v", dir, rep, err)
+		}
+	}
+	check(dir, true)
+	check("/ckpt", false)
+}
@@ -0,0 +1,451 @@
+package db
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"monolithdb/internal/sstable"
+	"monolithdb/internal/types"
+	"monolithdb/internal/vfs"
+)
+
+// 值日志（WiscKey 式的 key / value 分离）：开启 Options.ValueLogThreshold 后，Flush 时不小于阈值的 value
+// 追加写进 vlog/ 下的一个新文件，SST 里只保存它的位置（sstable.ValueRef），compaction 重写 SST 时
+// 不再反复搬动大 value。文件写完之后不再修改，编号与 SST 共用同一个计数器。
+//
+// 记录格式：| crc32c(uint32) | keyLen(uint32) | valLen(uint32) | key | value |，crc 覆盖之后的所有字节；
+// 保存 key 是为了读取时确认引用没有指错地方。
+//
+// 每张 SST 的 properties 记录了它引用每个值日志文件的字节数（sstable.Properties.ValueLogBytes）：
+// 不再被任何 SST 引用的文件在 compaction 之后删除；仍被引用、但大部分 value 已经失效的文件，
+// 由合并到最旧一层的 compaction 把其中有效的 value 搬到新文件（见 moveValues），之后旧文件不再被引用。
+
+const (
+	vlogDirName    = "vlog"
+	vlogHeaderSize = 12
+
+	// DefaultValueLogGCRatio 是 Options.ValueLogGCRatio 的默认值。
+	DefaultValueLogGCRatio = 0.5
+)
+
+// ErrCorruptValueLog 表示 SST 里的引用在值日志里找不到对应的记录：文件不存在、校验和或 key 不一致。
+var ErrCorruptValueLog = errors.New("db: corrupt value log")
+
+var vlogCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// valueLog 是 vlog/ 目录，缓存读取用的文件句柄。
+type valueLog struct {
+	fs  vfs.FS
+	dir string
+
+	mu    sync.Mutex
+	files map[uint64]vfs.File // close 之后为 nil，不再缓存
+}
+
+func newValueLog(fs vfs.FS, dir string) *valueLog {
+	return &valueLog{fs: fs, dir: dir, files: make(map[uint64]vfs.File)}
+}
+
+func (vl *valueLog) path(id uint64) string {
+	return filepath.Join(vl.dir, fmt.Sprintf("%06d.vlog", id))
+}
+
+// vlogWriter 顺序写一个新的值日志文件。
+type vlogWriter struct {
+	vl  *valueLog
+	id  uint64
+	f   vfs.File
+	w   *bufio.Writer
+	off uint64
+	buf []byte
+}
+
+// create 新建编号为 id 的值日志文件。
+func (vl *valueLog) create(id uint64) (*vlogWriter, error) {
+	if err := vl.fs.MkdirAll(vl.dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := vl.fs.OpenFile(vl.path(id), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &vlogWriter{vl: vl, id: id, f: f, w: bufio.NewWriterSize(f, 256*1024)}, nil
+}
+
+// add 追加一条记录，返回它的位置。
+func (w *vlogWriter) add(key string, value []byte) (sstable.ValueRef, error) {
+	b := binary.LittleEndian.AppendUint32(w.buf[:0], 0)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(key)))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(value)))
+	b = append(b, key...)
+	b = append(b, value...)
+	binary.LittleEndian.PutUint32(b, crc32.Checksum(b[4:], vlogCRCTable))
+	w.buf = b
+
+	if _, err := w.w.Write(b); err != nil {
+		return sstable.ValueRef{}, err
+	}
+	ref := sstable.ValueRef{File: w.id, Offset: w.off, Size: uint32(len(b))}
+	w.off += uint64(len(b))
+	return ref, nil
+}
+
+// finish 把文件写完并关闭；sync 为 true 时 fsync 文件和 vlog/ 目录，引用它的 SST 必须在这之后才安装。
+func (w *vlogWriter) finish(sync bool) error {
+	err := w.w.Flush()
+	if err == nil && sync {
+		err = w.f.Sync()
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && sync {
+		err = syncDir(w.vl.fs, w.vl.dir)
+	}
+	return err
+}
+
+// abort 放弃写了一半的文件。
+func (w *vlogWriter) abort() {
+	_ = w.f.Close()
+	_ = w.vl.fs.Remove(w.vl.path(w.id))
+}
+
+// read 读取 ref（编码后的 sstable.ValueRef）指向的 value，返回的切片是新分配的。
+func (vl *valueLog) read(key string, ref []byte) ([]byte, error) {
+	r, ok := sstable.DecodeValueRef(ref)
+	if !ok || r.Size < vlogHeaderSize {
+		return nil, fmt.Errorf("%w: invalid reference for key %q", ErrCorruptValueLog, key)
+	}
+	f, done, err := vl.file(r.File)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptValueLog, err)
+	}
+	defer done()
+
+	b := make([]byte, r.Size)
+	if _, err := f.ReadAt(b, int64(r.Offset)); err != nil {
+		return nil, fmt.Errorf("%w: read %06d.vlog @%d: %v", ErrCorruptValueLog, r.File, r.Offset, err)
+	}
+	keyLen := binary.LittleEndian.Uint32(b[4:8])
+	valLen := binary.LittleEndian.Uint32(b[8:12])
+	if crc32.Checksum(b[4:], vlogCRCTable) != binary.LittleEndian.Uint32(b[0:4]) ||
+		uint64(vlogHeaderSize)+uint64(keyLen)+uint64(valLen) != uint64(r.Size) ||
+		string(b[vlogHeaderSize:vlogHeaderSize+keyLen]) != key {
+		return nil, fmt.Errorf("%w: bad record for key %q in %06d.vlog @%d", ErrCorruptValueLog, key, r.File, r.Offset)
+	}
+	return b[vlogHeaderSize+keyLen:], nil
+}
+
+// file 返回编号为 id 的文件，用完之后调用 done。
+func (vl *valueLog) file(id uint64) (vfs.File, func(), error) {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+	if f, ok := vl.files[id]; ok {
+		return f, func() {}, nil
+	}
+	f, err := vl.fs.Open(vl.path(id))
+	if err != nil {
+		return nil, nil, err
+	}
+	if vl.files == nil {
+		// 已经 close：不再缓存
+		return f, func() { _ = f.Close() }, nil
+	}
+	vl.files[id] = f
+	return f, func() {}, nil
+}
+
+// remove 删除编号为 id 的文件。调用方持有 DB 的写锁，不会有读取正在使用它。
+func (vl *valueLog) remove(id uint64) error {
+	vl.mu.Lock()
+	if f, ok := vl.files[id]; ok {
+		_ = f.Close()
+		delete(vl.files, id)
+	}
+	vl.mu.Unlock()
+	return vl.fs.Remove(vl.path(id))
+}
+
+// close 关闭所有缓存的句柄。
+func (vl *valueLog) close() {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+	for _, f := range vl.files {
+		_ = f.Close()
+	}
+	vl.files = nil
+}
+
+// listValueLogs 返回 dir 下所有值日志文件的编号（升序），目录不存在时返回空。
+func listValueLogs(fs vfs.FS, dir string) ([]uint64, error) {
+	names, err := vfs.Glob(fs, dir, "*.vlog")
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint64
+	for _, p := range names {
+		id, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(p), ".vlog"), 10, 64)
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// resolve 把值日志引用换成 value 本身，不是引用的记录原样返回。
+func (vs *versionSet) resolve(e types.Entry) (types.Entry, error) {
+	if !e.ValueRef {
+		return e, nil
+	}
+	if vs.vlog == nil {
+		return types.Entry{}, fmt.Errorf("%w: no value log for key %q", ErrCorruptValueLog, e.Key)
+	}
+	v, err := vs.vlog.read(e.Key, e.Value)
+	if err != nil {
+		return types.Entry{}, err
+	}
+	e.Value, e.ValueRef = v, false
+	return e, nil
+}
+
+// separateValues 把 entries 里不小于 Options.ValueLogThreshold 的 value 写进一个新的值日志文件，
+// 原地换成引用；没有开启值日志或者没有这样的 value 时什么也不做。调用方持有写锁。
+func (d *DB) separateValues(entries []types.Entry) error {
+	return d.moveValues(entries, nil)
+}
+
+// moveValues 把 entries 里需要放进值日志的 value 写进一个新文件，原地换成引用：
+// 不小于阈值的内联 value，以及引用了 rewrite 中的文件的 value（值日志 GC）。调用方持有写锁。
+func (d *DB) moveValues(entries []types.Entry, rewrite map[uint64]bool) error {
+	threshold := d.opts.ValueLogThreshold
+	move := func(e types.Entry) bool {
+		if e.Tombstone {
+			return false
+		}
+		if e.ValueRef {
+			ref, _ := sstable.DecodeValueRef(e.Value)
+			return rewrite[ref.File]
+		}
+		return threshold > 0 && len(e.Value) >= threshold
+	}
+
+	var w *vlogWriter
+	for i, e := range entries {
+		if !move(e) {
+			continue
+		}
+		if w == nil {
+			var err error
+			if w, err = d.versions.vlog.create(d.versions.newFileNumber()); err != nil {
+				return err
+			}
+		}
+		e, err := d.versions.resolve(e)
+		if err != nil {
+			w.abort()
+			return err
+		}
+		ref, err := w.add(e.Key, e.Value)
+		if err != nil {
+			w.abort()
+			return err
+		}
+		entries[i].Value, entries[i].ValueRef = ref.Encode(), true
+	}
+	if w == nil {
+		return nil
+	}
+	if err := w.finish(!d.opts.DisableFsync); err != nil {
+		_ = d.opts.fs().Remove(d.versions.vlog.path(w.id))
+		return err
+	}
+	return nil
+}
+
+// valueLogGCFiles 返回合并到最旧一层的 compaction 需要重写的值日志文件：out 是合并后的全部记录，
+// 文件里不再被它们引用的字节达到文件大小的 Options.ValueLogGCRatio。
+func (d *DB) valueLogGCFiles(out []types.Entry) (map[uint64]bool, error) {
+	live := make(map[uint64]uint64)
+	for _, e := range out {
+		if ref, ok := sstable.DecodeValueRef(e.Value); ok && e.ValueRef {
+			live[ref.File] += uint64(ref.Size)
+		}
+	}
+	rewrite := make(map[uint64]bool)
+	for id, n := range live {
+		st, err := d.opts.fs().Stat(d.versions.vlog.path(id))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptValueLog, err)
+		}
+		if d.opts.valueLogGarbage(st.Size(), n) {
+			rewrite[id] = true
+		}
+	}
+	return rewrite, nil
+}
+
+// valueLogGarbage 判断大小为 size、其中 live 字节有效的值日志文件是否需要 GC。
+func (o Options) valueLogGarbage(size int64, live uint64) bool {
+	ratio := o.ValueLogGCRatio
+	if ratio <= 0 {
+		ratio = DefaultValueLogGCRatio
+	}
+	return size > 0 && live < uint64(size) && float64(uint64(size)-live) >= ratio*float64(size)
+}
+
+// ValueLogFile 描述一个值日志文件。
+type ValueLogFile struct {
+	File uint64
+	Size int64 // 文件字节数
+
+	// Referenced 是当前所有 SST 引用这个文件的字节数之和，包括被更新的版本遮住、
+	// 还没有被 compaction 清理掉的旧引用，所以只是有效数据的上限。
+	Referenced uint64
+}
+
+// ValueLogFiles 返回所有值日志文件（按编号升序）。
+func (d *DB) ValueLogFiles() ([]ValueLogFile, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.valueLogFilesLocked()
+}
+
+func (d *DB) valueLogFilesLocked() ([]ValueLogFile, error) {
+	ids, err := listValueLogs(d.opts.fs(), d.versions.vlog.dir)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	refs, err := d.valueLogRefs()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]ValueLogFile, 0, len(ids))
+	for _, id := range ids {
+		st, err := d.opts.fs().Stat(d.versions.vlog.path(id))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, ValueLogFile{File: id, Size: st.Size(), Referenced: refs[id]})
+	}
+	return files, nil
+}
+
+// valueLogRefs 汇总当前所有 SST 的 properties.ValueLogBytes。任何一张表的 properties 读不出来时返回错误。
+func (d *DB) valueLogRefs() (map[uint64]uint64, error) {
+	refs := make(map[uint64]uint64)
+	for _, p := range d.versions.current().tables {
+		props, err := sstable.ReadPropertiesWithOptions(p, d.sstReadOptions(ReadOptions{}))
+		if err != nil {
+			return nil, err
+		}
+		for id, n := range props.ValueLogBytes {
+			refs[id] += n
+		}
+	}
+	return refs, nil
+}
+
+// removeObsoleteValueLogs 删除不再被当前任何 SST 引用的值日志文件。调用方持有写锁。
+// 有表的 properties 读不出来时无法确定哪些文件还在使用，什么也不删（留给读取时报错 / Repair 处理）。
+func (d *DB) removeObsoleteValueLogs() error {
+	// 后台正在锁外写不可变 MemTable 时，它新写的值日志文件还没有被任何表引用，留到下次再删
+	if d.immFlushing {
+		return nil
+	}
+	// 被 Snapshot 固定住的旧表可能还引用着当前的表都不再引用的文件，等它们删除之后再说
+	if d.versions.numObsolete() > 0 {
+		return nil
+	}
+	files, err := d.valueLogFilesLocked()
+	if err != nil {
+		if errors.Is(err, sstable.ErrCorruptSST) {
+			return nil
+		}
+		return err
+	}
+	for _, f := range files {
+		if f.Referenced == 0 {
+			if err := d.versions.persistFileNumber(); err != nil {
+				return err
+			}
+			if err := d.versions.vlog.remove(f.File); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ValueLogGC 回收值日志的空间：删除不再被任何 SST 引用的文件；估计的失效比例（见 ValueLogFile.Referenced）
+// 达到 Options.ValueLogGCRatio 的文件需要把仍然有效的 value 搬到新文件，这通过一次全量 Compact 完成——
+// 只有合并到最旧一层时才能确定哪些引用已经失效。返回是否做了 compaction。
+//
+// 被更新的版本遮住的旧引用在 compaction 之前依然计入 Referenced，所以 ValueLogGC 只会在 compaction
+// 之后才看到这部分垃圾；需要立即回收时直接调用 Compact。
+func (d *DB) ValueLogGC() (bool, error) {
+	if d.opts.ReadOnly {
+		return false, ErrReadOnly
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.removeObsoleteValueLogs(); err != nil {
+		return false, err
+	}
+	files, err := d.valueLogFilesLocked()
+	if err != nil {
+		return false, err
+	}
+	for _, f := range files {
+		if d.opts.valueLogGarbage(f.Size, f.Referenced) {
+			return true, d.compactTables(d.versions.current().tables)
+		}
+	}
+	return false, nil
+}
+
+// startValueLogGC 启动每隔 interval 调用一次 ValueLogGC 的后台 goroutine，Close 时停止。
+func (d *DB) startValueLogGC(interval time.Duration) {
+	d.vlogGCStop = make(chan struct{})
+	d.vlogGCDone = make(chan struct{})
+	go func() {
+		defer close(d.vlogGCDone)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-d.vlogGCStop:
+				return
+			case <-t.C:
+			}
+			if _, err := d.ValueLogGC(); err != nil {
+				// 下一个周期再试
+				log.Printf("forgedb: value log GC: %v", err)
+			}
+		}
+	}()
+}
+
+// stopValueLogGC 停止后台值日志 GC 并等待正在进行的一轮结束。
+func (d *DB) stopValueLogGC() {
+	if d.vlogGCStop == nil {
+		return
+	}
+	close(d.vlogGCStop)
+	<-d.vlogGCDone
+	d.vlogGCStop = nil
+}
@@ -0,0 +1,314 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"monolithdb/internal/encrypt"
+	"monolithdb/internal/sstable"
+	"monolithdb/internal/vfs"
+)
+
+func bigValue(key string, n int) []byte {
+	return bytes.Repeat([]byte(key), n/len(key)+1)[:n]
+}
+
+// countValueRefs 返回当前所有表中值日志引用的个数。
+func countValueRefs(t *testing.T, d *DB) int {
+	t.Helper()
+	n := 0
+	for _, p := range d.versions.current().tables {
+		entries, err := sstable.Range(p, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if e.ValueRef {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func checkValues(t *testing.T, d *DB, want map[string][]byte) {
+	t.Helper()
+	for k, v := range want {
+		got, ok, err := d.Get(k)
+		if err != nil || !ok || !bytes.Equal(got, v) {
+			t.Fatalf("Get(%q) = %d bytes, %v, %v; want %d bytes", k, len(got), ok, err, len(v))
+		}
+	}
+	entries, err := d.Range("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Range returned %d entries, want %d", len(entries), len(want))
+	}
+	for _, e := range entries {
+		if e.ValueRef || !bytes.Equal(e.Value, want[e.Key]) {
+			t.Fatalf("Range %q = %d bytes (ref=%v), want %d bytes", e.Key, len(e.Value), e.ValueRef, len(want[e.Key]))
+		}
+	}
+}
+
+func TestValueLogSeparatesLargeValues(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	opts := Options{DisableFsync: true, ValueLogThreshold: 100}
+	d, err := OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]byte{}
+	for i := range 20 {
+		k := fmt.Sprintf("key%02d", i)
+		want[k] = bigValue(k, 50+i*10) // 一半小于阈值
+		if err := d.Put(k, want[k]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countValueRefs(t, d); n != 15 {
+		t.Fatalf("%d value refs, want 15", n)
+	}
+	files, err := d.ValueLogFiles()
+	if err != nil || len(files) != 1 {
+		t.Fatalf("ValueLogFiles = %v, %v", files, err)
+	}
+	if files[0].Referenced != uint64(files[0].Size) {
+		t.Fatalf("file %+v is not fully referenced", files[0])
+	}
+	checkValues(t, d, want)
+
+	// Touch 读取旧值后重写过期时间，value 依然完整
+	if n, err := d.Touch([]string{"key19"}, time.Hour); err != nil || n != 1 {
+		t.Fatalf("Touch = %d, %v", n, err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	checkValues(t, d, want)
+
+	// 关闭值日志之后已经分离的 value 依然可读，compaction 保留引用
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d, err = OpenWithOptions(dir, Options{DisableFsync: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countValueRefs(t, d); n != 15 {
+		t.Fatalf("%d value refs after compaction, want 15", n)
+	}
+	checkValues(t, d, want)
+}
+
+func TestValueLogGCRewritesMostlyDeadFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, ValueLogThreshold: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	want := map[string][]byte{}
+	put := func(k string, v []byte) {
+		t.Helper()
+		want[k] = v
+		if err := d.Put(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range 10 {
+		put(fmt.Sprintf("k%d", i), bigValue("old", 1000))
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	first, err := d.ValueLogFiles()
+	if err != nil || len(first) != 1 {
+		t.Fatalf("ValueLogFiles = %v, %v", first, err)
+	}
+
+	// 覆盖 8 个 key：第一个文件里只剩 2 个有效的 value，失效比例 80%
+	for i := range 8 {
+		put(fmt.Sprintf("k%d", i), bigValue("new", 1000))
+	}
+	if err := d.Delete("k9"); err != nil {
+		t.Fatal(err)
+	}
+	delete(want, "k9")
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 旧引用还在更旧的表里，估计值看不到垃圾
+	if compacted, err := d.ValueLogGC(); err != nil || compacted {
+		t.Fatalf("ValueLogGC = %v, %v before compaction", compacted, err)
+	}
+
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	files, err := d.ValueLogFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if f.File == first[0].File {
+			t.Fatalf("value log %d should have been rewritten: %+v", f.File, files)
+		}
+		if f.Referenced != uint64(f.Size) {
+			t.Fatalf("file %+v is not fully referenced after GC", f)
+		}
+	}
+	if len(files) != 2 {
+		t.Fatalf("ValueLogFiles = %+v, want the second flush and the GC output", files)
+	}
+	checkValues(t, d, want)
+
+	// 删除所有 key 之后，compaction 删除全部值日志文件
+	for k := range want {
+		if err := d.Delete(k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := listValueLogs(vfs.Default, filepath.Join(dir, vlogDirName)); err != nil || len(ids) != 0 {
+		t.Fatalf("value logs left after deleting everything: %v, %v", ids, err)
+	}
+}
+
+func TestValueLogCompactionFilterSeesValue(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	var seen []byte
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, ValueLogThreshold: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.SetCompactionFilter(CompactionFilterFunc(func(key string, value []byte) (FilterDecision, []byte) {
+		seen = value
+		return FilterKeep, nil
+	}))
+
+	v := bigValue("value", 500)
+	if err := d.Put("k", v); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(seen, v) {
+		t.Fatalf("filter saw %d bytes, want the %d byte value", len(seen), len(v))
+	}
+	checkValues(t, d, map[string][]byte{"k": v})
+}
+
+func TestValueLogCheckpointAndOrphans(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	opts := Options{DisableFsync: true, ValueLogThreshold: 10}
+	d, err := OpenWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]byte{"a": bigValue("a", 300), "b": bigValue("b", 300)}
+	for k, v := range want {
+		if err := d.Put(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cp := filepath.Join(t.TempDir(), "cp")
+	if _, err := d.Checkpoint(cp); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 崩溃时残留的、没有被任何表引用的值日志文件在打开时删除，编号也不会被复用
+	orphan := filepath.Join(cp, vlogDirName, "999999.vlog")
+	if err := os.WriteFile(orphan, []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := OpenWithOptions(cp, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := os.Stat(orphan); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("orphan value log not removed: %v", err)
+	}
+	if id := c.versions.newFileNumber(); id <= 999999 {
+		t.Fatalf("next file number %d reuses the orphan's number", id)
+	}
+	checkValues(t, c, want)
+}
+
+func TestValueLogDetectsCorruption(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := OpenWithOptions(dir, Options{DisableFsync: true, ValueLogThreshold: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Put("k", bigValue("v", 100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	files, err := d.ValueLogFiles()
+	if err != nil || len(files) != 1 {
+		t.Fatalf("ValueLogFiles = %v, %v", files, err)
+	}
+	path := d.versions.vlog.path(files[0].File)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[len(b)-1] ^= 0xff
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d.versions.vlog.close()
+	if _, _, err := d.Get("k"); !errors.Is(err, ErrCorruptValueLog) {
+		t.Fatalf("Get = %v, want ErrCorruptValueLog", err)
+	}
+}
+
+func TestValueLogRejectsEncryption(t *testing.T) {
+	keys, err := encrypt.NewStaticKeys("k1", bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = OpenWithOptions(t.TempDir(), Options{ValueLogThreshold: 10, Encryption: keys})
+	if !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("Open = %v, want ErrInvalidOptions", err)
+	}
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"strings"
+
+	"monolithdb/internal/wal"
+)
+
+// watchBuffer 是每个 watcher 缓冲的未读通知数，超过后 watcher 被断开（见 ErrWatchLagged）。
+const watchBuffer = 256
+
+// ErrWatchLagged 表示 watcher 消费太慢、缓冲区已满而被断开。
+// 调用方可以用最后收到的 Change.Seq 调用 Changes 补齐，再重新 Watch。
+var ErrWatchLagged = errors.New("db: watcher fell behind")
+
+type watcher struct {
+	prefix string
+	ch     chan Change
+
+	// lagged 在关闭 ch 之前（写锁内）设置
+	lagged bool
+}
+
+// Watch 返回 key 以 prefix 开头的变更通知，prefix 为空表示所有 key。
+// 只包含开始迭代之后提交的变更；通知在变更提交后发出，此时 Get 已经能读到新值。
+//
+// 迭代在 ctx 取消（产出 ctx.Err()）、调用方 break、DB 关闭（正常结束）
+// 或消费过慢（产出 ErrWatchLagged）时结束。
+func (d *DB) Watch(ctx context.Context, prefix string) iter.Seq2[Change, error] {
+	return func(yield func(Change, error) bool) {
+		w := &watcher{prefix: d.normKey(prefix), ch: make(chan Change, watchBuffer)}
+		d.mu.Lock()
+		if d.watchers == nil {
+			d.watchers = make(map[*watcher]struct{})
+		}
+		d.watchers[w] = struct{}{}
+		d.mu.Unlock()
+		defer d.unwatch(w)
+
+		for {
+			select {
+			case <-ctx.Done():
+				yield(Change{}, ctx.Err())
+				return
+			case c, ok := <-w.ch:
+				if !ok {
+					if w.lagged {
+						yield(Change{}, ErrWatchLagged)
+					}
+					return
+				}
+				if !yield(c, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (d *DB) unwatch(w *watcher) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.watchers[w]; ok {
+		delete(d.watchers, w)
+		close(w.ch)
+	}
+}
+
+// closeWatchers 在 Close 时（写锁内）结束所有 watcher。
+func (d *DB) closeWatchers() {
+	for w := range d.watchers {
+		delete(d.watchers, w)
+		close(w.ch)
+	}
+}
+
+// notifyWatchers 在 commit 中（写锁内）把记录分发给匹配的 watcher。
+// 不会阻塞：缓冲区满的 watcher 直接断开。
+func (d *DB) notifyWatchers(seq uint64, r wal.Record) {
+	if len(d.watchers) == 0 {
+		return
+	}
+	changes := expandRecord(seq, r)
+	for w := range d.watchers {
+		for _, c := range changes {
+			if !strings.HasPrefix(c.Key, w.prefix) {
+				continue
+			}
+			select {
+			case w.ch <- c:
+				continue
+			default:
+			}
+			w.lagged = true
+			delete(d.watchers, w)
+			close(w.ch)
+			break
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startWatch 在后台消费 Watch，并等到 watcher 注册完成。
+func startWatch(t *testing.T, d *DB, ctx context.Context, prefix string) (<-chan string, <-chan error) {
+	t.Helper()
+	before := d.watcherCount()
+	out, done := make(chan string, 1024), make(chan error, 1)
+	go func() {
+		defer close(out)
+		for c, err := range d.Watch(ctx, prefix) {
+			if err != nil {
+				done <- err
+				return
+			}
+			out <- fmt.Sprintf("%d:%s:%s:%s", c.Seq, c.Op, c.Key, c.Value)
+		}
+		done <- nil
+	}()
+	for d.watcherCount() == before {
+		time.Sleep(time.Millisecond)
+	}
+	return out, done
+}
+
+func (d *DB) watcherCount() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.watchers)
+}
+
+func TestWatchPrefix(t *testing.T) {
+	d, err := Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out, done := startWatch(t, d, ctx, "cfg/")
+
+	_ = d.Put("cfg/a", []byte("1"))
+	_ = d.Put("other", []byte("x"))
+	var b Batch
+	b.Put("cfg/b", []byte("2"))
+	b.Put("zzz", []byte("3"))
+	_ = d.Write(&b)
+	_ = d.Delete("cfg/a")
+
+	want := []string{"1:put:cfg/a:1", "3:put:cfg/b:2", "4:delete:cfg/a:"}
+	for _, w := range want {
+		select {
+		case got := <-out:
+			if got != w {
+				t.Fatalf("got %s, want %s", got, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %s", w)
+		}
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v", err)
+	}
+	if n := d.watcherCount(); n != 0 {
+		t.Fatalf("watchers after cancel = %d", n)
+	}
+}
+
+func TestWatchLaggedAndClose(t *testing.T) {
+	d, err := Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 不消费：缓冲区写满后 watcher 被断开
+	ctx := context.Background()
+	lagged := make(chan error, 1)
+	seq := d.Watch(ctx, "")
+	started := make(chan struct{})
+	go func() {
+		for _, err := range seq {
+			if err != nil {
+				lagged <- err
+				return
+			}
+			<-started // 第一条之后阻塞，直到写满
+		}
+		lagged <- nil
+	}()
+	for d.watcherCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < watchBuffer+2; i++ {
+		_ = d.Put(fmt.Sprintf("k%d", i), nil)
+	}
+	close(started)
+	if err := <-lagged; !errors.Is(err, ErrWatchLagged) {
+		t.Fatalf("err = %v", err)
+	}
+
+	// Close 会正常结束其余 watcher
+	_, done := startWatch(t, d, ctx, "")
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("err after close = %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+// Package encrypt 实现静态数据加密（encryption at rest）：SST 整个文件按块用 AES-GCM 加密，
+// WAL 逐条记录加密。密钥由 KeyProvider 提供，每个密文都带着密钥 ID，
+// 所以轮换密钥之后旧文件依然可以用旧密钥解密，compaction 重写时换成新密钥。
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrUnknownKey 表示 KeyProvider 里没有密文所用的密钥 ID。
+	ErrUnknownKey = errors.New("encrypt: unknown key id")
+	// ErrDecrypt 表示密文无法解密：密钥不对，或者数据被篡改 / 损坏 / 截断。
+	ErrDecrypt = errors.New("encrypt: decryption failed")
+	// ErrNoKeyProvider 表示读到了加密数据，但没有配置 KeyProvider。
+	ErrNoKeyProvider = errors.New("encrypt: data is encrypted but no key provider is configured")
+)
+
+// KeyProvider 提供加密密钥（AES-128/192/256，即 16/24/32 字节）。实现必须并发安全。
+type KeyProvider interface {
+	// ActiveKey 返回写新数据使用的密钥及其 ID。
+	ActiveKey() (id string, key []byte, err error)
+	// Key 按 ID 返回密钥，用于解密；不认识的 ID 返回 ErrUnknownKey。
+	Key(id string) ([]byte, error)
+}
+
+// maxKeyIDLen 是密钥 ID 的最大长度（密文里用 1 字节记录长度）。
+const maxKeyIDLen = 255
+
+// StaticKeys 是保存在内存里的 KeyProvider：一组按 ID 索引的密钥和其中一个活跃密钥。
+// Rotate 换上新的活跃密钥，旧密钥保留用于解密，直到所有用它加密的文件都被重写后再 Remove。
+type StaticKeys struct {
+	mu     sync.RWMutex
+	active string
+	keys   map[string][]byte
+}
+
+// NewStaticKeys 返回以 (id, key) 为活跃密钥的 StaticKeys。
+func NewStaticKeys(id string, key []byte) (*StaticKeys, error) {
+	k := &StaticKeys{keys: make(map[string][]byte)}
+	if err := k.Rotate(id, key); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Rotate 添加密钥 (id, key) 并把它设为活跃密钥。之后新写的数据都用它加密。
+func (k *StaticKeys) Rotate(id string, key []byte) error {
+	if err := checkKey(id, key); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if old, ok := k.keys[id]; ok && string(old) != string(key) {
+		return fmt.Errorf("encrypt: key id %q already has a different key", id)
+	}
+	k.keys[id] = append([]byte(nil), key...)
+	k.active = id
+	return nil
+}
+
+// Remove 删除一个不再使用的旧密钥。不能删除活跃密钥。
+func (k *StaticKeys) Remove(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if id == k.active {
+		return fmt.Errorf("encrypt: cannot remove the active key %q", id)
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+func (k *StaticKeys) ActiveKey() (string, []byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active, k.keys[k.active], nil
+}
+
+func (k *StaticKeys) Key(id string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+func checkKey(id string, key []byte) error {
+	if id == "" || len(id) > maxKeyIDLen {
+		return fmt.Errorf("encrypt: key id must be 1..%d bytes", maxKeyIDLen)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	}
+	return fmt.Errorf("encrypt: key %q must be 16, 24 or 32 bytes, got %d", id, len(key))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func activeAEAD(kp KeyProvider) (string, cipher.AEAD, error) {
+	id, key, err := kp.ActiveKey()
+	if err != nil {
+		return "", nil, err
+	}
+	if err := checkKey(id, key); err != nil {
+		return "", nil, err
+	}
+	aead, err := newGCM(key)
+	return id, aead, err
+}
+
+func aeadFor(kp KeyProvider, id string) (cipher.AEAD, error) {
+	if kp == nil {
+		return nil, ErrNoKeyProvider
+	}
+	key, err := kp.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	return newGCM(key)
+}
+
+// Seal 用活跃密钥加密一段独立的数据（例如一条 WAL 记录），aad 参与认证但不加密。
+// 格式：| idLen(1B) | id | nonce(12B) | ciphertext+tag |
+func Seal(kp KeyProvider, plaintext, aad []byte) ([]byte, error) {
+	id, aead, err := activeAEAD(kp)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, 1+len(id)+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	out = append(out, byte(len(id)))
+	out = append(out, id...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, aad), nil
+}
+
+// Open 解密 Seal 的输出。
+func Open(kp KeyProvider, sealed, aad []byte) ([]byte, error) {
+	id, rest, ok := splitKeyID(sealed)
+	if !ok {
+		return nil, ErrDecrypt
+	}
+	aead, err := aeadFor(kp, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+	nonce, ct := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	pt, err := aead.Open(nil, nonce, ct, aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return pt, nil
+}
+
+// SealedKeyID 返回 Seal 输出所用的密钥 ID。
+func SealedKeyID(sealed []byte) (string, bool) {
+	id, _, ok := splitKeyID(sealed)
+	return id, ok
+}
+
+func splitKeyID(b []byte) (id string, rest []byte, ok bool) {
+	if len(b) < 1 || len(b) < 1+int(b[0]) || b[0] == 0 {
+		return "", nil, false
+	}
+	n := int(b[0])
+	return string(b[1 : 1+n]), b[1+n:], true
+}
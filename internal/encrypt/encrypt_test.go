@@ -0,0 +1,135 @@
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func testKey(b byte) []byte { return bytes.Repeat([]byte{b}, 32) }
+
+func TestSealRotation(t *testing.T) {
+	keys, err := NewStaticKeys("k1", testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := Seal(keys, []byte("hello"), []byte("aad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(old, []byte("hello")) {
+		t.Fatal("plaintext visible in sealed output")
+	}
+	if _, err := Open(keys, old, []byte("other")); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("wrong aad: err = %v", err)
+	}
+
+	if err := keys.Rotate("k2", testKey(2)); err != nil {
+		t.Fatal(err)
+	}
+	cur, err := Seal(keys, []byte("world"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := SealedKeyID(cur); id != "k2" {
+		t.Fatalf("new data sealed with %q, want k2", id)
+	}
+	// 旧密钥依然可以解密轮换之前的数据
+	if pt, err := Open(keys, old, []byte("aad")); err != nil || string(pt) != "hello" {
+		t.Fatalf("Open(old) = %q, %v", pt, err)
+	}
+
+	if err := keys.Remove("k2"); err == nil {
+		t.Fatal("removing the active key should fail")
+	}
+	if err := keys.Remove("k1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(keys, old, []byte("aad")); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("removed key: err = %v", err)
+	}
+	if _, err := Open(nil, cur, nil); !errors.Is(err, ErrNoKeyProvider) {
+		t.Fatalf("nil provider: err = %v", err)
+	}
+	if _, err := NewStaticKeys("bad", []byte("short")); err == nil {
+		t.Fatal("short key should be rejected")
+	}
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	keys, err := NewStaticKeys("k1", testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 覆盖空文件、恰好整块、跨块几种情况
+	for _, n := range []int{0, 10, chunkSize, 3*chunkSize + 123} {
+		plain := make([]byte, n)
+		for i := range plain {
+			plain[i] = byte(i * 7)
+		}
+		enc, err := Encrypt(keys, plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := bytes.NewReader(enc)
+		if !IsEncrypted(r) || IsEncrypted(bytes.NewReader(plain)) {
+			t.Fatalf("n=%d: IsEncrypted mismatch", n)
+		}
+		fr, err := NewReader(r, int64(len(enc)), keys)
+		if err != nil {
+			t.Fatalf("n=%d: %v", n, err)
+		}
+		if fr.Size() != int64(n) || fr.KeyID() != "k1" {
+			t.Fatalf("n=%d: size=%d key=%q", n, fr.Size(), fr.KeyID())
+		}
+		got, err := io.ReadAll(io.NewSectionReader(fr, 0, fr.Size()))
+		if err != nil || !bytes.Equal(got, plain) {
+			t.Fatalf("n=%d: round trip mismatch (err=%v)", n, err)
+		}
+		if n > chunkSize {
+			// 跨块的随机读
+			buf := make([]byte, 100)
+			off := int64(chunkSize - 50)
+			if _, err := fr.ReadAt(buf, off); err != nil || !bytes.Equal(buf, plain[off:off+100]) {
+				t.Fatalf("n=%d: ReadAt across chunks failed: %v", n, err)
+			}
+		}
+	}
+}
+
+func TestFileDetectsTamperingAndTruncation(t *testing.T) {
+	keys, err := NewStaticKeys("k1", testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := bytes.Repeat([]byte("0123456789"), chunkSize/5) // 两块
+	enc, err := Encrypt(keys, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 截掉最后一整块：剩下的块没有结束标记
+	hdr := len(enc) - 2*(chunkSize+16)
+	truncated := enc[:hdr+chunkSize+16]
+	if _, err := NewReader(bytes.NewReader(truncated), int64(len(truncated)), keys); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("truncated file: err = %v", err)
+	}
+
+	tampered := bytes.Clone(enc)
+	tampered[hdr+10] ^= 1
+	fr, err := NewReader(bytes.NewReader(tampered), int64(len(tampered)), keys)
+	if err != nil {
+		t.Fatal(err) // 只有第一块被改，打开时只校验最后一块
+	}
+	if _, err := fr.ReadAt(make([]byte, 10), 0); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("tampered chunk: err = %v", err)
+	}
+
+	if _, err := NewReader(bytes.NewReader(enc), int64(len(enc)), nil); !errors.Is(err, ErrNoKeyProvider) {
+		t.Fatalf("nil provider: err = %v", err)
+	}
+	if id, err := FileKeyID(bytes.NewReader(enc)); err != nil || id != "k1" {
+		t.Fatalf("FileKeyID = %q, %v", id, err)
+	}
+}
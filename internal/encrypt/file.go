@@ -0,0 +1,244 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"sync"
+)
+
+// 加密文件格式：
+//
+//	| magic(8B) | idLen(1B) | keyID | salt(8B) | chunk 0 | chunk 1 | ... | chunk n-1 |
+//
+// 明文按 chunkSize 切块，每块单独用 AES-GCM 加密（密文比明文多一个 16 字节的 tag），
+// nonce = salt || 块序号(uint32)，附加数据标记是否为最后一块：
+// 块被调换顺序、文件被截断或拼接都会解密失败。最后一块可以不满，至少有一块。
+const (
+	fileMagic = "FDBENC01"
+	chunkSize = 16 << 10
+	saltSize  = 8
+)
+
+// IsEncrypted 报告 r 开头是否是加密文件的 magic。
+func IsEncrypted(r io.ReaderAt) bool {
+	var b [len(fileMagic)]byte
+	if _, err := r.ReadAt(b[:], 0); err != nil {
+		return false
+	}
+	return string(b[:]) == fileMagic
+}
+
+// FileKeyID 返回加密文件使用的密钥 ID。
+func FileKeyID(r io.ReaderAt) (string, error) {
+	id, _, _, err := readFileHeader(r)
+	return id, err
+}
+
+func readFileHeader(r io.ReaderAt) (id string, salt []byte, hdrLen int64, err error) {
+	var b [len(fileMagic) + 1]byte
+	if _, err := r.ReadAt(b[:], 0); err != nil || string(b[:len(fileMagic)]) != fileMagic || b[len(fileMagic)] == 0 {
+		return "", nil, 0, ErrDecrypt
+	}
+	n := int(b[len(fileMagic)])
+	rest := make([]byte, n+saltSize)
+	if _, err := r.ReadAt(rest, int64(len(b))); err != nil {
+		return "", nil, 0, ErrDecrypt
+	}
+	return string(rest[:n]), rest[n:], int64(len(b) + n + saltSize), nil
+}
+
+func chunkNonce(aead cipher.AEAD, salt []byte, idx uint32) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	copy(nonce, salt)
+	binary.BigEndian.PutUint32(nonce[len(nonce)-4:], idx)
+	return nonce
+}
+
+func chunkAAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// Writer 把写入的明文加密后写到底层 io.Writer。必须调用 Close 写出最后一块。
+type Writer struct {
+	w    io.Writer
+	aead cipher.AEAD
+	salt []byte
+	idx  uint32
+	buf  []byte
+	out  []byte
+}
+
+// NewWriter 用 kp 的活跃密钥创建加密写入器，立即写出文件头。
+func NewWriter(w io.Writer, kp KeyProvider) (*Writer, error) {
+	id, aead, err := activeAEAD(kp)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	hdr := append([]byte(fileMagic), byte(len(id)))
+	hdr = append(hdr, id...)
+	hdr = append(hdr, salt...)
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead, salt: salt, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+// Write 缓冲明文，攒满一块并且后面还有数据时才加密写出（最后一块要等 Close 时带上结束标记）。
+func (w *Writer) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if len(w.buf) == chunkSize {
+			if err := w.flushChunk(false); err != nil {
+				return n - len(p), err
+			}
+		}
+		m := min(chunkSize-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:m]...)
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// Close 写出最后一块。不会关闭底层 io.Writer。
+func (w *Writer) Close() error {
+	return w.flushChunk(true)
+}
+
+func (w *Writer) flushChunk(final bool) error {
+	w.out = w.aead.Seal(w.out[:0], chunkNonce(w.aead, w.salt, w.idx), w.buf, chunkAAD(final))
+	if _, err := w.w.Write(w.out); err != nil {
+		return err
+	}
+	w.idx++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Reader 提供加密文件的随机读（明文偏移），并发安全。
+type Reader struct {
+	r      io.ReaderAt
+	aead   cipher.AEAD
+	keyID  string
+	salt   []byte
+	hdrLen int64
+	chunks int64
+	size   int64 // 明文大小
+
+	mu       sync.Mutex
+	cacheIdx int64
+	cache    []byte
+}
+
+// NewReader 打开一个加密文件（密文总大小为 size）。会先校验最后一块，截断的文件在这里就会报错。
+func NewReader(r io.ReaderAt, size int64, kp KeyProvider) (*Reader, error) {
+	id, salt, hdrLen, err := readFileHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := aeadFor(kp, id)
+	if err != nil {
+		return nil, err
+	}
+	enc := int64(chunkSize + aead.Overhead())
+	body := size - hdrLen
+	if body < int64(aead.Overhead()) {
+		return nil, ErrDecrypt
+	}
+	chunks := (body + enc - 1) / enc
+	last := body - (chunks-1)*enc
+	if last < int64(aead.Overhead()) {
+		return nil, ErrDecrypt
+	}
+	rd := &Reader{
+		r: r, aead: aead, keyID: id, salt: salt, hdrLen: hdrLen, chunks: chunks,
+		size:     body - chunks*int64(aead.Overhead()),
+		cacheIdx: -1,
+	}
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	if _, err := rd.chunk(chunks - 1); err != nil {
+		return nil, err
+	}
+	return rd, nil
+}
+
+// Size 返回明文大小。
+func (r *Reader) Size() int64 { return r.size }
+
+// KeyID 返回文件使用的密钥 ID。
+func (r *Reader) KeyID() string { return r.keyID }
+
+// ReadAt 实现 io.ReaderAt（明文偏移）。
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrDecrypt
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for n < len(p) {
+		if off >= r.size {
+			return n, io.EOF
+		}
+		pt, err := r.chunk(off / chunkSize)
+		if err != nil {
+			return n, err
+		}
+		m := copy(p[n:], pt[off%chunkSize:])
+		n += m
+		off += int64(m)
+	}
+	return n, nil
+}
+
+// chunk 返回第 idx 块的明文（缓存最近一块）。调用方持有 mu。
+func (r *Reader) chunk(idx int64) ([]byte, error) {
+	if idx == r.cacheIdx {
+		return r.cache, nil
+	}
+	enc := int64(chunkSize + r.aead.Overhead())
+	start := r.hdrLen + idx*enc
+	n := enc
+	final := idx == r.chunks-1
+	if final {
+		n = r.hdrLen + r.size + r.chunks*int64(r.aead.Overhead()) - start
+	}
+	ct := make([]byte, n)
+	if _, err := r.r.ReadAt(ct, start); err != nil {
+		return nil, ErrDecrypt
+	}
+	pt, err := r.aead.Open(r.cache[:0], chunkNonce(r.aead, r.salt, uint32(idx)), ct, chunkAAD(final))
+	if err != nil {
+		r.cacheIdx = -1
+		return nil, ErrDecrypt
+	}
+	r.cache, r.cacheIdx = pt, idx
+	return pt, nil
+}
+
+// Encrypt 是一次性加密整段数据的便捷函数（测试和小文件使用）。
+func Encrypt(kp KeyProvider, plaintext []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, kp)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
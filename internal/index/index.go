@@ -0,0 +1,327 @@
+// Package index 在 ForgeDB 上维护二级索引：由记录派生出的 索引值 -> 主键 映射，
+// 例如按 user 记录里的 email 字段找到 user 的主键。
+//
+// 数据布局（<name> 是索引名）：
+//
+//	<key>                                   -> 主记录，和直接写 db.DB 时一样
+//	\x00index/<name>/<value>\x00<key>       -> 空 value（一条索引项）
+//	\x00index/<name>                        -> 空 value，表示索引已经回填过
+//
+// 以 "\x00index/" 开头的 key 保留给索引使用。与 invindex 一样，每个 (value, key) 一个 key，
+// 查询时用范围扫描取出某个值的所有主键；主记录和它的索引项在同一个 db.Batch 里写入，
+// 崩溃后不会出现主记录和索引不一致。
+//
+// 只有通过 DB 写入的记录会更新索引；绕过它直接写 db.DB 之后需要调用 Backfill 重建。
+package index
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"monolithdb/internal/db"
+)
+
+// ReservedPrefix 是索引项使用的 key 前缀，主记录的 key 不能以它开头。
+const ReservedPrefix = "\x00index/"
+
+var (
+	// ErrUnknownIndex 表示没有用 AddIndex 注册这个名字的索引。
+	ErrUnknownIndex = errors.New("index: unknown index")
+
+	// ErrIndexExists 表示同名的索引已经注册过。
+	ErrIndexExists = errors.New("index: index already exists")
+
+	// ErrInvalidName 表示索引名为空或包含 '/'。
+	ErrInvalidName = errors.New("index: invalid index name")
+
+	// ErrReservedKey 表示主记录的 key 以 ReservedPrefix 开头。
+	ErrReservedKey = errors.New("index: key uses the reserved index prefix")
+
+	// ErrInvalidValue 表示 Extractor 返回的索引值包含 0 字节（0 字节在索引项的 key 中用作分隔符）。
+	ErrInvalidValue = errors.New("index: index value contains a zero byte")
+)
+
+// Extractor 从一条主记录中取出它的索引值，可以返回多个（多值字段）或者没有（记录不进入索引）。
+// 同一条记录必须总是得到同样的结果：更新和删除时用它重新计算旧记录的索引值来删除旧索引项。
+type Extractor func(key string, value []byte) []string
+
+// DB 包装一个 *db.DB：通过它写入的记录会原子地更新所有注册的索引。
+// 读主记录可以直接用底层的 db.DB。同一个 DB 上的写操作是串行的。
+type DB struct {
+	d *db.DB
+
+	// mu 串行化写入和回填：更新记录需要先读旧值再删除旧索引项
+	mu      sync.Mutex
+	indexes map[string]Extractor
+}
+
+// New 返回包装 d 的 DB，还没有注册任何索引。
+func New(d *db.DB) *DB {
+	return &DB{d: d, indexes: make(map[string]Extractor)}
+}
+
+func indexPrefix(name string) string { return ReservedPrefix + name + "/" }
+
+func entryPrefix(name, value string) string { return indexPrefix(name) + value + "\x00" }
+
+func builtKey(name string) string { return ReservedPrefix + name }
+
+// AddIndex 注册名为 name 的索引。索引的定义（Extractor）不保存在数据库里，每次打开之后都要重新注册；
+// 第一次注册时（数据库里还没有回填标记）先用 Backfill 为已有的记录建立索引项。
+func (x *DB) AddIndex(name string, extract Extractor) error {
+	if name == "" || strings.IndexByte(name, '/') >= 0 {
+		return ErrInvalidName
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, ok := x.indexes[name]; ok {
+		return fmt.Errorf("%w: %q", ErrIndexExists, name)
+	}
+	_, built, err := x.d.Get(builtKey(name))
+	if err != nil {
+		return err
+	}
+	if !built {
+		if err := x.backfill(name, extract); err != nil {
+			return err
+		}
+	}
+	x.indexes[name] = extract
+	return nil
+}
+
+// Backfill 删除索引 name 的所有索引项，再扫描全部主记录重新建立，用于 Extractor 改变之后，
+// 或者有记录绕过 DB 直接写入之后。回填期间通过 DB 的写入会等待；
+// 索引项分多个批次写入，回填的中途查询可能看到不完整的结果。
+func (x *DB) Backfill(name string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	extract, ok := x.indexes[name]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownIndex, name)
+	}
+	return x.backfill(name, extract)
+}
+
+// backfillBatchOps 是回填时一个批次最多包含的操作数。
+const backfillBatchOps = 1024
+
+func (x *DB) backfill(name string, extract Extractor) error {
+	var b db.Batch
+	flush := func(force bool) error {
+		if b.Len() == 0 || (!force && b.Len() < backfillBatchOps) {
+			return nil
+		}
+		err := x.d.Write(&b)
+		b.Reset()
+		return err
+	}
+
+	p := indexPrefix(name)
+	for chunk, err := range x.d.RangeChunks(p, prefixEnd(p), 0) {
+		if err != nil {
+			return err
+		}
+		for _, e := range chunk {
+			b.Delete(e.Key)
+			if err := flush(false); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 主记录在保留前缀的两侧
+	for _, r := range [][2]string{{"", ReservedPrefix}, {prefixEnd(ReservedPrefix), ""}} {
+		for chunk, err := range x.d.RangeChunks(r[0], r[1], 0) {
+			if err != nil {
+				return err
+			}
+			for _, e := range chunk {
+				values, err := indexValues(extract, e.Key, e.Value)
+				if err != nil {
+					return err
+				}
+				for _, v := range values {
+					b.Put(entryPrefix(name, v)+e.Key, nil)
+					if err := flush(false); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	b.Put(builtKey(name), nil)
+	return flush(true)
+}
+
+// prefixEnd 返回以 p 开头的 key 的上界（不含）。p 的最后一个字节小于 0xff。
+func prefixEnd(p string) string {
+	return p[:len(p)-1] + string(p[len(p)-1]+1)
+}
+
+// indexValues 调用 extract 并去重，检查索引值不含 0 字节。
+func indexValues(extract Extractor, key string, value []byte) ([]string, error) {
+	values := extract(key, value)
+	seen := make(map[string]bool, len(values))
+	out := values[:0:0]
+	for _, v := range values {
+		if strings.IndexByte(v, 0) >= 0 {
+			return nil, fmt.Errorf("%w: index value %q of key %q", ErrInvalidValue, v, key)
+		}
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// Batch 收集一组写操作，由 DB.Write 和它们的索引项一起原子地提交。
+type Batch struct {
+	ops []op
+}
+
+type op struct {
+	key    string
+	value  []byte
+	delete bool
+}
+
+// Put 向批次追加一次写入。
+func (b *Batch) Put(key string, value []byte) {
+	b.ops = append(b.ops, op{key: key, value: value})
+}
+
+// Delete 向批次追加一次删除。
+func (b *Batch) Delete(key string) {
+	b.ops = append(b.ops, op{key: key, delete: true})
+}
+
+// Len 返回批次中的操作数。
+func (b *Batch) Len() int { return len(b.ops) }
+
+// Put 写入（或覆盖）主记录 key，并原子地更新所有索引。
+func (x *DB) Put(key string, value []byte) error {
+	var b Batch
+	b.Put(key, value)
+	return x.Write(&b)
+}
+
+// Delete 删除主记录 key 及其索引项；记录不存在时只写一个 tombstone。
+func (x *DB) Delete(key string) error {
+	var b Batch
+	b.Delete(key)
+	return x.Write(&b)
+}
+
+// Write 原子地提交批次中的所有操作和它们引起的索引变化；同一个 key 出现多次时后面的操作生效。
+func (x *DB) Write(b *Batch) error {
+	for _, o := range b.ops {
+		if strings.HasPrefix(o.key, ReservedPrefix) {
+			return fmt.Errorf("%w: %q", ErrReservedKey, o.key)
+		}
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	// cur 是批次执行到当前位置时每个 key 的值，同一个 key 的后续操作在它的基础上更新索引
+	cur := make(map[string]record)
+	var out db.Batch
+	for _, o := range b.ops {
+		old, seen := cur[o.key]
+		if !seen {
+			v, ok, err := x.d.Get(o.key)
+			if err != nil {
+				return err
+			}
+			old = record{v, ok}
+		}
+		next := record{o.value, !o.delete}
+		for name, extract := range x.indexes {
+			if err := updateEntries(&out, name, extract, o.key, old, next); err != nil {
+				return err
+			}
+		}
+		if o.delete {
+			out.Delete(o.key)
+		} else {
+			out.Put(o.key, o.value)
+		}
+		cur[o.key] = next
+	}
+	return x.d.Write(&out)
+}
+
+// record 是一个主记录的值，ok 为 false 表示记录不存在。
+type record struct {
+	value []byte
+	ok    bool
+}
+
+// updateEntries 把记录 key 从 old 变成 next 引起的索引项变化加入 out：删除只属于旧值的索引项，写入新值的索引项。
+func updateEntries(out *db.Batch, name string, extract Extractor, key string, old, next record) error {
+	var oldValues, newValues []string
+	var err error
+	if old.ok {
+		if oldValues, err = indexValues(extract, key, old.value); err != nil {
+			return err
+		}
+	}
+	if next.ok {
+		if newValues, err = indexValues(extract, key, next.value); err != nil {
+			return err
+		}
+	}
+	keep := make(map[string]bool, len(newValues))
+	for _, v := range newValues {
+		keep[v] = true
+		out.Put(entryPrefix(name, v)+key, nil)
+	}
+	for _, v := range oldValues {
+		if !keep[v] {
+			out.Delete(entryPrefix(name, v) + key)
+		}
+	}
+	return nil
+}
+
+// QueryIndex 返回索引 name 中值为 value 的记录的主键（升序）。
+func (x *DB) QueryIndex(name, value string) ([]string, error) {
+	x.mu.Lock()
+	_, ok := x.indexes[name]
+	x.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownIndex, name)
+	}
+	if strings.IndexByte(value, 0) >= 0 {
+		return nil, nil
+	}
+
+	p := entryPrefix(name, value)
+	entries, err := x.d.Range(p, prefixEnd(p))
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i] = e.Key[len(p):]
+	}
+	return keys, nil
+}
+
+// Indexes 返回已注册的索引名（升序）。
+func (x *DB) Indexes() []string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	names := make([]string, 0, len(x.indexes))
+	for name := range x.indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,142 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"monolithdb/internal/db"
+)
+
+// byCity 把 "name,city" 形式的记录按 city 建索引。
+func byCity(_ string, value []byte) []string {
+	_, city, ok := strings.Cut(string(value), ",")
+	if !ok {
+		return nil
+	}
+	return []string{city}
+}
+
+func TestIndexMaintainedWithWrites(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	x := New(d)
+	if err := x.AddIndex("city", byCity); err != nil {
+		t.Fatal(err)
+	}
+	check := func(city, want string) {
+		t.Helper()
+		got, err := x.QueryIndex("city", city)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != want {
+			t.Fatalf("QueryIndex(city, %s) = %v, want %s", city, got, want)
+		}
+	}
+
+	for k, v := range map[string]string{"u1": "ann,paris", "u2": "bob,rome", "u3": "cid,paris", "u4": "no city"} {
+		if err := x.Put(k, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check("paris", "[u1 u3]")
+	check("rome", "[u2]")
+
+	// 更新要删除旧值的索引项
+	if err := x.Put("u1", []byte("ann,rome")); err != nil {
+		t.Fatal(err)
+	}
+	check("paris", "[u3]")
+	check("rome", "[u1 u2]")
+
+	if err := x.Delete("u2"); err != nil {
+		t.Fatal(err)
+	}
+	check("rome", "[u1]")
+
+	// 同一个批次里多次修改同一个 key，以最后一次为准
+	var b Batch
+	b.Put("u5", []byte("eve,oslo"))
+	b.Put("u5", []byte("eve,lima"))
+	b.Delete("u3")
+	if err := x.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	check("oslo", "[]")
+	check("lima", "[u5]")
+	check("paris", "[]")
+
+	if v, ok, err := d.Get("u5"); err != nil || !ok || string(v) != "eve,lima" {
+		t.Fatalf("primary record u5 = %q %v %v", v, ok, err)
+	}
+	if _, err := x.QueryIndex("nope", "x"); !errors.Is(err, ErrUnknownIndex) {
+		t.Fatalf("unknown index: %v", err)
+	}
+	if err := x.Put(ReservedPrefix+"x", nil); !errors.Is(err, ErrReservedKey) {
+		t.Fatalf("reserved key: %v", err)
+	}
+	if err := x.AddIndex("city", byCity); !errors.Is(err, ErrIndexExists) {
+		t.Fatalf("duplicate index: %v", err)
+	}
+}
+
+func TestIndexBackfill(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := db.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 建索引之前已经存在的记录
+	for i := 0; i < 3000; i++ {
+		if err := d.Put(fmt.Sprintf("u%04d", i), []byte(fmt.Sprintf("n,c%d", i%3))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	x := New(d)
+	if err := x.AddIndex("city", byCity); err != nil {
+		t.Fatal(err)
+	}
+	got, err := x.QueryIndex("city", "c1")
+	if err != nil || len(got) != 1000 || got[0] != "u0001" {
+		t.Fatalf("after backfill: %d keys, first %v, err %v", len(got), got[:min(len(got), 1)], err)
+	}
+
+	// 绕过索引直接写入，Backfill 之后索引重新一致
+	if err := d.Put("u0001", []byte("n,c2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Backfill("city"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := x.QueryIndex("city", "c1"); err != nil || len(got) != 999 {
+		t.Fatalf("after rebuild: %d keys, %v", len(got), err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新打开之后再注册不会重复回填：绕过索引的写入不会出现在索引里
+	d, err = db.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Put("u0002", []byte("n,c1")); err != nil {
+		t.Fatal(err)
+	}
+	x = New(d)
+	if err := x.AddIndex("city", byCity); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := x.QueryIndex("city", "c1"); err != nil || len(got) != 999 {
+		t.Fatalf("after reopen: %d keys, %v", len(got), err)
+	}
+}
@@ -0,0 +1,200 @@
+// Package invindex 在 ForgeDB 上维护一个简单的倒排索引，支持按词项做 AND / OR 查询。
+//
+// 数据布局（<name> 是索引名）：
+//
+//	<name>/d/<docID>              -> 文档原文
+//	<name>/t/<token>\x00<docID>   -> 空 value（一条 posting）
+//
+// 引擎没有合并算子，所以 posting list 不是存成一个不断追加的 value，
+// 而是每个 (token, docID) 一个 key，查询时用范围扫描取出某个 token 的所有文档。
+// 文档和它的 posting 在同一个 db.Batch 里写入，不会出现只写了一半的索引。
+package invindex
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"monolithdb/internal/db"
+)
+
+// ErrInvalidDocID 表示文档 ID 为空或包含 0 字节（0 字节在 posting key 中用作分隔符）。
+var ErrInvalidDocID = errors.New("invindex: invalid document id")
+
+// Index 是一个倒排索引。同一个 Index 上的写操作是串行的。
+type Index struct {
+	d      *db.DB
+	prefix string
+
+	// mu 串行化 Put / Delete：更新文档需要先读旧文档再删除旧 posting
+	mu sync.Mutex
+}
+
+// New 返回名为 name 的索引，所有数据写在 "<name>/" 前缀下。
+func New(d *db.DB, name string) *Index {
+	return &Index{d: d, prefix: name + "/"}
+}
+
+func (ix *Index) docKey(docID string) string { return ix.prefix + "d/" + docID }
+
+func (ix *Index) postingPrefix(token string) string { return ix.prefix + "t/" + token + "\x00" }
+
+// Tokenize 把文本切分成去重后的小写词项：连续的字母 / 数字为一个词项，其余字符都是分隔符。
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	seen := make(map[string]bool, len(fields))
+	out := fields[:0]
+	for _, f := range fields {
+		if !seen[f] {
+			seen[f] = true
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Put 写入（或覆盖）文档 docID，并原子地更新它的 posting。
+func (ix *Index) Put(docID, text string) error {
+	if docID == "" || strings.IndexByte(docID, 0) >= 0 {
+		return ErrInvalidDocID
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	var b db.Batch
+	if err := ix.deletePostings(&b, docID); err != nil {
+		return err
+	}
+	b.Put(ix.docKey(docID), []byte(text))
+	for _, tok := range Tokenize(text) {
+		b.Put(ix.postingPrefix(tok)+docID, nil)
+	}
+	return ix.d.Write(&b)
+}
+
+// Delete 删除文档 docID 及其 posting；文档不存在时什么也不做。
+func (ix *Index) Delete(docID string) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	var b db.Batch
+	if err := ix.deletePostings(&b, docID); err != nil {
+		return err
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	b.Delete(ix.docKey(docID))
+	return ix.d.Write(&b)
+}
+
+// deletePostings 把旧文档的所有 posting 的删除操作加入 b。
+func (ix *Index) deletePostings(b *db.Batch, docID string) error {
+	old, ok, err := ix.d.Get(ix.docKey(docID))
+	if err != nil || !ok {
+		return err
+	}
+	for _, tok := range Tokenize(string(old)) {
+		b.Delete(ix.postingPrefix(tok) + docID)
+	}
+	return nil
+}
+
+// Get 返回文档原文。
+func (ix *Index) Get(docID string) (string, bool, error) {
+	v, ok, err := ix.d.Get(ix.docKey(docID))
+	return string(v), ok, err
+}
+
+// postings 返回包含 token 的文档 ID（升序）。
+func (ix *Index) postings(token string) ([]string, error) {
+	p := ix.postingPrefix(token)
+	// '\x00' 的下一个字节是 '\x01'，[p, end) 正好是这个 token 的所有 posting
+	end := p[:len(p)-1] + "\x01"
+	entries, err := ix.d.Range(p, end)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.Key[len(p):]
+	}
+	return ids, nil
+}
+
+// queryTokens 用与索引相同的规则切分查询词项。
+func queryTokens(terms []string) []string {
+	return Tokenize(strings.Join(terms, " "))
+}
+
+// And 返回包含所有 terms 的文档 ID（升序）。没有有效词项时返回 nil。
+func (ix *Index) And(terms ...string) ([]string, error) {
+	toks := queryTokens(terms)
+	if len(toks) == 0 {
+		return nil, nil
+	}
+
+	var result []string
+	for i, tok := range toks {
+		ids, err := ix.postings(tok)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			result = ids
+		} else {
+			result = intersect(result, ids)
+		}
+		if len(result) == 0 {
+			return nil, nil
+		}
+	}
+	return result, nil
+}
+
+// Or 返回包含任意一个 terms 的文档 ID（升序，去重）。
+func (ix *Index) Or(terms ...string) ([]string, error) {
+	set := make(map[string]bool)
+	for _, tok := range queryTokens(terms) {
+		ids, err := ix.postings(tok)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			set[id] = true
+		}
+	}
+	if len(set) == 0 {
+		return nil, nil
+	}
+
+	out := make([]string, 0, len(set))
+	for id := range set {
+		out = append(out, id)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// intersect 求两个升序列表的交集。
+func intersect(a, b []string) []string {
+	var out []string
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, a[i])
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return out
+}
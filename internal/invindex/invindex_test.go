@@ -0,0 +1,87 @@
+package invindex
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/db"
+)
+
+func TestIndexQueries(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := db.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ix := New(d, "logs")
+	docs := map[string]string{
+		"1": "ERROR disk full on node-a",
+		"2": "warning: disk almost full",
+		"3": "error: connection refused (node-b)",
+	}
+	for id, text := range docs {
+		if err := ix.Put(id, text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(name string, got []string, err error, want string) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != want {
+			t.Fatalf("%s = %v, want %s", name, got, want)
+		}
+	}
+
+	got, err := ix.And("error")
+	check("And(error)", got, err, "[1 3]")
+	got, err = ix.And("disk", "FULL")
+	check("And(disk, full)", got, err, "[1 2]")
+	got, err = ix.And("error", "disk")
+	check("And(error, disk)", got, err, "[1]")
+	got, err = ix.Or("refused", "warning")
+	check("Or(refused, warning)", got, err, "[2 3]")
+	got, err = ix.And("nothing")
+	check("And(nothing)", got, err, "[]")
+
+	// 覆盖文档：旧词项的 posting 要删除
+	if err := ix.Put("1", "all good"); err != nil {
+		t.Fatal(err)
+	}
+	got, err = ix.And("error")
+	check("And(error) after update", got, err, "[3]")
+
+	if err := ix.Delete("3"); err != nil {
+		t.Fatal(err)
+	}
+	got, err = ix.Or("error", "refused")
+	check("Or after delete", got, err, "[]")
+
+	// 重启后索引依然可用（batch 通过 WAL 回放恢复）
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	d, err = db.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+	ix = New(d, "logs")
+
+	got, err = ix.And("good")
+	check("And(good) after reopen", got, err, "[1]")
+	if text, ok, _ := ix.Get("2"); !ok || text != docs["2"] {
+		t.Fatalf("Get(2) = %q %v", text, ok)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Hello, hello WORLD! 数据库 v2.0")
+	if fmt.Sprint(got) != "[hello world 数据库 v2 0]" {
+		t.Fatalf("Tokenize = %v", got)
+	}
+}
@@ -0,0 +1,16 @@
+package leveldb
+
+import (
+	"fmt"
+
+	"monolithdb/internal/snappy"
+)
+
+// decodeSnappy 解压一个 snappy 压缩的块（block 格式，见 internal/snappy），坏数据返回 ErrCorrupt。
+func decodeSnappy(src []byte) ([]byte, error) {
+	b, err := snappy.Decode(src)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,513 @@
+// Package leveldb 读取 LevelDB / RocksDB 的 table 文件（.ldb / .sst），用于把已有的数据集迁移到 ForgeDB。
+//
+// 支持 LevelDB 的表，以及 RocksDB format_version 0–5 的 block-based table（二分索引，
+// 不压缩 / snappy / zlib 压缩）。分区索引、range deletion、merge 操作数、blob 等 RocksDB 特性
+// 返回 ErrUnsupported。只校验 crc32c 校验和，其他校验和类型的块不校验。
+package leveldb
+
+import (
+	"bytes"
+	"compress/flate"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"iter"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrCorrupt 表示文件不是合法的 table 文件，或者内容已经损坏。
+	ErrCorrupt = errors.New("leveldb: corrupt table")
+	// ErrUnsupported 表示 table 使用了本包不支持的格式或特性。
+	ErrUnsupported = errors.New("leveldb: unsupported table feature")
+)
+
+const (
+	// legacyMagic 是 LevelDB（以及 RocksDB format_version 0）的 footer magic。
+	legacyMagic uint64 = 0xdb4775248b80fb57
+	// rocksMagic 是 RocksDB block-based table（format_version >= 1）的 footer magic。
+	rocksMagic uint64 = 0x88e241b785f4cff7
+
+	legacyFooterSize = 48
+	rocksFooterSize  = 53
+	// maxHandles 是 footer 里两个 block handle 最多占用的字节数。
+	maxHandles = 40
+
+	// blockTrailerSize 是每个块之后的压缩类型（1 字节）和校验和（4 字节）。
+	blockTrailerSize = 5
+)
+
+// 块的压缩类型。
+const (
+	noCompression     = 0
+	snappyCompression = 1
+	zlibCompression   = 2
+)
+
+// checksumCRC32C 是 RocksDB footer 中 crc32c 校验和的类型编号，LevelDB 总是使用 crc32c。
+const checksumCRC32C = 1
+
+// internal key 末尾 8 字节里的记录类型。
+const (
+	typeDeletion       = 0x0
+	typeValue          = 0x1
+	typeSingleDeletion = 0x7
+)
+
+// RocksDB properties 块里用到的属性。
+const (
+	propIndexType       = "rocksdb.block.based.table.index.type"
+	propIndexValueDelta = "rocksdb.index.value.is.delta.encoded"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Record 是 table 中的一条记录。
+type Record struct {
+	Key     string
+	Value   []byte // Deleted 为 true 时为 nil
+	Seq     uint64 // LevelDB / RocksDB 的序号
+	Deleted bool   // 删除标记（Delete 或 SingleDelete）
+}
+
+type blockHandle struct {
+	offset, size uint64
+}
+
+// Table 是一个打开的 table 文件。
+type Table struct {
+	f    *os.File
+	path string
+
+	formatVersion uint32
+	checksum      byte
+	index         blockHandle
+	indexDelta    bool   // 索引的 value 使用 delta 编码（RocksDB format_version >= 4）
+	dict          []byte // zlib 预置字典，没有时为 nil
+}
+
+// Open 打开 path 处的 table 文件，读取并校验 footer、metaindex 和 properties。
+func Open(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &Table{f: f, path: path}
+	if err := t.init(); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Close 关闭文件。
+func (t *Table) Close() error { return t.f.Close() }
+
+func (t *Table) init() error {
+	st, err := t.f.Stat()
+	if err != nil {
+		return err
+	}
+	size := st.Size()
+	if size < legacyFooterSize {
+		return fmt.Errorf("%w: file too small", ErrCorrupt)
+	}
+
+	var tail [rocksFooterSize]byte
+	n := min(int64(len(tail)), size)
+	buf := tail[len(tail)-int(n):]
+	if _, err := t.f.ReadAt(buf, size-n); err != nil {
+		return err
+	}
+	var handles []byte
+	switch magic := binary.LittleEndian.Uint64(buf[len(buf)-8:]); magic {
+	case legacyMagic:
+		t.checksum = checksumCRC32C
+		handles = buf[len(buf)-legacyFooterSize:]
+	case rocksMagic:
+		if n < rocksFooterSize {
+			return fmt.Errorf("%w: file too small", ErrCorrupt)
+		}
+		t.formatVersion = binary.LittleEndian.Uint32(buf[len(buf)-12:])
+		if t.formatVersion > 5 {
+			return fmt.Errorf("%w: RocksDB format_version %d", ErrUnsupported, t.formatVersion)
+		}
+		t.checksum = buf[0]
+		handles = buf[1:]
+	default:
+		return fmt.Errorf("%w: bad magic %#x", ErrCorrupt, magic)
+	}
+
+	metaindex, rest, err := decodeHandle(handles[:maxHandles])
+	if err != nil {
+		return err
+	}
+	if t.index, _, err = decodeHandle(rest); err != nil {
+		return err
+	}
+	return t.readMeta(metaindex)
+}
+
+// readMeta 读取 metaindex 里列出的元数据块，拒绝会改变记录语义的特性。
+func (t *Table) readMeta(h blockHandle) error {
+	b, err := t.readBlock(h)
+	if err != nil {
+		return err
+	}
+	meta := make(map[string]blockHandle)
+	for key, value := range blockEntries(b, &err) {
+		mh, _, herr := decodeHandle(value)
+		if herr != nil {
+			return herr
+		}
+		meta[key] = mh
+	}
+	if err != nil {
+		return err
+	}
+
+	if h, ok := meta["rocksdb.range_del"]; ok && h.size > 0 {
+		return fmt.Errorf("%w: range deletions", ErrUnsupported)
+	}
+	if h, ok := meta["rocksdb.compression_dict"]; ok {
+		if t.dict, err = t.readBlock(h); err != nil {
+			return err
+		}
+	}
+	if h, ok := meta["rocksdb.properties"]; ok {
+		b, err := t.readBlock(h)
+		if err != nil {
+			return err
+		}
+		for key, value := range blockEntries(b, &err) {
+			switch key {
+			case propIndexType:
+				// 0 是二分查找索引，1 是带前缀哈希的二分索引，两者的块格式相同；2 是分区索引
+				if len(value) == 4 && binary.LittleEndian.Uint32(value) > 1 {
+					return fmt.Errorf("%w: partitioned index", ErrUnsupported)
+				}
+			case propIndexValueDelta:
+				v, _ := binary.Uvarint(value)
+				t.indexDelta = v != 0
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// All 按 internal key 的顺序（user key 按字节序升序，同一个 key 序号降序）返回表中的所有记录。
+func (t *Table) All() iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		handles, err := t.dataHandles()
+		if err != nil {
+			yield(Record{}, err)
+			return
+		}
+		for _, h := range handles {
+			b, err := t.readBlock(h)
+			if err != nil {
+				yield(Record{}, err)
+				return
+			}
+			for key, value := range blockEntries(b, &err) {
+				r, rerr := decodeRecord(key, value)
+				if rerr != nil {
+					yield(Record{}, fmt.Errorf("%s: %w", t.path, rerr))
+					return
+				}
+				if !yield(r, nil) {
+					return
+				}
+			}
+			if err != nil {
+				yield(Record{}, fmt.Errorf("%s: %w", t.path, err))
+				return
+			}
+		}
+	}
+}
+
+// dataHandles 读取索引块，按顺序返回所有数据块的位置。
+func (t *Table) dataHandles() ([]blockHandle, error) {
+	b, err := t.readBlock(t.index)
+	if err != nil {
+		return nil, err
+	}
+	if t.indexDelta {
+		return deltaHandles(b)
+	}
+	var out []blockHandle
+	for _, value := range blockEntries(b, &err) {
+		h, _, herr := decodeHandle(value)
+		if herr != nil {
+			return nil, herr
+		}
+		out = append(out, h)
+	}
+	return out, err
+}
+
+// readBlock 读取 h 处的块，校验 trailer 里的校验和并解压。
+func (t *Table) readBlock(h blockHandle) ([]byte, error) {
+	if h.size > 1<<31 {
+		return nil, fmt.Errorf("%w: block of %d bytes", ErrCorrupt, h.size)
+	}
+	buf := make([]byte, h.size+blockTrailerSize)
+	if _, err := t.f.ReadAt(buf, int64(h.offset)); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: block at %d is truncated", ErrCorrupt, h.offset)
+		}
+		return nil, err
+	}
+	data, kind := buf[:h.size], buf[h.size]
+	if t.checksum == checksumCRC32C {
+		want := unmask(binary.LittleEndian.Uint32(buf[h.size+1:]))
+		if crc32.Checksum(buf[:h.size+1], castagnoli) != want {
+			return nil, fmt.Errorf("%w: checksum mismatch in block at %d", ErrCorrupt, h.offset)
+		}
+	}
+
+	switch kind {
+	case noCompression:
+		return data, nil
+	case snappyCompression:
+		return decodeSnappy(data)
+	case zlibCompression:
+		// RocksDB format_version >= 2 在压缩数据前记录解压后的长度
+		if t.formatVersion >= 2 {
+			_, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, fmt.Errorf("%w: bad zlib block header", ErrCorrupt)
+			}
+			data = data[n:]
+		}
+		r := flate.NewReaderDict(bytes.NewReader(data), t.dict)
+		defer r.Close()
+		out, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: zlib: %v", ErrCorrupt, err)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("%w: compression type %d", ErrUnsupported, kind)
+	}
+}
+
+// unmask 还原 LevelDB 存储的 masked crc。
+func unmask(m uint32) uint32 {
+	rot := m - 0xa282ead8
+	return rot>>17 | rot<<15
+}
+
+func decodeHandle(b []byte) (blockHandle, []byte, error) {
+	off, n := binary.Uvarint(b)
+	if n <= 0 {
+		return blockHandle{}, nil, fmt.Errorf("%w: bad block handle", ErrCorrupt)
+	}
+	size, m := binary.Uvarint(b[n:])
+	if m <= 0 {
+		return blockHandle{}, nil, fmt.Errorf("%w: bad block handle", ErrCorrupt)
+	}
+	return blockHandle{offset: off, size: size}, b[n+m:], nil
+}
+
+// restarts 返回块的重启点数组和记录区的长度。
+// RocksDB 的数据块可能带哈希索引（最后一个 uint32 的最高位），它位于重启点数组和块末尾之间。
+func restarts(b []byte) ([]uint32, int, error) {
+	if len(b) < 4 {
+		return nil, 0, fmt.Errorf("%w: block too small", ErrCorrupt)
+	}
+	packed := binary.LittleEndian.Uint32(b[len(b)-4:])
+	num, end := int(packed&0x7fffffff), len(b)-4
+	if packed&0x80000000 != 0 {
+		if end < 2 {
+			return nil, 0, fmt.Errorf("%w: block too small", ErrCorrupt)
+		}
+		end -= 2 + int(binary.LittleEndian.Uint16(b[end-2:]))
+	}
+	end -= 4 * num
+	if num == 0 || end < 0 {
+		return nil, 0, fmt.Errorf("%w: bad restart array", ErrCorrupt)
+	}
+	out := make([]uint32, num)
+	for i := range out {
+		out[i] = binary.LittleEndian.Uint32(b[end+4*i:])
+	}
+	return out, end, nil
+}
+
+// blockEntries 按顺序返回块中的 key / value（前缀压缩已还原）。出错时停止迭代，错误写入 *errp。
+func blockEntries(b []byte, errp *error) iter.Seq2[string, []byte] {
+	return func(yield func(string, []byte) bool) {
+		_, end, err := restarts(b)
+		if err != nil {
+			*errp = err
+			return
+		}
+		var key []byte
+		for p := 0; p < end; {
+			shared, n1 := binary.Uvarint(b[p:end])
+			nonShared, n2 := uvarintAt(b[:end], p+n1, n1)
+			valueLen, n3 := uvarintAt(b[:end], p+n1+n2, n2)
+			if n1 <= 0 || n2 <= 0 || n3 <= 0 {
+				*errp = fmt.Errorf("%w: bad block entry at %d", ErrCorrupt, p)
+				return
+			}
+			p += n1 + n2 + n3
+			if shared > uint64(len(key)) || nonShared > uint64(end-p) || valueLen > uint64(end-p)-nonShared {
+				*errp = fmt.Errorf("%w: bad block entry at %d", ErrCorrupt, p)
+				return
+			}
+			key = append(key[:shared], b[p:p+int(nonShared)]...)
+			p += int(nonShared)
+			value := b[p : p+int(valueLen)]
+			p += int(valueLen)
+			if !yield(string(key), value) {
+				return
+			}
+		}
+	}
+}
+
+// uvarintAt 在前一个 varint 合法（prev > 0）时解码 b[p:] 开头的 varint。
+func uvarintAt(b []byte, p, prev int) (uint64, int) {
+	if prev <= 0 || p > len(b) {
+		return 0, 0
+	}
+	return binary.Uvarint(b[p:])
+}
+
+// deltaHandles 解码 value 使用 delta 编码的索引块：条目里没有 value 长度，
+// 不共享 key 前缀的条目（每个重启点）存完整的 handle，其余条目只存与上一个块大小的差，
+// 偏移紧接在上一个块（及其 trailer）之后。
+func deltaHandles(b []byte) ([]blockHandle, error) {
+	_, end, err := restarts(b)
+	if err != nil {
+		return nil, err
+	}
+	var out []blockHandle
+	var prev blockHandle
+	keyLen := uint64(0)
+	for p := 0; p < end; {
+		shared, n1 := binary.Uvarint(b[p:end])
+		nonShared, n2 := uvarintAt(b[:end], p+n1, n1)
+		if n1 <= 0 || n2 <= 0 || shared > keyLen || nonShared > uint64(end-p-n1-n2) {
+			return nil, fmt.Errorf("%w: bad index entry at %d", ErrCorrupt, p)
+		}
+		p += n1 + n2 + int(nonShared)
+		keyLen = shared + nonShared
+
+		var h blockHandle
+		if shared == 0 {
+			var rest []byte
+			if h, rest, err = decodeHandle(b[p:end]); err != nil {
+				return nil, err
+			}
+			p = end - len(rest)
+		} else {
+			delta, n := binary.Varint(b[p:end])
+			if n <= 0 || len(out) == 0 {
+				return nil, fmt.Errorf("%w: bad index entry at %d", ErrCorrupt, p)
+			}
+			p += n
+			h = blockHandle{offset: prev.offset + prev.size + blockTrailerSize, size: uint64(int64(prev.size) + delta)}
+		}
+		out = append(out, h)
+		prev = h
+	}
+	return out, nil
+}
+
+// decodeRecord 拆开 internal key：user key 之后是 8 字节的 (seq << 8) | type。
+func decodeRecord(key string, value []byte) (Record, error) {
+	if len(key) < 8 {
+		return Record{}, fmt.Errorf("%w: internal key too short", ErrCorrupt)
+	}
+	tag := binary.LittleEndian.Uint64([]byte(key[len(key)-8:]))
+	r := Record{Key: key[:len(key)-8], Seq: tag >> 8}
+	switch kind := tag & 0xff; kind {
+	case typeValue:
+		r.Value = bytes.Clone(value)
+	case typeDeletion, typeSingleDeletion:
+		r.Deleted = true
+	default:
+		return Record{}, fmt.Errorf("%w: record type %#x for key %q", ErrUnsupported, kind, r.Key)
+	}
+	return r, nil
+}
+
+// Merge 合并多张表（例如一个 LevelDB 数据目录下的所有 .ldb），按 user key 字节序升序、
+// 每个 key 只返回序号最大的那条记录（可能是删除标记）。
+func Merge(tables ...*Table) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		var h mergeHeap
+		for _, t := range tables {
+			next, stop := iter.Pull2(t.All())
+			defer stop()
+			if r, err, ok := next(); ok {
+				if err != nil {
+					yield(Record{}, err)
+					return
+				}
+				h = append(h, &mergeSource{cur: r, next: next})
+			}
+		}
+		heap.Init(&h)
+
+		var last string
+		started := false
+		for len(h) > 0 {
+			src := h[0]
+			r := src.cur
+			if nr, err, ok := src.next(); ok {
+				if err != nil {
+					yield(Record{}, err)
+					return
+				}
+				src.cur = nr
+				heap.Fix(&h, 0)
+			} else {
+				heap.Pop(&h)
+			}
+			// 同一个 key 按序号降序出堆，第一条就是最新的版本
+			if started && r.Key == last {
+				continue
+			}
+			last, started = r.Key, true
+			if !yield(r, nil) {
+				return
+			}
+		}
+	}
+}
+
+type mergeSource struct {
+	cur  Record
+	next func() (Record, error, bool)
+}
+
+// mergeHeap 按 (user key 升序, seq 降序) 排列各表当前的记录。
+type mergeHeap []*mergeSource
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if c := strings.Compare(h[i].cur.Key, h[j].cur.Key); c != 0 {
+		return c < 0
+	}
+	return h[i].cur.Seq > h[j].cur.Seq
+}
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)   { *h = append(*h, x.(*mergeSource)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,356 @@
+package leveldb
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// testTable 按 LevelDB / RocksDB 的格式写一张表，用来测试读取。
+type testTable struct {
+	rocks         bool
+	formatVersion uint32
+	compression   byte // noCompression / snappyCompression / zlibCompression
+	deltaIndex    bool
+	blockEntries  int // 每个数据块的记录数
+	rangeDel      bool
+
+	buf bytes.Buffer
+}
+
+type testRecord struct {
+	key   string
+	seq   uint64
+	kind  byte
+	value string
+}
+
+func internalKey(r testRecord) string {
+	var tag [8]byte
+	binary.LittleEndian.PutUint64(tag[:], r.seq<<8|uint64(r.kind))
+	return r.key + string(tag[:])
+}
+
+// encodeBlock 按前缀压缩编码块，每 interval 条记录一个重启点。
+// deltaValues 不为 nil 时按 delta 编码的索引块写：条目里没有 value 长度，共享前缀的条目写 deltaValues[i]。
+func encodeBlock(keys []string, values, deltaValues [][]byte, interval int) []byte {
+	var b []byte
+	var restarts []uint32
+	prev := ""
+	for i, k := range keys {
+		shared := 0
+		if i%interval == 0 {
+			restarts = append(restarts, uint32(len(b)))
+		} else {
+			for shared < len(prev) && shared < len(k) && prev[shared] == k[shared] {
+				shared++
+			}
+		}
+		v := values[i]
+		b = binary.AppendUvarint(b, uint64(shared))
+		b = binary.AppendUvarint(b, uint64(len(k)-shared))
+		if deltaValues == nil {
+			b = binary.AppendUvarint(b, uint64(len(v)))
+		} else if shared > 0 {
+			v = deltaValues[i]
+		}
+		b = append(b, k[shared:]...)
+		b = append(b, v...)
+		prev = k
+	}
+	for _, r := range restarts {
+		b = binary.LittleEndian.AppendUint32(b, r)
+	}
+	return binary.LittleEndian.AppendUint32(b, uint32(len(restarts)))
+}
+
+func appendHandle(b []byte, h blockHandle) []byte {
+	return binary.AppendUvarint(binary.AppendUvarint(b, h.offset), h.size)
+}
+
+// snappyLiteral 把 data 编码成只含一个 literal 的 snappy 块。
+func snappyLiteral(data []byte) []byte {
+	b := binary.AppendUvarint(nil, uint64(len(data)))
+	if n := len(data) - 1; n < 60 {
+		b = append(b, byte(n<<2))
+	} else {
+		b = append(b, 62<<2, byte(n), byte(n>>8), byte(n>>16))
+	}
+	return append(b, data...)
+}
+
+// writeBlock 写入一个块及其 trailer，meta 块总是不压缩。
+func (tt *testTable) writeBlock(data []byte, compress bool) blockHandle {
+	kind := byte(noCompression)
+	if compress {
+		kind = tt.compression
+	}
+	switch kind {
+	case snappyCompression:
+		data = snappyLiteral(data)
+	case zlibCompression:
+		var zb bytes.Buffer
+		if tt.formatVersion >= 2 {
+			zb.Write(binary.AppendUvarint(nil, uint64(len(data))))
+		}
+		w, _ := flate.NewWriter(&zb, flate.BestCompression)
+		w.Write(data)
+		w.Close()
+		data = zb.Bytes()
+	}
+	h := blockHandle{offset: uint64(tt.buf.Len()), size: uint64(len(data))}
+	tt.buf.Write(data)
+	crc := crc32.Checksum(append(bytes.Clone(data), kind), castagnoli)
+	masked := (crc>>15 | crc<<17) + 0xa282ead8
+	tt.buf.WriteByte(kind)
+	tt.buf.Write(binary.LittleEndian.AppendUint32(nil, masked))
+	return h
+}
+
+func (tt *testTable) write(t *testing.T, path string, records []testRecord) {
+	t.Helper()
+	var indexKeys []string
+	var indexValues, indexDeltas [][]byte
+	var prev blockHandle
+	for i := 0; i < len(records); i += tt.blockEntries {
+		chunk := records[i:min(i+tt.blockEntries, len(records))]
+		var keys []string
+		var values [][]byte
+		for _, r := range chunk {
+			keys = append(keys, internalKey(r))
+			values = append(values, []byte(r.value))
+		}
+		h := tt.writeBlock(encodeBlock(keys, values, nil, 2), true)
+		indexKeys = append(indexKeys, keys[len(keys)-1])
+		indexValues = append(indexValues, appendHandle(nil, h))
+		indexDeltas = append(indexDeltas, binary.AppendVarint(nil, int64(h.size)-int64(prev.size)))
+		prev = h
+	}
+
+	var metaKeys []string
+	var metaValues [][]byte
+	if tt.rocks {
+		delta := uint64(0)
+		if tt.deltaIndex {
+			delta = 1
+		}
+		props := tt.writeBlock(encodeBlock(
+			[]string{propIndexType, propIndexValueDelta},
+			[][]byte{binary.LittleEndian.AppendUint32(nil, 0), binary.AppendUvarint(nil, delta)}, nil, 16), false)
+		if tt.rangeDel {
+			rd := tt.writeBlock(encodeBlock([]string{internalKey(testRecord{key: "a", seq: 9, kind: 0xf})}, [][]byte{[]byte("z")}, nil, 1), false)
+			metaKeys, metaValues = append(metaKeys, "rocksdb.range_del"), append(metaValues, appendHandle(nil, rd))
+		}
+		metaKeys, metaValues = append(metaKeys, "rocksdb.properties"), append(metaValues, appendHandle(nil, props))
+	}
+	var metaindex blockHandle
+	if len(metaKeys) == 0 {
+		metaindex = tt.writeBlock(binary.LittleEndian.AppendUint32(binary.LittleEndian.AppendUint32(nil, 0), 1), false)
+	} else {
+		metaindex = tt.writeBlock(encodeBlock(metaKeys, metaValues, nil, 16), false)
+	}
+
+	var index blockHandle
+	if tt.deltaIndex {
+		index = tt.writeBlock(encodeBlock(indexKeys, indexValues, indexDeltas, 16), false)
+	} else {
+		index = tt.writeBlock(encodeBlock(indexKeys, indexValues, nil, 1), false)
+	}
+
+	handles := make([]byte, maxHandles)
+	copy(handles, appendHandle(appendHandle(nil, metaindex), index))
+	if tt.rocks {
+		tt.buf.WriteByte(checksumCRC32C)
+		tt.buf.Write(handles)
+		tt.buf.Write(binary.LittleEndian.AppendUint32(nil, tt.formatVersion))
+		tt.buf.Write(binary.LittleEndian.AppendUint64(nil, rocksMagic))
+	} else {
+		tt.buf.Write(handles)
+		tt.buf.Write(binary.LittleEndian.AppendUint64(nil, legacyMagic))
+	}
+	if err := os.WriteFile(path, tt.buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sampleRecords(n int) []testRecord {
+	var out []testRecord
+	for i := range n {
+		k := fmt.Sprintf("key%04d", i)
+		switch i % 5 {
+		case 0:
+			out = append(out, testRecord{key: k, seq: 100, kind: typeValue, value: "new"}, testRecord{key: k, seq: 10, kind: typeValue, value: "old"})
+		case 1:
+			out = append(out, testRecord{key: k, seq: uint64(i), kind: typeDeletion})
+		default:
+			out = append(out, testRecord{key: k, seq: uint64(i), kind: typeValue, value: fmt.Sprintf("value-%d-%s", i, bytes.Repeat([]byte("x"), i%7))})
+		}
+	}
+	return out
+}
+
+func readAll(t *testing.T, path string) []Record {
+	t.Helper()
+	tbl, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tbl.Close()
+	var out []Record
+	for r, err := range tbl.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func TestReadTable(t *testing.T) {
+	records := sampleRecords(50)
+	for _, tt := range []*testTable{
+		{blockEntries: 7},
+		{blockEntries: 4, compression: snappyCompression},
+		{rocks: true, formatVersion: 2, blockEntries: 5, compression: zlibCompression},
+		{rocks: true, formatVersion: 5, blockEntries: 3, compression: snappyCompression, deltaIndex: true},
+	} {
+		name := fmt.Sprintf("rocks=%v,v%d,c%d,delta=%v", tt.rocks, tt.formatVersion, tt.compression, tt.deltaIndex)
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "000001.ldb")
+			tt.write(t, path, records)
+			got := readAll(t, path)
+			if len(got) != len(records) {
+				t.Fatalf("read %d records, want %d", len(got), len(records))
+			}
+			for i, r := range records {
+				g := got[i]
+				if g.Key != r.key || g.Seq != r.seq || g.Deleted != (r.kind == typeDeletion) || string(g.Value) != r.value {
+					t.Fatalf("record %d = %+v, want %+v", i, g, r)
+				}
+			}
+		})
+	}
+}
+
+func TestReadTableErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "corrupt.ldb")
+	(&testTable{blockEntries: 4}).write(t, path, sampleRecords(10))
+	b, _ := os.ReadFile(path)
+	b[3] ^= 0xff
+	os.WriteFile(path, b, 0o644)
+	tbl, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err = range tbl.All() {
+		if err != nil {
+			break
+		}
+	}
+	tbl.Close()
+	if !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("All on corrupt block = %v, want ErrCorrupt", err)
+	}
+
+	path = filepath.Join(dir, "rangedel.sst")
+	(&testTable{rocks: true, formatVersion: 2, blockEntries: 4, rangeDel: true}).write(t, path, sampleRecords(10))
+	if _, err := Open(path); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("Open with range deletions = %v, want ErrUnsupported", err)
+	}
+
+	path = filepath.Join(dir, "merge.ldb")
+	(&testTable{blockEntries: 4}).write(t, path, []testRecord{{key: "a", seq: 1, kind: 0x2, value: "+1"}})
+	if tbl, err = Open(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, err = range tbl.All() {
+	}
+	tbl.Close()
+	if !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("All with merge operands = %v, want ErrUnsupported", err)
+	}
+
+	path = filepath.Join(dir, "garbage.ldb")
+	os.WriteFile(path, bytes.Repeat([]byte("x"), 100), 0o644)
+	if _, err := Open(path); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Open(garbage) = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+	older := filepath.Join(dir, "000001.ldb")
+	newer := filepath.Join(dir, "000002.ldb")
+	(&testTable{blockEntries: 2}).write(t, older, []testRecord{
+		{key: "a", seq: 1, kind: typeValue, value: "a1"},
+		{key: "b", seq: 2, kind: typeValue, value: "b2"},
+		{key: "d", seq: 3, kind: typeValue, value: "d3"},
+	})
+	(&testTable{blockEntries: 2}).write(t, newer, []testRecord{
+		{key: "b", seq: 5, kind: typeDeletion},
+		{key: "c", seq: 6, kind: typeValue, value: "c6"},
+		{key: "d", seq: 7, kind: typeValue, value: "d7"},
+		{key: "d", seq: 4, kind: typeValue, value: "d4"},
+	})
+
+	var tables []*Table
+	for _, p := range []string{older, newer} {
+		tbl, err := Open(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tbl.Close()
+		tables = append(tables, tbl)
+	}
+	var got []string
+	for r, err := range Merge(tables...) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s@%d:%v:%s", r.Key, r.Seq, r.Deleted, r.Value))
+	}
+	want := []string{"a@1:false:a1", "b@5:true:", "c@6:false:c6", "d@7:false:d7"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Merge = %v, want %v", got, want)
+	}
+}
+
+func TestDecodeSnappy(t *testing.T) {
+	// literal "abc"，然后 copy(offset 3, length 9)
+	got, err := decodeSnappy([]byte{12, 2 << 2, 'a', 'b', 'c', 5<<2 | 1, 3})
+	if err != nil || string(got) != "abcabcabcabc" {
+		t.Fatalf("decodeSnappy = %q, %v", got, err)
+	}
+	long := bytes.Repeat([]byte("0123456789"), 30)
+	if got, err := decodeSnappy(snappyLiteral(long)); err != nil || !bytes.Equal(got, long) {
+		t.Fatalf("decodeSnappy(long literal) = %q, %v", got, err)
+	}
+	for _, bad := range [][]byte{{5, 2 << 2, 'a'}, {4, 3<<2 | 2, 9, 0}, {12, 2 << 2, 'a', 'b', 'c'},
+		{3, 2 << 2, 'a', 'b', 'c', 5<<2 | 1, 3}} { // 最后一个：copy 之后超过开头的长度
+		if _, err := decodeSnappy(bad); !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("decodeSnappy(%v) = %v, want ErrCorrupt", bad, err)
+		}
+	}
+
+	// 开头声称解压后有 2 GiB：不能照着预分配
+	huge := binary.AppendUvarint(nil, 1<<31)
+	huge = append(huge, 2<<2, 'a', 'b', 'c')
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := decodeSnappy(huge); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("decodeSnappy(huge header) = %v, want ErrCorrupt", err)
+	}
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Fatalf("decodeSnappy allocated %d bytes for a %d-byte input", n, len(huge))
+	}
+}
@@ -0,0 +1,170 @@
+package manifest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"monolithdb/internal/vfs"
+)
+
+// Manifest 记录数据库目录的关键元信息。
+// 这些配置一旦写入数据，就不能随意改变（例如换了比较器，SST 里的 key 顺序就不再成立），
+// 所以在 Open 时需要和用户传入的配置做比对。
+type Manifest struct {
+	FormatVersion   uint32
+	Comparator      string
+	PrefixExtractor string
+	MergeOperator   string
+	KeyNormalizer   string
+
+	// NextFileNumber 是下一个可用的文件编号（SST 和值日志共用），0 表示没有记录。
+	// 编号最大的文件被删除之后，重启时不能只靠扫描目录得到它，所以删除文件之前先记在这里。
+	NextFileNumber uint64
+}
+
+// FileName 是 manifest 在数据目录下的文件名。
+const FileName = "MANIFEST"
+
+const (
+	magic uint32 = 0x464d414e // 'FMAN'
+
+	// 防止坏文件导致超大分配
+	maxFieldSize = 1 << 16
+)
+
+var ErrCorruptManifest = errors.New("manifest: corrupt")
+
+// Load 读取 manifest 文件。
+// 文件不存在时返回 (nil, nil)，由调用方决定是否新建。
+func Load(path string) (*Manifest, error) {
+	return LoadFS(vfs.Default, path)
+}
+
+// LoadFS 与 Load 相同，但通过 fs 读取文件。
+func LoadFS(fs vfs.FS, path string) (*Manifest, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	var m uint32
+	if err := binary.Read(r, binary.LittleEndian, &m); err != nil {
+		return nil, ErrCorruptManifest
+	}
+	if m != magic {
+		return nil, ErrCorruptManifest
+	}
+
+	out := &Manifest{}
+	if err := binary.Read(r, binary.LittleEndian, &out.FormatVersion); err != nil {
+		return nil, ErrCorruptManifest
+	}
+
+	fields := []*string{&out.Comparator, &out.PrefixExtractor, &out.MergeOperator}
+	for _, p := range fields {
+		s, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		*p = s
+	}
+
+	// KeyNormalizer 是后来追加的字段，旧文件里没有它，等同于空
+	if _, err := r.Peek(1); err == io.EOF {
+		return out, nil
+	}
+	if out.KeyNormalizer, err = readString(r); err != nil {
+		return nil, err
+	}
+
+	// NextFileNumber 同样是后来追加的
+	if _, err := r.Peek(1); err == io.EOF {
+		return out, nil
+	}
+	if err := binary.Read(r, binary.LittleEndian, &out.NextFileNumber); err != nil {
+		return nil, ErrCorruptManifest
+	}
+
+	return out, nil
+}
+
+// Write 把 manifest 写入 path。
+// 先写临时文件并 fsync，再 rename 覆盖，保证不会留下写了一半的 manifest。
+func Write(path string, m *Manifest) error {
+	return WriteFS(vfs.Default, path, m)
+}
+
+// WriteFS 与 Write 相同，但通过 fs 写入文件。
+func WriteFS(fs vfs.FS, path string, m *Manifest) error {
+	tmp := path + ".tmp"
+	f, err := fs.OpenFile(tmp, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	err = writeAll(w, m)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = fs.Remove(tmp)
+		return err
+	}
+
+	if err := fs.Rename(tmp, path); err != nil {
+		_ = fs.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// 格式：| magic(uint32) | formatVersion(uint32) | 4 x [len(uint32) | bytes] | nextFileNumber(uint64) |
+// 四个字符串依次是 comparator、prefix extractor、merge operator、key normalizer。
+func writeAll(w io.Writer, m *Manifest) error {
+	if err := binary.Write(w, binary.LittleEndian, magic); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, m.FormatVersion); err != nil {
+		return err
+	}
+	for _, s := range []string{m.Comparator, m.PrefixExtractor, m.MergeOperator, m.KeyNormalizer} {
+		if err := binary.Write(w, binary.LittleEndian, uint32(len(s))); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, s); err != nil {
+			return err
+		}
+	}
+	return binary.Write(w, binary.LittleEndian, m.NextFileNumber)
+}
+
+func readString(r io.Reader) (string, error) {
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", ErrCorruptManifest
+	}
+	if n > maxFieldSize {
+		return "", ErrCorruptManifest
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", ErrCorruptManifest
+	}
+	return string(b), nil
+}
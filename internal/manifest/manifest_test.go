@@ -0,0 +1,124 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifestWriteLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+
+	want := &Manifest{
+		FormatVersion:   1,
+		Comparator:      "forgedb.BytewiseComparator",
+		PrefixExtractor: "fixed:4",
+		MergeOperator:   "",
+		KeyNormalizer:   "forgedb.Lowercase",
+		NextFileNumber:  42,
+	}
+	if err := Write(path, want); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || *got != *want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	// 临时文件不应残留
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("expected tmp file to be removed, stat err=%v", err)
+	}
+}
+
+func TestManifestMissing(t *testing.T) {
+	dir := t.TempDir()
+
+	m, err := Load(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != nil {
+		t.Fatalf("expected nil manifest for missing file, got %+v", m)
+	}
+}
+
+func TestManifestCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+
+	if err := os.WriteFile(path, []byte{1, 2, 3}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load(path)
+	if err != ErrCorruptManifest {
+		t.Fatalf("expected ErrCorruptManifest, got %v", err)
+	}
+}
+
+func TestManifestWithoutKeyNormalizer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+
+	// 没有 KeyNormalizer 字段的旧格式：magic、版本号和三个字符串
+	var b bytes.Buffer
+	_ = binary.Write(&b, binary.LittleEndian, magic)
+	_ = binary.Write(&b, binary.LittleEndian, uint32(9))
+	for _, s := range []string{"cmp", "", "merge"} {
+		_ = binary.Write(&b, binary.LittleEndian, uint32(len(s)))
+		b.WriteString(s)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Manifest{FormatVersion: 9, Comparator: "cmp", MergeOperator: "merge"}
+	if got == nil || *got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestManifestWithoutNextFileNumber(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+
+	// 没有 NextFileNumber 字段的旧格式：magic、版本号和四个字符串
+	var b bytes.Buffer
+	_ = binary.Write(&b, binary.LittleEndian, magic)
+	_ = binary.Write(&b, binary.LittleEndian, uint32(10))
+	for _, s := range []string{"cmp", "", "", "norm"} {
+		_ = binary.Write(&b, binary.LittleEndian, uint32(len(s)))
+		b.WriteString(s)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Manifest{FormatVersion: 10, Comparator: "cmp", KeyNormalizer: "norm"}
+	if got == nil || *got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	// 只写了一半的 NextFileNumber 是损坏
+	b.Write([]byte{1, 2, 3})
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err != ErrCorruptManifest {
+		t.Fatalf("expected ErrCorruptManifest, got %v", err)
+	}
+}
@@ -12,17 +12,35 @@ func NewMemTable() *MemTable {
 	return &MemTable{sl: NewSkipList()}
 }
 
+// NewMemTableWithComparer 返回按 cmp 排序的 MemTable。
+func NewMemTableWithComparer(cmp types.Comparer) *MemTable {
+	return &MemTable{sl: NewSkipListWithComparer(cmp)}
+}
+
 // Put 写入/更新：本质是对 SkipList 做 Upsert。
 func (m *MemTable) Put(key string, value []byte) {
+	m.PutWithExpiry(key, value, 0)
+}
+
+// PutWithExpiry 写入/更新一条带过期时间的记录（expiresAt 为 unix 纳秒，0 表示永不过期）。
+// MemTable 本身不判断过期，由上层在读取时处理。
+func (m *MemTable) PutWithExpiry(key string, value []byte, expiresAt int64) {
 	e := types.Entry{
 		Key:       key,
 		Value:     cloneBytes(value),
 		Tombstone: false,
+		ExpiresAt: expiresAt,
 	}
 
 	m.sl.Upsert(key, e)
 }
 
+// PutEntry 写入/覆盖一条完整的记录（包括 tombstone、过期时间和序号），value 会被拷贝。
+func (m *MemTable) PutEntry(e types.Entry) {
+	e.Value = cloneBytes(e.Value)
+	m.sl.Upsert(e.Key, e)
+}
+
 // Get 查询：先从 SkipList.Search 拿到 Entry，再处理 tombstone。
 func (m *MemTable) Get(key string) ([]byte, bool) {
 	e, ok := m.sl.Search(key)
@@ -45,6 +63,12 @@ func (m *MemTable) GetAll(key string) (types.Entry, bool) {
 	return e, true
 }
 
+// GetAllNoCopy 与 GetAll 相同，但返回的 Value 直接引用表内的数据，不做拷贝。
+// 表内的 value 写入后不会再被修改，调用方也不能修改它。
+func (m *MemTable) GetAllNoCopy(key string) (types.Entry, bool) {
+	return m.sl.Search(key)
+}
+
 // Delete 删除：写 tombstone 覆盖
 func (m *MemTable) Delete(key string) {
 	e := types.Entry{
@@ -67,12 +91,14 @@ func (m *MemTable) Range(start, end string) []types.Entry {
 		n = m.sl.FirstGE(start)
 	}
 
-	for n != nil && (end == "" || n.key < end) {
+	for n != nil && (end == "" || m.sl.cmp.Compare(n.key, end) < 0) {
 		if !n.entry.Tombstone {
 			out = append(out, types.Entry{
 				Key:       n.key,
 				Value:     cloneBytes(n.entry.Value),
 				Tombstone: false,
+				ExpiresAt: n.entry.ExpiresAt,
+				Seq:       n.entry.Seq,
 			})
 		}
 		n = n.forward[0]
@@ -84,6 +110,15 @@ func (m *MemTable) Range(start, end string) []types.Entry {
 // RangeAll 范围查询：返回 [start, end) 的有序记录（包含 tombstone）。
 // 用于 Flush 到 SSTable，保证 Delete 也会被持久化。
 func (m *MemTable) RangeAll(start, end string) []types.Entry {
+	return m.rangeAll(start, end, true)
+}
+
+// RangeKeys 与 RangeAll 相同，但不复制 value（返回的 Value 都是 nil），用于只需要 key 的扫描。
+func (m *MemTable) RangeKeys(start, end string) []types.Entry {
+	return m.rangeAll(start, end, false)
+}
+
+func (m *MemTable) rangeAll(start, end string, withValues bool) []types.Entry {
 	var out []types.Entry
 
 	var n *node
@@ -93,13 +128,18 @@ func (m *MemTable) RangeAll(start, end string) []types.Entry {
 		n = m.sl.FirstGE(start)
 	}
 
-	for n != nil && (end == "" || n.key < end) {
+	for n != nil && (end == "" || m.sl.cmp.Compare(n.key, end) < 0) {
 		// 这里不跳过 tombstone
-		out = append(out, types.Entry{
+		e := types.Entry{
 			Key:       n.key,
-			Value:     cloneBytes(n.entry.Value),
 			Tombstone: n.entry.Tombstone,
-		})
+			ExpiresAt: n.entry.ExpiresAt,
+			Seq:       n.entry.Seq,
+		}
+		if withValues {
+			e.Value = cloneBytes(n.entry.Value)
+		}
+		out = append(out, e)
 
 		n = n.forward[0]
 	}
@@ -107,6 +147,73 @@ func (m *MemTable) RangeAll(start, end string) []types.Entry {
 	return out
 }
 
+// Iterator 按 key 升序遍历 MemTable 的记录（包含 tombstone），满足 mergeiter.Iterator。
+// 与 RangeAll 不同，记录在 Entry 时才拷贝：只读取一个范围的开头时不需要拷贝整个范围。
+// 遍历期间 MemTable 不能被修改（调用方持有 DB 的读锁）。
+type Iterator struct {
+	sl         *SkipList
+	n          *node
+	started    bool
+	withValues bool
+}
+
+// NewIterator 返回遍历 m 的 Iterator。withValues 为 false 时 Entry 的 Value 都是 nil（与 RangeKeys 相同）。
+func (m *MemTable) NewIterator(withValues bool) *Iterator {
+	return &Iterator{sl: m.sl, withValues: withValues}
+}
+
+// SeekGE 移动到第一条 key >= key 的记录，key 为空表示第一条记录。
+func (it *Iterator) SeekGE(key string) bool {
+	it.started = true
+	if key == "" {
+		it.n = it.sl.First()
+	} else {
+		it.n = it.sl.FirstGE(key)
+	}
+	return it.n != nil
+}
+
+// Next 移动到下一条记录；还没有定位过时移动到第一条记录。
+func (it *Iterator) Next() bool {
+	if !it.started {
+		return it.SeekGE("")
+	}
+	if it.n != nil {
+		it.n = it.n.forward[0]
+	}
+	return it.n != nil
+}
+
+// Entry 返回当前记录的拷贝。
+func (it *Iterator) Entry() types.Entry {
+	if it.n == nil {
+		return types.Entry{}
+	}
+	e := types.Entry{
+		Key:       it.n.key,
+		Tombstone: it.n.entry.Tombstone,
+		ExpiresAt: it.n.entry.ExpiresAt,
+		Seq:       it.n.entry.Seq,
+	}
+	if it.withValues {
+		e.Value = cloneBytes(it.n.entry.Value)
+	}
+	return e
+}
+
+// Err 总是返回 nil：遍历内存中的 SkipList 不会出错。
+func (it *Iterator) Err() error { return nil }
+
+// Len 返回记录数（包含 tombstone）。
+func (m *MemTable) Len() int {
+	return m.sl.Len()
+}
+
+// ApproximateBytes 返回 key + value 占用的近似字节数，用于统计和刷盘阈值判断。
+func (m *MemTable) ApproximateBytes() int64 {
+	return m.sl.Bytes()
+}
+
 // cloneBytes 防御性拷贝，避免外部修改 slice 影响表内数据。
 func cloneBytes(b []byte) []byte {
 	if b == nil {
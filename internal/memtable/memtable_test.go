@@ -2,7 +2,10 @@ package memtable
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
+
+	"monolithdb/internal/types"
 )
 
 func TestMemTablePutGet(t *testing.T) {
@@ -93,3 +96,64 @@ func TestMemTableReturnsClonedBytes(t *testing.T) {
 		t.Fatalf("expected stored value to remain 'hello' after modifying returned slice, got %q", v2)
 	}
 }
+
+func TestMemTableSizeAccounting(t *testing.T) {
+	m := NewMemTable()
+
+	m.Put("a", []byte("123"))
+	m.Put("bb", []byte("4"))
+	if m.Len() != 2 || m.ApproximateBytes() != int64(1+3+2+1) {
+		t.Fatalf("unexpected size: len=%d bytes=%d", m.Len(), m.ApproximateBytes())
+	}
+
+	// 覆盖写只调整 value 大小，tombstone 也算一条记录
+	m.Put("a", []byte("1"))
+	m.Delete("bb")
+	if m.Len() != 2 || m.ApproximateBytes() != int64(1+1+2) {
+		t.Fatalf("unexpected size after overwrite: len=%d bytes=%d", m.Len(), m.ApproximateBytes())
+	}
+}
+
+func TestMemTableIterator(t *testing.T) {
+	m := NewMemTable()
+	m.Put("c", []byte("3"))
+	m.Put("a", []byte("1"))
+	m.PutEntry(types.Entry{Key: "b", Tombstone: true, Seq: 7})
+	m.Put("d", []byte("4"))
+
+	// 没有 SeekGE 时 Next 从第一条开始，tombstone 也会返回
+	it := m.NewIterator(true)
+	var keys []string
+	for ok := it.Next(); ok; ok = it.Next() {
+		keys = append(keys, it.Entry().Key)
+	}
+	if fmt.Sprint(keys) != "[a b c d]" {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+
+	if !it.SeekGE("b") {
+		t.Fatalf("expected SeekGE(b) to find a record")
+	}
+	if e := it.Entry(); e.Key != "b" || !e.Tombstone || e.Seq != 7 {
+		t.Fatalf("unexpected entry after SeekGE(b): %+v", e)
+	}
+	if !it.Next() || it.Entry().Key != "c" {
+		t.Fatalf("expected c after b, got %+v", it.Entry())
+	}
+
+	// Entry 返回的 value 是拷贝
+	e := it.Entry()
+	e.Value[0] = 'X'
+	if v, _ := m.Get("c"); !bytes.Equal(v, []byte("3")) {
+		t.Fatalf("expected stored value to remain 3, got %q", v)
+	}
+
+	if it.SeekGE("e") {
+		t.Fatalf("expected SeekGE(e) to reach the end")
+	}
+
+	keysOnly := m.NewIterator(false)
+	if !keysOnly.SeekGE("") || keysOnly.Entry().Key != "a" || keysOnly.Entry().Value != nil {
+		t.Fatalf("unexpected keys-only entry: %+v", keysOnly.Entry())
+	}
+}
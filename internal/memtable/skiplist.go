@@ -24,9 +24,18 @@ type SkipList struct {
 	head  *node
 	level int
 	rnd   *rand.Rand
+	cmp   types.Comparer
+
+	count int   // 节点数（包含 tombstone）
+	bytes int64 // key + value 的总字节数（近似内存占用）
 }
 
 func NewSkipList() *SkipList {
+	return NewSkipListWithComparer(types.BytewiseComparer)
+}
+
+// NewSkipListWithComparer 返回按 cmp 排序的跳表。
+func NewSkipListWithComparer(cmp types.Comparer) *SkipList {
 	h := &node{
 		forward: make([]*node, maxLevel),
 	}
@@ -34,6 +43,7 @@ func NewSkipList() *SkipList {
 		head:  h,
 		level: 1,
 		rnd:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		cmp:   cmp,
 	}
 }
 
@@ -50,14 +60,14 @@ func (s *SkipList) Search(key string) (types.Entry, bool) {
 	x := s.head
 
 	for i := s.level - 1; i >= 0; i-- {
-		for x.forward[i] != nil && x.forward[i].key < key {
+		for x.forward[i] != nil && s.cmp.Compare(x.forward[i].key, key) < 0 {
 			x = x.forward[i]
 		}
 	}
 
 	// 最后在第 0 层确认
 	x = x.forward[0]
-	if x != nil && x.key == key {
+	if x != nil && s.cmp.Compare(x.key, key) == 0 {
 		return x.entry, true
 	}
 	return types.Entry{}, false
@@ -69,7 +79,7 @@ func (s *SkipList) Upsert(key string, entry types.Entry) {
 	x := s.head
 	// 找到每层的前驱
 	for i := s.level - 1; i >= 0; i-- {
-		for x.forward[i] != nil && x.forward[i].key < key {
+		for x.forward[i] != nil && s.cmp.Compare(x.forward[i].key, key) < 0 {
 			x = x.forward[i]
 		}
 		update[i] = x
@@ -77,7 +87,8 @@ func (s *SkipList) Upsert(key string, entry types.Entry) {
 
 	// 检查 level0 的下一个是不是目标 key
 	x = x.forward[0]
-	if x != nil && x.key == key {
+	if x != nil && s.cmp.Compare(x.key, key) == 0 {
+		s.bytes += int64(len(entry.Value)) - int64(len(x.entry.Value))
 		x.entry = entry
 		return
 	}
@@ -102,6 +113,19 @@ func (s *SkipList) Upsert(key string, entry types.Entry) {
 		newNode.forward[i] = update[i].forward[i]
 		update[i].forward[i] = newNode
 	}
+
+	s.count++
+	s.bytes += int64(len(key) + len(entry.Value))
+}
+
+// Len 返回节点数。
+func (s *SkipList) Len() int {
+	return s.count
+}
+
+// Bytes 返回所有 key + value 的总字节数。
+func (s *SkipList) Bytes() int64 {
+	return s.bytes
 }
 
 func (s *SkipList) First() *node {
@@ -113,7 +137,7 @@ func (s *SkipList) FirstGE(target string) *node {
 	x := s.head
 
 	for i := s.level - 1; i >= 0; i-- {
-		for x.forward[i] != nil && x.forward[i].key < target {
+		for x.forward[i] != nil && s.cmp.Compare(x.forward[i].key, target) < 0 {
 			x = x.forward[i]
 		}
 	}
@@ -0,0 +1,206 @@
+// Package mergeiter 把多个有序的迭代器（MemTable、SST 等）归并成一个：按 key 升序，
+// 同一个 key 只保留最新的版本。DB 的范围读取和 compaction 共用它。
+package mergeiter
+
+import (
+	"container/heap"
+	"sort"
+
+	"monolithdb/internal/types"
+)
+
+// Iterator 是一个按 key 升序、key 不重复的有序迭代器。sstable.Iterator 直接满足这个接口。
+//
+// 新建的 Iterator 位于第一条记录之前，Next 移动到第一条记录；SeekGE 移动到第一条 key >= key 的记录，
+// key 为空表示第一条。两者返回 false 时由 Err 区分读完和出错。
+type Iterator interface {
+	SeekGE(key string) bool
+	Next() bool
+	Entry() types.Entry
+	Err() error
+}
+
+// Options 控制 Merger 的行为。零值即默认配置。
+type Options struct {
+	// Comparer 是所有子迭代器的排序方式，nil 表示 types.BytewiseComparer。
+	Comparer types.Comparer
+
+	// KeepTombstones 为 true 时最新版本是 tombstone 的 key 也会返回（compaction 需要用它遮住更旧的表），
+	// 默认跳过。
+	KeepTombstones bool
+}
+
+// Merger 按 key 升序归并子迭代器，同一个 key 只返回一次：取序号最大的版本；
+// 序号相同、或者较新的版本没有序号时取更新的子迭代器（children 中下标更小的）里的版本，
+// 与 DB 点查的规则一致。Merger 本身也是一个 Iterator，不是并发安全的。
+type Merger struct {
+	children []Iterator
+	opts     Options
+	cmp      types.Comparer
+
+	h       mergeHeap
+	cur     types.Entry
+	ok      bool
+	started bool
+	err     error
+}
+
+// New 返回归并 children 的 Merger。children 按从新到旧排列。
+func New(children []Iterator, opts Options) *Merger {
+	cmp := opts.Comparer
+	if cmp == nil {
+		cmp = types.BytewiseComparer
+	}
+	return &Merger{children: children, opts: opts, cmp: cmp, h: mergeHeap{cmp: cmp}}
+}
+
+// SeekGE 把所有子迭代器移动到第一条 key >= key 的记录，再返回归并后的第一条。
+func (m *Merger) SeekGE(key string) bool {
+	m.started, m.ok, m.err = true, false, nil
+	m.h.items = m.h.items[:0]
+	for i, c := range m.children {
+		if c.SeekGE(key) {
+			m.h.items = append(m.h.items, heapItem{e: c.Entry(), child: i})
+		} else if err := c.Err(); err != nil {
+			m.err = err
+			return false
+		}
+	}
+	heap.Init(&m.h)
+	return m.next()
+}
+
+// Next 移动到下一个 key。还没有定位时移动到第一个 key。
+func (m *Merger) Next() bool {
+	if !m.started {
+		return m.SeekGE("")
+	}
+	if m.err != nil {
+		return false
+	}
+	return m.next()
+}
+
+// Valid 返回当前是否位于一条记录上。
+func (m *Merger) Valid() bool { return m.ok }
+
+// Entry 返回当前 key 的最新版本。
+func (m *Merger) Entry() types.Entry { return m.cur }
+
+// Err 返回子迭代器遇到的第一个错误。
+func (m *Merger) Err() error { return m.err }
+
+// next 弹出最小的 key 在所有子迭代器里的版本，选出最新的一个。
+func (m *Merger) next() bool {
+	m.cur, m.ok = types.Entry{}, false
+	for m.h.Len() > 0 {
+		// 堆里相同 key 按子迭代器从新到旧排列，第一个弹出的是最新来源的版本
+		best := m.h.items[0].e
+		for m.h.Len() > 0 && m.cmp.Compare(m.h.items[0].e.Key, best.Key) == 0 {
+			e := m.h.items[0].e
+			if best.Seq != 0 && e.Seq > best.Seq {
+				best = e
+			}
+			if !m.advance() {
+				return false
+			}
+		}
+		if best.Tombstone && !m.opts.KeepTombstones {
+			continue
+		}
+		m.cur, m.ok = best, true
+		return true
+	}
+	return false
+}
+
+// advance 让堆顶的子迭代器前进一条。子迭代器出错时返回 false。
+func (m *Merger) advance() bool {
+	top := &m.h.items[0]
+	c := m.children[top.child]
+	if c.Next() {
+		top.e = c.Entry()
+		heap.Fix(&m.h, 0)
+		return true
+	}
+	if err := c.Err(); err != nil {
+		m.err = err
+		return false
+	}
+	heap.Pop(&m.h)
+	return true
+}
+
+type heapItem struct {
+	e     types.Entry
+	child int
+}
+
+type mergeHeap struct {
+	items []heapItem
+	cmp   types.Comparer
+}
+
+func (h *mergeHeap) Len() int { return len(h.items) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	if c := h.cmp.Compare(h.items[i].e.Key, h.items[j].e.Key); c != 0 {
+		return c < 0
+	}
+	return h.items[i].child < h.items[j].child
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *mergeHeap) Push(x any) { h.items = append(h.items, x.(heapItem)) }
+
+func (h *mergeHeap) Pop() any {
+	it := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return it
+}
+
+// SliceIterator 遍历一组已经按 key 升序排列、key 不重复的记录（例如 MemTable.RangeAll 的结果）。
+type SliceIterator struct {
+	entries []types.Entry
+	cmp     types.Comparer
+	i       int
+}
+
+// NewSliceIterator 返回遍历 entries 的 Iterator，cmp 为 nil 表示 types.BytewiseComparer。
+func NewSliceIterator(entries []types.Entry, cmp types.Comparer) *SliceIterator {
+	if cmp == nil {
+		cmp = types.BytewiseComparer
+	}
+	return &SliceIterator{entries: entries, cmp: cmp, i: -1}
+}
+
+// SeekGE 移动到第一条 key >= key 的记录，key 为空表示第一条（与 sstable.Iterator 一致，
+// 自定义比较器下 "" 不一定排在最前面）。
+func (s *SliceIterator) SeekGE(key string) bool {
+	if key == "" {
+		s.i = 0
+		return s.i < len(s.entries)
+	}
+	s.i = sort.Search(len(s.entries), func(i int) bool { return s.cmp.Compare(s.entries[i].Key, key) >= 0 })
+	return s.i < len(s.entries)
+}
+
+// Next 移动到下一条记录。
+func (s *SliceIterator) Next() bool {
+	if s.i < len(s.entries) {
+		s.i++
+	}
+	return s.i < len(s.entries)
+}
+
+// Entry 返回当前记录。
+func (s *SliceIterator) Entry() types.Entry {
+	if s.i < 0 || s.i >= len(s.entries) {
+		return types.Entry{}
+	}
+	return s.entries[s.i]
+}
+
+// Err 总是返回 nil。
+func (s *SliceIterator) Err() error { return nil }
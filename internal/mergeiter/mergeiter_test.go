@@ -0,0 +1,147 @@
+package mergeiter
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"monolithdb/internal/types"
+)
+
+func collect(t *testing.T, m *Merger, ok bool) []types.Entry {
+	t.Helper()
+	var out []types.Entry
+	for ; ok; ok = m.Next() {
+		out = append(out, m.Entry())
+	}
+	if err := m.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func keysOf(entries []types.Entry) []string {
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i] = e.Key
+	}
+	return keys
+}
+
+func TestMergerNewestWins(t *testing.T) {
+	newest := []types.Entry{{Key: "a", Value: []byte("a2")}, {Key: "c", Tombstone: true}, {Key: "e", Value: []byte("e2")}}
+	older := []types.Entry{{Key: "a", Value: []byte("a1")}, {Key: "b", Value: []byte("b1")}, {Key: "c", Value: []byte("c1")}, {Key: "d", Value: []byte("d1")}}
+	children := func() []Iterator {
+		return []Iterator{NewSliceIterator(newest, nil), NewSliceIterator(older, nil)}
+	}
+
+	m := New(children(), Options{})
+	got := collect(t, m, m.Next())
+	if fmt.Sprint(keysOf(got)) != "[a b d e]" || string(got[0].Value) != "a2" {
+		t.Fatalf("got %+v", got)
+	}
+
+	// KeepTombstones 时 c 的 tombstone 遮住更旧的 c1
+	m = New(children(), Options{KeepTombstones: true})
+	got = collect(t, m, m.Next())
+	if fmt.Sprint(keysOf(got)) != "[a b c d e]" || !got[2].Tombstone {
+		t.Fatalf("with tombstones: got %+v", got)
+	}
+
+	m = New(children(), Options{})
+	got = collect(t, m, m.SeekGE("bb"))
+	if fmt.Sprint(keysOf(got)) != "[d e]" {
+		t.Fatalf("SeekGE(bb): got %+v", got)
+	}
+}
+
+func TestMergerSequenceNumbers(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		newer, older  uint64
+		wantNewerWins bool
+	}{
+		{"no seqs", 0, 0, true},
+		{"newer has higher seq", 5, 3, true},
+		{"older has higher seq", 3, 5, false}, // 例如编号更大的表是较早 ingest 的
+		{"newer without seq", 0, 5, true},     // 与点查一致：没有序号的版本按来源顺序
+		{"older without seq", 5, 0, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := New([]Iterator{
+				NewSliceIterator([]types.Entry{{Key: "k", Value: []byte("newer"), Seq: tc.newer}}, nil),
+				NewSliceIterator([]types.Entry{{Key: "k", Value: []byte("older"), Seq: tc.older}}, nil),
+			}, Options{})
+			if !m.Next() {
+				t.Fatal(m.Err())
+			}
+			if got := string(m.Entry().Value) == "newer"; got != tc.wantNewerWins {
+				t.Fatalf("got %q", m.Entry().Value)
+			}
+		})
+	}
+}
+
+func TestMergerMatchesMap(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	const nChildren = 6
+	children := make([]Iterator, nChildren)
+	want := map[string]types.Entry{}
+	for i := nChildren - 1; i >= 0; i-- { // oldest first，后面的覆盖前面的
+		seen := map[string]bool{}
+		var entries []types.Entry
+		for j := 0; j < 200; j++ {
+			k := fmt.Sprintf("k%03d", rnd.Intn(500))
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			e := types.Entry{Key: k, Value: []byte(fmt.Sprintf("%s@%d", k, i)), Tombstone: rnd.Intn(5) == 0}
+			entries = append(entries, e)
+			want[k] = e
+		}
+		sort.Slice(entries, func(a, b int) bool { return entries[a].Key < entries[b].Key })
+		children[i] = NewSliceIterator(entries, nil)
+	}
+
+	var wantKeys []string
+	for k, e := range want {
+		if !e.Tombstone {
+			wantKeys = append(wantKeys, k)
+		}
+	}
+	sort.Strings(wantKeys)
+
+	m := New(children, Options{})
+	got := collect(t, m, m.Next())
+	if fmt.Sprint(keysOf(got)) != fmt.Sprint(wantKeys) {
+		t.Fatalf("got %d keys, want %d", len(got), len(wantKeys))
+	}
+	for _, e := range got {
+		if string(e.Value) != string(want[e.Key].Value) {
+			t.Fatalf("%s = %q, want %q", e.Key, e.Value, want[e.Key].Value)
+		}
+	}
+}
+
+type failingIterator struct{ SliceIterator }
+
+var errChild = errors.New("child failed")
+
+func (f *failingIterator) Next() bool { return false }
+func (f *failingIterator) Err() error {
+	if f.i >= 0 {
+		return errChild
+	}
+	return nil
+}
+
+func TestMergerChildError(t *testing.T) {
+	bad := &failingIterator{*NewSliceIterator([]types.Entry{{Key: "a"}, {Key: "b"}}, nil)}
+	m := New([]Iterator{NewSliceIterator([]types.Entry{{Key: "c"}}, nil), bad}, Options{})
+	if m.Next() || !errors.Is(m.Err(), errChild) {
+		t.Fatalf("Next = %v, err %v; want errChild", m.Valid(), m.Err())
+	}
+}
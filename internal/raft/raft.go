@@ -0,0 +1,644 @@
+// Package raft 是一个内置的 Raft 共识层：写入先作为日志复制到多数派节点，
+// 提交后再通过 db.ApplyRecord 应用到每个节点自己的 DB，从而在 3 个及以上节点之间
+// 提供线性一致的读写和自动选主。
+//
+// 目前的限制：
+//   - 成员是静态配置的（Config.Peers），不支持在线增删节点；
+//   - 没有日志压缩 / InstallSnapshot，日志会一直保留；
+//   - 重启后从上次持久化的已应用位置继续重放日志（记录幂等，所以重放是安全的）。
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"monolithdb/internal/db"
+	"monolithdb/internal/wal"
+)
+
+var (
+	// ErrNotLeader 表示当前节点不是 leader（或者在提交前失去了 leader 身份）。
+	// 调用方应该通过 Leader() 找到 leader 后重试。
+	ErrNotLeader = errors.New("raft: not leader")
+
+	// ErrClosed 表示节点已经关闭。
+	ErrClosed = errors.New("raft: node closed")
+)
+
+// maxAppendEntries 是一次 AppendEntries 最多携带的日志条数。
+const maxAppendEntries = 256
+
+// Config 是节点配置。
+type Config struct {
+	// ID 是本节点的 ID，必须出现在 Peers 中。
+	ID string
+	// Peers 是集群全部节点（包括自己）：ID -> 基础 URL（例如 http://10.0.0.1:7071），
+	// 每个节点都要把 Node.Handler() 挂在这个 URL 下。
+	Peers map[string]string
+	// Dir 保存 Raft 日志和元数据，不能与数据 DB 的目录相同。
+	Dir string
+
+	// HeartbeatInterval 是 leader 发送心跳的间隔，默认 50ms。
+	HeartbeatInterval time.Duration
+	// ElectionTimeout 是 follower 多久收不到心跳就发起选举，实际取 [T, 2T) 内的随机值，默认 500ms。
+	ElectionTimeout time.Duration
+	// Client 用于节点之间的 RPC，默认 http.DefaultClient。
+	Client *http.Client
+}
+
+type role int
+
+const (
+	follower role = iota
+	candidate
+	leader
+)
+
+// Node 是 Raft 集群中的一个节点。
+type Node struct {
+	cfg    Config
+	d      *db.DB
+	st     *storage
+	client *http.Client
+	quorum int
+
+	mu       sync.Mutex
+	cond     *sync.Cond // 提交 / 应用位置、角色变化或关闭时广播
+	role     role
+	term     uint64
+	votedFor string
+	leaderID string
+	log      []entry // log[i] 是 index 为 i 的条目，log[0] 是哨兵
+
+	commitIndex uint64
+	lastApplied uint64
+
+	// 以下只在 leader 上使用
+	nextIndex     map[string]uint64
+	matchIndex    map[string]uint64
+	inflight      map[string]bool
+	round         uint64            // 每次广播加一，用于读屏障确认 leader 身份
+	ackRound      map[string]uint64 // 每个 peer 最近一次成功响应的广播轮次
+	nextHeartbeat time.Time
+
+	electionDeadline time.Time
+	closed           bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Open 启动一个节点，d 是要被复制的数据 DB。
+// d 只应该通过 Node 写入，直接写入 d 的数据不会复制到其他节点。
+func Open(d *db.DB, cfg Config) (*Node, error) {
+	if _, ok := cfg.Peers[cfg.ID]; !ok {
+		return nil, fmt.Errorf("raft: node %q is not in peers", cfg.ID)
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = 50 * time.Millisecond
+	}
+	if cfg.ElectionTimeout <= 0 {
+		cfg.ElectionTimeout = 500 * time.Millisecond
+	}
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	st, err := openStorage(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	term, vote, applied, entries, err := st.load()
+	if err != nil {
+		_ = st.close()
+		return nil, err
+	}
+	if applied >= uint64(len(entries)) {
+		_ = st.close()
+		return nil, fmt.Errorf("raft: applied index %d beyond log end %d", applied, len(entries)-1)
+	}
+
+	n := &Node{
+		cfg:         cfg,
+		d:           d,
+		st:          st,
+		client:      client,
+		quorum:      len(cfg.Peers)/2 + 1,
+		term:        term,
+		votedFor:    vote,
+		log:         entries,
+		commitIndex: applied,
+		lastApplied: applied,
+		stop:        make(chan struct{}),
+	}
+	n.cond = sync.NewCond(&n.mu)
+	n.resetElectionLocked()
+
+	n.wg.Add(2)
+	go n.run()
+	go n.applyLoop()
+	return n, nil
+}
+
+// Close 停止节点并关闭 Raft 存储（不会关闭数据 DB）。
+func (n *Node) Close() error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.closed = true
+	n.cond.Broadcast()
+	n.mu.Unlock()
+
+	close(n.stop)
+	n.wg.Wait()
+	return n.st.close()
+}
+
+// Leader 返回当前已知的 leader ID，未知时为空。
+func (n *Node) Leader() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.leaderID
+}
+
+// IsLeader 报告本节点当前是否是 leader。
+func (n *Node) IsLeader() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.role == leader
+}
+
+// ---- 写入 / 读取 ----
+
+// Put 通过 Raft 写入 key；返回 nil 时写入已被多数派持久化并在本节点应用。
+func (n *Node) Put(ctx context.Context, key string, value []byte) error {
+	return n.Propose(ctx, wal.Record{Op: wal.OpPut, Key: key, Value: value})
+}
+
+// PutWithTTL 通过 Raft 写入带过期时间的 key，过期时间在 leader 上换算成绝对时间。
+func (n *Node) PutWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return n.Put(ctx, key, value)
+	}
+	return n.Propose(ctx, wal.Record{Op: wal.OpPutTTL, Key: key, Value: value, ExpiresAt: time.Now().Add(ttl).UnixNano()})
+}
+
+// Delete 通过 Raft 删除 key。
+func (n *Node) Delete(ctx context.Context, key string) error {
+	return n.Propose(ctx, wal.Record{Op: wal.OpDelete, Key: key})
+}
+
+// Propose 把一条记录追加到 Raft 日志，等待它提交并在本节点应用。
+// 只能在 leader 上调用，否则返回 ErrNotLeader。
+func (n *Node) Propose(ctx context.Context, rec wal.Record) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return ErrClosed
+	}
+	if n.role != leader {
+		return ErrNotLeader
+	}
+
+	term := n.term
+	index := n.lastIndexLocked() + 1
+	if err := n.appendLocked(index, []entry{{Term: term, Rec: &rec}}); err != nil {
+		return err
+	}
+	n.broadcastLocked()
+	n.advanceCommitLocked()
+
+	// 条目被新 leader 覆盖时提前返回
+	replaced := func() bool {
+		return uint64(len(n.log)) <= index || n.log[index].Term != term
+	}
+	if err := n.waitLocked(ctx, func() bool { return n.lastApplied >= index || replaced() }); err != nil {
+		return err
+	}
+	if replaced() {
+		return ErrNotLeader
+	}
+	return nil
+}
+
+// Get 线性一致地读取 key：先确认本节点仍是 leader 且状态机已追上提交位置，再读本地 DB。
+func (n *Node) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if err := n.ReadBarrier(ctx); err != nil {
+		return nil, false, err
+	}
+	return n.d.Get(key)
+}
+
+// ReadBarrier 等到本节点上的读取可以保证线性一致：
+// 本节点是 leader、已提交过本 term 的条目、多数派确认了 leader 身份，
+// 并且状态机已经应用到确认时的提交位置。
+func (n *Node) ReadBarrier(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.role != leader {
+		return ErrNotLeader
+	}
+	term := n.term
+	lost := func() bool { return n.closed || n.role != leader || n.term != term }
+
+	// 上任时写入的空操作提交后，commitIndex 才包含之前 term 的所有已提交条目
+	if err := n.waitLocked(ctx, func() bool { return lost() || n.log[n.commitIndex].Term == term }); err != nil {
+		return err
+	}
+	if lost() {
+		return ErrNotLeader
+	}
+	readIndex := n.commitIndex
+
+	target := n.round + 1
+	n.broadcastLocked()
+	confirmed := func() bool {
+		acks := 1
+		for p := range n.cfg.Peers {
+			if p != n.cfg.ID && n.ackRound[p] >= target {
+				acks++
+			}
+		}
+		return acks >= n.quorum
+	}
+	if err := n.waitLocked(ctx, func() bool { return lost() || confirmed() }); err != nil {
+		return err
+	}
+	if lost() {
+		return ErrNotLeader
+	}
+
+	return n.waitLocked(ctx, func() bool { return n.lastApplied >= readIndex })
+}
+
+// waitLocked 在持有 n.mu 的情况下等待 done 成立，ctx 结束或节点关闭时返回错误。
+func (n *Node) waitLocked(ctx context.Context, done func() bool) error {
+	stop := context.AfterFunc(ctx, func() {
+		n.mu.Lock()
+		n.cond.Broadcast()
+		n.mu.Unlock()
+	})
+	defer stop()
+
+	for !done() {
+		if n.closed {
+			return ErrClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n.cond.Wait()
+	}
+	return nil
+}
+
+// ---- 日志 ----
+
+func (n *Node) lastIndexLocked() uint64 { return uint64(len(n.log) - 1) }
+
+func (n *Node) lastTermLocked() uint64 { return n.log[len(n.log)-1].Term }
+
+// appendLocked 用 entries 替换从 from 开始的日志（先持久化，再更新内存）。
+func (n *Node) appendLocked(from uint64, entries []entry) error {
+	if err := n.st.replace(from, n.lastIndexLocked(), entries); err != nil {
+		return err
+	}
+	n.log = append(n.log[:from], entries...)
+	return nil
+}
+
+// ---- 角色切换 ----
+
+func (n *Node) resetElectionLocked() {
+	t := n.cfg.ElectionTimeout
+	n.electionDeadline = time.Now().Add(t + rand.N(t))
+}
+
+// stepDownLocked 在看到更大的 term 时转为 follower。
+func (n *Node) stepDownLocked(term uint64) error {
+	if term > n.term {
+		if err := n.st.setTermVote(term, ""); err != nil {
+			return err
+		}
+		n.term, n.votedFor, n.leaderID = term, "", ""
+	}
+	if n.role != follower {
+		n.role = follower
+		n.cond.Broadcast()
+	}
+	return nil
+}
+
+func (n *Node) startElectionLocked() {
+	if err := n.st.setTermVote(n.term+1, n.cfg.ID); err != nil {
+		log.Printf("raft: %s: persist vote: %v", n.cfg.ID, err)
+		n.resetElectionLocked()
+		return
+	}
+	n.term++
+	n.votedFor = n.cfg.ID
+	n.role = candidate
+	n.leaderID = ""
+	n.resetElectionLocked()
+
+	term := n.term
+	args := voteArgs{Term: term, CandidateID: n.cfg.ID, LastLogIndex: n.lastIndexLocked(), LastLogTerm: n.lastTermLocked()}
+	votes := 1
+	if votes >= n.quorum {
+		n.becomeLeaderLocked()
+		return
+	}
+
+	for p := range n.cfg.Peers {
+		if p == n.cfg.ID {
+			continue
+		}
+		go func(p string) {
+			var reply voteReply
+			if err := n.call(p, votePath, args, &reply); err != nil {
+				return
+			}
+
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			if n.closed {
+				return
+			}
+			if reply.Term > n.term {
+				_ = n.stepDownLocked(reply.Term)
+				return
+			}
+			if n.role != candidate || n.term != term || !reply.Granted {
+				return
+			}
+			votes++
+			if votes >= n.quorum {
+				n.becomeLeaderLocked()
+			}
+		}(p)
+	}
+}
+
+func (n *Node) becomeLeaderLocked() {
+	n.role = leader
+	n.leaderID = n.cfg.ID
+	n.nextIndex = make(map[string]uint64)
+	n.matchIndex = make(map[string]uint64)
+	n.inflight = make(map[string]bool)
+	n.ackRound = make(map[string]uint64)
+	for p := range n.cfg.Peers {
+		n.nextIndex[p] = n.lastIndexLocked() + 1
+	}
+
+	// 写一条空操作：只有提交了本 term 的条目，之前 term 的条目才算确定提交
+	if err := n.appendLocked(n.lastIndexLocked()+1, []entry{{Term: n.term}}); err != nil {
+		log.Printf("raft: %s: append noop: %v", n.cfg.ID, err)
+		_ = n.stepDownLocked(n.term)
+		return
+	}
+	n.broadcastLocked()
+	n.advanceCommitLocked()
+	n.cond.Broadcast()
+}
+
+// ---- 复制 ----
+
+// run 驱动选举超时和心跳。
+func (n *Node) run() {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.stop:
+			return
+		case <-ticker.C:
+		}
+
+		n.mu.Lock()
+		now := time.Now()
+		switch {
+		case n.role == leader:
+			if !now.Before(n.nextHeartbeat) {
+				n.broadcastLocked()
+			}
+		case now.After(n.electionDeadline):
+			n.startElectionLocked()
+		}
+		n.mu.Unlock()
+	}
+}
+
+// broadcastLocked 向每个没有在途请求的 peer 发送 AppendEntries（没有新条目时就是心跳）。
+func (n *Node) broadcastLocked() {
+	n.round++
+	n.nextHeartbeat = time.Now().Add(n.cfg.HeartbeatInterval)
+
+	for p := range n.cfg.Peers {
+		if p == n.cfg.ID || n.inflight[p] {
+			continue
+		}
+		prev := n.nextIndex[p] - 1
+		end := min(n.lastIndexLocked(), prev+maxAppendEntries)
+		args := appendArgs{
+			Term:         n.term,
+			LeaderID:     n.cfg.ID,
+			PrevLogIndex: prev,
+			PrevLogTerm:  n.log[prev].Term,
+			Entries:      append([]entry(nil), n.log[prev+1:end+1]...),
+			LeaderCommit: n.commitIndex,
+		}
+		n.inflight[p] = true
+		go n.sendAppend(p, args, n.round)
+	}
+}
+
+func (n *Node) sendAppend(p string, args appendArgs, round uint64) {
+	var reply appendReply
+	err := n.call(p, appendPath, args, &reply)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	if n.role != leader || n.term != args.Term {
+		if err == nil && reply.Term > n.term {
+			_ = n.stepDownLocked(reply.Term)
+		}
+		return
+	}
+	n.inflight[p] = false
+	if err != nil {
+		return
+	}
+	if reply.Term > n.term {
+		_ = n.stepDownLocked(reply.Term)
+		return
+	}
+
+	n.ackRound[p] = max(n.ackRound[p], round)
+	if reply.Success {
+		match := args.PrevLogIndex + uint64(len(args.Entries))
+		n.matchIndex[p] = max(n.matchIndex[p], match)
+		n.nextIndex[p] = n.matchIndex[p] + 1
+		n.advanceCommitLocked()
+	} else {
+		n.nextIndex[p] = max(1, min(args.PrevLogIndex, reply.LastIndex+1))
+	}
+	n.cond.Broadcast()
+
+	// 还有没发完的条目就立即继续
+	if n.nextIndex[p] <= n.lastIndexLocked() {
+		n.broadcastLocked()
+	}
+}
+
+// advanceCommitLocked 把 commitIndex 推进到多数派都已复制、且属于当前 term 的最大 index。
+func (n *Node) advanceCommitLocked() {
+	for i := n.lastIndexLocked(); i > n.commitIndex; i-- {
+		if n.log[i].Term != n.term {
+			break // 之前 term 的条目不能直接按计数提交
+		}
+		count := 1
+		for p := range n.cfg.Peers {
+			if p != n.cfg.ID && n.matchIndex[p] >= i {
+				count++
+			}
+		}
+		if count >= n.quorum {
+			n.commitIndex = i
+			n.cond.Broadcast()
+			return
+		}
+	}
+}
+
+// ---- RPC 处理 ----
+
+func (n *Node) handleVote(args voteArgs) voteReply {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return voteReply{Term: n.term}
+	}
+	if args.Term > n.term {
+		if err := n.stepDownLocked(args.Term); err != nil {
+			return voteReply{Term: n.term}
+		}
+	}
+	if args.Term < n.term {
+		return voteReply{Term: n.term}
+	}
+
+	upToDate := args.LastLogTerm > n.lastTermLocked() ||
+		(args.LastLogTerm == n.lastTermLocked() && args.LastLogIndex >= n.lastIndexLocked())
+	if (n.votedFor != "" && n.votedFor != args.CandidateID) || !upToDate {
+		return voteReply{Term: n.term}
+	}
+
+	if err := n.st.setTermVote(n.term, args.CandidateID); err != nil {
+		return voteReply{Term: n.term}
+	}
+	n.votedFor = args.CandidateID
+	n.resetElectionLocked()
+	return voteReply{Term: n.term, Granted: true}
+}
+
+func (n *Node) handleAppend(args appendArgs) (appendReply, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return appendReply{}, ErrClosed
+	}
+	if args.Term < n.term {
+		return appendReply{Term: n.term}, nil
+	}
+	if err := n.stepDownLocked(args.Term); err != nil {
+		return appendReply{}, err
+	}
+	n.leaderID = args.LeaderID
+	n.resetElectionLocked()
+
+	last := n.lastIndexLocked()
+	if args.PrevLogIndex > last {
+		return appendReply{Term: n.term, LastIndex: last}, nil
+	}
+	if n.log[args.PrevLogIndex].Term != args.PrevLogTerm {
+		return appendReply{Term: n.term, LastIndex: args.PrevLogIndex - 1}, nil
+	}
+
+	// 跳过已经有的条目，从第一个冲突（或缺失）的位置开始覆盖
+	for i, e := range args.Entries {
+		index := args.PrevLogIndex + 1 + uint64(i)
+		if index <= n.lastIndexLocked() && n.log[index].Term == e.Term {
+			continue
+		}
+		if index <= n.commitIndex {
+			return appendReply{}, fmt.Errorf("raft: leader %s tried to overwrite committed index %d", args.LeaderID, index)
+		}
+		if err := n.appendLocked(index, args.Entries[i:]); err != nil {
+			return appendReply{}, err
+		}
+		break
+	}
+
+	if args.LeaderCommit > n.commitIndex {
+		n.commitIndex = min(args.LeaderCommit, args.PrevLogIndex+uint64(len(args.Entries)))
+		n.cond.Broadcast()
+	}
+	return appendReply{Term: n.term, Success: true}, nil
+}
+
+// ---- 应用 ----
+
+// applyLoop 把已提交的条目按顺序应用到数据 DB。
+func (n *Node) applyLoop() {
+	defer n.wg.Done()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for !n.closed {
+		if n.lastApplied >= n.commitIndex {
+			n.cond.Wait()
+			continue
+		}
+
+		index := n.lastApplied + 1
+		e := n.log[index]
+		n.mu.Unlock()
+		var err error
+		if e.Rec != nil {
+			err = n.d.ApplyRecord(*e.Rec)
+		}
+		if err == nil {
+			err = n.st.setApplied(index)
+		}
+		n.mu.Lock()
+
+		if err != nil {
+			// 应用失败说明本地存储出了问题，停止应用，等待人工介入
+			log.Printf("raft: %s: apply index %d: %v", n.cfg.ID, index, err)
+			for !n.closed {
+				n.cond.Wait()
+			}
+			return
+		}
+		n.lastApplied = index
+		n.cond.Broadcast()
+	}
+}
@@ -0,0 +1,298 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"monolithdb/internal/db"
+	"monolithdb/internal/vfs"
+)
+
+// cluster 是测试用的进程内集群：每个节点一个 httptest.Server，可以单独“断网”。
+type cluster struct {
+	t     *testing.T
+	ids   []string
+	nodes map[string]*Node
+	dbs   map[string]*db.DB
+
+	mu   sync.Mutex
+	down map[string]bool
+}
+
+func newCluster(t *testing.T, size int) *cluster {
+	t.Helper()
+	c := &cluster{t: t, nodes: map[string]*Node{}, dbs: map[string]*db.DB{}, down: map[string]bool{}}
+
+	peers := map[string]string{}
+	servers := map[string]*httptest.Server{}
+	for i := 0; i < size; i++ {
+		id := fmt.Sprintf("n%d", i)
+		c.ids = append(c.ids, id)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.mu.Lock()
+			down, n := c.down[id], c.nodes[id]
+			c.mu.Unlock()
+			if down || n == nil {
+				http.Error(w, "down", http.StatusServiceUnavailable)
+				return
+			}
+			n.Handler().ServeHTTP(w, r)
+		}))
+		t.Cleanup(srv.Close)
+		servers[id] = srv
+		peers[id] = srv.URL
+	}
+
+	for _, id := range c.ids {
+		dir := t.TempDir()
+		d, err := db.Open(filepath.Join(dir, "data"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := Open(d, Config{
+			ID:                id,
+			Peers:             peers,
+			Dir:               filepath.Join(dir, "raft"),
+			HeartbeatInterval: 20 * time.Millisecond,
+			ElectionTimeout:   150 * time.Millisecond,
+			Client:            &http.Client{Transport: downTransport{c: c, id: id}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.mu.Lock()
+		c.nodes[id], c.dbs[id] = n, d
+		c.mu.Unlock()
+	}
+	t.Cleanup(func() {
+		for _, id := range c.ids {
+			_ = c.nodes[id].Close()
+			_ = c.dbs[id].Close()
+		}
+	})
+	return c
+}
+
+// downTransport 在节点被“断网”时让它发出的 RPC 直接失败。
+type downTransport struct {
+	c  *cluster
+	id string
+}
+
+func (tr downTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	tr.c.mu.Lock()
+	down := tr.c.down[tr.id]
+	tr.c.mu.Unlock()
+	if down {
+		return nil, errors.New("network down")
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// setDown 让节点收不到、也发不出 RPC。
+func (c *cluster) setDown(id string, down bool) {
+	c.mu.Lock()
+	c.down[id] = down
+	c.mu.Unlock()
+}
+
+// waitLeader 等待在线节点中选出唯一的 leader。
+func (c *cluster) waitLeader(exclude string) *Node {
+	c.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var leaders []string
+		for _, id := range c.ids {
+			if id != exclude && c.nodes[id].IsLeader() {
+				leaders = append(leaders, id)
+			}
+		}
+		if len(leaders) == 1 {
+			return c.nodes[leaders[0]]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.t.Fatalf("no single leader elected")
+	return nil
+}
+
+func (c *cluster) waitValue(id, key, want string) {
+	c.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		v, ok, err := c.dbs[id].Get(key)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		if ok && string(v) == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.t.Fatalf("%s: %s never became %q", id, key, want)
+}
+
+func TestReplicatedWritesAndFailover(t *testing.T) {
+	c := newCluster(t, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	l := c.waitLeader("")
+	if err := l.Put(ctx, "a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range c.ids {
+		c.waitValue(id, "a", "1")
+	}
+
+	// follower 上写入返回 ErrNotLeader
+	for _, id := range c.ids {
+		if n := c.nodes[id]; n != l {
+			if err := n.Put(ctx, "x", nil); !errors.Is(err, ErrNotLeader) {
+				t.Fatalf("put on follower: %v", err)
+			}
+			break
+		}
+	}
+
+	v, ok, err := l.Get(ctx, "a")
+	if err != nil || !ok || string(v) != "1" {
+		t.Fatalf("linearizable get: %q %v %v", v, ok, err)
+	}
+
+	// leader 断网后剩下两个节点选出新 leader，并继续提供写入
+	oldID := l.cfg.ID
+	c.setDown(oldID, true)
+	l2 := c.waitLeader(oldID)
+	if err := l2.Put(ctx, "b", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 旧 leader 无法确认身份，读屏障不能成功
+	shortCtx, shortCancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	if _, _, err := l.Get(shortCtx, "a"); err == nil {
+		t.Fatalf("stale leader served a read")
+	}
+	shortCancel()
+
+	// 旧 leader 恢复后追上新日志
+	c.setDown(oldID, false)
+	c.waitValue(oldID, "b", "2")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok, _ := c.dbs[oldID].Get("a"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delete of a never reached %s", oldID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRestartReplaysFromStorage(t *testing.T) {
+	dir := t.TempDir()
+	d, err := db.Open(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+
+	cfg := Config{ID: "solo", Peers: map[string]string{"solo": "http://unused"}, Dir: filepath.Join(dir, "raft"),
+		ElectionTimeout: 50 * time.Millisecond}
+	ctx := context.Background()
+
+	n, err := Open(d, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !n.IsLeader() {
+		if time.Now().After(deadline) {
+			t.Fatalf("single node never became leader")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := n.PutWithTTL(ctx, "k", []byte("v"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	term := n.term
+	if err := n.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err = Open(d, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = n.Close() }()
+	if n.term != term || n.lastApplied == 0 || n.lastIndexLocked() < 2 {
+		t.Fatalf("state not restored: term=%d applied=%d last=%d", n.term, n.lastApplied, n.lastIndexLocked())
+	}
+	if ttl, ok, _ := d.TTL("k"); !ok || ttl <= 0 {
+		t.Fatalf("k lost after restart")
+	}
+}
+
+// syncCountFS 统计通过它打开的文件调用了多少次 Sync。
+type syncCountFS struct {
+	vfs.FS
+	syncs atomic.Int64
+}
+
+func (fs *syncCountFS) Create(name string) (vfs.File, error) {
+	return fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+}
+
+func (fs *syncCountFS) OpenFile(name string, flag int, perm os.FileMode) (vfs.File, error) {
+	f, err := fs.FS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return syncCountFile{File: f, syncs: &fs.syncs}, nil
+}
+
+type syncCountFile struct {
+	vfs.File
+	syncs *atomic.Int64
+}
+
+func (f syncCountFile) Sync() error {
+	f.syncs.Add(1)
+	return f.File.Sync()
+}
+
+func TestStorageSyncsBeforeReturning(t *testing.T) {
+	fs := &syncCountFS{FS: vfs.Default}
+	d, err := db.OpenWithOptions(filepath.Join(t.TempDir(), "raft"), db.Options{FS: fs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &storage{d: d}
+	defer st.close()
+
+	for name, write := range map[string]func() error{
+		"setTermVote": func() error { return st.setTermVote(3, "n2") },
+		"replace":     func() error { return st.replace(1, 0, []entry{{Term: 3}}) },
+	} {
+		before := fs.syncs.Load()
+		if err := write(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if fs.syncs.Load() == before {
+			t.Fatalf("%s returned without fsync", name)
+		}
+	}
+}
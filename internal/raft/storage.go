@@ -0,0 +1,124 @@
+package raft
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"monolithdb/internal/db"
+	"monolithdb/internal/wal"
+)
+
+// entry 是 Raft 日志中的一条记录。
+type entry struct {
+	Term uint64      `json:"term"`
+	Rec  *wal.Record `json:"rec,omitempty"` // nil 表示 leader 上任时写入的空操作
+}
+
+// storage 把 Raft 的持久化状态（term / vote / 日志 / 已应用位置）保存在一个独立的 ForgeDB 里：
+//
+//	meta/term     当前 term
+//	meta/vote     当前 term 投票给了谁
+//	meta/applied  已应用到状态机的最后一条日志
+//	log/<index>   日志条目（index 补零到 20 位，保证按 key 排序即按 index 排序）
+type storage struct {
+	d *db.DB
+}
+
+const (
+	keyTerm    = "meta/term"
+	keyVote    = "meta/vote"
+	keyApplied = "meta/applied"
+	logPrefix  = "log/"
+	logEnd     = "log0" // '0' 是 '/' 的下一个字节
+)
+
+func logKey(index uint64) string { return fmt.Sprintf("%s%020d", logPrefix, index) }
+
+func openStorage(dir string) (*storage, error) {
+	d, err := db.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &storage{d: d}, nil
+}
+
+func (s *storage) close() error { return s.d.Close() }
+
+func (s *storage) getUint(key string) (uint64, error) {
+	v, ok, err := s.d.Get(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return strconv.ParseUint(string(v), 10, 64)
+}
+
+// load 读出全部持久化状态。返回的 log 以 index 0 的哨兵条目开头。
+func (s *storage) load() (term uint64, vote string, applied uint64, log []entry, err error) {
+	if term, err = s.getUint(keyTerm); err != nil {
+		return
+	}
+	if applied, err = s.getUint(keyApplied); err != nil {
+		return
+	}
+	v, _, err := s.d.Get(keyVote)
+	if err != nil {
+		return
+	}
+	vote = string(v)
+
+	entries, err := s.d.Range(logPrefix, logEnd)
+	if err != nil {
+		return
+	}
+	log = make([]entry, 1, len(entries)+1)
+	for i, kv := range entries {
+		var e entry
+		if err = json.Unmarshal(kv.Value, &e); err != nil {
+			return
+		}
+		if kv.Key != logKey(uint64(i+1)) {
+			err = fmt.Errorf("raft: log gap at %s", kv.Key)
+			return
+		}
+		log = append(log, e)
+	}
+	return
+}
+
+// writeSync 写入 b 并 fsync：term / vote / 日志写完之后节点才会回复投票和 AppendEntries，
+// 只写到操作系统缓存的话，掉电之后可能在同一个 term 投两次票，或者丢掉已经确认提交的日志。
+func (s *storage) writeSync(b *db.Batch) error {
+	if err := s.d.Write(b); err != nil {
+		return err
+	}
+	return s.d.Sync()
+}
+
+func (s *storage) setTermVote(term uint64, vote string) error {
+	var b db.Batch
+	b.Put(keyTerm, []byte(strconv.FormatUint(term, 10)))
+	b.Put(keyVote, []byte(vote))
+	return s.writeSync(&b)
+}
+
+// setApplied 不 fsync：状态机本身也不 fsync，丢掉的只是一段需要重放的日志（记录幂等）。
+func (s *storage) setApplied(index uint64) error {
+	return s.d.Put(keyApplied, []byte(strconv.FormatUint(index, 10)))
+}
+
+// replace 原子地删除 [from, oldLast] 的日志并写入从 from 开始的 entries。
+func (s *storage) replace(from, oldLast uint64, entries []entry) error {
+	var b db.Batch
+	for i := from; i <= oldLast; i++ {
+		b.Delete(logKey(i))
+	}
+	for i, e := range entries {
+		v, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		b.Put(logKey(from+uint64(i)), v)
+	}
+	return s.writeSync(&b)
+}
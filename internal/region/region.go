@@ -0,0 +1,310 @@
+// Package region 把指定前缀的 key 放到各自独立的子数据库里（各自的 WAL、SST 和数据目录），
+// 对外仍然是一个 DB。大租户可以单独放到别的磁盘上，互不影响 compaction 和写停顿；
+// 没有单独配置的 key 都落在默认区，小租户共享同一份存储。
+//
+// 各个区之间没有跨区原子性：Batch 只能包含同一个区的 key，跨区时返回 ErrCrossRegion。
+package region
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"monolithdb/internal/db"
+	"monolithdb/internal/types"
+)
+
+var (
+	// ErrInvalidRegions 表示区的配置不合法（前缀为空或重复、目录为空或重复）。
+	ErrInvalidRegions = errors.New("region: invalid regions")
+	// ErrRegionsChanged 表示区的前缀与上次打开时记录的不一致：已有的 key 会被路由到错误的子数据库。
+	ErrRegionsChanged = errors.New("region: regions do not match the ones on disk")
+	// ErrCrossRegion 表示一个 Batch 里的 key 分属多个区，无法原子地提交。
+	ErrCrossRegion = errors.New("region: batch spans multiple regions")
+)
+
+// regionsFileName 在默认区的目录下记录所有区的前缀，打开时校验。
+const regionsFileName = "REGIONS"
+
+// Region 把以 Prefix 开头的 key 放到 Dir 下的独立子数据库里。前缀互相包含时以最长的为准。
+type Region struct {
+	Prefix string
+	Dir    string
+}
+
+// Options 是 Open 的配置。零值即默认配置（只有默认区）。
+type Options struct {
+	// Regions 是单独存放的前缀。前缀一旦写入数据就不能再增删或修改，否则 Open 返回 ErrRegionsChanged；
+	// Dir 可以改变（例如把子数据库整体搬到另一块磁盘之后）。
+	Regions []Region
+
+	// DB 是打开每个子数据库（包括默认区）使用的配置。
+	DB db.Options
+}
+
+type region struct {
+	prefix string
+	db     *db.DB
+}
+
+// DB 按 key 前缀把读写转发给对应的子数据库。并发安全性与 db.DB 相同。
+type DB struct {
+	def     *db.DB
+	regions []region // 按前缀长度降序，第一个匹配的就是最长前缀
+	norm    db.KeyNormalizer
+}
+
+// Open 打开 dir 下的默认区和 opts.Regions 中的各个区。
+func Open(dir string, opts Options) (*DB, error) {
+	if err := validate(dir, opts.Regions); err != nil {
+		return nil, err
+	}
+	def, err := db.OpenWithOptions(dir, opts.DB)
+	if err != nil {
+		return nil, err
+	}
+	r := &DB{def: def, norm: opts.DB.KeyNormalizer}
+	if err := checkRegions(dir, opts.Regions, opts.DB.ReadOnly); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	for _, reg := range opts.Regions {
+		sub, err := db.OpenWithOptions(reg.Dir, opts.DB)
+		if err != nil {
+			_ = r.Close()
+			return nil, fmt.Errorf("region %q: %w", reg.Prefix, err)
+		}
+		r.regions = append(r.regions, region{prefix: reg.Prefix, db: sub})
+	}
+	sort.SliceStable(r.regions, func(i, j int) bool { return len(r.regions[i].prefix) > len(r.regions[j].prefix) })
+	return r, nil
+}
+
+func validate(dir string, regions []Region) error {
+	prefixes := make(map[string]bool)
+	dirs := map[string]bool{filepath.Clean(dir): true}
+	for _, reg := range regions {
+		if reg.Prefix == "" || reg.Dir == "" {
+			return fmt.Errorf("%w: prefix and dir must not be empty", ErrInvalidRegions)
+		}
+		if prefixes[reg.Prefix] {
+			return fmt.Errorf("%w: duplicate prefix %q", ErrInvalidRegions, reg.Prefix)
+		}
+		d := filepath.Clean(reg.Dir)
+		if dirs[d] {
+			return fmt.Errorf("%w: directory %s is used twice", ErrInvalidRegions, reg.Dir)
+		}
+		prefixes[reg.Prefix], dirs[d] = true, true
+	}
+	return nil
+}
+
+// checkRegions 校验 dir 下记录的前缀与 regions 一致；没有记录时（新库）按 regions 写一份。
+func checkRegions(dir string, regions []Region, readOnly bool) error {
+	want := make([]string, len(regions))
+	for i, reg := range regions {
+		want[i] = reg.Prefix
+	}
+	slices.Sort(want)
+
+	path := filepath.Join(dir, regionsFileName)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if readOnly {
+			return nil
+		}
+		var b []byte
+		for _, p := range want {
+			b = append(strconv.AppendQuote(b, p), '\n')
+		}
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, b, 0o644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, path)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var got []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		p, err := strconv.Unquote(sc.Text())
+		if err != nil {
+			return fmt.Errorf("region: malformed %s: %w", regionsFileName, err)
+		}
+		got = append(got, p)
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		return fmt.Errorf("%w: %q on disk, %q requested", ErrRegionsChanged, got, want)
+	}
+	return nil
+}
+
+// For 返回负责 key 的子数据库，用于 DB 没有直接转发的操作（事务、Watch、统计等）。
+// 返回的 DB 由 region.DB 管理，不能单独 Close。
+func (r *DB) For(key string) *db.DB {
+	if r.norm != nil {
+		key = r.norm.Normalize(key)
+	}
+	for _, reg := range r.regions {
+		if strings.HasPrefix(key, reg.prefix) {
+			return reg.db
+		}
+	}
+	return r.def
+}
+
+// all 返回默认区和所有区的子数据库。
+func (r *DB) all() []*db.DB {
+	out := []*db.DB{r.def}
+	for _, reg := range r.regions {
+		out = append(out, reg.db)
+	}
+	return out
+}
+
+// Put 写入 key。
+func (r *DB) Put(key string, value []byte) error { return r.For(key).Put(key, value) }
+
+// PutWithTTL 写入一个 ttl 之后过期的值。
+func (r *DB) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	return r.For(key).PutWithTTL(key, value, ttl)
+}
+
+// Get 读取 key。
+func (r *DB) Get(key string) ([]byte, bool, error) { return r.For(key).Get(key) }
+
+// Delete 删除 key。
+func (r *DB) Delete(key string) error { return r.For(key).Delete(key) }
+
+// MultiGet 读取多个 key，values[i] / found[i] 对应 keys[i]。
+// 只有同一个区内的 key 是同一时刻的一致视图。
+func (r *DB) MultiGet(keys []string) (values [][]byte, found []bool, err error) {
+	values = make([][]byte, len(keys))
+	found = make([]bool, len(keys))
+	groups := make(map[*db.DB][]int)
+	for i, k := range keys {
+		sub := r.For(k)
+		groups[sub] = append(groups[sub], i)
+	}
+	for sub, idx := range groups {
+		ks := make([]string, len(idx))
+		for j, i := range idx {
+			ks[j] = keys[i]
+		}
+		vs, fs, err := sub.MultiGet(ks)
+		if err != nil {
+			return nil, nil, err
+		}
+		for j, i := range idx {
+			values[i], found[i] = vs[j], fs[j]
+		}
+	}
+	return values, found, nil
+}
+
+// Range 返回 [start, end) 内所有可见的 key（按字节序升序），结果合并自所有区。
+// 每个区各自受 Options.DB.MaxRangeBytes 限制；各区之间不是同一时刻的快照。
+func (r *DB) Range(start, end string) ([]types.Entry, error) {
+	var out []types.Entry
+	for _, sub := range r.all() {
+		entries, err := sub.Range(start, end)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, entries...)
+	}
+	// 一个 key 只属于一个区，合并后不会有重复
+	slices.SortFunc(out, func(a, b types.Entry) int { return strings.Compare(a.Key, b.Key) })
+	return out, nil
+}
+
+// Batch 收集一组写操作，由 DB.Write 原子地提交到它们所在的区。
+type Batch struct {
+	b    db.Batch
+	keys []string
+}
+
+// Put 向批次追加一次写入。
+func (b *Batch) Put(key string, value []byte) {
+	b.b.Put(key, value)
+	b.keys = append(b.keys, key)
+}
+
+// PutWithTTL 向批次追加一次带过期时间的写入。
+func (b *Batch) PutWithTTL(key string, value []byte, ttl time.Duration) {
+	b.b.PutWithTTL(key, value, ttl)
+	b.keys = append(b.keys, key)
+}
+
+// Delete 向批次追加一次删除。
+func (b *Batch) Delete(key string) {
+	b.b.Delete(key)
+	b.keys = append(b.keys, key)
+}
+
+// Len 返回批次中的操作数。
+func (b *Batch) Len() int { return b.b.Len() }
+
+// Write 原子地提交批次。批次里的 key 必须属于同一个区，否则返回 ErrCrossRegion，什么都不写。
+func (r *DB) Write(b *Batch) error {
+	if b.Len() == 0 {
+		return nil
+	}
+	sub := r.For(b.keys[0])
+	for _, k := range b.keys[1:] {
+		if r.For(k) != sub {
+			return fmt.Errorf("%w: %q and %q", ErrCrossRegion, b.keys[0], k)
+		}
+	}
+	return sub.Write(&b.b)
+}
+
+// Flush 把所有区的 MemTable 刷成 SST。
+func (r *DB) Flush() error {
+	return r.each((*db.DB).Flush)
+}
+
+// Compact 合并所有区的 SST。
+func (r *DB) Compact() error {
+	return r.each((*db.DB).Compact)
+}
+
+// Sync 把所有区已提交的写入 fsync 到磁盘。
+func (r *DB) Sync() error {
+	return r.each((*db.DB).Sync)
+}
+
+// Close 关闭所有区，返回遇到的第一个错误。
+func (r *DB) Close() error {
+	var first error
+	for _, sub := range r.all() {
+		if err := sub.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (r *DB) each(fn func(*db.DB) error) error {
+	for _, sub := range r.all() {
+		if err := fn(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package region
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/db"
+)
+
+func TestRegionsRouteByPrefix(t *testing.T) {
+	root := t.TempDir()
+	opts := Options{
+		Regions: []Region{
+			{Prefix: "big/", Dir: filepath.Join(root, "disk2", "big")},
+			{Prefix: "big/archive/", Dir: filepath.Join(root, "disk3", "archive")},
+		},
+		DB: db.Options{DisableFsync: true},
+	}
+	r, err := Open(filepath.Join(root, "main"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"a", "big/1", "big/archive/1", "small/1"} {
+		if err := r.Put(k, []byte("v:"+k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 最长前缀优先；子数据库彼此独立
+	if _, ok, _ := r.For("big/archive/1").Get("big/1"); ok {
+		t.Fatal("big/1 leaked into the archive region")
+	}
+	if v, ok, _ := r.For("big/x").Get("big/1"); !ok || string(v) != "v:big/1" {
+		t.Fatalf("big region Get = %q, %v", v, ok)
+	}
+	if _, ok, _ := r.For("small/1").Get("big/1"); ok {
+		t.Fatal("big/1 leaked into the default region")
+	}
+
+	entries, err := r.Range("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, e := range entries {
+		keys = append(keys, e.Key)
+	}
+	if len(keys) != 4 || keys[0] != "a" || keys[1] != "big/1" || keys[2] != "big/archive/1" || keys[3] != "small/1" {
+		t.Fatalf("Range keys = %q", keys)
+	}
+
+	values, found, err := r.MultiGet([]string{"small/1", "big/archive/1", "missing"})
+	if err != nil || !found[0] || !found[1] || found[2] || string(values[1]) != "v:big/archive/1" {
+		t.Fatalf("MultiGet = %q, %v, %v", values, found, err)
+	}
+
+	var b Batch
+	b.Put("big/2", []byte("x"))
+	b.Delete("a")
+	if err := r.Write(&b); !errors.Is(err, ErrCrossRegion) {
+		t.Fatalf("cross-region batch: err = %v", err)
+	}
+	if _, ok, _ := r.Get("a"); !ok {
+		t.Fatal("rejected batch must not write anything")
+	}
+	b = Batch{}
+	b.Put("big/2", []byte("x"))
+	b.Delete("big/1")
+	if err := r.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := r.Get("big/1"); ok {
+		t.Fatal("big/1 should be deleted")
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 前缀记录在默认区里：改了前缀就不能打开，改目录以外的配置不受影响
+	changed := opts
+	changed.Regions = opts.Regions[:1]
+	if _, err := Open(filepath.Join(root, "main"), changed); !errors.Is(err, ErrRegionsChanged) {
+		t.Fatalf("changed regions: err = %v", err)
+	}
+	r, err = Open(filepath.Join(root, "main"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if v, ok, _ := r.Get("big/2"); !ok || string(v) != "x" {
+		t.Fatalf("after reopen Get(big/2) = %q, %v", v, ok)
+	}
+
+	for _, bad := range [][]Region{
+		{{Prefix: "", Dir: filepath.Join(root, "x")}},
+		{{Prefix: "p", Dir: filepath.Join(root, "x")}, {Prefix: "p", Dir: filepath.Join(root, "y")}},
+		{{Prefix: "p", Dir: filepath.Join(root, "main")}},
+	} {
+		if _, err := Open(filepath.Join(root, "main"), Options{Regions: bad}); !errors.Is(err, ErrInvalidRegions) {
+			t.Fatalf("Open(%+v): err = %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"monolithdb/internal/db"
+	"monolithdb/internal/wal"
+)
+
+// saveEvery 表示每应用多少条增量记录持久化一次复制位置。
+// 位置落后于实际数据是安全的：重启后重放的记录是幂等的。
+const saveEvery = 1000
+
+// Follower 从 leader 拉取复制流并应用到本地 DB。
+//
+// follower 的 DB 不应再接受客户端写入，否则会与 leader 的数据产生分歧，
+// 并且会在下一次 checkpoint 时被覆盖。
+type Follower struct {
+	d         *db.DB
+	streamURL string
+	statePath string
+
+	// Client 用于连接 leader，默认 http.DefaultClient（不能设置整体超时，流是长连接）。
+	Client *http.Client
+	// RetryInterval 是连接断开后重连的间隔，默认 1s。
+	RetryInterval time.Duration
+
+	mu    sync.Mutex
+	epoch string
+	seq   uint64
+}
+
+// NewFollower 创建一个从 leaderURL（例如 http://10.0.0.1:7070）复制到 d 的 follower。
+// statePath 保存已应用的复制位置，重启后从这里继续追赶；文件不存在时从 checkpoint 开始。
+func NewFollower(d *db.DB, leaderURL, statePath string) (*Follower, error) {
+	u, err := url.Parse(strings.TrimSuffix(leaderURL, "/") + StreamPath)
+	if err != nil {
+		return nil, err
+	}
+	f := &Follower{d: d, streamURL: u.String(), statePath: statePath}
+	if err := f.loadState(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Position 返回已应用的复制位置。
+func (f *Follower) Position() (epoch string, seq uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.epoch, f.seq
+}
+
+// Run 持续复制直到 ctx 结束，连接出错时自动重连。返回 ctx.Err()。
+func (f *Follower) Run(ctx context.Context) error {
+	retry := f.RetryInterval
+	if retry <= 0 {
+		retry = time.Second
+	}
+	for {
+		err := f.sync(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Printf("replication: %v (retrying in %v)", err, retry)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retry):
+		}
+	}
+}
+
+// sync 建立一次连接并应用收到的消息，直到连接断开。
+func (f *Follower) sync(ctx context.Context) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	epoch, seq := f.Position()
+	q := url.Values{"epoch": {epoch}, "seq": {strconv.FormatUint(seq, 10)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.streamURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("leader returned %s", resp.Status)
+	}
+
+	// 一段时间内连心跳都没有，认为连接已经失效
+	watchdog := time.AfterFunc(5*heartbeatInterval, cancel)
+	defer watchdog.Stop()
+
+	// 断开时把最新位置落盘
+	defer func() {
+		if serr := f.saveState(); err == nil {
+			err = serr
+		}
+	}()
+
+	dec := json.NewDecoder(resp.Body)
+	var (
+		inCheckpoint bool
+		snapshotKeys map[string]bool
+		unsaved      int
+	)
+	for {
+		var m message
+		if err := dec.Decode(&m); err != nil {
+			return err
+		}
+		watchdog.Reset(5 * heartbeatInterval)
+
+		switch m.Type {
+		case msgCheckpoint:
+			inCheckpoint = true
+			snapshotKeys = make(map[string]bool)
+
+		case msgEntry:
+			if !inCheckpoint {
+				return errors.New("replication: entry outside checkpoint")
+			}
+			if err := f.d.ApplyRecord(m.record()); err != nil {
+				return err
+			}
+			snapshotKeys[m.Key] = true
+
+		case msgCheckpointEnd:
+			if !inCheckpoint {
+				return errors.New("replication: unexpected checkpoint end")
+			}
+			// 删除 leader 上已经不存在的 key
+			for chunk, err := range f.d.RangeChunks("", "", 0) {
+				if err != nil {
+					return err
+				}
+				for _, e := range chunk {
+					if snapshotKeys[e.Key] {
+						continue
+					}
+					if err := f.d.ApplyRecord(wal.Record{Op: wal.OpDelete, Key: e.Key}); err != nil {
+						return err
+					}
+				}
+			}
+			inCheckpoint, snapshotKeys = false, nil
+			f.setPosition(m.Epoch, m.Seq)
+			if err := f.saveState(); err != nil {
+				return err
+			}
+
+		case msgRecord:
+			epoch, seq := f.Position()
+			if inCheckpoint || m.Seq != seq+1 {
+				return fmt.Errorf("replication: expected seq %d, got %d", seq+1, m.Seq)
+			}
+			if err := f.d.ApplyRecord(m.record()); err != nil {
+				return err
+			}
+			f.setPosition(epoch, m.Seq)
+			if unsaved++; unsaved >= saveEvery {
+				if err := f.saveState(); err != nil {
+					return err
+				}
+				unsaved = 0
+			}
+
+		case msgHeartbeat:
+			if unsaved > 0 {
+				if err := f.saveState(); err != nil {
+					return err
+				}
+				unsaved = 0
+			}
+		}
+	}
+}
+
+func (f *Follower) setPosition(epoch string, seq uint64) {
+	f.mu.Lock()
+	f.epoch, f.seq = epoch, seq
+	f.mu.Unlock()
+}
+
+// 状态文件格式：一行 "<epoch> <seq>"
+func (f *Follower) loadState() error {
+	b, err := os.ReadFile(f.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(string(b))
+	if len(fields) != 2 {
+		return fmt.Errorf("replication: malformed state file %s", f.statePath)
+	}
+	seq, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("replication: malformed state file %s: %v", f.statePath, err)
+	}
+	f.epoch, f.seq = fields[0], seq
+	return nil
+}
+
+func (f *Follower) saveState() error {
+	epoch, seq := f.Position()
+	if epoch == "" {
+		return nil
+	}
+	tmp := f.statePath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%s %d\n", epoch, seq)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.statePath)
+}
@@ -0,0 +1,200 @@
+package replication
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"monolithdb/internal/db"
+	"monolithdb/internal/wal"
+)
+
+// StreamPath 是 leader 复制流的 HTTP 路径。
+const StreamPath = "/replication/stream"
+
+// DefaultBacklog 是 leader 默认在内存里保留的记录数。
+const DefaultBacklog = 10000
+
+// heartbeatInterval 是没有新记录时 leader 发送心跳的间隔。
+var heartbeatInterval = time.Second
+
+type seqRecord struct {
+	seq uint64
+	r   wal.Record
+}
+
+// Leader 收集 DB 的提交记录，并通过 HTTP 发给 follower。
+type Leader struct {
+	d     *db.DB
+	epoch string
+
+	mu      sync.Mutex
+	backlog int
+	log     []seqRecord // 最近的记录，seq 连续递增
+	lastSeq uint64
+	notify  chan struct{} // 有新记录时 close 并替换，唤醒所有等待的流
+}
+
+// NewLeader 在 d 上开启复制，backlog <= 0 表示 DefaultBacklog。
+// 它会占用 d 的提交回调（db.SetCommitHook）。
+//
+// 每个 Leader 有一个随机 epoch，序号只在同一个 epoch 内有意义；
+// 进程重启后 epoch 变化，所有 follower 都会重新拉取 checkpoint。
+func NewLeader(d *db.DB, backlog int) *Leader {
+	if backlog <= 0 {
+		backlog = DefaultBacklog
+	}
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+
+	l := &Leader{
+		d:       d,
+		epoch:   hex.EncodeToString(b[:]),
+		backlog: backlog,
+		notify:  make(chan struct{}),
+	}
+	d.SetCommitHook(l.onCommit)
+	return l
+}
+
+// Close 停止收集提交记录。已经建立的流在发完 backlog 后会一直等待直到客户端断开。
+func (l *Leader) Close() {
+	l.d.SetCommitHook(nil)
+}
+
+// Epoch 返回 leader 的 epoch。
+func (l *Leader) Epoch() string { return l.epoch }
+
+// LastSeq 返回最后一条提交记录的序号。
+func (l *Leader) LastSeq() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastSeq
+}
+
+// onCommit 在 DB 写锁内被调用，所以序号顺序就是提交顺序。
+func (l *Leader) onCommit(r wal.Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lastSeq++
+	l.log = append(l.log, seqRecord{seq: l.lastSeq, r: r})
+	// 超出 backlog 1/4 时再整体搬移，摊还开销
+	if len(l.log) > l.backlog+l.backlog/4 {
+		l.log = append([]seqRecord(nil), l.log[len(l.log)-l.backlog:]...)
+	}
+
+	close(l.notify)
+	l.notify = make(chan struct{})
+}
+
+// since 返回 seq 之后的记录。ok 为 false 表示 seq+1 已经不在 backlog 里。
+// wait 在没有新记录时可用于等待。
+func (l *Leader) since(seq uint64) (recs []seqRecord, wait <-chan struct{}, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	first := l.lastSeq + 1
+	if len(l.log) > 0 {
+		first = l.log[0].seq
+	}
+	if seq+1 < first || seq > l.lastSeq {
+		return nil, nil, false
+	}
+	recs = append(recs, l.log[seq+1-first:]...)
+	return recs, l.notify, true
+}
+
+// ServeHTTP 处理 StreamPath 上的请求。
+func (l *Leader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	seq, _ := strconv.ParseUint(q.Get("seq"), 10, 64)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+
+	_, _, inBacklog := l.since(seq)
+	if q.Get("epoch") != l.epoch || !inBacklog {
+		var err error
+		if seq, err = l.sendCheckpoint(enc); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+
+	ctx := r.Context()
+	hb := time.NewTicker(heartbeatInterval)
+	defer hb.Stop()
+
+	for {
+		recs, wait, ok := l.since(seq)
+		if !ok {
+			// 落后太多（例如发 checkpoint 期间 backlog 已经滚动过去），断开让 follower 重连
+			return
+		}
+		for _, sr := range recs {
+			if err := enc.Encode(recordMessage(sr.seq, sr.r)); err != nil {
+				return
+			}
+			seq = sr.seq
+		}
+		if len(recs) > 0 {
+			flusher.Flush()
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wait:
+		case <-hb.C:
+			if err := enc.Encode(message{Type: msgHeartbeat, Seq: seq}); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// sendCheckpoint 发送全量 live 数据，返回 checkpoint 对应的序号。
+// 先取序号再扫描：扫描期间的写入会在之后作为增量记录重放一次。
+func (l *Leader) sendCheckpoint(enc *json.Encoder) (uint64, error) {
+	seq := l.LastSeq()
+
+	if err := enc.Encode(message{Type: msgCheckpoint, Epoch: l.epoch, Seq: seq}); err != nil {
+		return 0, err
+	}
+	// 分块读取：数据量大时不需要把整个库读进内存
+	for chunk, err := range l.d.RangeChunks("", "", 0) {
+		if err != nil {
+			return 0, err
+		}
+		for _, e := range chunk {
+			m := message{Type: msgEntry, Op: wal.OpPut, Key: e.Key, Value: e.Value}
+			if e.ExpiresAt != 0 {
+				m.Op, m.ExpiresAt = wal.OpPutTTL, e.ExpiresAt
+			}
+			if err := enc.Encode(m); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := enc.Encode(message{Type: msgCheckpointEnd, Epoch: l.epoch, Seq: seq}); err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
@@ -0,0 +1,56 @@
+// Package replication 实现基于 WAL 传输的主从复制（leader -> follower）。
+//
+// leader 给每条提交的 WAL 记录分配递增的序号，并在内存里保留最近的一段（backlog）。
+// follower 通过 HTTP 长连接拉取记录流并用 db.ApplyRecord 应用到自己的库里：
+//
+//	GET /replication/stream?epoch=<epoch>&seq=<已应用的最后序号>
+//
+// 响应是换行分隔的 JSON 消息流。follower 请求的位置不在 backlog 里
+// （第一次连接、落后太多、leader 重启后 epoch 变化）时，leader 先发一份
+// checkpoint（全量 live 数据），再从 checkpoint 对应的序号继续发增量记录。
+//
+// checkpoint 不是严格的一致快照：扫描期间发生的写入可能已经包含在 checkpoint 中，
+// 又会作为增量记录再发一次。所有记录重复应用的结果都相同（过期时间是绝对时间），
+// 所以按顺序重放后 follower 与 leader 一致。
+package replication
+
+import "monolithdb/internal/wal"
+
+// 消息类型
+const (
+	msgCheckpoint    = "checkpoint"     // checkpoint 开始：Epoch / Seq 是 checkpoint 对应的位置
+	msgEntry         = "entry"          // checkpoint 中的一条 live 数据
+	msgCheckpointEnd = "checkpoint_end" // checkpoint 结束，之后是 Seq 之后的增量记录
+	msgRecord        = "record"         // 一条增量 WAL 记录
+	msgHeartbeat     = "heartbeat"      // 没有新记录时定期发送，用于检测连接存活
+)
+
+// message 是复制流中的一条消息。Value 不加 omitempty，以区分 nil 与空 value。
+type message struct {
+	Type  string `json:"type"`
+	Epoch string `json:"epoch,omitempty"`
+	Seq   uint64 `json:"seq,omitempty"`
+
+	Op        byte      `json:"op,omitempty"`
+	Key       string    `json:"key,omitempty"`
+	Value     []byte    `json:"value"`
+	ExpiresAt int64     `json:"expires_at,omitempty"`
+	Keys      []string  `json:"keys,omitempty"`
+	Batch     []message `json:"batch,omitempty"` // OpBatch 的子记录，只使用 Op / Key / Value / ExpiresAt
+}
+
+func (m *message) record() wal.Record {
+	r := wal.Record{Op: m.Op, Key: m.Key, Value: m.Value, ExpiresAt: m.ExpiresAt, Keys: m.Keys}
+	for i := range m.Batch {
+		r.Batch = append(r.Batch, m.Batch[i].record())
+	}
+	return r
+}
+
+func recordMessage(seq uint64, r wal.Record) message {
+	m := message{Type: msgRecord, Seq: seq, Op: r.Op, Key: r.Key, Value: r.Value, ExpiresAt: r.ExpiresAt, Keys: r.Keys}
+	for _, sub := range r.Batch {
+		m.Batch = append(m.Batch, recordMessage(0, sub))
+	}
+	return m
+}
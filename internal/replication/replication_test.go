@@ -0,0 +1,152 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"monolithdb/internal/db"
+)
+
+func openDB(t *testing.T, name string) *db.DB {
+	t.Helper()
+	d, err := db.Open(filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = d.Close() })
+	return d
+}
+
+// startFollower 启动 follower，返回停止函数（等待 Run 返回）。
+func startFollower(t *testing.T, f *Follower) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = f.Run(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func waitCaughtUp(t *testing.T, l *Leader, f *Follower) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		epoch, seq := f.Position()
+		if epoch == l.Epoch() && seq == l.LastSeq() {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("follower stuck at %s/%d, leader at %s/%d", epoch, seq, l.Epoch(), l.LastSeq())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func expectValue(t *testing.T, d *db.DB, key string, want []byte) {
+	t.Helper()
+	v, ok, err := d.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want == nil {
+		if ok {
+			t.Fatalf("%s: expected missing, got %q", key, v)
+		}
+		return
+	}
+	if !ok || !bytes.Equal(v, want) {
+		t.Fatalf("%s: got %q ok=%v, want %q", key, v, ok, want)
+	}
+}
+
+func TestReplicationStreamAndCatchUp(t *testing.T) {
+	old := heartbeatInterval
+	heartbeatInterval = 20 * time.Millisecond
+	defer func() { heartbeatInterval = old }()
+
+	leaderDB := openDB(t, "leader")
+	l := NewLeader(leaderDB, 4)
+	ts := httptest.NewServer(l)
+	defer ts.Close()
+
+	// follower 连接之前已有的数据通过 checkpoint 同步
+	for i := 0; i < 10; i++ {
+		if err := leaderDB.Put(fmt.Sprintf("k%d", i), []byte(fmt.Sprintf("v%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := leaderDB.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	followerDB := openDB(t, "follower")
+	statePath := filepath.Join(t.TempDir(), "REPLICA")
+	f, err := NewFollower(followerDB, ts.URL, statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.RetryInterval = 10 * time.Millisecond
+	stop := startFollower(t, f)
+
+	waitCaughtUp(t, l, f)
+	expectValue(t, followerDB, "k3", []byte("v3"))
+
+	// 增量记录：覆盖、删除、TTL、空 value
+	if err := leaderDB.Put("k3", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := leaderDB.Delete("k4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := leaderDB.PutWithTTL("ttl", []byte("x"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := leaderDB.Put("empty", []byte{}); err != nil {
+		t.Fatal(err)
+	}
+	waitCaughtUp(t, l, f)
+
+	expectValue(t, followerDB, "k3", []byte("new"))
+	expectValue(t, followerDB, "k4", nil)
+	expectValue(t, followerDB, "empty", []byte{})
+	if ttl, ok, _ := followerDB.TTL("ttl"); !ok || ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("ttl not replicated: %v %v", ttl, ok)
+	}
+	stop()
+
+	// follower 离线期间写入超过 backlog，重启后需要通过 checkpoint 追赶，
+	// 且 leader 上删除的 key 在 follower 上也要删除
+	for i := 0; i < 10; i++ {
+		if err := leaderDB.Put(fmt.Sprintf("later%d", i), []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := leaderDB.Delete("k5"); err != nil {
+		t.Fatal(err)
+	}
+
+	f2, err := NewFollower(followerDB, ts.URL, statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if epoch, _ := f2.Position(); epoch != l.Epoch() {
+		t.Fatalf("state not restored: epoch %q", epoch)
+	}
+	f2.RetryInterval = 10 * time.Millisecond
+	defer startFollower(t, f2)()
+
+	waitCaughtUp(t, l, f2)
+	expectValue(t, followerDB, "k5", nil)
+	expectValue(t, followerDB, "later9", []byte("x"))
+	expectValue(t, followerDB, "k3", []byte("new"))
+}
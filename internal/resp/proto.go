@@ -0,0 +1,117 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 单个请求的上限，防止恶意客户端声明一个巨大的数组 / bulk string
+const (
+	maxArgs     = 1 << 20
+	maxBulkLen  = 512 << 20 // 与 Redis 的 proto-max-bulk-len 默认值一致
+	maxInlineLn = 64 << 10
+)
+
+var errProtocol = errors.New("resp: protocol error")
+
+// readCommand 读取一条命令，返回参数列表（args[0] 是命令名）。
+// 同时支持 RESP 数组格式（redis-cli / 客户端库）和 inline 格式（telnet / nc）。
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+	if line[0] != '*' {
+		return parseInline(line), nil
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxArgs {
+		return nil, fmt.Errorf("%w: invalid multibulk length", errProtocol)
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+
+	args := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("%w: expected '$', got %q", errProtocol, line)
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, fmt.Errorf("%w: invalid bulk length", errProtocol)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", errProtocol)
+		}
+		args = append(args, buf[:size])
+	}
+	return args, nil
+}
+
+// readLine 读取一行并去掉结尾的 \r\n（inline 命令也接受单独的 \n）。
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxInlineLn {
+			return nil, fmt.Errorf("%w: line too long", errProtocol)
+		}
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+func parseInline(line []byte) [][]byte {
+	var args [][]byte
+	for _, f := range strings.Fields(string(line)) {
+		args = append(args, []byte(f))
+	}
+	return args
+}
+
+// writer 封装 RESP2 回复的编码。
+type writer struct {
+	w *bufio.Writer
+}
+
+func (w writer) simple(s string) { w.w.WriteString("+" + s + "\r\n") }
+
+func (w writer) err(s string) { w.w.WriteString("-" + s + "\r\n") }
+
+func (w writer) int(n int64) {
+	w.w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (w writer) bulk(b []byte) {
+	w.w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	w.w.Write(b)
+	w.w.WriteString("\r\n")
+}
+
+func (w writer) null() { w.w.WriteString("$-1\r\n") }
+
+func (w writer) array(n int) {
+	w.w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
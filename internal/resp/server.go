@@ -0,0 +1,474 @@
+// Package resp 实现 Redis 协议（RESP2）的一个子集，让 redis-cli、redis-benchmark
+// 以及现有的 Redis 客户端库可以直接访问 ForgeDB。
+//
+// 支持的命令：GET、SET（EX / PX）、SETNX、GETSET、MGET、MSET、DEL、EXISTS、SCAN（MATCH / COUNT）、TTL、PTTL、
+// EVAL（脚本使用 script 包的语言而不是 Lua），以及连接相关的 PING、ECHO、QUIT、COMMAND。
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"monolithdb/internal/db"
+	"monolithdb/internal/script"
+)
+
+// Server 是 RESP 协议的监听端。
+type Server struct {
+	d *db.DB
+
+	mu     sync.Mutex
+	lis    []net.Listener
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewServer 创建一个基于 d 的 RESP 服务端。
+func NewServer(d *db.DB) *Server {
+	return &Server{d: d, conns: make(map[net.Conn]struct{})}
+}
+
+// Serve 在 lis 上接受连接，直到 lis 出错或 Close 被调用。Close 之后返回 nil。
+func (s *Server) Serve(lis net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = lis.Close()
+		return nil
+	}
+	s.lis = append(s.lis, lis)
+	s.mu.Unlock()
+
+	for {
+		c, err := lis.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = c.Close()
+			return nil
+		}
+		s.conns[c] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(c)
+
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close 关闭所有监听和连接，并等待正在处理的命令结束。
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for _, l := range s.lis {
+		_ = l.Close()
+	}
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) serveConn(c net.Conn) {
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	w := writer{w: bufio.NewWriter(c)}
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				w.err("ERR " + err.Error())
+				_ = w.w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := s.exec(w, args)
+
+		// 流水线：客户端一次发来的命令全部处理完再统一 flush
+		if r.Buffered() == 0 || quit {
+			if err := w.w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// exec 执行一条命令并写出回复；返回 true 表示客户端请求断开连接。
+func (s *Server) exec(w writer, args [][]byte) (quit bool) {
+	name := strings.ToLower(string(args[0]))
+	args = args[1:]
+
+	argc := func(min, max int) bool {
+		if len(args) < min || (max >= 0 && len(args) > max) {
+			w.err(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+			return false
+		}
+		return true
+	}
+
+	switch name {
+	case "ping":
+		if !argc(0, 1) {
+			return
+		}
+		if len(args) == 1 {
+			w.bulk(args[0])
+		} else {
+			w.simple("PONG")
+		}
+
+	case "echo":
+		if argc(1, 1) {
+			w.bulk(args[0])
+		}
+
+	case "quit":
+		w.simple("OK")
+		return true
+
+	case "command":
+		// redis-cli 启动时会发 COMMAND DOCS 获取命令提示，返回空数组即可
+		w.array(0)
+
+	case "get":
+		if !argc(1, 1) {
+			return
+		}
+		v, ok, err := s.d.Get(string(args[0]))
+		switch {
+		case err != nil:
+			writeErr(w, err)
+		case !ok:
+			w.null()
+		default:
+			w.bulk(v)
+		}
+
+	case "set":
+		if argc(2, -1) {
+			s.set(w, args)
+		}
+
+	case "getset":
+		if !argc(2, 2) {
+			return
+		}
+		old, ok, err := s.d.GetSet(string(args[0]), args[1])
+		switch {
+		case err != nil:
+			writeErr(w, err)
+		case !ok:
+			w.null()
+		default:
+			w.bulk(old)
+		}
+
+	case "setnx":
+		if !argc(2, 2) {
+			return
+		}
+		ok, err := s.d.PutIfAbsent(string(args[0]), args[1])
+		switch {
+		case err != nil:
+			writeErr(w, err)
+		case ok:
+			w.int(1)
+		default:
+			w.int(0)
+		}
+
+	case "mget":
+		if !argc(1, -1) {
+			return
+		}
+		keys := make([]string, len(args))
+		for i, k := range args {
+			keys[i] = string(k)
+		}
+		vals, found, err := s.d.MultiGet(keys)
+		if err != nil {
+			writeErr(w, err)
+			return
+		}
+		w.array(len(keys))
+		for i := range keys {
+			if found[i] {
+				w.bulk(vals[i])
+			} else {
+				w.null()
+			}
+		}
+
+	case "mset":
+		if !argc(2, -1) {
+			return
+		}
+		if len(args)%2 != 0 {
+			w.err("ERR wrong number of arguments for 'mset' command")
+			return
+		}
+		// 与 Redis 一样，MSET 是原子的：所有 key 作为一个 Batch 写入
+		var b db.Batch
+		for i := 0; i < len(args); i += 2 {
+			b.Put(string(args[i]), args[i+1])
+		}
+		if err := s.d.Write(&b); err != nil {
+			writeErr(w, err)
+			return
+		}
+		w.simple("OK")
+
+	case "del":
+		if !argc(1, -1) {
+			return
+		}
+		// 先查再删，计数在并发写入下只是近似值（与 HTTP batch 一样，不是原子的）
+		var n int64
+		for _, k := range args {
+			_, ok, err := s.d.Get(string(k))
+			if err != nil {
+				writeErr(w, err)
+				return
+			}
+			if !ok {
+				continue
+			}
+			if err := s.d.Delete(string(k)); err != nil {
+				writeErr(w, err)
+				return
+			}
+			n++
+		}
+		w.int(n)
+
+	case "exists":
+		if !argc(1, -1) {
+			return
+		}
+		var n int64
+		for _, k := range args {
+			_, ok, err := s.d.Get(string(k))
+			if err != nil {
+				writeErr(w, err)
+				return
+			}
+			if ok {
+				n++
+			}
+		}
+		w.int(n)
+
+	case "ttl", "pttl":
+		if !argc(1, 1) {
+			return
+		}
+		ttl, ok, err := s.d.TTL(string(args[0]))
+		switch {
+		case err != nil:
+			writeErr(w, err)
+		case !ok:
+			w.int(-2)
+		case ttl == 0:
+			w.int(-1)
+		case name == "ttl":
+			w.int(int64((ttl + 500*time.Millisecond) / time.Second))
+		default:
+			w.int(ttl.Milliseconds())
+		}
+
+	case "scan":
+		if argc(1, -1) {
+			s.scan(w, args)
+		}
+
+	case "eval":
+		if argc(2, -1) {
+			s.eval(w, args)
+		}
+
+	default:
+		w.err(fmt.Sprintf("ERR unknown command '%s'", name))
+	}
+	return false
+}
+
+// set 处理 SET key value [EX seconds | PX milliseconds]。
+func (s *Server) set(w writer, args [][]byte) {
+	key, value := string(args[0]), args[1]
+
+	var ttl time.Duration
+	for opts := args[2:]; len(opts) > 0; {
+		opt := strings.ToLower(string(opts[0]))
+		if (opt != "ex" && opt != "px") || len(opts) < 2 || ttl != 0 {
+			w.err("ERR syntax error")
+			return
+		}
+		n, err := strconv.ParseInt(string(opts[1]), 10, 64)
+		if err != nil || n <= 0 {
+			w.err("ERR invalid expire time in 'set' command")
+			return
+		}
+		if opt == "ex" {
+			ttl = time.Duration(n) * time.Second
+		} else {
+			ttl = time.Duration(n) * time.Millisecond
+		}
+		opts = opts[2:]
+	}
+
+	if err := s.d.PutWithTTL(key, value, ttl); err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.simple("OK")
+}
+
+// scan 处理 SCAN cursor [MATCH pattern] [COUNT count]。
+//
+// cursor 是按 key 排序后的位置。每次调用都会从头扫描到 cursor 处，
+// 扫描期间有写入时可能重复或遗漏 key（Redis 的 SCAN 对并发修改也只有弱保证）。
+func (s *Server) scan(w writer, args [][]byte) {
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		w.err("ERR invalid cursor")
+		return
+	}
+
+	pattern, count := "", 10
+	for opts := args[1:]; len(opts) > 0; opts = opts[2:] {
+		if len(opts) < 2 {
+			w.err("ERR syntax error")
+			return
+		}
+		switch strings.ToLower(string(opts[0])) {
+		case "match":
+			pattern = string(opts[1])
+		case "count":
+			n, err := strconv.Atoi(string(opts[1]))
+			if err != nil || n <= 0 {
+				w.err("ERR value is not an integer or out of range")
+				return
+			}
+			count = n
+		default:
+			w.err("ERR syntax error")
+			return
+		}
+	}
+
+	// 分块读取到 cursor+count 之后的一条为止，不需要把所有 key 读进内存
+	end := cursor + uint64(count)
+	var keys []string
+	var pos, next uint64
+scan:
+	for chunk, err := range s.d.RangeChunks("", "", 0) {
+		if err != nil {
+			writeErr(w, err)
+			return
+		}
+		for _, e := range chunk {
+			if pos == end {
+				next = end // 后面还有 key
+				break scan
+			}
+			if pos >= cursor && (pattern == "" || globMatch(pattern, e.Key)) {
+				keys = append(keys, e.Key)
+			}
+			pos++
+		}
+	}
+
+	w.array(2)
+	w.bulk([]byte(strconv.FormatUint(next, 10)))
+	w.array(len(keys))
+	for _, k := range keys {
+		w.bulk([]byte(k))
+	}
+}
+
+// eval 处理 EVAL script numkeys [key ...] [arg ...]，脚本在写锁内原子地执行（见 script.Eval）。
+// 返回值按 Redis 执行 Lua 脚本的惯例转换：整数 -> integer，字符串 -> bulk，true -> 1，false / nil -> nil。
+func (s *Server) eval(w writer, args [][]byte) {
+	numKeys, err := strconv.Atoi(string(args[1]))
+	if err != nil || numKeys < 0 {
+		w.err("ERR value is not an integer or out of range")
+		return
+	}
+	if numKeys > len(args)-2 {
+		w.err("ERR Number of keys can't be greater than number of args")
+		return
+	}
+	keys := make([]string, numKeys)
+	for i := range keys {
+		keys[i] = string(args[2+i])
+	}
+
+	v, err := script.Eval(s.d, string(args[0]), keys, args[2+numKeys:])
+	switch {
+	case errors.Is(err, script.ErrSyntax), errors.Is(err, script.ErrRuntime), errors.Is(err, script.ErrAborted):
+		w.err("ERR " + err.Error())
+		return
+	case err != nil:
+		writeErr(w, err)
+		return
+	}
+	switch v := v.(type) {
+	case []byte:
+		w.bulk(v)
+	case int64:
+		w.int(v)
+	case bool:
+		if v {
+			w.int(1)
+		} else {
+			w.null()
+		}
+	default:
+		w.null()
+	}
+}
+
+func writeErr(w writer, err error) {
+	if errors.Is(err, db.ErrReadOnly) {
+		w.err("READONLY " + err.Error())
+		return
+	}
+	log.Printf("resp: %v", err)
+	w.err("ERR " + err.Error())
+}
@@ -0,0 +1,237 @@
+package resp
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"monolithdb/internal/db"
+)
+
+// client 是测试用的最小 RESP 客户端：发送数组格式的命令，把回复解析成字符串。
+type client struct {
+	c net.Conn
+	r *bufio.Reader
+}
+
+func (c *client) do(t *testing.T, args ...string) string {
+	t.Helper()
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := c.c.Write([]byte(b.String())); err != nil {
+		t.Fatal(err)
+	}
+	return c.read(t)
+}
+
+// read 读取一条回复：简单类型原样返回（带类型前缀），数组展开为 [a b ...]。
+func (c *client) read(t *testing.T) string {
+	t.Helper()
+	line, err := readLine(c.r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch line[0] {
+	case '$':
+		if string(line) == "$-1" {
+			return "(nil)"
+		}
+		v, err := readLine(c.r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(v)
+	case '*':
+		var n int
+		fmt.Sscanf(string(line[1:]), "%d", &n)
+		items := make([]string, n)
+		for i := range items {
+			items[i] = c.read(t)
+		}
+		return "[" + strings.Join(items, " ") + "]"
+	default:
+		return string(line)
+	}
+}
+
+func newTestServer(t *testing.T) *client {
+	t.Helper()
+
+	d, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(d)
+	go func() { _ = s.Serve(lis) }()
+
+	c, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = c.Close()
+		_ = s.Close()
+		_ = d.Close()
+	})
+	return &client{c: c, r: bufio.NewReader(c)}
+}
+
+func TestRESPCommands(t *testing.T) {
+	c := newTestServer(t)
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"PING"}, "+PONG"},
+		{[]string{"SET", "a", "1"}, "+OK"},
+		{[]string{"SET", "b", "2", "EX", "100"}, "+OK"},
+		{[]string{"SET", "c", "3", "NX"}, "-ERR syntax error"},
+		{[]string{"GET", "a"}, "1"},
+		{[]string{"GET", "missing"}, "(nil)"},
+		{[]string{"GETSET", "g", "1"}, "(nil)"},
+		{[]string{"GETSET", "g", "2"}, "1"},
+		{[]string{"GET", "g"}, "2"},
+		{[]string{"SETNX", "g", "x"}, ":0"},
+		{[]string{"SETNX", "n", "x"}, ":1"},
+		{[]string{"GET", "n"}, "x"},
+		{[]string{"GET", "g"}, "2"},
+		{[]string{"EXISTS", "a", "b", "missing"}, ":2"},
+		{[]string{"TTL", "a"}, ":-1"},
+		{[]string{"TTL", "b"}, ":100"},
+		{[]string{"TTL", "missing"}, ":-2"},
+		{[]string{"DEL", "a", "missing"}, ":1"},
+		{[]string{"GET", "a"}, "(nil)"},
+		{[]string{"GET"}, "-ERR wrong number of arguments for 'get' command"},
+		{[]string{"MSET", "m1", "x", "m2", "y"}, "+OK"},
+		{[]string{"MGET", "m1", "missing", "m2"}, "[x (nil) y]"},
+		{[]string{"MSET", "m1", "x", "m2"}, "-ERR wrong number of arguments for 'mset' command"},
+		{[]string{"FLUSHALL"}, "-ERR unknown command 'flushall'"},
+	}
+	for _, tc := range cases {
+		if got := c.do(t, tc.args...); got != tc.want {
+			t.Fatalf("%v: got %q, want %q", tc.args, got, tc.want)
+		}
+	}
+
+	// inline 命令（telnet / nc）
+	if _, err := c.c.Write([]byte("GET b\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.read(t); got != "2" {
+		t.Fatalf("inline GET: got %q", got)
+	}
+}
+
+func TestRESPEval(t *testing.T) {
+	c := newTestServer(t)
+
+	incr := `(let n (+ (int (or (get (key 0)) "0")) (int (arg 0)))) (put (key 0) (str n)) n`
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"EVAL", incr, "1", "counter", "5"}, ":5"},
+		{[]string{"EVAL", incr, "1", "counter", "2"}, ":7"},
+		{[]string{"GET", "counter"}, "7"},
+		{[]string{"EVAL", `(get (key 0))`, "1", "counter"}, "7"},
+		{[]string{"EVAL", `(exists "missing")`, "0"}, "(nil)"},
+		{[]string{"EVAL", `(exists (key 0))`, "1", "counter"}, ":1"},
+		{[]string{"EVAL", `(get "x")`, "2", "a"}, "-ERR Number of keys can't be greater than number of args"},
+		{[]string{"EVAL", `(put "x" "1") (error "stop")`, "0"}, "-ERR script: aborted: stop"},
+		{[]string{"GET", "x"}, "(nil)"},
+	}
+	for _, tc := range cases {
+		if got := c.do(t, tc.args...); got != tc.want {
+			t.Fatalf("%v: got %q, want %q", tc.args, got, tc.want)
+		}
+	}
+	if got := c.do(t, "EVAL", "(nope)", "0"); !strings.HasPrefix(got, "-ERR script: syntax error") {
+		t.Fatalf("syntax error reply = %q", got)
+	}
+}
+
+func TestRESPPipelinedMGet(t *testing.T) {
+	c := newTestServer(t)
+
+	if got := c.do(t, "MSET", "a", "1", "b", "2"); got != "+OK" {
+		t.Fatalf("MSET: %q", got)
+	}
+
+	// 一次写出多条命令，回复按顺序返回
+	const n = 50
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteString("*3\r\n$4\r\nMGET\r\n$1\r\na\r\n$1\r\nb\r\n")
+	}
+	if _, err := c.c.Write([]byte(b.String())); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if got := c.read(t); got != "[1 2]" {
+			t.Fatalf("reply %d: got %q", i, got)
+		}
+	}
+}
+
+func TestRESPScan(t *testing.T) {
+	c := newTestServer(t)
+
+	for i := 0; i < 25; i++ {
+		c.do(t, "SET", fmt.Sprintf("user:%02d", i), "x")
+	}
+	c.do(t, "SET", "other", "x")
+
+	// 按 cursor 翻页直到返回 0
+	var keys []string
+	cursor := "0"
+	for {
+		if _, err := fmt.Fprintf(c.c, "*6\r\n$4\r\nSCAN\r\n$%d\r\n%s\r\n$5\r\nMATCH\r\n$6\r\nuser:*\r\n$5\r\nCOUNT\r\n$1\r\n7\r\n", len(cursor), cursor); err != nil {
+			t.Fatal(err)
+		}
+		reply := strings.Trim(c.read(t), "[]")
+		parts := strings.Fields(reply)
+		cursor = parts[0]
+		for _, k := range parts[1:] {
+			keys = append(keys, strings.Trim(k, "[]"))
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+	if len(keys) != 25 || keys[0] != "user:00" || keys[24] != "user:24" {
+		t.Fatalf("scan returned %d keys: %v", len(keys), keys)
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	cases := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "a/b", true},
+		{"user:*", "user:1", true},
+		{"user:*", "usr:1", false},
+		{"h?llo", "hello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{`a\*b`, "a*b", true},
+		{`a\*b`, "axb", false},
+	}
+	for _, tc := range cases {
+		if got := globMatch(tc.pattern, tc.s); got != tc.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tc.pattern, tc.s, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"google.golang.org/grpc"
+)
+
+// Client 是 ForgeDB gRPC 服务的 Go 客户端。
+type Client struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewClient 基于已建立的连接创建客户端。
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{cc: cc}
+}
+
+func (c *Client) invoke(ctx context.Context, method string, req, resp message, opts ...grpc.CallOption) error {
+	opts = append([]grpc.CallOption{grpc.ForceCodec(codec{})}, opts...)
+	return c.cc.Invoke(ctx, "/"+serviceName+"/"+method, req, resp, opts...)
+}
+
+func (c *Client) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	resp := new(GetResponse)
+	if err := c.invoke(ctx, "Get", &GetRequest{Key: key}, resp); err != nil {
+		return nil, false, err
+	}
+	return resp.Value, resp.Found, nil
+}
+
+// Put 写入 key；ttlMs 为 0 表示永不过期。
+func (c *Client) Put(ctx context.Context, key string, value []byte, ttlMs int64) error {
+	return c.invoke(ctx, "Put", &PutRequest{Key: key, Value: value, TTLMs: ttlMs}, new(PutResponse))
+}
+
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return c.invoke(ctx, "Delete", &DeleteRequest{Key: key}, new(DeleteResponse))
+}
+
+func (c *Client) Batch(ctx context.Context, ops []BatchOp) ([]BatchResult, error) {
+	resp := new(BatchResponse)
+	if err := c.invoke(ctx, "Batch", &BatchRequest{Ops: ops}, resp); err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// MultiGet 一次请求读取多个 key，values / found 与 keys 一一对应。
+func (c *Client) MultiGet(ctx context.Context, keys []string) (values [][]byte, found []bool, err error) {
+	resp := new(MultiGetResponse)
+	if err := c.invoke(ctx, "MultiGet", &MultiGetRequest{Keys: keys}, resp); err != nil {
+		return nil, nil, err
+	}
+	if len(resp.Results) != len(keys) {
+		return nil, nil, errBadWire
+	}
+	values = make([][]byte, len(keys))
+	found = make([]bool, len(keys))
+	for i, r := range resp.Results {
+		values[i], found[i] = r.Value, r.Found
+	}
+	return values, found, nil
+}
+
+// MultiPut 一次请求原子写入多个 key；每个 PutRequest 的 TTLMs 为 0 表示永不过期。
+func (c *Client) MultiPut(ctx context.Context, entries []PutRequest) error {
+	return c.invoke(ctx, "MultiPut", &MultiPutRequest{Entries: entries}, new(MultiPutResponse))
+}
+
+// Scan 流式读取 [start, end) 内的记录，每收到一条调用一次 fn；fn 返回 false 时提前结束。
+// limit 为 0 表示不限制。
+func (c *Client) Scan(ctx context.Context, start, end string, limit uint32, fn func(KeyValue) bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // 提前结束时通知服务端停止发送
+
+	desc := &serviceDesc.Streams[0]
+	stream, err := c.cc.NewStream(ctx, desc, "/"+serviceName+"/Scan", grpc.ForceCodec(codec{}))
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(&ScanRequest{Start: start, End: end, Limit: limit}); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+
+	for {
+		var kv KeyValue
+		if err := stream.RecvMsg(&kv); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if !fn(kv) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package rpc
+
+import "fmt"
+
+// codec 是 grpc 的编解码器，直接调用各消息手写的 protobuf 编解码。
+// 名称必须是 "proto"，这样使用标准生成代码的客户端才能互通。
+type codec struct{}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("rpc: cannot marshal %T", v)
+	}
+	return m.marshal(), nil
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("rpc: cannot unmarshal into %T", v)
+	}
+	return m.unmarshal(data)
+}
+
+func (codec) Name() string { return "proto" }
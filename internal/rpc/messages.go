@@ -0,0 +1,512 @@
+package rpc
+
+import (
+	"errors"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 本文件按 proto/forgedb.proto 手写 protobuf 编解码（没有使用 protoc 生成代码）。
+// 字段号和类型必须与 .proto 保持一致，其他语言的客户端才能互通。
+
+var errBadWire = errors.New("rpc: malformed protobuf message")
+
+// message 是所有请求 / 响应类型实现的编解码接口。
+type message interface {
+	marshal() []byte
+	unmarshal(b []byte) error
+}
+
+type GetRequest struct {
+	Key string
+}
+
+type GetResponse struct {
+	Found bool
+	Value []byte
+}
+
+type PutRequest struct {
+	Key   string
+	Value []byte
+	TTLMs int64
+}
+
+type PutResponse struct{}
+
+type DeleteRequest struct {
+	Key string
+}
+
+type DeleteResponse struct{}
+
+// BatchOpType 对应 BatchOp.Type 枚举。
+type BatchOpType int32
+
+const (
+	BatchGet    BatchOpType = 0
+	BatchPut    BatchOpType = 1
+	BatchDelete BatchOpType = 2
+)
+
+type BatchOp struct {
+	Type  BatchOpType
+	Key   string
+	Value []byte
+	TTLMs int64
+}
+
+type BatchRequest struct {
+	Ops []BatchOp
+}
+
+type BatchResult struct {
+	Found bool
+	Value []byte
+	Error string
+}
+
+type BatchResponse struct {
+	Results []BatchResult
+}
+
+type MultiGetRequest struct {
+	Keys []string
+}
+
+type MultiGetResponse struct {
+	Results []GetResponse
+}
+
+type MultiPutRequest struct {
+	Entries []PutRequest
+}
+
+type MultiPutResponse struct{}
+
+type ScanRequest struct {
+	Start string
+	End   string
+	Limit uint32
+}
+
+type KeyValue struct {
+	Key   string
+	Value []byte
+}
+
+// ---- 编码辅助：proto3 标量字段取默认值时不写出 ----
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	return appendVarint(b, num, 1)
+}
+
+func appendMessage(b []byte, num protowire.Number, m message) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m.marshal())
+}
+
+// walk 遍历 b 中的每个字段并调用 fn；fn 返回 false 表示不认识该字段，直接跳过。
+func walk(b []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) (n int, ok bool)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errBadWire
+		}
+		b = b[n:]
+
+		n, ok := fn(num, typ, b)
+		if !ok {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return errBadWire
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func consumeString(typ protowire.Type, b []byte, dst *string) (int, bool) {
+	if typ != protowire.BytesType {
+		return 0, false
+	}
+	v, n := protowire.ConsumeString(b)
+	*dst = v
+	return n, true
+}
+
+func consumeBytes(typ protowire.Type, b []byte, dst *[]byte) (int, bool) {
+	if typ != protowire.BytesType {
+		return 0, false
+	}
+	v, n := protowire.ConsumeBytes(b)
+	if n >= 0 {
+		*dst = append([]byte(nil), v...)
+	}
+	return n, true
+}
+
+func consumeVarint(typ protowire.Type, b []byte, dst *uint64) (int, bool) {
+	if typ != protowire.VarintType {
+		return 0, false
+	}
+	v, n := protowire.ConsumeVarint(b)
+	*dst = v
+	return n, true
+}
+
+// ---- 各消息的编解码 ----
+
+func (m *GetRequest) marshal() []byte { return appendString(nil, 1, m.Key) }
+
+func (m *GetRequest) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, bool) {
+		if num == 1 {
+			return consumeString(typ, b, &m.Key)
+		}
+		return 0, false
+	})
+}
+
+func (m *GetResponse) marshal() []byte {
+	b := appendBool(nil, 1, m.Found)
+	return appendBytes(b, 2, m.Value)
+}
+
+func (m *GetResponse) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, bool) {
+		switch num {
+		case 1:
+			var v uint64
+			n, ok := consumeVarint(typ, b, &v)
+			m.Found = v != 0
+			return n, ok
+		case 2:
+			return consumeBytes(typ, b, &m.Value)
+		}
+		return 0, false
+	})
+}
+
+func (m *PutRequest) marshal() []byte {
+	b := appendString(nil, 1, m.Key)
+	b = appendBytes(b, 2, m.Value)
+	return appendVarint(b, 3, uint64(m.TTLMs))
+}
+
+func (m *PutRequest) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, bool) {
+		switch num {
+		case 1:
+			return consumeString(typ, b, &m.Key)
+		case 2:
+			return consumeBytes(typ, b, &m.Value)
+		case 3:
+			var v uint64
+			n, ok := consumeVarint(typ, b, &v)
+			m.TTLMs = int64(v)
+			return n, ok
+		}
+		return 0, false
+	})
+}
+
+func (m *PutResponse) marshal() []byte { return nil }
+
+func (m *PutResponse) unmarshal(b []byte) error {
+	return walk(b, func(protowire.Number, protowire.Type, []byte) (int, bool) { return 0, false })
+}
+
+func (m *DeleteRequest) marshal() []byte { return appendString(nil, 1, m.Key) }
+
+func (m *DeleteRequest) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, bool) {
+		if num == 1 {
+			return consumeString(typ, b, &m.Key)
+		}
+		return 0, false
+	})
+}
+
+func (m *DeleteResponse) marshal() []byte { return nil }
+
+func (m *DeleteResponse) unmarshal(b []byte) error {
+	return walk(b, func(protowire.Number, protowire.Type, []byte) (int, bool) { return 0, false })
+}
+
+func (m *BatchOp) marshal() []byte {
+	b := appendVarint(nil, 1, uint64(m.Type))
+	b = appendString(b, 2, m.Key)
+	b = appendBytes(b, 3, m.Value)
+	return appendVarint(b, 4, uint64(m.TTLMs))
+}
+
+func (m *BatchOp) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, bool) {
+		var v uint64
+		switch num {
+		case 1:
+			n, ok := consumeVarint(typ, b, &v)
+			m.Type = BatchOpType(v)
+			return n, ok
+		case 2:
+			return consumeString(typ, b, &m.Key)
+		case 3:
+			return consumeBytes(typ, b, &m.Value)
+		case 4:
+			n, ok := consumeVarint(typ, b, &v)
+			m.TTLMs = int64(v)
+			return n, ok
+		}
+		return 0, false
+	})
+}
+
+func (m *BatchRequest) marshal() []byte {
+	var b []byte
+	for i := range m.Ops {
+		b = appendMessage(b, 1, &m.Ops[i])
+	}
+	return b
+}
+
+func (m *BatchRequest) unmarshal(b []byte) error {
+	var inner error
+	err := walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, bool) {
+		if num != 1 || typ != protowire.BytesType {
+			return 0, false
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, true
+		}
+		var op BatchOp
+		if err := op.unmarshal(v); err != nil {
+			inner = err
+			return -1, true
+		}
+		m.Ops = append(m.Ops, op)
+		return n, true
+	})
+	if inner != nil {
+		return inner
+	}
+	return err
+}
+
+func (m *BatchResult) marshal() []byte {
+	b := appendBool(nil, 1, m.Found)
+	b = appendBytes(b, 2, m.Value)
+	return appendString(b, 3, m.Error)
+}
+
+func (m *BatchResult) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, bool) {
+		switch num {
+		case 1:
+			var v uint64
+			n, ok := consumeVarint(typ, b, &v)
+			m.Found = v != 0
+			return n, ok
+		case 2:
+			return consumeBytes(typ, b, &m.Value)
+		case 3:
+			return consumeString(typ, b, &m.Error)
+		}
+		return 0, false
+	})
+}
+
+func (m *BatchResponse) marshal() []byte {
+	var b []byte
+	for i := range m.Results {
+		b = appendMessage(b, 1, &m.Results[i])
+	}
+	return b
+}
+
+func (m *BatchResponse) unmarshal(b []byte) error {
+	var inner error
+	err := walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, bool) {
+		if num != 1 || typ != protowire.BytesType {
+			return 0, false
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, true
+		}
+		var r BatchResult
+		if err := r.unmarshal(v); err != nil {
+			inner = err
+			return -1, true
+		}
+		m.Results = append(m.Results, r)
+		return n, true
+	})
+	if inner != nil {
+		return inner
+	}
+	return err
+}
+
+func (m *MultiGetRequest) marshal() []byte {
+	var b []byte
+	for _, k := range m.Keys {
+		// repeated 字段的空字符串也要写出，否则会丢掉位置
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, k)
+	}
+	return b
+}
+
+func (m *MultiGetRequest) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, bool) {
+		if num != 1 {
+			return 0, false
+		}
+		var k string
+		n, ok := consumeString(typ, b, &k)
+		if ok && n >= 0 {
+			m.Keys = append(m.Keys, k)
+		}
+		return n, ok
+	})
+}
+
+func (m *MultiGetResponse) marshal() []byte {
+	var b []byte
+	for i := range m.Results {
+		b = appendMessage(b, 1, &m.Results[i])
+	}
+	return b
+}
+
+func (m *MultiGetResponse) unmarshal(b []byte) error {
+	var inner error
+	err := walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, bool) {
+		if num != 1 || typ != protowire.BytesType {
+			return 0, false
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, true
+		}
+		var r GetResponse
+		if err := r.unmarshal(v); err != nil {
+			inner = err
+			return -1, true
+		}
+		m.Results = append(m.Results, r)
+		return n, true
+	})
+	if inner != nil {
+		return inner
+	}
+	return err
+}
+
+func (m *MultiPutRequest) marshal() []byte {
+	var b []byte
+	for i := range m.Entries {
+		b = appendMessage(b, 1, &m.Entries[i])
+	}
+	return b
+}
+
+func (m *MultiPutRequest) unmarshal(b []byte) error {
+	var inner error
+	err := walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, bool) {
+		if num != 1 || typ != protowire.BytesType {
+			return 0, false
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, true
+		}
+		var e PutRequest
+		if err := e.unmarshal(v); err != nil {
+			inner = err
+			return -1, true
+		}
+		m.Entries = append(m.Entries, e)
+		return n, true
+	})
+	if inner != nil {
+		return inner
+	}
+	return err
+}
+
+func (m *MultiPutResponse) marshal() []byte { return nil }
+
+func (m *MultiPutResponse) unmarshal(b []byte) error {
+	return walk(b, func(protowire.Number, protowire.Type, []byte) (int, bool) { return 0, false })
+}
+
+func (m *ScanRequest) marshal() []byte {
+	b := appendString(nil, 1, m.Start)
+	b = appendString(b, 2, m.End)
+	return appendVarint(b, 3, uint64(m.Limit))
+}
+
+func (m *ScanRequest) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, bool) {
+		switch num {
+		case 1:
+			return consumeString(typ, b, &m.Start)
+		case 2:
+			return consumeString(typ, b, &m.End)
+		case 3:
+			var v uint64
+			n, ok := consumeVarint(typ, b, &v)
+			m.Limit = uint32(v)
+			return n, ok
+		}
+		return 0, false
+	})
+}
+
+func (m *KeyValue) marshal() []byte {
+	b := appendString(nil, 1, m.Key)
+	return appendBytes(b, 2, m.Value)
+}
+
+func (m *KeyValue) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, bool) {
+		switch num {
+		case 1:
+			return consumeString(typ, b, &m.Key)
+		case 2:
+			return consumeBytes(typ, b, &m.Value)
+		}
+		return 0, false
+	})
+}
@@ -0,0 +1,249 @@
+// Package rpc 通过 gRPC 对外提供 ForgeDB 的读写接口，接口定义见 proto/forgedb.proto。
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"monolithdb/internal/db"
+)
+
+const serviceName = "forgedb.v1.ForgeDB"
+
+// ForgeDBServer 是 forgedb.v1.ForgeDB 服务的服务端接口。
+type ForgeDBServer interface {
+	Get(ctx context.Context, req *GetRequest) (*GetResponse, error)
+	Put(ctx context.Context, req *PutRequest) (*PutResponse, error)
+	Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error)
+	Batch(ctx context.Context, req *BatchRequest) (*BatchResponse, error)
+	MultiGet(ctx context.Context, req *MultiGetRequest) (*MultiGetResponse, error)
+	MultiPut(ctx context.Context, req *MultiPutRequest) (*MultiPutResponse, error)
+	Scan(req *ScanRequest, stream grpc.ServerStream) error
+}
+
+// NewServer 创建一个已注册 ForgeDB 服务的 grpc.Server。
+// 额外的 opts（拦截器、TLS 等）会原样传给 grpc.NewServer。
+func NewServer(d *db.DB, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{grpc.ForceServerCodec(codec{})}, opts...)
+	s := grpc.NewServer(opts...)
+	Register(s, &server{d: d})
+	return s
+}
+
+// Register 把 srv 注册到 s 上。s 必须使用本包的编解码器（见 NewServer）。
+func Register(s grpc.ServiceRegistrar, srv ForgeDBServer) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+// server 是 ForgeDBServer 基于 *db.DB 的实现。
+type server struct {
+	d *db.DB
+}
+
+func (s *server) Get(_ context.Context, req *GetRequest) (*GetResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty key")
+	}
+	v, ok, err := s.d.Get(req.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &GetResponse{Found: ok, Value: v}, nil
+}
+
+func (s *server) Put(_ context.Context, req *PutRequest) (*PutResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty key")
+	}
+	if req.TTLMs < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "negative ttl_ms %d", req.TTLMs)
+	}
+	if err := s.d.PutWithTTL(req.Key, req.Value, time.Duration(req.TTLMs)*time.Millisecond); err != nil {
+		return nil, toStatus(err)
+	}
+	return &PutResponse{}, nil
+}
+
+func (s *server) Delete(_ context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty key")
+	}
+	if err := s.d.Delete(req.Key); err != nil {
+		return nil, toStatus(err)
+	}
+	return &DeleteResponse{}, nil
+}
+
+// Batch 依次执行每个操作。与 HTTP 接口一样，批量操作不是原子的，错误按操作逐条返回。
+func (s *server) Batch(_ context.Context, req *BatchRequest) (*BatchResponse, error) {
+	resp := &BatchResponse{Results: make([]BatchResult, len(req.Ops))}
+	for i, op := range req.Ops {
+		res := &resp.Results[i]
+		if op.Key == "" {
+			res.Error = "empty key"
+			continue
+		}
+
+		switch op.Type {
+		case BatchGet:
+			v, ok, err := s.d.Get(op.Key)
+			if err != nil {
+				res.Error = err.Error()
+				continue
+			}
+			res.Found, res.Value = ok, v
+		case BatchPut:
+			if op.TTLMs < 0 {
+				res.Error = fmt.Sprintf("negative ttl_ms %d", op.TTLMs)
+				continue
+			}
+			if err := s.d.PutWithTTL(op.Key, op.Value, time.Duration(op.TTLMs)*time.Millisecond); err != nil {
+				res.Error = err.Error()
+			}
+		case BatchDelete:
+			if err := s.d.Delete(op.Key); err != nil {
+				res.Error = err.Error()
+			}
+		default:
+			res.Error = fmt.Sprintf("unknown op type %d", op.Type)
+		}
+	}
+	return resp, nil
+}
+
+// MultiGet 一次读取多个 key，所有 key 在同一个读锁内读取。
+func (s *server) MultiGet(_ context.Context, req *MultiGetRequest) (*MultiGetResponse, error) {
+	for _, k := range req.Keys {
+		if k == "" {
+			return nil, status.Error(codes.InvalidArgument, "empty key")
+		}
+	}
+	vals, found, err := s.d.MultiGet(req.Keys)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &MultiGetResponse{Results: make([]GetResponse, len(req.Keys))}
+	for i := range req.Keys {
+		resp.Results[i] = GetResponse{Found: found[i], Value: vals[i]}
+	}
+	return resp, nil
+}
+
+// MultiPut 把所有 entries 作为一个 Batch 原子写入；任一参数非法时什么都不写。
+func (s *server) MultiPut(_ context.Context, req *MultiPutRequest) (*MultiPutResponse, error) {
+	var b db.Batch
+	for _, e := range req.Entries {
+		if e.Key == "" {
+			return nil, status.Error(codes.InvalidArgument, "empty key")
+		}
+		if e.TTLMs < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "negative ttl_ms %d", e.TTLMs)
+		}
+		b.PutWithTTL(e.Key, e.Value, time.Duration(e.TTLMs)*time.Millisecond)
+	}
+	if err := s.d.Write(&b); err != nil {
+		return nil, toStatus(err)
+	}
+	return &MultiPutResponse{}, nil
+}
+
+// Scan 把 [start, end) 内的记录逐条发给客户端。
+// SendMsg 在 HTTP/2 流控窗口用完时会阻塞，所以客户端读得慢时服务端也会相应放慢；
+// 记录按块读取，服务端内存里最多只有一块，等待客户端时也不持有数据库的锁。
+func (s *server) Scan(req *ScanRequest, stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	var sent uint32
+	for chunk, err := range s.d.RangeChunks(req.Start, req.End, 0) {
+		if err != nil {
+			return toStatus(err)
+		}
+		for _, e := range chunk {
+			if req.Limit > 0 && sent >= req.Limit {
+				return nil
+			}
+			if err := ctx.Err(); err != nil {
+				return status.FromContextError(err).Err()
+			}
+			if err := stream.SendMsg(&KeyValue{Key: e.Key, Value: e.Value}); err != nil {
+				return err
+			}
+			sent++
+		}
+	}
+	return nil
+}
+
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, db.ErrReadOnly):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, db.ErrKeyTooLarge), errors.Is(err, db.ErrValueTooLarge):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// ---- 手写的服务描述（相当于 protoc-gen-go-grpc 生成的部分） ----
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*ForgeDBServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Get", Handler: unaryHandler("Get", func(s ForgeDBServer, ctx context.Context, req *GetRequest) (any, error) {
+			return s.Get(ctx, req)
+		})},
+		{MethodName: "Put", Handler: unaryHandler("Put", func(s ForgeDBServer, ctx context.Context, req *PutRequest) (any, error) {
+			return s.Put(ctx, req)
+		})},
+		{MethodName: "Delete", Handler: unaryHandler("Delete", func(s ForgeDBServer, ctx context.Context, req *DeleteRequest) (any, error) {
+			return s.Delete(ctx, req)
+		})},
+		{MethodName: "Batch", Handler: unaryHandler("Batch", func(s ForgeDBServer, ctx context.Context, req *BatchRequest) (any, error) {
+			return s.Batch(ctx, req)
+		})},
+		{MethodName: "MultiGet", Handler: unaryHandler("MultiGet", func(s ForgeDBServer, ctx context.Context, req *MultiGetRequest) (any, error) {
+			return s.MultiGet(ctx, req)
+		})},
+		{MethodName: "MultiPut", Handler: unaryHandler("MultiPut", func(s ForgeDBServer, ctx context.Context, req *MultiPutRequest) (any, error) {
+			return s.MultiPut(ctx, req)
+		})},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Scan", Handler: scanHandler, ServerStreams: true},
+	},
+	Metadata: "proto/forgedb.proto",
+}
+
+// unaryHandler 把类型化的方法包装成 grpc.MethodDesc 需要的 handler。
+func unaryHandler[Req any, PReq interface {
+	*Req
+	message
+}](method string, call func(ForgeDBServer, context.Context, PReq) (any, error)) func(any, context.Context, func(any) error, grpc.UnaryServerInterceptor) (any, error) {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		req := PReq(new(Req))
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return call(srv.(ForgeDBServer), ctx, req)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + method}
+		return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+			return call(srv.(ForgeDBServer), ctx, req.(PReq))
+		})
+	}
+}
+
+func scanHandler(srv any, stream grpc.ServerStream) error {
+	req := new(ScanRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(ForgeDBServer).Scan(req, stream)
+}
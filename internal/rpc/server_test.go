@@ -0,0 +1,170 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"monolithdb/internal/db"
+)
+
+func newTestClient(t *testing.T, d *db.DB) *Client {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(d)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	cc, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cc.Close() })
+	return NewClient(cc)
+}
+
+func TestGRPCKVBatchAndScan(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+
+	c := newTestClient(t, d)
+	ctx := context.Background()
+
+	for i := 0; i < 100; i++ {
+		k := fmt.Sprintf("k%03d", i)
+		if err := c.Put(ctx, k, []byte("v"+k), 0); err != nil {
+			t.Fatalf("Put %s: %v", k, err)
+		}
+	}
+
+	v, ok, err := c.Get(ctx, "k042")
+	if err != nil || !ok || string(v) != "vk042" {
+		t.Fatalf("Get k042: %q %v %v", v, ok, err)
+	}
+
+	if err := c.Delete(ctx, "k042"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := c.Get(ctx, "k042"); ok {
+		t.Fatalf("k042 should be deleted")
+	}
+
+	if _, _, err := c.Get(ctx, ""); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("empty key: want InvalidArgument, got %v", err)
+	}
+
+	res, err := c.Batch(ctx, []BatchOp{
+		{Type: BatchPut, Key: "x", Value: []byte("1")},
+		{Type: BatchGet, Key: "x"},
+		{Type: BatchDelete, Key: "x"},
+		{Type: BatchGet, Key: "x"},
+		{Type: BatchPut, Key: ""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 5 || !res[1].Found || string(res[1].Value) != "1" || res[3].Found || res[4].Error == "" {
+		t.Fatalf("unexpected batch results: %+v", res)
+	}
+
+	// 流式扫描：范围 + limit
+	var keys []string
+	err = c.Scan(ctx, "k040", "k050", 5, func(kv KeyValue) bool {
+		keys = append(keys, kv.Key)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"k040", "k041", "k043", "k044", "k045"}
+	if fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Fatalf("scan keys = %v, want %v", keys, want)
+	}
+
+	// 客户端提前结束
+	n := 0
+	err = c.Scan(ctx, "", "", 0, func(KeyValue) bool {
+		n++
+		return n < 10
+	})
+	if err != nil || n != 10 {
+		t.Fatalf("early stop: n=%d err=%v", n, err)
+	}
+}
+
+func TestGRPCMultiGetMultiPut(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+
+	c := newTestClient(t, d)
+	ctx := context.Background()
+
+	err = c.MultiPut(ctx, []PutRequest{
+		{Key: "a", Value: []byte("1")},
+		{Key: "b", Value: []byte("2"), TTLMs: 60_000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vals, found, err := c.MultiGet(ctx, []string{"a", "missing", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found[0] || string(vals[0]) != "1" || found[1] || !found[2] || string(vals[2]) != "2" {
+		t.Fatalf("unexpected MultiGet: %q %v", vals, found)
+	}
+
+	// 任一条非法时整批都不写入
+	err = c.MultiPut(ctx, []PutRequest{{Key: "c", Value: []byte("3")}, {Key: ""}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("empty key: want InvalidArgument, got %v", err)
+	}
+	if _, ok, _ := c.Get(ctx, "c"); ok {
+		t.Fatalf("c written by rejected MultiPut")
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	in := &BatchRequest{Ops: []BatchOp{
+		{Type: BatchPut, Key: "a", Value: []byte{0, 1, 2}, TTLMs: 1500},
+		{Type: BatchDelete, Key: "b"},
+	}}
+	var out BatchRequest
+	if err := out.unmarshal(in.marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(out) != fmt.Sprint(*in) {
+		t.Fatalf("round trip: got %+v want %+v", out, *in)
+	}
+
+	mg := &MultiGetRequest{Keys: []string{"a", "", "b"}}
+	var mgOut MultiGetRequest
+	if err := mgOut.unmarshal(mg.marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(mgOut) != fmt.Sprint(*mg) {
+		t.Fatalf("round trip: got %+v want %+v", mgOut, *mg)
+	}
+
+	if err := new(GetRequest).unmarshal([]byte{0x0a, 0x05, 'a'}); err == nil {
+		t.Fatalf("expected error on truncated message")
+	}
+}
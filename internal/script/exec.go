@@ -0,0 +1,27 @@
+package script
+
+import "monolithdb/internal/db"
+
+// Exec 在 d.Update 的回调里执行脚本：脚本里的读写在写锁内完成，中间不会插入其他写入；
+// 成功时所有写入作为一个 Batch 原子地提交，出错（包括 (error msg)）时全部丢弃。
+func (s *Script) Exec(d *db.DB, keys []string, args [][]byte) (Value, error) {
+	var v Value
+	err := d.Update(func(tx *db.UpdateTx) error {
+		var err error
+		v, err = s.Run(tx, keys, args)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Eval 编译并用 Exec 执行 src。
+func Eval(d *db.DB, src string, keys []string, args [][]byte) (Value, error) {
+	s, err := Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	return s.Exec(d, keys, args)
+}
@@ -0,0 +1,557 @@
+// Package script 实现一个很小的 S 表达式脚本语言，供网络服务端在写锁内原子地执行
+// “读取 - 修改 - 条件写入”这类多步操作（类似 Redis 的 EVAL）。
+//
+// 语法：
+//
+//	; 注释到行尾
+//	(let n (int (or (get (key 0)) "0")))
+//	(if (< n 100)
+//	    (do (put (key 0) (str (+ n 1))) n)
+//	    (error "limit reached"))
+//
+// 值只有五种：nil、字节串、int64、bool。nil 和 false 为假，其余都为真。
+// 语言没有循环和自定义函数，每个表达式最多求值一次，执行代价与脚本长度成正比。
+//
+// 特殊形式：
+//
+//	(do e...)            依次求值，返回最后一个
+//	(let name e)         把 e 的值绑定到变量 name 并返回它
+//	(if c then [else])   条件
+//	(and e...) (or e...) 短路求值，返回决定结果的那个值
+//
+// 内置函数：
+//
+//	(key i) (arg i)      第 i 个 key / 参数（从 0 开始）
+//	(get k)              读取，不存在时返回 nil
+//	(exists k)           key 是否存在
+//	(put k v [ttl-ms])   写入，返回 nil
+//	(del k)              删除，返回删除前 key 是否存在
+//	(= a b) (!= a b)     比较类型和值
+//	(< a b) (<= a b) (> a b) (>= a b)   整数按大小、字节串按字典序比较
+//	(+ a b...) (- a b...) (* a b...) (/ a b) (% a b)   整数运算
+//	(int x) (str x)      字节串与整数互转
+//	(concat a b...) (len x) (not x) (nil? x)
+//	(error msg)          中止脚本，丢弃所有写入，返回 ErrAborted
+package script
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrSyntax 表示脚本无法解析（括号不匹配、未知函数、参数个数不对等）。
+	ErrSyntax = errors.New("script: syntax error")
+	// ErrRuntime 表示脚本执行出错（类型不对、下标越界、除以零等）。
+	ErrRuntime = errors.New("script: runtime error")
+	// ErrAborted 表示脚本调用了 (error msg)。
+	ErrAborted = errors.New("script: aborted")
+)
+
+// Value 是脚本里的值：nil、[]byte、int64 或 bool。
+type Value = any
+
+// Store 是脚本读写数据的接口，由 db.UpdateTx 实现。
+type Store interface {
+	Get(key string) ([]byte, bool, error)
+	PutWithTTL(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// Script 是编译好的脚本，可以并发地多次执行。
+type Script struct {
+	prog []*node
+}
+
+type nodeKind int
+
+const (
+	nodeLit nodeKind = iota
+	nodeSym
+	nodeList
+)
+
+type node struct {
+	kind nodeKind
+	val  Value   // nodeLit
+	sym  string  // nodeSym，以及 nodeList 的函数名
+	args []*node // nodeList
+	pos  int
+}
+
+// arity 是特殊形式和内置函数的参数个数范围，max < 0 表示不限。
+var arity = map[string][2]int{
+	"do": {1, -1}, "let": {2, 2}, "if": {2, 3}, "and": {1, -1}, "or": {1, -1},
+	"key": {1, 1}, "arg": {1, 1},
+	"get": {1, 1}, "exists": {1, 1}, "put": {2, 3}, "del": {1, 1},
+	"=": {2, 2}, "!=": {2, 2}, "<": {2, 2}, "<=": {2, 2}, ">": {2, 2}, ">=": {2, 2},
+	"+": {1, -1}, "-": {1, -1}, "*": {1, -1}, "/": {2, 2}, "%": {2, 2},
+	"int": {1, 1}, "str": {1, 1}, "concat": {1, -1}, "len": {1, 1}, "not": {1, 1}, "nil?": {1, 1},
+	"error": {1, 1},
+}
+
+// Compile 解析 src。脚本由一个或多个表达式组成，执行结果是最后一个表达式的值。
+func Compile(src string) (*Script, error) {
+	p := &parser{src: src}
+	var prog []*node
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			break
+		}
+		n, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		prog = append(prog, n)
+	}
+	if len(prog) == 0 {
+		return nil, fmt.Errorf("%w: empty script", ErrSyntax)
+	}
+	return &Script{prog: prog}, nil
+}
+
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w at offset %d: %s", ErrSyntax, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ';':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) parse() (*node, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("unexpected end of script")
+	}
+	start := p.pos
+	switch c := p.src[p.pos]; c {
+	case '(':
+		p.pos++
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] == '(' || p.src[p.pos] == ')' || p.src[p.pos] == '"' {
+			return nil, p.errorf("expected a function name")
+		}
+		name := p.atom()
+		ar, ok := arity[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown function %q", ErrSyntax, name)
+		}
+		n := &node{kind: nodeList, sym: name, pos: start}
+		for {
+			p.skipSpace()
+			if p.pos >= len(p.src) {
+				return nil, p.errorf("unclosed '('")
+			}
+			if p.src[p.pos] == ')' {
+				p.pos++
+				break
+			}
+			arg, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			n.args = append(n.args, arg)
+		}
+		if len(n.args) < ar[0] || (ar[1] >= 0 && len(n.args) > ar[1]) {
+			return nil, fmt.Errorf("%w: wrong number of arguments for %q", ErrSyntax, name)
+		}
+		if name == "let" && n.args[0].kind != nodeSym {
+			return nil, fmt.Errorf("%w: let needs a variable name", ErrSyntax)
+		}
+		return n, nil
+	case ')':
+		return nil, p.errorf("unexpected ')'")
+	case '"':
+		p.pos++
+		for p.pos < len(p.src) && p.src[p.pos] != '"' {
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated string")
+		}
+		p.pos++
+		s, err := strconv.Unquote(p.src[start:p.pos])
+		if err != nil {
+			return nil, p.errorf("bad string literal")
+		}
+		return &node{kind: nodeLit, val: []byte(s), pos: start}, nil
+	}
+
+	a := p.atom()
+	if n, err := strconv.ParseInt(a, 10, 64); err == nil {
+		return &node{kind: nodeLit, val: n, pos: start}, nil
+	}
+	switch a {
+	case "nil":
+		return &node{kind: nodeLit, val: nil, pos: start}, nil
+	case "true", "false":
+		return &node{kind: nodeLit, val: a == "true", pos: start}, nil
+	}
+	return &node{kind: nodeSym, sym: a, pos: start}, nil
+}
+
+// atom 读到空白、括号、引号或注释为止。
+func (p *parser) atom() string {
+	start := p.pos
+	for p.pos < len(p.src) && !strings.ContainsRune(" \t\r\n()\";", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// Run 用 st 执行脚本，keys / args 由 (key i) / (arg i) 读取，返回最后一个表达式的值。
+// 出错时已经执行的写入不会被撤销：需要原子性时在 db.DB.Update 的回调里执行（见 Eval）。
+func (s *Script) Run(st Store, keys []string, args [][]byte) (Value, error) {
+	in := &interp{st: st, keys: keys, args: args, vars: make(map[string]Value)}
+	var v Value
+	for _, n := range s.prog {
+		var err error
+		if v, err = in.eval(n); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+type interp struct {
+	st   Store
+	keys []string
+	args [][]byte
+	vars map[string]Value
+}
+
+func runtimeErr(n *node, format string, args ...any) error {
+	return fmt.Errorf("%w: (%s) at offset %d: %s", ErrRuntime, n.sym, n.pos, fmt.Sprintf(format, args...))
+}
+
+func truthy(v Value) bool {
+	return v != nil && v != false
+}
+
+func (in *interp) eval(n *node) (Value, error) {
+	switch n.kind {
+	case nodeLit:
+		return n.val, nil
+	case nodeSym:
+		v, ok := in.vars[n.sym]
+		if !ok {
+			return nil, fmt.Errorf("%w: undefined variable %q at offset %d", ErrRuntime, n.sym, n.pos)
+		}
+		return v, nil
+	}
+
+	// 特殊形式：参数按需求值
+	switch n.sym {
+	case "do":
+		var v Value
+		for _, a := range n.args {
+			var err error
+			if v, err = in.eval(a); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case "let":
+		v, err := in.eval(n.args[1])
+		if err != nil {
+			return nil, err
+		}
+		in.vars[n.args[0].sym] = v
+		return v, nil
+	case "if":
+		c, err := in.eval(n.args[0])
+		if err != nil {
+			return nil, err
+		}
+		if truthy(c) {
+			return in.eval(n.args[1])
+		}
+		if len(n.args) == 3 {
+			return in.eval(n.args[2])
+		}
+		return nil, nil
+	case "and", "or":
+		var v Value
+		for _, a := range n.args {
+			var err error
+			if v, err = in.eval(a); err != nil {
+				return nil, err
+			}
+			if truthy(v) != (n.sym == "and") {
+				return v, nil
+			}
+		}
+		return v, nil
+	}
+
+	args := make([]Value, len(n.args))
+	for i, a := range n.args {
+		var err error
+		if args[i], err = in.eval(a); err != nil {
+			return nil, err
+		}
+	}
+	return in.call(n, args)
+}
+
+func (in *interp) call(n *node, args []Value) (Value, error) {
+	bytesArg := func(i int) ([]byte, error) {
+		b, ok := args[i].([]byte)
+		if !ok {
+			return nil, runtimeErr(n, "argument %d must be a string, got %s", i+1, typeName(args[i]))
+		}
+		return b, nil
+	}
+	intArg := func(i int) (int64, error) {
+		v, ok := args[i].(int64)
+		if !ok {
+			return 0, runtimeErr(n, "argument %d must be an integer, got %s", i+1, typeName(args[i]))
+		}
+		return v, nil
+	}
+
+	switch n.sym {
+	case "key", "arg":
+		i, err := intArg(0)
+		if err != nil {
+			return nil, err
+		}
+		if n.sym == "key" {
+			if i < 0 || i >= int64(len(in.keys)) {
+				return nil, runtimeErr(n, "index %d out of range (%d keys)", i, len(in.keys))
+			}
+			return []byte(in.keys[i]), nil
+		}
+		if i < 0 || i >= int64(len(in.args)) {
+			return nil, runtimeErr(n, "index %d out of range (%d args)", i, len(in.args))
+		}
+		return in.args[i], nil
+
+	case "get", "exists", "del":
+		k, err := bytesArg(0)
+		if err != nil {
+			return nil, err
+		}
+		v, ok, err := in.st.Get(string(k))
+		if err != nil {
+			return nil, err
+		}
+		switch n.sym {
+		case "get":
+			if !ok {
+				return nil, nil
+			}
+			if v == nil {
+				v = []byte{}
+			}
+			return v, nil
+		case "exists":
+			return ok, nil
+		}
+		if ok {
+			if err := in.st.Delete(string(k)); err != nil {
+				return nil, err
+			}
+		}
+		return ok, nil
+
+	case "put":
+		k, err := bytesArg(0)
+		if err != nil {
+			return nil, err
+		}
+		v, err := bytesArg(1)
+		if err != nil {
+			return nil, err
+		}
+		var ttl time.Duration
+		if len(args) == 3 {
+			ms, err := intArg(2)
+			if err != nil {
+				return nil, err
+			}
+			ttl = time.Duration(ms) * time.Millisecond
+		}
+		return nil, in.st.PutWithTTL(string(k), v, ttl)
+
+	case "=", "!=":
+		eq := equal(args[0], args[1])
+		return eq == (n.sym == "="), nil
+
+	case "<", "<=", ">", ">=":
+		c, err := compare(n, args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		switch n.sym {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+
+	case "+", "-", "*", "/", "%":
+		acc, err := intArg(0)
+		if err != nil {
+			return nil, err
+		}
+		if len(args) == 1 && n.sym == "-" {
+			return -acc, nil
+		}
+		for i := 1; i < len(args); i++ {
+			v, err := intArg(i)
+			if err != nil {
+				return nil, err
+			}
+			switch n.sym {
+			case "+":
+				acc += v
+			case "-":
+				acc -= v
+			case "*":
+				acc *= v
+			default:
+				if v == 0 {
+					return nil, runtimeErr(n, "division by zero")
+				}
+				if n.sym == "/" {
+					acc /= v
+				} else {
+					acc %= v
+				}
+			}
+		}
+		return acc, nil
+
+	case "int":
+		switch v := args[0].(type) {
+		case int64:
+			return v, nil
+		case []byte:
+			i, err := strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64)
+			if err != nil {
+				return nil, runtimeErr(n, "%q is not an integer", v)
+			}
+			return i, nil
+		}
+		return nil, runtimeErr(n, "cannot convert %s to an integer", typeName(args[0]))
+
+	case "str":
+		switch v := args[0].(type) {
+		case nil:
+			return []byte{}, nil
+		case []byte:
+			return v, nil
+		case int64:
+			return []byte(strconv.FormatInt(v, 10)), nil
+		case bool:
+			return []byte(strconv.FormatBool(v)), nil
+		}
+
+	case "concat":
+		var out []byte
+		for i := range args {
+			b, err := bytesArg(i)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, b...)
+		}
+		if out == nil {
+			out = []byte{}
+		}
+		return out, nil
+
+	case "len":
+		b, err := bytesArg(0)
+		if err != nil {
+			return nil, err
+		}
+		return int64(len(b)), nil
+
+	case "not":
+		return !truthy(args[0]), nil
+
+	case "nil?":
+		return args[0] == nil, nil
+
+	case "error":
+		msg, err := bytesArg(0)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %s", ErrAborted, msg)
+	}
+	return nil, runtimeErr(n, "not implemented")
+}
+
+func equal(a, b Value) bool {
+	switch x := a.(type) {
+	case []byte:
+		y, ok := b.([]byte)
+		return ok && bytes.Equal(x, y)
+	default:
+		return a == b
+	}
+}
+
+func compare(n *node, a, b Value) (int, error) {
+	switch x := a.(type) {
+	case int64:
+		if y, ok := b.(int64); ok {
+			switch {
+			case x < y:
+				return -1, nil
+			case x > y:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case []byte:
+		if y, ok := b.([]byte); ok {
+			return bytes.Compare(x, y), nil
+		}
+	}
+	return 0, runtimeErr(n, "cannot compare %s with %s", typeName(a), typeName(b))
+}
+
+func typeName(v Value) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case []byte:
+		return "string"
+	case int64:
+		return "integer"
+	case bool:
+		return "bool"
+	}
+	return fmt.Sprintf("%T", v)
+}
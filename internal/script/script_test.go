@@ -0,0 +1,111 @@
+package script
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/db"
+)
+
+const incrScript = `
+; 计数器加一，超过上限时中止
+(let n (int (or (get (key 0)) "0")))
+(if (>= n (int (arg 0)))
+    (error "limit reached")
+    (do (put (key 0) (str (+ n 1)))
+        (+ n 1)))
+`
+
+func TestEvalCounter(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	s, err := Compile(incrScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for want := int64(1); want <= 2; want++ {
+		v, err := s.Exec(d, []string{"hits"}, [][]byte{[]byte("2")})
+		if err != nil || v != want {
+			t.Fatalf("Exec = %v, %v; want %d", v, err, want)
+		}
+	}
+	if _, err := s.Exec(d, []string{"hits"}, [][]byte{[]byte("2")}); !errors.Is(err, ErrAborted) {
+		t.Fatalf("over the limit: err = %v", err)
+	}
+	if v, _, _ := d.Get("hits"); string(v) != "2" {
+		t.Fatalf("hits = %q", v)
+	}
+}
+
+func TestEvalAbortDiscardsWrites(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Put("src", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 先写后中止：之前的写入和删除都不能生效
+	_, err = Eval(d, `(put "dst" (get "src")) (del "src") (error "nope")`, nil, nil)
+	if !errors.Is(err, ErrAborted) {
+		t.Fatalf("err = %v", err)
+	}
+	if _, ok, _ := d.Get("dst"); ok {
+		t.Fatal("write from aborted script is visible")
+	}
+
+	// 脚本内的读能看到自己之前的写
+	v, err := Eval(d, `(put "dst" (get "src")) (del "src") (and (not (exists "src")) (get "dst"))`, nil, nil)
+	if err != nil || string(v.([]byte)) != "v" {
+		t.Fatalf("move = %v, %v", v, err)
+	}
+	if _, ok, _ := d.Get("src"); ok {
+		t.Fatal("src should be deleted")
+	}
+}
+
+func TestCompileAndRuntimeErrors(t *testing.T) {
+	for _, src := range []string{``, `(get "a"`, `(frobnicate 1)`, `(put "a")`, `(let 1 2)`, `)`, `("a")`, `"unterminated`} {
+		if _, err := Compile(src); !errors.Is(err, ErrSyntax) {
+			t.Errorf("Compile(%q): err = %v, want ErrSyntax", src, err)
+		}
+	}
+
+	d, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, src := range []string{`(+ 1 "a")`, `(/ 1 0)`, `(key 0)`, `undefined`, `(int "x")`, `(< 1 "a")`} {
+		if _, err := Eval(d, src, nil, nil); !errors.Is(err, ErrRuntime) {
+			t.Errorf("Eval(%q): err = %v, want ErrRuntime", src, err)
+		}
+	}
+
+	cases := map[string]Value{
+		`(concat "a" (str 12) (str true))`: []byte("a12true"),
+		`(- 10 3 2)`:                       int64(5),
+		`(- 4)`:                            int64(-4),
+		`(% 7 3)`:                          int64(1),
+		`(or nil false "x")`:               []byte("x"),
+		`(and 1 nil 2)`:                    nil,
+		`(if false 1)`:                     nil,
+		`(= "a" "a")`:                      true,
+		`(!= 1 "1")`:                       true,
+		`(nil? (get "missing"))`:           true,
+		`(len "hello")`:                    int64(5),
+	}
+	for src, want := range cases {
+		v, err := Eval(d, src, nil, nil)
+		if err != nil || !equal(v, want) {
+			t.Errorf("Eval(%q) = %v, %v; want %v", src, v, err, want)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package snappy 实现 snappy 的 block 格式（不是 framing 格式）：开头是解压后长度的 varint，
+// 之后是一串 literal / copy 元素，每个元素的 tag 低两位表示类型。
+//
+// 压缩是 snappy 参考实现的贪心哈希匹配：只找 4 字节以上的重复、不回溯，换取很低的 CPU 开销，
+// 适合 WAL 这样每次写入都要压缩的热路径。
+package snappy
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrCorrupt 表示输入不是合法的 snappy 块。
+var ErrCorrupt = errors.New("snappy: corrupt input")
+
+const (
+	tagLiteral = 0
+	tagCopy1   = 1 // 4–11 字节，11 位偏移
+	tagCopy2   = 2 // 1–64 字节，16 位偏移
+	tagCopy4   = 3 // 1–64 字节，32 位偏移
+)
+
+const (
+	// maxBlockSize 是压缩时每段输入的大小：段内的偏移都能用 16 位表示，各段之间不互相引用。
+	maxBlockSize = 1 << 16
+
+	// inputMargin 是段尾不找匹配的字节数，匹配时可以直接读 8 字节而不检查越界。
+	inputMargin = 16 - 1
+
+	// minNonLiteralBlockSize 以下的段直接写成 literal。
+	minNonLiteralBlockSize = 1 + 1 + inputMargin
+
+	tableBits = 14
+	tableSize = 1 << tableBits
+
+	// maxDecodedLen 是能解压的最大长度，防止坏数据的长度字段导致超大分配。
+	maxDecodedLen = 1 << 31
+
+	// preallocRatio 是解压前按输入大小预分配输出的倍数上限：开头的长度来自数据本身，不可信，
+	// 压缩率更高的块在解压时再扩容。
+	preallocRatio = 4
+)
+
+// Encode 把 src 压缩成一个 snappy 块，追加到 dst 后面返回。
+func Encode(dst, src []byte) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(src)))
+	for len(src) > 0 {
+		p := src
+		if len(p) > maxBlockSize {
+			p = p[:maxBlockSize]
+		}
+		src = src[len(p):]
+		if len(p) < minNonLiteralBlockSize {
+			dst = emitLiteral(dst, p)
+		} else {
+			dst = encodeBlock(dst, p)
+		}
+	}
+	return dst
+}
+
+// encodeBlock 压缩不超过 maxBlockSize 的一段。table 按 4 字节的哈希记录最近出现的位置，
+// 连续找不到匹配时逐渐加大步长，不可压缩的数据很快跳过。
+func encodeBlock(dst, src []byte) []byte {
+	var table [tableSize]uint16
+	sLimit := len(src) - inputMargin
+	nextEmit := 0
+	s := 1
+	nextHash := hash(load32(src, s))
+
+encode:
+	for {
+		skip := 32
+		nextS := s
+		candidate := 0
+		for {
+			s = nextS
+			step := skip >> 5
+			nextS = s + step
+			skip += step
+			if nextS > sLimit {
+				break encode
+			}
+			candidate = int(table[nextHash])
+			table[nextHash] = uint16(s)
+			nextHash = hash(load32(src, nextS))
+			if load32(src, s) == load32(src, candidate) {
+				break
+			}
+		}
+
+		dst = emitLiteral(dst, src[nextEmit:s])
+		for {
+			// 已经确认前 4 个字节相同，再往后延长
+			base := s
+			s += 4
+			for i := candidate + 4; s < len(src) && src[i] == src[s]; i, s = i+1, s+1 {
+			}
+			dst = emitCopy(dst, base-candidate, s-base)
+			nextEmit = s
+			if s >= sLimit {
+				break encode
+			}
+
+			// 紧跟着的位置也可能是一个匹配：补上 s-1 的哈希，再检查 s
+			x := load64(src, s-1)
+			table[hash(uint32(x))] = uint16(s - 1)
+			currHash := hash(uint32(x >> 8))
+			candidate = int(table[currHash])
+			table[currHash] = uint16(s)
+			if uint32(x>>8) != load32(src, candidate) {
+				nextHash = hash(uint32(x >> 16))
+				s++
+				break
+			}
+		}
+	}
+
+	if nextEmit < len(src) {
+		dst = emitLiteral(dst, src[nextEmit:])
+	}
+	return dst
+}
+
+// emitLiteral 写出一个 literal 元素：长度 - 1 放在 tag 高 6 位，>= 60 时后面跟着 1–4 字节的长度。
+func emitLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+	n := uint32(len(lit) - 1)
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|tagLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|tagLiteral, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2|tagLiteral, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2|tagLiteral, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2|tagLiteral, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, lit...)
+}
+
+// emitCopy 写出复制 offset 之前 length 个字节的 copy 元素（offset < maxBlockSize，length >= 4）。
+// 一个元素最多 64 字节，更长的拆开，并保证最后一个至少 4 字节。
+func emitCopy(dst []byte, offset, length int) []byte {
+	for length >= 68 {
+		dst = append(dst, 63<<2|tagCopy2, byte(offset), byte(offset>>8))
+		length -= 64
+	}
+	if length > 64 {
+		dst = append(dst, 59<<2|tagCopy2, byte(offset), byte(offset>>8))
+		length -= 60
+	}
+	if length >= 12 || offset >= 2048 {
+		return append(dst, byte(length-1)<<2|tagCopy2, byte(offset), byte(offset>>8))
+	}
+	return append(dst, byte(offset>>8)<<5|byte(length-4)<<2|tagCopy1, byte(offset))
+}
+
+func load32(b []byte, i int) uint32 { return binary.LittleEndian.Uint32(b[i:]) }
+
+func load64(b []byte, i int) uint64 { return binary.LittleEndian.Uint64(b[i:]) }
+
+func hash(u uint32) uint32 { return (u * 0x1e35a7bd) >> (32 - tableBits) }
+
+// Decode 解压一个 snappy 块。输出超过开头声明的长度、偏移越界、数据被截断时返回 ErrCorrupt。
+func Decode(src []byte) ([]byte, error) {
+	n, p := binary.Uvarint(src)
+	if p <= 0 || n > maxDecodedLen {
+		return nil, ErrCorrupt
+	}
+	dst := make([]byte, 0, min(n, uint64(len(src))*preallocRatio))
+	for p < len(src) {
+		tag := src[p]
+		p++
+		var length, offset int
+		switch tag & 3 {
+		case tagLiteral:
+			length = int(tag >> 2)
+			if length >= 60 {
+				k := length - 59
+				if p+k > len(src) {
+					return nil, ErrCorrupt
+				}
+				length = 0
+				for i := k - 1; i >= 0; i-- {
+					length = length<<8 | int(src[p+i])
+				}
+				p += k
+			}
+			length++
+			if length <= 0 || length > len(src)-p || uint64(len(dst)+length) > n {
+				return nil, ErrCorrupt
+			}
+			dst = append(dst, src[p:p+length]...)
+			p += length
+			continue
+		case tagCopy1:
+			if p >= len(src) {
+				return nil, ErrCorrupt
+			}
+			length = 4 + int(tag>>2&7)
+			offset = int(tag&0xe0)<<3 | int(src[p])
+			p++
+		case tagCopy2:
+			if p+2 > len(src) {
+				return nil, ErrCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[p:]))
+			p += 2
+		case tagCopy4:
+			if p+4 > len(src) {
+				return nil, ErrCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[p:]))
+			p += 4
+		}
+		if offset <= 0 || offset > len(dst) || uint64(len(dst)+length) > n {
+			return nil, ErrCorrupt
+		}
+		// 源和目标可能重叠（offset < length），只能逐字节复制
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if uint64(len(dst)) != n {
+		return nil, ErrCorrupt
+	}
+	return dst, nil
+}
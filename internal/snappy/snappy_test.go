@@ -0,0 +1,77 @@
+package snappy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand/v2"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	random := make([]byte, 100000)
+	for i := range random {
+		random[i] = byte(rng.IntN(256))
+	}
+	json := []byte(strings.Repeat(`{"name":"forge","tags":["a","b"],"count":42},`, 4000))
+
+	for name, src := range map[string][]byte{
+		"empty":      nil,
+		"short":      []byte("abc"),
+		"repeated":   bytes.Repeat([]byte{'x'}, 200000),
+		"json":       json,
+		"random":     random,
+		"mixed":      append(append([]byte{}, random[:5000]...), json[:70000]...),
+		"long match": append(bytes.Repeat([]byte("0123456789abcdef"), 10), random[:3000]...),
+	} {
+		enc := Encode(nil, src)
+		got, err := Decode(enc)
+		if err != nil || !bytes.Equal(got, src) {
+			t.Fatalf("%s: round trip failed: err=%v len=%d want %d", name, err, len(got), len(src))
+		}
+		if name == "json" && len(enc) > len(src)/5 {
+			t.Fatalf("json compressed to %d of %d bytes", len(enc), len(src))
+		}
+	}
+
+	// 追加到 dst 后面
+	enc := Encode([]byte("prefix"), []byte("abc"))
+	if !bytes.HasPrefix(enc, []byte("prefix")) {
+		t.Fatalf("Encode did not append to dst: %q", enc)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	// literal "abc"，然后 copy(offset 3, length 9)
+	got, err := Decode([]byte{12, 2 << 2, 'a', 'b', 'c', 5<<2 | 1, 3})
+	if err != nil || string(got) != "abcabcabcabc" {
+		t.Fatalf("Decode = %q, %v", got, err)
+	}
+	for _, bad := range [][]byte{
+		{},
+		{5, 2 << 2, 'a'},                        // literal 被截断
+		{4, 3<<2 | 2, 9, 0},                     // copy 的偏移越界
+		{12, 2 << 2, 'a', 'b', 'c'},             // 比声明的短
+		{3, 2 << 2, 'a', 'b', 'c', 5<<2 | 1, 3}, // copy 之后超过声明的长度
+	} {
+		if _, err := Decode(bad); !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("Decode(%v) = %v, want ErrCorrupt", bad, err)
+		}
+	}
+
+	// 开头声称解压后有 2 GiB：不能照着预分配
+	huge := binary.AppendUvarint(nil, 1<<31)
+	huge = append(huge, 2<<2, 'a', 'b', 'c')
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := Decode(huge); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Decode(huge header) = %v, want ErrCorrupt", err)
+	}
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Fatalf("Decode allocated %d bytes for a %d-byte input", n, len(huge))
+	}
+}
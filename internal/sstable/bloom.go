@@ -2,9 +2,57 @@ package sstable
 
 import (
 	"encoding/binary"
+	"fmt"
 	"hash/fnv"
+	"math"
 )
 
+// MaxBloomHashes 是 WriterOptions.BloomHashes 的上限。
+const MaxBloomHashes = 30
+
+// 没有设置 BloomBitsPerKey / BloomFalsePositiveRate 时使用的固定参数。
+const (
+	defaultBloomBits   = 1 << 20
+	defaultBloomHashes = 7
+)
+
+// ValidateBloomOptions 检查 opts 中 bloom 相关的配置是否合法。
+func ValidateBloomOptions(opts WriterOptions) error {
+	switch {
+	case opts.BloomBitsPerKey < 0:
+		return fmt.Errorf("sstable: negative BloomBitsPerKey %d", opts.BloomBitsPerKey)
+	case opts.BloomFalsePositiveRate < 0 || opts.BloomFalsePositiveRate >= 1 || math.IsNaN(opts.BloomFalsePositiveRate):
+		return fmt.Errorf("sstable: BloomFalsePositiveRate %v is not in [0, 1)", opts.BloomFalsePositiveRate)
+	case opts.BloomHashes < 0 || opts.BloomHashes > MaxBloomHashes:
+		return fmt.Errorf("sstable: BloomHashes %d is not in [0, %d]", opts.BloomHashes, MaxBloomHashes)
+	}
+	return nil
+}
+
+// bloomParams 返回 n 个 key 的表使用的 bloom 位数和哈希次数（见 WriterOptions.BloomBitsPerKey）。
+// opts 已经通过 ValidateBloomOptions 检查。
+func bloomParams(n int, opts WriterOptions) (uint32, uint8) {
+	bitsPerKey := float64(opts.BloomBitsPerKey)
+	if p := opts.BloomFalsePositiveRate; p > 0 {
+		// 最优哈希次数下误判率 p ≈ exp(-bits * ln2^2)
+		bitsPerKey = -math.Log(p) / (math.Ln2 * math.Ln2)
+	}
+	if bitsPerKey == 0 {
+		k := defaultBloomHashes
+		if opts.BloomHashes > 0 {
+			k = opts.BloomHashes
+		}
+		return defaultBloomBits, uint8(k)
+	}
+
+	k := opts.BloomHashes
+	if k == 0 {
+		k = min(max(int(math.Round(bitsPerKey*math.Ln2)), 1), MaxBloomHashes)
+	}
+	m := math.Ceil(bitsPerKey * float64(max(n, 1)))
+	return uint32(min(m, math.MaxUint32)), uint8(k)
+}
+
 type bloom struct {
 	m uint32 // 总位数 (bit 数)
 	k uint8  // 哈希函数次数
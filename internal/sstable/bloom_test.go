@@ -110,3 +110,109 @@ func TestBloomSkipsCorruptIndexOnMiss(t *testing.T) {
 		t.Fatalf("expected NotFound with nil value, got res=%v v=%v", res, v)
 	}
 }
+
+func TestIgnoreBloomBypassesStaleFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "000001.sst")
+	entries := []types.Entry{{Key: "a", Value: []byte("1")}, {Key: "b", Value: []byte("2")}}
+	if err := WriteTable(path, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	// 清空 bloom 的位图：模拟损坏 / 过期的 filter，所有 key 都会被判定为不存在
+	ti, err := Describe(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(make([]byte, ti.BloomBytes), int64(ti.BloomStart)+12); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	if _, res, err := GetEntry(path, "b"); err != nil || res != NotFound {
+		t.Fatalf("with bloom: res=%v err=%v", res, err)
+	}
+	e, res, err := GetEntryWithOptions(path, "b", ReadOptions{IgnoreBloom: true})
+	if err != nil || res != Found || string(e.Value) != "2" {
+		t.Fatalf("ignore bloom: %+v res=%v err=%v", e, res, err)
+	}
+}
+
+func TestBloomParametersFromOptions(t *testing.T) {
+	const n = 1000
+	entries := make([]types.Entry, 0, n)
+	for i := 0; i < n; i++ {
+		entries = append(entries, types.Entry{Key: fmt.Sprintf("k%06d", i), Value: []byte("v")})
+	}
+
+	for _, tc := range []struct {
+		name     string
+		opts     WriterOptions
+		bits     uint32
+		hashes   uint8
+		maxFalse int // 10000 个不存在的 key 中最多允许的误判数
+	}{
+		{"default", WriterOptions{}, defaultBloomBits, defaultBloomHashes, 10},
+		{"bits-per-key", WriterOptions{BloomBitsPerKey: 10}, 10 * n, 7, 300},
+		{"bits-and-hashes", WriterOptions{BloomBitsPerKey: 10, BloomHashes: 3}, 10 * n, 3, 500},
+		{"fp-rate", WriterOptions{BloomFalsePositiveRate: 0.01, BloomBitsPerKey: 100}, 9586, 7, 300},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "000001.sst")
+			tc.opts.NoSync = true
+			if err := WriteTableWithOptions(path, entries, tc.opts); err != nil {
+				t.Fatal(err)
+			}
+			ti, err := Describe(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ti.BloomBits != tc.bits || ti.BloomHashes != tc.hashes {
+				t.Fatalf("bloom bits=%d hashes=%d, want %d, %d", ti.BloomBits, ti.BloomHashes, tc.bits, tc.hashes)
+			}
+
+			// 读表只依赖表里记录的参数
+			r, err := OpenReader(path, ReadOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			for _, e := range entries {
+				if _, res, err := r.GetEntry(e.Key); err != nil || res != Found {
+					t.Fatalf("GetEntry(%q) = %v, %v", e.Key, res, err)
+				}
+			}
+			bf, err := r.filter()
+			if err != nil {
+				t.Fatal(err)
+			}
+			falsePositives := 0
+			for i := 0; i < 10000; i++ {
+				if bf.mayContain(fmt.Sprintf("missing%06d", i)) {
+					falsePositives++
+				}
+			}
+			if falsePositives > tc.maxFalse {
+				t.Fatalf("%d false positives out of 10000", falsePositives)
+			}
+		})
+	}
+}
+
+func TestBloomOptionsValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "000001.sst")
+	entries := []types.Entry{{Key: "a", Value: []byte("1")}}
+	for _, opts := range []WriterOptions{
+		{BloomBitsPerKey: -1},
+		{BloomFalsePositiveRate: 1},
+		{BloomFalsePositiveRate: -0.1},
+		{BloomHashes: MaxBloomHashes + 1},
+	} {
+		if err := WriteTableWithOptions(path, entries, opts); err == nil {
+			t.Fatalf("WriteTableWithOptions(%+v) succeeded", opts)
+		}
+	}
+}
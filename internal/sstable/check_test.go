@@ -0,0 +1,53 @@
+package sstable
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestCheckTable(t *testing.T) {
+	path := writeReaderTable(t, 2000, WriterOptions{BlockSize: 1024})
+	bad, err := CheckTable(path, ReadOptions{})
+	if err != nil || bad != nil {
+		t.Fatalf("healthy table: %+v, %v", bad, err)
+	}
+
+	r, err := OpenReader(path, ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := r.index()
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 改坏中间一个块里的一个 value 字节
+	blk := idx[len(idx)/2]
+	b[blk.offset+40] ^= 0xff
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	bad, err = CheckTable(path, ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bad) != 1 || bad[0].Offset != blk.offset || bad[0].Start != blk.key || bad[0].End != idx[len(idx)/2+1].key ||
+		!errors.Is(bad[0].Err, ErrCorruptSST) {
+		t.Fatalf("corrupt blocks = %+v, want the block at %d starting at %q", bad, blk.offset, blk.key)
+	}
+
+	// footer 损坏时整张表都不可信
+	b[len(b)-1] ^= 0xff
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CheckTable(path, ReadOptions{}); !errors.Is(err, ErrCorruptSST) {
+		t.Fatalf("corrupt footer: err %v", err)
+	}
+}
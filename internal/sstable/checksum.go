@@ -0,0 +1,133 @@
+package sstable
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+// 数据块的校验和：写表时对每个块（相邻两个索引项之间的数据区，最后一块到 props 区为止）
+// 计算 crc32c，记录在 properties 里（Properties.BlockChecksums），不改变文件格式，旧表没有校验和。
+// ReadOptions.VerifyChecksums 为 true 时，读到的块先整体校验再解码。
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+func marshalChecksums(sums []uint32) string {
+	b := make([]byte, 0, 4*len(sums))
+	for _, s := range sums {
+		b = binary.LittleEndian.AppendUint32(b, s)
+	}
+	return string(b)
+}
+
+func unmarshalChecksums(v string) ([]uint32, bool) {
+	if len(v)%4 != 0 {
+		return nil, false
+	}
+	sums := make([]uint32, len(v)/4)
+	for i := range sums {
+		sums[i] = binary.LittleEndian.Uint32([]byte(v[4*i : 4*i+4]))
+	}
+	return sums, true
+}
+
+// blockSums 读取表的块校验和；表没有记录校验和时返回 nil。
+// 校验和的数量与索引项数不一致说明 props 或索引已经损坏。
+func blockSums(f io.ReaderAt, fileSize int64, idx []indexEntry) ([]uint32, error) {
+	props, err := readProperties(f, fileSize)
+	if err != nil {
+		return nil, err
+	}
+	if props.BlockChecksums == nil {
+		return nil, nil
+	}
+	if len(props.BlockChecksums) != len(idx) {
+		return nil, fmt.Errorf("%w: %d block checksums for %d index entries", ErrCorruptSST, len(props.BlockChecksums), len(idx))
+	}
+	return props.BlockChecksums, nil
+}
+
+// checkedReader 从第 first 个块开始顺序读取数据区直到 end（不含）：
+// 每个块先整体读进内存，校验 crc32c 之后再交给调用方。
+type checkedReader struct {
+	f    io.ReaderAt
+	idx  []indexEntry
+	sums []uint32
+	end  uint64
+
+	next int // 下一个要读的块
+	buf  []byte
+	off  int
+}
+
+func (r *checkedReader) Read(p []byte) (int, error) {
+	for r.off == len(r.buf) {
+		if r.next >= len(r.idx) || r.idx[r.next].offset >= r.end {
+			return 0, io.EOF
+		}
+		start, stop := r.idx[r.next].offset, r.end
+		if r.next+1 < len(r.idx) {
+			stop = min(stop, r.idx[r.next+1].offset)
+		}
+		if stop < start {
+			return 0, ErrCorruptSST
+		}
+		if cap(r.buf) < int(stop-start) {
+			r.buf = make([]byte, stop-start)
+		}
+		r.buf, r.off = r.buf[:stop-start], 0
+		if _, err := r.f.ReadAt(r.buf, int64(start)); err != nil {
+			return 0, ErrCorruptSST
+		}
+		if crc32.Checksum(r.buf, castagnoli) != r.sums[r.next] {
+			return 0, fmt.Errorf("%w: checksum mismatch in block at offset %d", ErrCorruptSST, start)
+		}
+		r.next++
+	}
+	n := copy(p, r.buf[r.off:])
+	r.off += n
+	return n, nil
+}
+
+// dataReader 返回读取数据区 [from, end) 的 reader，from 必须是某个块的起点。
+// verify 为 true 且表有块校验和时逐块校验，否则直接读文件。
+func dataReader(f io.ReaderAt, fileSize int64, idx []indexEntry, from, end uint64, verify bool) (*bufio.Reader, error) {
+	var sums []uint32
+	if verify {
+		var err error
+		if sums, err = blockSums(f, fileSize, idx); err != nil {
+			return nil, err
+		}
+	}
+	if sums == nil {
+		return bufio.NewReaderSize(io.NewSectionReader(f, int64(from), int64(end-from)), 64*1024), nil
+	}
+	cr := &checkedReader{}
+	if err := cr.reset(f, idx, sums, from, end); err != nil {
+		return nil, err
+	}
+	return bufio.NewReaderSize(cr, 64*1024), nil
+}
+
+// reset 让 r 从 from 开始读取，保留之前分配的块缓冲。from 必须是某个块的起点。
+func (r *checkedReader) reset(f io.ReaderAt, idx []indexEntry, sums []uint32, from, end uint64) error {
+	i := sort.Search(len(idx), func(i int) bool { return idx[i].offset >= from })
+	if i == len(idx) || idx[i].offset != from {
+		return ErrCorruptSST
+	}
+	*r = checkedReader{f: f, idx: idx, sums: sums, end: end, next: i, buf: r.buf[:0]}
+	return nil
+}
+
+// verifyBlocks 校验所有数据块；表没有块校验和时什么也不做。
+func verifyBlocks(f io.ReaderAt, fileSize int64, idx []indexEntry, dataEnd uint64) error {
+	r, err := dataReader(f, fileSize, idx, idx[0].offset, dataEnd, true)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(io.Discard, r)
+	return err
+}
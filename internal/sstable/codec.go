@@ -0,0 +1,239 @@
+package sstable
+
+import (
+	"bytes"
+	"cmp"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// ErrUnknownCodec 表示表里的记录使用了没有注册的压缩算法（或者写表时指定了不存在的算法）。
+var ErrUnknownCodec = errors.New("sstable: unknown compression codec")
+
+// Codec 是 value 的压缩算法。实现必须是无状态、并发安全的。
+type Codec interface {
+	// Name 是算法名称，写入表的 properties，也是 WriterOptions.Compression 使用的名字。
+	Name() string
+	// Compress 把 src 压缩后追加到 dst 并返回。
+	Compress(dst, src []byte) []byte
+	// Decompress 把 Compress 的输出还原后追加到 dst 并返回。
+	Decompress(dst, src []byte) ([]byte, error)
+}
+
+// 压缩过的 record 里记的是 codec 的 ID（1 字节），表的 properties 里记的是名称。
+// ID 一经写入磁盘就不能再换给别的算法；0 保留给“不压缩”，1..15 保留给内置算法。
+const (
+	codecIDFlate     byte = 1
+	codecIDFlateDict byte = 2 // flate + 表自带的字典（Properties.CompressionDict），不在注册表里
+
+	// MinCustomCodecID 是用户自定义算法可以使用的最小 ID。
+	MinCustomCodecID byte = 16
+)
+
+var codecs = struct {
+	sync.RWMutex
+	byID   map[byte]Codec
+	byName map[string]byte
+}{
+	byID:   map[byte]Codec{codecIDFlate: flateCodec{}},
+	byName: map[string]byte{"flate": codecIDFlate},
+}
+
+// RegisterCodec 以 id 注册一个压缩算法，之后写表时可以通过 WriterOptions.Compression 按名称使用，
+// 读表时按记录里的 id 找到它解压。id 必须 >= MinCustomCodecID，且 id 和名称都不能重复。
+// 应该在打开数据库之前（通常是 init 中）注册；读到未注册 id 的记录会返回 ErrUnknownCodec。
+func RegisterCodec(id byte, c Codec) error {
+	if id < MinCustomCodecID {
+		return fmt.Errorf("sstable: codec id %d is reserved", id)
+	}
+	name := c.Name()
+	if name == "" {
+		return errors.New("sstable: codec name is empty")
+	}
+
+	codecs.Lock()
+	defer codecs.Unlock()
+	if old, ok := codecs.byID[id]; ok {
+		return fmt.Errorf("sstable: codec id %d already registered as %q", id, old.Name())
+	}
+	if _, ok := codecs.byName[name]; ok {
+		return fmt.Errorf("sstable: codec %q already registered", name)
+	}
+	codecs.byID[id] = c
+	codecs.byName[name] = id
+	return nil
+}
+
+// Codecs 返回所有已注册算法的名称（包括内置的 flate）。
+func Codecs() []string {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	names := make([]string, 0, len(codecs.byName))
+	for name := range codecs.byName {
+		names = append(names, name)
+	}
+	return names
+}
+
+// LookupCodec 按名称查找已注册的算法。
+func LookupCodec(name string) (Codec, bool) {
+	_, c, err := codecByName(name)
+	return c, err == nil
+}
+
+func codecByName(name string) (byte, Codec, error) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	id, ok := codecs.byName[name]
+	if !ok {
+		return 0, nil, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+	}
+	return id, codecs.byID[id], nil
+}
+
+func codecByID(id byte) (Codec, error) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	c, ok := codecs.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: id %d", ErrUnknownCodec, id)
+	}
+	return c, nil
+}
+
+// flateCodec 是内置的 DEFLATE 压缩（标准库 compress/flate），不需要额外依赖。
+type flateCodec struct{}
+
+func (flateCodec) Name() string { return "flate" }
+
+func (flateCodec) Compress(dst, src []byte) []byte {
+	buf := bytes.NewBuffer(dst)
+	w, _ := flate.NewWriter(buf, flate.DefaultCompression) // 只有 level 非法时才会出错
+	_, _ = w.Write(src)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+func (flateCodec) Decompress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+	if _, err := io.Copy(buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// flateDictCodec 是带预置字典的 flate：很多小而相似的 value 单独压缩几乎没有收益，
+// 共用一个从样本里训练出来的字典之后，每条 value 都能引用字典里的公共片段。
+type flateDictCodec struct{ dict []byte }
+
+func (flateDictCodec) Name() string { return "flate" }
+
+func (c flateDictCodec) Compress(dst, src []byte) []byte {
+	buf := bytes.NewBuffer(dst)
+	// 较低的压缩级别对短输入不会引用预置字典，只有 BestCompression 才会
+	w, _ := flate.NewWriterDict(buf, flate.BestCompression, c.dict)
+	_, _ = w.Write(src)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+func (c flateDictCodec) Decompress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	r := flate.NewReaderDict(bytes.NewReader(src), c.dict)
+	defer r.Close()
+	if _, err := io.Copy(buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MaxDictSize 是压缩字典的最大字节数（flate 的窗口大小，更早的内容引用不到）。
+const MaxDictSize = 32 << 10
+
+// dictGram 是训练字典时统计的片段长度。
+const dictGram = 8
+
+// TrainDictionary 从样本 value 中训练一个不超过 maxSize 字节（<= 0 或超过 MaxDictSize 时取 MaxDictSize）的字典。
+//
+// 统计所有样本里每个 dictGram 字节片段出现的次数，按“平均每字节的片段出现次数”给每个（去重后的）样本打分，
+// 分数高的样本更能代表这批数据。flate 引用越近的内容编码越短，所以分数最高的放在字典末尾。
+// 样本太少或没有重复内容时返回 nil。
+func TrainDictionary(samples [][]byte, maxSize int) []byte {
+	if maxSize <= 0 || maxSize > MaxDictSize {
+		maxSize = MaxDictSize
+	}
+	freq := make(map[string]int)
+	seen := make(map[string]bool)
+	var uniq [][]byte
+	for _, s := range samples {
+		if len(s) < dictGram || seen[string(s)] {
+			continue
+		}
+		seen[string(s)] = true
+		uniq = append(uniq, s)
+		for i := 0; i+dictGram <= len(s); i++ {
+			freq[string(s[i:i+dictGram])]++
+		}
+	}
+
+	type scored struct {
+		b     []byte
+		score float64
+	}
+	var cands []scored
+	for _, s := range uniq {
+		var sum int
+		for i := 0; i+dictGram <= len(s); i++ {
+			sum += freq[string(s[i:i+dictGram])] - 1 // 只在自己里出现的片段没有价值
+		}
+		if sum > 0 {
+			cands = append(cands, scored{s, float64(sum) / float64(len(s))})
+		}
+	}
+	if len(cands) == 0 {
+		return nil
+	}
+	slices.SortStableFunc(cands, func(a, b scored) int { return cmp.Compare(b.score, a.score) })
+
+	// 从分数最高的开始取，直到装满；最后倒过来让最高分的在末尾
+	var picked [][]byte
+	size := 0
+	for _, c := range cands {
+		if size+len(c.b) > maxSize {
+			continue
+		}
+		picked = append(picked, c.b)
+		size += len(c.b)
+	}
+	dict := make([]byte, 0, size)
+	for i := len(picked) - 1; i >= 0; i-- {
+		dict = append(dict, picked[i]...)
+	}
+	return dict
+}
+
+// tableDict 返回按需从已打开的表 f 的 properties 读取压缩字典的函数，只在读到用字典压缩的记录时才会调用，结果会缓存。
+func tableDict(f io.ReaderAt, fileSize int64) func() ([]byte, error) {
+	var dict []byte
+	var err error
+	var loaded bool
+	return func() ([]byte, error) {
+		if !loaded {
+			var p Properties
+			p, err = readProperties(f, fileSize)
+			dict, loaded = p.CompressionDict, true
+		}
+		return dict, err
+	}
+}
+
+// staticDict 返回总是给出 dict 的字典函数。
+func staticDict(dict []byte) func() ([]byte, error) {
+	return func() ([]byte, error) { return dict, nil }
+}
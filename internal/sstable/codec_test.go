@@ -0,0 +1,161 @@
+package sstable
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"monolithdb/internal/types"
+)
+
+// reverseCodec 把数据倒过来再做 flate，用来确认读表时走的是注册的算法。
+type reverseCodec struct{}
+
+func (reverseCodec) Name() string { return "test-reverse" }
+
+func (reverseCodec) Compress(dst, src []byte) []byte {
+	rev := make([]byte, len(src))
+	for i, b := range src {
+		rev[len(src)-1-i] = b
+	}
+	return flateCodec{}.Compress(dst, rev)
+}
+
+func (reverseCodec) Decompress(dst, src []byte) ([]byte, error) {
+	rev, err := flateCodec{}.Decompress(nil, src)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(rev)-1; i < j; i, j = i+1, j-1 {
+		rev[i], rev[j] = rev[j], rev[i]
+	}
+	return append(dst, rev...), nil
+}
+
+func TestCodecRegistry(t *testing.T) {
+	if err := RegisterCodec(MinCustomCodecID-1, reverseCodec{}); err == nil {
+		t.Fatal("expected reserved id to be rejected")
+	}
+	if err := RegisterCodec(200, reverseCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterCodec(201, reverseCodec{}); err == nil {
+		t.Fatal("expected duplicate name to be rejected")
+	}
+	if c, ok := LookupCodec("test-reverse"); !ok || c.Name() != "test-reverse" {
+		t.Fatalf("LookupCodec = %v %v", c, ok)
+	}
+
+	path := filepath.Join(t.TempDir(), "000001.sst")
+	long := []byte(strings.Repeat("compressible ", 100))
+	entries := []types.Entry{
+		{Key: "a", Value: long, Seq: 1},
+		{Key: "b", Value: []byte("x")}, // 压缩后不会变小，按原样写入
+		{Key: "c", Tombstone: true},
+		{Key: "d", Value: []byte{}},
+	}
+	if err := WriteTableWithOptions(path, entries, WriterOptions{Compression: "test-reverse"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := RangeWithOptions(path, "", "", ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Fatalf("expected %+v, got %+v", entries, got)
+	}
+	if v, res, err := Get(path, "a"); err != nil || res != Found || !bytes.Equal(v, long) {
+		t.Fatalf("Get(a) = %d bytes, %v, %v", len(v), res, err)
+	}
+	p, err := ReadProperties(path)
+	if err != nil || p.Compression != "test-reverse" {
+		t.Fatalf("compression property = %q, %v", p.Compression, err)
+	}
+	if err := Verify(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Describe 记录的偏移按压缩后的大小前进，最后一条之后正好是数据区末尾
+	ti, err := Describe(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for rec, err := range ti.Records() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+		if rec.Entry.Key == "a" && !bytes.Equal(rec.Entry.Value, long) {
+			t.Fatal("Records returned the compressed value")
+		}
+	}
+	if n != len(entries) {
+		t.Fatalf("Records yielded %d entries", n)
+	}
+
+	if err := WriteTableWithOptions(path, entries, WriterOptions{Compression: "no-such-codec"}); !errors.Is(err, ErrUnknownCodec) {
+		t.Fatalf("expected ErrUnknownCodec, got %v", err)
+	}
+}
+
+func TestDictionaryCompression(t *testing.T) {
+	var entries []types.Entry
+	var samples [][]byte
+	for i := 0; i < 500; i++ {
+		v := fmt.Appendf(nil, `{"user_id":%d,"status":"active","region":"eu-west-1","plan":"premium"}`, i)
+		entries = append(entries, types.Entry{Key: fmt.Sprintf("user/%04d", i), Value: v})
+		samples = append(samples, v)
+	}
+	dict := TrainDictionary(samples, 4<<10)
+	if len(dict) == 0 || len(dict) > 4<<10 {
+		t.Fatalf("dictionary size = %d", len(dict))
+	}
+
+	dir := t.TempDir()
+	dataBytes := func(name string, opts WriterOptions) uint64 {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := WriteTableWithOptions(path, entries, opts); err != nil {
+			t.Fatal(err)
+		}
+		ti, err := Describe(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ti.PropsStart - headerSize
+	}
+	plain := dataBytes("plain.sst", WriterOptions{Compression: "flate"})
+	withDict := dataBytes("dict.sst", WriterOptions{CompressionDict: dict})
+	// 单独压缩一条小 value 几乎没有收益，有字典之后才明显变小
+	if withDict*2 > plain {
+		t.Fatalf("dictionary did not help: %d bytes with dict, %d without", withDict, plain)
+	}
+
+	path := filepath.Join(dir, "dict.sst")
+	got, err := RangeWithOptions(path, "", "", ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Fatal("range over dictionary-compressed table returned different entries")
+	}
+	if v, res, err := Get(path, "user/0042"); err != nil || res != Found || !bytes.Equal(v, entries[42].Value) {
+		t.Fatalf("Get = %q, %v, %v", v, res, err)
+	}
+	if scanned, err := ScanData(path); err != nil || len(scanned) != len(entries) {
+		t.Fatalf("ScanData = %d entries, %v", len(scanned), err)
+	}
+	if p, err := ReadProperties(path); err != nil || !bytes.Equal(p.CompressionDict, dict) {
+		t.Fatalf("dictionary not stored in properties: %v", err)
+	}
+
+	if err := WriteTableWithOptions(path, entries, WriterOptions{Compression: "test-reverse", CompressionDict: dict}); err == nil {
+		t.Fatal("expected dictionary with a non-flate codec to be rejected")
+	}
+}
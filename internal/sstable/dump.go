@@ -0,0 +1,271 @@
+package sstable
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"time"
+
+	"monolithdb/internal/encrypt"
+	"monolithdb/internal/types"
+)
+
+// TableInfo 是一张 SST 的结构化描述，由 Describe 返回。
+type TableInfo struct {
+	Path     string
+	FileSize int64 // 明文大小（加密的表不含加密开销）
+
+	// KeyID 是加密表使用的密钥 ID，明文表为空。
+	KeyID string
+
+	// header
+	Magic uint32
+	Count uint32
+
+	// footer，FormatVersion 是表格式版本（见 TableFormatVersion），footer 带版本之前的旧表为 0
+	FormatVersion uint32
+	PropsStart    uint64
+	IndexStart    uint64
+	BloomStart    uint64
+
+	Properties Properties
+	Index      []IndexInfo
+
+	// bloom 参数；使用 FilterPolicy 的表（见 Properties.FilterPolicy）只有 BloomBytes，是 filter 的字节数
+	BloomBits   uint32
+	BloomHashes uint8
+	BloomBytes  int
+
+	keys encrypt.KeyProvider // Records 重新打开文件时使用
+}
+
+// IndexInfo 是稀疏索引里的一项。
+type IndexInfo struct {
+	Key    string
+	Offset uint64
+}
+
+// RecordInfo 是数据区里的一条记录及其文件内偏移。
+type RecordInfo struct {
+	Offset uint64
+	Entry  types.Entry
+}
+
+// Describe 读取表的元数据（header / footer / properties / 索引 / bloom 参数）。
+// 数据区内容通过 Records 逐条迭代，不会一次性读入内存。
+func Describe(path string) (*TableInfo, error) {
+	return DescribeWithOptions(path, ReadOptions{})
+}
+
+// DescribeWithOptions 与 Describe 相同，加密的表用 opts.Keys 解密。
+func DescribeWithOptions(path string, opts ReadOptions) (*TableInfo, error) {
+	f, err := openTable(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ti := &TableInfo{Path: path, FileSize: f.Size(), KeyID: f.keyID, keys: opts.Keys}
+
+	var hdr [headerSize]byte
+	if _, err := io.ReadFull(f, hdr[:]); err != nil {
+		return nil, ErrCorruptSST
+	}
+	ti.Magic = binary.LittleEndian.Uint32(hdr[0:4])
+	ti.Count = binary.LittleEndian.Uint32(hdr[4:8])
+	if ti.Magic != magic {
+		return ti, ErrCorruptSST
+	}
+
+	ft, err := readFooter(f, ti.FileSize)
+	if err != nil {
+		return ti, err
+	}
+	ti.FormatVersion = ft.version
+	ti.PropsStart, ti.IndexStart, ti.BloomStart = ft.propsStart, ft.indexStart, ft.bloomStart
+
+	if ti.Properties, err = readProperties(f, ti.FileSize); err != nil {
+		return ti, err
+	}
+
+	idx, _, err := loadIndex(f, ti.FileSize, nil)
+	if err != nil {
+		return ti, err
+	}
+	for _, it := range idx {
+		ti.Index = append(ti.Index, IndexInfo{Key: it.key, Offset: it.offset})
+	}
+
+	br := io.NewSectionReader(f, int64(ft.bloomStart), int64(ft.start-ft.bloomStart))
+	bloomBytes, err := io.ReadAll(br)
+	if err != nil {
+		return ti, err
+	}
+	if ti.Properties.FilterPolicy != "" {
+		data, ok := unmarshalPolicyFilter(bloomBytes)
+		if !ok {
+			return ti, ErrCorruptSST
+		}
+		ti.BloomBytes = len(data)
+		return ti, nil
+	}
+	bf, ok := unmarshalBloom(bloomBytes)
+	if !ok {
+		return ti, ErrCorruptSST
+	}
+	ti.BloomBits, ti.BloomHashes, ti.BloomBytes = bf.m, bf.k, len(bf.b)
+
+	return ti, nil
+}
+
+// Records 按顺序迭代数据区的所有记录。
+// 解码失败时会产出一次非 nil 的 error 并结束迭代。
+func (ti *TableInfo) Records() iter.Seq2[RecordInfo, error] {
+	return func(yield func(RecordInfo, error) bool) {
+		f, err := openTable(ti.Path, ReadOptions{Keys: ti.keys})
+		if err != nil {
+			yield(RecordInfo{}, err)
+			return
+		}
+		defer f.Close()
+
+		section := io.NewSectionReader(f, headerSize, int64(ti.PropsStart)-headerSize)
+		r := bufio.NewReaderSize(section, 64*1024)
+
+		off := uint64(headerSize)
+		for off < ti.PropsStart {
+			e, n, err := readRecordN(r, ti.PropsStart, staticDict(ti.Properties.CompressionDict))
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = ErrCorruptSST
+				}
+				yield(RecordInfo{Offset: off}, err)
+				return
+			}
+			if !yield(RecordInfo{Offset: off, Entry: e}, nil) {
+				return
+			}
+			off += n
+		}
+	}
+}
+
+// DumpOptions 控制 Dump 的输出内容。
+type DumpOptions struct {
+	// Records 为 true 时额外打印数据区的每一条记录。
+	Records bool
+
+	// Keys 用于解密加密的表。
+	Keys encrypt.KeyProvider
+}
+
+// Dump 以人类可读的形式把表结构打印到 w，用于排查文件格式问题。
+// 即使表已损坏，也会先打印出已经成功解析的部分，再返回错误。
+func Dump(path string, w io.Writer, opts DumpOptions) error {
+	ti, err := DescribeWithOptions(path, ReadOptions{Keys: opts.Keys})
+	if ti != nil {
+		printTableInfo(w, ti)
+	}
+	if err != nil {
+		fmt.Fprintf(w, "error: %v\n", err)
+		return err
+	}
+
+	if !opts.Records {
+		return nil
+	}
+
+	fmt.Fprintf(w, "records:\n")
+	for rec, err := range ti.Records() {
+		if err != nil {
+			fmt.Fprintf(w, "  @%d error: %v\n", rec.Offset, err)
+			return err
+		}
+		e := rec.Entry
+		meta := ""
+		if e.Seq != 0 {
+			meta = fmt.Sprintf(" seq=%d", e.Seq)
+		}
+		if e.ExpiresAt != 0 {
+			meta += " expires=" + time.Unix(0, e.ExpiresAt).UTC().Format(time.RFC3339Nano)
+		}
+		if ref, ok := DecodeValueRef(e.Value); ok && e.ValueRef {
+			fmt.Fprintf(w, "  @%d %q => <value-log %06d @%d, %d bytes>%s\n", rec.Offset, e.Key, ref.File, ref.Offset, ref.Size, meta)
+		} else if e.Tombstone {
+			fmt.Fprintf(w, "  @%d %q <tombstone>%s\n", rec.Offset, e.Key, meta)
+		} else {
+			fmt.Fprintf(w, "  @%d %q => %q (%d bytes)%s\n", rec.Offset, e.Key, e.Value, len(e.Value), meta)
+		}
+	}
+	return nil
+}
+
+func printTableInfo(w io.Writer, ti *TableInfo) {
+	fmt.Fprintf(w, "file: %s (%d bytes)\n", ti.Path, ti.FileSize)
+	if ti.KeyID != "" {
+		fmt.Fprintf(w, "encryption: key=%q\n", ti.KeyID)
+	}
+	fmt.Fprintf(w, "header: magic=0x%08x count=%d\n", ti.Magic, ti.Count)
+	if ti.IndexStart == 0 {
+		return
+	}
+	fmt.Fprintf(w, "footer: version=%d props@%d index@%d bloom@%d\n", ti.FormatVersion, ti.PropsStart, ti.IndexStart, ti.BloomStart)
+
+	p := ti.Properties
+	if p.EngineVersion != "" {
+		fmt.Fprintf(w, "properties:\n")
+		fmt.Fprintf(w, "  creation-reason: %s\n", p.CreationReason)
+		if len(p.InputFiles) > 0 {
+			fmt.Fprintf(w, "  input-files: %v\n", p.InputFiles)
+		}
+		if len(p.OutputFiles) > 0 {
+			fmt.Fprintf(w, "  output-files: %v\n", p.OutputFiles)
+		}
+		if p.IngestSource != "" {
+			fmt.Fprintf(w, "  ingest-source: %s\n", p.IngestSource)
+		}
+		if p.BlockSize > 0 {
+			fmt.Fprintf(w, "  block-size: %d\n", p.BlockSize)
+		}
+		if p.MaxSeq > 0 {
+			fmt.Fprintf(w, "  max-seq: %d\n", p.MaxSeq)
+		}
+		if p.Compression != "" {
+			fmt.Fprintf(w, "  compression: %s\n", p.Compression)
+		}
+		if len(p.CompressionDict) > 0 {
+			fmt.Fprintf(w, "  compression-dict: %d bytes\n", len(p.CompressionDict))
+		}
+		if len(p.BlockChecksums) > 0 {
+			fmt.Fprintf(w, "  block-checksums: %d\n", len(p.BlockChecksums))
+		}
+		if len(p.ValueLogBytes) > 0 {
+			fmt.Fprintf(w, "  value-log-refs: %s\n", marshalValueLogBytes(p.ValueLogBytes))
+		}
+		if p.NumEntries > 0 {
+			fmt.Fprintf(w, "  entries: %d (%d tombstones)\n", p.NumEntries, p.NumTombstones)
+		}
+		if p.FilterPolicy != "" {
+			fmt.Fprintf(w, "  filter-policy: %s\n", p.FilterPolicy)
+		}
+		fmt.Fprintf(w, "  engine-version: %s\n", p.EngineVersion)
+		fmt.Fprintf(w, "  host: %s\n", p.Host)
+		fmt.Fprintf(w, "  created-at: %s\n", p.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"))
+	}
+
+	if len(ti.Index) > 0 {
+		fmt.Fprintf(w, "index (%d entries):\n", len(ti.Index))
+		for _, it := range ti.Index {
+			fmt.Fprintf(w, "  %q -> @%d\n", it.Key, it.Offset)
+		}
+	}
+
+	if ti.BloomBits > 0 {
+		fmt.Fprintf(w, "bloom: bits=%d hashes=%d bytes=%d\n", ti.BloomBits, ti.BloomHashes, ti.BloomBytes)
+	} else if ti.Properties.FilterPolicy != "" {
+		fmt.Fprintf(w, "filter: policy=%s bytes=%d\n", ti.Properties.FilterPolicy, ti.BloomBytes)
+	}
+}
@@ -0,0 +1,81 @@
+package sstable
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"monolithdb/internal/types"
+)
+
+func TestDescribeAndRecords(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "000001.sst")
+
+	n := indexStride*2 + 5
+	entries := make([]types.Entry, 0, n)
+	for i := 0; i < n; i++ {
+		entries = append(entries, types.Entry{
+			Key:   fmt.Sprintf("k%04d", i),
+			Value: []byte(fmt.Sprintf("v%04d", i)),
+		})
+	}
+	entries[3].Value, entries[3].Tombstone = nil, true
+
+	if err := WriteTable(path, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	ti, err := Describe(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ti.Count != uint32(n) || len(ti.Index) != 3 || ti.BloomHashes == 0 {
+		t.Fatalf("unexpected table info: %+v", ti)
+	}
+
+	i := 0
+	for rec, err := range ti.Records() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Entry.Key != entries[i].Key || rec.Entry.Tombstone != entries[i].Tombstone {
+			t.Fatalf("record %d: expected %+v, got %+v", i, entries[i], rec.Entry)
+		}
+		// 索引项的 offset 必须和迭代出的 record offset 对得上
+		if i%indexStride == 0 && ti.Index[i/indexStride].Offset != rec.Offset {
+			t.Fatalf("record %d: index offset %d != record offset %d", i, ti.Index[i/indexStride].Offset, rec.Offset)
+		}
+		i++
+	}
+	if i != n {
+		t.Fatalf("expected %d records, got %d", n, i)
+	}
+}
+
+func TestDumpPrintsSections(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "000001.sst")
+
+	entries := []types.Entry{
+		{Key: "a", Value: []byte("1")},
+		{Key: "b", Tombstone: true},
+	}
+	if err := WriteTable(path, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Dump(path, &buf, DumpOptions{Records: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"header: magic=0x46534442 count=2", "footer:", "index (1 entries)", "bloom:", `"a" => "1"`, `"b" <tombstone>`} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected dump to contain %q, got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package sstable
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"monolithdb/internal/encrypt"
+	"monolithdb/internal/vfs"
+)
+
+// tableFile 是一个打开的表文件。加密的表（见 WriterOptions.Encryption）在这里透明解密，
+// 之后读 header / footer / 索引 / 数据区使用的都是明文的偏移和大小。
+type tableFile struct {
+	*io.SectionReader
+	f     vfs.File
+	keyID string // 加密表使用的密钥 ID，明文表为空
+}
+
+// openTable 打开 path。加密的表需要 opts.Keys 里有它的密钥：Keys 为 nil 时返回 encrypt.ErrNoKeyProvider，
+// 没有对应的密钥时返回 encrypt.ErrUnknownKey；密文被篡改或截断时返回包装了 ErrCorruptSST 的错误。
+// opts.BytesRead 非 nil 时统计从文件读取的字节数。
+func openTable(path string, opts ReadOptions) (*tableFile, error) {
+	f, err := vfs.OrDefault(opts.FS).Open(path)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	var ra io.ReaderAt = f
+	if opts.BytesRead != nil {
+		ra = countingReaderAt{r: f, n: opts.BytesRead}
+	}
+	if !encrypt.IsEncrypted(ra) {
+		return &tableFile{SectionReader: io.NewSectionReader(ra, 0, st.Size()), f: f}, nil
+	}
+
+	er, err := encrypt.NewReader(ra, st.Size(), opts.Keys)
+	if err != nil {
+		f.Close()
+		if errors.Is(err, encrypt.ErrDecrypt) {
+			return nil, fmt.Errorf("%w: %w", ErrCorruptSST, err)
+		}
+		return nil, err
+	}
+	return &tableFile{SectionReader: io.NewSectionReader(er, 0, er.Size()), f: f, keyID: er.KeyID()}, nil
+}
+
+func (t *tableFile) Close() error { return t.f.Close() }
+
+// countingReaderAt 把每次读到的字节数累加到 n 上（见 ReadOptions.BytesRead）。
+type countingReaderAt struct {
+	r io.ReaderAt
+	n *atomic.Uint64
+}
+
+func (c countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.n.Add(uint64(n))
+	return n, err
+}
+
+// TableKeyID 返回加密表使用的密钥 ID，明文表返回空字符串。只读文件头，不需要密钥。
+// 用于确认密钥轮换之后，旧密钥加密的表是否都已经被 compaction 重写。
+func TableKeyID(path string) (string, error) {
+	return TableKeyIDFS(vfs.Default, path)
+}
+
+// TableKeyIDFS 与 TableKeyID 相同，但通过 fs 读取文件。
+func TableKeyIDFS(fs vfs.FS, path string) (string, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if !encrypt.IsEncrypted(f) {
+		return "", nil
+	}
+	id, err := encrypt.FileKeyID(f)
+	if err != nil {
+		return "", ErrCorruptSST
+	}
+	return id, nil
+}
@@ -0,0 +1,118 @@
+package sstable
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnknownFilterPolicy 表示写表时指定了没有注册的过滤器。
+var ErrUnknownFilterPolicy = errors.New("sstable: unknown filter policy")
+
+// FilterPolicy 是点查时用来跳过表的过滤器（内置的是 bloom filter）。实现必须是无状态、并发安全的，
+// 可以是空间 / 误判率更好的 ribbon、cuckoo filter 等。
+type FilterPolicy interface {
+	// Name 是过滤器名称，写入表的 properties，读表时按它找到 MayContain。
+	// 格式改变（旧的 filter 不能再被正确读取）时必须换一个名称。
+	Name() string
+	// CreateFilter 为表中所有的 key（按表的顺序，包含 tombstone）生成 filter。
+	CreateFilter(keys []string) []byte
+	// MayContain 返回 key 是否可能在生成 filter 的 keys 中；返回 false 时 key 一定不在。
+	MayContain(filter []byte, key string) bool
+}
+
+var filterPolicies = struct {
+	sync.RWMutex
+	byName map[string]FilterPolicy
+}{byName: map[string]FilterPolicy{}}
+
+// RegisterFilterPolicy 注册一个过滤器，之后写表时可以通过 WriterOptions.FilterPolicy 使用，
+// 读表时按 properties 里的名称找到它。名称不能为空或重复，应该在打开数据库之前（通常是 init 中）注册。
+// 读到使用未注册过滤器的表时不做过滤，表依然可读，只是点查不能提前跳过。
+func RegisterFilterPolicy(p FilterPolicy) error {
+	name := p.Name()
+	if name == "" {
+		return errors.New("sstable: filter policy name is empty")
+	}
+
+	filterPolicies.Lock()
+	defer filterPolicies.Unlock()
+	if _, ok := filterPolicies.byName[name]; ok {
+		return fmt.Errorf("sstable: filter policy %q already registered", name)
+	}
+	filterPolicies.byName[name] = p
+	return nil
+}
+
+// LookupFilterPolicy 按名称查找已注册的过滤器。
+func LookupFilterPolicy(name string) (FilterPolicy, bool) {
+	filterPolicies.RLock()
+	defer filterPolicies.RUnlock()
+	p, ok := filterPolicies.byName[name]
+	return p, ok
+}
+
+// FilterPolicies 返回所有已注册过滤器的名称（不包括内置的 bloom filter）。
+func FilterPolicies() []string {
+	filterPolicies.RLock()
+	defer filterPolicies.RUnlock()
+	names := make([]string, 0, len(filterPolicies.byName))
+	for name := range filterPolicies.byName {
+		names = append(names, name)
+	}
+	return names
+}
+
+// tableFilter 是读表时使用的过滤器：mayContain 返回 false 表示 key 一定不在表里。
+// 内置的 *bloom 直接实现它。
+type tableFilter interface {
+	mayContain(key string) bool
+}
+
+// policyFilter 是用 FilterPolicy 生成的 filter。
+type policyFilter struct {
+	policy FilterPolicy
+	data   []byte
+}
+
+func (f policyFilter) mayContain(key string) bool { return f.policy.MayContain(f.data, key) }
+
+// noFilter 用于过滤器没有注册的表：所有 key 都可能存在。
+type noFilter struct{}
+
+func (noFilter) mayContain(string) bool { return true }
+
+// 使用 FilterPolicy 的表，filter 区的格式：| filterLen(uint32) | filter... |
+// 长度前缀保证 filter 为空时 filter 区也不为空（footer 要求 bloomStart < footerStart）。
+func marshalPolicyFilter(filter []byte) []byte {
+	out := binary.LittleEndian.AppendUint32(make([]byte, 0, 4+len(filter)), uint32(len(filter)))
+	return append(out, filter...)
+}
+
+func unmarshalPolicyFilter(p []byte) ([]byte, bool) {
+	if len(p) < 4 || uint64(binary.LittleEndian.Uint32(p)) != uint64(len(p)-4) {
+		return nil, false
+	}
+	return p[4:], true
+}
+
+// parseFilter 按 props.FilterPolicy 解析 filter 区 b。
+func parseFilter(b []byte, props Properties) (tableFilter, error) {
+	if props.FilterPolicy == "" {
+		bf, ok := unmarshalBloom(b)
+		if !ok || bf.m == 0 || bf.k == 0 {
+			return nil, ErrCorruptSST
+		}
+		return bf, nil
+	}
+	data, ok := unmarshalPolicyFilter(b)
+	if !ok {
+		return nil, ErrCorruptSST
+	}
+	p, ok := LookupFilterPolicy(props.FilterPolicy)
+	if !ok {
+		return noFilter{}, nil
+	}
+	return policyFilter{policy: p, data: data}, nil
+}
@@ -0,0 +1,129 @@
+package sstable
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"monolithdb/internal/types"
+)
+
+// exactFilter 把所有 key 原样存下来，没有误判，并记录 MayContain 被调用的次数。
+type exactFilter struct{ calls *atomic.Int64 }
+
+func (exactFilter) Name() string { return "test-exact" }
+
+func (exactFilter) CreateFilter(keys []string) []byte {
+	return []byte(strings.Join(keys, "\x00"))
+}
+
+func (f exactFilter) MayContain(filter []byte, key string) bool {
+	f.calls.Add(1)
+	return slices.Contains(strings.Split(string(filter), "\x00"), key)
+}
+
+var exactCalls atomic.Int64
+
+func init() {
+	if err := RegisterFilterPolicy(exactFilter{&exactCalls}); err != nil {
+		panic(err)
+	}
+}
+
+func TestFilterPolicyRegistry(t *testing.T) {
+	if err := RegisterFilterPolicy(exactFilter{&exactCalls}); err == nil {
+		t.Fatal("expected duplicate name to be rejected")
+	}
+	if p, ok := LookupFilterPolicy("test-exact"); !ok || p.Name() != "test-exact" {
+		t.Fatalf("LookupFilterPolicy = %v %v", p, ok)
+	}
+	if !slices.Contains(FilterPolicies(), "test-exact") {
+		t.Fatalf("FilterPolicies = %v", FilterPolicies())
+	}
+
+	path := filepath.Join(t.TempDir(), "000001.sst")
+	err := WriteTableWithOptions(path, []types.Entry{{Key: "a"}}, WriterOptions{FilterPolicy: "no-such-filter"})
+	if !errors.Is(err, ErrUnknownFilterPolicy) {
+		t.Fatalf("WriteTableWithOptions = %v, want ErrUnknownFilterPolicy", err)
+	}
+}
+
+func TestFilterPolicyTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "000001.sst")
+	var entries []types.Entry
+	for i := 0; i < indexStride*3; i++ {
+		entries = append(entries, types.Entry{Key: fmt.Sprintf("k%04d", i), Value: []byte("v")})
+	}
+	if err := WriteTableWithOptions(path, entries, WriterOptions{NoSync: true, FilterPolicy: "test-exact"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(path); err != nil {
+		t.Fatal(err)
+	}
+
+	ti, err := Describe(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ti.Properties.FilterPolicy != "test-exact" || ti.BloomBits != 0 || ti.BloomBytes == 0 {
+		t.Fatalf("unexpected table info: %+v", ti)
+	}
+	var out bytes.Buffer
+	if err := Dump(path, &out, DumpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "filter: policy=test-exact") {
+		t.Fatalf("dump output missing filter:\n%s", out.String())
+	}
+
+	r, err := OpenReader(path, ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	before := exactCalls.Load()
+	if _, res, err := r.GetEntry("k0007"); err != nil || res != Found {
+		t.Fatalf("GetEntry(k0007) = %v, %v", res, err)
+	}
+	if _, res, err := r.GetEntry("missing"); err != nil || res != NotFound {
+		t.Fatalf("GetEntry(missing) = %v, %v", res, err)
+	}
+	if n := exactCalls.Load() - before; n != 2 {
+		t.Fatalf("MayContain called %d times, want 2", n)
+	}
+}
+
+func TestUnregisteredFilterPolicyStillReadable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "000001.sst")
+	entries := []types.Entry{{Key: "a", Value: []byte("1")}, {Key: "b", Value: []byte("2")}}
+	if err := WriteTableWithOptions(path, entries, WriterOptions{NoSync: true, FilterPolicy: "test-exact"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟用没有注册这个过滤器的程序读表
+	filterPolicies.Lock()
+	p := filterPolicies.byName["test-exact"]
+	delete(filterPolicies.byName, "test-exact")
+	filterPolicies.Unlock()
+	defer func() {
+		filterPolicies.Lock()
+		filterPolicies.byName["test-exact"] = p
+		filterPolicies.Unlock()
+	}()
+
+	if err := Verify(path); err != nil {
+		t.Fatal(err)
+	}
+	e, res, err := GetEntry(path, "b")
+	if err != nil || res != Found || string(e.Value) != "2" {
+		t.Fatalf("GetEntry(b) = %+v, %v, %v", e, res, err)
+	}
+	if _, res, err := GetEntry(path, "c"); err != nil || res != NotFound {
+		t.Fatalf("GetEntry(c) = %v, %v", res, err)
+	}
+}
@@ -2,46 +2,156 @@ package sstable
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
-	"os"
 )
 
 // footer 布局：
-// [indexStartOffset(uint64)][bloomStartOffset(uint64)]
-const footerSize = 16
+// [indexStartOffset(uint64)][bloomStartOffset(uint64)][propsStartOffset(uint64)][version(uint32)][footerMagic(uint64)]
+//
+// version 是表的格式版本，读取时先校验末尾的 footerMagic，再检查 version：
+// 比 TableFormatVersion 新的表返回 ErrUnsupportedVersion，而不是当成损坏。
+//
+// 末尾不是 footerMagic 的是 footer 带版本之前写出的旧表，version 记为 0，按两种旧布局解析：
+//
+//	24 字节（formatVersion 2~7）：[indexStartOffset(uint64)][bloomStartOffset(uint64)][propsStartOffset(uint64)]
+//	16 字节（formatVersion 1）：[indexStartOffset(uint64)][bloomStartOffset(uint64)]，没有 properties 区
+const footerSize = 36
+
+const (
+	footerSizeV1 = 24
+	footerSizeV0 = 16
+)
+
+// footerMagic 标记文件末尾是一个合法的 footer。
+const footerMagic uint64 = 0x464f524745535354 // "FORGESST"
+
+// TableFormatVersion 是当前写出的 SST 格式版本，也是能读取的最高版本。
+// 以后改变数据区 / 元数据区编码方式（分块、校验和……）时递增，并在 footerParsers 里加上新版本的解析，
+// 旧版本的读取逻辑保留。
+const TableFormatVersion uint32 = 1
+
+// ErrUnsupportedVersion 表示表由更新的引擎写出，格式版本超出了当前能读取的范围。
+var ErrUnsupportedVersion = errors.New("sstable: unsupported table format version")
+
+type footer struct {
+	indexStart uint64
+	bloomStart uint64
+	propsStart uint64 // 没有 properties 区的旧表等于 indexStart
+	version    uint32
+	start      uint64 // footer 的起始位置，也是 bloom 区的终点
+}
+
+func (ft footer) marshal() []byte {
+	b := make([]byte, 0, footerSize)
+	b = binary.LittleEndian.AppendUint64(b, ft.indexStart)
+	b = binary.LittleEndian.AppendUint64(b, ft.bloomStart)
+	b = binary.LittleEndian.AppendUint64(b, ft.propsStart)
+	b = binary.LittleEndian.AppendUint32(b, ft.version)
+	return binary.LittleEndian.AppendUint64(b, footerMagic)
+}
 
 // loadFooter 读取并校验 footer，返回 indexStartOffset 与 bloomStartOffset。
+func loadFooter(f io.ReaderAt, fileSize int64) (indexStartOffset, bloomStartOffset uint64, err error) {
+	ft, err := readFooter(f, fileSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	return ft.indexStart, ft.bloomStart, nil
+}
+
+// readFooter 读取并校验完整的 footer。
 // 约束：
-//	header(8) ... records ... index ... bloom ... footer(16)
-//	indexStartOffset >= headerSize
+//
+//	header(8) ... records ... props ... index ... bloom ... footer
+//	propsStartOffset >= headerSize
+//	propsStartOffset < indexStartOffset（16 字节 footer 的旧表两者相等）
 //	indexStartOffset < bloomStartOffset
 //	bloomStartOffset < footerStart
-func loadFooter(f *os.File, fileSize int64) (indexStartOffset, bloomStartOffset uint64, err error) {
-	if fileSize < int64(headerSize+footerSize) {
-		return 0, 0, ErrCorruptSST
+func readFooter(f io.ReaderAt, fileSize int64) (footer, error) {
+	var ft footer
+
+	if fileSize < int64(headerSize+footerSizeV0) {
+		return ft, ErrCorruptSST
 	}
 
-	// footerStart 是 footer 起始位置（也是 bloom 区的 end）
-	footerStart := uint64(fileSize) - uint64(footerSize)
+	// 整块读取文件末尾最长 footer 的字节数
+	var buf [footerSize]byte
+	b := buf[max(0, footerSize-(fileSize-headerSize)):]
+	if _, err := f.ReadAt(b, fileSize-int64(len(b))); err != nil {
+		return ft, ErrCorruptSST
+	}
+	if len(b) < footerSize || binary.LittleEndian.Uint64(b[28:36]) != footerMagic {
+		return readLegacyFooter(b, uint64(fileSize))
+	}
 
-	// seek 到 footer 并读取两个 offset
-	if _, err := f.Seek(-footerSize, io.SeekEnd); err != nil {
-		return 0, 0, err
+	// magic 正确说明 footer 本身完整：版本不认识时明确报告，而不是按损坏处理
+	version := binary.LittleEndian.Uint32(b[24:28])
+	if version > TableFormatVersion {
+		return ft, fmt.Errorf("%w: %d (this build reads up to %d)", ErrUnsupportedVersion, version, TableFormatVersion)
 	}
-	if err := binary.Read(f, binary.LittleEndian, &indexStartOffset); err != nil {
-		return 0, 0, ErrCorruptSST
+	parse, ok := footerParsers[version]
+	if !ok {
+		return ft, ErrCorruptSST
 	}
-	if err := binary.Read(f, binary.LittleEndian, &bloomStartOffset); err != nil {
-		return 0, 0, ErrCorruptSST
+	return parse(b, uint64(fileSize))
+}
+
+// footerParsers 按 footer 里的表格式版本选择解析方式，1 到 TableFormatVersion 每个版本都有一项。
+// 格式变化时增加一个版本和它的解析函数，旧版本的保留，新旧版本的表可以同时存在。
+var footerParsers = map[uint32]func(b []byte, fileSize uint64) (footer, error){
+	1: parseFooterV1,
+}
+
+// parseFooterV1 解析表格式版本 1 的 footer，b 是文件末尾的 footerSize 个字节。
+func parseFooterV1(b []byte, fileSize uint64) (footer, error) {
+	ft := footer{
+		indexStart: binary.LittleEndian.Uint64(b[0:8]),
+		bloomStart: binary.LittleEndian.Uint64(b[8:16]),
+		propsStart: binary.LittleEndian.Uint64(b[16:24]),
+		version:    1,
+		start:      fileSize - footerSize,
+	}
+	if !ft.valid() || ft.propsStart == ft.indexStart {
+		return ft, ErrCorruptSST
+	}
+	return ft, nil
+}
+
+// readLegacyFooter 按 24 字节、16 字节两种旧布局依次解析 b（文件末尾的字节），返回第一个满足约束的。
+// 16 字节 footer 的最后 8 字节是 bloomStartOffset，当成 24 字节布局时 propsStart 会大于 bloomStart，
+// 所以两种布局不会混淆。
+func readLegacyFooter(b []byte, fileSize uint64) (footer, error) {
+	if len(b) >= footerSizeV1 {
+		b := b[len(b)-footerSizeV1:]
+		ft := footer{
+			indexStart: binary.LittleEndian.Uint64(b[0:8]),
+			bloomStart: binary.LittleEndian.Uint64(b[8:16]),
+			propsStart: binary.LittleEndian.Uint64(b[16:24]),
+			start:      fileSize - footerSizeV1,
+		}
+		if ft.valid() && ft.propsStart < ft.indexStart {
+			return ft, nil
+		}
 	}
 
-	// 校验 offset 合法性
-	if indexStartOffset < uint64(headerSize) || indexStartOffset >= footerStart {
-		return 0, 0, ErrCorruptSST
+	b = b[len(b)-footerSizeV0:]
+	ft := footer{
+		indexStart: binary.LittleEndian.Uint64(b[0:8]),
+		bloomStart: binary.LittleEndian.Uint64(b[8:16]),
+		start:      fileSize - footerSizeV0,
 	}
-	if bloomStartOffset <= indexStartOffset || bloomStartOffset >= footerStart {
-		return 0, 0, ErrCorruptSST
+	ft.propsStart = ft.indexStart
+	if !ft.valid() {
+		return footer{}, ErrCorruptSST
 	}
+	return ft, nil
+}
 
-	return indexStartOffset, bloomStartOffset, nil
+// valid 检查各区的 offset 是否满足 readFooter 注释里的约束（propsStart 可以等于 indexStart）。
+func (ft footer) valid() bool {
+	return ft.indexStart >= uint64(headerSize) && ft.indexStart < ft.start &&
+		ft.bloomStart > ft.indexStart && ft.bloomStart < ft.start &&
+		ft.propsStart >= uint64(headerSize) && ft.propsStart <= ft.indexStart
 }
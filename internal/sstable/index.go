@@ -4,40 +4,50 @@ import (
 	"bufio"
 	"encoding/binary"
 	"io"
-	"os"
+	"slices"
 	"sort"
+
+	"monolithdb/internal/types"
 )
 
 const (
 	// 稀疏索引间隔：每隔多少条 record 写一个索引项
 	indexStride = 32
 
+	// 自适应块大小的范围，见 adaptiveBlockSize
+	minAdaptiveBlockSize = 1 << 10
+	maxAdaptiveBlockSize = 64 << 10
+
 	// 简单的防爆上限（防止坏文件造成 OOM）
 	maxIndexKeySize = 1 << 20 // 1MB
 	maxIndexCount   = 1 << 20 // 约 100 万条索引项，上限很宽
 
 	headerSize = 8 // magic(uint32) + count(uint32)
+
+	recordHeaderSize = 9 // keyLen(uint32) + valLen(uint32) + flags(1B)
 )
 
+// MaxKeySize 是表里 key 的最大字节数：索引项可能就是 key 本身，读取时更长的索引项会被当成损坏，
+// 所以写表时拒绝更长的 key。
+const MaxKeySize = maxIndexKeySize
+
 type indexEntry struct {
 	key    string
 	offset uint64
 }
 
-// loadIndex 尝试从文件尾部加载索引。
-// 返回：entries, indexOffset, err
-func loadIndex(f *os.File, fileSize int64) ([]indexEntry, uint64, error) {
-	indexStartOffset, _, err := loadFooter(f, fileSize)
+// loadIndex 尝试从文件尾部加载索引，并按 cmp 检查索引项是否递增（cmp 为 nil 时不检查，
+// 供不知道比较器的诊断工具使用）。
+// 返回：entries, dataEnd（数据区终点，即 propsStartOffset）, err
+func loadIndex(f io.ReaderAt, fileSize int64, cmp types.Comparer) ([]indexEntry, uint64, error) {
+	ft, err := readFooter(f, fileSize)
 	if err != nil {
 		return nil, 0, err
 	}
+	indexStartOffset := ft.indexStart
 
-	// Seek 到索引区起点，读 indexCount
-	if _, err := f.Seek(int64(indexStartOffset), io.SeekStart); err != nil {
-		return nil, 0, err
-	}
-
-	r := bufio.NewReaderSize(f, 64*1024)
+	// 从索引区起点开始读 indexCount
+	r := bufio.NewReaderSize(io.NewSectionReader(f, int64(indexStartOffset), fileSize-int64(indexStartOffset)), 64*1024)
 
 	var indexCount uint32
 	if err := binary.Read(r, binary.LittleEndian, &indexCount); err != nil {
@@ -69,8 +79,8 @@ func loadIndex(f *os.File, fileSize int64) ([]indexEntry, uint64, error) {
 			return nil, 0, ErrCorruptSST
 		}
 
-		// recordOffset 必须指向数据区（严格小于 indexStartOffset）
-		if recordOffset < uint64(headerSize) || recordOffset >= indexStartOffset {
+		// recordOffset 必须指向数据区（严格小于 propsStartOffset）
+		if recordOffset < uint64(headerSize) || recordOffset >= ft.propsStart {
 			return nil, 0, ErrCorruptSST
 		}
 
@@ -78,20 +88,20 @@ func loadIndex(f *os.File, fileSize int64) ([]indexEntry, uint64, error) {
 	}
 
 	// 索引必须按 key 递增
-	if !sort.SliceIsSorted(entries, func(i, j int) bool { return entries[i].key < entries[j].key }) {
+	if cmp != nil && !sort.SliceIsSorted(entries, func(i, j int) bool { return cmp.Compare(entries[i].key, entries[j].key) < 0 }) {
 		return nil, 0, ErrCorruptSST
 	}
 
-	return entries, indexStartOffset, nil
+	return entries, ft.propsStart, nil
 }
 
-// pickScanRange 根据 target key 选择扫描区间 [startOffset, endOffset)。
-func pickScanRange(entries []indexEntry, indexOffset uint64, target string) (start uint64, end uint64) {
-	// indexOffset 是索引区起点（数据区终点）
-	end = indexOffset
+// pickScanRange 根据 target key（按 cmp 的顺序）选择扫描区间 [startOffset, endOffset)。
+func pickScanRange(entries []indexEntry, dataEnd uint64, target string, cmp types.Comparer) (start uint64, end uint64) {
+	// dataEnd 是数据区终点
+	end = dataEnd
 
 	// 找到最后一个 <= target 的索引项
-	i := sort.Search(len(entries), func(i int) bool { return entries[i].key > target }) - 1 // 返回最小的 i，使得 f(i) == true
+	i := sort.Search(len(entries), func(i int) bool { return cmp.Compare(entries[i].key, target) > 0 }) - 1 // 返回最小的 i，使得 f(i) == true
 	if i < 0 {
 		i = 0
 	}
@@ -106,3 +116,25 @@ func pickScanRange(entries []indexEntry, indexOffset uint64, target string) (sta
 	}
 	return start, end
 }
+
+// AdaptiveBlockSize 作为 WriterOptions.BlockSize 时，由写入器根据记录大小分布选择块大小。
+const AdaptiveBlockSize = -1
+
+// adaptiveBlockSize 根据 entries 的记录大小中位数选择块大小：
+// 一个块大约容纳 indexStride 条中等大小的记录，限制在 [1KB, 64KB]。
+//
+// 小 key / 小 value 的表得到小块，点查扫描的字节少；
+// 大 value 的表得到大块，索引项少，顺序扫描时也更少在块之间跳转。
+func adaptiveBlockSize(entries []types.Entry) int {
+	if len(entries) == 0 {
+		return minAdaptiveBlockSize
+	}
+	sizes := make([]uint64, len(entries))
+	for i, e := range entries {
+		sizes[i] = recordSize(e)
+	}
+	slices.Sort(sizes)
+	median := sizes[len(sizes)/2]
+
+	return int(min(max(median*indexStride, minAdaptiveBlockSize), maxAdaptiveBlockSize))
+}
@@ -48,12 +48,12 @@ func TestIndexLimitsScanRange(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	idx, indexStartOffset, err := loadIndex(f, st.Size())
+	idx, indexStartOffset, err := loadIndex(f, st.Size(), types.BytewiseComparer)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	start, end := pickScanRange(idx, indexStartOffset, target)
+	start, end := pickScanRange(idx, indexStartOffset, target, types.BytewiseComparer)
 	if !(start < end) {
 		t.Fatalf("bad scan range: start=%d end=%d", start, end)
 	}
@@ -128,13 +128,13 @@ func TestIndexCorruptionInsideRange(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	idx, indexStartOffset, err := loadIndex(f, st.Size())
+	idx, indexStartOffset, err := loadIndex(f, st.Size(), types.BytewiseComparer)
 	if err != nil {
 		_ = f.Close()
 		t.Fatal(err)
 	}
 
-	start, end := pickScanRange(idx, indexStartOffset, target)
+	start, end := pickScanRange(idx, indexStartOffset, target, types.BytewiseComparer)
 	if !(start < end) {
 		_ = f.Close()
 		t.Fatalf("bad scan range: start=%d end=%d", start, end)
@@ -223,3 +223,58 @@ func TestIndexFooterOutOfRange(t *testing.T) {
 		t.Fatalf("expected ErrCorruptSST, got %v", err)
 	}
 }
+
+func TestAdaptiveBlockSize(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name string, valueLen, n int) *TableInfo {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		entries := make([]types.Entry, 0, n)
+		for i := 0; i < n; i++ {
+			entries = append(entries, types.Entry{
+				Key:   fmt.Sprintf("k%04d", i),
+				Value: bytes.Repeat([]byte{'v'}, valueLen),
+			})
+		}
+		if err := WriteTableWithOptions(path, entries, WriterOptions{NoSync: true, BlockSize: AdaptiveBlockSize}); err != nil {
+			t.Fatal(err)
+		}
+		// 按字节切块不影响点查
+		for _, i := range []int{0, n / 2, n - 1} {
+			if _, res, err := Get(path, entries[i].Key); err != nil || res != Found {
+				t.Fatalf("%s: Get(%s) res=%v err=%v", name, entries[i].Key, res, err)
+			}
+		}
+		ti, err := Describe(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ti
+	}
+
+	small := write("small.sst", 4, 1000)
+	large := write("large.sst", 8<<10, 200)
+
+	if small.Properties.BlockSize != minAdaptiveBlockSize {
+		t.Fatalf("small block size = %d", small.Properties.BlockSize)
+	}
+	if large.Properties.BlockSize != maxAdaptiveBlockSize {
+		t.Fatalf("large block size = %d", large.Properties.BlockSize)
+	}
+	// 每个块的数据量不小于块大小（最后一块除外）
+	for _, ti := range []*TableInfo{small, large} {
+		for i := 1; i < len(ti.Index)-1; i++ {
+			if got := ti.Index[i+1].Offset - ti.Index[i].Offset; got < uint64(ti.Properties.BlockSize) {
+				t.Fatalf("%s: block %d is %d bytes", ti.Path, i, got)
+			}
+		}
+	}
+	// 小记录的块里记录更多，大记录的块里记录更少
+	if perBlock := 1000 / len(small.Index); perBlock <= indexStride {
+		t.Fatalf("small table: %d records per block", perBlock)
+	}
+	if perBlock := 200 / len(large.Index); perBlock >= indexStride {
+		t.Fatalf("large table: %d records per block", perBlock)
+	}
+}
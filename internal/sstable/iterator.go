@@ -0,0 +1,157 @@
+package sstable
+
+import (
+	"bufio"
+	"errors"
+	"io"
+
+	"monolithdb/internal/types"
+)
+
+// Iterator 按 key 的顺序遍历一张表的记录（包含 tombstone）。
+// SeekGE 用稀疏索引定位到 key 所在的块，之后 Next 顺序读取数据区，不再查索引。
+//
+// 新建的 Iterator 位于第一条记录之前，可以直接用 for it.Next() { ... } 遍历整张表。
+// Next / SeekGE 返回 false 时要检查 Err 区分读完和出错。Iterator 不是并发安全的；
+// 它不持有文件，Reader 关闭之后不能再使用。
+type Iterator struct {
+	r        *Reader
+	verify   bool
+	keysOnly bool
+
+	br  *bufio.Reader
+	cur types.Entry
+	ok  bool
+	err error
+}
+
+// NewIterator 返回遍历 r 的迭代器，见 Iterator。
+func NewIterator(r *Reader) *Iterator {
+	return NewIteratorWithOptions(r, ReadOptions{})
+}
+
+// NewIteratorWithOptions 与 NewIterator 相同。opts 里只有 VerifyChecksums 和 KeysOnly 生效：
+// VerifyChecksums 为 true 时读到的每个数据块先校验 crc32c，KeysOnly 为 true 时不读取 value。
+func NewIteratorWithOptions(r *Reader, opts ReadOptions) *Iterator {
+	return &Iterator{r: r, verify: opts.VerifyChecksums, keysOnly: opts.KeysOnly}
+}
+
+// SeekGE 移动到第一条 key >= key 的记录（按 Reader 的比较器），没有这样的记录时返回 false。
+// key 为空表示移动到第一条记录。
+func (it *Iterator) SeekGE(key string) bool {
+	it.ok, it.err = false, nil
+	idx, err := it.r.index()
+	if err != nil {
+		it.err = err
+		return false
+	}
+	from := idx[0].offset
+	if key != "" {
+		from, _ = pickScanRange(idx, it.r.ft.propsStart, key, it.r.cmp)
+	}
+	if err := it.reset(idx, from); err != nil {
+		it.err = err
+		return false
+	}
+	return it.next(key)
+}
+
+// Next 移动到下一条记录，已经是最后一条时返回 false。还没有定位时移动到第一条记录。
+func (it *Iterator) Next() bool {
+	if it.br == nil {
+		return it.SeekGE("")
+	}
+	if !it.ok {
+		return false
+	}
+	return it.next("")
+}
+
+// Valid 返回迭代器当前是否位于一条记录上。
+func (it *Iterator) Valid() bool { return it.ok }
+
+// Key 返回当前记录的 key，Valid 为 false 时为空。
+func (it *Iterator) Key() string { return it.cur.Key }
+
+// Value 返回当前记录的 value（已经解压）。ValueRef 记录返回的是编码后的引用（见 DecodeValueRef）。
+// 迭代器以 ReadOptions.KeysOnly 创建时总是 nil。
+func (it *Iterator) Value() []byte { return it.cur.Value }
+
+// Tombstone 返回当前记录是否是删除标记。
+func (it *Iterator) Tombstone() bool { return it.cur.Tombstone }
+
+// Entry 返回当前的完整记录（包含过期时间、序号等元信息）。
+func (it *Iterator) Entry() types.Entry { return it.cur }
+
+// Err 返回迭代过程中遇到的错误。
+func (it *Iterator) Err() error { return it.err }
+
+// reset 让迭代器从数据区的 from 开始顺序读取，from 必须是某个块的起点。
+func (it *Iterator) reset(idx []indexEntry, from uint64) error {
+	end := it.r.ft.propsStart
+	var src io.Reader = io.NewSectionReader(it.r.f, int64(from), int64(end-from))
+	if it.verify {
+		sums, err := it.r.sums()
+		if err != nil {
+			return err
+		}
+		if sums != nil {
+			cr := &checkedReader{}
+			if err := cr.reset(it.r.f, idx, sums, from, end); err != nil {
+				return err
+			}
+			src = cr
+		}
+	}
+	if it.br == nil {
+		it.br = bufio.NewReaderSize(src, 64*1024)
+	} else {
+		it.br.Reset(src)
+	}
+	return nil
+}
+
+// next 读取下一条 key >= lower 的记录。跳过的记录不解码 value。
+func (it *Iterator) next(lower string) bool {
+	it.cur, it.ok = types.Entry{}, false
+	for {
+		h, err := readRecordHeader(it.br, uint64(it.r.size))
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				it.err = err
+			}
+			return false
+		}
+		keyB := make([]byte, h.keyLen)
+		if _, err := io.ReadFull(it.br, keyB); err != nil {
+			it.err = corruptErr(err)
+			return false
+		}
+		key := string(keyB)
+		skip := lower != "" && it.r.cmp.Compare(key, lower) < 0
+		if skip || it.keysOnly {
+			if err := h.skipValue(it.br); err != nil {
+				it.err = err
+				return false
+			}
+			if skip {
+				continue
+			}
+			it.cur, it.ok = h.entry(key, nil), true
+			return true
+		}
+
+		codec, err := h.codec(it.r.dict)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		v, err := h.readValue(it.br, codec)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.cur, it.ok = h.entry(key, v), true
+		return true
+	}
+}
@@ -0,0 +1,134 @@
+package sstable
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestIteratorMatchesRange(t *testing.T) {
+	for _, wopts := range []WriterOptions{{}, {Compression: "flate"}, {BlockSize: 1024}} {
+		path := writeReaderTable(t, 1000, wopts)
+		want, err := Range(path, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := OpenReader(path, ReadOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+
+		for _, ro := range []ReadOptions{{}, {VerifyChecksums: true}} {
+			it := NewIteratorWithOptions(r, ro)
+			i := 0
+			for it.Next() {
+				e := want[i]
+				if it.Key() != e.Key || !bytes.Equal(it.Value(), e.Value) || it.Tombstone() != e.Tombstone || it.Entry().Seq != e.Seq {
+					t.Fatalf("%+v record %d: got %+v, want %+v", wopts, i, it.Entry(), e)
+				}
+				i++
+			}
+			if it.Err() != nil || i != len(want) || it.Valid() {
+				t.Fatalf("%+v: iterated %d of %d records, err %v", wopts, i, len(want), it.Err())
+			}
+			if it.Next() {
+				t.Fatal("Next after the last record returned true")
+			}
+		}
+	}
+}
+
+func TestIteratorKeysOnly(t *testing.T) {
+	for _, wopts := range []WriterOptions{{}, {Compression: "flate"}} {
+		path := writeReaderTable(t, 1000, wopts)
+		want, err := Range(path, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := OpenReader(path, ReadOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+
+		it := NewIteratorWithOptions(r, ReadOptions{KeysOnly: true})
+		i := 0
+		for ok := it.SeekGE("key000500"); ok; ok = it.Next() {
+			e := want[500+i]
+			if it.Key() != e.Key || it.Value() != nil || it.Tombstone() != e.Tombstone || it.Entry().Seq != e.Seq {
+				t.Fatalf("%+v record %d: got %+v, want key %q", wopts, i, it.Entry(), e.Key)
+			}
+			i++
+		}
+		if it.Err() != nil || i != 500 {
+			t.Fatalf("%+v: iterated %d of 500 records, err %v", wopts, i, it.Err())
+		}
+	}
+}
+
+func TestIteratorSeekGE(t *testing.T) {
+	path := writeReaderTable(t, 500, WriterOptions{})
+	r, err := OpenReader(path, ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	it := NewIterator(r)
+	for _, tc := range []struct{ seek, want string }{
+		{"", "key000000"},
+		{"a", "key000000"},
+		{"key000123", "key000123"},
+		{"key000123x", "key000124"},
+		{"key000009", "key000009"}, // tombstone
+		{"key000499", "key000499"},
+	} {
+		if !it.SeekGE(tc.seek) || it.Key() != tc.want {
+			t.Fatalf("SeekGE(%q) = %q, valid=%v err=%v; want %q", tc.seek, it.Key(), it.Valid(), it.Err(), tc.want)
+		}
+	}
+	if !it.SeekGE("key000009") || !it.Tombstone() || it.Value() != nil {
+		t.Fatalf("key000009: tombstone=%v value=%q", it.Tombstone(), it.Value())
+	}
+
+	// Seek 之后 Next 顺序读下去，可以再次 Seek 回到前面
+	it.SeekGE("key000100")
+	for i := 101; i < 110; i++ {
+		if !it.Next() || it.Key() != fmt.Sprintf("key%06d", i) {
+			t.Fatalf("Next = %q, want key%06d", it.Key(), i)
+		}
+	}
+	if !it.SeekGE("key000001") || it.Key() != "key000001" {
+		t.Fatalf("SeekGE backwards = %q", it.Key())
+	}
+
+	if it.SeekGE("zzz") || it.Valid() || it.Err() != nil {
+		t.Fatalf("SeekGE past the end: valid=%v err=%v", it.Valid(), it.Err())
+	}
+}
+
+func TestIteratorDetectsCorruptBlock(t *testing.T) {
+	path := writeReaderTable(t, 500, WriterOptions{})
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 改坏第一个块里某条记录 value 中的一个字节：长度字段不变，只有块校验和能发现
+	b[headerSize+40] ^= 0xff
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenReader(path, ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	it := NewIteratorWithOptions(r, ReadOptions{VerifyChecksums: true})
+	if it.SeekGE("") || !errors.Is(it.Err(), ErrCorruptSST) {
+		t.Fatalf("SeekGE = %v, err %v; want ErrCorruptSST", it.Valid(), it.Err())
+	}
+}
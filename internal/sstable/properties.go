@@ -0,0 +1,278 @@
+package sstable
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EngineVersion 会写入每张表的 properties，用于追溯文件由哪个版本的引擎生成。
+const EngineVersion = "forgedb-0.1.0"
+
+// 表的创建原因。
+const (
+	ReasonFlush      = "flush"
+	ReasonCompaction = "compaction"
+	ReasonIngest     = "ingest"
+	ReasonRepair     = "repair"
+	ReasonExport     = "export"
+)
+
+// Properties 是每张 SST 附带的来源信息（provenance）。
+// 出现损坏或者意料之外的文件时，可以据此追溯它是怎么产生的。
+type Properties struct {
+	CreationReason  string            // flush / compaction / ingest / repair / export
+	InputFiles      []string          // compaction / repair 的输入文件
+	OutputFiles     []string          // 同一次 compaction 的所有输出文件（包括这张表），只有一个输出时为空
+	IngestSource    string            // ingest 的外部来源
+	BlockSize       int               // 按字节切块时的块大小，0 表示按条数（见 WriterOptions.BlockSize）
+	MaxSeq          uint64            // 表中记录的最大提交序号，写表时自动计算；0 表示记录没有序号
+	Compression     string            // 压缩 value 使用的算法名称（见 RegisterCodec），写表时自动填充
+	CompressionDict []byte            // 压缩字典（见 WriterOptions.CompressionDict），写表时自动填充
+	BlockChecksums  []uint32          // 每个数据块的 crc32c（块与索引项一一对应），写表时自动计算；旧表为空
+	ValueLogBytes   map[uint64]uint64 // 每个值日志文件被这张表引用的字节数（见 ValueRef），写表时自动计算；没有引用时为空
+	NumEntries      uint64            // 记录数（包含 tombstone），写表时自动计算；旧表为 0
+	NumTombstones   uint64            // tombstone 数，写表时自动计算；旧表为 0
+	FilterPolicy    string            // 过滤器名称（见 RegisterFilterPolicy），写表时自动填充；空表示内置的 bloom filter
+	EngineVersion   string            // 写出这张表的引擎版本
+	Host            string            // 写出这张表的主机名
+	CreatedAt       time.Time         // 创建时间
+}
+
+// properties 在文件里存成一组 string -> string，方便以后追加字段而不破坏格式。
+const (
+	propReason    = "forgedb.creation-reason"
+	propInputs    = "forgedb.input-files"
+	propOutputs   = "forgedb.output-files"
+	propIngest    = "forgedb.ingest-source"
+	propVersion   = "forgedb.engine-version"
+	propHost      = "forgedb.host"
+	propCreatedAt = "forgedb.created-at-unix-nano"
+	propBlockSize = "forgedb.block-size"
+	propMaxSeq    = "forgedb.max-seq"
+	propCodec     = "forgedb.compression"
+	propCodecDict = "forgedb.compression-dict"
+	propBlockCRC  = "forgedb.block-crc32c"
+	propValueLog  = "forgedb.value-log-refs"
+	propEntries   = "forgedb.num-entries"
+	propDeletions = "forgedb.num-tombstones"
+	propFilter    = "forgedb.filter-policy"
+
+	maxPropCount = 1 << 10
+)
+
+// withDefaults 补全调用方没有填写的 EngineVersion / Host / CreatedAt。
+func (p Properties) withDefaults() Properties {
+	if p.EngineVersion == "" {
+		p.EngineVersion = EngineVersion
+	}
+	if p.Host == "" {
+		p.Host, _ = os.Hostname()
+	}
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	}
+	return p
+}
+
+// 序列化格式：| count(uint32) | count x [keyLen(uint32) | key | valLen(uint32) | val] |
+func (p Properties) marshal() []byte {
+	kv := [][2]string{
+		{propReason, p.CreationReason},
+		{propInputs, strings.Join(p.InputFiles, ",")},
+		{propIngest, p.IngestSource},
+		{propVersion, p.EngineVersion},
+		{propHost, p.Host},
+		{propCreatedAt, strconv.FormatInt(p.CreatedAt.UnixNano(), 10)},
+	}
+	if len(p.OutputFiles) > 0 {
+		kv = append(kv, [2]string{propOutputs, strings.Join(p.OutputFiles, ",")})
+	}
+	if p.BlockSize > 0 {
+		kv = append(kv, [2]string{propBlockSize, strconv.Itoa(p.BlockSize)})
+	}
+	if p.MaxSeq > 0 {
+		kv = append(kv, [2]string{propMaxSeq, strconv.FormatUint(p.MaxSeq, 10)})
+	}
+	if p.Compression != "" {
+		kv = append(kv, [2]string{propCodec, p.Compression})
+	}
+	if len(p.CompressionDict) > 0 {
+		kv = append(kv, [2]string{propCodecDict, string(p.CompressionDict)})
+	}
+	if len(p.BlockChecksums) > 0 {
+		kv = append(kv, [2]string{propBlockCRC, marshalChecksums(p.BlockChecksums)})
+	}
+	if len(p.ValueLogBytes) > 0 {
+		kv = append(kv, [2]string{propValueLog, marshalValueLogBytes(p.ValueLogBytes)})
+	}
+	if p.NumEntries > 0 {
+		kv = append(kv, [2]string{propEntries, strconv.FormatUint(p.NumEntries, 10)})
+	}
+	if p.NumTombstones > 0 {
+		kv = append(kv, [2]string{propDeletions, strconv.FormatUint(p.NumTombstones, 10)})
+	}
+	if p.FilterPolicy != "" {
+		kv = append(kv, [2]string{propFilter, p.FilterPolicy})
+	}
+
+	out := binary.LittleEndian.AppendUint32(nil, uint32(len(kv)))
+	for _, it := range kv {
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(it[0])))
+		out = append(out, it[0]...)
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(it[1])))
+		out = append(out, it[1]...)
+	}
+	return out
+}
+
+func unmarshalProperties(b []byte) (Properties, bool) {
+	var p Properties
+
+	next := func() (string, bool) {
+		if len(b) < 4 {
+			return "", false
+		}
+		n := binary.LittleEndian.Uint32(b)
+		b = b[4:]
+		if uint64(n) > uint64(len(b)) {
+			return "", false
+		}
+		s := string(b[:n])
+		b = b[n:]
+		return s, true
+	}
+
+	if len(b) < 4 {
+		return p, false
+	}
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	if count > maxPropCount {
+		return p, false
+	}
+
+	for i := uint32(0); i < count; i++ {
+		k, ok := next()
+		if !ok {
+			return p, false
+		}
+		v, ok := next()
+		if !ok {
+			return p, false
+		}
+
+		// 不认识的 key 直接忽略，保持向前兼容
+		switch k {
+		case propReason:
+			p.CreationReason = v
+		case propInputs:
+			if v != "" {
+				p.InputFiles = strings.Split(v, ",")
+			}
+		case propOutputs:
+			if v != "" {
+				p.OutputFiles = strings.Split(v, ",")
+			}
+		case propIngest:
+			p.IngestSource = v
+		case propVersion:
+			p.EngineVersion = v
+		case propHost:
+			p.Host = v
+		case propCreatedAt:
+			ns, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return p, false
+			}
+			p.CreatedAt = time.Unix(0, ns)
+		case propBlockSize:
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return p, false
+			}
+			p.BlockSize = n
+		case propMaxSeq:
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return p, false
+			}
+			p.MaxSeq = n
+		case propCodec:
+			p.Compression = v
+		case propCodecDict:
+			p.CompressionDict = []byte(v)
+		case propBlockCRC:
+			sums, ok := unmarshalChecksums(v)
+			if !ok {
+				return p, false
+			}
+			p.BlockChecksums = sums
+		case propValueLog:
+			m, ok := unmarshalValueLogBytes(v)
+			if !ok {
+				return p, false
+			}
+			p.ValueLogBytes = m
+		case propEntries, propDeletions:
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return p, false
+			}
+			if k == propEntries {
+				p.NumEntries = n
+			} else {
+				p.NumTombstones = n
+			}
+		case propFilter:
+			p.FilterPolicy = v
+		}
+	}
+
+	if len(b) != 0 {
+		return p, false
+	}
+	return p, true
+}
+
+// ReadProperties 读取表的 properties 区。
+func ReadProperties(path string) (Properties, error) {
+	return ReadPropertiesWithOptions(path, ReadOptions{})
+}
+
+// ReadPropertiesWithOptions 与 ReadProperties 相同，加密的表用 opts.Keys 解密。
+func ReadPropertiesWithOptions(path string, opts ReadOptions) (Properties, error) {
+	f, err := openTable(path, opts)
+	if err != nil {
+		return Properties{}, err
+	}
+	defer f.Close()
+	return readProperties(f, f.Size())
+}
+
+// readProperties 从已打开的表里读取 properties 区。
+func readProperties(f io.ReaderAt, fileSize int64) (Properties, error) {
+	ft, err := readFooter(f, fileSize)
+	if err != nil {
+		return Properties{}, err
+	}
+	if ft.propsStart == ft.indexStart {
+		// 16 字节 footer 的旧表没有 properties 区
+		return Properties{}, nil
+	}
+
+	sr := io.NewSectionReader(f, int64(ft.propsStart), int64(ft.indexStart-ft.propsStart))
+	b, err := io.ReadAll(sr)
+	if err != nil {
+		return Properties{}, err
+	}
+
+	p, ok := unmarshalProperties(b)
+	if !ok {
+		return Properties{}, ErrCorruptSST
+	}
+	return p, nil
+}
@@ -0,0 +1,155 @@
+package sstable
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"monolithdb/internal/types"
+)
+
+func TestPropertiesRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "000001.sst")
+
+	entries := []types.Entry{
+		{Key: "a", Value: []byte("1")},
+		{Key: "b", Value: []byte("2")},
+	}
+	created := time.Unix(1700000000, 42)
+	opts := WriterOptions{
+		Properties: Properties{
+			CreationReason: ReasonCompaction,
+			InputFiles:     []string{"000003.sst", "000004.sst"},
+			OutputFiles:    []string{"000005.sst", "000006.sst"},
+			Host:           "node-1",
+			CreatedAt:      created,
+		},
+	}
+	if err := WriteTableWithOptions(path, entries, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ReadProperties(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.CreationReason != ReasonCompaction || p.Host != "node-1" {
+		t.Fatalf("unexpected properties: %+v", p)
+	}
+	if !reflect.DeepEqual(p.InputFiles, opts.Properties.InputFiles) {
+		t.Fatalf("expected inputs %v, got %v", opts.Properties.InputFiles, p.InputFiles)
+	}
+	if !reflect.DeepEqual(p.OutputFiles, opts.Properties.OutputFiles) {
+		t.Fatalf("expected outputs %v, got %v", opts.Properties.OutputFiles, p.OutputFiles)
+	}
+	if !p.CreatedAt.Equal(created) {
+		t.Fatalf("expected created-at %v, got %v", created, p.CreatedAt)
+	}
+	// 没填的字段应自动补全
+	if p.EngineVersion != EngineVersion {
+		t.Fatalf("expected engine version %q, got %q", EngineVersion, p.EngineVersion)
+	}
+
+	// properties 区不能影响点查
+	if _, res, err := Get(path, "b"); err != nil || res != Found {
+		t.Fatalf("expected b Found, got res=%v err=%v", res, err)
+	}
+}
+
+func TestEntryCountProperties(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "000001.sst")
+	entries := []types.Entry{
+		{Key: "a", Value: []byte("1")},
+		{Key: "b", Tombstone: true},
+		{Key: "c", Tombstone: true},
+		{Key: "d", Value: []byte{}},
+	}
+	// 调用方填的计数会被覆盖
+	opts := WriterOptions{Properties: Properties{NumEntries: 100, NumTombstones: 50}}
+	if err := WriteTableWithOptions(path, entries, opts); err != nil {
+		t.Fatal(err)
+	}
+	p, err := ReadProperties(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.NumEntries != 4 || p.NumTombstones != 2 {
+		t.Fatalf("entries = %d, tombstones = %d; want 4, 2", p.NumEntries, p.NumTombstones)
+	}
+}
+
+func TestSeqRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "000001.sst")
+	entries := []types.Entry{
+		{Key: "a", Value: []byte("1"), Seq: 7},
+		{Key: "b", Tombstone: true, Seq: 9},
+		{Key: "c", Value: []byte("3")}, // 没有序号的记录照常读写
+	}
+	if err := WriteTableWithOptions(path, entries, WriterOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ReadProperties(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.MaxSeq != 9 {
+		t.Fatalf("expected max-seq 9, got %d", p.MaxSeq)
+	}
+
+	got, err := RangeWithOptions(path, "", "", ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Fatalf("expected %+v, got %+v", entries, got)
+	}
+	// tombstone 也要带回序号，供上层和其他表比较
+	if e, res, err := GetEntryWithOptions(path, "b", ReadOptions{}); err != nil || res != Deleted || e.Seq != 9 {
+		t.Fatalf("expected b Deleted with seq 9, got %+v res=%v err=%v", e, res, err)
+	}
+}
+
+func TestValueRefRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "000001.sst")
+	r1 := ValueRef{File: 3, Offset: 0, Size: 120}
+	r2 := ValueRef{File: 3, Offset: 120, Size: 80}
+	r3 := ValueRef{File: 5, Offset: 40, Size: 1000}
+	entries := []types.Entry{
+		{Key: "a", Value: r1.Encode(), ValueRef: true, Seq: 1},
+		{Key: "b", Value: []byte("inline")},
+		{Key: "c", Value: r2.Encode(), ValueRef: true},
+		{Key: "d", Value: r3.Encode(), ValueRef: true, ExpiresAt: 99},
+	}
+	// 引用不压缩，即使开启了压缩
+	if err := WriteTableWithOptions(path, entries, WriterOptions{Compression: "flate"}); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ReadProperties(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[uint64]uint64{3: 200, 5: 1000}; !reflect.DeepEqual(p.ValueLogBytes, want) {
+		t.Fatalf("value log bytes = %v, want %v", p.ValueLogBytes, want)
+	}
+	got, err := RangeWithOptions(path, "", "", ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Fatalf("expected %+v, got %+v", entries, got)
+	}
+	if e, res, err := GetEntry(path, "c"); err != nil || res != Found || !e.ValueRef {
+		t.Fatalf("GetEntry(c) = %+v, %v, %v", e, res, err)
+	} else if ref, ok := DecodeValueRef(e.Value); !ok || ref != r2 {
+		t.Fatalf("decoded %+v, %v; want %+v", ref, ok, r2)
+	}
+
+	bad := []types.Entry{{Key: "a", Value: []byte("short"), ValueRef: true}}
+	if err := WriteTable(filepath.Join(t.TempDir(), "000002.sst"), bad); err == nil {
+		t.Fatal("expected an error for an invalid value reference")
+	}
+}
@@ -0,0 +1,89 @@
+package sstable
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"iter"
+
+	"monolithdb/internal/types"
+)
+
+// Range 返回表中 [start, end) 的有序记录（包含 tombstone）。
+// start 为空表示从头开始，end 为空表示直到末尾。
+// 通过稀疏索引定位起点，之后顺序读取数据区。
+func Range(path string, start, end string) ([]types.Entry, error) {
+	return RangeWithOptions(path, start, end, ReadOptions{})
+}
+
+// RangeWithOptions 与 Range 相同，但按 opts.Comparer 的顺序判断范围。
+func RangeWithOptions(path string, start, end string, opts ReadOptions) ([]types.Entry, error) {
+	var out []types.Entry
+	for e, err := range RangeIter(path, start, end, opts) {
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// RangeIter 与 RangeWithOptions 相同，但逐条返回记录，不把整个范围读进内存。
+// 迭代期间文件保持打开。
+func RangeIter(path string, start, end string, opts ReadOptions) iter.Seq2[types.Entry, error] {
+	return func(yield func(types.Entry, error) bool) {
+		f, err := openTable(path, opts)
+		if err != nil {
+			yield(types.Entry{}, err)
+			return
+		}
+		defer f.Close()
+
+		var m uint32
+		if err := binary.Read(f, binary.LittleEndian, &m); err != nil || m != magic {
+			yield(types.Entry{}, ErrCorruptSST)
+			return
+		}
+
+		fileSize := f.Size()
+
+		cmp := opts.comparer()
+		idx, dataEnd, err := loadIndex(f, fileSize, cmp)
+		if err != nil {
+			yield(types.Entry{}, err)
+			return
+		}
+
+		// 起点：最后一个 <= start 的索引项；终点一直到数据区末尾
+		from, _ := pickScanRange(idx, dataEnd, start, cmp)
+		if start == "" {
+			from = idx[0].offset
+		}
+
+		r, err := dataReader(f, fileSize, idx, from, dataEnd, opts.VerifyChecksums)
+		if err != nil {
+			yield(types.Entry{}, err)
+			return
+		}
+		dict := tableDict(f, fileSize)
+
+		for {
+			e, err := readRecord(r, uint64(fileSize), dict)
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					yield(types.Entry{}, err)
+				}
+				return
+			}
+			if start != "" && cmp.Compare(e.Key, start) < 0 {
+				continue
+			}
+			if end != "" && cmp.Compare(e.Key, end) >= 0 {
+				return
+			}
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package sstable
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"unsafe"
+
+	"monolithdb/internal/types"
+)
+
+// Reader 是一张打开的表，用于对同一张表反复点查（DB 为每张表缓存一个）。
+//
+// header 和 footer 在 OpenReader 时读取；过滤器（bloom）、索引、字典和块校验和在第一次用到时读取并缓存，
+// 之后的点查只读索引选出的那一段数据区。读缓冲和 key 缓冲来自 sync.Pool，跳过的记录不解码 value，
+// 所以一次点查只为命中记录的 value 分配内存。
+//
+// Reader 并发安全。Close 之后不能再使用。
+type Reader struct {
+	f    *tableFile
+	size int64
+	ft   footer
+	cmp  types.Comparer
+
+	filter func() (tableFilter, error)
+	index  func() ([]indexEntry, error)
+	sums   func() ([]uint32, error)
+	dict   func() ([]byte, error)
+}
+
+// OpenReader 打开 path 并读取 header 和 footer。opts.Comparer 和 opts.Keys 对之后的所有点查生效，
+// IgnoreBloom / VerifyChecksums 在每次点查时单独指定（见 GetEntryWithOptions）。
+func OpenReader(path string, opts ReadOptions) (*Reader, error) {
+	f, err := openTable(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	var hdr [headerSize]byte
+	if _, err := f.ReadAt(hdr[:], 0); err != nil || binary.LittleEndian.Uint32(hdr[0:4]) != magic {
+		f.Close()
+		return nil, ErrCorruptSST
+	}
+	ft, err := readFooter(f, f.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r := &Reader{f: f, size: f.Size(), ft: ft, cmp: opts.comparer()}
+	r.filter = sync.OnceValues(r.loadFilter)
+	r.index = sync.OnceValues(r.loadIndex)
+	r.sums = sync.OnceValues(func() ([]uint32, error) {
+		idx, err := r.index()
+		if err != nil {
+			return nil, err
+		}
+		return blockSums(f, r.size, idx)
+	})
+	r.dict = sync.OnceValues(tableDict(f, r.size))
+	return r, nil
+}
+
+// IndexKeys 返回稀疏索引里每个块的起点（升序），可以用来把表的 key 范围切成大小相近的几段。
+// 起点可能是缩短过的分隔 key（见 types.Comparer），不一定是表里真实存在的 key。
+func (r *Reader) IndexKeys() ([]string, error) {
+	idx, err := r.index()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(idx))
+	for i, it := range idx {
+		keys[i] = it.key
+	}
+	return keys, nil
+}
+
+// Close 关闭表文件。
+func (r *Reader) Close() error { return r.f.Close() }
+
+// loadFilter 按 properties 里记录的过滤器名称解析 filter 区。
+func (r *Reader) loadFilter() (tableFilter, error) {
+	props, err := readProperties(r.f, r.size)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, r.ft.start-r.ft.bloomStart)
+	if _, err := r.f.ReadAt(b, int64(r.ft.bloomStart)); err != nil {
+		return nil, ErrCorruptSST
+	}
+	return parseFilter(b, props)
+}
+
+func (r *Reader) loadIndex() ([]indexEntry, error) {
+	idx, dataEnd, err := loadIndex(r.f, r.size, r.cmp)
+	if err != nil {
+		return nil, err
+	}
+	// 防御：确保 loadIndex 读到的 offset 与 footer 一致
+	if dataEnd != r.ft.propsStart {
+		return nil, ErrCorruptSST
+	}
+	return idx, nil
+}
+
+// getScratch 是一次点查使用的临时对象，在点查之间通过 getPool 复用。
+type getScratch struct {
+	sr  io.SectionReader
+	cr  checkedReader
+	br  *bufio.Reader
+	key []byte
+}
+
+var getPool = sync.Pool{
+	New: func() any { return &getScratch{br: bufio.NewReaderSize(nil, 16*1024)} },
+}
+
+// release 断开对表文件的引用（池里的对象不能让已经关闭的表继续被引用），保留缓冲区，放回池中。
+func (sc *getScratch) release() {
+	sc.br.Reset(nil)
+	sc.sr = io.SectionReader{}
+	sc.cr = checkedReader{buf: sc.cr.buf[:0]}
+	getPool.Put(sc)
+}
+
+// GetEntry 使用默认配置查找 key，见 GetEntryWithOptions。
+func (r *Reader) GetEntry(key string) (types.Entry, GetResult, error) {
+	return r.GetEntryWithOptions(key, ReadOptions{})
+}
+
+// GetEntryWithOptions 查找 key，结果与包级的 GetEntryWithOptions 相同。
+// opts 里只有 IgnoreBloom 和 VerifyChecksums 生效，比较器和密钥以 OpenReader 时的为准。
+func (r *Reader) GetEntryWithOptions(key string, opts ReadOptions) (types.Entry, GetResult, error) {
+	if !opts.IgnoreBloom {
+		filter, err := r.filter()
+		if err != nil {
+			return types.Entry{}, NotFound, err
+		}
+		// Bloom 明确“不存在” => 快速返回
+		if !filter.mayContain(key) {
+			return types.Entry{}, NotFound, nil
+		}
+	}
+
+	idx, err := r.index()
+	if err != nil {
+		return types.Entry{}, NotFound, err
+	}
+	start, end := pickScanRange(idx, r.ft.propsStart, key, r.cmp)
+	if end <= start {
+		return types.Entry{}, NotFound, ErrCorruptSST
+	}
+
+	sc := getPool.Get().(*getScratch)
+	defer sc.release()
+	sc.sr = *io.NewSectionReader(r.f, int64(start), int64(end-start))
+	sc.br.Reset(&sc.sr)
+	if opts.VerifyChecksums {
+		sums, err := r.sums()
+		if err != nil {
+			return types.Entry{}, NotFound, err
+		}
+		if sums != nil {
+			if err := sc.cr.reset(r.f, idx, sums, start, end); err != nil {
+				return types.Entry{}, NotFound, err
+			}
+			sc.br.Reset(&sc.cr)
+		}
+	}
+
+	for {
+		h, err := readRecordHeader(sc.br, uint64(r.size))
+		if err != nil {
+			// 区间读完就结束：没找到
+			if errors.Is(err, io.EOF) {
+				return types.Entry{}, NotFound, nil
+			}
+			return types.Entry{}, NotFound, err
+		}
+		if cap(sc.key) < int(h.keyLen) {
+			sc.key = make([]byte, h.keyLen)
+		}
+		sc.key = sc.key[:h.keyLen]
+		if _, err := io.ReadFull(sc.br, sc.key); err != nil {
+			return types.Entry{}, NotFound, corruptErr(err)
+		}
+
+		// 比较器不会保存参数，可以直接把缓冲区当成字符串比较，不必为每条跳过的记录分配 key
+		switch c := r.cmp.Compare(unsafe.String(unsafe.SliceData(sc.key), len(sc.key)), key); {
+		case c < 0:
+			if err := h.skipValue(sc.br); err != nil {
+				return types.Entry{}, NotFound, err
+			}
+			continue
+		case c > 0:
+			return types.Entry{}, NotFound, nil
+		}
+
+		codec, err := h.codec(r.dict)
+		if err != nil {
+			return types.Entry{}, NotFound, err
+		}
+		v, err := h.readValue(sc.br, codec)
+		if err != nil {
+			return types.Entry{}, NotFound, err
+		}
+		// 只有完全相同的 key 才相等（见 types.Comparer），直接使用参数，不再复制一份
+		e := h.entry(key, v)
+		if e.Tombstone {
+			return e, Deleted, nil
+		}
+		return e, Found, nil
+	}
+}
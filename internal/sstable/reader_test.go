@@ -0,0 +1,175 @@
+package sstable
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"monolithdb/internal/types"
+)
+
+func writeReaderTable(tb testing.TB, n int, opts WriterOptions) string {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "000001.sst")
+	entries := make([]types.Entry, n)
+	for i := range entries {
+		entries[i] = types.Entry{Key: fmt.Sprintf("key%06d", i), Value: bytes.Repeat([]byte{byte('a' + i%26)}, 100), Seq: uint64(i + 1)}
+		if i%10 == 9 {
+			entries[i] = types.Entry{Key: entries[i].Key, Tombstone: true, Seq: uint64(i + 1)}
+		}
+	}
+	opts.NoSync = true
+	if err := WriteTableWithOptions(path, entries, opts); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+func TestReaderMatchesGetEntry(t *testing.T) {
+	for _, wopts := range []WriterOptions{{}, {Compression: "flate"}} {
+		path := writeReaderTable(t, 2000, wopts)
+		r, err := OpenReader(path, ReadOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		for w := range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := w; i < 2100; i += 4 {
+					key := fmt.Sprintf("key%06d", i)
+					for _, ro := range []ReadOptions{{}, {IgnoreBloom: true, VerifyChecksums: true}} {
+						want, wantRes, wantErr := GetEntryWithOptions(path, key, ro)
+						got, gotRes, err := r.GetEntryWithOptions(key, ro)
+						if err != nil || wantErr != nil || gotRes != wantRes || got.Key != want.Key || got.Seq != want.Seq || !bytes.Equal(got.Value, want.Value) {
+							t.Errorf("%s %+v: got %v %v %v, want %v %v %v", key, ro, got, gotRes, err, want, wantRes, wantErr)
+							return
+						}
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReaderKeyBeforeFirst(t *testing.T) {
+	path := writeReaderTable(t, 100, WriterOptions{})
+	r, err := OpenReader(path, ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, key := range []string{"a", "key", "key000000x", "zzz"} {
+		if _, res, err := r.GetEntryWithOptions(key, ReadOptions{IgnoreBloom: true}); err != nil || res != NotFound {
+			t.Fatalf("Get(%q) = %v, %v", key, res, err)
+		}
+	}
+}
+
+func TestReaderCountsBytesRead(t *testing.T) {
+	path := writeReaderTable(t, 2000, WriterOptions{})
+	st, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n atomic.Uint64
+	r, err := OpenReader(path, ReadOptions{BytesRead: &n})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	opened := n.Load()
+	if opened == 0 {
+		t.Fatal("header and footer reads not counted")
+	}
+
+	// 一次点查读过滤器、索引和一个块，远小于整张表
+	if _, res, err := r.GetEntry("key001000"); err != nil || res != Found {
+		t.Fatalf("Get = %v, %v", res, err)
+	}
+	first := n.Load() - opened
+	if first == 0 || first >= uint64(st.Size()) {
+		t.Fatalf("first Get read %d bytes of a %d-byte table", first, st.Size())
+	}
+	// 过滤器和索引已经缓存，之后只读数据块
+	before := n.Load()
+	if _, _, err := r.GetEntry("key001500"); err != nil {
+		t.Fatal(err)
+	}
+	if got := n.Load() - before; got == 0 || got >= first {
+		t.Fatalf("second Get read %d bytes, first %d", got, first)
+	}
+
+	// 完整遍历读完整个数据区
+	before = n.Load()
+	it := NewIterator(r)
+	for it.Next() {
+	}
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+	if got := n.Load() - before; got < uint64(r.ft.propsStart)-headerSize {
+		t.Fatalf("full scan read %d bytes, data region is %d", got, r.ft.propsStart)
+	}
+}
+
+func BenchmarkGetEntry(b *testing.B) {
+	path := writeReaderTable(b, 100000, WriterOptions{})
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		if _, _, err := GetEntry(path, fmt.Sprintf("key%06d", i*7919%100000)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReaderGetEntry(b *testing.B) {
+	path := writeReaderTable(b, 100000, WriterOptions{})
+	r, err := OpenReader(path, ReadOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer r.Close()
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%06d", i*7919%100000)
+	}
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		if _, _, err := r.GetEntry(keys[i%len(keys)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReaderGetEntryParallel(b *testing.B) {
+	path := writeReaderTable(b, 100000, WriterOptions{})
+	r, err := OpenReader(path, ReadOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer r.Close()
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%06d", i*7919%100000)
+	}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if _, _, err := r.GetEntry(keys[i%len(keys)]); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
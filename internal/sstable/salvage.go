@@ -0,0 +1,178 @@
+package sstable
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"monolithdb/internal/types"
+)
+
+// LostRange 是 Salvage 跳过的一段无法解码的数据区。
+type LostRange struct {
+	Offset uint64 // 在（解密后的）文件里的起始偏移
+	Length uint64 // 字节数
+
+	// After / Before 是损坏区两侧恢复出来的 key，丢失的记录都在 (After, Before) 之间。
+	// 损坏区在第一条 / 最后一条可读记录之外时对应的一侧为空。
+	After, Before string
+}
+
+// SalvageReport 描述 Salvage 的结果。
+type SalvageReport struct {
+	Recovered int         // 恢复出来、写入新表的记录数
+	Expected  uint32      // header 里记录的记录数（header 本身损坏时没有意义）
+	Lost      []LostRange // 跳过的损坏区，按偏移升序
+}
+
+// Salvage 使用默认配置从损坏的表 path 中尽量恢复记录，写成 dst 处的一张新表，见 SalvageWithOptions。
+func Salvage(path, dst string) (SalvageReport, error) {
+	return SalvageWithOptions(path, dst, ReadOptions{}, WriterOptions{})
+}
+
+// SalvageWithOptions 逐条扫描 path 的数据区，跳过无法解码的区域，把恢复出来的记录按 wopts 写成 dst 处的新表。
+// 不依赖索引和 bloom；footer 完好时以它确定数据区的结尾，否则扫描到文件末尾。
+//
+// 表里没有逐条的校验和，所以损坏是按“解码失败或者 key 不递增”判断的（见 plausible）：遇到这样的位置后
+// 逐字节向后寻找下一个合理的记录，中间的字节记为一个 LostRange。
+// 长度字段以外的位翻转（例如 value 里的字节）无法发现，会原样保留在新表里。
+//
+// 一条记录也恢复不出来时返回 ErrCorruptSST，不写 dst。加密的表密文损坏时整张表都无法解密，同样返回 ErrCorruptSST。
+// wopts.Properties.CreationReason 为空时记为 ReasonRepair，输入文件是 path。
+func SalvageWithOptions(path, dst string, opts ReadOptions, wopts WriterOptions) (SalvageReport, error) {
+	var rep SalvageReport
+	f, err := openTable(path, opts)
+	if err != nil {
+		return rep, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, f.Size()))
+	if err != nil {
+		return rep, err
+	}
+	if len(data) < headerSize {
+		return rep, fmt.Errorf("%w: no data section", ErrCorruptSST)
+	}
+	rep.Expected = binary.LittleEndian.Uint32(data[4:headerSize])
+
+	end := uint64(len(data))
+	if ft, err := readFooter(f, f.Size()); err == nil {
+		end = ft.propsStart
+	}
+
+	s := salvager{
+		data: data[:end],
+		cmp:  opts.comparer(),
+		dict: tableDict(f, f.Size()),
+	}
+	entries, lost := s.scan()
+	rep.Recovered, rep.Lost = len(entries), lost
+	if len(entries) == 0 {
+		return rep, fmt.Errorf("%w: no records could be recovered", ErrCorruptSST)
+	}
+
+	if wopts.Properties.CreationReason == "" {
+		wopts.Properties.CreationReason = ReasonRepair
+		wopts.Properties.InputFiles = []string{filepath.Base(path)}
+	}
+	if wopts.Comparer == nil {
+		wopts.Comparer = opts.Comparer
+	}
+	return rep, WriteTableWithOptions(dst, entries, wopts)
+}
+
+type salvager struct {
+	data []byte
+	cmp  types.Comparer
+	dict func() ([]byte, error)
+}
+
+// decode 解码 pos 处的一条记录，返回它和下一条记录的位置。
+func (s *salvager) decode(pos uint64) (types.Entry, uint64, bool) {
+	if pos >= uint64(len(s.data)) {
+		return types.Entry{}, 0, false
+	}
+	r := bufio.NewReaderSize(bytes.NewReader(s.data[pos:]), 64)
+	e, n, err := readRecordN(r, uint64(len(s.data))-pos, s.dict)
+	if err != nil {
+		return types.Entry{}, 0, false
+	}
+	return e, pos + n, true
+}
+
+// plausible 判断 pos 处是否是一条可以接在 last 之后的记录：它能解码、key 比 last 大，
+// 并且紧接着的下一条也能解码且 key 更大（或者它正好结束在数据区末尾）。
+// 只看一条的话，长度字段损坏的记录会“吞掉”后面的正常记录，损坏区里碰巧像记录的字节也容易被当成记录。
+func (s *salvager) plausible(pos uint64, last *types.Entry) (types.Entry, uint64, bool) {
+	e, next, ok := s.decode(pos)
+	if !ok || (last != nil && s.cmp.Compare(last.Key, e.Key) >= 0) {
+		return types.Entry{}, 0, false
+	}
+	if next != uint64(len(s.data)) {
+		e2, _, ok := s.decode(next)
+		if !ok || s.cmp.Compare(e.Key, e2.Key) >= 0 {
+			return types.Entry{}, 0, false
+		}
+	}
+	return e, next, true
+}
+
+// accept 判断顺序扫描时 pos 处的记录能否保留。与 plausible 不同，下一条解码失败时
+// 只要这条记录的范围里找不到被它吞掉的正常记录，就认为损坏从下一条开始，这条依然保留。
+func (s *salvager) accept(pos uint64, last *types.Entry) (types.Entry, uint64, bool) {
+	if e, next, ok := s.plausible(pos, last); ok {
+		return e, next, true
+	}
+	e, next, ok := s.decode(pos)
+	if !ok || (last != nil && s.cmp.Compare(last.Key, e.Key) >= 0) {
+		return types.Entry{}, 0, false
+	}
+	for q := pos + 1; q < next; q++ {
+		if _, _, ok := s.plausible(q, last); ok {
+			return types.Entry{}, 0, false
+		}
+	}
+	return e, next, true
+}
+
+// scan 从 header 之后开始顺序解码，遇到损坏就逐字节向后重新同步。
+func (s *salvager) scan() ([]types.Entry, []LostRange) {
+	var out []types.Entry
+	var lost []LostRange
+	last := func() *types.Entry {
+		if len(out) == 0 {
+			return nil
+		}
+		return &out[len(out)-1]
+	}
+
+	end := uint64(len(s.data))
+	for pos := uint64(headerSize); pos < end; {
+		if e, next, ok := s.accept(pos, last()); ok {
+			out = append(out, e)
+			pos = next
+			continue
+		}
+
+		bad := pos
+		for pos++; pos < end; pos++ {
+			if _, _, ok := s.plausible(pos, last()); ok {
+				break
+			}
+		}
+		lr := LostRange{Offset: bad, Length: pos - bad}
+		if l := last(); l != nil {
+			lr.After = l.Key
+		}
+		if pos < end {
+			e, _, _ := s.decode(pos)
+			lr.Before = e.Key
+		}
+		lost = append(lost, lr)
+	}
+	return out, lost
+}
@@ -0,0 +1,122 @@
+package sstable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/types"
+)
+
+func salvageEntries(n int) []types.Entry {
+	entries := make([]types.Entry, n)
+	for i := range entries {
+		entries[i] = types.Entry{Key: fmt.Sprintf("key%05d", i), Value: []byte(fmt.Sprintf("value-%d", i))}
+	}
+	return entries
+}
+
+// corruptRecord 把 key 所在记录的 keyLen 改成一个越界的值。
+func corruptRecord(t *testing.T, b []byte, key string) {
+	t.Helper()
+	i := bytes.Index(b, []byte(key))
+	if i < recordHeaderSize {
+		t.Fatalf("key %q not found", key)
+	}
+	binary.LittleEndian.PutUint32(b[i-recordHeaderSize:], 0xfffffff0)
+}
+
+func TestSalvage(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "000001.sst")
+	entries := salvageEntries(200)
+	if err := WriteTableWithOptions(src, entries, WriterOptions{NoSync: true}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corruptRecord(t, b, "key00050")
+	// 再把从 key00121 开始的一段整体写成垃圾
+	i := bytes.Index(b, []byte("key00121")) - recordHeaderSize
+	copy(b[i:i+52], bytes.Repeat([]byte{0xee}, 52))
+	if err := os.WriteFile(src, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ScanData(src); !errors.Is(err, ErrCorruptSST) {
+		t.Fatalf("ScanData = %v, want ErrCorruptSST", err)
+	}
+
+	dst := filepath.Join(dir, "000002.sst")
+	rep, err := Salvage(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Expected != 200 || rep.Recovered < 190 || rep.Recovered >= 200 {
+		t.Fatalf("report = %+v", rep)
+	}
+	if len(rep.Lost) != 2 || rep.Lost[0].After >= "key00050" || rep.Lost[0].Before <= "key00050" ||
+		rep.Lost[1].After != "key00120" || rep.Lost[1].Before <= "key00121" {
+		t.Fatalf("lost ranges = %+v", rep.Lost)
+	}
+
+	if err := Verify(dst); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ScanData(dst)
+	if err != nil || len(got) != rep.Recovered {
+		t.Fatalf("ScanData(salvaged) = %d entries, %v", len(got), err)
+	}
+	want := make(map[string]string)
+	for _, e := range entries {
+		want[e.Key] = string(e.Value)
+	}
+	for _, e := range got {
+		if want[e.Key] != string(e.Value) {
+			t.Fatalf("salvaged %q = %q, want %q", e.Key, e.Value, want[e.Key])
+		}
+	}
+	for _, k := range []string{"key00000", "key00049", "key00051", "key00199"} {
+		if v, res, err := Get(dst, k); err != nil || res != Found || string(v) != want[k] {
+			t.Fatalf("Get(%q) = %q, %v, %v", k, v, res, err)
+		}
+	}
+	props, err := ReadProperties(dst)
+	if err != nil || props.CreationReason != ReasonRepair || len(props.InputFiles) != 1 || props.InputFiles[0] != "000001.sst" {
+		t.Fatalf("properties = %+v, %v", props, err)
+	}
+}
+
+func TestSalvageWithoutFooter(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "000001.sst")
+	if err := WriteTableWithOptions(src, salvageEntries(50), WriterOptions{NoSync: true}); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(src)
+	copy(b[len(b)-footerSize:], make([]byte, footerSize))
+	corruptRecord(t, b, "key00010")
+	os.WriteFile(src, b, 0o644)
+
+	rep, err := Salvage(src, filepath.Join(dir, "000002.sst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Recovered < 45 {
+		t.Fatalf("report = %+v", rep)
+	}
+
+	garbage := filepath.Join(dir, "garbage.sst")
+	os.WriteFile(garbage, bytes.Repeat([]byte{0xab}, 4096), 0o644)
+	if _, err := Salvage(garbage, filepath.Join(dir, "out.sst")); !errors.Is(err, ErrCorruptSST) {
+		t.Fatalf("Salvage(garbage) = %v, want ErrCorruptSST", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out.sst")); !os.IsNotExist(err) {
+		t.Fatalf("Salvage(garbage) wrote output: %v", err)
+	}
+}
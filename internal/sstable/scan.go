@@ -0,0 +1,293 @@
+package sstable
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"monolithdb/internal/types"
+)
+
+// ScanData 不依赖 footer / 索引 / bloom，直接按 header 里的 count 顺序解码数据区。
+// 用于 Repair：当元数据区损坏但数据区完好时，可以用返回的 entries 重建整张表。
+// 数据区本身损坏（长度越界、key 非递增、提前 EOF）时返回 ErrCorruptSST。
+func ScanData(path string) ([]types.Entry, error) {
+	return ScanDataWithOptions(path, ReadOptions{})
+}
+
+// ScanDataWithOptions 与 ScanData 相同，但按 opts.Comparer 检查 key 是否递增。
+func ScanDataWithOptions(path string, opts ReadOptions) ([]types.Entry, error) {
+	cmp := opts.comparer()
+
+	f, err := openTable(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fileSize := uint64(f.Size())
+
+	r := bufio.NewReaderSize(f, 64*1024)
+
+	var m, count uint32
+	if err := binary.Read(r, binary.LittleEndian, &m); err != nil {
+		return nil, ErrCorruptSST
+	}
+	if m != magic {
+		return nil, ErrCorruptSST
+	}
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, ErrCorruptSST
+	}
+
+	// 每条 record 至少 9 字节（keyLen + valLen + tomb），count 不可能超过这个上限
+	if uint64(count)*9 > fileSize {
+		return nil, ErrCorruptSST
+	}
+
+	out := make([]types.Entry, 0, count)
+	dict := tableDict(f, int64(fileSize))
+	for i := uint32(0); i < count; i++ {
+		e, err := readRecord(r, fileSize, dict)
+		if err != nil {
+			return nil, ErrCorruptSST
+		}
+		if len(out) > 0 && cmp.Compare(out[len(out)-1].Key, e.Key) >= 0 {
+			return nil, ErrCorruptSST
+		}
+		out = append(out, e)
+	}
+
+	return out, nil
+}
+
+// readRecord 解码一条 record：
+// | keyLen(uint32) | valLen(uint32) | flags(1B) | [expiresAt(int64)] | [seq(uint64)] | [codec(1B)] | key | val |
+// limit 用来拦截明显越界的长度，避免坏数据触发超大分配。压缩过的 val 会被解压，
+// 用表自带字典压缩的记录通过 dict 取得字典。
+// 在 record 开头就读不到数据时返回 io.EOF，codec 未注册时返回 ErrUnknownCodec，
+// 其余解码失败一律返回 ErrCorruptSST。
+func readRecord(r *bufio.Reader, limit uint64, dict func() ([]byte, error)) (types.Entry, error) {
+	e, _, err := readRecordN(r, limit, dict)
+	return e, err
+}
+
+// readRecordN 与 readRecord 相同，另外返回这条 record 在文件里占用的字节数。
+func readRecordN(r *bufio.Reader, limit uint64, dict func() ([]byte, error)) (types.Entry, uint64, error) {
+	h, err := readRecordHeader(r, limit)
+	if err != nil {
+		return types.Entry{}, 0, err
+	}
+	codec, err := h.codec(dict)
+	if err != nil {
+		return types.Entry{}, 0, err
+	}
+
+	keyB := make([]byte, h.keyLen)
+	if _, err := io.ReadFull(r, keyB); err != nil {
+		return types.Entry{}, 0, corruptErr(err)
+	}
+	valB, err := h.readValue(r, codec)
+	if err != nil {
+		return types.Entry{}, 0, err
+	}
+	return h.entry(string(keyB), valB), h.size, nil
+}
+
+// recordHeader 是 record 里 key 之前的部分。
+type recordHeader struct {
+	keyLen, valLen uint32
+	flags          byte
+	expiresAt      int64
+	seq            uint64
+	codecID        byte   // 没有压缩时为 0
+	size           uint64 // 整条 record 占用的字节数
+}
+
+// readRecordHeader 读取一条 record 的 header，之后 r 停在 key 的开头。
+// 错误的含义与 readRecord 相同。只用 Peek / Discard，不分配内存。
+func readRecordHeader(r *bufio.Reader, limit uint64) (recordHeader, error) {
+	var h recordHeader
+	b, err := r.Peek(recordHeaderSize)
+	if len(b) < 4 && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+		return h, io.EOF
+	}
+	if err != nil {
+		return h, corruptErr(err)
+	}
+	h.keyLen = binary.LittleEndian.Uint32(b[0:4])
+	h.valLen = binary.LittleEndian.Uint32(b[4:8])
+	h.flags = b[8]
+	if h.keyLen == 0 || uint64(h.keyLen)+uint64(h.valLen) > limit {
+		return h, ErrCorruptSST
+	}
+	if h.flags&^knownFlags != 0 {
+		return h, ErrCorruptSST
+	}
+	_, _ = r.Discard(recordHeaderSize)
+	h.size = uint64(recordHeaderSize) + uint64(h.keyLen) + uint64(h.valLen)
+
+	if h.flags&flagExpiry != 0 {
+		b, err := r.Peek(8)
+		if err != nil {
+			return h, corruptErr(err)
+		}
+		h.expiresAt = int64(binary.LittleEndian.Uint64(b))
+		_, _ = r.Discard(8)
+		h.size += 8
+	}
+	if h.flags&flagSeq != 0 {
+		b, err := r.Peek(8)
+		if err != nil {
+			return h, corruptErr(err)
+		}
+		h.seq = binary.LittleEndian.Uint64(b)
+		_, _ = r.Discard(8)
+		h.size += 8
+	}
+	if h.flags&flagCompressed != 0 {
+		if h.codecID, err = r.ReadByte(); err != nil {
+			return h, corruptErr(err)
+		}
+		h.size++
+	}
+	return h, nil
+}
+
+// codec 返回解压 value 用的算法，value 没有压缩时返回 nil。
+func (h recordHeader) codec(dict func() ([]byte, error)) (Codec, error) {
+	if h.flags&flagCompressed == 0 {
+		return nil, nil
+	}
+	if h.codecID == codecIDFlateDict {
+		d, err := dict()
+		if err != nil || len(d) == 0 {
+			return nil, ErrCorruptSST
+		}
+		return flateDictCodec{d}, nil
+	}
+	return codecByID(h.codecID)
+}
+
+// readValue 读取 key 之后的 value（需要时解压），返回的切片是新分配的。
+func (h recordHeader) readValue(r *bufio.Reader, codec Codec) ([]byte, error) {
+	if h.valLen == 0 {
+		if h.flags&flagEmptyValue != 0 {
+			return []byte{}, nil
+		}
+		return nil, nil
+	}
+	valB := make([]byte, h.valLen)
+	if _, err := io.ReadFull(r, valB); err != nil {
+		return nil, corruptErr(err)
+	}
+	if codec != nil {
+		var err error
+		if valB, err = codec.Decompress(nil, valB); err != nil {
+			return nil, ErrCorruptSST
+		}
+	}
+	return valB, nil
+}
+
+// skipValue 跳过 key 之后的 value。
+func (h recordHeader) skipValue(r *bufio.Reader) error {
+	if _, err := r.Discard(int(h.valLen)); err != nil {
+		return corruptErr(err)
+	}
+	return nil
+}
+
+func (h recordHeader) entry(key string, value []byte) types.Entry {
+	return types.Entry{
+		Key:       key,
+		Value:     value,
+		Tombstone: h.flags&flagTombstone != 0,
+		ExpiresAt: h.expiresAt,
+		Seq:       h.seq,
+		ValueRef:  h.flags&flagValueRef != 0,
+	}
+}
+
+// corruptErr 把读数据区时遇到的错误统一成 ErrCorruptSST，已经包装了 ErrCorruptSST 的错误
+// （例如块校验和不匹配）原样返回，保留其中的细节。
+func corruptErr(err error) error {
+	if errors.Is(err, ErrCorruptSST) {
+		return err
+	}
+	return ErrCorruptSST
+}
+
+// recordSize 返回 e 不压缩时编码成 record 占用的字节数。
+func recordSize(e types.Entry) uint64 {
+	n := uint64(recordHeaderSize + len(e.Key) + len(e.Value))
+	if e.ExpiresAt != 0 {
+		n += 8
+	}
+	if e.Seq != 0 {
+		n += 8
+	}
+	return n
+}
+
+// Verify 检查表的 header、footer、索引和 bloom 是否都能正确加载。
+// 不会逐条校验数据区（那是 ScanData 的工作）；opts.VerifyChecksums 为 true 时另外校验所有数据块的校验和。
+func Verify(path string) error {
+	return VerifyWithOptions(path, ReadOptions{})
+}
+
+// VerifyWithOptions 与 Verify 相同，但按 opts.Comparer 检查索引顺序。
+func VerifyWithOptions(path string, opts ReadOptions) error {
+	f, err := openTable(path, opts)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var hdr [headerSize]byte
+	if _, err := io.ReadFull(f, hdr[:]); err != nil {
+		return ErrCorruptSST
+	}
+	if binary.LittleEndian.Uint32(hdr[0:4]) != magic {
+		return ErrCorruptSST
+	}
+
+	fileSize := f.Size()
+
+	ft, err := readFooter(f, fileSize)
+	if err != nil {
+		return err
+	}
+	idx, dataEnd, err := loadIndex(f, fileSize, opts.comparer())
+	if err != nil {
+		return err
+	}
+	if opts.VerifyChecksums {
+		if err := verifyBlocks(f, fileSize, idx, dataEnd); err != nil {
+			return err
+		}
+	}
+
+	// 表用到的压缩算法必须已经注册，否则读到压缩过的记录时才会失败
+	props, err := readProperties(f, fileSize)
+	if err != nil {
+		return err
+	}
+	if props.Compression != "" {
+		if _, _, err := codecByName(props.Compression); err != nil {
+			return err
+		}
+	}
+
+	br := io.NewSectionReader(f, int64(ft.bloomStart), int64(ft.start-ft.bloomStart))
+	bloomBytes, err := io.ReadAll(br)
+	if err != nil {
+		return err
+	}
+	if _, err := parseFilter(bloomBytes, props); err != nil {
+		return err
+	}
+
+	return nil
+}
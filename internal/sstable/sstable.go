@@ -4,10 +4,15 @@ import (
 	"bufio"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
+	"sync/atomic"
 
+	"monolithdb/internal/encrypt"
 	"monolithdb/internal/types"
+	"monolithdb/internal/vfs"
 )
 
 var ErrCorruptSST = errors.New("sstable: corrupt")
@@ -16,18 +21,32 @@ const (
 	magic uint32 = 0x46534442 // 'FSDB' = ForgeDB（仅用于识别文件）
 )
 
+// record flags（每条 record 的第 9 个字节）
+const (
+	flagTombstone  byte = 1 << 0 // 删除标记
+	flagExpiry     byte = 1 << 1 // flags 之后紧跟 expiresAt(int64)
+	flagEmptyValue byte = 1 << 2 // valLen=0 且 value 是空切片而不是 nil
+	flagSeq        byte = 1 << 3 // expiresAt（如果有）之后紧跟 seq(uint64)
+	flagCompressed byte = 1 << 4 // seq（如果有）之后紧跟 codec id(1B)，val 是压缩后的数据
+	flagValueRef   byte = 1 << 5 // val 是编码后的 ValueRef，value 本身在值日志里
+
+	knownFlags = flagTombstone | flagExpiry | flagEmptyValue | flagSeq | flagCompressed | flagValueRef
+)
+
 type countWriter struct {
-	w *bufio.Writer
-	n uint64
+	w   *bufio.Writer
+	n   uint64
+	crc uint32 // 当前数据块的 crc32c
 }
 
-func newCountWriter(f *os.File) *countWriter {
-	return &countWriter{w: bufio.NewWriterSize(f, 64*1024)}
+func newCountWriter(w io.Writer) *countWriter {
+	return &countWriter{w: bufio.NewWriterSize(w, 64*1024)}
 }
 
 func (cw *countWriter) Write(p []byte) (int, error) {
 	n, err := cw.w.Write(p)
 	cw.n += uint64(n)
+	cw.crc = crc32.Update(cw.crc, castagnoli, p[:n])
 	return n, err
 }
 
@@ -36,20 +55,115 @@ func (cw *countWriter) WriteByte(b byte) error {
 		return err
 	}
 	cw.n++
+	cw.crc = crc32.Update(cw.crc, castagnoli, []byte{b})
 	return nil
 }
 
 func (cw *countWriter) Flush() error { return cw.w.Flush() }
 
-// WriteTable 将有序 entries 写入 SSTable 文件。
+// RateLimiter 限制写表的速度：每次把数据写进文件之前调用 WaitN(n)，
+// 由实现决定是否需要等待。
+type RateLimiter interface {
+	WaitN(n int)
+}
+
+// limitedWriter 在每次写文件之前向 RateLimiter 申请额度（bufio 之下，按实际落盘的块申请）。
+type limitedWriter struct {
+	w       io.Writer
+	limiter RateLimiter
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	lw.limiter.WaitN(len(p))
+	return lw.w.Write(p)
+}
+
+// WriterOptions 控制 WriteTableWithOptions 的行为。零值即默认配置。
+type WriterOptions struct {
+	// Properties 会写入表的 properties 区；EngineVersion / Host / CreatedAt 为空时自动填充。
+	Properties Properties
+
+	// NoSync 为 true 时写完不 fsync。默认会 fsync，保证 WriteTable 返回后数据已落盘；
+	// 只有测试或可以接受掉电丢表的场景才应该关闭。
+	NoSync bool
+
+	// Comparer 是 entries 的排序方式，nil 表示 types.BytewiseComparer。
+	// 索引项用 Comparer.Separator 缩短。
+	Comparer types.Comparer
+
+	// BlockSize 是两个稀疏索引项之间数据的目标字节数（一个“块”），点查最多扫描一个块。
+	// 0 表示每 indexStride 条记录一个索引项；AdaptiveBlockSize 表示根据这批记录的大小分布自动选择。
+	BlockSize int
+
+	// RateLimiter 不为 nil 时限制写文件的速度，nil 表示不限速。
+	RateLimiter RateLimiter
+
+	// Compression 是压缩 value 使用的算法名称（见 RegisterCodec），空表示不压缩。
+	// 压缩后没有变小的 value 按原样写入。
+	Compression string
+
+	// CompressionDict 不为空时用这个字典（flate 预置字典，见 TrainDictionary）压缩 value，
+	// 字典随表一起写入 properties。此时 Compression 必须为空或 "flate"。
+	CompressionDict []byte
+
+	// Encryption 不为 nil 时用它的活跃密钥加密整个文件（见 encrypt 包），读取时需要 ReadOptions.Keys。
+	// 先压缩再加密；记录偏移、索引等都是明文里的位置。
+	Encryption encrypt.KeyProvider
+
+	// BloomBitsPerKey 大于 0 时 bloom filter 按每个 key 这么多位分配；BloomFalsePositiveRate 在 (0, 1) 之间时
+	// 按目标误判率换算每个 key 的位数，两者都设置时以误判率为准。都为 0 时固定 1<<20 位，和之前写出的表一样。
+	// 位数和哈希次数写在表的 bloom 区里，读表时按表里记录的参数查询，不依赖写表时的配置。
+	BloomBitsPerKey        int
+	BloomFalsePositiveRate float64
+
+	// BloomHashes 是每个 key 的哈希次数（最大 MaxBloomHashes），0 表示按每个 key 的位数取最优值 bits*ln2，
+	// 固定大小时为 7。
+	BloomHashes int
+
+	// FilterPolicy 是过滤器名称（见 RegisterFilterPolicy），空表示内置的 bloom filter。
+	// 设置时忽略上面的 Bloom 参数；名称写入 properties，读表时按表里的名称选择过滤器。
+	FilterPolicy string
+
+	// FS 是写表使用的文件系统，nil 表示操作系统的文件系统（vfs.Default）。
+	FS vfs.FS
+}
+
+// WriteTable 将有序 entries 写入 SSTable 文件（使用默认 WriterOptions）。
 func WriteTable(path string, entries []types.Entry) error {
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
+	return WriteTableWithOptions(path, entries, WriterOptions{})
+}
+
+// WriteTableWithOptions 将有序 entries 按 opts 写入 SSTable 文件。
+func WriteTableWithOptions(path string, entries []types.Entry, opts WriterOptions) error {
+	if err := ValidateBloomOptions(opts); err != nil {
+		return err
+	}
+	var policy FilterPolicy
+	if opts.FilterPolicy != "" {
+		var ok bool
+		if policy, ok = LookupFilterPolicy(opts.FilterPolicy); !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownFilterPolicy, opts.FilterPolicy)
+		}
+	}
+	f, err := vfs.OrDefault(opts.FS).OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	w := newCountWriter(f)
+	// 写入链：countWriter(bufio) -> 加密 -> 限速 -> 文件，限速按实际落盘的字节计算
+	var out io.Writer = f
+	if opts.RateLimiter != nil {
+		out = &limitedWriter{w: f, limiter: opts.RateLimiter}
+	}
+	var enc *encrypt.Writer
+	if opts.Encryption != nil {
+		if enc, err = encrypt.NewWriter(out, opts.Encryption); err != nil {
+			return err
+		}
+		out = enc
+	}
+	w := newCountWriter(out)
 
 	// 1) 写 header：magic + count
 	if err := binary.Write(w, binary.LittleEndian, magic); err != nil {
@@ -59,21 +173,108 @@ func WriteTable(path string, entries []types.Entry) error {
 		return err
 	}
 
-	bf := newBloom(1<<20, 7)
+	// 内置 bloom 边写边加 key；FilterPolicy 需要所有 key 一起生成
+	var bf *bloom
+	var filterKeys []string
+	if policy == nil {
+		bf = newBloom(bloomParams(len(entries), opts))
+	} else {
+		filterKeys = make([]string, 0, len(entries))
+	}
+
+	cmp := opts.Comparer
+	if cmp == nil {
+		cmp = types.BytewiseComparer
+	}
+	blockSize := opts.BlockSize
+	if blockSize == AdaptiveBlockSize {
+		blockSize = adaptiveBlockSize(entries)
+	}
+	props := opts.Properties
+	if blockSize > 0 {
+		props.BlockSize = blockSize
+	}
+	props.ValueLogBytes = nil
+	props.NumEntries, props.NumTombstones = uint64(len(entries)), 0
+	for _, e := range entries {
+		if len(e.Key) > MaxKeySize {
+			return fmt.Errorf("sstable: key of %d bytes exceeds the %d byte limit", len(e.Key), MaxKeySize)
+		}
+		props.MaxSeq = max(props.MaxSeq, e.Seq)
+		if e.Tombstone {
+			props.NumTombstones++
+		}
+		if e.ValueRef {
+			ref, ok := DecodeValueRef(e.Value)
+			if !ok || e.Tombstone {
+				return fmt.Errorf("sstable: invalid value log reference for key %q", e.Key)
+			}
+			if props.ValueLogBytes == nil {
+				props.ValueLogBytes = make(map[uint64]uint64)
+			}
+			props.ValueLogBytes[ref.File] += uint64(ref.Size)
+		}
+	}
+	var codec Codec
+	var codecID byte
+	switch {
+	case len(opts.CompressionDict) > 0:
+		if opts.Compression != "" && opts.Compression != "flate" {
+			return fmt.Errorf("sstable: compression dictionary requires flate, not %q", opts.Compression)
+		}
+		if len(opts.CompressionDict) > MaxDictSize {
+			return fmt.Errorf("sstable: compression dictionary larger than %d bytes", MaxDictSize)
+		}
+		codecID, codec = codecIDFlateDict, flateDictCodec{opts.CompressionDict}
+		props.Compression, props.CompressionDict = "flate", opts.CompressionDict
+	case opts.Compression != "":
+		if codecID, codec, err = codecByName(opts.Compression); err != nil {
+			return err
+		}
+		props.Compression = opts.Compression
+	}
+	props.FilterPolicy = opts.FilterPolicy
+	var compressed []byte
 
 	// 2) 写 records 和索引
 	var idx []indexEntry
+	var blockStart uint64
 
 	for i, e := range entries {
 		recOff := w.n
 
-		// 写索引
-		if i%indexStride == 0 {
-			idx = append(idx, indexEntry{key: e.Key, offset: recOff})
+		// 写索引：按条数或按字节数切块，第一条记录总是有索引项
+		var newBlock bool
+		if blockSize > 0 {
+			newBlock = i == 0 || recOff-blockStart >= uint64(blockSize)
+		} else {
+			newBlock = i%indexStride == 0
+		}
+		if newBlock {
+			// 上一个块结束：记下它的校验和，从这里开始计算新块的
+			if i > 0 {
+				props.BlockChecksums = append(props.BlockChecksums, w.crc)
+			}
+			w.crc = 0
+
+			// 索引项只需要满足 上一条 key < 索引 key <= 本条 key，查找结果就不变
+			ik := e.Key
+			if i > 0 {
+				ik = cmp.Separator(entries[i-1].Key, e.Key)
+			}
+			idx = append(idx, indexEntry{key: ik, offset: recOff})
+			blockStart = recOff
 		}
 
 		keyB := []byte(e.Key)
 		valB := e.Value
+		var isCompressed bool
+		if codec != nil && len(valB) > 0 && !e.ValueRef {
+			compressed = codec.Compress(compressed[:0], valB)
+			if len(compressed) < len(valB) {
+				valB, isCompressed = compressed, true
+			}
+		}
 
 		if err := binary.Write(w, binary.LittleEndian, uint32(len(keyB))); err != nil {
 			return err
@@ -82,13 +283,43 @@ func WriteTable(path string, entries []types.Entry) error {
 			return err
 		}
 
-		var tomb byte = 0
+		var flags byte = 0
 		if e.Tombstone {
-			tomb = 1
+			flags |= flagTombstone
+		}
+		if e.ExpiresAt != 0 {
+			flags |= flagExpiry
+		}
+		if valB != nil && len(valB) == 0 {
+			flags |= flagEmptyValue
+		}
+		if e.Seq != 0 {
+			flags |= flagSeq
 		}
-		if err := w.WriteByte(tomb); err != nil {
+		if isCompressed {
+			flags |= flagCompressed
+		}
+		if e.ValueRef {
+			flags |= flagValueRef
+		}
+		if err := w.WriteByte(flags); err != nil {
 			return err
 		}
+		if e.ExpiresAt != 0 {
+			if err := binary.Write(w, binary.LittleEndian, e.ExpiresAt); err != nil {
+				return err
+			}
+		}
+		if e.Seq != 0 {
+			if err := binary.Write(w, binary.LittleEndian, e.Seq); err != nil {
+				return err
+			}
+		}
+		if isCompressed {
+			if err := w.WriteByte(codecID); err != nil {
+				return err
+			}
+		}
 
 		if _, err := w.Write(keyB); err != nil {
 			return err
@@ -100,7 +331,21 @@ func WriteTable(path string, entries []types.Entry) error {
 		}
 
 		// 写入 bloom
-		bf.add(e.Key)
+		if bf != nil {
+			bf.add(e.Key)
+		} else {
+			filterKeys = append(filterKeys, e.Key)
+		}
+	}
+
+	if len(entries) > 0 {
+		props.BlockChecksums = append(props.BlockChecksums, w.crc)
+	}
+
+	// 写 properties
+	propsStartOffset := w.n
+	if _, err := w.Write(props.withDefaults().marshal()); err != nil {
+		return err
 	}
 
 	// 写索引
@@ -127,138 +372,99 @@ func WriteTable(path string, entries []types.Entry) error {
 
 	// 写 bloomStartOffset
 	bloomStartOffset := w.n
-	bloomBytes := bf.marshal()
+	var bloomBytes []byte
+	if bf != nil {
+		bloomBytes = bf.marshal()
+	} else {
+		bloomBytes = marshalPolicyFilter(policy.CreateFilter(filterKeys))
+	}
 	if _, err := w.Write(bloomBytes); err != nil {
 		return err
 	}
 
 	// footer
-	if err := binary.Write(w, binary.LittleEndian, indexStartOffset); err != nil {
+	ft := footer{
+		indexStart: indexStartOffset,
+		bloomStart: bloomStartOffset,
+		propsStart: propsStartOffset,
+		version:    TableFormatVersion,
+	}
+	if _, err := w.Write(ft.marshal()); err != nil {
 		return err
 	}
-	if err := binary.Write(w, binary.LittleEndian, bloomStartOffset); err != nil {
+
+	if err := w.Flush(); err != nil {
 		return err
 	}
+	if enc != nil {
+		if err := enc.Close(); err != nil {
+			return err
+		}
+	}
 
-	return w.Flush()
+	// fsync：rename 之前数据必须已经持久化，否则崩溃后可能留下空表 / 半张表
+	if !opts.NoSync {
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	return f.Close()
 }
 
 // Get 从 SSTable 文件中查找 key。
 func Get(path string, key string) ([]byte, GetResult, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, NotFound, err
-	}
-	defer f.Close()
+	e, res, err := GetEntry(path, key)
+	return e.Value, res, err
+}
 
-	r := bufio.NewReaderSize(f, 64*1024)
+// ReadOptions 控制单次点查的行为。零值即默认配置。
+type ReadOptions struct {
+	// IgnoreBloom 为 true 时不读取 bloom filter，总是通过索引扫描数据区。
+	// 用于排查 bloom 损坏导致的假阴性，或者 repair 之后 bloom 已知过期的情况。
+	IgnoreBloom bool
 
-	// 1) 读 header
-	var m uint32
-	if err := binary.Read(r, binary.LittleEndian, &m); err != nil {
-		return nil, NotFound, err
-	}
-	if m != magic {
-		return nil, NotFound, ErrCorruptSST
-	}
+	// Comparer 必须与写表时的一致，nil 表示 types.BytewiseComparer。
+	Comparer types.Comparer
 
-	var count uint32
-	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
-		return nil, NotFound, ErrCorruptSST
-	}
+	// Keys 用于解密加密的表（见 WriterOptions.Encryption），读明文表时不需要。
+	Keys encrypt.KeyProvider
 
-	// 2) 读取 stat + footer
-	st, err := f.Stat()
-	if err != nil {
-		return nil, NotFound, err
-	}
-	fileSize := st.Size()
+	// VerifyChecksums 为 true 时，读到的每个数据块先校验 crc32c（见 Properties.BlockChecksums），
+	// 不匹配时返回 ErrCorruptSST。没有记录校验和的旧表不校验。
+	VerifyChecksums bool
 
-	indexStartOffset, bloomStartOffset, err := loadFooter(f, fileSize)
-	if err != nil {
-		return nil, NotFound, err
-	}
+	// BytesRead 非 nil 时，每次从表文件读取的字节数（包括 header、索引、过滤器，加密表按密文计算）
+	// 都累加到它上面。对 OpenReader 来说在打开时指定，之后对这个 Reader 的所有读取都会计入。
+	BytesRead *atomic.Uint64
 
-	// 3) bloom：读取 [bloomStartOffset, footerStart)
-	footerStart := uint64(fileSize) - uint64(footerSize)
-	br := io.NewSectionReader(f, int64(bloomStartOffset), int64(footerStart-bloomStartOffset))
+	// KeysOnly 为 true 时 Iterator 跳过每条记录的 value：不分配、不解压，Value 返回 nil
+	// （Entry 里的 Tombstone、ExpiresAt、ValueRef 等元信息不受影响）。用于只需要枚举 key 的扫描。
+	KeysOnly bool
 
-	bloomBytes, err := io.ReadAll(br)
-	if err != nil {
-		return nil, NotFound, err
-	}
+	// FS 是读表使用的文件系统，nil 表示操作系统的文件系统（vfs.Default）。
+	FS vfs.FS
+}
 
-	bf, ok := unmarshalBloom(bloomBytes)
-	if !ok || bf.m == 0 || bf.k == 0 {
-		return nil, NotFound, ErrCorruptSST
+func (o ReadOptions) comparer() types.Comparer {
+	if o.Comparer == nil {
+		return types.BytewiseComparer
 	}
+	return o.Comparer
+}
 
-	// Bloom 明确“不存在” => 快速返回
-	if !bf.mayContain(key) {
-		return nil, NotFound, nil
-	}
+// GetEntry 与 Get 相同，但返回完整的 Entry（包含过期时间等元信息）。
+func GetEntry(path string, key string) (types.Entry, GetResult, error) {
+	return GetEntryWithOptions(path, key, ReadOptions{})
+}
 
-	// 4) 可能存在：加载索引并选择扫描区间
-	entries, indexStartOffset2, err := loadIndex(f, fileSize)
+// GetEntryWithOptions 按 opts 查找 key，返回完整的 Entry。
+// 结果为 Deleted 时返回的是 tombstone 本身（可以读取它的 Seq）。
+// 每次调用都重新打开文件、读取 bloom 和索引；反复查同一张表时使用 OpenReader。
+func GetEntryWithOptions(path string, key string, opts ReadOptions) (types.Entry, GetResult, error) {
+	r, err := OpenReader(path, opts)
 	if err != nil {
-		return nil, NotFound, err
-	}
-	// 防御：确保 loadIndex 读到的 offset 与 footer 一致
-	if indexStartOffset2 != indexStartOffset {
-		return nil, NotFound, ErrCorruptSST
-	}
-
-	start, end := pickScanRange(entries, indexStartOffset, key)
-	if end <= start {
-		return nil, NotFound, ErrCorruptSST
-	}
-
-	section := io.NewSectionReader(f, int64(start), int64(end-start))
-	sr := bufio.NewReaderSize(section, 64*1024)
-
-	// 5) 根据索引查找
-	for {
-		var keyLen uint32
-		var valLen uint32
-
-		if err := binary.Read(sr, binary.LittleEndian, &keyLen); err != nil {
-			// 区间读完就结束：没找到
-			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
-				return nil, NotFound, nil
-			}
-			return nil, NotFound, ErrCorruptSST
-		}
-		if err := binary.Read(sr, binary.LittleEndian, &valLen); err != nil {
-			return nil, NotFound, ErrCorruptSST
-		}
-
-		tomb, err := sr.ReadByte()
-		if err != nil {
-			return nil, NotFound, ErrCorruptSST
-		}
-
-		keyB := make([]byte, keyLen)
-		if _, err := io.ReadFull(sr, keyB); err != nil {
-			return nil, NotFound, ErrCorruptSST
-		}
-
-		var valB []byte
-		if valLen > 0 {
-			valB = make([]byte, valLen)
-			if _, err := io.ReadFull(sr, valB); err != nil {
-				return nil, NotFound, ErrCorruptSST
-			}
-		}
-
-		k := string(keyB)
-		if k == key {
-			if tomb == 1 {
-				return nil, Deleted, nil
-			}
-			return valB, Found, nil
-		}
-		if k > key {
-			return nil, NotFound, nil
-		}
+		return types.Entry{}, NotFound, err
 	}
+	defer r.Close()
+	return r.GetEntryWithOptions(key, opts)
 }
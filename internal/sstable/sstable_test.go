@@ -2,6 +2,9 @@ package sstable
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -82,3 +85,211 @@ func TestSSTableMagicMismatch(t *testing.T) {
 		t.Fatalf("expected ErrCorruptSST, got %v", err)
 	}
 }
+
+// rewriteLegacyFooter 把 path 处的表改写成 footer 带版本之前的布局：footerLen 为 footerSizeV1 时保留
+// properties 区（formatVersion 2~7），为 footerSizeV0 时连 properties 区一起去掉（formatVersion 1）。
+func rewriteLegacyFooter(t *testing.T, path string, footerLen int) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft, err := readFooter(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := append([]byte(nil), data[:ft.propsStart]...)
+	propsStart := uint64(len(out))
+	if footerLen == footerSizeV1 {
+		out = append(out, data[ft.propsStart:ft.indexStart]...)
+	}
+	indexStart := uint64(len(out))
+	out = append(out, data[ft.indexStart:ft.bloomStart]...)
+	bloomStart := uint64(len(out))
+	out = append(out, data[ft.bloomStart:ft.start]...)
+	out = binary.LittleEndian.AppendUint64(out, indexStart)
+	out = binary.LittleEndian.AppendUint64(out, bloomStart)
+	if footerLen == footerSizeV1 {
+		out = binary.LittleEndian.AppendUint64(out, propsStart)
+	}
+	if err := os.WriteFile(path, out, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkLegacyTable 检查改写成旧布局的表依然可以点查、遍历、校验。
+func checkLegacyTable(t *testing.T, path string, n int) *TableInfo {
+	t.Helper()
+	if v, res, err := Get(path, "k0007"); err != nil || res != Found || string(v) != "7" {
+		t.Fatalf("Get(k0007) = %q, %v, %v", v, res, err)
+	}
+	if _, res, err := Get(path, "k0003"); err != nil || res != Deleted {
+		t.Fatalf("Get(k0003) = %v, %v", res, err)
+	}
+	if _, res, err := Get(path, "zzz"); err != nil || res != NotFound {
+		t.Fatalf("Get(zzz) = %v, %v", res, err)
+	}
+
+	r, err := OpenReader(path, ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	it := NewIterator(r)
+	count := 0
+	for ok := it.SeekGE(""); ok; ok = it.Next() {
+		count++
+	}
+	if it.Err() != nil || count != n {
+		t.Fatalf("iterated %d of %d entries, err %v", count, n, it.Err())
+	}
+
+	if err := VerifyWithOptions(path, ReadOptions{VerifyChecksums: true}); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	ti, err := Describe(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ti.FormatVersion != 0 {
+		t.Fatalf("format version = %d, want 0", ti.FormatVersion)
+	}
+	return ti
+}
+
+func legacyEntries(n int) []types.Entry {
+	entries := make([]types.Entry, n)
+	for i := range entries {
+		entries[i] = types.Entry{Key: fmt.Sprintf("k%04d", i), Value: []byte(fmt.Sprint(i))}
+	}
+	entries[3] = types.Entry{Key: entries[3].Key, Tombstone: true}
+	return entries
+}
+
+func TestReadFooterWithoutProperties(t *testing.T) {
+	// formatVersion 1 的表：16 字节 footer，没有 properties 区
+	const n = 300
+	path := filepath.Join(t.TempDir(), "000001.sst")
+	if err := WriteTable(path, legacyEntries(n)); err != nil {
+		t.Fatal(err)
+	}
+	rewriteLegacyFooter(t, path, footerSizeV0)
+
+	ti := checkLegacyTable(t, path, n)
+	if ti.PropsStart != ti.IndexStart || ti.Properties.EngineVersion != "" {
+		t.Fatalf("legacy table has properties: %+v", ti.Properties)
+	}
+}
+
+func TestReadPreviousTableVersion(t *testing.T) {
+	// footer 带版本之前（formatVersion 2~7）的表：24 字节 footer，有 properties 区
+	const n = 300
+	path := filepath.Join(t.TempDir(), "000001.sst")
+	if err := WriteTableWithOptions(path, legacyEntries(n), WriterOptions{Properties: Properties{CreationReason: ReasonFlush}}); err != nil {
+		t.Fatal(err)
+	}
+	rewriteLegacyFooter(t, path, footerSizeV1)
+
+	ti := checkLegacyTable(t, path, n)
+	if ti.Properties.CreationReason != ReasonFlush || len(ti.Properties.BlockChecksums) == 0 {
+		t.Fatalf("properties = %+v", ti.Properties)
+	}
+}
+
+func TestFooterParsersCoverVersions(t *testing.T) {
+	for v := uint32(1); v <= TableFormatVersion; v++ {
+		if footerParsers[v] == nil {
+			t.Fatalf("no footer parser for table format version %d", v)
+		}
+	}
+}
+
+func TestFooterVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "000001.sst")
+	if err := WriteTable(path, []types.Entry{{Key: "a", Value: []byte("1")}}); err != nil {
+		t.Fatal(err)
+	}
+	ti, err := Describe(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ti.FormatVersion != TableFormatVersion {
+		t.Fatalf("format version = %d", ti.FormatVersion)
+	}
+
+	// 把 footer 里的版本改成比当前更新的值：应该明确报告不支持，而不是损坏
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], TableFormatVersion+1)
+	if _, err := f.WriteAt(b[:], ti.FileSize-footerSize+24); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	if _, _, err := Get(path, "a"); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("Get: expected ErrUnsupportedVersion, got %v", err)
+	}
+	if err := Verify(path); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("Verify: expected ErrUnsupportedVersion, got %v", err)
+	}
+
+	// footer magic 被破坏时依然是 ErrCorruptSST
+	f, err = os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte{0}, ti.FileSize-1); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+	if err := Verify(path); !errors.Is(err, ErrCorruptSST) {
+		t.Fatalf("Verify: expected ErrCorruptSST, got %v", err)
+	}
+}
+
+func TestVerifyChecksumsDetectsFlippedValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "000001.sst")
+	entries := make([]types.Entry, 500)
+	for i := range entries {
+		entries[i] = types.Entry{Key: fmt.Sprintf("key%05d", i), Value: []byte(fmt.Sprintf("value-%05d", i))}
+	}
+	if err := WriteTableWithOptions(path, entries, WriterOptions{NoSync: true, BlockSize: 512}); err != nil {
+		t.Fatal(err)
+	}
+	props, err := ReadProperties(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(props.BlockChecksums) < 2 {
+		t.Fatalf("block checksums = %d, want several blocks", len(props.BlockChecksums))
+	}
+
+	// 只改 value 里的一个字节：不校验时读得出来（值是错的），校验时报告损坏
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := bytes.Index(b, []byte("value-00250"))
+	b[i+len("value-")] = 'X'
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Verify(path); err != nil {
+		t.Fatalf("Verify without checksums = %v", err)
+	}
+	if err := VerifyWithOptions(path, ReadOptions{VerifyChecksums: true}); !errors.Is(err, ErrCorruptSST) {
+		t.Fatalf("Verify with checksums = %v, want ErrCorruptSST", err)
+	}
+	if _, _, err := GetEntryWithOptions(path, "key00250", ReadOptions{VerifyChecksums: true}); !errors.Is(err, ErrCorruptSST) {
+		t.Fatalf("GetEntry with checksums = %v, want ErrCorruptSST", err)
+	}
+	// 其它块不受影响
+	if e, res, err := GetEntryWithOptions(path, "key00001", ReadOptions{VerifyChecksums: true}); err != nil || res != Found || string(e.Value) != "value-00001" {
+		t.Fatalf("GetEntry(key00001) = %v, %v, %v", e, res, err)
+	}
+}
@@ -0,0 +1,73 @@
+package sstable
+
+import (
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValueRef 是值日志（value log）里一条记录的位置。大 value 不写进 SST，SST 里只保存它的 ValueRef
+// （Entry.ValueRef 为 true，Entry.Value 是 Encode 的结果），compaction 重写 SST 时只搬动这 20 字节。
+// 值日志文件本身由 DB 管理，sstable 只负责编码，以及在 properties 里统计每个文件被引用的字节数。
+type ValueRef struct {
+	File   uint64 // 值日志文件编号
+	Offset uint64 // 记录在文件里的偏移
+	Size   uint32 // 整条记录占用的字节数
+}
+
+const valueRefSize = 20
+
+// Encode 把 r 编码成定长的 20 字节。
+func (r ValueRef) Encode() []byte {
+	b := make([]byte, 0, valueRefSize)
+	b = binary.LittleEndian.AppendUint64(b, r.File)
+	b = binary.LittleEndian.AppendUint64(b, r.Offset)
+	return binary.LittleEndian.AppendUint32(b, r.Size)
+}
+
+// DecodeValueRef 解码 Encode 的结果，长度不对时返回 false。
+func DecodeValueRef(b []byte) (ValueRef, bool) {
+	if len(b) != valueRefSize {
+		return ValueRef{}, false
+	}
+	return ValueRef{
+		File:   binary.LittleEndian.Uint64(b[0:8]),
+		Offset: binary.LittleEndian.Uint64(b[8:16]),
+		Size:   binary.LittleEndian.Uint32(b[16:20]),
+	}, true
+}
+
+// marshalValueLogBytes 按文件编号升序写成 "file:bytes,file:bytes"。
+func marshalValueLogBytes(m map[uint64]uint64) string {
+	files := make([]uint64, 0, len(m))
+	for f := range m {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i] < files[j] })
+	parts := make([]string, len(files))
+	for i, f := range files {
+		parts[i] = strconv.FormatUint(f, 10) + ":" + strconv.FormatUint(m[f], 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+func unmarshalValueLogBytes(v string) (map[uint64]uint64, bool) {
+	m := make(map[uint64]uint64)
+	for _, part := range strings.Split(v, ",") {
+		f, n, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, false
+		}
+		file, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		bytes, err := strconv.ParseUint(n, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		m[file] = bytes
+	}
+	return m, true
+}
@@ -0,0 +1,94 @@
+package timeseries
+
+import (
+	"strings"
+	"time"
+
+	"monolithdb/internal/db"
+)
+
+// Rule 是一条保留规则：metric 以 MetricPrefix 开头、且早于 now-After 的数据点，
+// 降采样到每 Resolution 最多一个点；Resolution 为 0 表示直接删除。
+//
+// 一个数据点同时满足多条规则时，使用 After 最大的那条。
+type Rule struct {
+	MetricPrefix string
+	After        time.Duration
+	Resolution   time.Duration
+}
+
+// CompactionFilter 返回按 rules 处理 name 存储中数据点的 compaction filter 工厂，
+// 用作 db.Options.CompactionFilterFactory。不属于这个存储的 key 原样保留。
+//
+// 降采样的方式是抽样而不是聚合：每个时间桶只保留最早的那个点。
+// 因为 filter 按 key 升序看到数据点，只需要记住上一个保留点所在的桶；
+// 同一批数据重复 compaction 的结果不变。
+//
+// 数据点的年龄按 clock 计算，应当传入与 db.Options.Clock 相同的时钟；nil 表示系统时间。
+func CompactionFilter(name string, rules []Rule, clock db.Clock) func() db.CompactionFilter {
+	now := time.Now
+	if clock != nil {
+		now = clock.Now
+	}
+	return func() db.CompactionFilter {
+		return &retentionFilter{prefix: name + "/", rules: rules, now: now().UnixNano()}
+	}
+}
+
+type retentionFilter struct {
+	prefix string
+	rules  []Rule
+	now    int64
+
+	// 上一个保留的点：同一 metric、同一分辨率下的同一个桶只保留第一个点
+	lastMetric string
+	lastRes    int64
+	lastBucket int64
+}
+
+func (f *retentionFilter) Filter(key string, value []byte) (db.FilterDecision, []byte) {
+	metric, ts, ok := parseKey(f.prefix, key)
+	if !ok {
+		return db.FilterKeep, nil
+	}
+	r, ok := f.match(metric, ts)
+	if !ok {
+		return db.FilterKeep, nil
+	}
+	if r.Resolution <= 0 {
+		return db.FilterDrop, nil
+	}
+
+	res := int64(r.Resolution)
+	bucket := floorDiv(ts, res)
+	if metric == f.lastMetric && res == f.lastRes && bucket == f.lastBucket {
+		return db.FilterDrop, nil
+	}
+	f.lastMetric, f.lastRes, f.lastBucket = metric, res, bucket
+	return db.FilterKeep, nil
+}
+
+// match 返回适用于该数据点的规则（After 最大的那条）。
+func (f *retentionFilter) match(metric string, ts int64) (Rule, bool) {
+	var best Rule
+	found := false
+	age := time.Duration(f.now - ts)
+	for _, r := range f.rules {
+		if !strings.HasPrefix(metric, r.MetricPrefix) || age < r.After {
+			continue
+		}
+		if !found || r.After > best.After {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+// floorDiv 向下取整的除法（负时间戳也落在正确的桶里）。
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,126 @@
+// Package timeseries 在 ForgeDB 上存储按时间排序的 float64 数据点，
+// 支持追加写入、按时间范围查询，以及通过 compaction filter 按保留规则降采样 / 过期旧数据。
+//
+// 数据布局（<name> 是存储名）：
+//
+//	<name>/<metric>\x00<ts>   -> float64（8 字节大端 IEEE 754）
+//
+// ts 是 unix 纳秒时间戳，最高位取反后按 8 字节大端编码，
+// 这样同一个 metric 的数据点在 key 顺序上就是时间顺序（包括 1970 年之前的负时间戳）。
+package timeseries
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"monolithdb/internal/db"
+)
+
+// ErrInvalidMetric 表示 metric 名为空或包含 0 字节（0 字节在 key 中用作分隔符）。
+var ErrInvalidMetric = errors.New("timeseries: invalid metric")
+
+// Point 是一个数据点。
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Store 是一组时间序列。
+type Store struct {
+	d      *db.DB
+	prefix string
+}
+
+// New 返回名为 name 的时间序列存储，所有数据写在 "<name>/" 前缀下。
+func New(d *db.DB, name string) *Store {
+	return &Store{d: d, prefix: name + "/"}
+}
+
+func validMetric(metric string) bool {
+	return metric != "" && strings.IndexByte(metric, 0) < 0
+}
+
+// encodeTS 把时间戳编码成按字节序与时间顺序一致的 8 字节。
+func encodeTS(ts int64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(ts)^(1<<63))
+	return b[:]
+}
+
+func decodeTS(b []byte) int64 {
+	return int64(binary.BigEndian.Uint64(b) ^ (1 << 63))
+}
+
+func (s *Store) seriesPrefix(metric string) string { return s.prefix + metric + "\x00" }
+
+func (s *Store) key(metric string, t time.Time) string {
+	return s.seriesPrefix(metric) + string(encodeTS(t.UnixNano()))
+}
+
+// parseKey 从 key 中解析出 metric 和时间戳；不是本存储的数据点时 ok 为 false。
+func parseKey(prefix, key string) (metric string, ts int64, ok bool) {
+	rest, found := strings.CutPrefix(key, prefix)
+	if !found || len(rest) < 10 || rest[len(rest)-9] != 0 {
+		return "", 0, false
+	}
+	metric = rest[:len(rest)-9]
+	if !validMetric(metric) {
+		return "", 0, false
+	}
+	return metric, decodeTS([]byte(rest[len(rest)-8:])), true
+}
+
+func encodeValue(v float64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], math.Float64bits(v))
+	return b[:]
+}
+
+// Append 写入一个数据点；同一 metric、同一时间戳的旧值会被覆盖。
+func (s *Store) Append(metric string, t time.Time, v float64) error {
+	if !validMetric(metric) {
+		return ErrInvalidMetric
+	}
+	return s.d.Put(s.key(metric, t), encodeValue(v))
+}
+
+// AppendBatch 原子地写入同一个 metric 的多个数据点。
+func (s *Store) AppendBatch(metric string, points []Point) error {
+	if !validMetric(metric) {
+		return ErrInvalidMetric
+	}
+	if len(points) == 0 {
+		return nil
+	}
+	var b db.Batch
+	for _, p := range points {
+		b.Put(s.key(metric, p.Time), encodeValue(p.Value))
+	}
+	return s.d.Write(&b)
+}
+
+// Range 返回 metric 在 [from, to) 内的数据点，按时间升序。
+func (s *Store) Range(metric string, from, to time.Time) ([]Point, error) {
+	if !validMetric(metric) {
+		return nil, ErrInvalidMetric
+	}
+	entries, err := s.d.Range(s.key(metric, from), s.key(metric, to))
+	if err != nil {
+		return nil, err
+	}
+	p := s.seriesPrefix(metric)
+	out := make([]Point, 0, len(entries))
+	for _, e := range entries {
+		if len(e.Key) != len(p)+8 || len(e.Value) != 8 {
+			continue
+		}
+		out = append(out, Point{
+			Time:  time.Unix(0, decodeTS([]byte(e.Key[len(p):]))),
+			Value: math.Float64frombits(binary.BigEndian.Uint64(e.Value)),
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,153 @@
+package timeseries
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"monolithdb/internal/db"
+)
+
+func TestAppendAndRange(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	s := New(d, "ts")
+	base := time.Unix(0, 0)
+	// 包含负时间戳：编码后仍然按时间排序
+	for _, sec := range []int64{5, -3, 0, 2, 9} {
+		if err := s.Append("cpu", base.Add(time.Duration(sec)*time.Second), float64(sec)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AppendBatch("cpu2", []Point{{Time: base, Value: 42}}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Range("cpu", base.Add(-5*time.Second), base.Add(9*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vals []float64
+	for _, p := range got {
+		vals = append(vals, p.Value)
+		if p.Time.Unix() != int64(p.Value) {
+			t.Fatalf("point %v has time %v", p.Value, p.Time)
+		}
+	}
+	want := []float64{-3, 0, 2, 5}
+	if len(vals) != len(want) {
+		t.Fatalf("values = %v, want %v", vals, want)
+	}
+	for i := range want {
+		if vals[i] != want[i] {
+			t.Fatalf("values = %v, want %v", vals, want)
+		}
+	}
+
+	if err := s.Append("bad\x00metric", base, 1); err != ErrInvalidMetric {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestRetentionCompaction(t *testing.T) {
+	rules := []Rule{
+		{MetricPrefix: "cpu", After: time.Hour, Resolution: time.Minute},
+		{MetricPrefix: "cpu", After: 24 * time.Hour, Resolution: 0},
+	}
+	clock := db.NewManualClock(time.Unix(1700000040, 0)) // 整分钟
+	d, err := db.OpenWithOptions(filepath.Join(t.TempDir(), "data"), db.Options{
+		DisableFsync:            true,
+		Clock:                   clock,
+		CompactionFilterFactory: CompactionFilter("ts", rules, clock),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	s := New(d, "ts")
+	now := clock.Now()
+	var pts []Point
+	// 最近 10 秒：保留原始精度
+	for i := 0; i < 10; i++ {
+		pts = append(pts, Point{Time: now.Add(-time.Duration(i) * time.Second), Value: 1})
+	}
+	// 2 小时前的 3 分钟，每 10 秒一个点：降采样到每分钟一个
+	old := now.Add(-2 * time.Hour)
+	for i := 0; i < 18; i++ {
+		pts = append(pts, Point{Time: old.Add(time.Duration(i) * 10 * time.Second), Value: float64(i)})
+	}
+	// 2 天前：直接删除
+	pts = append(pts, Point{Time: now.Add(-48 * time.Hour), Value: 1})
+	if err := s.AppendBatch("cpu.user", pts); err != nil {
+		t.Fatal(err)
+	}
+	// 不匹配任何规则的 metric 不受影响
+	if err := s.Append("mem", now.Add(-48*time.Hour), 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put("other", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(metric string, from, to time.Time) []Point {
+		t.Helper()
+		got, err := s.Range(metric, from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	if got := count("cpu.user", now.Add(-time.Minute), now.Add(time.Second)); len(got) != 10 {
+		t.Fatalf("recent points = %d, want 10", len(got))
+	}
+	got := count("cpu.user", old, old.Add(time.Hour))
+	if len(got) != 3 || got[0].Value != 0 || got[1].Value != 6 || got[2].Value != 12 {
+		t.Fatalf("downsampled = %v", got)
+	}
+	if got := count("cpu.user", now.Add(-72*time.Hour), now.Add(-24*time.Hour)); len(got) != 0 {
+		t.Fatalf("expired points = %v", got)
+	}
+	if got := count("mem", now.Add(-72*time.Hour), now); len(got) != 1 {
+		t.Fatalf("mem points = %v", got)
+	}
+	if _, ok, _ := d.Get("other"); !ok {
+		t.Fatal("non-timeseries key dropped")
+	}
+
+	// 再次 compaction 结果不变
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if got := count("cpu.user", old, old.Add(time.Hour)); len(got) != 3 {
+		t.Fatalf("after second compaction = %v", got)
+	}
+
+	// 时钟推进 2 小时：最近 10 秒的点也降采样（now 所在的分钟和前一分钟各一个）
+	clock.Advance(2 * time.Hour)
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if got := count("cpu.user", now.Add(-time.Minute), now.Add(time.Second)); len(got) != 2 {
+		t.Fatalf("recent points after 2h = %v", got)
+	}
+
+	// 再推进 2 天：cpu 的点全部删除
+	clock.Advance(48 * time.Hour)
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if got := count("cpu.user", now.Add(-72*time.Hour), now.Add(time.Second)); len(got) != 0 {
+		t.Fatalf("points after 2 days = %v", got)
+	}
+}
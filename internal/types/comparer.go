@@ -0,0 +1,55 @@
+package types
+
+import "strings"
+
+// Comparer 定义 key 的全序。MemTable、SST 的索引查找以及 compaction 的合并都按它排序。
+//
+// 数据一旦写入，比较器就不能再更换：Name 会记录在 manifest 里，打开时校验。
+type Comparer interface {
+	// Name 是比较器的唯一名称。
+	Name() string
+
+	// Compare 返回 a 与 b 的顺序：a < b 时小于 0，a == b 时为 0，a > b 时大于 0。
+	// 只有完全相同的两个 key 才能返回 0。
+	// 实现不能在返回之后继续持有 a、b：SST 点查传入的 key 可能引用会被复用的缓冲区。
+	Compare(a, b string) int
+
+	// Separator 返回一个满足 a < s <= b 的尽量短的 key（调用时保证 a < b）。
+	// SST 用它代替块的第一个 key 作为索引项，减小索引体积；直接返回 b 总是正确的。
+	Separator(a, b string) string
+
+	// Successor 返回一个满足 s >= a 的尽量短的 key；直接返回 a 总是正确的。
+	Successor(a string) string
+}
+
+// BytewiseComparer 按字节序比较 key，是默认的比较器。
+var BytewiseComparer Comparer = bytewiseComparer{}
+
+type bytewiseComparer struct{}
+
+func (bytewiseComparer) Name() string { return "forgedb.BytewiseComparator" }
+
+func (bytewiseComparer) Compare(a, b string) int { return strings.Compare(a, b) }
+
+// Separator 取 b 到第一个与 a 不同的字节为止的前缀：
+// 这个前缀在该字节上大于 a（或者 a 是它的真前缀），且是 b 的前缀，所以 a < s <= b。
+func (bytewiseComparer) Separator(a, b string) string {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	if n >= len(b) {
+		return b
+	}
+	return b[:n+1]
+}
+
+// Successor 对第一个不是 0xff 的字节加一并截断。
+func (bytewiseComparer) Successor(a string) string {
+	for i := 0; i < len(a); i++ {
+		if a[i] != 0xff {
+			return a[:i] + string([]byte{a[i] + 1})
+		}
+	}
+	return a
+}
@@ -0,0 +1,27 @@
+package types
+
+import "testing"
+
+func TestBytewiseSeparatorAndSuccessor(t *testing.T) {
+	cmp := BytewiseComparer
+	for _, c := range [][3]string{
+		{"apple", "apricot", "apr"},
+		{"abc", "abcdef", "abcd"},
+		{"a", "b", "b"},
+		{"k0031", "k0032", "k0032"},
+	} {
+		s := cmp.Separator(c[0], c[1])
+		if s != c[2] {
+			t.Fatalf("Separator(%q, %q) = %q, want %q", c[0], c[1], s, c[2])
+		}
+		if cmp.Compare(c[0], s) >= 0 || cmp.Compare(s, c[1]) > 0 {
+			t.Fatalf("Separator(%q, %q) = %q out of range", c[0], c[1], s)
+		}
+	}
+
+	for _, c := range [][2]string{{"abc", "b"}, {"\xff\xffx", "\xff\xffy"}, {"\xff", "\xff"}} {
+		if got := cmp.Successor(c[0]); got != c[1] {
+			t.Fatalf("Successor(%q) = %q, want %q", c[0], got, c[1])
+		}
+	}
+}
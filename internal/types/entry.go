@@ -2,7 +2,10 @@ package types
 
 // KV 记录
 type Entry struct {
-	Key string
-	Value []byte
-	Tombstone bool // 删除标记
+	Key       string
+	Value     []byte
+	Tombstone bool   // 删除标记
+	ExpiresAt int64  // 过期时间（unix 纳秒），0 表示永不过期
+	Seq       uint64 // 写入时的提交序号，0 表示未知（没有记录序号的旧表）
+	ValueRef  bool   // Value 不是值本身，而是值日志里的位置（见 sstable.ValueRef），只出现在 SST 里
 }
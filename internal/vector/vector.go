@@ -0,0 +1,200 @@
+// Package vector 在 ForgeDB 里存储定长 float32 向量，并支持在 key 范围内做暴力最近邻搜索。
+//
+// 向量编码为 dim 个 little-endian float32（每个 4 字节），没有额外的头部，
+// 所以同一个 key 范围内的向量必须使用相同的维度。
+package vector
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"monolithdb/internal/db"
+)
+
+// ErrDimension 表示向量维度与 Store 的维度不一致。
+var ErrDimension = errors.New("vector: dimension mismatch")
+
+// Metric 是最近邻搜索使用的距离度量。距离越小越相近。
+type Metric int
+
+const (
+	L2     Metric = iota // 欧氏距离的平方
+	Cosine               // 1 - 余弦相似度；零向量与任何向量的距离为 1
+	Dot                  // 负的点积（点积越大越相近）
+)
+
+func (m Metric) String() string {
+	switch m {
+	case L2:
+		return "l2"
+	case Cosine:
+		return "cosine"
+	case Dot:
+		return "dot"
+	default:
+		return fmt.Sprintf("Metric(%d)", int(m))
+	}
+}
+
+// Encode 把向量编码为 value。
+func Encode(v []float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(x))
+	}
+	return b
+}
+
+// Decode 把 value 解码为向量；长度不是 4 的倍数时返回 ErrDimension。
+func Decode(b []byte) ([]float32, error) {
+	if len(b)%4 != 0 {
+		return nil, fmt.Errorf("%w: %d bytes is not a multiple of 4", ErrDimension, len(b))
+	}
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v, nil
+}
+
+// Distance 按 metric 计算 a 与 b 的距离，a 和 b 的长度必须相同。
+func Distance(metric Metric, a, b []float32) float32 {
+	switch metric {
+	case Cosine:
+		var dot, na, nb float64
+		for i := range a {
+			dot += float64(a[i]) * float64(b[i])
+			na += float64(a[i]) * float64(a[i])
+			nb += float64(b[i]) * float64(b[i])
+		}
+		if na == 0 || nb == 0 {
+			return 1
+		}
+		return float32(1 - dot/math.Sqrt(na*nb))
+	case Dot:
+		var dot float64
+		for i := range a {
+			dot += float64(a[i]) * float64(b[i])
+		}
+		return float32(-dot)
+	default:
+		var sum float64
+		for i := range a {
+			d := float64(a[i]) - float64(b[i])
+			sum += d * d
+		}
+		return float32(sum)
+	}
+}
+
+// Neighbor 是一条搜索结果。
+type Neighbor struct {
+	Key      string
+	Vector   []float32
+	Distance float32
+}
+
+// Store 在 DB 上提供固定维度的向量读写。
+type Store struct {
+	d   *db.DB
+	dim int
+}
+
+// NewStore 返回维度为 dim 的向量存储；dim 必须大于 0。
+func NewStore(d *db.DB, dim int) (*Store, error) {
+	if dim <= 0 {
+		return nil, fmt.Errorf("vector: invalid dimension %d", dim)
+	}
+	return &Store{d: d, dim: dim}, nil
+}
+
+// Dim 返回向量维度。
+func (s *Store) Dim() int { return s.dim }
+
+// Put 写入 key 对应的向量。
+func (s *Store) Put(key string, v []float32) error {
+	if len(v) != s.dim {
+		return fmt.Errorf("%w: got %d, want %d", ErrDimension, len(v), s.dim)
+	}
+	return s.d.Put(key, Encode(v))
+}
+
+// Get 读取 key 对应的向量。
+func (s *Store) Get(key string) ([]float32, bool, error) {
+	b, ok, err := s.d.Get(key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	v, err := s.decode(key, b)
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+func (s *Store) decode(key string, b []byte) ([]float32, error) {
+	if len(b) != 4*s.dim {
+		return nil, fmt.Errorf("%w: key %q has %d bytes, want %d", ErrDimension, key, len(b), 4*s.dim)
+	}
+	return Decode(b)
+}
+
+// Search 在 [start, end) 内暴力搜索与 query 最近的 k 个向量，按距离升序返回。
+// start / end 的含义与 db.Range 相同。范围内任何一个 value 维度不对都会返回 ErrDimension。
+//
+// 复杂度是 O(n·dim)，且会把整个范围读入内存，适合几万条以内的数据。
+func (s *Store) Search(start, end string, query []float32, k int, metric Metric) ([]Neighbor, error) {
+	if len(query) != s.dim {
+		return nil, fmt.Errorf("%w: query has %d, want %d", ErrDimension, len(query), s.dim)
+	}
+	if k <= 0 {
+		return nil, nil
+	}
+
+	entries, err := s.d.Range(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	// 大小为 k 的最大堆：堆顶是当前结果里最远的一个
+	h := &neighborHeap{}
+	for _, e := range entries {
+		v, err := s.decode(e.Key, e.Value)
+		if err != nil {
+			return nil, err
+		}
+		dist := Distance(metric, query, v)
+		if h.Len() < k {
+			heap.Push(h, Neighbor{Key: e.Key, Vector: v, Distance: dist})
+		} else if dist < (*h)[0].Distance {
+			(*h)[0] = Neighbor{Key: e.Key, Vector: v, Distance: dist}
+			heap.Fix(h, 0)
+		}
+	}
+
+	out := []Neighbor(*h)
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Distance != out[j].Distance {
+			return out[i].Distance < out[j].Distance
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out, nil
+}
+
+type neighborHeap []Neighbor
+
+func (h neighborHeap) Len() int           { return len(h) }
+func (h neighborHeap) Less(i, j int) bool { return h[i].Distance > h[j].Distance }
+func (h neighborHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *neighborHeap) Push(x any)        { *h = append(*h, x.(Neighbor)) }
+func (h *neighborHeap) Pop() any {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
@@ -0,0 +1,85 @@
+package vector
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"monolithdb/internal/db"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	in := []float32{0, 1.5, -2.25, 3e10}
+	out, err := Decode(Encode(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(out) != fmt.Sprint(in) {
+		t.Fatalf("round trip: got %v want %v", out, in)
+	}
+	if _, err := Decode([]byte{1, 2, 3}); !errors.Is(err, ErrDimension) {
+		t.Fatalf("expected ErrDimension, got %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+
+	s, err := NewStore(d, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 在 x 轴上放 0..9，query 在 3.2 附近
+	for i := 0; i < 10; i++ {
+		if err := s.Put(fmt.Sprintf("vec/%d", i), []float32{float32(i), 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// 范围外的非向量数据不应影响搜索
+	if err := d.Put("zzz", []byte("not a vector")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Search("vec/", "vec0", []float32{3.2, 0}, 3, L2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, n := range got {
+		keys = append(keys, n.Key)
+	}
+	if fmt.Sprint(keys) != "[vec/3 vec/4 vec/2]" {
+		t.Fatalf("L2 neighbors = %v", keys)
+	}
+
+	// 余弦：方向相同的向量距离为 0
+	if err := s.Put("dir/a", []float32{1, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("dir/b", []float32{1, -1}); err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.Search("dir/", "dir0", []float32{5, 5}, 1, Cosine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Key != "dir/a" || got[0].Distance > 1e-6 {
+		t.Fatalf("cosine neighbor = %+v", got)
+	}
+
+	if _, err := s.Search("", "", []float32{0, 0}, 1, L2); !errors.Is(err, ErrDimension) {
+		t.Fatalf("expected ErrDimension when range contains non-vectors, got %v", err)
+	}
+	if err := s.Put("bad", []float32{1}); !errors.Is(err, ErrDimension) {
+		t.Fatalf("expected ErrDimension on Put, got %v", err)
+	}
+}
@@ -0,0 +1,431 @@
+package vfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MemFS 是完全在内存里的 FS，并发安全。Sync / SyncDir 什么也不做，进程退出之后内容就没有了。
+// 用于测试和不需要持久化的数据库（见 db.OpenInMemory）。
+type MemFS struct {
+	mu    sync.Mutex
+	nodes map[string]*memNode // 清理过的路径 -> 文件或目录，根目录 "/" 和 "." 总是存在
+}
+
+// memNode 是一个文件或目录。硬链接的两个路径指向同一个 memNode。
+type memNode struct {
+	dir bool
+
+	mu      sync.RWMutex
+	data    []byte
+	modTime time.Time
+}
+
+// NewMem 返回一个空的 MemFS。
+func NewMem() *MemFS {
+	now := time.Now()
+	return &MemFS{nodes: map[string]*memNode{
+		"/": {dir: true, modTime: now},
+		".": {dir: true, modTime: now},
+	}}
+}
+
+func memPath(name string) string { return filepath.Clean(name) }
+
+func pathErr(op, name string, err error) error { return &os.PathError{Op: op, Path: name, Err: err} }
+
+// parentOK 报告 p 的父目录是否存在。调用方持有 m.mu。
+func (m *MemFS) parentOK(p string) bool {
+	n, ok := m.nodes[filepath.Dir(p)]
+	return ok && n.dir
+}
+
+func (m *MemFS) Open(name string) (File, error) { return m.OpenFile(name, os.O_RDONLY, 0) }
+
+func (m *MemFS) Create(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (m *MemFS) OpenFile(name string, flag int, _ os.FileMode) (File, error) {
+	p := memPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, ok := m.nodes[p]
+	switch {
+	case ok && n.dir:
+		if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+			return nil, pathErr("open", name, fs.ErrInvalid)
+		}
+	case ok && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, pathErr("open", name, fs.ErrExist)
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, pathErr("open", name, fs.ErrNotExist)
+	case !ok:
+		if !m.parentOK(p) {
+			return nil, pathErr("open", name, fs.ErrNotExist)
+		}
+		n = &memNode{modTime: time.Now()}
+		m.nodes[p] = n
+	}
+	if flag&os.O_TRUNC != 0 && !n.dir {
+		n.mu.Lock()
+		n.data, n.modTime = nil, time.Now()
+		n.mu.Unlock()
+	}
+	return &memFile{
+		name:   name,
+		n:      n,
+		read:   flag&os.O_WRONLY == 0,
+		write:  flag&(os.O_WRONLY|os.O_RDWR) != 0,
+		append: flag&os.O_APPEND != 0,
+	}, nil
+}
+
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	p := memPath(name)
+	m.mu.Lock()
+	n, ok := m.nodes[p]
+	m.mu.Unlock()
+	if !ok {
+		return nil, pathErr("stat", name, fs.ErrNotExist)
+	}
+	return n.info(filepath.Base(p)), nil
+}
+
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	op, np := memPath(oldpath), memPath(newpath)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, ok := m.nodes[op]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	if !m.parentOK(np) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	if op == np {
+		return nil
+	}
+	if dst, ok := m.nodes[np]; ok && dst.dir && (!n.dir || m.hasChildren(np)) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrExist}
+	}
+	delete(m.nodes, op)
+	m.nodes[np] = n
+	if n.dir {
+		prefix := op + string(filepath.Separator)
+		for p, c := range m.nodes {
+			if strings.HasPrefix(p, prefix) {
+				delete(m.nodes, p)
+				m.nodes[filepath.Join(np, p[len(prefix):])] = c
+			}
+		}
+	}
+	return nil
+}
+
+// hasChildren 报告目录 p 下是否还有文件。调用方持有 m.mu。
+func (m *MemFS) hasChildren(p string) bool {
+	for q := range m.nodes {
+		if q != p && filepath.Dir(q) == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemFS) Remove(name string) error {
+	p := memPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, ok := m.nodes[p]
+	if !ok {
+		return pathErr("remove", name, fs.ErrNotExist)
+	}
+	if n.dir && m.hasChildren(p) {
+		return pathErr("remove", name, fs.ErrExist)
+	}
+	delete(m.nodes, p)
+	return nil
+}
+
+func (m *MemFS) RemoveAll(path string) error {
+	p := memPath(path)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.nodes, p)
+	prefix := p + string(filepath.Separator)
+	for q := range m.nodes {
+		if strings.HasPrefix(q, prefix) {
+			delete(m.nodes, q)
+		}
+	}
+	return nil
+}
+
+func (m *MemFS) MkdirAll(path string, _ os.FileMode) error {
+	p := memPath(path)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var missing []string
+	for q := p; ; q = filepath.Dir(q) {
+		n, ok := m.nodes[q]
+		if ok {
+			if !n.dir {
+				return pathErr("mkdir", q, fs.ErrExist)
+			}
+			break
+		}
+		missing = append(missing, q)
+		if filepath.Dir(q) == q {
+			break
+		}
+	}
+	for _, q := range missing {
+		m.nodes[q] = &memNode{dir: true, modTime: time.Now()}
+	}
+	return nil
+}
+
+func (m *MemFS) Truncate(name string, size int64) error {
+	f, err := m.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Truncate(size)
+}
+
+func (m *MemFS) Link(oldname, newname string) error {
+	op, np := memPath(oldname), memPath(newname)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, ok := m.nodes[op]
+	if !ok || !m.parentOK(np) {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrNotExist}
+	}
+	if n.dir {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	if _, ok := m.nodes[np]; ok {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrExist}
+	}
+	m.nodes[np] = n
+	return nil
+}
+
+func (m *MemFS) List(dir string) ([]string, error) {
+	p := memPath(dir)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, ok := m.nodes[p]
+	if !ok {
+		return nil, pathErr("open", dir, fs.ErrNotExist)
+	}
+	if !n.dir {
+		return nil, pathErr("readdirent", dir, fs.ErrInvalid)
+	}
+	var names []string
+	for q := range m.nodes {
+		if q != p && filepath.Dir(q) == p {
+			names = append(names, filepath.Base(q))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *MemFS) SyncDir(dir string) error {
+	if _, err := m.Stat(dir); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (n *memNode) info(name string) os.FileInfo {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return memInfo{name: name, size: int64(len(n.data)), dir: n.dir, modTime: n.modTime}
+}
+
+// memInfo 实现 os.FileInfo。
+type memInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	modTime time.Time
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.dir }
+func (i memInfo) Sys() any           { return nil }
+
+func (i memInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+// memFile 是 MemFS 里打开的文件，自己记录读写位置；同一个文件的多个句柄共享内容。
+type memFile struct {
+	name   string
+	n      *memNode
+	read   bool
+	write  bool
+	append bool
+
+	mu     sync.Mutex // 保护 off；ReadAt 不使用 off，不需要加锁
+	off    int64
+	closed atomic.Bool
+}
+
+func (f *memFile) check(write bool) error {
+	if f.closed.Load() {
+		return pathErr("write", f.name, fs.ErrClosed)
+	}
+	if f.n.dir {
+		return pathErr("read", f.name, fs.ErrInvalid)
+	}
+	if write && !f.write || !write && !f.read {
+		return pathErr("access", f.name, fs.ErrPermission)
+	}
+	return nil
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.readAt(p, f.off)
+	f.off += int64(n)
+	return n, err
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.readAt(p, off)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *memFile) readAt(p []byte, off int64) (int, error) {
+	if err := f.check(false); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, pathErr("read", f.name, fs.ErrInvalid)
+	}
+	f.n.mu.RLock()
+	defer f.n.mu.RUnlock()
+	if off >= int64(len(f.n.data)) {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	return copy(p, f.n.data[off:]), nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check(true); err != nil {
+		return 0, err
+	}
+	f.n.mu.Lock()
+	defer f.n.mu.Unlock()
+	if f.append {
+		f.off = int64(len(f.n.data))
+	}
+	if end := f.off + int64(len(p)); end > int64(len(f.n.data)) {
+		if end > int64(cap(f.n.data)) {
+			grown := make([]byte, end, max(end, 2*int64(cap(f.n.data))))
+			copy(grown, f.n.data)
+			f.n.data = grown
+		} else {
+			f.n.data = f.n.data[:end]
+		}
+	}
+	copy(f.n.data[f.off:], p)
+	f.off += int64(len(p))
+	f.n.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed.Load() {
+		return 0, pathErr("seek", f.name, fs.ErrClosed)
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		f.n.mu.RLock()
+		offset += int64(len(f.n.data))
+		f.n.mu.RUnlock()
+	}
+	if offset < 0 {
+		return 0, pathErr("seek", f.name, fs.ErrInvalid)
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check(true); err != nil {
+		return err
+	}
+	if size < 0 {
+		return pathErr("truncate", f.name, fs.ErrInvalid)
+	}
+	f.n.mu.Lock()
+	defer f.n.mu.Unlock()
+	if size <= int64(len(f.n.data)) {
+		f.n.data = f.n.data[:size]
+	} else {
+		f.n.data = append(f.n.data, make([]byte, size-int64(len(f.n.data)))...)
+	}
+	f.n.modTime = time.Now()
+	return nil
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	if f.closed.Load() {
+		return nil, pathErr("stat", f.name, fs.ErrClosed)
+	}
+	return f.n.info(filepath.Base(f.name)), nil
+}
+
+func (f *memFile) Sync() error {
+	if f.closed.Load() {
+		return pathErr("sync", f.name, fs.ErrClosed)
+	}
+	return nil
+}
+
+func (f *memFile) Close() error {
+	if !f.closed.CompareAndSwap(false, true) {
+		return pathErr("close", f.name, fs.ErrClosed)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Package vfs 是存储引擎使用的文件系统接口。db、wal、sstable 通过它访问文件，而不是直接调用 os，
+// 测试可以换成内存实现（NewMem）、注入故障，以后也可以换成其它后端。
+//
+// 路径的写法与 os 相同（用 path/filepath 拼接），实现负责解释。
+package vfs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// File 是一个打开的文件，方法的语义与 *os.File 相同。
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.Seeker
+	io.Closer
+
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Truncate(size int64) error
+}
+
+// FS 是一个文件系统。所有方法的语义与 os 包里的同名函数相同，错误同样可以用
+// errors.Is(err, os.ErrNotExist) 等判断。
+type FS interface {
+	// Open 以只读方式打开文件。
+	Open(name string) (File, error)
+
+	// Create 创建文件（已存在时清空），以读写方式打开。
+	Create(name string) (File, error)
+
+	// OpenFile 按 flag（os.O_CREATE、os.O_APPEND 等）打开文件。
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+
+	Stat(name string) (os.FileInfo, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	RemoveAll(path string) error
+	MkdirAll(path string, perm os.FileMode) error
+	Truncate(name string, size int64) error
+
+	// Link 创建硬链接：newname 与 oldname 共享同一份内容。不支持时返回错误，调用方改为复制。
+	Link(oldname, newname string) error
+
+	// List 返回目录 dir 下的文件和子目录的名字（不含路径），按名字升序。
+	List(dir string) ([]string, error)
+
+	// SyncDir 把目录 dir 的元数据（新建、重命名、删除的文件）持久化。
+	SyncDir(dir string) error
+}
+
+// Default 是直接使用操作系统文件系统的实现。
+var Default FS = osFS{}
+
+// OrDefault 返回 fs，fs 为 nil 时返回 Default。各个包的 Options 用它实现"nil 表示操作系统文件系统"。
+func OrDefault(fs FS) FS {
+	if fs == nil {
+		return Default
+	}
+	return fs
+}
+
+type osFS struct{}
+
+func (osFS) Open(name string) (File, error) { return os.Open(name) }
+
+func (osFS) Create(name string) (File, error) { return os.Create(name) }
+
+func (osFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (osFS) Stat(name string) (os.FileInfo, error)        { return os.Stat(name) }
+func (osFS) Rename(oldpath, newpath string) error         { return os.Rename(oldpath, newpath) }
+func (osFS) Remove(name string) error                     { return os.Remove(name) }
+func (osFS) RemoveAll(path string) error                  { return os.RemoveAll(path) }
+func (osFS) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
+func (osFS) Truncate(name string, size int64) error       { return os.Truncate(name, size) }
+func (osFS) Link(oldname, newname string) error           { return os.Link(oldname, newname) }
+
+func (osFS) List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+	}
+	return names, nil
+}
+
+func (osFS) SyncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ReadFile 读出整个文件，与 os.ReadFile 相同。
+func ReadFile(fs FS, name string) ([]byte, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// WriteFile 把 data 写入文件（已存在时清空），与 os.WriteFile 相同，不做 Sync。
+func WriteFile(fs FS, name string, data []byte, perm os.FileMode) error {
+	f, err := fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Glob 返回 dir 下名字匹配 pattern（filepath.Match 的语法）的文件的完整路径，按名字升序。
+// dir 不存在时返回空结果。
+func Glob(fs FS, dir, pattern string) ([]string, error) {
+	names, err := fs.List(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, name := range names {
+		ok, err := filepath.Match(pattern, name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
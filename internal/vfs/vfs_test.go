@@ -0,0 +1,174 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testFS 对 fs 做一遍基本操作，操作系统和内存两种实现的行为应该一致。
+func testFS(t *testing.T, fs FS, root string) {
+	dir := filepath.Join(root, "a", "b")
+	if err := fs.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "f")
+	if _, err := fs.Open(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("open missing file: %v", err)
+	}
+	if _, err := fs.Create(filepath.Join(root, "missing", "f")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("create in missing dir: %v", err)
+	}
+
+	f, err := fs.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("hello world")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("O_EXCL on existing file: %v", err)
+	}
+
+	// 追加写
+	f, err = fs.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("!")); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	f, err = fs.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 5)
+	if _, err := f.ReadAt(b, 6); err != nil || string(b) != "world" {
+		t.Fatalf("ReadAt = %q, %v", b, err)
+	}
+	if _, err := f.ReadAt(b, 10); err != io.EOF {
+		t.Fatalf("ReadAt past end: %v", err)
+	}
+	if _, err := f.Seek(6, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if all, err := io.ReadAll(f); err != nil || string(all) != "world!" {
+		t.Fatalf("ReadAll = %q, %v", all, err)
+	}
+	if st, err := f.Stat(); err != nil || st.Size() != 12 {
+		t.Fatalf("Stat = %v, %v", st, err)
+	}
+	if _, err := f.Write([]byte("x")); err == nil {
+		t.Fatal("write to read-only file succeeded")
+	}
+	f.Close()
+
+	if err := fs.Truncate(path, 5); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ReadFile(fs, path); err != nil || string(b) != "hello" {
+		t.Fatalf("after truncate = %q, %v", b, err)
+	}
+
+	// 硬链接共享内容，删除一个名字不影响另一个
+	link := filepath.Join(root, "a", "link")
+	if err := fs.Link(path, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(fs, path, []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ReadFile(fs, link); err != nil || string(b) != "new" {
+		t.Fatalf("link = %q, %v", b, err)
+	}
+
+	if err := fs.Rename(link, filepath.Join(dir, "g.log")); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(fs, filepath.Join(dir, "h.log"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if names, err := fs.List(dir); err != nil || !reflect.DeepEqual(names, []string{"g.log", "h.log"}) {
+		t.Fatalf("List = %v, %v", names, err)
+	}
+	if got, err := Glob(fs, dir, "*.log"); err != nil || !reflect.DeepEqual(got, []string{filepath.Join(dir, "g.log"), filepath.Join(dir, "h.log")}) {
+		t.Fatalf("Glob = %v, %v", got, err)
+	}
+	if got, err := Glob(fs, filepath.Join(root, "missing"), "*"); err != nil || got != nil {
+		t.Fatalf("Glob missing dir = %v, %v", got, err)
+	}
+	if err := fs.SyncDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// 目录改名带上下面的文件
+	if err := fs.Rename(filepath.Join(root, "a"), filepath.Join(root, "c")); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := fs.Stat(filepath.Join(root, "c", "b", "g.log")); err != nil || st.Size() != 3 || st.IsDir() {
+		t.Fatalf("Stat after dir rename = %v, %v", st, err)
+	}
+	if err := fs.Remove(filepath.Join(root, "c")); err == nil {
+		t.Fatal("removed non-empty dir")
+	}
+	if err := fs.RemoveAll(filepath.Join(root, "c")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(filepath.Join(root, "c", "b")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Stat after RemoveAll: %v", err)
+	}
+}
+
+func TestOSFS(t *testing.T) {
+	testFS(t, Default, t.TempDir())
+}
+
+func TestMemFS(t *testing.T) {
+	testFS(t, NewMem(), "/data")
+	testFS(t, NewMem(), "rel")
+}
+
+func TestMemFSHandlesShareContent(t *testing.T) {
+	fs := NewMem()
+	w, err := fs.Create("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := fs.Open("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 3)
+	if _, err := r.ReadAt(b, 0); err != nil || string(b) != "abc" {
+		t.Fatalf("ReadAt = %q, %v", b, err)
+	}
+	// 文件删除之后已经打开的句柄依然可以读
+	if err := fs.Remove("f"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadAt(b, 0); err != nil || string(b) != "abc" {
+		t.Fatalf("ReadAt after remove = %q, %v", b, err)
+	}
+	r.Close()
+	if _, err := r.ReadAt(b, 0); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("ReadAt after close: %v", err)
+	}
+}
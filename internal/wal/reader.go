@@ -0,0 +1,130 @@
+package wal
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	"monolithdb/internal/vfs"
+)
+
+// ErrTruncated 表示 Reader 当前 offset 已经超出文件末尾，
+// 通常是 DB.Flush 截断了 WAL。调用方需要从其他来源（SST）补齐数据后再从头读取。
+var ErrTruncated = errors.New("wal: log truncated behind reader")
+
+// 单条记录 key / value 的上限，防止读到写了一半的长度字段时做超大分配
+const maxRecordPayload = 1 << 30
+
+// Reader 从指定 offset 开始读取 WAL，可以追踪（tail）一个正在被写入的日志。
+// 它只读文件，不会和写入方的 WAL 互相加锁。
+//
+// 尾部不完整的记录（写入方还没写完）不会被消费：Next 返回 io.EOF，
+// 之后再调用会从同一个 offset 重新尝试。
+type Reader struct {
+	f   vfs.File
+	off int64
+	dec decoder
+
+	// PollInterval 是 Wait 轮询新数据的间隔，默认 50ms。
+	PollInterval time.Duration
+}
+
+// NewReader 打开 path，从 offset 处开始读取（0 表示从头）。
+func NewReader(path string, offset int64) (*Reader, error) {
+	return NewReaderWithOptions(path, offset, Options{})
+}
+
+// NewReaderWithOptions 与 NewReader 相同，加密的记录用 opts.Keys 解密。
+func NewReaderWithOptions(path string, offset int64, opts Options) (*Reader, error) {
+	f, err := vfs.OrDefault(opts.FS).Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{f: f, off: offset, dec: decoder{keys: opts.Keys}, PollInterval: 50 * time.Millisecond}, nil
+}
+
+// Offset 返回下一条待读记录的起始 offset，可以保存下来供下次 NewReader 续读。
+func (r *Reader) Offset() int64 {
+	return r.off
+}
+
+// Next 读取下一条完整记录。
+// 没有新的完整记录时返回 io.EOF；WAL 被截断到 offset 之前时返回 ErrTruncated。
+func (r *Reader) Next() (Record, error) {
+	st, err := r.f.Stat()
+	if err != nil {
+		return Record{}, err
+	}
+	size := st.Size()
+	if size < r.off {
+		return Record{}, ErrTruncated
+	}
+
+	var hdr [recordHeaderSize]byte
+	if size-r.off < recordHeaderSize {
+		return Record{}, io.EOF
+	}
+	if _, err := r.f.ReadAt(hdr[:], r.off); err != nil {
+		return Record{}, err
+	}
+
+	op := hdr[0]
+	keyLen := binary.LittleEndian.Uint32(hdr[1:5])
+	valLen := binary.LittleEndian.Uint32(hdr[5:9])
+	if uint64(keyLen)+uint64(valLen) > maxRecordPayload {
+		return Record{}, ErrCorruptWAL
+	}
+
+	n := int64(keyLen) + int64(valLen)
+	if size-r.off-recordHeaderSize < n {
+		// 写入方还没写完这条记录
+		return Record{}, io.EOF
+	}
+
+	payload := make([]byte, n)
+	if _, err := r.f.ReadAt(payload, r.off+recordHeaderSize); err != nil {
+		return Record{}, err
+	}
+
+	var valB []byte
+	if valLen > 0 {
+		valB = payload[keyLen:]
+	}
+	rec, err := r.dec.decode(op, payload[:keyLen], valB)
+	if err != nil {
+		return Record{}, err
+	}
+
+	r.off += recordHeaderSize + n
+	return rec, nil
+}
+
+// Wait 阻塞直到读到下一条记录、出现错误或 ctx 结束。
+func (r *Reader) Wait(ctx context.Context) (Record, error) {
+	interval := r.PollInterval
+	if interval <= 0 {
+		interval = 50 * time.Millisecond
+	}
+
+	for {
+		rec, err := r.Next()
+		if !errors.Is(err, io.EOF) {
+			return rec, err
+		}
+
+		t := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return Record{}, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Close 关闭底层文件。
+func (r *Reader) Close() error {
+	return r.f.Close()
+}
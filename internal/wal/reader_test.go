@@ -0,0 +1,112 @@
+package wal
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReaderTailsActiveLog(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "forge.wal")
+
+	w, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := w.AppendPut("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.PollInterval = 5 * time.Millisecond
+
+	rec, err := r.Next()
+	if err != nil || rec.Op != OpPut || rec.Key != "a" {
+		t.Fatalf("expected put a, got %+v err=%v", rec, err)
+	}
+	if _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF at end of log, got %v", err)
+	}
+
+	// Wait 应该能等到之后追加的记录
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = w.AppendDelete("a")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	rec, err = r.Wait(ctx)
+	if err != nil || rec.Op != OpDelete || rec.Key != "a" {
+		t.Fatalf("expected delete a, got %+v err=%v", rec, err)
+	}
+}
+
+func TestReaderSkipsPartialTailAndDetectsTruncation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "forge.wal")
+
+	w, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AppendPut("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+
+	// 模拟写了一半的第二条记录
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{OpPut, 1, 0, 0, 0, 1, 0, 0, 0, 'b'}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	off := r.Offset()
+	if _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF for partial record, got %v", err)
+	}
+	if r.Offset() != off {
+		t.Fatalf("partial record must not advance offset: %d -> %d", off, r.Offset())
+	}
+
+	// 写完这条记录后可以继续读
+	if _, err := f.Write([]byte{'2'}); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+	rec, err := r.Next()
+	if err != nil || rec.Key != "b" || string(rec.Value) != "2" {
+		t.Fatalf("expected put b=2, got %+v err=%v", rec, err)
+	}
+
+	// 截断（模拟 Flush）
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("expected ErrTruncated, got %v", err)
+	}
+}
@@ -2,47 +2,110 @@ package wal
 
 import (
 	"bufio"
+	"bytes"
+	"compress/flate"
 	"encoding/binary"
 	"errors"
 	"io"
 	"os"
 	"sync"
+
+	"monolithdb/internal/encrypt"
+	"monolithdb/internal/snappy"
+	"monolithdb/internal/vfs"
 )
 
 // WAL 是预写日志（Write-Ahead Log）。
 // 作用：写入先追加到日志，崩溃后可通过回放恢复内存状态。
 type WAL struct {
-	mu  sync.Mutex
-	f   *os.File
-	buf *bufio.Writer
+	mu   sync.Mutex
+	f    vfs.File
+	buf  *bufio.Writer
+	keys encrypt.KeyProvider
+	tmp  []byte // 加密时拼装内层记录的缓冲区
+
+	compress bool   // 见 Options.Compress
+	zbuf     []byte // 压缩时复用的缓冲区
+	ztmp     []byte
 }
 
+// Options 控制 WAL 的读写。零值即默认配置。
+type Options struct {
+	// Keys 不为 nil 时，新追加的记录用它的活跃密钥加密成 OpSealed 记录；
+	// 读取时用它解密。读到 OpSealed 记录但 Keys 为 nil 时返回 encrypt.ErrNoKeyProvider。
+	Keys encrypt.KeyProvider
+
+	// Compress 为 true 时，value 区不小于 CompressMinSize 的记录先用 snappy 压缩成 OpSnappy 记录
+	// （同时配置了 Keys 时先压缩再加密）；压缩后没有变小的记录原样写入。
+	// 读取时总是能识别压缩记录（包括旧版本写出的 OpCompressed），与这个选项无关。
+	Compress bool
+
+	// FS 是读写 WAL 文件使用的文件系统，nil 表示操作系统的文件系统（vfs.Default）。
+	FS vfs.FS
+}
+
+// CompressMinSize 是 Options.Compress 尝试压缩的最小 value 区长度，更短的记录压缩收益抵不过开销。
+const CompressMinSize = 256
+
 // Record 表示 WAL 中的一条记录。
 type Record struct {
 	Op    byte
 	Key   string
 	Value []byte
+
+	ExpiresAt int64    // OpPutTTL / OpTouch：新的过期时间（unix 纳秒），0 表示永不过期
+	Keys      []string // OpTouch：本批次涉及的所有 key
+	Batch     []Record // OpBatch：批次内的记录（只会是 OpPut / OpDelete / OpPutTTL）
 }
 
 const (
-	opPut    byte = 0
-	opDelete byte = 1
+	OpPut    byte = 0
+	OpDelete byte = 1
+	OpPutTTL byte = 2 // value 区：| expiresAt(int64) | value |
+	OpTouch  byte = 3 // key 为空；value 区：| expiresAt(int64) | count(uint32) | count x [keyLen(uint32) | key] |
+	OpBatch  byte = 4 // key 为空；value 区：| count(uint32) | count x [op(1B) | keyLen(uint32) | valLen(uint32) | key | val] |
+	OpSealed byte = 5 // key 为空；value 区是 encrypt.Seal 加密后的一条完整记录（op + 长度 + key + value）
+	// OpCompressed 的 key 为空；value 区是 DEFLATE 压缩后的一条完整记录。只会出现在最外层或 OpSealed 里面。
+	// 现在只读取：DEFLATE 每次追加的 CPU 开销太大，新的压缩记录都写成 OpSnappy。
+	OpCompressed byte = 6
+	// OpSnappy 与 OpCompressed 相同，但 value 区是 snappy（block 格式，见 internal/snappy）压缩的。
+	OpSnappy byte = 7
 )
 
+// opFlagEmptyValue 与 op 按位或：表示 value 是空切片而不是 nil（valLen 都是 0，无法区分）
+const opFlagEmptyValue byte = 0x80
+
+// recordHeaderSize = op(1B) + keyLen(uint32) + valLen(uint32)
+const recordHeaderSize = 9
+
 // Open 打开或创建 WAL 文件，准备追加写。
 func Open(path string) (*WAL, error) {
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	return OpenWithOptions(path, Options{})
+}
+
+// OpenWithOptions 与 Open 相同，opts.Keys 不为 nil 时之后追加的记录都会加密。
+// 文件里已有的明文记录保持不变，读取时明文和加密记录可以混在一起。
+func OpenWithOptions(path string, opts Options) (*WAL, error) {
+	f, err := vfs.OrDefault(opts.FS).OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
 
 	if err != nil {
 		return nil, err
 	}
 
 	return &WAL{
-		f:   f,
-		buf: bufio.NewWriterSize(f, 64*1024),
+		f:        f,
+		buf:      bufio.NewWriterSize(f, 64*1024),
+		keys:     opts.Keys,
+		compress: opts.Compress,
 	}, nil
 }
 
+// Discard 返回一个丢弃所有记录的 WAL：追加和 Sync 总是成功，但什么也不写。
+// 用于不需要持久化、也不需要崩溃恢复的数据库（见 db.OpenInMemory）。
+func Discard() *WAL {
+	return &WAL{buf: bufio.NewWriterSize(io.Discard, 4096)}
+}
+
 // Close 关闭 WAL（会先 Flush 缓冲区）。
 func (w *WAL) Close() error {
 	w.mu.Lock()
@@ -59,109 +122,351 @@ func (w *WAL) Close() error {
 	return nil
 }
 
-// AppendPut 追加一条 Put 记录到 WAL 文件。
-// 记录格式：| op(1B) | keyLen(uint32) | valLen(uint32) | key bytes | val bytes |
-func (w *WAL) AppendPut(key string, value []byte) error {
+// Sync 把缓冲区写入文件并 fsync，保证已追加的记录掉电后不丢。
+func (w *WAL) Sync() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	// 1) op
-	if err := w.buf.WriteByte(opPut); err != nil {
+	if err := w.buf.Flush(); err != nil {
 		return err
 	}
+	if w.f == nil {
+		return nil
+	}
+	return w.f.Sync()
+}
 
-	// 2) keyLen / valLen
-	keyB := []byte(key)
-	if err := binary.Write(w.buf, binary.LittleEndian, uint32(len(keyB))); err != nil {
-		return err
+// AppendPut 追加一条 Put 记录到 WAL 文件。
+// 记录格式：| op(1B) | keyLen(uint32) | valLen(uint32) | key bytes | val bytes |
+func (w *WAL) AppendPut(key string, value []byte) error {
+	op := OpPut
+	if value != nil && len(value) == 0 {
+		op |= opFlagEmptyValue
 	}
-	if err := binary.Write(w.buf, binary.LittleEndian, uint32(len(value))); err != nil {
-		return err
+	return w.append(op, key, value)
+}
+
+// AppendPutTTL 追加一条带过期时间的 Put 记录。
+func (w *WAL) AppendPutTTL(key string, value []byte, expiresAt int64) error {
+	payload := binary.LittleEndian.AppendUint64(nil, uint64(expiresAt))
+	payload = append(payload, value...)
+	op := OpPutTTL
+	if value != nil && len(value) == 0 {
+		op |= opFlagEmptyValue
 	}
+	return w.append(op, key, payload)
+}
 
-	// 3) key bytes / value bytes
-	if _, err := w.buf.Write(keyB); err != nil {
-		return err
+// AppendDelete 追加一条 Delete 记录到 WAL 文件。
+// 记录格式：| op(1B) | keyLen(uint32) | valLen(uint32=0) | key bytes |
+func (w *WAL) AppendDelete(key string) error {
+	return w.append(OpDelete, key, nil)
+}
+
+// AppendTouch 把一批 key 的过期时间更新写成一条记录（不包含 value）。
+// 整批要么完整落盘、要么在回放时被当作损坏尾部丢弃。
+func (w *WAL) AppendTouch(keys []string, expiresAt int64) error {
+	payload := binary.LittleEndian.AppendUint64(nil, uint64(expiresAt))
+	payload = binary.LittleEndian.AppendUint32(payload, uint32(len(keys)))
+	for _, k := range keys {
+		payload = binary.LittleEndian.AppendUint32(payload, uint32(len(k)))
+		payload = append(payload, k...)
 	}
-	if len(value) > 0 {
-		if _, err := w.buf.Write(value); err != nil {
-			return err
+	return w.append(OpTouch, "", payload)
+}
+
+// ErrInvalidBatch 表示 AppendBatch 收到了不能放进批次的记录（如 OpTouch 或嵌套的 OpBatch）。
+var ErrInvalidBatch = errors.New("wal: invalid batch record")
+
+// AppendBatch 把多条 Put / Delete / PutTTL 记录写成一条 OpBatch 记录。
+// 批次内每条子记录的编码与单独写入时相同；整批要么完整落盘，要么在回放时整体丢弃。
+func (w *WAL) AppendBatch(recs []Record) error {
+	payload := binary.LittleEndian.AppendUint32(nil, uint32(len(recs)))
+	for _, r := range recs {
+		op, val, ok := encodeSimple(r)
+		if !ok {
+			return ErrInvalidBatch
 		}
+		payload = append(payload, op)
+		payload = binary.LittleEndian.AppendUint32(payload, uint32(len(r.Key)))
+		payload = binary.LittleEndian.AppendUint32(payload, uint32(len(val)))
+		payload = append(payload, r.Key...)
+		payload = append(payload, val...)
 	}
+	return w.append(OpBatch, "", payload)
+}
 
-	return w.buf.Flush()
+// encodeSimple 返回 Put / Delete / PutTTL 记录的 op 字节和 value 区，与 AppendPut 等的编码一致。
+func encodeSimple(r Record) (op byte, val []byte, ok bool) {
+	emptyFlag := func(op byte) byte {
+		if r.Value != nil && len(r.Value) == 0 {
+			return op | opFlagEmptyValue
+		}
+		return op
+	}
+	switch r.Op {
+	case OpPut:
+		return emptyFlag(OpPut), r.Value, true
+	case OpDelete:
+		return OpDelete, nil, true
+	case OpPutTTL:
+		val = binary.LittleEndian.AppendUint64(nil, uint64(r.ExpiresAt))
+		return emptyFlag(OpPutTTL), append(val, r.Value...), true
+	default:
+		return 0, nil, false
+	}
 }
 
-// AppendDelete 追加一条 Delete 记录到 WAL 文件。
-// 记录格式：| op(1B) | keyLen(uint32) | valLen(uint32=0) | key bytes |
-func (w *WAL) AppendDelete(key string) error {
+// append 按统一格式写入一条记录并 Flush。开启压缩时整条记录压缩后包成 OpSnappy 记录，
+// 配置了密钥时再整条加密后包成 OpSealed 记录。
+func (w *WAL) append(op byte, key string, val []byte) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	if w.compress && len(val) >= CompressMinSize {
+		if z, ok := w.snappy(op, key, val); ok {
+			op, key, val = OpSnappy, "", z
+		}
+	}
+	if w.keys != nil {
+		w.tmp = appendFrame(w.tmp[:0], op, key, val)
+		sealed, err := encrypt.Seal(w.keys, w.tmp, nil)
+		if err != nil {
+			return err
+		}
+		op, key, val = OpSealed, "", sealed
+	}
+
 	// 1) op
-	if err := w.buf.WriteByte(opDelete); err != nil {
+	if err := w.buf.WriteByte(op); err != nil {
 		return err
 	}
 
-	// 2) keyLen / valLen(=0)
+	// 2) keyLen / valLen
 	keyB := []byte(key)
 	if err := binary.Write(w.buf, binary.LittleEndian, uint32(len(keyB))); err != nil {
 		return err
 	}
-	if err := binary.Write(w.buf, binary.LittleEndian, uint32(0)); err != nil {
+	if err := binary.Write(w.buf, binary.LittleEndian, uint32(len(val))); err != nil {
 		return err
 	}
 
-	// 3) key bytes
+	// 3) key bytes / value bytes
 	if _, err := w.buf.Write(keyB); err != nil {
 		return err
 	}
+	if len(val) > 0 {
+		if _, err := w.buf.Write(val); err != nil {
+			return err
+		}
+	}
 
 	return w.buf.Flush()
 }
 
+// snappy 把整条记录压缩成 OpSnappy 的 value 区；压缩后没有变小时返回 false。
+func (w *WAL) snappy(op byte, key string, val []byte) ([]byte, bool) {
+	w.ztmp = appendFrame(w.ztmp[:0], op, key, val)
+	w.zbuf = snappy.Encode(w.zbuf[:0], w.ztmp)
+	if len(w.zbuf) >= len(w.ztmp) {
+		return nil, false
+	}
+	// zbuf 在下一次 append 时复用，这里返回的切片只在本次 append 内使用
+	return w.zbuf, true
+}
+
+// appendFrame 把一条记录按 | op | keyLen | valLen | key | val | 编码后追加到 dst。
+func appendFrame(dst []byte, op byte, key string, val []byte) []byte {
+	dst = append(dst, op)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(key)))
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(val)))
+	dst = append(dst, key...)
+	return append(dst, val...)
+}
+
 var ErrCorruptWAL = errors.New("wal: corrupt record")
 
+// decoder 把 op / key / value 区解析成 Record，OpSealed 记录先用 keys 解密。
+// opaque 为 true 时（Dump 使用），没有密钥的加密记录原样返回 Op 为 OpSealed 的 Record，而不是报错。
+type decoder struct {
+	keys   encrypt.KeyProvider
+	opaque bool
+}
+
+func (d decoder) decode(op byte, keyB, valB []byte) (Record, error) {
+	switch op {
+	case OpSealed:
+		if len(keyB) != 0 {
+			return Record{}, ErrCorruptWAL
+		}
+		inner, err := encrypt.Open(d.keys, valB, nil)
+		switch {
+		case errors.Is(err, encrypt.ErrDecrypt):
+			return Record{}, ErrCorruptWAL
+		case err != nil && d.opaque:
+			return Record{Op: OpSealed, Value: valB}, nil
+		case err != nil:
+			return Record{}, err
+		}
+		return decodeFrame(inner, true)
+	case OpCompressed, OpSnappy:
+		return decodeCompressed(op, keyB, valB)
+	}
+	rec, ok := decodeRecord(op, keyB, valB)
+	if !ok {
+		return rec, ErrCorruptWAL
+	}
+	return rec, nil
+}
+
+// decodeCompressed 解压 OpCompressed / OpSnappy 记录的 value 区，里面必须是一条未压缩、未加密的完整记录。
+func decodeCompressed(op byte, keyB, valB []byte) (Record, error) {
+	if len(keyB) != 0 {
+		return Record{}, ErrCorruptWAL
+	}
+	var inner []byte
+	var err error
+	if op == OpSnappy {
+		inner, err = snappy.Decode(valB)
+	} else {
+		inner, err = io.ReadAll(flate.NewReader(bytes.NewReader(valB)))
+	}
+	if err != nil {
+		return Record{}, ErrCorruptWAL
+	}
+	return decodeFrame(inner, false)
+}
+
+// decodeFrame 解析 OpSealed / OpCompressed 包着的内层记录：必须恰好是一条完整的记录，
+// 且不能再是加密记录；allowCompressed 为 true 时（加密记录的内层）可以是压缩记录。
+func decodeFrame(inner []byte, allowCompressed bool) (Record, error) {
+	if len(inner) < recordHeaderSize {
+		return Record{}, ErrCorruptWAL
+	}
+	compressed := inner[0] == OpCompressed || inner[0] == OpSnappy
+	if inner[0] == OpSealed || (compressed && !allowCompressed) {
+		return Record{}, ErrCorruptWAL
+	}
+	keyLen := uint64(binary.LittleEndian.Uint32(inner[1:]))
+	valLen := uint64(binary.LittleEndian.Uint32(inner[5:]))
+	body := inner[recordHeaderSize:]
+	if keyLen+valLen != uint64(len(body)) {
+		return Record{}, ErrCorruptWAL
+	}
+	var innerVal []byte
+	if valLen > 0 {
+		innerVal = body[keyLen:]
+	}
+	if compressed {
+		return decodeCompressed(inner[0], body[:keyLen], innerVal)
+	}
+	rec, ok := decodeRecord(inner[0], body[:keyLen], innerVal)
+	if !ok {
+		return rec, ErrCorruptWAL
+	}
+	return rec, nil
+}
+
 // Replay 读取整个 WAL 文件并解析成 Record 列表。
 func Replay(path string) ([]Record, error) {
-	f, err := os.Open(path)
+	return ReplayWithOptions(path, Options{})
+}
+
+// ReplayWithOptions 与 Replay 相同，加密的记录用 opts.Keys 解密。
+func ReplayWithOptions(path string, opts Options) ([]Record, error) {
+	out, _, err := replay(opts.FS, path, decoder{keys: opts.Keys})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReplayValid 和 Replay 类似，但遇到损坏不会整体失败：
+// 返回损坏位置之前的所有完整记录，以及这些记录占用的字节数 validSize。
+// 若 validSize 小于文件大小，说明从 validSize 开始的内容无法解析。
+func ReplayValid(path string) (records []Record, validSize int64, err error) {
+	return ReplayValidWithOptions(path, Options{})
+}
+
+// ReplayValidWithOptions 与 ReplayValid 相同，加密的记录用 opts.Keys 解密。
+// 缺少密钥不算损坏，直接返回错误，不会把后面的记录当成尾部丢掉。
+func ReplayValidWithOptions(path string, opts Options) (records []Record, validSize int64, err error) {
+	out, off, err := replay(opts.FS, path, decoder{keys: opts.Keys})
+	if err != nil && !errors.Is(err, ErrCorruptWAL) {
+		return nil, 0, err
+	}
+	return out, off, nil
+}
+
+// replay 逐条解析 WAL，返回已成功解析的记录和它们结束的 offset。
+// 遇到损坏时同时返回已解析部分和 ErrCorruptWAL。
+func replay(fs vfs.FS, path string, dec decoder) ([]Record, int64, error) {
+	var out []Record
+	off, err := scan(fs, path, dec, func(_ int64, rec Record) bool {
+		out = append(out, rec)
+		return true
+	})
+	return out, off, err
+}
+
+// scan 逐条解码 WAL，对每条完整记录调用 fn(记录起始 offset, 记录)。
+// fn 返回 false 时提前停止。返回值是最后一条成功解码记录的结束 offset。fs 为 nil 时使用 vfs.Default。
+func scan(fs vfs.FS, path string, dec decoder, fn func(off int64, rec Record) bool) (int64, error) {
+	f, err := vfs.OrDefault(fs).Open(path)
 	if err != nil {
 		// WAL 不存在就当作空
 		if os.IsNotExist(err) {
-			return nil, nil
+			return 0, nil
 		}
-		return nil, err
+		return 0, err
 	}
 	defer f.Close()
 
-	r := bufio.NewReaderSize(f, 64*1024)
-	var out []Record
+	return scanReader(bufio.NewReaderSize(f, 64*1024), dec, fn)
+}
+
+// Scan 从 r 中逐条解码 WAL 记录并调用 fn，fn 返回 false 时提前停止。
+// 末尾不完整或损坏的记录返回 ErrCorruptWAL。
+func Scan(r io.Reader, fn func(rec Record) bool) error {
+	return ScanWithOptions(r, Options{}, fn)
+}
+
+// ScanWithOptions 与 Scan 相同，加密的记录用 opts.Keys 解密。
+func ScanWithOptions(r io.Reader, opts Options, fn func(rec Record) bool) error {
+	_, err := scanReader(bufio.NewReaderSize(r, 64*1024), decoder{keys: opts.Keys}, func(_ int64, rec Record) bool {
+		return fn(rec)
+	})
+	return err
+}
+
+func scanReader(r *bufio.Reader, dec decoder, fn func(off int64, rec Record) bool) (int64, error) {
+	var off int64
 
 	for {
 		// 1) 读 op
 		op, err := r.ReadByte()
 		if err != nil {
 			if errors.Is(err, io.EOF) {
-				return out, nil
+				return off, nil
 			}
-			return nil, err
+			return off, err
 		}
 
 		// 2) 读 keyLen / valLen
 		var keyLen uint32
 		var valLen uint32
 		if err := binary.Read(r, binary.LittleEndian, &keyLen); err != nil {
-			return nil, ErrCorruptWAL
+			return off, ErrCorruptWAL
 		}
 		if err := binary.Read(r, binary.LittleEndian, &valLen); err != nil {
-			return nil, ErrCorruptWAL
+			return off, ErrCorruptWAL
 		}
 
 		// 3) 读 key bytes
 		keyB := make([]byte, keyLen)
 		// io.ReadFull(r,keyB)：必须把 keyB 填满，否则就返回错误
 		if _, err := io.ReadFull(r, keyB); err != nil {
-			return nil, ErrCorruptWAL
+			return off, ErrCorruptWAL
 		}
 
 		// 4) 读 value bytes（delete 的 valLen=0）
@@ -169,19 +474,102 @@ func Replay(path string) ([]Record, error) {
 		if valLen > 0 {
 			valB = make([]byte, valLen)
 			if _, err = io.ReadFull(r, valB); err != nil {
-				return nil, ErrCorruptWAL
+				return off, ErrCorruptWAL
 			}
 		}
 
-		// 5) 简单校验 op
-		if op != opPut && op != opDelete {
-			return nil, ErrCorruptWAL
+		// 5) 按 op 解析 value 区
+		rec, err := dec.decode(op, keyB, valB)
+		if err != nil {
+			return off, err
+		}
+		if !fn(off, rec) {
+			return off, nil
 		}
+		off += int64(recordHeaderSize) + int64(keyLen) + int64(valLen)
+	}
+}
 
-		out = append(out, Record{
-			Op:    op,
-			Key:   string(keyB),
-			Value: valB,
-		})
+// decodeRecord 根据 op 解析 value 区里的附加字段。
+func decodeRecord(op byte, keyB, valB []byte) (Record, bool) {
+	empty := op&opFlagEmptyValue != 0
+	op &^= opFlagEmptyValue
+	rec := Record{Op: op, Key: string(keyB)}
+
+	switch op {
+	case OpPut, OpDelete:
+		rec.Value = valB
+	case OpPutTTL:
+		if len(valB) < 8 {
+			return rec, false
+		}
+		rec.ExpiresAt = int64(binary.LittleEndian.Uint64(valB))
+		if len(valB) > 8 {
+			rec.Value = valB[8:]
+		}
+	case OpTouch:
+		if len(keyB) != 0 || len(valB) < 12 {
+			return rec, false
+		}
+		rec.ExpiresAt = int64(binary.LittleEndian.Uint64(valB))
+		count := binary.LittleEndian.Uint32(valB[8:])
+		p := valB[12:]
+		for i := uint32(0); i < count; i++ {
+			if len(p) < 4 {
+				return rec, false
+			}
+			n := binary.LittleEndian.Uint32(p)
+			p = p[4:]
+			if uint64(n) > uint64(len(p)) {
+				return rec, false
+			}
+			rec.Keys = append(rec.Keys, string(p[:n]))
+			p = p[n:]
+		}
+		if len(p) != 0 {
+			return rec, false
+		}
+	case OpBatch:
+		if len(keyB) != 0 || len(valB) < 4 {
+			return rec, false
+		}
+		count := binary.LittleEndian.Uint32(valB)
+		p := valB[4:]
+		for i := uint32(0); i < count; i++ {
+			if len(p) < recordHeaderSize {
+				return rec, false
+			}
+			subOp := p[0]
+			kl := uint64(binary.LittleEndian.Uint32(p[1:]))
+			vl := uint64(binary.LittleEndian.Uint32(p[5:]))
+			p = p[recordHeaderSize:]
+			if kl+vl > uint64(len(p)) {
+				return rec, false
+			}
+			var subVal []byte
+			if vl > 0 {
+				subVal = p[kl : kl+vl]
+			}
+			sub, ok := decodeRecord(subOp, p[:kl], subVal)
+			if !ok || (sub.Op != OpPut && sub.Op != OpDelete && sub.Op != OpPutTTL) {
+				return rec, false
+			}
+			rec.Batch = append(rec.Batch, sub)
+			p = p[kl+vl:]
+		}
+		if len(p) != 0 {
+			return rec, false
+		}
+	default:
+		return rec, false
+	}
+
+	// 空 value 标记只对 put 类记录有意义，且 value 必须真的为空
+	if empty {
+		if (op != OpPut && op != OpPutTTL) || len(rec.Value) != 0 {
+			return rec, false
+		}
+		rec.Value = []byte{}
 	}
+	return rec, true
 }
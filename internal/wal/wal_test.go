@@ -2,8 +2,14 @@ package wal
 
 import (
 	"bytes"
+	"compress/flate"
+	"errors"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"monolithdb/internal/encrypt"
 )
 
 func TestWALAppendAndReplay(t *testing.T) {
@@ -37,17 +43,278 @@ func TestWALAppendAndReplay(t *testing.T) {
 	}
 
 	// record 0: put a=1
-	if records[0].Op != opPut || records[0].Key != "a" || !bytes.Equal(records[0].Value, []byte("1")) {
+	if records[0].Op != OpPut || records[0].Key != "a" || !bytes.Equal(records[0].Value, []byte("1")) {
 		t.Fatalf("unexpected record[0]: %+v", records[0])
 	}
 
 	// record 1: put b=hello
-	if records[1].Op != opPut || records[1].Key != "b" || !bytes.Equal(records[1].Value, []byte("hello")) {
+	if records[1].Op != OpPut || records[1].Key != "b" || !bytes.Equal(records[1].Value, []byte("hello")) {
 		t.Fatalf("unexpected record[1]: %+v", records[1])
 	}
 
 	// record 2: delete a
-	if records[2].Op != opDelete || records[2].Key != "a" || len(records[2].Value) != 0 {
+	if records[2].Op != OpDelete || records[2].Key != "a" || len(records[2].Value) != 0 {
 		t.Fatalf("unexpected record[2]: %+v", records[2])
 	}
 }
+
+func TestReplayValidAndDumpReportCorruption(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "forge.wal")
+
+	w, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AppendPut("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AppendDelete("bb"); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+
+	// put a=1 占 9+1+1，delete bb 占 9+2
+	goodSize := int64(recordHeaderSize+2) + int64(recordHeaderSize+2)
+
+	// 追加一条不完整的记录
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{OpPut, 3, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	if _, err := Replay(path); err != ErrCorruptWAL {
+		t.Fatalf("expected ErrCorruptWAL from Replay, got %v", err)
+	}
+
+	records, valid, err := ReplayValid(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || valid != goodSize {
+		t.Fatalf("expected 2 records / %d valid bytes, got %d / %d", goodSize, len(records), valid)
+	}
+
+	var buf bytes.Buffer
+	sum, err := Dump(path, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Puts != 1 || sum.Deletes != 1 || !sum.Corrupt || sum.ValidSize != goodSize {
+		t.Fatalf("unexpected summary: %+v", sum)
+	}
+	out := buf.String()
+	for _, want := range []string{`@0 put key="a"`, `@11 del key="bb"`, "corruption at offset 22"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected dump to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestAppendBatchRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forge.wal")
+	w, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	batch := []Record{
+		{Op: OpPut, Key: "a", Value: []byte("1")},
+		{Op: OpPut, Key: "empty", Value: []byte{}},
+		{Op: OpDelete, Key: "b"},
+		{Op: OpPutTTL, Key: "c", Value: []byte("3"), ExpiresAt: 42},
+	}
+	if err := w.AppendBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AppendBatch([]Record{{Op: OpTouch, Keys: []string{"a"}}}); err != ErrInvalidBatch {
+		t.Fatalf("expected ErrInvalidBatch for touch in batch, got %v", err)
+	}
+
+	records, err := Replay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Op != OpBatch || len(records[0].Batch) != len(batch) {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+	got := records[0].Batch
+	if got[0].Key != "a" || !bytes.Equal(got[0].Value, []byte("1")) {
+		t.Fatalf("sub[0] = %+v", got[0])
+	}
+	if got[1].Value == nil || len(got[1].Value) != 0 {
+		t.Fatalf("empty value not preserved: %+v", got[1])
+	}
+	if got[2].Op != OpDelete || got[2].Key != "b" {
+		t.Fatalf("sub[2] = %+v", got[2])
+	}
+	if got[3].Op != OpPutTTL || got[3].ExpiresAt != 42 || string(got[3].Value) != "3" {
+		t.Fatalf("sub[3] = %+v", got[3])
+	}
+}
+
+func TestSealedRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forge.wal")
+	keys, err := encrypt.NewStaticKeys("k1", bytes.Repeat([]byte{7}, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 先写一条明文记录，再以加密方式重新打开追加：两种记录可以混在一个文件里
+	w, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AppendPut("plain", []byte("v0")); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+	w, err = OpenWithOptions(path, Options{Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AppendPut("secret", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AppendBatch([]Record{{Op: OpDelete, Key: "plain"}}); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+
+	raw, _ := os.ReadFile(path)
+	if bytes.Contains(raw, []byte("secret")) {
+		t.Fatal("sealed record leaks its key")
+	}
+
+	records, err := ReplayWithOptions(path, Options{Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[1].Key != "secret" || string(records[1].Value) != "v1" ||
+		records[2].Op != OpBatch || records[2].Batch[0].Key != "plain" {
+		t.Fatalf("records = %+v", records)
+	}
+
+	if _, err := Replay(path); !errors.Is(err, encrypt.ErrNoKeyProvider) {
+		t.Fatalf("replay without keys: err = %v", err)
+	}
+	if _, _, err := ReplayValid(path); !errors.Is(err, encrypt.ErrNoKeyProvider) {
+		t.Fatalf("missing keys must not be treated as a corrupt tail: err = %v", err)
+	}
+
+	var out bytes.Buffer
+	sum, err := Dump(path, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Records != 3 || sum.Sealed != 2 || sum.Corrupt || !strings.Contains(out.String(), `sealed key-id="k1"`) {
+		t.Fatalf("dump summary = %+v\n%s", sum, out.String())
+	}
+}
+
+func TestCompressedRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forge.wal")
+	doc := []byte(strings.Repeat(`{"name":"forge","tags":["a","b"],"count":42},`, 100))
+
+	w, err := OpenWithOptions(path, Options{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AppendPut("small", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AppendPut("doc", doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AppendBatch([]Record{{Op: OpPut, Key: "b1", Value: doc}, {Op: OpDelete, Key: "small"}}); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+
+	// 再以加密 + 压缩的方式追加：先压缩后加密
+	keys, err := encrypt.NewStaticKeys("k1", bytes.Repeat([]byte{7}, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err = OpenWithOptions(path, Options{Keys: keys, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AppendPutTTL("sealed", doc, 99); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+
+	st, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Size() >= int64(len(doc)) {
+		t.Fatalf("wal size = %d, want well below one uncompressed value (%d)", st.Size(), len(doc))
+	}
+
+	records, err := ReplayWithOptions(path, Options{Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[0].Key != "small" || string(records[0].Value) != "v" ||
+		!bytes.Equal(records[1].Value, doc) ||
+		records[2].Op != OpBatch || !bytes.Equal(records[2].Batch[0].Value, doc) || records[2].Batch[1].Op != OpDelete ||
+		records[3].Op != OpPutTTL || records[3].ExpiresAt != 99 || !bytes.Equal(records[3].Value, doc) {
+		t.Fatalf("records = %+v", records)
+	}
+
+	// 压缩记录的内容被破坏时按损坏处理，ReplayValid 截在它之前
+	raw, _ := os.ReadFile(path)
+	first := recordHeaderSize + len("small") + 1
+	if raw[first] != OpSnappy {
+		t.Fatalf("compressed record op = %d, want OpSnappy", raw[first])
+	}
+	raw[first+recordHeaderSize+10] ^= 0xff
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	records, valid, err := ReplayValidWithOptions(path, Options{Keys: keys})
+	if err != nil || len(records) != 1 || valid != int64(first) {
+		t.Fatalf("ReplayValid = %d records, valid=%d, err=%v", len(records), valid, err)
+	}
+}
+
+func TestReadDeflateRecords(t *testing.T) {
+	// 旧版本写出的 OpCompressed（DEFLATE）记录仍然能读
+	doc := []byte(strings.Repeat(`{"name":"forge"},`, 50))
+	var z bytes.Buffer
+	zw, _ := flate.NewWriter(&z, flate.BestSpeed)
+	_, _ = zw.Write(appendFrame(nil, OpPut, "doc", doc))
+	_ = zw.Close()
+
+	path := filepath.Join(t.TempDir(), "forge.wal")
+	if err := os.WriteFile(path, appendFrame(nil, OpCompressed, "", z.Bytes()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	records, err := Replay(path)
+	if err != nil || len(records) != 1 || records[0].Key != "doc" || !bytes.Equal(records[0].Value, doc) {
+		t.Fatalf("Replay = %+v, %v", records, err)
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	w := Discard()
+	if err := w.AppendPut("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AppendBatch([]Record{{Op: OpDelete, Key: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}